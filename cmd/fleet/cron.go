@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/policies"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
 	"github.com/fleetdm/fleet/v4/server/service/schedule"
 	"github.com/fleetdm/fleet/v4/server/vulnerabilities"
@@ -169,17 +171,73 @@ func scanVulnerabilities(
 		}
 	}
 
-	level.Debug(logger).Log("vulnAutomationEnabled", vulnAutomationEnabled)
+	// check for generic webhook integrations
+	for _, w := range appConfig.Integrations.Webhook {
+		if w.EnableSoftwareVulnerabilities {
+			if vulnAutomationEnabled != "" {
+				err := ctxerr.New(ctx, "webhook integration check")
+				errHandler(ctx, logger, "more than one automation enabled", err)
+			}
+			vulnAutomationEnabled = "webhook_integration"
+			break
+		}
+	}
+
+	// check for Slack integrations
+	for _, sl := range appConfig.Integrations.Slack {
+		if sl.EnableSoftwareVulnerabilities {
+			if vulnAutomationEnabled != "" {
+				err := ctxerr.New(ctx, "slack check")
+				errHandler(ctx, logger, "more than one automation enabled", err)
+			}
+			vulnAutomationEnabled = "slack"
+			break
+		}
+	}
 
-	collectVulns := vulnAutomationEnabled != ""
+	// check for Microsoft Teams integrations
+	for _, mt := range appConfig.Integrations.MicrosoftTeams {
+		if mt.EnableSoftwareVulnerabilities {
+			if vulnAutomationEnabled != "" {
+				err := ctxerr.New(ctx, "microsoft teams check")
+				errHandler(ctx, logger, "more than one automation enabled", err)
+			}
+			vulnAutomationEnabled = "microsoft_teams"
+			break
+		}
+	}
+
+	// the teams' vulnerabilities webhooks can be enabled regardless of the
+	// global automation.
+	teamVulnWebhookEnabled, err := webhooks.TeamVulnerabilitiesWebhookEnabled(ctx, ds)
+	if err != nil {
+		errHandler(ctx, logger, "checking teams vulnerabilities webhooks", err)
+	}
+
+	// the teams' Jira and Zendesk integrations create one ticket per team, with
+	// the affected hosts of that team.
+	teamVulnIntgs, err := worker.LoadTeamVulnIntegrations(ctx, ds)
+	if err != nil {
+		errHandler(ctx, logger, "loading teams vulnerabilities integrations", err)
+	}
+	teamVulnAutomationEnabled := teamVulnWebhookEnabled || teamVulnIntgs.Enabled()
+
+	level.Debug(logger).Log(
+		"vulnAutomationEnabled", vulnAutomationEnabled,
+		"teamVulnWebhookEnabled", teamVulnWebhookEnabled,
+		"teamVulnJiraCount", len(teamVulnIntgs.JiraTeamIDs),
+		"teamVulnZendeskCount", len(teamVulnIntgs.ZendeskTeamIDs),
+	)
+
+	collectVulns := vulnAutomationEnabled != "" || teamVulnAutomationEnabled
 	nvdVulns := checkNVDVulnerabilities(ctx, ds, logger, vulnPath, config, collectVulns)
 	ovalVulns := checkOvalVulnerabilities(ctx, ds, logger, vulnPath, config, collectVulns)
 	recentVulns := filterRecentVulns(ctx, ds, logger, nvdVulns, ovalVulns, config.RecentVulnerabilityMaxAge)
 
 	if len(recentVulns) > 0 {
-		switch vulnAutomationEnabled {
-		case "webhook":
-			// send recent vulnerabilities via webhook
+		if vulnAutomationEnabled == "webhook" || teamVulnWebhookEnabled {
+			// send recent vulnerabilities via the global webhook, if enabled, and
+			// the teams' webhooks.
 			if err := webhooks.TriggerVulnerabilitiesWebhook(
 				ctx,
 				ds,
@@ -189,6 +247,11 @@ func scanVulnerabilities(
 				time.Now()); err != nil {
 				errHandler(ctx, logger, "triggering vulnerabilities webhook", err)
 			}
+		}
+
+		switch vulnAutomationEnabled {
+		case "webhook":
+			// already sent above, along with the teams' webhooks
 
 		case "jira":
 			// queue job to create jira issues
@@ -212,9 +275,64 @@ func scanVulnerabilities(
 				errHandler(ctx, logger, "queueing vulnerabilities to Zendesk", err)
 			}
 
+		case "webhook_integration":
+			// queue job to send the webhook integration requests
+			if err := worker.QueueWebhookVulnJobs(
+				ctx,
+				ds,
+				kitlog.With(logger, "webhook_integration", "vulnerabilities"),
+				recentVulns,
+			); err != nil {
+				errHandler(ctx, logger, "queueing vulnerabilities to webhook integration", err)
+			}
+
+		case "slack":
+			// queue job to post the Slack messages
+			if err := worker.QueueSlackVulnJobs(
+				ctx,
+				ds,
+				kitlog.With(logger, "slack", "vulnerabilities"),
+				recentVulns,
+			); err != nil {
+				errHandler(ctx, logger, "queueing vulnerabilities to Slack", err)
+			}
+
+		case "microsoft_teams":
+			// queue job to post the Microsoft Teams messages
+			if err := worker.QueueMicrosoftTeamsVulnJobs(
+				ctx,
+				ds,
+				kitlog.With(logger, "microsoft_teams", "vulnerabilities"),
+				recentVulns,
+			); err != nil {
+				errHandler(ctx, logger, "queueing vulnerabilities to Microsoft Teams", err)
+			}
+
 		default:
-			err = ctxerr.New(ctx, "no vuln automations enabled")
-			errHandler(ctx, logger, "attempting to process vuln automations", err)
+			if !teamVulnAutomationEnabled {
+				err = ctxerr.New(ctx, "no vuln automations enabled")
+				errHandler(ctx, logger, "attempting to process vuln automations", err)
+			}
+		}
+
+		// queue jobs to create the teams' Jira issues and Zendesk tickets
+		if err := worker.QueueJiraTeamVulnJobs(
+			ctx,
+			ds,
+			kitlog.With(logger, "jira", "team_vulnerabilities"),
+			recentVulns,
+			teamVulnIntgs.JiraTeamIDs,
+		); err != nil {
+			errHandler(ctx, logger, "queueing team vulnerabilities to jira", err)
+		}
+		if err := worker.QueueZendeskTeamVulnJobs(
+			ctx,
+			ds,
+			kitlog.With(logger, "zendesk", "team_vulnerabilities"),
+			recentVulns,
+			teamVulnIntgs.ZendeskTeamIDs,
+		); err != nil {
+			errHandler(ctx, logger, "queueing team vulnerabilities to Zendesk", err)
 		}
 	}
 
@@ -356,6 +474,7 @@ func cronWebhooks(
 	logger kitlog.Logger,
 	identifier string,
 	failingPoliciesSet fleet.FailingPolicySet,
+	passingPoliciesSet fleet.FailingPolicySet,
 	intervalReload time.Duration,
 ) {
 	appConfig, err := ds.AppConfig(ctx)
@@ -400,7 +519,7 @@ func cronWebhooks(
 
 		// We set the db lock durations to match the intervalReload.
 		maybeTriggerHostStatus(ctx, ds, logger, identifier, appConfig, intervalReload)
-		maybeTriggerFailingPoliciesAutomation(ctx, ds, logger, identifier, appConfig, intervalReload, failingPoliciesSet)
+		maybeTriggerFailingPoliciesAutomation(ctx, ds, logger, identifier, appConfig, intervalReload, failingPoliciesSet, passingPoliciesSet)
 
 		level.Debug(logger).Log("loop", "done")
 	}
@@ -439,6 +558,7 @@ func maybeTriggerFailingPoliciesAutomation(
 	appConfig *fleet.AppConfig,
 	lockDuration time.Duration,
 	failingPoliciesSet fleet.FailingPolicySet,
+	passingPoliciesSet fleet.FailingPolicySet,
 ) {
 	logger = kitlog.With(logger, "cron", lockKeyWebhooksFailingPolicies)
 
@@ -461,7 +581,7 @@ func maybeTriggerFailingPoliciesAutomation(
 		switch cfg.AutomationType {
 		case policies.FailingPolicyWebhook:
 			return webhooks.SendFailingPoliciesBatchedPOSTs(
-				ctx, policy, failingPoliciesSet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, time.Now(), logger)
+				ctx, ds, policy, failingPoliciesSet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, cfg.WebhookSecret, time.Now(), logger)
 
 		case policies.FailingPolicyJira:
 			hosts, err := failingPoliciesSet.ListHosts(policy.ID)
@@ -486,12 +606,54 @@ func maybeTriggerFailingPoliciesAutomation(
 			if err := failingPoliciesSet.RemoveHosts(policy.ID, hosts); err != nil {
 				return ctxerr.Wrapf(ctx, err, "removing %d hosts from failing policies set %d", len(hosts), policy.ID)
 			}
+
+		case policies.FailingPolicyWebhookIntegration:
+			hosts, err := failingPoliciesSet.ListHosts(policy.ID)
+			if err != nil {
+				return ctxerr.Wrapf(ctx, err, "listing hosts for failing policies set %d", policy.ID)
+			}
+			if err := worker.QueueWebhookFailingPolicyJob(ctx, ds, logger, policy, hosts); err != nil {
+				return err
+			}
+			if err := failingPoliciesSet.RemoveHosts(policy.ID, hosts); err != nil {
+				return ctxerr.Wrapf(ctx, err, "removing %d hosts from failing policies set %d", len(hosts), policy.ID)
+			}
+
+		case policies.FailingPolicySlack:
+			hosts, err := failingPoliciesSet.ListHosts(policy.ID)
+			if err != nil {
+				return ctxerr.Wrapf(ctx, err, "listing hosts for failing policies set %d", policy.ID)
+			}
+			if err := worker.QueueSlackFailingPolicyJob(ctx, ds, logger, policy, hosts); err != nil {
+				return err
+			}
+			if err := failingPoliciesSet.RemoveHosts(policy.ID, hosts); err != nil {
+				return ctxerr.Wrapf(ctx, err, "removing %d hosts from failing policies set %d", len(hosts), policy.ID)
+			}
+
+		case policies.FailingPolicyMicrosoftTeams:
+			hosts, err := failingPoliciesSet.ListHosts(policy.ID)
+			if err != nil {
+				return ctxerr.Wrapf(ctx, err, "listing hosts for failing policies set %d", policy.ID)
+			}
+			if err := worker.QueueMicrosoftTeamsFailingPolicyJob(ctx, ds, logger, policy, hosts); err != nil {
+				return err
+			}
+			if err := failingPoliciesSet.RemoveHosts(policy.ID, hosts); err != nil {
+				return ctxerr.Wrapf(ctx, err, "removing %d hosts from failing policies set %d", len(hosts), policy.ID)
+			}
 		}
 		return nil
 	})
 	if err != nil {
 		errHandler(ctx, logger, "triggering failing policies automation", err)
 	}
+
+	// resolve the Jira and Zendesk tickets of the hosts that pass again the
+	// policies they were failing.
+	if err := worker.QueueResolvedPolicyTicketJobs(ctx, ds, logger, passingPoliciesSet); err != nil {
+		errHandler(ctx, logger, "queueing resolved policy ticket jobs", err)
+	}
 }
 
 func cronWorker(
@@ -507,9 +669,10 @@ func cronWorker(
 
 	logger = kitlog.With(logger, "cron", lockKeyWorker)
 
-	// create the worker and register the Jira and Zendesk jobs even if no
-	// integration is enabled, as that config can change live (and if it's not
-	// there won't be any records to process so it will mostly just sleep).
+	// create the worker and register the Jira, Zendesk, webhook, Slack,
+	// Microsoft Teams and webhook delivery jobs even if no integration is
+	// enabled, as that config can change live (and if it's not there won't be
+	// any records to process so it will mostly just sleep).
 	w := worker.NewWorker(ds, logger)
 	jira := &worker.Jira{
 		Datastore:     ds,
@@ -521,10 +684,33 @@ func cronWorker(
 		Log:           logger,
 		NewClientFunc: newZendeskClient,
 	}
+	webhook := &worker.Webhook{
+		Datastore:     ds,
+		Log:           logger,
+		NewClientFunc: newWebhookClient,
+	}
+	slack := &worker.Slack{
+		Datastore:     ds,
+		Log:           logger,
+		NewClientFunc: newSlackClient,
+	}
+	msTeams := &worker.MicrosoftTeams{
+		Datastore:     ds,
+		Log:           logger,
+		NewClientFunc: newMicrosoftTeamsClient,
+	}
+	webhookDelivery := &worker.WebhookDelivery{
+		Datastore: ds,
+		Log:       logger,
+	}
 	// leave the url empty for now, will be filled when the lock is acquired with
 	// the up-to-date config.
 	w.Register(jira)
 	w.Register(zendesk)
+	w.Register(webhook)
+	w.Register(slack)
+	w.Register(msTeams)
+	w.Register(webhookDelivery)
 
 	// Read app config a first time before starting, to clear up any failer client
 	// configuration if we're not on a fleet-owned server. Technically, the ServerURL
@@ -570,6 +756,9 @@ func cronWorker(
 
 		jira.FleetURL = appConfig.ServerSettings.ServerURL
 		zendesk.FleetURL = appConfig.ServerSettings.ServerURL
+		webhook.FleetURL = appConfig.ServerSettings.ServerURL
+		slack.FleetURL = appConfig.ServerSettings.ServerURL
+		msTeams.FleetURL = appConfig.ServerSettings.ServerURL
 
 		workCtx, cancel := context.WithTimeout(ctx, lockDuration)
 		if err := w.ProcessJobs(workCtx); err != nil {
@@ -613,6 +802,18 @@ func newZendeskClient(opts *externalsvc.ZendeskOptions) (worker.ZendeskClient, e
 	return client, nil
 }
 
+func newWebhookClient(opts *externalsvc.WebhookOptions) (worker.WebhookClient, error) {
+	return externalsvc.NewWebhookClient(opts)
+}
+
+func newSlackClient(opts *externalsvc.SlackOptions) (worker.SlackClient, error) {
+	return externalsvc.NewSlackClient(opts)
+}
+
+func newMicrosoftTeamsClient(opts *externalsvc.MicrosoftTeamsOptions) (worker.MicrosoftTeamsClient, error) {
+	return externalsvc.NewMicrosoftTeamsClient(opts)
+}
+
 func newFailerClient(forcedFailures string) *worker.TestAutomationFailer {
 	var failerClient *worker.TestAutomationFailer
 	if forcedFailures != "" {
@@ -634,6 +835,7 @@ func newFailerClient(forcedFailures string) *worker.TestAutomationFailer {
 
 func startCleanupsAndAggregationSchedule(
 	ctx context.Context, instanceID string, ds fleet.Datastore, logger kitlog.Logger, enrollHostLimiter fleet.EnrollHostLimiter,
+	activityArchive fleet.ActivityArchiveStore, auditLogEnabled bool,
 ) {
 	schedule.New(
 		ctx, "cleanups_then_aggregation", instanceID, 1*time.Hour, ds,
@@ -687,6 +889,25 @@ func startCleanupsAndAggregationSchedule(
 				return ds.CleanupHostOperatingSystems(ctx)
 			},
 		),
+		schedule.WithJob(
+			"expired_activities",
+			func(ctx context.Context) error {
+				return cleanupExpiredActivities(ctx, ds, activityArchive, auditLogEnabled, time.Now(), activitiesCleanupBatchSize)
+			},
+		),
+		schedule.WithJob(
+			"expired_campaign_results",
+			func(ctx context.Context) error {
+				return cleanupExpiredCampaignResults(ctx, ds, time.Now())
+			},
+		),
+		schedule.WithJob(
+			"expired_webhook_deliveries",
+			func(ctx context.Context) error {
+				_, err := ds.CleanupWebhookDeliveries(ctx, time.Now().Add(-fleet.WebhookDeliveryRetention))
+				return err
+			},
+		),
 		// Run aggregation jobs after cleanups.
 		schedule.WithJob(
 			"query_aggregated_stats",
@@ -730,6 +951,229 @@ func startSendStatsSchedule(ctx context.Context, instanceID string, ds fleet.Dat
 	).Start()
 }
 
+// activitiesCleanupBatchSize is the maximum number of expired activities
+// archived and deleted at once.
+const activitiesCleanupBatchSize = 1000
+
+// cleanupExpiredActivities deletes the activities older than the expiry window
+// of the app config, oldest first. If archiving is enabled, the activities are
+// only deleted once they have been archived. If the audit log is enabled, the
+// activities are only deleted once they have been streamed to it.
+func cleanupExpiredActivities(
+	ctx context.Context, ds fleet.Datastore, activityArchive fleet.ActivityArchiveStore, auditLogEnabled bool, now time.Time, batchSize uint,
+) error {
+	appConfig, err := ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	settings := appConfig.ActivityExpirySettings
+	if !settings.ActivityExpiryEnabled || settings.ActivityExpiryWindow <= 0 {
+		return nil
+	}
+	if settings.ArchiveEnabled && activityArchive == nil {
+		return ctxerr.New(ctx, "activity archive is enabled but activity.archive_s3 is not configured")
+	}
+
+	before := now.AddDate(0, 0, -settings.ActivityExpiryWindow)
+	// the activities are read from the replica, so the cursor avoids archiving
+	// twice a batch that was deleted but not yet replicated.
+	var after string
+	for {
+		opts := fleet.ListActivitiesOptions{
+			ListOptions: fleet.ListOptions{
+				OrderKey:       "a.id",
+				OrderDirection: fleet.OrderAscending,
+				PerPage:        batchSize,
+				After:          after,
+			},
+			EndCreatedAt: &before,
+		}
+		if auditLogEnabled {
+			opts.Streamed = ptr.Bool(true)
+		}
+		activities, err := ds.ListActivities(ctx, opts)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "list expired activities")
+		}
+		if len(activities) == 0 {
+			return nil
+		}
+
+		if settings.ArchiveEnabled {
+			for _, monthActivities := range groupActivitiesByMonth(activities) {
+				if err := activityArchive.ArchiveActivities(ctx, monthActivities); err != nil {
+					return ctxerr.Wrap(ctx, err, "archive expired activities")
+				}
+			}
+		}
+
+		ids := make([]uint, 0, len(activities))
+		for _, activity := range activities {
+			ids = append(ids, activity.ID)
+		}
+		if err := ds.DeleteActivities(ctx, ids); err != nil {
+			return ctxerr.Wrap(ctx, err, "delete expired activities")
+		}
+
+		if uint(len(activities)) < batchSize {
+			return nil
+		}
+		after = strconv.FormatUint(uint64(ids[len(ids)-1]), 10)
+	}
+}
+
+// cleanupExpiredCampaignResults deletes the stored live query campaign results
+// older than the retention of the app config.
+func cleanupExpiredCampaignResults(ctx context.Context, ds fleet.Datastore, now time.Time) error {
+	appConfig, err := ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	retentionDays := appConfig.LiveQueryResultsSettings.RetentionDays
+	if retentionDays <= 0 {
+		return nil
+	}
+	if _, err := ds.CleanupDistributedQueryCampaignResults(ctx, now.AddDate(0, 0, -retentionDays)); err != nil {
+		return ctxerr.Wrap(ctx, err, "cleanup campaign results")
+	}
+	return nil
+}
+
+// groupActivitiesByMonth splits the activities, ordered by ID, in runs of
+// activities created in the same month.
+func groupActivitiesByMonth(activities []*fleet.Activity) [][]*fleet.Activity {
+	var groups [][]*fleet.Activity
+	var month string
+	for _, activity := range activities {
+		m := activity.CreatedAt.UTC().Format(fleet.ActivityArchiveMonthFormat)
+		if len(groups) == 0 || m != month {
+			groups = append(groups, nil)
+			month = m
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], activity)
+	}
+	return groups
+}
+
+const (
+	// liveQueryExportsInterval is the interval at which the exported results
+	// of the completed campaigns are combined.
+	liveQueryExportsInterval = time.Minute
+	// liveQueryExportsLateResultsWindow is how long after they were first
+	// combined the exported results of a campaign are combined again if late
+	// results were written.
+	liveQueryExportsLateResultsWindow = time.Hour
+)
+
+func startLiveQueryExportsSchedule(
+	ctx context.Context, instanceID string, ds fleet.Datastore, liveQueryExport fleet.LiveQueryExportStore, logger kitlog.Logger,
+) {
+	schedule.New(
+		ctx, "live_query_exports", instanceID, liveQueryExportsInterval, ds,
+		schedule.WithLogger(kitlog.With(logger, "cron", "live_query_exports")),
+		schedule.WithJob(
+			"combine_live_query_exports",
+			func(ctx context.Context) error {
+				return combineLiveQueryExports(ctx, ds, liveQueryExport, time.Now())
+			},
+		),
+	).Start()
+}
+
+// combineLiveQueryExports combines the exported results of the completed
+// campaigns, so that they can be downloaded, and combines them again for a
+// while to include the results of the hosts that respond late. A failed
+// campaign does not prevent the others from being combined, it is retried on
+// the next run.
+func combineLiveQueryExports(ctx context.Context, ds fleet.Datastore, liveQueryExport fleet.LiveQueryExportStore, now time.Time) error {
+	campaigns, err := ds.ListDistributedQueryCampaignsToExport(ctx, now, now.Add(-liveQueryExportsLateResultsWindow))
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list campaigns to export")
+	}
+
+	var lastErr error
+	for _, campaign := range campaigns {
+		if err := liveQueryExport.CombineCampaignExport(ctx, campaign.ID, campaign.ExportFormat); err != nil {
+			lastErr = ctxerr.Wrapf(ctx, err, "combine export of campaign %d", campaign.ID)
+			continue
+		}
+		if campaign.ExportedAt == nil {
+			if err := ds.MarkDistributedQueryCampaignExported(ctx, campaign.ID, now); err != nil {
+				lastErr = ctxerr.Wrapf(ctx, err, "mark campaign %d exported", campaign.ID)
+			}
+		}
+	}
+	return lastErr
+}
+
+// activitiesStreamingBatchSize is the maximum number of activities streamed to
+// the audit logger in a single write.
+const activitiesStreamingBatchSize = 500
+
+func startActivitiesStreamingSchedule(
+	ctx context.Context, instanceID string, ds fleet.Datastore, config config.FleetConfig, auditLogger fleet.JSONLogger, logger kitlog.Logger,
+) {
+	schedule.New(
+		ctx, "activities_streaming", instanceID, config.Activity.AuditLogInterval, ds,
+		schedule.WithLogger(kitlog.With(logger, "cron", "activities_streaming")),
+		schedule.WithJob(
+			"stream_activities",
+			func(ctx context.Context) error {
+				return streamActivities(ctx, ds, auditLogger, activitiesStreamingBatchSize)
+			},
+		),
+	).Start()
+}
+
+// streamActivities writes the activities that have not been streamed yet to
+// the audit logger, oldest first, and marks them as streamed. An activity is
+// only marked once it has been successfully written, so a failed write is
+// retried on the next run.
+func streamActivities(ctx context.Context, ds fleet.Datastore, auditLogger fleet.JSONLogger, batchSize uint) error {
+	// the activities are read from the replica, so the cursor avoids streaming
+	// twice a batch that was marked but not yet replicated.
+	var after string
+	for {
+		activities, err := ds.ListActivities(ctx, fleet.ListActivitiesOptions{
+			ListOptions: fleet.ListOptions{
+				OrderKey:       "a.id",
+				OrderDirection: fleet.OrderAscending,
+				PerPage:        batchSize,
+				After:          after,
+			},
+			Streamed: ptr.Bool(false),
+		})
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "list activities to stream")
+		}
+		if len(activities) == 0 {
+			return nil
+		}
+
+		logs := make([]json.RawMessage, 0, len(activities))
+		ids := make([]uint, 0, len(activities))
+		for _, activity := range activities {
+			b, err := json.Marshal(activity)
+			if err != nil {
+				return ctxerr.Wrap(ctx, err, "marshal activity")
+			}
+			logs = append(logs, b)
+			ids = append(ids, activity.ID)
+		}
+		if err := auditLogger.Write(ctx, logs); err != nil {
+			return ctxerr.Wrap(ctx, err, "write activities to audit log")
+		}
+		if err := ds.MarkActivitiesAsStreamed(ctx, ids); err != nil {
+			return ctxerr.Wrap(ctx, err, "mark activities as streamed")
+		}
+
+		if uint(len(activities)) < batchSize {
+			return nil
+		}
+		after = strconv.FormatUint(uint64(ids[len(ids)-1]), 10)
+	}
+}
+
 func trySendStatistics(ctx context.Context, ds fleet.Datastore, frequency time.Duration, url string, config config.FleetConfig, license *fleet.LicenseInfo) error {
 	ac, err := ds.AppConfig(ctx)
 	if err != nil {
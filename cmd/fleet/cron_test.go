@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"testing"
 	"time"
 
@@ -64,3 +67,229 @@ func TestFilterRecentVulns(t *testing.T) {
 		require.ElementsMatch(t, expected, actual)
 	})
 }
+
+type recordingJSONLogger struct {
+	logs []json.RawMessage
+	err  error
+}
+
+func (l *recordingJSONLogger) Write(ctx context.Context, logs []json.RawMessage) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.logs = append(l.logs, logs...)
+	return nil
+}
+
+func TestStreamActivities(t *testing.T) {
+	ctx := context.Background()
+	ds := new(mock.Store)
+
+	var pending []*fleet.Activity
+	for i := 1; i <= 5; i++ {
+		pending = append(pending, &fleet.Activity{ID: uint(i), Type: fleet.ActivityTypeCreatedUser})
+	}
+	ds.ListActivitiesFunc = func(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
+		require.NotNil(t, opt.Streamed)
+		require.False(t, *opt.Streamed)
+		require.Equal(t, "a.id", opt.OrderKey)
+
+		var res []*fleet.Activity
+		for _, a := range pending {
+			if opt.After != "" {
+				after, err := strconv.Atoi(opt.After)
+				require.NoError(t, err)
+				if a.ID <= uint(after) {
+					continue
+				}
+			}
+			if uint(len(res)) == opt.PerPage {
+				break
+			}
+			res = append(res, a)
+		}
+		return res, nil
+	}
+	var marked []uint
+	ds.MarkActivitiesAsStreamedFunc = func(ctx context.Context, activityIDs []uint) error {
+		marked = append(marked, activityIDs...)
+		return nil
+	}
+
+	// nothing is marked if the write fails
+	auditLogger := &recordingJSONLogger{err: errors.New("write failed")}
+	require.Error(t, streamActivities(ctx, ds, auditLogger, 2))
+	require.Empty(t, marked)
+
+	auditLogger.err = nil
+	require.NoError(t, streamActivities(ctx, ds, auditLogger, 2))
+	require.Equal(t, []uint{1, 2, 3, 4, 5}, marked)
+	require.Len(t, auditLogger.logs, 5)
+
+	var first fleet.Activity
+	require.NoError(t, json.Unmarshal(auditLogger.logs[0], &first))
+	require.Equal(t, uint(1), first.ID)
+	require.Equal(t, fleet.ActivityTypeCreatedUser, first.Type)
+}
+
+func TestCleanupExpiredActivities(t *testing.T) {
+	ctx := context.Background()
+	ds := new(mock.Store)
+	archive := new(mock.ActivityArchiveStore)
+	now := time.Date(2022, 9, 15, 0, 0, 0, 0, time.UTC)
+
+	settings := fleet.ActivityExpirySettings{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{ActivityExpirySettings: settings}, nil
+	}
+
+	// two activities in July, one in August, one recent activity
+	var stored []*fleet.Activity
+	for i, createdAt := range []time.Time{
+		time.Date(2022, 7, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 7, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 9, 14, 0, 0, 0, 0, time.UTC),
+	} {
+		a := &fleet.Activity{ID: uint(i + 1)}
+		a.CreatedAt = createdAt
+		stored = append(stored, a)
+	}
+	ds.ListActivitiesFunc = func(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
+		require.NotNil(t, opt.EndCreatedAt)
+		var res []*fleet.Activity
+		for _, a := range stored {
+			if opt.After != "" {
+				after, err := strconv.Atoi(opt.After)
+				require.NoError(t, err)
+				if a.ID <= uint(after) {
+					continue
+				}
+			}
+			if !a.CreatedAt.Before(*opt.EndCreatedAt) {
+				continue
+			}
+			if opt.Streamed != nil && a.Streamed != *opt.Streamed {
+				continue
+			}
+			if uint(len(res)) == opt.PerPage {
+				break
+			}
+			res = append(res, a)
+		}
+		return res, nil
+	}
+	var deleted []uint
+	ds.DeleteActivitiesFunc = func(ctx context.Context, activityIDs []uint) error {
+		deleted = append(deleted, activityIDs...)
+		return nil
+	}
+	var archived [][]uint
+	archive.ArchiveActivitiesFunc = func(ctx context.Context, activities []*fleet.Activity) error {
+		var ids []uint
+		for _, a := range activities {
+			ids = append(ids, a.ID)
+		}
+		archived = append(archived, ids)
+		return nil
+	}
+
+	// expiry disabled
+	require.NoError(t, cleanupExpiredActivities(ctx, ds, archive, false, now, 2))
+	require.False(t, ds.ListActivitiesFuncInvoked)
+
+	// archive enabled without store
+	settings = fleet.ActivityExpirySettings{ActivityExpiryEnabled: true, ActivityExpiryWindow: 30, ArchiveEnabled: true}
+	require.Error(t, cleanupExpiredActivities(ctx, ds, nil, false, now, 2))
+	require.Empty(t, deleted)
+
+	// the activities are archived by month, then deleted
+	require.NoError(t, cleanupExpiredActivities(ctx, ds, archive, false, now, 2))
+	require.Equal(t, [][]uint{{1, 2}, {3}}, archived)
+	require.Equal(t, []uint{1, 2, 3}, deleted)
+
+	// nothing is deleted if the archive fails
+	deleted, archived = nil, nil
+	archive.ArchiveActivitiesFunc = func(ctx context.Context, activities []*fleet.Activity) error {
+		return errors.New("archive failed")
+	}
+	require.Error(t, cleanupExpiredActivities(ctx, ds, archive, false, now, 2))
+	require.Empty(t, deleted)
+
+	// without archive, the activities are deleted
+	settings.ArchiveEnabled = false
+	require.NoError(t, cleanupExpiredActivities(ctx, ds, nil, false, now, 2))
+	require.Equal(t, []uint{1, 2, 3}, deleted)
+
+	// with the audit log, only the streamed activities are deleted
+	deleted = nil
+	stored[0].Streamed = true
+	stored[2].Streamed = true
+	require.NoError(t, cleanupExpiredActivities(ctx, ds, nil, true, now, 2))
+	require.Equal(t, []uint{1, 3}, deleted)
+}
+
+func TestCleanupExpiredCampaignResults(t *testing.T) {
+	ctx := context.Background()
+	ds := new(mock.Store)
+	now := time.Date(2022, 9, 15, 0, 0, 0, 0, time.UTC)
+
+	settings := fleet.LiveQueryResultsSettings{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{LiveQueryResultsSettings: settings}, nil
+	}
+	var before time.Time
+	ds.CleanupDistributedQueryCampaignResultsFunc = func(ctx context.Context, olderThan time.Time) (uint, error) {
+		before = olderThan
+		return 0, nil
+	}
+
+	// the results are kept forever
+	require.NoError(t, cleanupExpiredCampaignResults(ctx, ds, now))
+	require.False(t, ds.CleanupDistributedQueryCampaignResultsFuncInvoked)
+
+	settings.RetentionDays = 30
+	require.NoError(t, cleanupExpiredCampaignResults(ctx, ds, now))
+	require.True(t, ds.CleanupDistributedQueryCampaignResultsFuncInvoked)
+	require.Equal(t, time.Date(2022, 8, 16, 0, 0, 0, 0, time.UTC), before)
+}
+
+func TestCombineLiveQueryExports(t *testing.T) {
+	ctx := context.Background()
+	ds := new(mock.Store)
+	store := new(mock.LiveQueryExportStore)
+	now := time.Date(2022, 9, 18, 12, 0, 0, 0, time.UTC)
+	exportedAt := now.Add(-10 * time.Minute)
+
+	ds.ListDistributedQueryCampaignsToExportFunc = func(ctx context.Context, at, exportedSince time.Time) ([]*fleet.DistributedQueryCampaign, error) {
+		require.Equal(t, now, at)
+		require.Equal(t, now.Add(-liveQueryExportsLateResultsWindow), exportedSince)
+		return []*fleet.DistributedQueryCampaign{
+			{ID: 1, ExportFormat: fleet.LiveQueryExportCSV},
+			{ID: 2, ExportFormat: fleet.LiveQueryExportNDJSON},
+			{ID: 3, ExportFormat: fleet.LiveQueryExportCSV, ExportedAt: &exportedAt},
+		}, nil
+	}
+	var combined []uint
+	store.CombineCampaignExportFunc = func(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat) error {
+		if campaignID == 1 {
+			return errors.New("boom")
+		}
+		combined = append(combined, campaignID)
+		return nil
+	}
+	var marked []uint
+	ds.MarkDistributedQueryCampaignExportedFunc = func(ctx context.Context, id uint, at time.Time) error {
+		require.Equal(t, now, at)
+		marked = append(marked, id)
+		return nil
+	}
+
+	// the failed campaign does not prevent the others from being combined,
+	// and only the campaigns combined for the first time are marked
+	err := combineLiveQueryExports(ctx, ds, store, now)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "boom")
+	require.Equal(t, []uint{2, 3}, combined)
+	require.Equal(t, []uint{2}, marked)
+}
@@ -17,6 +17,7 @@ import (
 	"os/signal"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -179,6 +180,8 @@ the way that the Fleet server works.
 			var ds fleet.Datastore
 			var carveStore fleet.CarveStore
 			var installerStore fleet.InstallerStore
+			var activityArchive fleet.ActivityArchiveStore
+			var liveQueryExport fleet.LiveQueryExportStore
 			mailService := mail.NewService()
 
 			opts := []mysql.DBOption{mysql.Logger(logger), mysql.WithFleetConfig(&config)}
@@ -217,6 +220,20 @@ the way that the Fleet server works.
 				}
 			}
 
+			if config.Activity.ArchiveS3.Bucket != "" {
+				activityArchive, err = s3.NewActivityArchiveStore(config.Activity.ArchiveS3)
+				if err != nil {
+					initFatal(err, "initializing S3 activity archive store")
+				}
+			}
+
+			if config.LiveQuery.ExportS3.Bucket != "" {
+				liveQueryExport, err = s3.NewLiveQueryExportStore(config.LiveQuery.ExportS3)
+				if err != nil {
+					initFatal(err, "initializing S3 live query export store")
+				}
+			}
+
 			migrationStatus, err := ds.MigrationStatus(cmd.Context())
 			if err != nil {
 				initFatal(err, "retrieving migration status")
@@ -351,6 +368,11 @@ the way that the Fleet server works.
 			}
 
 			failingPolicySet := redis_policy_set.NewFailing(redisPool)
+			passingPolicySet := redis_policy_set.NewPassing(redisPool)
+			loginAttempts := &redis.LoginAttemptsStore{
+				Pool:      redisPool,
+				KeyPrefix: "login_attempts::",
+			}
 
 			task := async.NewTask(ds, redisPool, clock.C, config.Osquery)
 
@@ -384,7 +406,9 @@ the way that the Fleet server works.
 			defer cancelFunc()
 			eh := errorstore.NewHandler(ctx, redisPool, logger, config.Logging.ErrorRetentionPeriod)
 			ctx = ctxerr.NewContext(ctx, eh)
-			svc, err := service.NewService(ctx, ds, task, resultStore, logger, osqueryLogger, config, mailService, clock.C, ssoSessionStore, liveQueryStore, carveStore, installerStore, *license, failingPolicySet, geoIP, redisWrapperDS)
+			var backgroundTasks sync.WaitGroup
+			ctx = service.WithBackgroundTasks(ctx, &backgroundTasks)
+			svc, err := service.NewService(ctx, ds, task, resultStore, logger, osqueryLogger, config, mailService, clock.C, ssoSessionStore, liveQueryStore, carveStore, installerStore, activityArchive, liveQueryExport, *license, failingPolicySet, passingPolicySet, geoIP, redisWrapperDS, loginAttempts)
 			if err != nil {
 				initFatal(err, "initializing service")
 			}
@@ -400,8 +424,8 @@ the way that the Fleet server works.
 			if err != nil {
 				initFatal(errors.New("Error generating random instance identifier"), "")
 			}
-			runCrons(ctx, ds, task, kitlog.With(logger, "component", "crons"), config, license, failingPolicySet, instanceID)
-			if err := startSchedules(ctx, ds, logger, config, license, redisWrapperDS, instanceID); err != nil {
+			runCrons(ctx, ds, task, kitlog.With(logger, "component", "crons"), config, license, failingPolicySet, passingPolicySet, instanceID)
+			if err := startSchedules(ctx, ds, logger, config, license, redisWrapperDS, activityArchive, liveQueryExport, instanceID); err != nil {
 				initFatal(err, "failed to register schedules")
 			}
 
@@ -608,9 +632,14 @@ the way that the Fleet server works.
 				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 				defer cancel()
 				errs <- func() error {
+					// the service is stopped once the pending requests are
+					// done, and its background tasks are waited for, e.g. so
+					// that the queued live query results are exported.
+					err := srv.Shutdown(ctx)
 					cancelFunc()
 					launcher.GracefulStop()
-					return srv.Shutdown(ctx)
+					backgroundTasks.Wait()
+					return err
 				}()
 			}()
 
@@ -668,6 +697,7 @@ func runCrons(
 	config configpkg.FleetConfig,
 	license *fleet.LicenseInfo,
 	failingPoliciesSet fleet.FailingPolicySet,
+	passingPoliciesSet fleet.FailingPolicySet,
 	ourIdentifier string,
 ) {
 	// StartCollectors starts a goroutine per collector, using ctx to cancel.
@@ -675,7 +705,7 @@ func runCrons(
 
 	go cronVulnerabilities(
 		ctx, ds, kitlog.With(logger, "cron", "vulnerabilities"), ourIdentifier, &config.Vulnerabilities)
-	go cronWebhooks(ctx, ds, kitlog.With(logger, "cron", "webhooks"), ourIdentifier, failingPoliciesSet, 1*time.Hour)
+	go cronWebhooks(ctx, ds, kitlog.With(logger, "cron", "webhooks"), ourIdentifier, failingPoliciesSet, passingPoliciesSet, 1*time.Hour)
 	go cronWorker(ctx, ds, kitlog.With(logger, "cron", "worker"), ourIdentifier)
 }
 
@@ -686,11 +716,25 @@ func startSchedules(
 	config config.FleetConfig,
 	license *fleet.LicenseInfo,
 	enrollHostLimiter fleet.EnrollHostLimiter,
+	activityArchive fleet.ActivityArchiveStore,
+	liveQueryExport fleet.LiveQueryExportStore,
 	instanceID string,
 ) error {
-	startCleanupsAndAggregationSchedule(ctx, instanceID, ds, logger, enrollHostLimiter)
+	startCleanupsAndAggregationSchedule(ctx, instanceID, ds, logger, enrollHostLimiter, activityArchive, config.Activity.EnableAuditLog)
 	startSendStatsSchedule(ctx, instanceID, ds, config, license, logger)
 
+	if liveQueryExport != nil {
+		startLiveQueryExportsSchedule(ctx, instanceID, ds, liveQueryExport, logger)
+	}
+
+	if config.Activity.EnableAuditLog {
+		auditLogger, err := logging.NewAuditLogger(config, logger)
+		if err != nil {
+			return fmt.Errorf("initializing audit logging: %w", err)
+		}
+		startActivitiesStreamingSchedule(ctx, instanceID, ds, config, auditLogger, logger)
+	}
+
 	return nil
 }
 
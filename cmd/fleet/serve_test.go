@@ -158,9 +158,19 @@ func TestCronWebhooks(t *testing.T) {
 		return nil
 	}
 
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
+	ds.NewWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+		return delivery, nil
+	}
+	ds.UpdateWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+		return nil
+	}
+
 	calledOnce := make(chan struct{})
 	calledTwice := make(chan struct{})
-	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, daysCount int) (int, int, error) {
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
 		defer func() {
 			select {
 			case <-calledOnce:
@@ -180,7 +190,7 @@ func TestCronWebhooks(t *testing.T) {
 	defer cancelFunc()
 
 	failingPoliciesSet := service.NewMemFailingPolicySet()
-	go cronWebhooks(ctx, ds, kitlog.With(kitlog.NewNopLogger(), "cron", "webhooks"), "1234", failingPoliciesSet, 5*time.Minute)
+	go cronWebhooks(ctx, ds, kitlog.With(kitlog.NewNopLogger(), "cron", "webhooks"), "1234", failingPoliciesSet, service.NewMemFailingPolicySet(), 5*time.Minute)
 
 	<-calledOnce
 	time.Sleep(1 * time.Second)
@@ -220,6 +230,10 @@ func TestCronVulnerabilitiesCreatesDatabasesPath(t *testing.T) {
 		return nil
 	}
 
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
+
 	vulnPath := filepath.Join(t.TempDir(), "something")
 	require.NoDirExists(t, vulnPath)
 
@@ -323,6 +337,9 @@ func TestCronWebhooksLockDuration(t *testing.T) {
 			},
 		}, nil
 	}
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
 	hostStatus := make(chan struct{})
 	hostStatusClosed := false
 	failingPolicies := make(chan struct{})
@@ -352,7 +369,7 @@ func TestCronWebhooksLockDuration(t *testing.T) {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
-	go cronWebhooks(ctx, ds, kitlog.NewNopLogger(), "1234", service.NewMemFailingPolicySet(), 1*time.Hour)
+	go cronWebhooks(ctx, ds, kitlog.NewNopLogger(), "1234", service.NewMemFailingPolicySet(), service.NewMemFailingPolicySet(), 1*time.Hour)
 
 	select {
 	case <-failingPolicies:
@@ -395,6 +412,10 @@ func TestCronWebhooksIntervalChange(t *testing.T) {
 		}, nil
 	}
 
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
+
 	lockCalled := make(chan struct{}, 1)
 	ds.LockFunc = func(ctx context.Context, name string, owner string, expiration time.Duration) (bool, error) {
 		select {
@@ -408,7 +429,7 @@ func TestCronWebhooksIntervalChange(t *testing.T) {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	defer cancelFunc()
 
-	go cronWebhooks(ctx, ds, kitlog.NewNopLogger(), "1234", service.NewMemFailingPolicySet(), 200*time.Millisecond)
+	go cronWebhooks(ctx, ds, kitlog.NewNopLogger(), "1234", service.NewMemFailingPolicySet(), service.NewMemFailingPolicySet(), 200*time.Millisecond)
 
 	select {
 	case <-configLoaded:
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	scopeFlagName     = "scope"
+	expiresInFlagName = "expires-in"
+	tokenIDFlagName   = "id"
+)
+
+func apiTokenCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "api-token",
+		Usage: "Manage your personal API tokens",
+		Subcommands: []*cli.Command{
+			createAPITokenCommand(),
+			listAPITokensCommand(),
+			revokeAPITokenCommand(),
+		},
+	}
+}
+
+func createAPITokenCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "create",
+		Usage: "Create a new personal API token",
+		UsageText: `This command will create a new API token for the current user. Requests authenticated with the token are only allowed if both the scopes of the token and the role of the user allow them.
+
+   The key of the token is only displayed once, when the token is created.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     nameFlagName,
+				Usage:    "Name of the token (required)",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:     scopeFlagName,
+				Usage:    "Scope of the token in object:action[,action] format, e.g. host:read,list (required, multiple may be specified)",
+				Required: true,
+			},
+			&cli.UintFlag{
+				Name:  teamFlagName,
+				Usage: "Restrict the token to the team with this ID",
+			},
+			&cli.DurationFlag{
+				Name:  expiresInFlagName,
+				Usage: "Duration after which the token expires, e.g. 720h (the token doesn't expire by default)",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			var scopes fleet.APITokenScopes
+			for _, s := range c.StringSlice(scopeFlagName) {
+				scope, err := fleet.ParseAPITokenScope(s)
+				if err != nil {
+					return err
+				}
+				scopes = append(scopes, scope)
+			}
+
+			payload := fleet.APITokenPayload{
+				Name:   c.String(nameFlagName),
+				Scopes: scopes,
+			}
+			if c.IsSet(teamFlagName) {
+				payload.TeamID = ptr.Uint(c.Uint(teamFlagName))
+			}
+			if expiresIn := c.Duration(expiresInFlagName); expiresIn != 0 {
+				if expiresIn < 0 {
+					return errors.New("--expires-in must be positive")
+				}
+				payload.ExpiresAt = ptr.Time(time.Now().Add(expiresIn))
+			}
+
+			token, err := client.CreateAPIToken(payload)
+			if err != nil {
+				return fmt.Errorf("Failed to create API token: %w", err)
+			}
+
+			fmt.Fprintf(c.App.Writer, "[+] Created API token %q (ID %d). Store its key now, it won't be displayed again:\n%s\n", token.Name, token.ID, token.Key)
+			return nil
+		},
+	}
+}
+
+func listAPITokensCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List your personal API tokens",
+		Flags: []cli.Flag{
+			jsonFlag(),
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			tokens, err := client.ListAPITokens()
+			if err != nil {
+				return fmt.Errorf("could not list API tokens: %w", err)
+			}
+
+			if c.Bool(jsonFlagName) {
+				return printJSON(tokens, c.App.Writer)
+			}
+
+			if len(tokens) == 0 {
+				log(c, "No API tokens found")
+				return nil
+			}
+
+			data := [][]string{}
+			for _, t := range tokens {
+				team := ""
+				if t.TeamID != nil {
+					team = fmt.Sprint(*t.TeamID)
+				}
+				scopes := make([]string, 0, len(t.Scopes))
+				for _, s := range t.Scopes {
+					scopes = append(scopes, s.Object+":"+strings.Join(s.Actions, ","))
+				}
+				data = append(data, []string{
+					fmt.Sprint(t.ID),
+					t.Name,
+					team,
+					strings.Join(scopes, " "),
+					formatOptionalTime(t.ExpiresAt),
+					formatOptionalTime(t.LastUsedAt),
+				})
+			}
+			columns := []string{"ID", "Name", "Team", "Scopes", "Expires at", "Last used at"}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
+func revokeAPITokenCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "revoke",
+		Usage:     "Revoke a personal API token",
+		UsageText: `This command will revoke the API token specified by its ID, as displayed by "fleetctl api-token list".`,
+		Flags: []cli.Flag{
+			&cli.UintFlag{
+				Name:     tokenIDFlagName,
+				Usage:    "ID of the token (required)",
+				Required: true,
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			if err := client.DeleteAPIToken(c.Uint(tokenIDFlagName)); err != nil {
+				return fmt.Errorf("Failed to revoke API token: %w", err)
+			}
+			fmt.Fprintf(c.App.Writer, "[+] Revoked API token %d\n", c.Uint(tokenIDFlagName))
+			return nil
+		},
+	}
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
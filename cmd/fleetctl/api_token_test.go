@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenCommands(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	var created *fleet.APIToken
+	ds.NewAPITokenFunc = func(ctx context.Context, token *fleet.APIToken) (*fleet.APIToken, error) {
+		token.ID = 1
+		created = token
+		return token, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		return &fleet.Team{ID: tid, Name: "team"}, nil
+	}
+
+	out := runAppForTest(t, []string{
+		"api-token", "create", "--name", "automation",
+		"--scope", "host:read,list", "--scope", "targeted_query:run",
+		"--team", "2", "--expires-in", "24h",
+	})
+	require.NotNil(t, created)
+	assert.Equal(t, "automation", created.Name)
+	assert.Equal(t, fleet.APITokenScopes{
+		{Object: "host", Actions: []string{"read", "list"}},
+		{Object: "targeted_query", Actions: []string{"run"}},
+	}, created.Scopes)
+	assert.Equal(t, ptr.Uint(2), created.TeamID)
+	require.NotNil(t, created.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), *created.ExpiresAt, time.Minute)
+
+	// the key is displayed, and only its hash is stored
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	key := lines[len(lines)-1]
+	require.True(t, strings.HasPrefix(key, fleet.APITokenPrefix))
+	assert.Equal(t, fleet.HashAPITokenKey(key), created.KeyHash)
+
+	runAppCheckErr(t, []string{"api-token", "create", "--name", "bad", "--scope", "host"}, `unable to parse "host" as object:actions`)
+
+	ds.ListAPITokensFunc = func(ctx context.Context, userID uint) ([]*fleet.APIToken, error) {
+		return []*fleet.APIToken{{
+			ID:     1,
+			UserID: userID,
+			Name:   "automation",
+			TeamID: ptr.Uint(2),
+			Scopes: fleet.APITokenScopes{{Object: "host", Actions: []string{"read", "list"}}},
+		}}, nil
+	}
+	out = runAppForTest(t, []string{"api-token", "list"})
+	assert.Contains(t, out, "automation")
+	assert.Contains(t, out, "host:read,list")
+	assert.Contains(t, out, "never")
+
+	var deleted uint
+	ds.DeleteAPITokenFunc = func(ctx context.Context, userID uint, id uint) error {
+		deleted = id
+		return nil
+	}
+	out = runAppForTest(t, []string{"api-token", "revoke", "--id", "1"})
+	assert.Equal(t, uint(1), deleted)
+	assert.Equal(t, "[+] Revoked API token 1\n", out)
+}
@@ -206,6 +206,40 @@ spec:
 	assert.JSONEq(t, string(newAgentOpts), string(*teamsByName["team1"].Config.AgentOptions))
 	assert.Equal(t, []*fleet.EnrollSecret{{Secret: "BBB"}}, enrolledSecretsCalled[uint(42)])
 	assert.True(t, ds.ApplyEnrollSecretsFuncInvoked)
+
+	filename = writeTmpYml(t, `
+apiVersion: v1
+kind: team
+spec:
+  team:
+    name: team1
+    webhook_settings:
+      host_status_webhook:
+        enable_host_status_webhook: true
+        destination_url: https://example.com/host_status
+        host_percentage: 25
+        days_count: 2
+      vulnerabilities_webhook:
+        enable_vulnerabilities_webhook: true
+        destination_url: https://example.com/vulnerabilities
+        host_batch_size: 10
+`)
+
+	require.Equal(t, "[+] applied 1 teams\n", runAppForTest(t, []string{"apply", "-f", filename}))
+	webhookSettings := teamsByName["team1"].Config.WebhookSettings
+	require.NotNil(t, webhookSettings.HostStatusWebhook)
+	assert.Equal(t, fleet.HostStatusWebhookSettings{
+		Enable:         true,
+		DestinationURL: "https://example.com/host_status",
+		HostPercentage: 25,
+		DaysCount:      2,
+	}, *webhookSettings.HostStatusWebhook)
+	require.NotNil(t, webhookSettings.VulnerabilitiesWebhook)
+	assert.Equal(t, fleet.VulnerabilitiesWebhookSettings{
+		Enable:         true,
+		DestinationURL: "https://example.com/vulnerabilities",
+		HostBatchSize:  10,
+	}, *webhookSettings.VulnerabilitiesWebhook)
 }
 
 func writeTmpYml(t *testing.T, contents string) string {
@@ -218,6 +252,9 @@ func writeTmpYml(t *testing.T, contents string) string {
 
 func TestApplyAppConfig(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	ds.ListUsersFunc = func(ctx context.Context, opt fleet.UserListOptions) ([]*fleet.User, error) {
 		return userRoleSpecList, nil
@@ -271,6 +308,9 @@ spec:
 
 func TestApplyAppConfigUnknownFields(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	ds.ListUsersFunc = func(ctx context.Context, opt fleet.UserListOptions) ([]*fleet.User, error) {
 		return userRoleSpecList, nil
@@ -309,6 +349,9 @@ spec:
 
 func TestApplyAppConfigDeprecatedFields(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	ds.ListUsersFunc = func(ctx context.Context, opt fleet.UserListOptions) ([]*fleet.User, error) {
 		return userRoleSpecList, nil
@@ -419,6 +462,9 @@ spec:
 
 func TestApplyEnrollSecrets(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	var appliedSecrets []*fleet.EnrollSecret
 	ds.ApplyEnrollSecretsFunc = func(ctx context.Context, teamID *uint, secrets []*fleet.EnrollSecret) error {
@@ -446,6 +492,9 @@ spec:
 
 func TestApplyLabels(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	var appliedLabels []*fleet.LabelSpec
 	ds.ApplyLabelSpecsFunc = func(ctx context.Context, specs []*fleet.LabelSpec) error {
@@ -470,6 +519,55 @@ spec:
 	assert.Equal(t, "select 1;", appliedLabels[0].Query)
 }
 
+func TestApplyCustomRoles(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	var appliedRoles []*fleet.CustomRoleSpec
+	ds.ApplyCustomRoleSpecsFunc = func(ctx context.Context, specs []*fleet.CustomRoleSpec) error {
+		appliedRoles = specs
+		return nil
+	}
+	ds.ListCustomRolesFunc = func(ctx context.Context) ([]*fleet.CustomRole, error) {
+		return nil, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	name := writeTmpYml(t, `---
+apiVersion: v1
+kind: custom_role
+spec:
+  name: helpdesk
+  description: Read hosts and policies
+  permissions:
+    - object: host
+      actions: [read, list]
+    - object: policy
+      actions: [read]
+`)
+
+	assert.Equal(t, "[+] applied 1 custom roles\n", runAppForTest(t, []string{"apply", "-f", name}))
+	assert.True(t, ds.ApplyCustomRoleSpecsFuncInvoked)
+	require.Len(t, appliedRoles, 1)
+	assert.Equal(t, "helpdesk", appliedRoles[0].Name)
+	assert.Equal(t, fleet.CustomRolePermissions{
+		{Object: "host", Actions: []string{"read", "list"}},
+		{Object: "policy", Actions: []string{"read"}},
+	}, appliedRoles[0].Permissions)
+
+	name = writeTmpYml(t, `---
+apiVersion: v1
+kind: custom_role
+spec:
+  name: helpdesk
+  permissions:
+    - object: host
+      actions: [write_role]
+`)
+	runAppCheckErr(t, []string{"apply", "-f", name}, `applying custom roles: POST /api/latest/fleet/spec/custom_roles received status 422 Validation Failed: custom role "helpdesk": invalid action "write_role" for object "host"`)
+}
+
 func TestApplyPacks(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
 
@@ -561,6 +659,9 @@ spec:
 
 func TestCanApplyIntervalsInNanoseconds(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	// Stubs
 	ds.ListUsersFunc = func(ctx context.Context, opt fleet.UserListOptions) ([]*fleet.User, error) {
@@ -596,6 +697,9 @@ spec:
 
 func TestCanApplyIntervalsUsingDurations(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	// Stubs
 	ds.ListUsersFunc = func(ctx context.Context, opt fleet.UserListOptions) ([]*fleet.User, error) {
@@ -88,6 +88,19 @@ func deleteCommand() *cli.Command {
 				}
 			}
 
+			for _, role := range specs.CustomRoles {
+				fmt.Printf("[+] deleting custom role %q\n", role.Name)
+				if err := fleet.DeleteCustomRole(role.Name); err != nil {
+					root := ctxerr.Cause(err)
+					switch root.(type) {
+					case service.NotFoundErr:
+						fmt.Printf("[!] custom role %q doesn't exist\n", role.Name)
+						continue
+					}
+					return err
+				}
+			}
+
 			return nil
 		},
 	}
@@ -10,6 +10,9 @@ import (
 
 func TestDeleteLabel(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	var deletedLabel string
 	ds.DeleteLabelFunc = func(ctx context.Context, name string) error {
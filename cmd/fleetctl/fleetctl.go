@@ -80,6 +80,7 @@ func createApp(reader io.Reader, writer io.Writer, exitErrHandler cli.ExitErrHan
 		convertCommand(),
 		goqueryCommand(),
 		userCommand(),
+		apiTokenCommand(),
 		debugCommand(),
 		previewCommand(),
 		eefleetctl.UpdatesCommand(),
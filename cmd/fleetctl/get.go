@@ -1,13 +1,17 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/pkg/secure"
 	"gopkg.in/guregu/null.v3"
@@ -25,6 +29,15 @@ const (
 	withQueriesFlagName         = "with-queries"
 	expiredFlagName             = "expired"
 	includeServerConfigFlagName = "include-server-config"
+	monthFlagName               = "month"
+	archivedMonthsFlagName      = "archived-months"
+	activityTypeFlagName        = "type"
+	pageFlagName                = "page"
+	perPageFlagName             = "per-page"
+	hostIDFlagName              = "host-id"
+	onlyErrorsFlagName          = "only-errors"
+	matchFlagName               = "match"
+	statusFlagName              = "status"
 )
 
 type specGeneric struct {
@@ -93,6 +106,16 @@ func printLabel(c *cli.Context, label *fleet.LabelSpec) error {
 	return printSpec(c, spec)
 }
 
+func printCustomRole(c *cli.Context, role *fleet.CustomRoleSpec) error {
+	spec := specGeneric{
+		Kind:    fleet.CustomRoleKind,
+		Version: fleet.ApiVersion,
+		Spec:    role,
+	}
+
+	return printSpec(c, spec)
+}
+
 func printQuery(c *cli.Context, query *fleet.QuerySpec) error {
 	spec := specGeneric{
 		Kind:    fleet.QueryKind,
@@ -257,6 +280,170 @@ func printSpec(c *cli.Context, spec specGeneric) error {
 	return err
 }
 
+// queryReportPageSize is the number of rows retrieved per request when listing
+// the rows of a query report, it is also used to find the query by name.
+const queryReportPageSize = 500
+
+func getQueryReportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "query-report",
+		Usage:     "Retrieve the latest results of a scheduled query on each host",
+		UsageText: `fleetctl get query-report [options] <query name>`,
+		Flags: []cli.Flag{
+			&cli.UintFlag{
+				Name:  teamFlagName,
+				Usage: "Only retrieve the results of the hosts in this team",
+			},
+			&cli.UintFlag{
+				Name:  hostIDFlagName,
+				Usage: "Only retrieve the results of the host with this ID",
+			},
+			&cli.StringFlag{
+				Name:  matchFlagName,
+				Usage: "Only retrieve the results whose hostname or values contain this text",
+			},
+			&cli.BoolFlag{
+				Name:  csvFlagName,
+				Usage: "Output in CSV format, one line per row",
+			},
+			&cli.BoolFlag{
+				Name:  jsonFlagName,
+				Usage: "Output in JSON format, one line per row",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			name := c.Args().First()
+			if name == "" {
+				return errors.New("must provide query name as first argument")
+			}
+
+			if c.Bool(csvFlagName) && c.Bool(jsonFlagName) {
+				return errors.New("Can't specify both csv and json flags.")
+			}
+
+			queryID, err := queryIDByName(client, name)
+			if err != nil {
+				return err
+			}
+
+			query := url.Values{}
+			query.Set("per_page", strconv.Itoa(queryReportPageSize))
+			if c.IsSet(teamFlagName) {
+				query.Set("team_id", strconv.FormatUint(uint64(c.Uint(teamFlagName)), 10))
+			}
+			if c.IsSet(hostIDFlagName) {
+				query.Set("host_id", strconv.FormatUint(uint64(c.Uint(hostIDFlagName)), 10))
+			}
+			if match := c.String(matchFlagName); match != "" {
+				query.Set("query", match)
+			}
+
+			var rows []*fleet.QueryReportRow
+			for page := 0; ; page++ {
+				query.Set("page", strconv.Itoa(page))
+				pageRows, err := client.GetQueryReport(queryID, query.Encode())
+				if err != nil {
+					return fmt.Errorf("could not get query report: %w", err)
+				}
+				rows = append(rows, pageRows...)
+				if len(pageRows) < queryReportPageSize {
+					break
+				}
+			}
+
+			if len(rows) == 0 {
+				log(c, "No results found")
+				return nil
+			}
+
+			if c.Bool(jsonFlagName) {
+				enc := json.NewEncoder(c.App.Writer)
+				for _, row := range rows {
+					if err := enc.Encode(row); err != nil {
+						return fmt.Errorf("print query report row: %w", err)
+					}
+				}
+				return nil
+			}
+
+			columns, data := queryReportTable(rows)
+			if c.Bool(csvFlagName) {
+				w := csv.NewWriter(c.App.Writer)
+				if err := w.Write(columns); err != nil {
+					return fmt.Errorf("print query report: %w", err)
+				}
+				if err := w.WriteAll(data); err != nil {
+					return fmt.Errorf("print query report: %w", err)
+				}
+				return nil
+			}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
+// queryIDByName returns the ID of the saved query with the name.
+func queryIDByName(client *service.Client, name string) (uint, error) {
+	query := url.Values{}
+	query.Set("per_page", strconv.Itoa(queryReportPageSize))
+	for page := 0; ; page++ {
+		query.Set("page", strconv.Itoa(page))
+		queries, err := client.ListQueries(query.Encode())
+		if err != nil {
+			return 0, fmt.Errorf("could not list queries: %w", err)
+		}
+		for _, q := range queries {
+			if q.Name == name {
+				return q.ID, nil
+			}
+		}
+		if len(queries) < queryReportPageSize {
+			return 0, fmt.Errorf("Query '%s' not found", name)
+		}
+	}
+}
+
+// queryReportTable returns the columns and the rows of the query report, with
+// the hostname first, the columns of the query sorted by name and the time the
+// row was fetched last.
+func queryReportTable(rows []*fleet.QueryReportRow) ([]string, [][]string) {
+	colSet := make(map[string]bool)
+	for _, row := range rows {
+		for col := range row.Columns {
+			colSet[col] = true
+		}
+	}
+	var queryColumns []string
+	for col := range colSet {
+		queryColumns = append(queryColumns, col)
+	}
+	sort.Strings(queryColumns)
+
+	columns := append([]string{"hostname"}, queryColumns...)
+	columns = append(columns, "last_fetched")
+
+	data := [][]string{}
+	for _, row := range rows {
+		line := []string{row.Hostname}
+		for _, col := range queryColumns {
+			line = append(line, row.Columns[col])
+		}
+		line = append(line, row.LastFetched.UTC().Format(time.RFC3339))
+		data = append(data, line)
+	}
+	return columns, data
+}
+
 func getCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "get",
@@ -271,8 +458,14 @@ func getCommand() *cli.Command {
 			getCarveCommand(),
 			getCarvesCommand(),
 			getUserRolesCommand(),
+			getCustomRolesCommand(),
 			getTeamsCommand(),
 			getSoftwareCommand(),
+			getActivitiesCommand(),
+			getCampaignResultsCommand(),
+			getCampaignHostsCommand(),
+			getCampaignExportCommand(),
+			getQueryReportCommand(),
 		},
 	}
 }
@@ -876,6 +1069,79 @@ func getUserRolesCommand() *cli.Command {
 	}
 }
 
+func getCustomRolesCommand() *cli.Command {
+	return &cli.Command{
+		Name:    "custom_roles",
+		Aliases: []string{"custom_role", "cr"},
+		Usage:   "List information about one or more custom roles",
+		Flags: []cli.Flag{
+			jsonFlag(),
+			yamlFlag(),
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			name := c.Args().First()
+
+			// if name wasn't provided, list all custom roles
+			if name == "" {
+				roles, err := client.GetCustomRoles()
+				if err != nil {
+					return fmt.Errorf("could not list custom roles: %w", err)
+				}
+
+				if c.Bool(yamlFlagName) || c.Bool(jsonFlagName) {
+					for _, role := range roles {
+						if err := printCustomRole(c, role); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+
+				if len(roles) == 0 {
+					log(c, "No custom roles found")
+					return nil
+				}
+
+				// Default to printing as a table
+				data := [][]string{}
+
+				for _, role := range roles {
+					permissions := make([]string, 0, len(role.Permissions))
+					for _, p := range role.Permissions {
+						permissions = append(permissions, p.Object+":"+strings.Join(p.Actions, ","))
+					}
+					data = append(data, []string{
+						role.Name,
+						role.Description,
+						strings.Join(permissions, " "),
+					})
+				}
+
+				columns := []string{"name", "description", "permissions"}
+				printTable(c, columns, data)
+
+				return nil
+			}
+
+			// Custom role name was specified
+			role, err := client.GetCustomRole(name)
+			if err != nil {
+				return err
+			}
+
+			return printCustomRole(c, role)
+		},
+	}
+}
+
 func printTable(c *cli.Context, columns []string, data [][]string) {
 	table := defaultTable(c.App.Writer)
 	table.SetHeader(columns)
@@ -1022,3 +1288,426 @@ func getSoftwareCommand() *cli.Command {
 		},
 	}
 }
+
+func getActivitiesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "activities",
+		Usage: "List the activities, including the archived ones",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  monthFlagName,
+				Usage: "List the activities archived for the month (e.g. 2022-08)",
+			},
+			&cli.BoolFlag{
+				Name:  archivedMonthsFlagName,
+				Usage: "List the months that have archived activities",
+			},
+			&cli.StringFlag{
+				Name:  activityTypeFlagName,
+				Usage: "Only list the activities of this type (not supported with --month)",
+			},
+			&cli.UintFlag{
+				Name:  pageFlagName,
+				Usage: "Page of the activities to list",
+			},
+			&cli.UintFlag{
+				Name:  perPageFlagName,
+				Usage: "Number of activities per page",
+				Value: 100,
+			},
+			jsonFlag(),
+			yamlFlag(),
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			if c.Bool(yamlFlagName) && c.Bool(jsonFlagName) {
+				return errors.New("Can't specify both yaml and json flags.")
+			}
+
+			if c.Bool(archivedMonthsFlagName) {
+				months, err := client.ListArchivedActivityMonths()
+				if err != nil {
+					return fmt.Errorf("could not list archived activity months: %w", err)
+				}
+				if len(months) == 0 {
+					log(c, "No archived activities found")
+					return nil
+				}
+				for _, month := range months {
+					fmt.Fprintln(c.App.Writer, month)
+				}
+				return nil
+			}
+
+			query := url.Values{}
+			query.Set("page", strconv.FormatUint(uint64(c.Uint(pageFlagName)), 10))
+			query.Set("per_page", strconv.FormatUint(uint64(c.Uint(perPageFlagName)), 10))
+
+			var activities []*fleet.Activity
+			if month := c.String(monthFlagName); month != "" {
+				if c.String(activityTypeFlagName) != "" {
+					return fmt.Errorf("--%s cannot be used with --%s", activityTypeFlagName, monthFlagName)
+				}
+				activities, err = client.ListArchivedActivities(month, query.Encode())
+			} else {
+				query.Set("order_key", "a.id")
+				query.Set("order_direction", "desc")
+				if activityType := c.String(activityTypeFlagName); activityType != "" {
+					query.Set("activity_type", activityType)
+				}
+				activities, err = client.ListActivities(query.Encode())
+			}
+			if err != nil {
+				return fmt.Errorf("could not list activities: %w", err)
+			}
+
+			if len(activities) == 0 {
+				log(c, "No activities found")
+				return nil
+			}
+
+			if c.Bool(jsonFlagName) || c.Bool(yamlFlagName) {
+				spec := specGeneric{
+					Kind:    "activity",
+					Version: "1",
+					Spec:    activities,
+				}
+				return printSpec(c, spec)
+			}
+
+			// Default to printing as table
+			data := [][]string{}
+			for _, a := range activities {
+				var details string
+				if a.Details != nil {
+					details = string(*a.Details)
+				}
+				data = append(data, []string{
+					fmt.Sprint(a.ID),
+					a.CreatedAt.UTC().Format(time.RFC3339),
+					a.Type,
+					a.ActorFullName,
+					details,
+				})
+			}
+			columns := []string{"ID", "Created At", "Type", "Actor", "Details"}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
+// campaignResultsPageSize is the number of host results retrieved per request
+// when listing the results of a campaign.
+const campaignResultsPageSize = 100
+
+func getCampaignResultsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "campaign-results",
+		Usage:     "Retrieve the stored results of a live query campaign by ID",
+		UsageText: `fleetctl get campaign-results [options] <campaign ID>`,
+		Flags: []cli.Flag{
+			&cli.UintFlag{
+				Name:  hostIDFlagName,
+				Usage: "Only retrieve the results of the host with this ID",
+			},
+			&cli.BoolFlag{
+				Name:  onlyErrorsFlagName,
+				Usage: "Only retrieve the hosts that failed to run the query",
+			},
+			&cli.BoolFlag{
+				Name:  csvFlagName,
+				Usage: "Output in CSV format, one line per row",
+			},
+			&cli.BoolFlag{
+				Name:  jsonFlagName,
+				Usage: "Output in JSON format, one line per host",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			idString := c.Args().First()
+			if idString == "" {
+				return errors.New("must provide campaign ID as first argument")
+			}
+			id, err := strconv.ParseUint(idString, 10, 64)
+			if err != nil {
+				return fmt.Errorf("unable to parse campaign ID as int: %w", err)
+			}
+
+			if c.Bool(csvFlagName) && c.Bool(jsonFlagName) {
+				return errors.New("Can't specify both csv and json flags.")
+			}
+
+			query := url.Values{}
+			query.Set("per_page", strconv.Itoa(campaignResultsPageSize))
+			if c.IsSet(hostIDFlagName) {
+				query.Set("host_id", strconv.FormatUint(uint64(c.Uint(hostIDFlagName)), 10))
+			}
+			if c.Bool(onlyErrorsFlagName) {
+				query.Set("only_errors", "true")
+			}
+
+			var campaign *fleet.DistributedQueryCampaign
+			var results []*fleet.DistributedQueryCampaignResult
+			for page := 0; ; page++ {
+				query.Set("page", strconv.Itoa(page))
+				pageCampaign, pageResults, err := client.ListCampaignResults(uint(id), query.Encode())
+				if err != nil {
+					return fmt.Errorf("could not list campaign results: %w", err)
+				}
+				if campaign == nil {
+					campaign = pageCampaign
+				}
+				results = append(results, pageResults...)
+				if len(pageResults) < campaignResultsPageSize {
+					break
+				}
+			}
+			if campaign != nil && campaign.CollectUntil != nil && campaign.HostCounts != nil {
+				counts := campaign.HostCounts
+				fmt.Fprintf(os.Stderr, "Campaign collects results until %s: %d responded, %d errored, %d pending\n",
+					campaign.CollectUntil.Format(time.RFC3339), counts.Responded, counts.Errored, counts.Pending)
+			}
+
+			if len(results) == 0 {
+				log(c, "No results found")
+				return nil
+			}
+
+			if c.Bool(jsonFlagName) {
+				enc := json.NewEncoder(c.App.Writer)
+				for _, res := range results {
+					if err := enc.Encode(res); err != nil {
+						return fmt.Errorf("print campaign result: %w", err)
+					}
+				}
+				return nil
+			}
+
+			for _, res := range results {
+				if res.Truncated {
+					fmt.Fprintf(os.Stderr, "Warning: only %d of the %d rows of host %s were stored\n", len(res.Rows), res.RowCount, res.Hostname)
+				}
+			}
+			columns, data := campaignResultsTable(results)
+			if c.Bool(csvFlagName) {
+				w := csv.NewWriter(c.App.Writer)
+				if err := w.Write(columns); err != nil {
+					return fmt.Errorf("print campaign results: %w", err)
+				}
+				if err := w.WriteAll(data); err != nil {
+					return fmt.Errorf("print campaign results: %w", err)
+				}
+				return nil
+			}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
+// campaignHostsPageSize is the number of hosts retrieved per request when
+// listing the hosts of an asynchronous campaign.
+const campaignHostsPageSize = 500
+
+func getCampaignHostsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "campaign-hosts",
+		Usage:     "Retrieve the status of the hosts targeted by an asynchronous live query campaign by ID",
+		UsageText: `fleetctl get campaign-hosts [options] <campaign ID>`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  statusFlagName,
+				Usage: "Only retrieve the hosts with this status (pending, responded or errored)",
+			},
+			&cli.BoolFlag{
+				Name:  jsonFlagName,
+				Usage: "Output in JSON format, one line per host",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			idString := c.Args().First()
+			if idString == "" {
+				return errors.New("must provide campaign ID as first argument")
+			}
+			id, err := strconv.ParseUint(idString, 10, 64)
+			if err != nil {
+				return fmt.Errorf("unable to parse campaign ID as int: %w", err)
+			}
+
+			query := url.Values{}
+			query.Set("per_page", strconv.Itoa(campaignHostsPageSize))
+			if status := c.String(statusFlagName); status != "" {
+				query.Set("status", status)
+			}
+
+			var hosts []*fleet.DistributedQueryCampaignHost
+			for page := 0; ; page++ {
+				query.Set("page", strconv.Itoa(page))
+				_, pageHosts, err := client.ListCampaignHosts(uint(id), query.Encode())
+				if err != nil {
+					return fmt.Errorf("could not list campaign hosts: %w", err)
+				}
+				hosts = append(hosts, pageHosts...)
+				if len(pageHosts) < campaignHostsPageSize {
+					break
+				}
+			}
+
+			if len(hosts) == 0 {
+				log(c, "No hosts found")
+				return nil
+			}
+
+			if c.Bool(jsonFlagName) {
+				enc := json.NewEncoder(c.App.Writer)
+				for _, host := range hosts {
+					if err := enc.Encode(host); err != nil {
+						return fmt.Errorf("print campaign host: %w", err)
+					}
+				}
+				return nil
+			}
+
+			columns := []string{"host ID", "hostname", "status", "responded at"}
+			var data [][]string
+			for _, host := range hosts {
+				respondedAt := ""
+				if host.RespondedAt != nil {
+					respondedAt = host.RespondedAt.UTC().Format(time.RFC3339)
+				}
+				data = append(data, []string{
+					strconv.FormatUint(uint64(host.HostID), 10),
+					host.Hostname,
+					string(host.Status),
+					respondedAt,
+				})
+			}
+			printTable(c, columns, data)
+
+			return nil
+		},
+	}
+}
+
+func getCampaignExportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "campaign-export",
+		Usage:     "Retrieve the download link of the exported results of a completed live query campaign by ID",
+		UsageText: `fleetctl get campaign-export [options] <campaign ID>`,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  jsonFlagName,
+				Usage: "Output in JSON format, with the format and the expiration of the link",
+			},
+			configFlag(),
+			contextFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			idString := c.Args().First()
+			if idString == "" {
+				return errors.New("must provide campaign ID as first argument")
+			}
+			id, err := strconv.ParseUint(idString, 10, 64)
+			if err != nil {
+				return fmt.Errorf("unable to parse campaign ID as int: %w", err)
+			}
+
+			export, err := client.CampaignExport(uint(id))
+			if err != nil {
+				return fmt.Errorf("could not get campaign export: %w", err)
+			}
+
+			if c.Bool(jsonFlagName) {
+				if err := json.NewEncoder(c.App.Writer).Encode(export); err != nil {
+					return fmt.Errorf("print campaign export: %w", err)
+				}
+				return nil
+			}
+			fmt.Fprintln(c.App.Writer, export.URL)
+			return nil
+		},
+	}
+}
+
+// campaignResultsTable returns the columns and the rows of the campaign
+// results, one row per result row, with the hostname first and the columns of
+// the query sorted by name. The error column is only present if a host failed
+// to run the query, such hosts have a single row with the error.
+func campaignResultsTable(results []*fleet.DistributedQueryCampaignResult) ([]string, [][]string) {
+	colSet := make(map[string]bool)
+	var hasErrors bool
+	for _, res := range results {
+		if res.Error != nil {
+			hasErrors = true
+		}
+		for _, row := range res.Rows {
+			for col := range row {
+				colSet[col] = true
+			}
+		}
+	}
+	var queryColumns []string
+	for col := range colSet {
+		queryColumns = append(queryColumns, col)
+	}
+	sort.Strings(queryColumns)
+
+	columns := append([]string{"hostname"}, queryColumns...)
+	if hasErrors {
+		columns = append(columns, "error")
+	}
+
+	data := [][]string{}
+	for _, res := range results {
+		if res.Error != nil {
+			line := make([]string, len(columns))
+			line[0] = res.Hostname
+			line[len(line)-1] = *res.Error
+			data = append(data, line)
+			continue
+		}
+		for _, row := range res.Rows {
+			line := []string{res.Hostname}
+			for _, col := range queryColumns {
+				line = append(line, row[col])
+			}
+			if hasErrors {
+				line = append(line, "")
+			}
+			data = append(data, line)
+		}
+	}
+	return columns, data
+}
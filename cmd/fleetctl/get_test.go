@@ -13,6 +13,7 @@ import (
 
 	"github.com/fleetdm/fleet/v4/pkg/spec"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/stretchr/testify/assert"
@@ -162,6 +163,9 @@ spec:
     id: 42
     integrations:
       jira: null
+      microsoft_teams: null
+      slack: null
+      webhook: null
       zendesk: null
     name: team1
     user_count: 99
@@ -189,6 +193,9 @@ spec:
     id: 43
     integrations:
       jira: null
+      microsoft_teams: null
+      slack: null
+      webhook: null
       zendesk: null
     name: team2
     user_count: 87
@@ -199,8 +206,8 @@ spec:
         host_batch_size: 0
         policy_ids: null
 `
-			expectedJson := `{"kind":"team","apiVersion":"v1","spec":{"team":{"id":42,"created_at":"1999-03-10T02:45:06.371Z","name":"team1","description":"team1 description","webhook_settings":{"failing_policies_webhook":{"enable_failing_policies_webhook":false,"destination_url":"","policy_ids":null,"host_batch_size":0}},"integrations":{"jira":null,"zendesk":null},"user_count":99,"host_count":0}}}
-{"kind":"team","apiVersion":"v1","spec":{"team":{"id":43,"created_at":"1999-03-10T02:45:06.371Z","name":"team2","description":"team2 description","agent_options":{"config":{"foo":"bar"},"overrides":{"platforms":{"darwin":{"foo":"override"}}}},"webhook_settings":{"failing_policies_webhook":{"enable_failing_policies_webhook":false,"destination_url":"","policy_ids":null,"host_batch_size":0}},"integrations":{"jira":null,"zendesk":null},"user_count":87,"host_count":0}}}
+			expectedJson := `{"kind":"team","apiVersion":"v1","spec":{"team":{"id":42,"created_at":"1999-03-10T02:45:06.371Z","name":"team1","description":"team1 description","webhook_settings":{"failing_policies_webhook":{"enable_failing_policies_webhook":false,"destination_url":"","policy_ids":null,"host_batch_size":0}},"integrations":{"jira":null,"zendesk":null,"webhook":null,"slack":null,"microsoft_teams":null},"user_count":99,"host_count":0}}}
+{"kind":"team","apiVersion":"v1","spec":{"team":{"id":43,"created_at":"1999-03-10T02:45:06.371Z","name":"team2","description":"team2 description","agent_options":{"config":{"foo":"bar"},"overrides":{"platforms":{"darwin":{"foo":"override"}}}},"webhook_settings":{"failing_policies_webhook":{"enable_failing_policies_webhook":false,"destination_url":"","policy_ids":null,"host_batch_size":0}},"integrations":{"jira":null,"zendesk":null,"webhook":null,"slack":null,"microsoft_teams":null},"user_count":87,"host_count":0}}}
 `
 			if tt.shouldHaveExpiredBanner {
 				expectedJson = expiredBanner.String() + expectedJson
@@ -446,6 +453,17 @@ kind: config
 spec:
   fleet_desktop:
     transparency_url: https://fleetdm.com/transparency
+  activity_expiry_settings:
+    activity_expiry_enabled: false
+    activity_expiry_window: 0
+    archive_enabled: false
+  live_query_results_settings:
+    max_rows_per_campaign: 0
+    max_rows_per_host: 0
+    retention_days: 0
+  query_reports_settings:
+    enabled: false
+    max_rows_per_query: 0
   host_expiry_settings:
     host_expiry_enabled: false
     host_expiry_window: 0
@@ -454,6 +472,9 @@ spec:
     enable_software_inventory: false
   integrations:
     jira: null
+    microsoft_teams: null
+    slack: null
+    webhook: null
     zendesk: null
   org_info:
     org_logo_url: ""
@@ -482,11 +503,30 @@ spec:
     enable_sso: false
     enable_sso_idp_login: false
     entity_id: ""
+    groups_attribute: ""
     idp_image_url: ""
     idp_name: ""
     issuer_uri: ""
     metadata: ""
     metadata_url: ""
+    oidc:
+      client_id: ""
+      client_secret: ""
+      email_claim: ""
+      issuer_url: ""
+      name_claim: ""
+      scopes: null
+    protocol: ""
+    reject_unmapped_users: false
+    role_mappings: null
+  two_factor_auth_settings:
+    enforced: false
+  password_policy:
+    history_count: 0
+    max_age_days: 0
+    min_length: 0
+    require_lowercase: false
+    require_uppercase: false
   vulnerability_settings:
     databases_path: /some/path
   webhook_settings:
@@ -537,6 +577,20 @@ spec:
       "host_expiry_enabled": false,
       "host_expiry_window": 0
     },
+    "activity_expiry_settings": {
+      "activity_expiry_enabled": false,
+      "activity_expiry_window": 0,
+      "archive_enabled": false
+    },
+    "live_query_results_settings": {
+      "retention_days": 0,
+      "max_rows_per_host": 0,
+      "max_rows_per_campaign": 0
+    },
+    "query_reports_settings": {
+      "enabled": false,
+      "max_rows_per_query": 0
+    },
     "features": {
       "enable_host_users": true,
       "enable_software_inventory": false
@@ -550,7 +604,27 @@ spec:
       "idp_name": "",
       "enable_jit_provisioning": false,
       "enable_sso": false,
-      "enable_sso_idp_login": false
+      "enable_sso_idp_login": false,
+      "protocol": "",
+      "oidc": {
+        "issuer_url": "",
+        "client_id": "",
+        "client_secret": "",
+        "scopes": null,
+        "email_claim": "",
+        "name_claim": ""
+      },
+      "groups_attribute": "",
+      "role_mappings": null,
+      "reject_unmapped_users": false
+    },
+    "two_factor_auth_settings": { "enforced": false },
+    "password_policy": {
+      "min_length": 0,
+      "require_uppercase": false,
+      "require_lowercase": false,
+      "history_count": 0,
+      "max_age_days": 0
     },
     "fleet_desktop": { "transparency_url": "https://fleetdm.com/transparency" },
     "vulnerability_settings": { "databases_path": "/some/path" },
@@ -574,7 +648,7 @@ spec:
       },
       "interval": "0s"
     },
-    "integrations": { "jira": null, "zendesk": null }
+    "integrations": { "jira": null, "zendesk": null, "webhook": null, "slack": null, "microsoft_teams": null }
   }
 }
 `
@@ -591,6 +665,17 @@ kind: config
 spec:
   fleet_desktop:
     transparency_url: https://fleetdm.com/transparency
+  activity_expiry_settings:
+    activity_expiry_enabled: false
+    activity_expiry_window: 0
+    archive_enabled: false
+  live_query_results_settings:
+    max_rows_per_campaign: 0
+    max_rows_per_host: 0
+    retention_days: 0
+  query_reports_settings:
+    enabled: false
+    max_rows_per_query: 0
   host_expiry_settings:
     host_expiry_enabled: false
     host_expiry_window: 0
@@ -599,6 +684,9 @@ spec:
     enable_software_inventory: false
   integrations:
     jira: null
+    microsoft_teams: null
+    slack: null
+    webhook: null
     zendesk: null
   license:
     expiration: "0001-01-01T00:00:00Z"
@@ -647,11 +735,30 @@ spec:
     enable_sso: false
     enable_sso_idp_login: false
     entity_id: ""
+    groups_attribute: ""
     idp_image_url: ""
     idp_name: ""
     issuer_uri: ""
     metadata: ""
     metadata_url: ""
+    oidc:
+      client_id: ""
+      client_secret: ""
+      email_claim: ""
+      issuer_url: ""
+      name_claim: ""
+      scopes: null
+    protocol: ""
+    reject_unmapped_users: false
+    role_mappings: null
+  two_factor_auth_settings:
+    enforced: false
+  password_policy:
+    history_count: 0
+    max_age_days: 0
+    min_length: 0
+    require_lowercase: false
+    require_uppercase: false
   update_interval:
     osquery_detail: 1h0m0s
     osquery_policy: 1h0m0s
@@ -717,6 +824,20 @@ spec:
       "host_expiry_enabled": false,
       "host_expiry_window": 0
     },
+    "activity_expiry_settings": {
+      "activity_expiry_enabled": false,
+      "activity_expiry_window": 0,
+      "archive_enabled": false
+    },
+    "live_query_results_settings": {
+      "retention_days": 0,
+      "max_rows_per_host": 0,
+      "max_rows_per_campaign": 0
+    },
+    "query_reports_settings": {
+      "enabled": false,
+      "max_rows_per_query": 0
+    },
     "features": {
       "enable_host_users": true,
       "enable_software_inventory": false
@@ -730,7 +851,27 @@ spec:
       "metadata_url": "",
       "idp_name": "",
       "enable_sso": false,
-      "enable_sso_idp_login": false
+      "enable_sso_idp_login": false,
+      "protocol": "",
+      "oidc": {
+        "issuer_url": "",
+        "client_id": "",
+        "client_secret": "",
+        "scopes": null,
+        "email_claim": "",
+        "name_claim": ""
+      },
+      "groups_attribute": "",
+      "role_mappings": null,
+      "reject_unmapped_users": false
+    },
+    "two_factor_auth_settings": { "enforced": false },
+    "password_policy": {
+      "min_length": 0,
+      "require_uppercase": false,
+      "require_lowercase": false,
+      "history_count": 0,
+      "max_age_days": 0
     },
     "fleet_desktop": {
       "transparency_url": "https://fleetdm.com/transparency"
@@ -760,7 +901,10 @@ spec:
     },
     "integrations": {
       "jira": null,
-      "zendesk": null
+      "zendesk": null,
+      "webhook": null,
+      "slack": null,
+      "microsoft_teams": null
     },
     "update_interval": {
       "osquery_detail": "1h0m0s",
@@ -1347,7 +1491,22 @@ func TestEnrichedAppConfig(t *testing.T) {
           "idp_name": "",
           "enable_sso": false,
           "enable_sso_idp_login": false,
-          "enable_jit_provisioning": false
+          "enable_jit_provisioning": false,
+          "protocol": "",
+          "oidc": {
+            "issuer_url": "",
+            "client_id": "",
+            "client_secret": "",
+            "scopes": null,
+            "email_claim": "",
+            "name_claim": ""
+          },
+          "groups_attribute": "",
+          "role_mappings": null,
+          "reject_unmapped_users": false
+        },
+        "two_factor_auth_settings": {
+          "enforced": false
         },
         "fleet_desktop": {
           "transparency_url": "https://fleetdm.com/transparency"
@@ -1377,7 +1536,10 @@ func TestEnrichedAppConfig(t *testing.T) {
         },
         "integrations": {
           "jira": null,
-          "zendesk": null
+          "zendesk": null,
+          "webhook": null,
+          "slack": null,
+          "microsoft_teams": null
         },
         "update_interval": {
           "osquery_detail": 3600000000000,
@@ -1431,3 +1593,206 @@ func TestEnrichedAppConfig(t *testing.T) {
 		require.Equal(t, "filesystem", enriched.Logging.Status.Plugin)
 	})
 }
+
+func TestGetActivities(t *testing.T) {
+	archive := new(mock.ActivityArchiveStore)
+	_, ds := runServerWithMockedDS(t, &service.TestServerOpts{ActivityArchive: archive})
+
+	details := json.RawMessage(`{"host_id":1}`)
+	createdAt := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	recent := &fleet.Activity{ID: 42, ActorFullName: "Alice", Type: fleet.ActivityTypeDeletedHost, Details: &details}
+	recent.CreatedAt = createdAt
+	archived := &fleet.Activity{ID: 7, ActorFullName: "Bob", Type: fleet.ActivityTypeCreatedUser}
+	archived.CreatedAt = createdAt.AddDate(0, -1, 0)
+
+	ds.ListActivitiesFunc = func(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
+		require.Equal(t, fleet.ActivityTypeDeletedHost, opt.ActivityType)
+		require.Equal(t, uint(10), opt.PerPage)
+		return []*fleet.Activity{recent}, nil
+	}
+	archive.ListArchivedActivityMonthsFunc = func(ctx context.Context) ([]string, error) {
+		return []string{"2022-08"}, nil
+	}
+	archive.ListArchivedActivitiesFunc = func(ctx context.Context, month string, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+		require.Equal(t, "2022-08", month)
+		require.Equal(t, uint(1), opt.Page)
+		return []*fleet.Activity{archived}, nil
+	}
+
+	out := runAppForTest(t, []string{"get", "activities", "--type", fleet.ActivityTypeDeletedHost, "--per-page", "10"})
+	assert.Contains(t, out, "2022-09-01T10:00:00Z")
+	assert.Contains(t, out, "Alice")
+	assert.Contains(t, out, `{"host_id":1}`)
+
+	assert.Equal(t, "2022-08\n", runAppForTest(t, []string{"get", "activities", "--archived-months"}))
+
+	out = runAppForTest(t, []string{"get", "activities", "--month", "2022-08", "--page", "1", "--json"})
+	assert.Contains(t, out, `"kind":"activity"`)
+	assert.Contains(t, out, `"actor_full_name":"Bob"`)
+
+	runAppCheckErr(t, []string{"get", "activities", "--month", "2022-08", "--type", "created_user"}, "--type cannot be used with --month")
+}
+
+func TestGetCampaignResults(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		// the campaign was started by the admin user of the test server
+		admin, err := ds.UserByIDFunc(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &fleet.DistributedQueryCampaign{ID: id, UserID: admin.ID, Status: fleet.QueryComplete}, nil
+	}
+	var lastOpt fleet.ListCampaignResultsOptions
+	ds.ListDistributedQueryCampaignResultsFunc = func(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) ([]*fleet.DistributedQueryCampaignResult, error) {
+		require.Equal(t, uint(42), campaignID)
+		lastOpt = opt
+		if opt.Page > 0 {
+			return nil, nil
+		}
+		return []*fleet.DistributedQueryCampaignResult{
+			{DistributedQueryCampaignID: 42, HostID: 1, Hostname: "foo.local", Rows: []map[string]string{{"b": "2", "a": "1"}}, RowCount: 1},
+			{DistributedQueryCampaignID: 42, HostID: 2, Hostname: "bar.local", Rows: []map[string]string{}, Error: ptr.String("no such table")},
+		}, nil
+	}
+
+	out := runAppForTest(t, []string{"get", "campaign-results", "42"})
+	assert.Contains(t, out, "foo.local")
+	assert.Contains(t, out, "no such table")
+
+	expectedCSV := "hostname,a,b,error\nfoo.local,1,2,\nbar.local,,,no such table\n"
+	assert.Equal(t, expectedCSV, runAppForTest(t, []string{"get", "campaign-results", "--csv", "42"}))
+
+	out = runAppForTest(t, []string{"get", "campaign-results", "--json", "--host-id", "1", "--only-errors", "42"})
+	assert.Contains(t, out, `"hostname":"foo.local","rows":[{"a":"1","b":"2"}]`)
+	assert.Equal(t, 2, strings.Count(out, "\n"))
+	require.NotNil(t, lastOpt.HostID)
+	assert.Equal(t, uint(1), *lastOpt.HostID)
+	assert.True(t, lastOpt.OnlyErrors)
+
+	runAppCheckErr(t, []string{"get", "campaign-results", "--csv", "--json", "42"}, "Can't specify both csv and json flags.")
+	runAppCheckErr(t, []string{"get", "campaign-results"}, "must provide campaign ID as first argument")
+}
+
+func TestGetCampaignHosts(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		// the campaign was started by the admin user of the test server
+		admin, err := ds.UserByIDFunc(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		collectUntil := time.Now().Add(time.Hour)
+		return &fleet.DistributedQueryCampaign{ID: id, UserID: admin.ID, Status: fleet.QueryRunning, CollectUntil: &collectUntil}, nil
+	}
+	ds.CountDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint) (*fleet.CampaignHostCounts, error) {
+		return &fleet.CampaignHostCounts{Pending: 1, Responded: 1}, nil
+	}
+	respondedAt := time.Date(2022, 9, 15, 10, 0, 0, 0, time.UTC)
+	var lastOpt fleet.ListCampaignHostsOptions
+	ds.ListDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) ([]*fleet.DistributedQueryCampaignHost, error) {
+		require.Equal(t, uint(42), campaignID)
+		lastOpt = opt
+		if opt.Page > 0 {
+			return nil, nil
+		}
+		return []*fleet.DistributedQueryCampaignHost{
+			{HostID: 1, Hostname: "foo.local", Status: fleet.CampaignHostResponded, RespondedAt: &respondedAt},
+			{HostID: 2, Hostname: "bar.local", Status: fleet.CampaignHostPending},
+		}, nil
+	}
+
+	out := runAppForTest(t, []string{"get", "campaign-hosts", "42"})
+	assert.Contains(t, out, "foo.local")
+	assert.Contains(t, out, "2022-09-15T10:00:00Z")
+	assert.Contains(t, out, "pending")
+
+	out = runAppForTest(t, []string{"get", "campaign-hosts", "--json", "--status", "pending", "42"})
+	assert.Contains(t, out, `"hostname":"bar.local","status":"pending","responded_at":null`)
+	assert.Equal(t, 2, strings.Count(out, "\n"))
+	assert.Equal(t, fleet.CampaignHostPending, lastOpt.Status)
+
+	_, err := runAppNoChecks([]string{"get", "campaign-hosts", "--status", "unknown", "42"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status must be one of pending, responded or errored")
+	runAppCheckErr(t, []string{"get", "campaign-hosts"}, "must provide campaign ID as first argument")
+}
+
+func TestGetCampaignExport(t *testing.T) {
+	store := new(mock.LiveQueryExportStore)
+	_, ds := runServerWithMockedDS(t, &service.TestServerOpts{LiveQueryExport: store})
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		// the campaign was started by the admin user of the test server
+		admin, err := ds.UserByIDFunc(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		campaign := &fleet.DistributedQueryCampaign{ID: id, UserID: admin.ID, Status: fleet.QueryRunning, ExportFormat: fleet.LiveQueryExportCSV}
+		if id != 43 {
+			campaign.Status = fleet.QueryComplete
+			campaign.ExportedAt = ptr.Time(time.Now())
+		}
+		return campaign, nil
+	}
+	store.CampaignExportURLFunc = func(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat, expiration time.Duration) (string, error) {
+		return "https://example.com/results.csv?signature=abc", nil
+	}
+
+	assert.Equal(t, "https://example.com/results.csv?signature=abc\n", runAppForTest(t, []string{"get", "campaign-export", "42"}))
+
+	out := runAppForTest(t, []string{"get", "campaign-export", "--json", "42"})
+	assert.Contains(t, out, `"campaign_id":42,"format":"csv","url":"https://example.com/results.csv?signature=abc"`)
+
+	_, err := runAppNoChecks([]string{"get", "campaign-export", "43"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the export is not available until the campaign completed")
+	runAppCheckErr(t, []string{"get", "campaign-export"}, "must provide campaign ID as first argument")
+}
+
+func TestGetQueryReport(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	ds.ListQueriesFunc = func(ctx context.Context, opt fleet.ListQueryOptions) ([]*fleet.Query, error) {
+		return []*fleet.Query{{ID: 41, Name: "other"}, {ID: 42, Name: "apps"}}, nil
+	}
+	ds.QueryFunc = func(ctx context.Context, id uint) (*fleet.Query, error) {
+		return &fleet.Query{ID: id, Name: "apps"}, nil
+	}
+	fetchedAt := time.Date(2022, 9, 14, 10, 0, 0, 0, time.UTC)
+	var lastOpt fleet.QueryReportOptions
+	ds.ListQueryReportRowsFunc = func(ctx context.Context, queryID uint, filter fleet.TeamFilter, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error) {
+		require.Equal(t, uint(42), queryID)
+		lastOpt = opt
+		if opt.Page > 0 {
+			return nil, nil
+		}
+		return []*fleet.QueryReportRow{
+			{ScheduledQueryID: 1, HostID: 1, Hostname: "foo.local", Columns: map[string]string{"name": "Slack", "version": "4.28"}, LastFetched: fetchedAt},
+			{ScheduledQueryID: 1, HostID: 2, Hostname: "bar.local", Columns: map[string]string{"name": "Zoom"}, LastFetched: fetchedAt},
+		}, nil
+	}
+
+	out := runAppForTest(t, []string{"get", "query-report", "apps"})
+	assert.Contains(t, out, "foo.local")
+	assert.Contains(t, out, "Zoom")
+
+	expectedCSV := "hostname,name,version,last_fetched\nfoo.local,Slack,4.28,2022-09-14T10:00:00Z\nbar.local,Zoom,,2022-09-14T10:00:00Z\n"
+	assert.Equal(t, expectedCSV, runAppForTest(t, []string{"get", "query-report", "--csv", "apps"}))
+
+	out = runAppForTest(t, []string{"get", "query-report", "--json", "--team", "3", "--host-id", "1", "--match", "Sla", "apps"})
+	assert.Contains(t, out, `"hostname":"foo.local"`)
+	assert.Contains(t, out, `"columns":{"name":"Slack","version":"4.28"}`)
+	assert.Equal(t, 2, strings.Count(out, "\n"))
+	require.NotNil(t, lastOpt.TeamID)
+	assert.Equal(t, uint(3), *lastOpt.TeamID)
+	require.NotNil(t, lastOpt.HostID)
+	assert.Equal(t, uint(1), *lastOpt.HostID)
+	assert.Equal(t, "Sla", lastOpt.MatchQuery)
+
+	runAppCheckErr(t, []string{"get", "query-report", "--csv", "--json", "apps"}, "Can't specify both csv and json flags.")
+	runAppCheckErr(t, []string{"get", "query-report"}, "must provide query name as first argument")
+	runAppCheckErr(t, []string{"get", "query-report", "missing"}, "Query 'missing' not found")
+}
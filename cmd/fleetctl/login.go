@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,6 +15,7 @@ func loginCommand() *cli.Command {
 	var (
 		flEmail    string
 		flPassword string
+		flTOTPCode string
 	)
 	return &cli.Command{
 		Name:  "login",
@@ -21,7 +23,7 @@ func loginCommand() *cli.Command {
 		UsageText: `
 fleetctl login [options]
 
-Interactively prompts for email and password if not specified in the flags or environment variables.
+Interactively prompts for email and password if not specified in the flags or environment variables. If two-factor authentication is enabled, also prompts for the code of the authenticator app (or a recovery code) if not specified with --totp-code.
 
 Trying to login with SSO? First, login to the Fleet UI and retrieve your API token from the "My account" page. Then set your API token with the fleetctl config set --token <your-api-token-here> command. You're now logged in to fleetctl.
 `,
@@ -40,6 +42,12 @@ Trying to login with SSO? First, login to the Fleet UI and retrieve your API tok
 				Destination: &flPassword,
 				Usage:       "Password to use to log in (recommended to use interactive entry)",
 			},
+			&cli.StringFlag{
+				Name:        "totp-code",
+				Value:       "",
+				Destination: &flTOTPCode,
+				Usage:       "Two-factor authentication code to use to log in",
+			},
 			configFlag(),
 			contextFlag(),
 			debugFlag(),
@@ -71,6 +79,10 @@ Trying to login with SSO? First, login to the Fleet UI and retrieve your API tok
 			}
 
 			token, err := fleet.Login(flEmail, flPassword)
+			var totpErr *service.TOTPRequiredErr
+			if errors.As(err, &totpErr) {
+				token, err = loginTOTP(fleet, totpErr, flTOTPCode)
+			}
 			if err != nil {
 				root := ctxerr.Cause(err)
 				switch root.(type) {
@@ -96,3 +108,38 @@ Trying to login with SSO? First, login to the Fleet UI and retrieve your API tok
 		},
 	}
 }
+
+// loginTOTP completes the two-factor authentication challenge of the login,
+// enrolling the user first if two-factor authentication is enforced.
+func loginTOTP(fleet *service.Client, totpErr *service.TOTPRequiredErr, code string) (string, error) {
+	if totpErr.EnrollmentRequired {
+		enrollment, err := fleet.EnrollTOTPForLogin(totpErr.Token)
+		if err != nil {
+			return "", err
+		}
+		fmt.Println("Two-factor authentication is required. Add this secret to your authenticator app:")
+		fmt.Printf("  Secret: %s\n", enrollment.Secret)
+		fmt.Printf("  URI: %s\n", enrollment.ProvisioningURI)
+		// the code must be generated with the new secret
+		code = ""
+	}
+
+	if code == "" {
+		fmt.Print("Two-factor authentication code: ")
+		if _, err := fmt.Scanln(&code); err != nil {
+			return "", fmt.Errorf("error reading two-factor authentication code: %w", err)
+		}
+	}
+
+	token, recoveryCodes, err := fleet.LoginTOTP(totpErr.Token, code)
+	if err != nil {
+		return "", err
+	}
+	if len(recoveryCodes) > 0 {
+		fmt.Println("Two-factor authentication enabled. Store these recovery codes in a safe place, each of them can be used once instead of a code:")
+		for _, c := range recoveryCodes {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+	return token, nil
+}
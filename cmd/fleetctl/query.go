@@ -9,14 +9,17 @@ import (
 	"time"
 
 	"github.com/briandowns/spinner"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service"
 	"github.com/urfave/cli/v2"
 )
 
 func queryCommand() *cli.Command {
 	var (
 		flHosts, flLabels, flQuery, flQueryName string
-		flQuiet, flExit, flPretty               bool
-		flTimeout                               time.Duration
+		flGroupBy, flAggregate, flExport        string
+		flQuiet, flExit, flPretty, flAsync      bool
+		flTimeout, flCollectFor                 time.Duration
 	)
 	return &cli.Command{
 		Name:      "query",
@@ -75,6 +78,40 @@ func queryCommand() *cli.Command {
 				Destination: &flTimeout,
 				Usage:       "How long to run query before exiting (10s, 1h, etc.)",
 			},
+			&cli.BoolFlag{
+				Name:        "async",
+				EnvVars:     []string{"ASYNC"},
+				Destination: &flAsync,
+				Usage:       "Collect the results of the hosts as they check in, including the offline hosts, and exit immediately",
+			},
+			&cli.DurationFlag{
+				Name:        "collect-for",
+				EnvVars:     []string{"COLLECT_FOR"},
+				Value:       24 * time.Hour,
+				Destination: &flCollectFor,
+				Usage:       "How long an --async query collects results (1h, 72h, etc., at most 168h)",
+			},
+			&cli.StringFlag{
+				Name:        "group-by",
+				EnvVars:     []string{"GROUP_BY"},
+				Value:       "",
+				Destination: &flGroupBy,
+				Usage:       "Comma separated columns to aggregate the results by on the server, instead of returning the rows of every host",
+			},
+			&cli.StringFlag{
+				Name:        "aggregate",
+				EnvVars:     []string{"AGGREGATE"},
+				Value:       "",
+				Destination: &flAggregate,
+				Usage:       "Comma separated aggregates to compute for each group on the server: count, min(column), max(column), distinct(column)",
+			},
+			&cli.StringFlag{
+				Name:        "export",
+				EnvVars:     []string{"EXPORT"},
+				Value:       "",
+				Destination: &flExport,
+				Usage:       "Export the results to the object storage of the server in this format (ndjson or csv), to download once the campaign completes",
+			},
 			configFlag(),
 			contextFlag(),
 			debugFlag(),
@@ -115,10 +152,40 @@ func queryCommand() *cli.Command {
 			hosts := strings.Split(flHosts, ",")
 			labels := strings.Split(flLabels, ",")
 
-			res, err := fleet.LiveQuery(flQuery, labels, hosts)
+			aggregation, err := parseAggregation(flGroupBy, flAggregate)
+			if err != nil {
+				return err
+			}
+			if aggregation != nil && flAsync {
+				return errors.New("--group-by and --aggregate must not be provided with --async")
+			}
+
+			opts := campaignOptions(flCollectFor, flExport)
+			if flAsync {
+				campaign, err := fleet.AsyncLiveQuery(flQuery, labels, hosts, opts)
+				if err != nil {
+					return err
+				}
+				if !flQuiet && campaign.CollectUntil != nil {
+					fmt.Fprintf(os.Stderr, "Campaign %[1]d collects results until %[2]s, they can be retrieved with: fleetctl get campaign-results %[1]d\n",
+						campaign.ID, campaign.CollectUntil.Format(time.RFC3339))
+					printExportHint(opts, campaign.ID)
+				}
+				fmt.Fprintln(c.App.Writer, campaign.ID)
+				return nil
+			}
+
+			res, err := fleet.LiveQueryWithOptions(c.Context, flQuery, labels, hosts, service.LiveQueryOptions{
+				Aggregation:  aggregation,
+				ExportFormat: opts.ExportFormat,
+			})
 			if err != nil {
 				return err
 			}
+			if !flQuiet {
+				fmt.Fprintf(os.Stderr, "Campaign %[1]d started, the results can be retrieved later with: fleetctl get campaign-results %[1]d\n", res.CampaignID())
+				printExportHint(opts, res.CampaignID())
+			}
 
 			tick := time.NewTicker(100 * time.Millisecond)
 			defer tick.Stop()
@@ -142,8 +209,27 @@ func queryCommand() *cli.Command {
 				timeoutChan = make(chan time.Time)
 			}
 
+			// the aggregate is written once the query is done, it is updated by
+			// the server every few seconds.
+			writeAggregate := func() {
+				if aggregation == nil {
+					return
+				}
+				if err := output.WriteAggregate(res.Aggregate()); err != nil {
+					fmt.Fprintf(os.Stderr, "Error writing aggregate: %s\n", err)
+				}
+			}
+
 			for {
 				select {
+				// Print the hosts that failed to run an aggregated query
+				case hostStatus := <-res.HostStatuses():
+					if hostStatus.Error != nil && !flQuiet {
+						s.Stop()
+						fmt.Fprintf(os.Stderr, "Host %s failed: %s\n", hostStatus.Hostname, *hostStatus.Error)
+						s.Start()
+					}
+
 				// Print a result
 				case hostResult := <-res.Results():
 					s.Stop()
@@ -177,6 +263,8 @@ func queryCommand() *cli.Command {
 					}
 
 					if responded >= online && flExit {
+						s.Stop()
+						writeAggregate()
 						return nil
 					}
 
@@ -191,6 +279,7 @@ func queryCommand() *cli.Command {
 						if !flQuiet {
 							fmt.Fprintln(os.Stderr, msg)
 						}
+						writeAggregate()
 						return nil
 					}
 
@@ -200,9 +289,57 @@ func queryCommand() *cli.Command {
 					if !flQuiet {
 						fmt.Fprintln(os.Stderr, s.Suffix+"\nStopped by timeout")
 					}
+					writeAggregate()
 					return nil
 				}
 			}
 		},
 	}
 }
+
+// parseAggregation returns the aggregation of the --group-by and --aggregate
+// flags, e.g. "version" and "count,max(build)", or nil if none is set.
+func parseAggregation(groupBy, aggregates string) (*fleet.LiveQueryAggregation, error) {
+	if groupBy == "" && aggregates == "" {
+		return nil, nil
+	}
+
+	aggregation := &fleet.LiveQueryAggregation{}
+	if groupBy != "" {
+		aggregation.GroupBy = strings.Split(groupBy, ",")
+	}
+	if aggregates == "" {
+		return aggregation, nil
+	}
+	for _, agg := range strings.Split(aggregates, ",") {
+		agg = strings.TrimSpace(agg)
+		spec := fleet.LiveQueryAggregateSpec{Func: fleet.LiveQueryAggregateFunc(agg)}
+		if i := strings.Index(agg, "("); i >= 0 {
+			if !strings.HasSuffix(agg, ")") {
+				return nil, fmt.Errorf("invalid aggregate %q, expected function(column)", agg)
+			}
+			spec.Func = fleet.LiveQueryAggregateFunc(agg[:i])
+			spec.Column = agg[i+1 : len(agg)-1]
+		}
+		aggregation.Aggregates = append(aggregation.Aggregates, spec)
+	}
+	return aggregation, nil
+}
+
+// campaignOptions returns the campaign options of the --collect-for and
+// --export flags. The collection window is only used by --async queries.
+func campaignOptions(collectFor time.Duration, export string) fleet.CampaignOptions {
+	return fleet.CampaignOptions{
+		CollectWindow: collectFor,
+		ExportFormat:  fleet.LiveQueryExportFormat(export),
+	}
+}
+
+// printExportHint tells how to download the exported results of the campaign,
+// if it is exported.
+func printExportHint(opts fleet.CampaignOptions, campaignID uint) {
+	if opts.ExportFormat == "" {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Once the campaign completes, its exported results can be downloaded from the link returned by: fleetctl get campaign-export %d\n", campaignID)
+}
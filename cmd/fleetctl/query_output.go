@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"sort"
@@ -13,6 +14,9 @@ import (
 
 type outputWriter interface {
 	WriteResult(res fleet.DistributedQueryResult) error
+	// WriteAggregate writes the aggregate of an aggregated live query, which
+	// is nil if it was not received.
+	WriteAggregate(agg *fleet.LiveQueryAggregate) error
 }
 
 type resultOutput struct {
@@ -41,6 +45,13 @@ func (w *jsonWriter) WriteResult(res fleet.DistributedQueryResult) error {
 	return json.NewEncoder(w.w).Encode(out)
 }
 
+func (w *jsonWriter) WriteAggregate(agg *fleet.LiveQueryAggregate) error {
+	if agg == nil {
+		agg = &fleet.LiveQueryAggregate{Rows: []map[string]string{}}
+	}
+	return json.NewEncoder(w.w).Encode(agg)
+}
+
 type prettyWriter struct {
 	results []fleet.DistributedQueryResult
 	columns map[string]bool
@@ -92,3 +103,41 @@ func (w *prettyWriter) WriteResult(res fleet.DistributedQueryResult) error {
 
 	return nil
 }
+
+func (w *prettyWriter) WriteAggregate(agg *fleet.LiveQueryAggregate) error {
+	if agg == nil {
+		agg = &fleet.LiveQueryAggregate{}
+	}
+
+	columnSet := make(map[string]bool)
+	for _, row := range agg.Rows {
+		for col := range row {
+			columnSet[col] = true
+		}
+	}
+	columns := []string{}
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	table := tablewriter.NewWriter(w.writer.Newline())
+	table.SetRowLine(true)
+	table.SetHeader(columns)
+	for _, row := range agg.Rows {
+		cols := []string{}
+		for _, col := range columns {
+			cols = append(cols, row[col])
+		}
+		table.Append(cols)
+	}
+	table.Render()
+	fmt.Fprintf(w.writer.Newline(), "%d rows aggregated from %d hosts (%d errors)\n", agg.RowCount, agg.HostCount, agg.ErrorCount)
+	if agg.Truncated {
+		fmt.Fprintln(w.writer.Newline(), "Warning: the aggregate is truncated, the maximum number of groups or distinct values was reached")
+	}
+
+	w.writer.Flush()
+
+	return nil
+}
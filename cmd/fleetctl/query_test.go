@@ -8,6 +8,7 @@ import (
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/live_query/live_query_mock"
+	"github.com/fleetdm/fleet/v4/server/mock"
 	"github.com/fleetdm/fleet/v4/server/pubsub"
 	"github.com/fleetdm/fleet/v4/server/service"
 	kitlog "github.com/go-kit/kit/log"
@@ -117,3 +118,159 @@ func TestLiveQuery(t *testing.T) {
 `
 	assert.Equal(t, expected, runAppForTest(t, []string{"query", "--hosts", "1234", "--query", "select 42, * from time"}))
 }
+
+func TestLiveQueryAggregated(t *testing.T) {
+	rs := pubsub.NewInmemQueryResults()
+	lq := live_query_mock.New(t)
+
+	_, ds := runServerWithMockedDS(t, &service.TestServerOpts{
+		Rs: rs,
+		Lq: lq,
+	})
+
+	users, err := ds.ListUsersFunc(context.Background(), fleet.UserListOptions{})
+	require.NoError(t, err)
+	var admin *fleet.User
+	for _, user := range users {
+		if user.GlobalRole != nil && *user.GlobalRole == fleet.RoleAdmin {
+			admin = user
+		}
+	}
+
+	ds.HostIDsByNameFunc = func(ctx context.Context, filter fleet.TeamFilter, hostnames []string) ([]uint, error) {
+		return []uint{1234}, nil
+	}
+	ds.LabelIDsByNameFunc = func(ctx context.Context, labels []string) ([]uint, error) {
+		return nil, nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewQueryFunc = func(ctx context.Context, query *fleet.Query, opts ...fleet.OptionalArg) (*fleet.Query, error) {
+		query.ID = 42
+		return query, nil
+	}
+	ds.NewDistributedQueryCampaignFunc = func(ctx context.Context, camp *fleet.DistributedQueryCampaign) (*fleet.DistributedQueryCampaign, error) {
+		camp.ID = 321
+		return camp, nil
+	}
+	ds.NewDistributedQueryCampaignTargetFunc = func(ctx context.Context, target *fleet.DistributedQueryCampaignTarget) (*fleet.DistributedQueryCampaignTarget, error) {
+		return target, nil
+	}
+	ds.HostIDsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets) ([]uint, error) {
+		return []uint{1}, nil
+	}
+	ds.CountHostsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets, now time.Time) (fleet.TargetMetrics, error) {
+		return fleet.TargetMetrics{TotalHosts: 1, OnlineHosts: 1}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.DistributedQueryCampaignTargetIDsFunc = func(ctx context.Context, id uint) (targets *fleet.HostTargets, err error) {
+		return &fleet.HostTargets{HostIDs: []uint{99}}, nil
+	}
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		return &fleet.DistributedQueryCampaign{
+			ID:     321,
+			UserID: admin.ID,
+		}, nil
+	}
+	ds.SaveDistributedQueryCampaignFunc = func(ctx context.Context, camp *fleet.DistributedQueryCampaign) error {
+		return nil
+	}
+	ds.QueryFunc = func(ctx context.Context, id uint) (*fleet.Query, error) {
+		return &fleet.Query{}, nil
+	}
+
+	lq.On("RunQuery", "321", "select version from osquery_info", []uint{1}).Return(nil)
+
+	go func() {
+		time.Sleep(2 * time.Second)
+		require.NoError(t, rs.WriteResult(
+			fleet.DistributedQueryResult{
+				DistributedQueryCampaignID: 321,
+				Rows:                       []map[string]string{{"version": "5.4.0"}},
+				Host: fleet.Host{
+					ID:       99,
+					Hostname: "somehostname",
+				},
+			},
+		))
+	}()
+
+	// only the aggregate is written, not the rows of the host
+	expected := `{"rows":[{"count":"1","host_count":"1","version":"5.4.0"}],"host_count":1,"error_count":0,"row_count":1,"truncated":false}
+`
+	assert.Equal(t, expected, runAppForTest(t, []string{
+		"query", "--hosts", "1234", "--query", "select version from osquery_info", "--group-by", "version", "--aggregate", "count",
+	}))
+
+	runAppCheckErr(t, []string{"query", "--hosts", "1234", "--query", "select 1", "--aggregate", "max(version"},
+		`invalid aggregate "max(version", expected function(column)`)
+	runAppCheckErr(t, []string{"query", "--hosts", "1234", "--query", "select 1", "--group-by", "version", "--async"},
+		"--group-by and --aggregate must not be provided with --async")
+}
+
+func TestLiveQueryAsync(t *testing.T) {
+	lq := live_query_mock.New(t)
+	_, ds := runServerWithMockedDS(t, &service.TestServerOpts{
+		Rs:              pubsub.NewInmemQueryResults(),
+		Lq:              lq,
+		LiveQueryExport: new(mock.LiveQueryExportStore),
+	})
+
+	ds.HostIDsByNameFunc = func(ctx context.Context, filter fleet.TeamFilter, hostnames []string) ([]uint, error) {
+		return []uint{1234}, nil
+	}
+	ds.LabelIDsByNameFunc = func(ctx context.Context, labels []string) ([]uint, error) {
+		return nil, nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewQueryFunc = func(ctx context.Context, query *fleet.Query, opts ...fleet.OptionalArg) (*fleet.Query, error) {
+		query.ID = 42
+		return query, nil
+	}
+	var gotCampaign *fleet.DistributedQueryCampaign
+	ds.NewDistributedQueryCampaignFunc = func(ctx context.Context, camp *fleet.DistributedQueryCampaign) (*fleet.DistributedQueryCampaign, error) {
+		camp.ID = 321
+		gotCampaign = camp
+		return camp, nil
+	}
+	ds.NewDistributedQueryCampaignTargetFunc = func(ctx context.Context, target *fleet.DistributedQueryCampaignTarget) (*fleet.DistributedQueryCampaignTarget, error) {
+		return target, nil
+	}
+	ds.HostIDsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets) ([]uint, error) {
+		return []uint{1}, nil
+	}
+	ds.NewDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint, hostIDs []uint) error {
+		return nil
+	}
+	ds.CountHostsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets, now time.Time) (fleet.TargetMetrics, error) {
+		return fleet.TargetMetrics{TotalHosts: 1}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	lq.On("RunQueryWithExpiration", "321", "select 42, * from time", []uint{1}, 72*time.Hour).Return(nil)
+
+	assert.Equal(t, "321\n", runAppForTest(t, []string{"query", "--hosts", "1234", "--query", "select 42, * from time", "--async", "--collect-for", "72h"}))
+	lq.AssertExpectations(t)
+	require.NotNil(t, gotCampaign.CollectUntil)
+	assert.Equal(t, fleet.QueryRunning, gotCampaign.Status)
+
+	_, err := runAppNoChecks([]string{"query", "--hosts", "1234", "--query", "select 1", "--async", "--collect-for", "200h"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collect_window must be between 0 and 168h0m0s")
+
+	// the results can be exported
+	lq.On("RunQueryWithExpiration", "321", "select 42, * from time", []uint{1}, 24*time.Hour).Return(nil)
+	assert.Equal(t, "321\n", runAppForTest(t, []string{"query", "--hosts", "1234", "--query", "select 42, * from time", "--async", "--export", "csv"}))
+	lq.AssertExpectations(t)
+	assert.Equal(t, fleet.LiveQueryExportCSV, gotCampaign.ExportFormat)
+
+	_, err = runAppNoChecks([]string{"query", "--hosts", "1234", "--query", "select 1", "--async", "--export", "xml"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "export_format must be ndjson or csv")
+}
@@ -34,6 +34,7 @@ func userCommand() *cli.Command {
 		Subcommands: []*cli.Command{
 			createUserCommand(),
 			deleteUserCommand(),
+			unlockUserCommand(),
 			createBulkUsersCommand(),
 			deleteBulkUsersCommand(),
 		},
@@ -322,6 +323,34 @@ func deleteUserCommand() *cli.Command {
 	}
 }
 
+func unlockUserCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "unlock",
+		Usage:     "Unlock a user locked after too many failed logins",
+		UsageText: `This command will unlock the account of a user specified by their email in Fleet.`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:     emailFlagName,
+				Usage:    "Email for user (required)",
+				Required: true,
+			},
+			configFlag(),
+			contextFlag(),
+			yamlFlag(),
+			debugFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			client, err := clientFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			email := c.String(emailFlagName)
+			return client.UnlockUser(email)
+		},
+	}
+}
+
 func deleteBulkUsersCommand() *cli.Command {
 	return &cli.Command{
 		Name:      "delete-users",
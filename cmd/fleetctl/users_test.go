@@ -19,6 +19,9 @@ import (
 
 func TestUserDelete(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
 		return &fleet.User{
@@ -39,6 +42,40 @@ func TestUserDelete(t *testing.T) {
 	assert.Equal(t, uint(42), deletedUser)
 }
 
+func TestUserUnlock(t *testing.T) {
+	_, ds := runServerWithMockedDS(t)
+
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		return &fleet.User{
+			ID:    42,
+			Name:  "test1",
+			Email: "user1@test.com",
+		}, nil
+	}
+	userByID := ds.UserByIDFunc
+	ds.UserByIDFunc = func(ctx context.Context, id uint) (*fleet.User, error) {
+		if id != 42 {
+			// the authenticated admin
+			return userByID(ctx, id)
+		}
+		return &fleet.User{
+			ID:    42,
+			Name:  "test1",
+			Email: "user1@test.com",
+		}, nil
+	}
+	var activityDetails map[string]interface{}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		if activityType == fleet.ActivityTypeUnlockedUser {
+			activityDetails = *details
+		}
+		return nil
+	}
+
+	assert.Equal(t, "", runAppForTest(t, []string{"user", "unlock", "--email", "user1@test.com"}))
+	assert.Equal(t, map[string]interface{}{"user_id": uint(42), "user_email": "user1@test.com"}, activityDetails)
+}
+
 type notFoundError struct{}
 
 var _ fleet.NotFoundError = (*notFoundError)(nil)
@@ -56,6 +93,15 @@ func (e *notFoundError) Error() string {
 // the passed flags (e.g. SSO users shouldn't be required to do password reset on first login).
 func TestUserCreateForcePasswordReset(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewPasswordHistoryFunc = func(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+		return nil
+	}
 
 	pwd := test.GoodPassword
 
@@ -114,6 +160,15 @@ func writeTmpCsv(t *testing.T, contents string) string {
 
 func TestCreateBulkUsers(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewPasswordHistoryFunc = func(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+		return nil
+	}
 	ds.InviteByEmailFunc = func(ctx context.Context, email string) (*fleet.Invite, error) {
 		return nil, nil
 	}
@@ -137,6 +192,9 @@ func TestCreateBulkUsers(t *testing.T) {
 
 func TestDeleteBulkUsers(t *testing.T) {
 	_, ds := runServerWithMockedDS(t)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	csvFilePath := writeTmpCsv(t,
 		`Email
 	user11@example.com
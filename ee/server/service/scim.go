@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+)
+
+// checkSCIMAuthenticated returns an error if the request is not
+// authenticated with the SCIM token, the SCIM service methods must only be
+// used by the SCIM endpoints.
+func (svc *Service) checkSCIMAuthenticated(ctx context.Context) error {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		return authz.ForbiddenWithInternal("request not authenticated with the SCIM token", nil, nil, nil)
+	}
+	return nil
+}
+
+func (svc *Service) SCIMListGroups(ctx context.Context, name string) ([]*fleet.Team, error) {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		team, err := svc.ds.TeamByName(ctx, name)
+		switch {
+		case err == nil:
+			return []*fleet.Team{team}, nil
+		case fleet.IsNotFound(err):
+			return nil, nil
+		default:
+			return nil, ctxerr.Wrap(ctx, err, "get SCIM group team by name")
+		}
+	}
+
+	// the SCIM token gives access to all the teams
+	filter := fleet.TeamFilter{User: &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}}
+	teams, err := svc.ds.ListTeams(ctx, filter, fleet.ListOptions{OrderKey: "id"})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list SCIM group teams")
+	}
+	return teams, nil
+}
+
+func (svc *Service) SCIMGroup(ctx context.Context, teamID uint) (*fleet.Team, []*fleet.User, error) {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	team, err := svc.ds.Team(ctx, teamID)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "get SCIM group team")
+	}
+	members, err := svc.ds.ListUsers(ctx, fleet.UserListOptions{
+		ListOptions: fleet.ListOptions{OrderKey: "id"},
+		TeamID:      team.ID,
+	})
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "list SCIM group members")
+	}
+	// only the SSO users are managed by SCIM, the other members of the team
+	// are neither listed nor removed by the group changes.
+	ssoMembers := members[:0]
+	for _, user := range members {
+		if user.SSOEnabled {
+			ssoMembers = append(ssoMembers, user)
+		}
+	}
+	return team, ssoMembers, nil
+}
+
+func (svc *Service) SCIMNewGroup(ctx context.Context, name string) (*fleet.Team, error) {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	switch _, err := svc.ds.TeamByName(ctx, name); {
+	case err == nil:
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("displayName", fmt.Sprintf("team %q already exists", name)).WithStatus(http.StatusConflict))
+	case fleet.IsNotFound(err):
+		// OK
+	default:
+		return nil, ctxerr.Wrap(ctx, err, "get SCIM group team by name")
+	}
+
+	team, err := svc.createTeam(ctx, fleet.TeamPayload{Name: &name})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create SCIM group team")
+	}
+	return team, nil
+}
+
+func (svc *Service) SCIMSetGroupMembers(ctx context.Context, teamID uint, userIDs []uint) error {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return err
+	}
+
+	team, members, err := svc.SCIMGroup(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[uint]bool, len(userIDs))
+	for _, id := range userIDs {
+		keep[id] = true
+	}
+	isMember := make(map[uint]bool, len(members))
+
+	var changed []*fleet.User
+	var deactivated []uint
+	for _, user := range members {
+		isMember[user.ID] = true
+		if !keep[user.ID] {
+			if removeTeamFromUser(user, team.ID) {
+				deactivated = append(deactivated, user.ID)
+			}
+			changed = append(changed, user)
+		}
+	}
+	for _, id := range userIDs {
+		if isMember[id] {
+			continue
+		}
+		isMember[id] = true
+
+		user, err := svc.ds.UserByID(ctx, id)
+		switch {
+		case err == nil && !user.SSOEnabled:
+			// users that are not managed by SCIM can't be added to a group
+			return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("members", fmt.Sprintf("user %d not found", id)))
+		case fleet.IsNotFound(err):
+			return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("members", fmt.Sprintf("user %d not found", id)))
+		case err != nil:
+			return ctxerr.Wrap(ctx, err, "get SCIM group member")
+		}
+		if addTeamToUser(user, *team) {
+			changed = append(changed, user)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := svc.ds.SaveUsers(ctx, changed); err != nil {
+		return ctxerr.Wrap(ctx, err, "save SCIM group members")
+	}
+	for _, id := range deactivated {
+		if err := svc.DeleteSessionsForUser(ctx, id); err != nil {
+			return ctxerr.Wrap(ctx, err, "delete sessions of deactivated SCIM group member")
+		}
+	}
+	return nil
+}
+
+// addTeamToUser gives the user the observer role in the team. Global
+// observers lose their global role, while global admins and maintainers are
+// left untouched as they already have access to all the teams. It returns
+// true if the user was modified.
+func addTeamToUser(user *fleet.User, team fleet.Team) bool {
+	if user.GlobalRole != nil {
+		if *user.GlobalRole != fleet.RoleObserver {
+			return false
+		}
+		user.GlobalRole = nil
+	}
+	user.Teams = append(user.Teams, fleet.UserTeam{Team: team, Role: fleet.RoleObserver})
+	return true
+}
+
+// removeTeamFromUser removes the role of the user in the team. Users without
+// any role left are deactivated, as they must not keep any access to Fleet,
+// their sessions must be deleted. It returns true if the user was
+// deactivated.
+func removeTeamFromUser(user *fleet.User, teamID uint) bool {
+	teams := make([]fleet.UserTeam, 0, len(user.Teams))
+	for _, t := range user.Teams {
+		if t.ID != teamID {
+			teams = append(teams, t)
+		}
+	}
+	user.Teams = teams
+	if len(teams) > 0 || user.GlobalRole != nil || user.Disabled {
+		return false
+	}
+	user.Disabled = true
+	return true
+}
@@ -35,6 +35,7 @@ func NewService(
 	if err != nil {
 		return nil, fmt.Errorf("new authorizer: %w", err)
 	}
+	authorizer.SetCustomRolesLoader(ds.ListCustomRoles)
 
 	return &Service{
 		Service: svc,
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/fleetdm/fleet/v4/server"
 	"github.com/fleetdm/fleet/v4/server/authz"
@@ -20,6 +21,39 @@ func (svc *Service) NewTeam(ctx context.Context, p fleet.TeamPayload) (*fleet.Te
 		return nil, err
 	}
 
+	team, err := svc.createTeam(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeCreatedTeam,
+		&map[string]interface{}{"team_id": team.ID, "team_name": team.Name},
+	); err != nil {
+		return nil, err
+	}
+
+	obfuscateTeam(ctx, team)
+	return team, nil
+}
+
+// obfuscateTeam masks the secrets of the team config, it must be called on
+// every team returned by the service. Like in the app config, only the global
+// admins can see the URLs of the Slack and Microsoft Teams webhooks.
+func obfuscateTeam(ctx context.Context, team *fleet.Team) {
+	team.Config.WebhookSettings.MaskSecrets()
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok || vc.User == nil || vc.User.GlobalRole == nil || *vc.User.GlobalRole != fleet.RoleAdmin {
+		team.Config.Integrations.MaskURLs()
+	}
+}
+
+// createTeam creates the team with the agent options of the global config
+// and a default enroll secret if none is provided.
+func (svc *Service) createTeam(ctx context.Context, p fleet.TeamPayload) (*fleet.Team, error) {
 	// Copy team options from global options
 	globalConfig, err := svc.ds.AppConfig(ctx)
 	if err != nil {
@@ -54,21 +88,7 @@ func (svc *Service) NewTeam(ctx context.Context, p fleet.TeamPayload) (*fleet.Te
 		team.Secrets = []*fleet.EnrollSecret{{Secret: secret}}
 	}
 
-	team, err = svc.ds.NewTeam(ctx, team)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := svc.ds.NewActivity(
-		ctx,
-		authz.UserFromContext(ctx),
-		fleet.ActivityTypeCreatedTeam,
-		&map[string]interface{}{"team_id": team.ID, "team_name": team.Name},
-	); err != nil {
-		return nil, err
-	}
-
-	return team, nil
+	return svc.ds.NewTeam(ctx, team)
 }
 
 func (svc *Service) ModifyTeam(ctx context.Context, teamID uint, payload fleet.TeamPayload) (*fleet.Team, error) {
@@ -91,10 +111,14 @@ func (svc *Service) ModifyTeam(ctx context.Context, teamID uint, payload fleet.T
 	}
 
 	if payload.WebhookSettings != nil {
-		team.Config.WebhookSettings = *payload.WebhookSettings
+		team.Config.WebhookSettings.Apply(*payload.WebhookSettings)
 	}
 
 	if payload.Integrations != nil {
+		// the webhook URLs are masked for the non-admins, applying back the
+		// masked URLs keeps the stored ones.
+		payload.Integrations.RestoreMaskedURLs(team.Config.Integrations)
+
 		// the team integrations must reference an existing global config integration.
 		appCfg, err := svc.ds.AppConfig(ctx)
 		if err != nil {
@@ -111,6 +135,9 @@ func (svc *Service) ModifyTeam(ctx context.Context, teamID uint, payload fleet.T
 
 		team.Config.Integrations.Jira = payload.Integrations.Jira
 		team.Config.Integrations.Zendesk = payload.Integrations.Zendesk
+		team.Config.Integrations.Webhook = payload.Integrations.Webhook
+		team.Config.Integrations.Slack = payload.Integrations.Slack
+		team.Config.Integrations.MicrosoftTeams = payload.Integrations.MicrosoftTeams
 	}
 
 	if payload.WebhookSettings != nil || payload.Integrations != nil {
@@ -123,12 +150,22 @@ func (svc *Service) ModifyTeam(ctx context.Context, teamID uint, payload fleet.T
 			team.Config.Integrations,
 			invalid,
 		)
+		fleet.ValidateEnabledVulnerabilitiesTeamIntegrations(
+			team.Config.WebhookSettings.VulnerabilitiesWebhook,
+			team.Config.Integrations,
+			invalid,
+		)
 		if invalid.HasErrors() {
 			return nil, ctxerr.Wrap(ctx, invalid)
 		}
 	}
 
-	return svc.ds.SaveTeam(ctx, team)
+	team, err = svc.ds.SaveTeam(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	obfuscateTeam(ctx, team)
+	return team, nil
 }
 
 func (svc *Service) ModifyTeamAgentOptions(ctx context.Context, teamID uint, options json.RawMessage) (*fleet.Team, error) {
@@ -161,19 +198,31 @@ func (svc *Service) ModifyTeamAgentOptions(ctx context.Context, teamID uint, opt
 		return nil, ctxerr.Wrap(ctx, err, "create edited agent options activity")
 	}
 
+	obfuscateTeam(ctx, tm)
 	return tm, nil
 }
 
 func (svc *Service) AddTeamUsers(ctx context.Context, teamID uint, users []fleet.TeamUser) (*fleet.Team, error) {
-	if err := svc.authz.Authorize(ctx, &fleet.Team{ID: teamID}, fleet.ActionWrite); err != nil {
+	if err := svc.authz.Authorize(ctx, &fleet.Team{ID: teamID}, fleet.ActionWriteRole); err != nil {
 		return nil, err
 	}
 
 	currentUser := authz.UserFromContext(ctx)
 
+	var customRoles map[string]bool
 	idMap := make(map[uint]fleet.TeamUser)
 	for _, user := range users {
-		if !fleet.ValidTeamRole(user.Role) {
+		if fleet.IsCustomRole(user.Role) && customRoles == nil {
+			roles, err := svc.ds.ListCustomRoles(ctx)
+			if err != nil {
+				return nil, ctxerr.Wrap(ctx, err, "list custom roles")
+			}
+			customRoles = make(map[string]bool, len(roles))
+			for _, role := range roles {
+				customRoles[role.Name] = true
+			}
+		}
+		if !fleet.ValidTeamRole(user.Role) && !customRoles[user.Role] {
 			return nil, fleet.NewInvalidArgumentError("users", fmt.Sprintf("%s is not a valid role for a team user", user.Role))
 		}
 		idMap[user.ID] = user
@@ -190,6 +239,7 @@ func (svc *Service) AddTeamUsers(ctx context.Context, teamID uint, users []fleet
 	if err != nil {
 		return nil, err
 	}
+	before := teamUserRoles(team.Users)
 
 	// Replace existing
 	for i, existingUser := range team.Users {
@@ -206,11 +256,19 @@ func (svc *Service) AddTeamUsers(ctx context.Context, teamID uint, users []fleet
 
 	logging.WithExtras(ctx, "users", team.Users)
 
-	return svc.ds.SaveTeam(ctx, team)
+	team, err = svc.ds.SaveTeam(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.newEditedTeamUsersActivity(ctx, team, before); err != nil {
+		return nil, err
+	}
+	obfuscateTeam(ctx, team)
+	return team, nil
 }
 
 func (svc *Service) DeleteTeamUsers(ctx context.Context, teamID uint, users []fleet.TeamUser) (*fleet.Team, error) {
-	if err := svc.authz.Authorize(ctx, &fleet.Team{ID: teamID}, fleet.ActionWrite); err != nil {
+	if err := svc.authz.Authorize(ctx, &fleet.Team{ID: teamID}, fleet.ActionWriteRole); err != nil {
 		return nil, err
 	}
 
@@ -223,6 +281,7 @@ func (svc *Service) DeleteTeamUsers(ctx context.Context, teamID uint, users []fl
 	if err != nil {
 		return nil, err
 	}
+	before := teamUserRoles(team.Users)
 
 	newUsers := []fleet.TeamUser{}
 	// Delete existing
@@ -236,7 +295,65 @@ func (svc *Service) DeleteTeamUsers(ctx context.Context, teamID uint, users []fl
 
 	logging.WithExtras(ctx, "users", team.Users)
 
-	return svc.ds.SaveTeam(ctx, team)
+	team, err = svc.ds.SaveTeam(ctx, team)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.newEditedTeamUsersActivity(ctx, team, before); err != nil {
+		return nil, err
+	}
+	obfuscateTeam(ctx, team)
+	return team, nil
+}
+
+// teamUserRoles maps the IDs of the team users to their role in the team.
+func teamUserRoles(users []fleet.TeamUser) map[string]string {
+	roles := make(map[string]string, len(users))
+	for _, user := range users {
+		roles[strconv.FormatUint(uint64(user.ID), 10)] = user.Role
+	}
+	return roles
+}
+
+// newEditedTeamUsersActivity records the roles of the team users that changed,
+// a nil role means that the user was not (or is not anymore) in the team.
+func (svc *Service) newEditedTeamUsersActivity(ctx context.Context, team *fleet.Team, before map[string]string) error {
+	after := teamUserRoles(team.Users)
+
+	changedBefore, changedAfter := make(map[string]*string), make(map[string]*string)
+	for id, role := range before {
+		if after[id] != role {
+			changedBefore[id] = ptr.String(role)
+			changedAfter[id] = nil
+			if newRole, ok := after[id]; ok {
+				changedAfter[id] = ptr.String(newRole)
+			}
+		}
+	}
+	for id, role := range after {
+		if _, ok := before[id]; !ok {
+			changedBefore[id] = nil
+			changedAfter[id] = ptr.String(role)
+		}
+	}
+	if len(changedAfter) == 0 {
+		return nil
+	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeEditedTeamUsers,
+		&map[string]interface{}{
+			"team_id":   team.ID,
+			"team_name": team.Name,
+			"before":    map[string]interface{}{"users": changedBefore},
+			"after":     map[string]interface{}{"users": changedAfter},
+		},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create edited team users activity")
+	}
+	return nil
 }
 
 func (svc *Service) ListTeamUsers(ctx context.Context, teamID uint, opt fleet.ListOptions) ([]*fleet.User, error) {
@@ -263,7 +380,14 @@ func (svc *Service) ListTeams(ctx context.Context, opt fleet.ListOptions) ([]*fl
 	}
 	filter := fleet.TeamFilter{User: vc.User, IncludeObserver: true}
 
-	return svc.ds.ListTeams(ctx, filter, opt)
+	teams, err := svc.ds.ListTeams(ctx, filter, opt)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		obfuscateTeam(ctx, team)
+	}
+	return teams, nil
 }
 
 func (svc *Service) ListAvailableTeamsForUser(ctx context.Context, user *fleet.User) ([]*fleet.TeamSummary, error) {
@@ -320,7 +444,12 @@ func (svc *Service) GetTeam(ctx context.Context, teamID uint) (*fleet.Team, erro
 
 	logging.WithExtras(ctx, "id", teamID)
 
-	return svc.ds.Team(ctx, teamID)
+	team, err := svc.ds.Team(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	obfuscateTeam(ctx, team)
+	return team, nil
 }
 
 func (svc *Service) TeamEnrollSecrets(ctx context.Context, teamID uint) ([]*fleet.EnrollSecret, error) {
@@ -349,6 +478,16 @@ func (svc *Service) ModifyTeamEnrollSecrets(ctx context.Context, teamID uint, se
 		return nil, err
 	}
 
+	// the secrets themselves are never recorded
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeEditedEnrollSecrets,
+		&map[string]interface{}{"global": false, "team_id": teamID, "secrets_count": len(newSecrets)},
+	); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create edited enroll secrets activity")
+	}
+
 	return newSecrets, nil
 }
 
@@ -404,11 +543,15 @@ func (svc Service) ApplyTeamSpecs(ctx context.Context, specs []*fleet.TeamSpec)
 				if agentOptions == nil {
 					agentOptions = config.AgentOptions
 				}
+				teamConfig := fleet.TeamConfig{
+					AgentOptions: agentOptions,
+				}
+				if spec.WebhookSettings != nil {
+					teamConfig.WebhookSettings.Apply(*spec.WebhookSettings)
+				}
 				tm, err := svc.ds.NewTeam(ctx, &fleet.Team{
-					Name: spec.Name,
-					Config: fleet.TeamConfig{
-						AgentOptions: agentOptions,
-					},
+					Name:    spec.Name,
+					Config:  teamConfig,
 					Secrets: secrets,
 				})
 				if err != nil {
@@ -429,6 +572,24 @@ func (svc Service) ApplyTeamSpecs(ctx context.Context, specs []*fleet.TeamSpec)
 		if len(secrets) > 0 {
 			team.Secrets = secrets
 		}
+		if spec.WebhookSettings != nil {
+			team.Config.WebhookSettings.Apply(*spec.WebhookSettings)
+
+			invalid := &fleet.InvalidArgumentError{}
+			fleet.ValidateEnabledFailingPoliciesTeamIntegrations(
+				team.Config.WebhookSettings.FailingPoliciesWebhook,
+				team.Config.Integrations,
+				invalid,
+			)
+			fleet.ValidateEnabledVulnerabilitiesTeamIntegrations(
+				team.Config.WebhookSettings.VulnerabilitiesWebhook,
+				team.Config.Integrations,
+				invalid,
+			)
+			if invalid.HasErrors() {
+				return ctxerr.Wrap(ctx, invalid)
+			}
+		}
 
 		_, err = svc.ds.SaveTeam(ctx, team)
 		if err != nil {
@@ -3,15 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"sort"
 
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/go-kit/kit/log/level"
 )
 
 // GetSSOUser is the premium implementation of svc.GetSSOUser, it allows to
 // create users during the SSO flow the first time they log in if
-// config.SSOSettings.EnableJITProvisioning is `true`
+// config.SSOSettings.EnableJITProvisioning is `true`, and to set the roles of
+// the users from their groups if config.SSOSettings.RoleMappings are set.
 func (svc *Service) GetSSOUser(ctx context.Context, auth fleet.Auth) (*fleet.User, error) {
 	config, err := svc.ds.AppConfig(ctx)
 	if err != nil {
@@ -26,6 +29,11 @@ func (svc *Service) GetSSOUser(ctx context.Context, auth fleet.Auth) (*fleet.Use
 	var nfe fleet.NotFoundError
 	switch {
 	case err == nil:
+		if user.SSOEnabled && len(config.SSOSettings.RoleMappings) > 0 {
+			if err := svc.updateSSOUserRoles(ctx, config, auth, user); err != nil {
+				return nil, err
+			}
+		}
 		return user, nil
 	case errors.As(err, &nfe):
 		if !config.SSOSettings.EnableJITProvisioning {
@@ -40,12 +48,31 @@ func (svc *Service) GetSSOUser(ctx context.Context, auth fleet.Auth) (*fleet.Use
 		displayName = auth.UserID()
 	}
 
-	user, err = svc.Service.NewUser(ctx, fleet.UserPayload{
+	payload := fleet.UserPayload{
 		Name:       &displayName,
 		Email:      ptr.String(auth.UserID()),
 		SSOEnabled: ptr.Bool(true),
 		GlobalRole: ptr.String(fleet.RoleObserver),
-	})
+	}
+	if len(config.SSOSettings.RoleMappings) > 0 {
+		globalRole, teams, err := svc.ssoUserRoles(ctx, config, auth)
+		if err != nil {
+			return nil, err
+		}
+		// unmapped users are created as global observers, unless rejected
+		if globalRole == nil && len(teams) == 0 {
+			if config.SSOSettings.RejectUnmappedUsers {
+				return nil, ctxerr.Wrap(ctx, errUnmappedSSOUser, "SSO role mapping")
+			}
+			globalRole = ptr.String(fleet.RoleObserver)
+		}
+		payload.GlobalRole = globalRole
+		if len(teams) > 0 {
+			payload.Teams = &teams
+		}
+	}
+
+	user, err = svc.Service.NewUser(ctx, payload)
 	if err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "creating new SSO user")
 	}
@@ -61,3 +88,119 @@ func (svc *Service) GetSSOUser(ctx context.Context, auth fleet.Auth) (*fleet.Use
 
 	return user, nil
 }
+
+// errUnmappedSSOUser is returned when the groups of an SSO user don't match
+// any role mapping and the user can't log in.
+var errUnmappedSSOUser = fleet.NewPermissionError("user groups are not mapped to a Fleet role")
+
+// ssoUserRoles returns the roles given to the user by the role mappings of
+// the SSO settings, from the groups in the SSO response. It returns no role
+// if the user doesn't match any mapping.
+func (svc *Service) ssoUserRoles(ctx context.Context, config *fleet.AppConfig, auth fleet.Auth) (*string, []fleet.UserTeam, error) {
+	groups := auth.UserGroups(config.SSOSettings.GroupsAttribute)
+	globalRole, teamRoles, ok := config.SSOSettings.MappedRoles(groups)
+	if globalRole != "" {
+		return &globalRole, nil, nil
+	}
+
+	var teams []fleet.UserTeam
+	if ok {
+		// sort the team names so that the teams are saved in a stable order
+		names := make([]string, 0, len(teamRoles))
+		for name := range teamRoles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			team, err := svc.ds.TeamByName(ctx, name)
+			if err != nil {
+				if fleet.IsNotFound(err) {
+					level.Info(svc.logger).Log("msg", "SSO role mapping team not found", "team", name)
+					continue
+				}
+				return nil, nil, ctxerr.Wrap(ctx, err, "get SSO role mapping team")
+			}
+			teams = append(teams, fleet.UserTeam{Team: *team, Role: teamRoles[name]})
+		}
+	}
+	return nil, teams, nil
+}
+
+// updateSSOUserRoles sets the roles of an existing user from the groups in
+// the SSO response, so that changes of the groups in the IDP are applied
+// every time the user logs in.
+//
+// A user that doesn't match any mapping anymore was removed from the mapped
+// groups: their sessions are deleted and the login is rejected. Their roles
+// are left as is rather than saving a user without any role, they are
+// replaced as soon as the user matches a mapping again. Only the global
+// observers, the default role of the unmapped users created by JIT
+// provisioning, can still log in if unmapped users are not rejected.
+func (svc *Service) updateSSOUserRoles(ctx context.Context, config *fleet.AppConfig, auth fleet.Auth, user *fleet.User) error {
+	globalRole, teams, err := svc.ssoUserRoles(ctx, config, auth)
+	if err != nil {
+		return err
+	}
+	if globalRole == nil && len(teams) == 0 {
+		if !config.SSOSettings.RejectUnmappedUsers && sameUserRoles(user, ptr.String(fleet.RoleObserver), nil) {
+			return nil
+		}
+		if err := svc.ds.DestroyAllSessionsForUser(ctx, user.ID); err != nil {
+			return ctxerr.Wrap(ctx, err, "deleting sessions of unmapped SSO user")
+		}
+		return ctxerr.Wrap(ctx, errUnmappedSSOUser, "SSO role mapping")
+	}
+	if sameUserRoles(user, globalRole, teams) {
+		return nil
+	}
+
+	user.GlobalRole = globalRole
+	user.Teams = teams
+	if err := svc.ds.SaveUser(ctx, user); err != nil {
+		return ctxerr.Wrap(ctx, err, "saving SSO user roles")
+	}
+
+	teamRoles := make([]map[string]interface{}, 0, len(teams))
+	for _, t := range teams {
+		teamRoles = append(teamRoles, map[string]interface{}{
+			"team_id":   t.ID,
+			"team_name": t.Name,
+			"role":      t.Role,
+		})
+	}
+	if err := svc.ds.NewActivity(
+		ctx,
+		user,
+		fleet.ActivityTypeUserRolesChangedBySSO,
+		&map[string]interface{}{
+			"global_role": globalRole,
+			"teams":       teamRoles,
+		},
+	); err != nil {
+		return err
+	}
+	return nil
+}
+
+func sameUserRoles(user *fleet.User, globalRole *string, teams []fleet.UserTeam) bool {
+	if (user.GlobalRole == nil) != (globalRole == nil) {
+		return false
+	}
+	if globalRole != nil && *user.GlobalRole != *globalRole {
+		return false
+	}
+	if len(user.Teams) != len(teams) {
+		return false
+	}
+	roles := make(map[uint]string, len(user.Teams))
+	for _, t := range user.Teams {
+		roles[t.ID] = t.Role
+	}
+	for _, t := range teams {
+		if role, ok := roles[t.ID]; !ok || role != t.Role {
+			return false
+		}
+	}
+	return true
+}
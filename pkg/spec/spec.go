@@ -27,6 +27,7 @@ type Group struct {
 	AppConfig    interface{}
 	EnrollSecret *fleet.EnrollSecretSpec
 	UsersRoles   *fleet.UsersRoleSpec
+	CustomRoles  []*fleet.CustomRoleSpec
 }
 
 // Metadata holds the metadata for a single YAML section/item.
@@ -114,6 +115,13 @@ func GroupFromBytes(b []byte) (*Group, error) {
 			}
 			specs.UsersRoles = userRoleSpec
 
+		case fleet.CustomRoleKind:
+			var customRoleSpec *fleet.CustomRoleSpec
+			if err := yaml.Unmarshal(s.Spec, &customRoleSpec); err != nil {
+				return nil, fmt.Errorf("unmarshaling %s spec: %w", kind, err)
+			}
+			specs.CustomRoles = append(specs.CustomRoles, customRoleSpec)
+
 		case fleet.TeamKind:
 			var teamSpec TeamSpec
 			if err := yaml.Unmarshal(s.Spec, &teamSpec); err != nil {
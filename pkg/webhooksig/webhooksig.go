@@ -0,0 +1,132 @@
+// Package webhooksig signs and verifies the payloads of the webhook requests
+// sent by Fleet.
+//
+// When a secret is configured for a webhook, Fleet sets two headers on the
+// request:
+//
+//	X-Fleet-Timestamp: the time at which the request was signed, as a Unix
+//	timestamp in seconds.
+//	X-Fleet-Signature: "sha256=" followed by the hex-encoded HMAC-SHA256 of
+//	the timestamp, a "." and the request body, using the secret as key.
+//
+// Receivers should verify the signature and reject requests with a timestamp
+// too far from the current time to prevent replay attacks. Receivers written
+// in Go can use Verify or VerifyRequest to do so.
+package webhooksig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader is the name of the HTTP header that holds the signature
+	// of the request.
+	SignatureHeader = "X-Fleet-Signature"
+	// TimestampHeader is the name of the HTTP header that holds the Unix
+	// timestamp (in seconds) at which the request was signed.
+	TimestampHeader = "X-Fleet-Timestamp"
+
+	// DefaultTolerance is the recommended maximum difference between the
+	// signature timestamp and the current time.
+	DefaultTolerance = 5 * time.Minute
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	// ErrMissingHeaders is returned when the signature or timestamp header is
+	// missing from the request.
+	ErrMissingHeaders = errors.New("missing webhook signature headers")
+	// ErrInvalidSignature is returned when the signature does not match the
+	// payload.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrTimestampOutOfTolerance is returned when the signature timestamp is
+	// too far from the current time.
+	ErrTimestampOutOfTolerance = errors.New("webhook timestamp outside of tolerance")
+)
+
+// Sign returns the value of the signature header for the body signed at the
+// provided time using the secret.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(computeMAC(secret, timestamp.Unix(), body))
+}
+
+// SetHeaders signs the body at the provided time and sets the signature and
+// timestamp headers.
+func SetHeaders(h http.Header, secret string, timestamp time.Time, body []byte) {
+	h.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	h.Set(SignatureHeader, Sign(secret, timestamp, body))
+}
+
+// Verify checks that the signature in the headers is valid for the body and
+// secret, and that its timestamp is within tolerance of the current time. A
+// tolerance <= 0 disables the timestamp check.
+func Verify(secret string, h http.Header, body []byte, tolerance time.Duration) error {
+	return verify(secret, h, body, tolerance, time.Now())
+}
+
+// VerifyRequest reads the body of the request and verifies its signature as
+// for Verify. It returns the body, which cannot be read again from the
+// request.
+func VerifyRequest(r *http.Request, secret string, tolerance time.Duration) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err := Verify(secret, r.Header, body, tolerance); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func verify(secret string, h http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	rawTs, rawSig := h.Get(TimestampHeader), h.Get(SignatureHeader)
+	if rawTs == "" || rawSig == "" {
+		return ErrMissingHeaders
+	}
+
+	ts, err := strconv.ParseInt(rawTs, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s header: %w", TimestampHeader, err)
+	}
+	if tolerance > 0 {
+		diff := now.Sub(time.Unix(ts, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			return ErrTimestampOutOfTolerance
+		}
+	}
+
+	if !strings.HasPrefix(rawSig, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(rawSig, signaturePrefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal(sig, computeMAC(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeMAC(secret string, ts int64, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
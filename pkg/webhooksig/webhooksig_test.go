@@ -0,0 +1,76 @@
+package webhooksig
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"text":"hello"}`)
+	now := time.Now()
+
+	h := make(http.Header)
+	SetHeaders(h, "s3cr3t", now, body)
+	require.Equal(t, strconv.FormatInt(now.Unix(), 10), h.Get(TimestampHeader))
+	require.True(t, strings.HasPrefix(h.Get(SignatureHeader), "sha256="))
+	require.Equal(t, Sign("s3cr3t", now, body), h.Get(SignatureHeader))
+
+	cases := []struct {
+		desc      string
+		secret    string
+		header    func() http.Header
+		body      []byte
+		tolerance time.Duration
+		now       time.Time
+		wantErr   error
+	}{
+		{"valid", "s3cr3t", func() http.Header { return h }, body, DefaultTolerance, now, nil},
+		{"wrong secret", "nope", func() http.Header { return h }, body, DefaultTolerance, now, ErrInvalidSignature},
+		{"tampered body", "s3cr3t", func() http.Header { return h }, []byte(`{"text":"bye"}`), DefaultTolerance, now, ErrInvalidSignature},
+		{"replayed too late", "s3cr3t", func() http.Header { return h }, body, DefaultTolerance, now.Add(time.Hour), ErrTimestampOutOfTolerance},
+		{"from the future", "s3cr3t", func() http.Header { return h }, body, DefaultTolerance, now.Add(-time.Hour), ErrTimestampOutOfTolerance},
+		{"no tolerance check", "s3cr3t", func() http.Header { return h }, body, 0, now.Add(time.Hour), nil},
+		{"missing headers", "s3cr3t", func() http.Header { return make(http.Header) }, body, DefaultTolerance, now, ErrMissingHeaders},
+		{"altered timestamp", "s3cr3t", func() http.Header {
+			hh := h.Clone()
+			hh.Set(TimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+			return hh
+		}, body, DefaultTolerance, now, ErrInvalidSignature},
+		{"bad signature prefix", "s3cr3t", func() http.Header {
+			hh := h.Clone()
+			hh.Set(SignatureHeader, strings.TrimPrefix(h.Get(SignatureHeader), "sha256="))
+			return hh
+		}, body, DefaultTolerance, now, ErrInvalidSignature},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := verify(c.secret, c.header(), c.body, c.tolerance, c.now)
+			if c.wantErr == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"text":"hello"}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	SetHeaders(req.Header, "s3cr3t", time.Now(), []byte(body))
+
+	got, err := VerifyRequest(req, "s3cr3t", DefaultTolerance)
+	require.NoError(t, err)
+	require.Equal(t, body, string(got))
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	SetHeaders(req.Header, "other", time.Now(), []byte(body))
+	_, err = VerifyRequest(req, "s3cr3t", DefaultTolerance)
+	require.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -13,16 +13,35 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
 )
 
+// customRolesTTL is the maximum time the custom roles are cached by the
+// Authorizer before being reloaded, so that the changes made through other
+// Fleet instances are picked up.
+const customRolesTTL = 30 * time.Second
+
+// CustomRolesLoader loads all the custom roles.
+type CustomRolesLoader func(ctx context.Context) ([]*fleet.CustomRole, error)
+
 // Authorizer stores the compiled policy and performs authorization checks.
 type Authorizer struct {
 	query rego.PreparedEvalQuery
+	// store holds the data the policy is evaluated with, i.e. the custom
+	// roles, available to the policy as data.custom_roles.
+	store storage.Store
+
+	customRolesMu       sync.Mutex
+	customRolesLoader   CustomRolesLoader
+	customRolesLoadedAt time.Time
 }
 
 // Load the policy from policy.rego in this directory.
@@ -33,15 +52,19 @@ var policy string
 // policy.rego.
 func NewAuthorizer() (*Authorizer, error) {
 	ctx := context.Background()
+	store := inmem.NewFromObject(map[string]interface{}{
+		"custom_roles": map[string]interface{}{},
+	})
 	query, err := rego.New(
 		rego.Query("allowed = data.authz.allow"),
 		rego.Module("policy.rego", policy),
+		rego.Store(store),
 	).PrepareForEval(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("prepare query: %w", err)
 	}
 
-	return &Authorizer{query: query}, nil
+	return &Authorizer{query: query, store: store}, nil
 }
 
 // Must returns a new authorizer, or panics if there is an error.
@@ -53,6 +76,63 @@ func Must() *Authorizer {
 	return auth
 }
 
+// SetCustomRolesLoader sets the function used to load the custom roles. The
+// custom roles are loaded when authorizing a user that has a custom role, and
+// are then cached for a short time.
+func (a *Authorizer) SetCustomRolesLoader(loader CustomRolesLoader) {
+	a.customRolesMu.Lock()
+	defer a.customRolesMu.Unlock()
+
+	a.customRolesLoader = loader
+	a.customRolesLoadedAt = time.Time{}
+}
+
+// ReloadCustomRoles reloads the custom roles with the loader, if any, without
+// waiting for the cached ones to expire. It must be called after the custom
+// roles are modified.
+func (a *Authorizer) ReloadCustomRoles(ctx context.Context) error {
+	return a.loadCustomRoles(ctx, true)
+}
+
+func (a *Authorizer) loadCustomRoles(ctx context.Context, force bool) error {
+	a.customRolesMu.Lock()
+	defer a.customRolesMu.Unlock()
+
+	if a.customRolesLoader == nil {
+		return nil
+	}
+	if !force && time.Since(a.customRolesLoadedAt) < customRolesTTL {
+		return nil
+	}
+
+	roles, err := a.customRolesLoader(ctx)
+	if err != nil {
+		return fmt.Errorf("load custom roles: %w", err)
+	}
+	if err := a.SetCustomRoles(ctx, roles); err != nil {
+		return err
+	}
+	a.customRolesLoadedAt = time.Now()
+	return nil
+}
+
+// SetCustomRoles replaces the custom roles the policy is evaluated with. They
+// are keyed by name in data.custom_roles.
+func (a *Authorizer) SetCustomRoles(ctx context.Context, roles []*fleet.CustomRole) error {
+	data := make(map[string]interface{}, len(roles))
+	for _, role := range roles {
+		roleInterface, err := jsonToInterface(role)
+		if err != nil {
+			return fmt.Errorf("custom role to interface: %w", err)
+		}
+		data[role.Name] = roleInterface
+	}
+	if err := storage.WriteOne(ctx, a.store, storage.ReplaceOp, storage.MustParsePath("/custom_roles"), data); err != nil {
+		return fmt.Errorf("write custom roles: %w", err)
+	}
+	return nil
+}
+
 // SkipAuthorization must be used by service methods that do not need an
 // authorization check.
 //
@@ -110,6 +190,24 @@ func (a *Authorizer) Authorize(ctx context.Context, object, action interface{})
 		return ForbiddenWithInternal("object to interface: "+err.Error(), subject, object, action)
 	}
 
+	// The custom roles are only needed by the policy for the users that have
+	// one.
+	if subject.HasCustomRole() {
+		if err := a.loadCustomRoles(ctx, false); err != nil {
+			return ForbiddenWithInternal(err.Error(), subject, object, action)
+		}
+	}
+
+	// Requests authenticated with an API token are only allowed if both the
+	// scopes of the token and the role of the user allow it (the user of the
+	// viewer is already restricted to the team of the token, if any).
+	if token := apiTokenFromContext(ctx); token != nil {
+		actionString, _ := action.(string)
+		if !apiTokenAllows(token, subject, objectInterface, actionString) {
+			return ForbiddenWithInternal("API token scopes disallow request", subject, object, action)
+		}
+	}
+
 	// Perform the check via Rego.
 	input := map[string]interface{}{
 		"subject": subjectInterface,
@@ -201,3 +299,37 @@ func UserFromContext(ctx context.Context) *fleet.User {
 	}
 	return vc.User
 }
+
+// apiTokenAllows returns true if the scopes of the API token allow the action
+// on the object. Regardless of the scopes, the tokens can read their own user
+// and the list of teams (filtered by the role of the user), which are needed
+// to identify the user of the token (e.g. via the /me endpoint).
+func apiTokenAllows(token *fleet.APIToken, subject *fleet.User, object interface{}, action string) bool {
+	var objectType string
+	var objectID json.Number
+	if m, ok := object.(map[string]interface{}); ok {
+		objectType, _ = m["type"].(string)
+		objectID, _ = m["id"].(json.Number)
+	}
+
+	if action == fleet.ActionRead {
+		switch {
+		case objectType == "user" && objectID.String() == fmt.Sprint(subject.ID):
+			return true
+		case objectType == "team" && objectID.String() == "0":
+			return true
+		}
+	}
+	return token.Scopes.Allows(objectType, action)
+}
+
+// apiTokenFromContext retrieves the API token used to authenticate the
+// request from the viewer context, returning nil if the request was not
+// authenticated with an API token.
+func apiTokenFromContext(ctx context.Context) *fleet.APIToken {
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return vc.APIToken
+}
@@ -83,18 +83,18 @@ allow {
   action == read
 }
 
-# Admin can write teams
+# Admin can write teams and manage their users
 allow {
   object.type == "team"
   subject.global_role == admin
-  action == write
+  action == [write, write_role][_]
 }
 
-# Team admin can write teams
+# Team admin can write teams and manage their users
 allow {
   object.type == "team"
   team_role(subject, object.id) == admin
-  action == write
+  action == [write, write_role][_]
 }
 
 ##
@@ -166,6 +166,24 @@ allow {
 	action == [read, write][_]
 }
 
+##
+# API tokens
+##
+
+# Any user can read/write own API tokens
+allow {
+  object.type == "api_token"
+  object.user_id == subject.id
+  action == [read, write][_]
+}
+
+# Admins can read/write (revoke) the API tokens of all users
+allow {
+  object.type == "api_token"
+  subject.global_role == admin
+  action == [read, write][_]
+}
+
 ##
 # Enroll Secrets
 ##
@@ -509,4 +527,97 @@ allow {
   object.type == "software_inventory"
   team_role(subject, object.team_id) == [admin, maintainer, observer][_]
   action == read
-}
\ No newline at end of file
+}
+##
+# Webhook deliveries
+##
+
+# Only global admins can read webhook deliveries and redeliver them
+allow {
+  object.type == "webhook_delivery"
+  subject.global_role == admin
+  action == [read, write][_]
+}
+
+##
+# Custom roles
+#
+# Custom roles are defined by the admins as sets of permissions (object types
+# and actions), they are passed by the Authorizer as data.custom_roles, keyed
+# by name. The rules of the built-in roles above don't apply to them.
+##
+
+# All users can read the custom roles
+allow {
+  object.type == "custom_role"
+  not is_null(subject)
+  action == read
+}
+
+# Only global admins can write the custom roles
+allow {
+  object.type == "custom_role"
+  subject.global_role == admin
+  action == write
+}
+
+# Custom roles can't give permissions on those objects (see
+# fleet.CustomRoleSpec.Validate), even if a stored role has them.
+custom_role_excluded_objects := {"api_token", "app_config", "custom_role", "enroll_secret", "invite", "session", "user"}
+
+# Custom roles can't allow those actions, even with the "*" action.
+custom_role_excluded_actions := {write_role, change_password}
+
+# custom_role_allows is true if the custom role allows the action on the type
+# of object.
+custom_role_allows(role) {
+  not custom_role_excluded_objects[object.type]
+  not custom_role_excluded_actions[action]
+  permission := data.custom_roles[role].permissions[_]
+  permission.object == object.type
+  permission.actions[_] == [action, "*"][_]
+}
+
+# Users with a custom global role can perform the actions allowed by the role
+# on all objects.
+allow {
+  custom_role_allows(subject.global_role)
+}
+
+# Users with a custom team role can perform the actions allowed by the role on
+# the objects of the team.
+allow {
+  custom_role_allows(team_role(subject, object.team_id))
+}
+allow {
+  object.type == "team"
+  custom_role_allows(team_role(subject, object.id))
+}
+allow {
+  object.type == "pack"
+  custom_role_allows(team_role(subject, object.pack_team_id))
+}
+
+# Targeted queries are allowed if the role allows them on all the target teams.
+allow {
+  object.type == "targeted_query"
+  is_null(subject.global_role)
+
+  not is_null(object.host_targets.teams)
+  ok_teams := { tmid | tmid := object.host_targets.teams[_]; custom_role_allows(team_role(subject, tmid)) }
+  count(ok_teams) == count(object.host_targets.teams)
+}
+
+# The permissions of custom team roles on objects that don't belong to a team,
+# like queries and labels, apply regardless of the team (the targets of the
+# live queries are then filtered to the teams of the user).
+allow {
+  object.type == ["query", "label", "target"][_]
+  custom_role_allows(subject.teams[_].role)
+}
+allow {
+  object.type == "targeted_query"
+  is_null(subject.global_role)
+  is_null(object.host_targets.teams)
+  custom_role_allows(subject.teams[_].role)
+}
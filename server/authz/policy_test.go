@@ -1,10 +1,12 @@
 package authz
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
 
+	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/test"
@@ -288,12 +290,30 @@ func TestAuthorizeTeam(t *testing.T) {
 
 		{user: test.UserAdmin, object: team, action: read, allow: true},
 		{user: test.UserAdmin, object: team, action: write, allow: true},
+		{user: test.UserAdmin, object: team, action: writeRole, allow: true},
 
 		{user: test.UserMaintainer, object: team, action: read, allow: true},
 		{user: test.UserMaintainer, object: team, action: write, allow: false},
+		{user: test.UserMaintainer, object: team, action: writeRole, allow: false},
 
 		{user: test.UserObserver, object: team, action: read, allow: true},
 		{user: test.UserObserver, object: team, action: write, allow: false},
+		{user: test.UserObserver, object: team, action: writeRole, allow: false},
+	})
+
+	// team admins can write their team and manage its users
+	teamAdmin := &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: fleet.RoleAdmin}}}
+	teamMaintainer := &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: fleet.RoleMaintainer}}}
+	team1 := &fleet.Team{ID: 1}
+	team2 := &fleet.Team{ID: 2}
+	runTestCases(t, []authTestCase{
+		{user: teamAdmin, object: team1, action: write, allow: true},
+		{user: teamAdmin, object: team1, action: writeRole, allow: true},
+		{user: teamAdmin, object: team2, action: write, allow: false},
+		{user: teamAdmin, object: team2, action: writeRole, allow: false},
+
+		{user: teamMaintainer, object: team1, action: write, allow: false},
+		{user: teamMaintainer, object: team1, action: writeRole, allow: false},
 	})
 }
 
@@ -719,6 +739,28 @@ func TestAuthorizeCarves(t *testing.T) {
 	})
 }
 
+func TestAuthorizeWebhookDeliveries(t *testing.T) {
+	t.Parallel()
+
+	delivery := &fleet.WebhookDelivery{}
+	runTestCases(t, []authTestCase{
+		{user: nil, object: delivery, action: read, allow: false},
+		{user: nil, object: delivery, action: write, allow: false},
+		{user: test.UserNoRoles, object: delivery, action: read, allow: false},
+		{user: test.UserNoRoles, object: delivery, action: write, allow: false},
+		{user: test.UserMaintainer, object: delivery, action: read, allow: false},
+		{user: test.UserMaintainer, object: delivery, action: write, allow: false},
+		{user: test.UserObserver, object: delivery, action: read, allow: false},
+		{user: test.UserObserver, object: delivery, action: write, allow: false},
+		{user: test.UserTeamAdminTeam1, object: delivery, action: read, allow: false},
+		{user: test.UserTeamAdminTeam1, object: delivery, action: write, allow: false},
+
+		// Only admins allowed
+		{user: test.UserAdmin, object: delivery, action: read, allow: true},
+		{user: test.UserAdmin, object: delivery, action: write, allow: true},
+	})
+}
+
 func TestAuthorizePolicies(t *testing.T) {
 	t.Parallel()
 
@@ -767,6 +809,257 @@ func TestAuthorizePolicies(t *testing.T) {
 	})
 }
 
+func TestAuthorizeAPITokens(t *testing.T) {
+	t.Parallel()
+
+	token := &fleet.APIToken{UserID: 42}
+	runTestCases(t, []authTestCase{
+		{user: nil, object: token, action: read, allow: false},
+		{user: nil, object: token, action: write, allow: false},
+
+		// Admin can read/write all
+		{user: test.UserAdmin, object: token, action: read, allow: true},
+		{user: test.UserAdmin, object: token, action: write, allow: true},
+
+		// Regular users can read/write own
+		{user: test.UserMaintainer, object: token, action: read, allow: false},
+		{user: test.UserMaintainer, object: token, action: write, allow: false},
+		{user: test.UserMaintainer, object: &fleet.APIToken{UserID: test.UserMaintainer.ID}, action: read, allow: true},
+		{user: test.UserMaintainer, object: &fleet.APIToken{UserID: test.UserMaintainer.ID}, action: write, allow: true},
+
+		{user: test.UserTeamAdminTeam1, object: token, action: write, allow: false},
+		{user: test.UserTeamAdminTeam1, object: &fleet.APIToken{UserID: test.UserTeamAdminTeam1.ID}, action: write, allow: true},
+	})
+}
+
+func TestAuthorizeWithAPITokenScopes(t *testing.T) {
+	t.Parallel()
+
+	hostsReadOnly := &fleet.APIToken{
+		ID:     1,
+		Scopes: fleet.APITokenScopes{{Object: "host", Actions: []string{read, list}}},
+	}
+	liveQueriesTeam1 := &fleet.APIToken{
+		ID:     2,
+		TeamID: ptr.Uint(1),
+		Scopes: fleet.APITokenScopes{
+			{Object: "query", Actions: []string{read, list, runNew}},
+			{Object: "targeted_query", Actions: []string{run}},
+		},
+	}
+	all := &fleet.APIToken{
+		ID:     3,
+		Scopes: fleet.APITokenScopes{{Object: "*", Actions: []string{"*"}}},
+	}
+
+	host := &fleet.Host{}
+	hostTeam1 := &fleet.Host{TeamID: ptr.Uint(1)}
+	query := &fleet.Query{}
+	team1Query := &fleet.TargetedQuery{HostTargets: fleet.HostTargets{TeamIDs: []uint{1}}, Query: query}
+	team2Query := &fleet.TargetedQuery{HostTargets: fleet.HostTargets{TeamIDs: []uint{2}}, Query: query}
+
+	testCases := []struct {
+		user   *fleet.User
+		token  *fleet.APIToken
+		object interface{}
+		action string
+		allow  bool
+	}{
+		// the scopes restrict the role of the user
+		{test.UserAdmin, hostsReadOnly, host, read, true},
+		{test.UserAdmin, hostsReadOnly, hostTeam1, list, true},
+		{test.UserAdmin, hostsReadOnly, host, write, false},
+		{test.UserAdmin, hostsReadOnly, query, read, false},
+
+		// but don't extend it
+		{test.UserTeamObserverTeam1, hostsReadOnly, hostTeam1, read, true},
+		{test.UserTeamObserverTeam1, hostsReadOnly, host, read, false},
+		{test.UserTeamObserverTeam1, all, hostTeam1, write, false},
+
+		// the team of the token restricts the role of the user to that team
+		{test.UserAdmin, liveQueriesTeam1, query, runNew, true},
+		{test.UserAdmin, liveQueriesTeam1, team1Query, run, true},
+		{test.UserAdmin, liveQueriesTeam1, team2Query, run, false},
+		{test.UserAdmin, liveQueriesTeam1, hostTeam1, read, false},
+		{test.UserTeamAdminTeam2, liveQueriesTeam1, query, runNew, false},
+
+		// the tokens can always read their user and the list of teams
+		{test.UserAdmin, hostsReadOnly, test.UserAdmin, read, true},
+		{test.UserAdmin, hostsReadOnly, &fleet.Team{}, read, true},
+		{test.UserAdmin, hostsReadOnly, test.UserObserver, read, false},
+		{test.UserAdmin, hostsReadOnly, &fleet.Team{ID: 1}, read, false},
+
+		// tokens can't be used to manage tokens or change passwords and roles
+		{test.UserAdmin, all, host, write, true},
+		{test.UserAdmin, all, &fleet.APIToken{UserID: test.UserAdmin.ID}, read, false},
+		{test.UserAdmin, all, test.UserAdmin, changePwd, false},
+		{test.UserAdmin, all, test.UserObserver, writeRole, false},
+	}
+	for i, tt := range testCases {
+		tt := tt
+		t.Run(fmt.Sprintf("%d_%s_%T_%s", i, tt.action, tt.object, fmt.Sprint(tt.allow)), func(t *testing.T) {
+			t.Parallel()
+
+			ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: tt.token.ScopedUser(tt.user), APIToken: tt.token})
+			err := auth.Authorize(ctx, tt.object, tt.action)
+			if tt.allow {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCustomRoles(t *testing.T) {
+	t.Parallel()
+
+	customAuth, err := NewAuthorizer()
+	require.NoError(t, err)
+
+	var loaded int
+	roles := []*fleet.CustomRole{
+		{
+			Name: "helpdesk",
+			Permissions: fleet.CustomRolePermissions{
+				{Object: "host", Actions: []string{read, list}},
+				{Object: "policy", Actions: []string{read}},
+			},
+		},
+		{
+			Name: "query author",
+			Permissions: fleet.CustomRolePermissions{
+				{Object: "query", Actions: []string{read, write}},
+			},
+		},
+		{
+			Name: "live queries",
+			Permissions: fleet.CustomRolePermissions{
+				{Object: "query", Actions: []string{read, runNew}},
+				{Object: "targeted_query", Actions: []string{run}},
+			},
+		},
+		{
+			// stored roles with objects that are not valid anymore don't give
+			// any permission on those objects
+			Name: "everything",
+			Permissions: fleet.CustomRolePermissions{
+				{Object: "host", Actions: []string{"*"}},
+				{Object: "*", Actions: []string{"*"}},
+				{Object: "user", Actions: []string{"*"}},
+				{Object: "invite", Actions: []string{"*"}},
+				{Object: "app_config", Actions: []string{"*"}},
+				{Object: "enroll_secret", Actions: []string{"*"}},
+				{Object: "custom_role", Actions: []string{"*"}},
+			},
+		},
+	}
+	customAuth.SetCustomRolesLoader(func(ctx context.Context) ([]*fleet.CustomRole, error) {
+		loaded++
+		return roles, nil
+	})
+
+	globalHelpdesk := &fleet.User{ID: 100, GlobalRole: ptr.String("helpdesk")}
+	globalQueryAuthor := &fleet.User{ID: 101, GlobalRole: ptr.String("query author")}
+	globalEverything := &fleet.User{ID: 102, GlobalRole: ptr.String("everything")}
+	globalUnknown := &fleet.User{ID: 103, GlobalRole: ptr.String("unknown")}
+	team1Helpdesk := &fleet.User{ID: 104, Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: "helpdesk"}}}
+	team1Everything := &fleet.User{ID: 106, Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: "everything"}}}
+	team1LiveQueries := &fleet.User{ID: 105, Teams: []fleet.UserTeam{
+		{Team: fleet.Team{ID: 1}, Role: "live queries"},
+		{Team: fleet.Team{ID: 2}, Role: fleet.RoleObserver},
+	}}
+
+	host := &fleet.Host{}
+	hostTeam1 := &fleet.Host{TeamID: ptr.Uint(1)}
+	hostTeam2 := &fleet.Host{TeamID: ptr.Uint(2)}
+	query := &fleet.Query{}
+	team1Query := &fleet.TargetedQuery{HostTargets: fleet.HostTargets{TeamIDs: []uint{1}}, Query: query}
+	team2Query := &fleet.TargetedQuery{HostTargets: fleet.HostTargets{TeamIDs: []uint{2}}, Query: query}
+	team12Query := &fleet.TargetedQuery{HostTargets: fleet.HostTargets{TeamIDs: []uint{1, 2}}, Query: query}
+	noTeamQuery := &fleet.TargetedQuery{Query: query}
+
+	testCases := []struct {
+		user   *fleet.User
+		object interface{}
+		action string
+		allow  bool
+	}{
+		// global custom roles apply to all objects
+		{globalHelpdesk, host, read, true},
+		{globalHelpdesk, hostTeam1, read, true},
+		{globalHelpdesk, host, write, false},
+		{globalHelpdesk, &fleet.Policy{}, read, true},
+		{globalHelpdesk, &fleet.Policy{}, write, false},
+		{globalHelpdesk, query, runNew, false},
+		{globalHelpdesk, noTeamQuery, run, false},
+		{globalQueryAuthor, query, write, true},
+		{globalQueryAuthor, &fleet.Pack{}, write, false},
+		{globalQueryAuthor, &fleet.AppConfig{}, write, false},
+		{globalQueryAuthor, host, read, false},
+
+		// the rules of the built-in roles still apply to everyone
+		{globalQueryAuthor, &fleet.AppConfig{}, read, true},
+		{globalQueryAuthor, globalQueryAuthor, read, true},
+
+		// roles that are not defined don't give any permission
+		{globalUnknown, host, read, false},
+
+		// custom roles can't manage the users, their access and the custom
+		// roles
+		{globalEverything, host, write, true},
+		{globalEverything, &fleet.Label{}, write, false},
+		{globalEverything, test.UserObserver, write, false},
+		{globalEverything, test.UserObserver, writeRole, false},
+		{globalEverything, test.UserObserver, changePwd, false},
+		{globalEverything, &fleet.Invite{}, write, false},
+		{globalEverything, &fleet.AppConfig{}, write, false},
+		{globalEverything, &fleet.EnrollSecret{}, read, false},
+		{globalEverything, &fleet.CustomRole{}, write, false},
+		{globalEverything, &fleet.CustomRole{}, read, true},
+
+		// team custom roles apply to the objects of the team
+		{team1Helpdesk, hostTeam1, read, true},
+		{team1Helpdesk, hostTeam2, read, false},
+		{team1Helpdesk, host, read, false},
+		{team1Helpdesk, &fleet.Policy{PolicyData: fleet.PolicyData{TeamID: ptr.Uint(1)}}, read, true},
+		{team1Helpdesk, &fleet.Policy{PolicyData: fleet.PolicyData{TeamID: ptr.Uint(2)}}, read, false},
+
+		// team custom roles can write the team but not manage its users
+		{team1Everything, &fleet.Team{ID: 1}, write, true},
+		{team1Everything, &fleet.Team{ID: 1}, writeRole, false},
+		{team1Everything, &fleet.Team{ID: 2}, write, false},
+
+		// and to the objects that don't belong to a team
+		{team1LiveQueries, query, runNew, true},
+		{team1LiveQueries, team1Query, run, true},
+		{team1LiveQueries, noTeamQuery, run, true},
+		{team1LiveQueries, team2Query, run, false},
+		{team1LiveQueries, team12Query, run, false},
+		{team1LiveQueries, query, write, false},
+	}
+	for i, tt := range testCases {
+		tt := tt
+		t.Run(fmt.Sprintf("%d_%s_%T_%s", i, tt.action, tt.object, fmt.Sprint(tt.allow)), func(t *testing.T) {
+			err := customAuth.Authorize(test.UserContext(tt.user), tt.object, tt.action)
+			if tt.allow {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	// the roles are cached by the authorizer
+	assert.Equal(t, 1, loaded)
+	require.NoError(t, customAuth.ReloadCustomRoles(context.Background()))
+	assert.Equal(t, 2, loaded)
+
+	// users without custom roles don't need them
+	require.NoError(t, customAuth.Authorize(test.UserContext(test.UserAdmin), host, write))
+	assert.Equal(t, 2, loaded)
+}
+
 func assertAuthorized(t *testing.T, user *fleet.User, object, action interface{}) {
 	t.Helper()
 
@@ -85,12 +85,17 @@ type ServerConfig struct {
 	URLPrefix      string `yaml:"url_prefix"`
 	Keepalive      bool   `yaml:"keepalive"`
 	SandboxEnabled bool   `yaml:"sandbox_enabled"`
+	SCIMToken      string `yaml:"scim_token"`
 }
 
 // AuthConfig defines configs related to user authorization
 type AuthConfig struct {
-	BcryptCost  int `yaml:"bcrypt_cost"`
-	SaltKeySize int `yaml:"salt_key_size"`
+	BcryptCost       int           `yaml:"bcrypt_cost"`
+	SaltKeySize      int           `yaml:"salt_key_size"`
+	LockoutThreshold int           `yaml:"lockout_threshold"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration"`
+	LockoutEmail     bool          `yaml:"lockout_email"`
+	TOTPEnabled      bool          `yaml:"totp_enabled"`
 }
 
 // AppConfig defines configs related to HTTP
@@ -113,6 +118,9 @@ type OsqueryConfig struct {
 	EnrollCooldown                   time.Duration `yaml:"enroll_cooldown"`
 	StatusLogPlugin                  string        `yaml:"status_log_plugin"`
 	ResultLogPlugin                  string        `yaml:"result_log_plugin"`
+	MultiLogPluginMode               string        `yaml:"multi_log_plugin_mode"`
+	LogSpoolDir                      string        `yaml:"log_spool_dir"`
+	LogSpoolMaxSize                  int64         `yaml:"log_spool_max_size"`
 	LabelUpdateInterval              time.Duration `yaml:"label_update_interval"`
 	PolicyUpdateInterval             time.Duration `yaml:"policy_update_interval"`
 	DetailUpdateInterval             time.Duration `yaml:"detail_update_interval"`
@@ -184,6 +192,25 @@ type AsyncProcessingConfig struct {
 	RedisScanKeysCount      int
 }
 
+// ActivityConfig defines configs related to the activities (audit log).
+type ActivityConfig struct {
+	EnableAuditLog   bool          `json:"enable_audit_log" yaml:"enable_audit_log"`
+	AuditLogPlugin   string        `json:"audit_log_plugin" yaml:"audit_log_plugin"`
+	AuditLogInterval time.Duration `json:"audit_log_interval" yaml:"audit_log_interval"`
+	// ArchiveS3 is the S3 bucket where the expired activities are archived.
+	ArchiveS3 S3Config `json:"archive_s3" yaml:"archive_s3"`
+}
+
+// LiveQueryConfig defines configs related to the live queries.
+type LiveQueryConfig struct {
+	// ExportS3 is the S3 bucket where the results of the exported live query
+	// campaigns are written.
+	ExportS3 S3Config `json:"export_s3" yaml:"export_s3"`
+	// ExportURLExpiration is how long the download links of the exported
+	// results are valid.
+	ExportURLExpiration time.Duration `json:"export_url_expiration" yaml:"export_url_expiration"`
+}
+
 // LoggingConfig defines configs related to logging
 type LoggingConfig struct {
 	Debug                bool
@@ -204,6 +231,7 @@ type FirehoseConfig struct {
 	StsAssumeRoleArn string `yaml:"sts_assume_role_arn"`
 	StatusStream     string `yaml:"status_stream"`
 	ResultStream     string `yaml:"result_stream"`
+	AuditStream      string `yaml:"audit_stream"`
 }
 
 // KinesisConfig defines configs for the AWS Kinesis logging plugin
@@ -215,6 +243,7 @@ type KinesisConfig struct {
 	StsAssumeRoleArn string `yaml:"sts_assume_role_arn"`
 	StatusStream     string `yaml:"status_stream"`
 	ResultStream     string `yaml:"result_stream"`
+	AuditStream      string `yaml:"audit_stream"`
 }
 
 // LambdaConfig defines configs for the AWS Lambda logging plugin
@@ -225,6 +254,7 @@ type LambdaConfig struct {
 	StsAssumeRoleArn string `yaml:"sts_assume_role_arn"`
 	StatusFunction   string `yaml:"status_function"`
 	ResultFunction   string `yaml:"result_function"`
+	AuditFunction    string `yaml:"audit_function"`
 }
 
 // S3Config defines config to enable file carving storage to an S3 bucket
@@ -245,6 +275,7 @@ type PubSubConfig struct {
 	Project       string `json:"project"`
 	StatusTopic   string `json:"status_topic" yaml:"status_topic"`
 	ResultTopic   string `json:"result_topic" yaml:"result_topic"`
+	AuditTopic    string `json:"audit_topic" yaml:"audit_topic"`
 	AddAttributes bool   `json:"add_attributes" yaml:"add_attributes"`
 }
 
@@ -252,6 +283,7 @@ type PubSubConfig struct {
 type FilesystemConfig struct {
 	StatusLogFile        string `json:"status_log_file" yaml:"status_log_file"`
 	ResultLogFile        string `json:"result_log_file" yaml:"result_log_file"`
+	AuditLogFile         string `json:"audit_log_file" yaml:"audit_log_file"`
 	EnableLogRotation    bool   `json:"enable_log_rotation" yaml:"enable_log_rotation"`
 	EnableLogCompression bool   `json:"enable_log_compression" yaml:"enable_log_compression"`
 }
@@ -260,11 +292,50 @@ type FilesystemConfig struct {
 type KafkaRESTConfig struct {
 	StatusTopic      string `json:"status_topic" yaml:"status_topic"`
 	ResultTopic      string `json:"result_topic" yaml:"result_topic"`
+	AuditTopic       string `json:"audit_topic" yaml:"audit_topic"`
 	ProxyHost        string `json:"proxyhost" yaml:"proxyhost"`
 	ContentTypeValue string `json:"content_type_value" yaml:"content_type_value"`
 	Timeout          int    `json:"timeout" yaml:"timeout"`
 }
 
+// SplunkConfig defines configs for the Splunk HTTP Event Collector logging
+// plugin.
+type SplunkConfig struct {
+	URL              string        `json:"url" yaml:"url"`
+	Token            string        `json:"token" yaml:"token"`
+	Index            string        `json:"index" yaml:"index"`
+	StatusSourcetype string        `json:"status_sourcetype" yaml:"status_sourcetype"`
+	ResultSourcetype string        `json:"result_sourcetype" yaml:"result_sourcetype"`
+	AuditSourcetype  string        `json:"audit_sourcetype" yaml:"audit_sourcetype"`
+	Timeout          time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// ElasticsearchConfig defines configs for the Elasticsearch bulk API logging
+// plugin.
+type ElasticsearchConfig struct {
+	URL         string        `json:"url" yaml:"url"`
+	Username    string        `json:"username" yaml:"username"`
+	Password    string        `json:"password" yaml:"password"`
+	APIKey      string        `json:"api_key" yaml:"api_key"`
+	StatusIndex string        `json:"status_index" yaml:"status_index"`
+	ResultIndex string        `json:"result_index" yaml:"result_index"`
+	AuditIndex  string        `json:"audit_index" yaml:"audit_index"`
+	Timeout     time.Duration `json:"timeout" yaml:"timeout"`
+}
+
+// SyslogConfig defines configs for the RFC 5424 syslog logging plugin.
+type SyslogConfig struct {
+	Address       string        `json:"address" yaml:"address"`
+	Facility      string        `json:"facility" yaml:"facility"`
+	AppName       string        `json:"app_name" yaml:"app_name"`
+	TLS           bool          `json:"tls" yaml:"tls"`
+	TLSCA         string        `json:"tls_ca" yaml:"tls_ca"`
+	TLSCert       string        `json:"tls_cert" yaml:"tls_cert"`
+	TLSKey        string        `json:"tls_key" yaml:"tls_key"`
+	TLSServerName string        `json:"tls_server_name" yaml:"tls_server_name"`
+	Timeout       time.Duration `json:"timeout" yaml:"timeout"`
+}
+
 // LicenseConfig defines configs related to licensing Fleet.
 type LicenseConfig struct {
 	Key              string `yaml:"key"`
@@ -333,6 +404,8 @@ type FleetConfig struct {
 	Session          SessionConfig
 	Osquery          OsqueryConfig
 	Logging          LoggingConfig
+	Activity         ActivityConfig
+	LiveQuery        LiveQueryConfig `yaml:"live_query"`
 	Firehose         FirehoseConfig
 	Kinesis          KinesisConfig
 	Lambda           LambdaConfig
@@ -340,6 +413,9 @@ type FleetConfig struct {
 	PubSub           PubSubConfig
 	Filesystem       FilesystemConfig
 	KafkaREST        KafkaRESTConfig
+	Splunk           SplunkConfig
+	Elasticsearch    ElasticsearchConfig
+	Syslog           SyslogConfig
 	License          LicenseConfig
 	Vulnerabilities  VulnerabilitiesConfig
 	Upgrades         UpgradesConfig
@@ -468,6 +544,8 @@ func (man Manager) addConfigs() {
 		"Controls whether HTTP keep-alives are enabled.")
 	man.addConfigBool("server.sandbox_enabled", false,
 		"When enabled, Fleet limits some features for the Sandbox")
+	man.addConfigString("server.scim_token", "",
+		"Bearer token used by the identity provider to authenticate SCIM provisioning requests (SCIM is disabled if empty)")
 
 	// Hide the sandbox flag as we don't want it to be discoverable for users for now
 	sandboxFlag := man.command.PersistentFlags().Lookup(flagNameFromConfigKey("server.sandbox_enabled"))
@@ -480,6 +558,14 @@ func (man Manager) addConfigs() {
 		"Bcrypt iterations")
 	man.addConfigInt("auth.salt_key_size", 24,
 		"Size of salt for passwords")
+	man.addConfigInt("auth.lockout_threshold", 10,
+		"Number of failed logins after which an account is locked (0 to disable)")
+	man.addConfigDuration("auth.lockout_duration", 15*time.Minute,
+		"Duration an account remains locked after too many failed logins (i.e. 15m)")
+	man.addConfigBool("auth.lockout_email", true,
+		"Notify users by email when their account is locked (requires SMTP)")
+	man.addConfigBool("auth.totp_enabled", false,
+		"Enable the two-factor authentication (TOTP) of the password logins")
 
 	// App
 	man.addConfigString("app.token_key", "CHANGEME",
@@ -508,6 +594,12 @@ func (man Manager) addConfigs() {
 		"Log plugin to use for status logs")
 	man.addConfigString("osquery.result_log_plugin", "filesystem",
 		"Log plugin to use for result logs")
+	man.addConfigString("osquery.multi_log_plugin_mode", "all",
+		"Failure mode when logs are written to multiple log plugins (all or best_effort)")
+	man.addConfigString("osquery.log_spool_dir", "",
+		"Directory where osquery logs that fail to be written are spooled and replayed from (disabled if empty)")
+	man.addConfigInt("osquery.log_spool_max_size", 1024*1024*1024,
+		"Maximum size in bytes of the osquery log spool, per log type")
 	man.addConfigDuration("osquery.label_update_interval", 1*time.Hour,
 		"Interval to update host label membership (i.e. 1h)")
 	man.addConfigDuration("osquery.policy_update_interval", 1*time.Hour,
@@ -559,6 +651,36 @@ func (man Manager) addConfigs() {
 	man.addConfigString("logging.tracing_type", "opentelemetry",
 		"Select the kind of tracing, defaults to opentelemetry, can also be elasticapm")
 
+	// Activity
+	man.addConfigBool("activity.enable_audit_log", false,
+		"Enable the streaming of the activities (audit log) to the audit log plugin")
+	man.addConfigString("activity.audit_log_plugin", "filesystem",
+		"Log plugin to use for the audit log")
+	man.addConfigDuration("activity.audit_log_interval", 1*time.Minute,
+		"Interval at which the new activities are streamed to the audit log plugin")
+	man.addConfigString("activity.archive_s3.bucket", "", "Bucket where to archive the expired activities")
+	man.addConfigString("activity.archive_s3.prefix", "", "Prefix under which the activities are archived")
+	man.addConfigString("activity.archive_s3.region", "", "AWS Region (if blank region is derived)")
+	man.addConfigString("activity.archive_s3.endpoint_url", "", "AWS Service Endpoint to use (leave blank for default service endpoints)")
+	man.addConfigString("activity.archive_s3.access_key_id", "", "Access Key ID for AWS authentication")
+	man.addConfigString("activity.archive_s3.secret_access_key", "", "Secret Access Key for AWS authentication")
+	man.addConfigString("activity.archive_s3.sts_assume_role_arn", "", "ARN of role to assume for AWS")
+	man.addConfigBool("activity.archive_s3.disable_ssl", false, "Disable SSL (typically for local testing)")
+	man.addConfigBool("activity.archive_s3.force_s3_path_style", false, "Set this to true to force path-style addressing, i.e., `http://s3.amazonaws.com/BUCKET/KEY`")
+
+	// Live queries
+	man.addConfigString("live_query.export_s3.bucket", "", "Bucket where to export the live query results")
+	man.addConfigString("live_query.export_s3.prefix", "", "Prefix under which the live query results are exported")
+	man.addConfigString("live_query.export_s3.region", "", "AWS Region (if blank region is derived)")
+	man.addConfigString("live_query.export_s3.endpoint_url", "", "AWS Service Endpoint to use (leave blank for default service endpoints)")
+	man.addConfigString("live_query.export_s3.access_key_id", "", "Access Key ID for AWS authentication")
+	man.addConfigString("live_query.export_s3.secret_access_key", "", "Secret Access Key for AWS authentication")
+	man.addConfigString("live_query.export_s3.sts_assume_role_arn", "", "ARN of role to assume for AWS")
+	man.addConfigBool("live_query.export_s3.disable_ssl", false, "Disable SSL (typically for local testing)")
+	man.addConfigBool("live_query.export_s3.force_s3_path_style", false, "Set this to true to force path-style addressing, i.e., `http://s3.amazonaws.com/BUCKET/KEY`")
+	man.addConfigDuration("live_query.export_url_expiration", 1*time.Hour,
+		"How long the download links of the exported live query results are valid")
+
 	// Firehose
 	man.addConfigString("firehose.region", "", "AWS Region to use")
 	man.addConfigString("firehose.endpoint_url", "",
@@ -569,6 +691,8 @@ func (man Manager) addConfigs() {
 		"ARN of role to assume for AWS")
 	man.addConfigString("firehose.status_stream", "",
 		"Firehose stream name for status logs")
+	man.addConfigString("firehose.audit_stream", "",
+		"Firehose stream name for audit logs")
 	man.addConfigString("firehose.result_stream", "",
 		"Firehose stream name for result logs")
 
@@ -582,6 +706,8 @@ func (man Manager) addConfigs() {
 		"ARN of role to assume for AWS")
 	man.addConfigString("kinesis.status_stream", "",
 		"Kinesis stream name for status logs")
+	man.addConfigString("kinesis.audit_stream", "",
+		"Kinesis stream name for audit logs")
 	man.addConfigString("kinesis.result_stream", "",
 		"Kinesis stream name for result logs")
 
@@ -593,6 +719,8 @@ func (man Manager) addConfigs() {
 		"ARN of role to assume for AWS")
 	man.addConfigString("lambda.status_function", "",
 		"Lambda function name for status logs")
+	man.addConfigString("lambda.audit_function", "",
+		"Lambda function name for audit logs")
 	man.addConfigString("lambda.result_function", "",
 		"Lambda function name for result logs")
 
@@ -611,6 +739,7 @@ func (man Manager) addConfigs() {
 	man.addConfigString("pubsub.project", "", "Google Cloud Project to use")
 	man.addConfigString("pubsub.status_topic", "", "PubSub topic for status logs")
 	man.addConfigString("pubsub.result_topic", "", "PubSub topic for result logs")
+	man.addConfigString("pubsub.audit_topic", "", "PubSub topic for audit logs")
 	man.addConfigBool("pubsub.add_attributes", false, "Add PubSub attributes in addition to the message body")
 
 	// Filesystem
@@ -618,6 +747,8 @@ func (man Manager) addConfigs() {
 		"Log file path to use for status logs")
 	man.addConfigString("filesystem.result_log_file", filepath.Join(os.TempDir(), "osquery_result"),
 		"Log file path to use for result logs")
+	man.addConfigString("filesystem.audit_log_file", filepath.Join(os.TempDir(), "fleet_audit"),
+		"Log file path to use for audit logs")
 	man.addConfigBool("filesystem.enable_log_rotation", false,
 		"Enable automatic rotation for osquery log files")
 	man.addConfigBool("filesystem.enable_log_compression", false,
@@ -626,11 +757,42 @@ func (man Manager) addConfigs() {
 	// KafkaREST
 	man.addConfigString("kafkarest.status_topic", "", "Kafka REST topic for status logs")
 	man.addConfigString("kafkarest.result_topic", "", "Kafka REST topic for result logs")
+	man.addConfigString("kafkarest.audit_topic", "", "Kafka REST topic for audit logs")
 	man.addConfigString("kafkarest.proxyhost", "", "Kafka REST proxy host url")
 	man.addConfigString("kafkarest.content_type_value", "application/vnd.kafka.json.v1+json",
 		"Kafka REST proxy content type header (defaults to \"application/vnd.kafka.json.v1+json\"")
 	man.addConfigInt("kafkarest.timeout", 5, "Kafka REST proxy json post timeout")
 
+	// Splunk
+	man.addConfigString("splunk.url", "", "Splunk HTTP Event Collector URL (e.g. https://splunk.example.com:8088)")
+	man.addConfigString("splunk.token", "", "Splunk HTTP Event Collector token")
+	man.addConfigString("splunk.index", "", "Splunk index for osquery logs (defaults to the token's default index)")
+	man.addConfigString("splunk.status_sourcetype", "osquery:status", "Splunk sourcetype for status logs")
+	man.addConfigString("splunk.result_sourcetype", "osquery:result", "Splunk sourcetype for result logs")
+	man.addConfigString("splunk.audit_sourcetype", "fleet:audit", "Splunk sourcetype for audit logs")
+	man.addConfigDuration("splunk.timeout", 10*time.Second, "Splunk HTTP Event Collector request timeout")
+
+	// Elasticsearch
+	man.addConfigString("elasticsearch.url", "", "Elasticsearch URL (e.g. https://elasticsearch.example.com:9200)")
+	man.addConfigString("elasticsearch.username", "", "Elasticsearch username for basic authentication")
+	man.addConfigString("elasticsearch.password", "", "Elasticsearch password for basic authentication")
+	man.addConfigString("elasticsearch.api_key", "", "Elasticsearch API key (base64-encoded), used instead of basic authentication")
+	man.addConfigString("elasticsearch.status_index", "osquery-status", "Elasticsearch index or data stream for status logs")
+	man.addConfigString("elasticsearch.result_index", "osquery-result", "Elasticsearch index or data stream for result logs")
+	man.addConfigString("elasticsearch.audit_index", "fleet-audit", "Elasticsearch index or data stream for audit logs")
+	man.addConfigDuration("elasticsearch.timeout", 30*time.Second, "Elasticsearch bulk request timeout")
+
+	// Syslog
+	man.addConfigString("syslog.address", "", "Syslog server address (host:port) to send logs to over TCP")
+	man.addConfigString("syslog.facility", "local0", "Syslog facility of the messages")
+	man.addConfigString("syslog.app_name", "fleet", "Syslog APP-NAME of the messages")
+	man.addConfigBool("syslog.tls", false, "Connect to the syslog server using TLS")
+	man.addConfigString("syslog.tls_ca", "", "Path to the CA certificate used to verify the syslog server")
+	man.addConfigString("syslog.tls_cert", "", "Path to the client certificate for the syslog server")
+	man.addConfigString("syslog.tls_key", "", "Path to the client key for the syslog server")
+	man.addConfigString("syslog.tls_server_name", "", "Server name used to verify the syslog server certificate")
+	man.addConfigDuration("syslog.timeout", 10*time.Second, "Syslog connection and write timeout")
+
 	// License
 	man.addConfigString("license.key", "", "Fleet license key (to enable Fleet Premium features)")
 	man.addConfigBool("license.enforce_host_limit", false, "Enforce license limit of enrolled hosts")
@@ -744,10 +906,15 @@ func (man Manager) LoadConfig() FleetConfig {
 			URLPrefix:      man.getConfigString("server.url_prefix"),
 			Keepalive:      man.getConfigBool("server.keepalive"),
 			SandboxEnabled: man.getConfigBool("server.sandbox_enabled"),
+			SCIMToken:      man.getConfigString("server.scim_token"),
 		},
 		Auth: AuthConfig{
-			BcryptCost:  man.getConfigInt("auth.bcrypt_cost"),
-			SaltKeySize: man.getConfigInt("auth.salt_key_size"),
+			BcryptCost:       man.getConfigInt("auth.bcrypt_cost"),
+			SaltKeySize:      man.getConfigInt("auth.salt_key_size"),
+			LockoutThreshold: man.getConfigInt("auth.lockout_threshold"),
+			LockoutDuration:  man.getConfigDuration("auth.lockout_duration"),
+			LockoutEmail:     man.getConfigBool("auth.lockout_email"),
+			TOTPEnabled:      man.getConfigBool("auth.totp_enabled"),
 		},
 		App: AppConfig{
 			TokenKeySize:              man.getConfigInt("app.token_key_size"),
@@ -764,6 +931,9 @@ func (man Manager) LoadConfig() FleetConfig {
 			EnrollCooldown:                   man.getConfigDuration("osquery.enroll_cooldown"),
 			StatusLogPlugin:                  man.getConfigString("osquery.status_log_plugin"),
 			ResultLogPlugin:                  man.getConfigString("osquery.result_log_plugin"),
+			MultiLogPluginMode:               man.getConfigString("osquery.multi_log_plugin_mode"),
+			LogSpoolDir:                      man.getConfigString("osquery.log_spool_dir"),
+			LogSpoolMaxSize:                  int64(man.getConfigInt("osquery.log_spool_max_size")),
 			StatusLogFile:                    man.getConfigString("osquery.status_log_file"),
 			ResultLogFile:                    man.getConfigString("osquery.result_log_file"),
 			LabelUpdateInterval:              man.getConfigDuration("osquery.label_update_interval"),
@@ -791,6 +961,36 @@ func (man Manager) LoadConfig() FleetConfig {
 			TracingEnabled:       man.getConfigBool("logging.tracing_enabled"),
 			TracingType:          man.getConfigString("logging.tracing_type"),
 		},
+		Activity: ActivityConfig{
+			EnableAuditLog:   man.getConfigBool("activity.enable_audit_log"),
+			AuditLogPlugin:   man.getConfigString("activity.audit_log_plugin"),
+			AuditLogInterval: man.getConfigDuration("activity.audit_log_interval"),
+			ArchiveS3: S3Config{
+				Bucket:           man.getConfigString("activity.archive_s3.bucket"),
+				Prefix:           man.getConfigString("activity.archive_s3.prefix"),
+				Region:           man.getConfigString("activity.archive_s3.region"),
+				EndpointURL:      man.getConfigString("activity.archive_s3.endpoint_url"),
+				AccessKeyID:      man.getConfigString("activity.archive_s3.access_key_id"),
+				SecretAccessKey:  man.getConfigString("activity.archive_s3.secret_access_key"),
+				StsAssumeRoleArn: man.getConfigString("activity.archive_s3.sts_assume_role_arn"),
+				DisableSSL:       man.getConfigBool("activity.archive_s3.disable_ssl"),
+				ForceS3PathStyle: man.getConfigBool("activity.archive_s3.force_s3_path_style"),
+			},
+		},
+		LiveQuery: LiveQueryConfig{
+			ExportS3: S3Config{
+				Bucket:           man.getConfigString("live_query.export_s3.bucket"),
+				Prefix:           man.getConfigString("live_query.export_s3.prefix"),
+				Region:           man.getConfigString("live_query.export_s3.region"),
+				EndpointURL:      man.getConfigString("live_query.export_s3.endpoint_url"),
+				AccessKeyID:      man.getConfigString("live_query.export_s3.access_key_id"),
+				SecretAccessKey:  man.getConfigString("live_query.export_s3.secret_access_key"),
+				StsAssumeRoleArn: man.getConfigString("live_query.export_s3.sts_assume_role_arn"),
+				DisableSSL:       man.getConfigBool("live_query.export_s3.disable_ssl"),
+				ForceS3PathStyle: man.getConfigBool("live_query.export_s3.force_s3_path_style"),
+			},
+			ExportURLExpiration: man.getConfigDuration("live_query.export_url_expiration"),
+		},
 		Firehose: FirehoseConfig{
 			Region:           man.getConfigString("firehose.region"),
 			EndpointURL:      man.getConfigString("firehose.endpoint_url"),
@@ -799,6 +999,7 @@ func (man Manager) LoadConfig() FleetConfig {
 			StsAssumeRoleArn: man.getConfigString("firehose.sts_assume_role_arn"),
 			StatusStream:     man.getConfigString("firehose.status_stream"),
 			ResultStream:     man.getConfigString("firehose.result_stream"),
+			AuditStream:      man.getConfigString("firehose.audit_stream"),
 		},
 		Kinesis: KinesisConfig{
 			Region:           man.getConfigString("kinesis.region"),
@@ -807,6 +1008,7 @@ func (man Manager) LoadConfig() FleetConfig {
 			SecretAccessKey:  man.getConfigString("kinesis.secret_access_key"),
 			StatusStream:     man.getConfigString("kinesis.status_stream"),
 			ResultStream:     man.getConfigString("kinesis.result_stream"),
+			AuditStream:      man.getConfigString("kinesis.audit_stream"),
 			StsAssumeRoleArn: man.getConfigString("kinesis.sts_assume_role_arn"),
 		},
 		Lambda: LambdaConfig{
@@ -815,6 +1017,7 @@ func (man Manager) LoadConfig() FleetConfig {
 			SecretAccessKey:  man.getConfigString("lambda.secret_access_key"),
 			StatusFunction:   man.getConfigString("lambda.status_function"),
 			ResultFunction:   man.getConfigString("lambda.result_function"),
+			AuditFunction:    man.getConfigString("lambda.audit_function"),
 			StsAssumeRoleArn: man.getConfigString("lambda.sts_assume_role_arn"),
 		},
 		S3: S3Config{
@@ -832,21 +1035,54 @@ func (man Manager) LoadConfig() FleetConfig {
 			Project:       man.getConfigString("pubsub.project"),
 			StatusTopic:   man.getConfigString("pubsub.status_topic"),
 			ResultTopic:   man.getConfigString("pubsub.result_topic"),
+			AuditTopic:    man.getConfigString("pubsub.audit_topic"),
 			AddAttributes: man.getConfigBool("pubsub.add_attributes"),
 		},
 		Filesystem: FilesystemConfig{
 			StatusLogFile:        man.getConfigString("filesystem.status_log_file"),
 			ResultLogFile:        man.getConfigString("filesystem.result_log_file"),
+			AuditLogFile:         man.getConfigString("filesystem.audit_log_file"),
 			EnableLogRotation:    man.getConfigBool("filesystem.enable_log_rotation"),
 			EnableLogCompression: man.getConfigBool("filesystem.enable_log_compression"),
 		},
 		KafkaREST: KafkaRESTConfig{
 			StatusTopic:      man.getConfigString("kafkarest.status_topic"),
 			ResultTopic:      man.getConfigString("kafkarest.result_topic"),
+			AuditTopic:       man.getConfigString("kafkarest.audit_topic"),
 			ProxyHost:        man.getConfigString("kafkarest.proxyhost"),
 			ContentTypeValue: man.getConfigString("kafkarest.content_type_value"),
 			Timeout:          man.getConfigInt("kafkarest.timeout"),
 		},
+		Splunk: SplunkConfig{
+			URL:              man.getConfigString("splunk.url"),
+			Token:            man.getConfigString("splunk.token"),
+			Index:            man.getConfigString("splunk.index"),
+			StatusSourcetype: man.getConfigString("splunk.status_sourcetype"),
+			ResultSourcetype: man.getConfigString("splunk.result_sourcetype"),
+			AuditSourcetype:  man.getConfigString("splunk.audit_sourcetype"),
+			Timeout:          man.getConfigDuration("splunk.timeout"),
+		},
+		Elasticsearch: ElasticsearchConfig{
+			URL:         man.getConfigString("elasticsearch.url"),
+			Username:    man.getConfigString("elasticsearch.username"),
+			Password:    man.getConfigString("elasticsearch.password"),
+			APIKey:      man.getConfigString("elasticsearch.api_key"),
+			StatusIndex: man.getConfigString("elasticsearch.status_index"),
+			ResultIndex: man.getConfigString("elasticsearch.result_index"),
+			AuditIndex:  man.getConfigString("elasticsearch.audit_index"),
+			Timeout:     man.getConfigDuration("elasticsearch.timeout"),
+		},
+		Syslog: SyslogConfig{
+			Address:       man.getConfigString("syslog.address"),
+			Facility:      man.getConfigString("syslog.facility"),
+			AppName:       man.getConfigString("syslog.app_name"),
+			TLS:           man.getConfigBool("syslog.tls"),
+			TLSCA:         man.getConfigString("syslog.tls_ca"),
+			TLSCert:       man.getConfigString("syslog.tls_cert"),
+			TLSKey:        man.getConfigString("syslog.tls_key"),
+			TLSServerName: man.getConfigString("syslog.tls_server_name"),
+			Timeout:       man.getConfigDuration("syslog.timeout"),
+		},
 		License: LicenseConfig{
 			Key:              man.getConfigString("license.key"),
 			EnforceHostLimit: man.getConfigBool("license.enforce_host_limit"),
@@ -1173,8 +1409,11 @@ func TestConfig() FleetConfig {
 			InviteTokenValidityPeriod: 5 * 24 * time.Hour,
 		},
 		Auth: AuthConfig{
-			BcryptCost:  6, // Low cost keeps tests fast
-			SaltKeySize: 24,
+			BcryptCost:       6, // Low cost keeps tests fast
+			SaltKeySize:      24,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			TOTPEnabled:      true,
 		},
 		Session: SessionConfig{
 			KeySize:  64,
@@ -1195,6 +1434,9 @@ func TestConfig() FleetConfig {
 			Debug:         true,
 			DisableBanner: true,
 		},
+		LiveQuery: LiveQueryConfig{
+			ExportURLExpiration: 1 * time.Hour,
+		},
 		Filesystem: FilesystemConfig{
 			StatusLogFile: testLogFile,
 			ResultLogFile: testLogFile,
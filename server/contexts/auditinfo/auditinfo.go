@@ -0,0 +1,33 @@
+// Package auditinfo provides the context for the request information that is
+// recorded along with the activities of the audit log.
+package auditinfo
+
+import (
+	"context"
+)
+
+type key int
+
+const auditInfoKey key = 0
+
+// AuditInfo holds the information about the request that performed an
+// activity.
+type AuditInfo struct {
+	// RemoteIP is the IP address of the client (as forwarded by the proxies,
+	// if any).
+	RemoteIP string
+	// UserAgent is the User-Agent header of the request.
+	UserAgent string
+}
+
+// NewContext returns a new context carrying the audit information of the
+// current request.
+func NewContext(ctx context.Context, info AuditInfo) context.Context {
+	return context.WithValue(ctx, auditInfoKey, info)
+}
+
+// FromContext extracts the audit information from context if present.
+func FromContext(ctx context.Context) (AuditInfo, bool) {
+	info, ok := ctx.Value(auditInfoKey).(AuditInfo)
+	return info, ok
+}
@@ -39,6 +39,10 @@ const (
 	// which only allows limited access to the device's own host information.
 	// This authentication mode does not support granular authorization.
 	AuthnDeviceToken
+	// AuthnSCIMToken is when authentication is done via the SCIM bearer token
+	// of the server configuration, used by the identity provider to provision
+	// users. This authentication mode does not support granular authorization.
+	AuthnSCIMToken
 )
 
 // AuthorizationContext contains the context information used for the
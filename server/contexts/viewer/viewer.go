@@ -28,6 +28,9 @@ func FromContext(ctx context.Context) (Viewer, bool) {
 type Viewer struct {
 	User    *fleet.User
 	Session *fleet.Session
+	// APIToken is set instead of Session when the user is authenticated with a
+	// personal API token.
+	APIToken *fleet.APIToken
 }
 
 // UserID is a helper that enables quick access to the user ID of the current
@@ -74,6 +77,9 @@ func (v Viewer) IsUserID(id uint) bool {
 // IsLoggedIn determines whether or not the current VC is attached to a user
 // account
 func (v Viewer) IsLoggedIn() bool {
+	if v.APIToken != nil && v.APIToken.ID != 0 {
+		return true
+	}
 	if v.Session != nil {
 		// Without having access to a service to call GetInfoAboutSession(id),
 		// we can't synchronously check the database here.
@@ -42,6 +42,17 @@ var (
 		},
 	}
 
+	apiTokenViewer = Viewer{
+		User: &fleet.User{
+			ID:   48,
+			Name: "API Token User",
+		},
+		APIToken: &fleet.APIToken{
+			ID:     7,
+			UserID: 48,
+		},
+	}
+
 	// Admin users
 	adminViewer = Viewer{
 		User: &fleet.User{
@@ -85,6 +96,7 @@ func TestIsLoggedIn(t *testing.T) {
 
 	assert.Equal(t, true, userViewer.IsLoggedIn())
 	assert.Equal(t, true, needsPasswordResetUserViewer.IsLoggedIn())
+	assert.Equal(t, true, apiTokenViewer.IsLoggedIn())
 
 	assert.Equal(t, true, adminViewer.IsLoggedIn())
 	assert.Equal(t, true, needsPasswordResetAdminViewer.IsLoggedIn())
@@ -96,6 +108,7 @@ func TestCanPerformActions(t *testing.T) {
 
 	assert.Equal(t, true, userViewer.CanPerformActions())
 	assert.Equal(t, false, needsPasswordResetUserViewer.CanPerformActions())
+	assert.Equal(t, true, apiTokenViewer.CanPerformActions())
 
 	assert.Equal(t, true, adminViewer.CanPerformActions())
 	assert.Equal(t, false, needsPasswordResetAdminViewer.CanPerformActions())
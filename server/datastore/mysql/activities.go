@@ -5,23 +5,56 @@ import (
 	"database/sql"
 	"encoding/json"
 
+	"github.com/fleetdm/fleet/v4/server/contexts/auditinfo"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/jmoiron/sqlx"
 )
 
-// NewActivity stores an activity item that the user performed
+// maxActivityUserAgentLength is the size of the user_agent column, longer user
+// agents are truncated.
+const maxActivityUserAgentLength = 255
+
+// NewActivity stores an activity item that the user performed. The user may be
+// nil if the activity was not performed by a user (e.g. via SCIM
+// provisioning), or have no ID if it does not exist anymore, in which case only
+// its name is stored.
 func (ds *Datastore) NewActivity(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
 	detailsBytes, err := json.Marshal(details)
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "marshaling activity details")
 	}
+
+	var userID *uint
+	var userName *string
+	if user != nil {
+		if user.ID != 0 {
+			userID = &user.ID
+		}
+		userName = &user.Name
+	}
+
+	var ip, ua *string
+	if info, ok := auditinfo.FromContext(ctx); ok {
+		if info.RemoteIP != "" {
+			ip = &info.RemoteIP
+		}
+		if v := info.UserAgent; v != "" {
+			if len(v) > maxActivityUserAgentLength {
+				v = v[:maxActivityUserAgentLength]
+			}
+			ua = &v
+		}
+	}
+
 	_, err = ds.writer.ExecContext(ctx,
-		`INSERT INTO activities (user_id, user_name, activity_type, details) VALUES(?,?,?,?)`,
-		user.ID,
-		user.Name,
+		`INSERT INTO activities (user_id, user_name, activity_type, details, ip_address, user_agent) VALUES(?,?,?,?,?,?)`,
+		userID,
+		userName,
 		activityType,
 		detailsBytes,
+		ip,
+		ua,
 	)
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "new activity")
@@ -30,14 +63,37 @@ func (ds *Datastore) NewActivity(ctx context.Context, user *fleet.User, activity
 }
 
 // ListActivities returns a slice of activities performed across the organization
-func (ds *Datastore) ListActivities(ctx context.Context, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+func (ds *Datastore) ListActivities(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
 	activities := []*fleet.Activity{}
-	query := `SELECT a.id, a.user_id, a.created_at, a.activity_type, a.details, coalesce(u.name, a.user_name) as name, u.gravatar_url, u.email
+	query := `SELECT a.id, a.user_id, a.created_at, a.activity_type, a.details, a.ip_address, a.user_agent, a.streamed,
+	            coalesce(u.name, a.user_name, '') as name, u.gravatar_url, u.email
 	          FROM activities a LEFT JOIN users u ON (a.user_id=u.id)
 			  WHERE true`
-	query = appendListOptionsToSQL(query, opt)
 
-	err := sqlx.SelectContext(ctx, ds.reader, &activities, query)
+	var args []interface{}
+	if opt.ActivityType != "" {
+		query += ` AND a.activity_type = ?`
+		args = append(args, opt.ActivityType)
+	}
+	if opt.ActorID != nil {
+		query += ` AND a.user_id = ?`
+		args = append(args, *opt.ActorID)
+	}
+	if opt.StartCreatedAt != nil {
+		query += ` AND a.created_at >= ?`
+		args = append(args, *opt.StartCreatedAt)
+	}
+	if opt.EndCreatedAt != nil {
+		query += ` AND a.created_at < ?`
+		args = append(args, *opt.EndCreatedAt)
+	}
+	if opt.Streamed != nil {
+		query += ` AND a.streamed = ?`
+		args = append(args, *opt.Streamed)
+	}
+	query, args = appendListOptionsWithCursorToSQL(query, args, opt.ListOptions)
+
+	err := sqlx.SelectContext(ctx, ds.reader, &activities, query, args...)
 	if err == sql.ErrNoRows {
 		return nil, ctxerr.Wrap(ctx, notFound("Activity"))
 	} else if err != nil {
@@ -46,3 +102,35 @@ func (ds *Datastore) ListActivities(ctx context.Context, opt fleet.ListOptions)
 
 	return activities, nil
 }
+
+// MarkActivitiesAsStreamed marks the activities as exported to the audit log.
+func (ds *Datastore) MarkActivitiesAsStreamed(ctx context.Context, activityIDs []uint) error {
+	if len(activityIDs) == 0 {
+		return nil
+	}
+
+	stmt, args, err := sqlx.In(`UPDATE activities SET streamed = true WHERE id IN (?)`, activityIDs)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "build mark activities as streamed query")
+	}
+	if _, err := ds.writer.ExecContext(ctx, stmt, args...); err != nil {
+		return ctxerr.Wrap(ctx, err, "mark activities as streamed")
+	}
+	return nil
+}
+
+// DeleteActivities deletes the activities, e.g. once they have expired.
+func (ds *Datastore) DeleteActivities(ctx context.Context, activityIDs []uint) error {
+	if len(activityIDs) == 0 {
+		return nil
+	}
+
+	stmt, args, err := sqlx.In(`DELETE FROM activities WHERE id IN (?)`, activityIDs)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "build delete activities query")
+	}
+	if _, err := ds.writer.ExecContext(ctx, stmt, args...); err != nil {
+		return ctxerr.Wrap(ctx, err, "delete activities")
+	}
+	return nil
+}
@@ -2,10 +2,14 @@ package mysql
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/fleetdm/fleet/v4/server/contexts/auditinfo"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,6 +23,11 @@ func TestActivity(t *testing.T) {
 	}{
 		{"UsernameChange", testActivityUsernameChange},
 		{"New", testActivityNew},
+		{"RequestContext", testActivityRequestContext},
+		{"Filters", testActivityFilters},
+		{"Streamed", testActivityStreamed},
+		{"NoActor", testActivityNoActor},
+		{"Delete", testActivityDelete},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -41,7 +50,7 @@ func testActivityUsernameChange(t *testing.T, ds *Datastore) {
 	require.NoError(t, ds.NewActivity(context.Background(), u, "test1", &map[string]interface{}{"detail": 1, "sometext": "aaa"}))
 	require.NoError(t, ds.NewActivity(context.Background(), u, "test2", &map[string]interface{}{"detail": 2}))
 
-	activities, err := ds.ListActivities(context.Background(), fleet.ListOptions{})
+	activities, err := ds.ListActivities(context.Background(), fleet.ListActivitiesOptions{})
 	require.NoError(t, err)
 	assert.Len(t, activities, 2)
 	assert.Equal(t, "fullname", activities[0].ActorFullName)
@@ -50,7 +59,7 @@ func testActivityUsernameChange(t *testing.T, ds *Datastore) {
 	err = ds.SaveUser(context.Background(), u)
 	require.NoError(t, err)
 
-	activities, err = ds.ListActivities(context.Background(), fleet.ListOptions{})
+	activities, err = ds.ListActivities(context.Background(), fleet.ListActivitiesOptions{})
 	require.NoError(t, err)
 	assert.Len(t, activities, 2)
 	assert.Equal(t, "newname", activities[0].ActorFullName)
@@ -60,7 +69,7 @@ func testActivityUsernameChange(t *testing.T, ds *Datastore) {
 	err = ds.DeleteUser(context.Background(), u.ID)
 	require.NoError(t, err)
 
-	activities, err = ds.ListActivities(context.Background(), fleet.ListOptions{})
+	activities, err = ds.ListActivities(context.Background(), fleet.ListActivitiesOptions{})
 	require.NoError(t, err)
 	assert.Len(t, activities, 2)
 	assert.Equal(t, "fullname", activities[0].ActorFullName)
@@ -79,9 +88,11 @@ func testActivityNew(t *testing.T, ds *Datastore) {
 	require.NoError(t, ds.NewActivity(context.Background(), u, "test1", &map[string]interface{}{"detail": 1, "sometext": "aaa"}))
 	require.NoError(t, ds.NewActivity(context.Background(), u, "test2", &map[string]interface{}{"detail": 2}))
 
-	opt := fleet.ListOptions{
-		Page:    0,
-		PerPage: 1,
+	opt := fleet.ListActivitiesOptions{
+		ListOptions: fleet.ListOptions{
+			Page:    0,
+			PerPage: 1,
+		},
 	}
 	activities, err := ds.ListActivities(context.Background(), opt)
 	require.NoError(t, err)
@@ -89,9 +100,11 @@ func testActivityNew(t *testing.T, ds *Datastore) {
 	assert.Equal(t, "fullname", activities[0].ActorFullName)
 	assert.Equal(t, "test1", activities[0].Type)
 
-	opt = fleet.ListOptions{
-		Page:    1,
-		PerPage: 1,
+	opt = fleet.ListActivitiesOptions{
+		ListOptions: fleet.ListOptions{
+			Page:    1,
+			PerPage: 1,
+		},
 	}
 	activities, err = ds.ListActivities(context.Background(), opt)
 	require.NoError(t, err)
@@ -99,11 +112,136 @@ func testActivityNew(t *testing.T, ds *Datastore) {
 	assert.Equal(t, "fullname", activities[0].ActorFullName)
 	assert.Equal(t, "test2", activities[0].Type)
 
-	opt = fleet.ListOptions{
-		Page:    0,
-		PerPage: 10,
+	opt = fleet.ListActivitiesOptions{
+		ListOptions: fleet.ListOptions{
+			Page:    0,
+			PerPage: 10,
+		},
 	}
 	activities, err = ds.ListActivities(context.Background(), opt)
 	require.NoError(t, err)
 	assert.Len(t, activities, 2)
 }
+
+func testActivityRequestContext(t *testing.T, ds *Datastore) {
+	u := test.NewUser(t, ds, "fullname", "email@asd.com", true)
+
+	ctx := auditinfo.NewContext(context.Background(), auditinfo.AuditInfo{
+		RemoteIP:  "10.0.0.1",
+		UserAgent: "fleetctl/4.20.0 " + strings.Repeat("a", 300),
+	})
+	require.NoError(t, ds.NewActivity(ctx, u, "test1", nil))
+	require.NoError(t, ds.NewActivity(context.Background(), u, "test2", nil))
+
+	activities, err := ds.ListActivities(context.Background(), fleet.ListActivitiesOptions{ListOptions: fleet.ListOptions{OrderKey: "a.id"}})
+	require.NoError(t, err)
+	require.Len(t, activities, 2)
+	require.NotNil(t, activities[0].ActorIP)
+	assert.Equal(t, "10.0.0.1", *activities[0].ActorIP)
+	require.NotNil(t, activities[0].ActorUserAgent)
+	assert.Len(t, *activities[0].ActorUserAgent, 255)
+	assert.True(t, strings.HasPrefix(*activities[0].ActorUserAgent, "fleetctl/4.20.0 "))
+	assert.Nil(t, activities[1].ActorIP)
+	assert.Nil(t, activities[1].ActorUserAgent)
+}
+
+func testActivityFilters(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	u1 := test.NewUser(t, ds, "user1", "user1@example.com", true)
+	u2 := test.NewUser(t, ds, "user2", "user2@example.com", true)
+
+	require.NoError(t, ds.NewActivity(ctx, u1, "type1", nil))
+	require.NoError(t, ds.NewActivity(ctx, u1, "type2", nil))
+	require.NoError(t, ds.NewActivity(ctx, u2, "type1", nil))
+	// move the first activity to the past
+	_, err := ds.writer.Exec(`UPDATE activities SET created_at = ? WHERE activity_type = ? AND user_id = ?`,
+		time.Now().Add(-48*time.Hour), "type1", u1.ID)
+	require.NoError(t, err)
+
+	listTypes := func(opt fleet.ListActivitiesOptions) []string {
+		opt.OrderKey = "a.id"
+		activities, err := ds.ListActivities(ctx, opt)
+		require.NoError(t, err)
+		var types []string
+		for _, a := range activities {
+			types = append(types, a.Type+"/"+a.ActorFullName)
+		}
+		return types
+	}
+
+	assert.Equal(t, []string{"type1/user1", "type2/user1", "type1/user2"}, listTypes(fleet.ListActivitiesOptions{}))
+	assert.Equal(t, []string{"type1/user1", "type1/user2"}, listTypes(fleet.ListActivitiesOptions{ActivityType: "type1"}))
+	assert.Equal(t, []string{"type1/user2"}, listTypes(fleet.ListActivitiesOptions{ActorID: &u2.ID}))
+	assert.Equal(t, []string{"type1/user2"}, listTypes(fleet.ListActivitiesOptions{ActivityType: "type1", ActorID: &u2.ID}))
+	assert.Empty(t, listTypes(fleet.ListActivitiesOptions{ActivityType: "type2", ActorID: &u2.ID}))
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	assert.Equal(t, []string{"type2/user1", "type1/user2"}, listTypes(fleet.ListActivitiesOptions{StartCreatedAt: &yesterday}))
+	assert.Equal(t, []string{"type1/user1"}, listTypes(fleet.ListActivitiesOptions{EndCreatedAt: &yesterday}))
+	assert.Equal(t, []string{"type2/user1"}, listTypes(fleet.ListActivitiesOptions{StartCreatedAt: &yesterday, ActorID: &u1.ID}))
+}
+
+func testActivityStreamed(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	u := test.NewUser(t, ds, "fullname", "email@asd.com", true)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ds.NewActivity(ctx, u, "test", nil))
+	}
+	// no-op
+	require.NoError(t, ds.MarkActivitiesAsStreamed(ctx, nil))
+
+	opt := fleet.ListActivitiesOptions{ListOptions: fleet.ListOptions{OrderKey: "a.id"}, Streamed: ptr.Bool(false)}
+	activities, err := ds.ListActivities(ctx, opt)
+	require.NoError(t, err)
+	require.Len(t, activities, 3)
+
+	require.NoError(t, ds.MarkActivitiesAsStreamed(ctx, []uint{activities[0].ID, activities[1].ID}))
+	notStreamed, err := ds.ListActivities(ctx, opt)
+	require.NoError(t, err)
+	require.Len(t, notStreamed, 1)
+	assert.Equal(t, activities[2].ID, notStreamed[0].ID)
+
+	opt.Streamed = ptr.Bool(true)
+	streamed, err := ds.ListActivities(ctx, opt)
+	require.NoError(t, err)
+	require.Len(t, streamed, 2)
+	assert.True(t, streamed[0].Streamed)
+}
+
+func testActivityNoActor(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	require.NoError(t, ds.NewActivity(ctx, nil, "test1", nil))
+	require.NoError(t, ds.NewActivity(ctx, &fleet.User{Name: "deleted"}, "test2", nil))
+
+	activities, err := ds.ListActivities(ctx, fleet.ListActivitiesOptions{ListOptions: fleet.ListOptions{OrderKey: "a.id"}})
+	require.NoError(t, err)
+	require.Len(t, activities, 2)
+	assert.Nil(t, activities[0].ActorID)
+	assert.Empty(t, activities[0].ActorFullName)
+	assert.Nil(t, activities[1].ActorID)
+	assert.Equal(t, "deleted", activities[1].ActorFullName)
+}
+
+func testActivityDelete(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	u := test.NewUser(t, ds, "fullname", "email@asd.com", true)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ds.NewActivity(ctx, u, "test", nil))
+	}
+	// no-op
+	require.NoError(t, ds.DeleteActivities(ctx, nil))
+
+	opt := fleet.ListActivitiesOptions{ListOptions: fleet.ListOptions{OrderKey: "a.id"}}
+	activities, err := ds.ListActivities(ctx, opt)
+	require.NoError(t, err)
+	require.Len(t, activities, 3)
+
+	require.NoError(t, ds.DeleteActivities(ctx, []uint{activities[0].ID, activities[2].ID}))
+	remaining, err := ds.ListActivities(ctx, opt)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, activities[1].ID, remaining[0].ID)
+}
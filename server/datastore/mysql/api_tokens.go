@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/jmoiron/sqlx"
+)
+
+const apiTokenColumns = `id, created_at, updated_at, user_id, name, key_hash, team_id, scopes, expires_at, last_used_at`
+
+func (ds *Datastore) NewAPIToken(ctx context.Context, token *fleet.APIToken) (*fleet.APIToken, error) {
+	sqlStatement := `
+		INSERT INTO api_tokens (
+			user_id,
+			name,
+			key_hash,
+			team_id,
+			scopes,
+			expires_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	result, err := ds.writer.ExecContext(ctx, sqlStatement, token.UserID, token.Name, token.KeyHash, token.TeamID, token.Scopes, token.ExpiresAt)
+	if err != nil {
+		if isDuplicate(err) {
+			return nil, ctxerr.Wrap(ctx, alreadyExists("APIToken", token.Name))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "inserting api token")
+	}
+
+	id, _ := result.LastInsertId() // cannot fail with the mysql driver
+	return ds.apiTokenByID(ctx, ds.writer, uint(id))
+}
+
+func (ds *Datastore) apiTokenByID(ctx context.Context, q sqlx.QueryerContext, id uint) (*fleet.APIToken, error) {
+	var token fleet.APIToken
+	err := sqlx.GetContext(ctx, q, &token, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE id = ?`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("APIToken").WithID(id))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "selecting api token by id")
+	}
+	return &token, nil
+}
+
+func (ds *Datastore) APITokenByKeyHash(ctx context.Context, keyHash string) (*fleet.APIToken, error) {
+	var token fleet.APIToken
+	err := sqlx.GetContext(ctx, ds.reader, &token, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE key_hash = ?`, keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("APIToken").WithName("<key redacted>"))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "selecting api token by key hash")
+	}
+	return &token, nil
+}
+
+func (ds *Datastore) ListAPITokens(ctx context.Context, userID uint) ([]*fleet.APIToken, error) {
+	tokens := []*fleet.APIToken{}
+	err := sqlx.SelectContext(ctx, ds.reader, &tokens, `SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_id = ? ORDER BY name`, userID)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "selecting api tokens for user")
+	}
+	return tokens, nil
+}
+
+func (ds *Datastore) DeleteAPIToken(ctx context.Context, userID, id uint) error {
+	result, err := ds.writer.ExecContext(ctx, `DELETE FROM api_tokens WHERE user_id = ? AND id = ?`, userID, id)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "deleting api token")
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ctxerr.Wrap(ctx, notFound("APIToken").WithID(id))
+	}
+	return nil
+}
+
+func (ds *Datastore) MarkAPITokenUsed(ctx context.Context, id uint) error {
+	_, err := ds.writer.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, ds.clock.Now(), id)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "updating api token last used")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokens(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"CRUD", testAPITokensCRUD},
+		{"DeleteTeam", testAPITokensDeleteTeam},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testAPITokensCRUD(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user1 := test.NewUser(t, ds, "Alice", "alice@example.com", true)
+	user2 := test.NewUser(t, ds, "Bob", "bob@example.com", true)
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	token1, err := ds.NewAPIToken(ctx, &fleet.APIToken{
+		UserID:    user1.ID,
+		Name:      "zz automation",
+		KeyHash:   fleet.HashAPITokenKey("key1"),
+		Scopes:    fleet.APITokenScopes{{Object: "host", Actions: []string{"read", "list"}}},
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, token1.ID)
+	assert.Equal(t, fleet.APITokenScopes{{Object: "host", Actions: []string{"read", "list"}}}, token1.Scopes)
+	require.NotNil(t, token1.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*token1.ExpiresAt))
+	assert.Nil(t, token1.LastUsedAt)
+	assert.Nil(t, token1.TeamID)
+
+	token2, err := ds.NewAPIToken(ctx, &fleet.APIToken{
+		UserID:  user1.ID,
+		Name:    "aa live queries",
+		KeyHash: fleet.HashAPITokenKey("key2"),
+		Scopes:  fleet.APITokenScopes{{Object: "*", Actions: []string{"*"}}},
+	})
+	require.NoError(t, err)
+
+	// names are unique per user
+	_, err = ds.NewAPIToken(ctx, &fleet.APIToken{UserID: user1.ID, Name: "aa live queries", KeyHash: fleet.HashAPITokenKey("key3")})
+	require.Error(t, err)
+	var existsErr fleet.AlreadyExistsError
+	require.ErrorAs(t, err, &existsErr)
+	_, err = ds.NewAPIToken(ctx, &fleet.APIToken{UserID: user2.ID, Name: "aa live queries", KeyHash: fleet.HashAPITokenKey("key3")})
+	require.NoError(t, err)
+
+	got, err := ds.APITokenByKeyHash(ctx, fleet.HashAPITokenKey("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, token1.ID, got.ID)
+	assert.Equal(t, user1.ID, got.UserID)
+
+	_, err = ds.APITokenByKeyHash(ctx, fleet.HashAPITokenKey("nope"))
+	require.True(t, fleet.IsNotFound(err))
+
+	tokens, err := ds.ListAPITokens(ctx, user1.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, token2.ID, tokens[0].ID)
+	assert.Equal(t, token1.ID, tokens[1].ID)
+
+	require.NoError(t, ds.MarkAPITokenUsed(ctx, token1.ID))
+	got, err = ds.APITokenByKeyHash(ctx, fleet.HashAPITokenKey("key1"))
+	require.NoError(t, err)
+	require.NotNil(t, got.LastUsedAt)
+
+	// can't delete the token of another user
+	err = ds.DeleteAPIToken(ctx, user2.ID, token1.ID)
+	require.True(t, fleet.IsNotFound(err))
+
+	require.NoError(t, ds.DeleteAPIToken(ctx, user1.ID, token1.ID))
+	_, err = ds.APITokenByKeyHash(ctx, fleet.HashAPITokenKey("key1"))
+	require.True(t, fleet.IsNotFound(err))
+
+	// deleting the user deletes its tokens
+	require.NoError(t, ds.DeleteUser(ctx, user1.ID))
+	tokens, err = ds.ListAPITokens(ctx, user1.ID)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+func testAPITokensDeleteTeam(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Alice", "alice@example.com", true)
+	team, err := ds.NewTeam(ctx, &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+
+	_, err = ds.NewAPIToken(ctx, &fleet.APIToken{
+		UserID:  user.ID,
+		Name:    "team token",
+		KeyHash: fleet.HashAPITokenKey("key1"),
+		TeamID:  ptr.Uint(team.ID),
+		Scopes:  fleet.APITokenScopes{{Object: "host", Actions: []string{"read"}}},
+	})
+	require.NoError(t, err)
+
+	tokens, err := ds.ListAPITokens(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, ptr.Uint(team.ID), tokens[0].TeamID)
+
+	// the tokens restricted to a team are deleted with the team
+	require.NoError(t, ds.DeleteTeam(ctx, team.ID))
+	tokens, err = ds.ListAPITokens(ctx, user.ID)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
@@ -282,10 +282,12 @@ func testAppConfigDefaults(t *testing.T, ds *Datastore) {
 	require.False(t, ac.WebhookSettings.HostStatusWebhook.Enable)
 	require.True(t, ac.Features.EnableHostUsers)
 	require.False(t, ac.Features.EnableSoftwareInventory)
+	require.Equal(t, fleet.LiveQueryResultsSettings{RetentionDays: 30, MaxRowsPerHost: 1000, MaxRowsPerCampaign: 100000}, ac.LiveQueryResultsSettings)
+	require.Equal(t, fleet.QueryReportsSettings{Enabled: false, MaxRowsPerQuery: 1000}, ac.QueryReportsSettings)
 
 	_, err = ds.writer.Exec(
 		insertAppConfigQuery,
-		`{"webhook_settings": {"interval": "12h"}, "features": {"enable_host_users": false}}`,
+		`{"webhook_settings": {"interval": "12h"}, "features": {"enable_host_users": false}, "live_query_results_settings": {"retention_days": 0}}`,
 	)
 	require.NoError(t, err)
 
@@ -295,6 +297,7 @@ func testAppConfigDefaults(t *testing.T, ds *Datastore) {
 	require.Equal(t, 12*time.Hour, ac.WebhookSettings.Interval.Duration)
 	require.False(t, ac.Features.EnableHostUsers)
 	require.False(t, ac.Features.EnableSoftwareInventory)
+	require.Equal(t, fleet.LiveQueryResultsSettings{RetentionDays: 0, MaxRowsPerHost: 1000, MaxRowsPerCampaign: 100000}, ac.LiveQueryResultsSettings)
 }
 
 func testAppConfigBackwardsCompatibility(t *testing.T, ds *Datastore) {
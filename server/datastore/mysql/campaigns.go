@@ -2,6 +2,9 @@ package mysql
 
 import (
 	"context"
+	sqldb "database/sql"
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
@@ -15,11 +18,13 @@ func (ds *Datastore) NewDistributedQueryCampaign(ctx context.Context, camp *flee
 		INSERT INTO distributed_query_campaigns (
 			query_id,
 			status,
-			user_id
+			user_id,
+			collect_until,
+			export_format
 		)
-		VALUES(?,?,?)
+		VALUES(?,?,?,?,?)
 	`
-	result, err := ds.writer.ExecContext(ctx, sqlStatement, camp.QueryID, camp.Status, camp.UserID)
+	result, err := ds.writer.ExecContext(ctx, sqlStatement, camp.QueryID, camp.Status, camp.UserID, camp.CollectUntil, camp.ExportFormat)
 	if err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "inserting distributed query campaign")
 	}
@@ -35,6 +40,9 @@ func (ds *Datastore) DistributedQueryCampaign(ctx context.Context, id uint) (*fl
 	`
 	campaign := &fleet.DistributedQueryCampaign{}
 	if err := sqlx.GetContext(ctx, ds.reader, campaign, sql, id); err != nil {
+		if err == sqldb.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("DistributedQueryCampaign").WithID(id))
+		}
 		return nil, ctxerr.Wrap(ctx, err, "selecting distributed query campaign")
 	}
 
@@ -73,6 +81,34 @@ func (ds *Datastore) DistributedQueryCampaignsForQuery(ctx context.Context, quer
 	return campaigns, nil
 }
 
+func (ds *Datastore) ListDistributedQueryCampaignsToExport(ctx context.Context, now, exportedSince time.Time) ([]*fleet.DistributedQueryCampaign, error) {
+	// an asynchronous campaign is complete once its collection window ended,
+	// even if the cleanup did not update its status yet.
+	sqlStatement := `
+		SELECT * FROM distributed_query_campaigns
+		WHERE export_format != ''
+		AND (exported_at IS NULL OR exported_at >= ?)
+		AND (status = ? OR (collect_until IS NOT NULL AND collect_until <= ?))
+		ORDER BY id
+	`
+	campaigns := []*fleet.DistributedQueryCampaign{}
+	if err := sqlx.SelectContext(ctx, ds.reader, &campaigns, sqlStatement, exportedSince, fleet.QueryComplete, now); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select campaigns to export")
+	}
+	return campaigns, nil
+}
+
+func (ds *Datastore) MarkDistributedQueryCampaignExported(ctx context.Context, id uint, exportedAt time.Time) error {
+	_, err := ds.writer.ExecContext(ctx,
+		`UPDATE distributed_query_campaigns SET exported_at = ? WHERE id = ? AND exported_at IS NULL`,
+		exportedAt, id,
+	)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "mark campaign exported")
+	}
+	return nil
+}
+
 func (ds *Datastore) DistributedQueryCampaignTargetIDs(ctx context.Context, id uint) (*fleet.HostTargets, error) {
 	sqlStatement := `
 		SELECT * FROM distributed_query_campaign_targets WHERE distributed_query_campaign_id = ?
@@ -122,16 +158,19 @@ func (ds *Datastore) NewDistributedQueryCampaignTarget(ctx context.Context, targ
 }
 
 func (ds *Datastore) CleanupDistributedQueryCampaigns(ctx context.Context, now time.Time) (expired uint, err error) {
-	// Expire old waiting/running campaigns
+	// Expire old waiting/running campaigns, the asynchronous campaigns run
+	// until the end of their collection window.
 	sqlStatement := `
 		UPDATE distributed_query_campaigns
 		SET status = ?
 		WHERE (status = ? AND created_at < ?)
-		OR (status = ? AND created_at < ?)
+		OR (status = ? AND collect_until IS NULL AND created_at < ?)
+		OR (status = ? AND collect_until IS NOT NULL AND collect_until <= ?)
 	`
 	result, err := ds.writer.ExecContext(ctx, sqlStatement, fleet.QueryComplete,
 		fleet.QueryWaiting, now.Add(-1*time.Minute),
-		fleet.QueryRunning, now.Add(-24*time.Hour))
+		fleet.QueryRunning, now.Add(-24*time.Hour),
+		fleet.QueryRunning, now)
 	if err != nil {
 		return 0, ctxerr.Wrap(ctx, err, "updating distributed query campaign")
 	}
@@ -143,3 +182,289 @@ func (ds *Datastore) CleanupDistributedQueryCampaigns(ctx context.Context, now t
 
 	return uint(exp), nil
 }
+
+func (ds *Datastore) NewDistributedQueryCampaignResult(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error {
+	// only the first result of a host is kept, the unique index covers the
+	// concurrent results of the same host.
+	var exists bool
+	if err := sqlx.GetContext(ctx, ds.writer, &exists, `
+		SELECT EXISTS (
+			SELECT 1 FROM distributed_query_campaign_results
+			WHERE distributed_query_campaign_id = ? AND host_id = ?
+		)`,
+		result.DistributedQueryCampaignID, result.HostID,
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "check existing campaign result")
+	}
+	if exists {
+		return nil
+	}
+
+	rows := result.Rows
+	truncated := result.Truncated
+	if maxCampaignRows > 0 && len(rows) > 0 {
+		var err error
+		rows, err = ds.reserveCampaignResultRows(ctx, result.DistributedQueryCampaignID, rows, maxCampaignRows)
+		if err != nil {
+			return err
+		}
+		truncated = truncated || len(rows) < len(result.Rows)
+	}
+	if rows == nil {
+		rows = []map[string]string{}
+	}
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "marshal campaign result rows")
+	}
+
+	res, err := ds.writer.ExecContext(ctx, `
+		INSERT IGNORE INTO distributed_query_campaign_results
+			(distributed_query_campaign_id, host_id, hostname, data, row_count, truncated, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		result.DistributedQueryCampaignID, result.HostID, result.Hostname, data, result.RowCount, truncated, result.Error,
+	)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "insert campaign result")
+	}
+	if n, _ := res.RowsAffected(); n == 0 && maxCampaignRows > 0 && len(rows) > 0 {
+		// another result of the host was stored concurrently, give back the
+		// reserved rows.
+		if _, err := ds.writer.ExecContext(ctx, `
+			UPDATE distributed_query_campaign_result_counts SET row_count = row_count - ?
+			WHERE distributed_query_campaign_id = ? AND row_count >= ?`,
+			len(rows), result.DistributedQueryCampaignID, len(rows),
+		); err != nil {
+			return ctxerr.Wrap(ctx, err, "release campaign result count")
+		}
+		return nil
+	}
+
+	// the host of an asynchronous campaign is no longer pending
+	status := fleet.CampaignHostResponded
+	if result.Error != nil {
+		status = fleet.CampaignHostErrored
+	}
+	if _, err := ds.writer.ExecContext(ctx, `
+		UPDATE distributed_query_campaign_hosts SET status = ?, responded_at = CURRENT_TIMESTAMP
+		WHERE distributed_query_campaign_id = ? AND host_id = ? AND status = ?`,
+		status, result.DistributedQueryCampaignID, result.HostID, fleet.CampaignHostPending,
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "update campaign host status")
+	}
+
+	result.Rows = rows
+	result.Truncated = truncated
+	return nil
+}
+
+// reserveCampaignResultRows accounts for the rows of a host result in the
+// rows count of the campaign and returns the rows that fit in the limit.
+//
+// The count row is not locked, so that the results of a large campaign are not
+// serialized: the rows are truncated to the limit according to the count read
+// first, and then added only if the campaign is still below the limit. The
+// limit may thus be exceeded by the results stored concurrently, by at most
+// the rows of a host each.
+func (ds *Datastore) reserveCampaignResultRows(ctx context.Context, campaignID uint, rows []map[string]string, maxCampaignRows int) ([]map[string]string, error) {
+	if _, err := ds.writer.ExecContext(ctx,
+		`INSERT IGNORE INTO distributed_query_campaign_result_counts (distributed_query_campaign_id) VALUES (?)`,
+		campaignID,
+	); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "insert campaign result count")
+	}
+	var campaignRows int
+	if err := sqlx.GetContext(ctx, ds.writer, &campaignRows,
+		`SELECT row_count FROM distributed_query_campaign_result_counts WHERE distributed_query_campaign_id = ?`,
+		campaignID,
+	); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select campaign result count")
+	}
+
+	if remaining := maxCampaignRows - campaignRows; len(rows) > remaining {
+		if remaining <= 0 {
+			return nil, nil
+		}
+		rows = rows[:remaining]
+	}
+
+	res, err := ds.writer.ExecContext(ctx, `
+		UPDATE distributed_query_campaign_result_counts SET row_count = row_count + ?
+		WHERE distributed_query_campaign_id = ? AND row_count < ?`,
+		len(rows), campaignID, maxCampaignRows,
+	)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "update campaign result count")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// the limit was reached concurrently
+		return nil, nil
+	}
+	return rows, nil
+}
+
+func (ds *Datastore) ListDistributedQueryCampaignResults(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) ([]*fleet.DistributedQueryCampaignResult, error) {
+	sqlStatement := `
+		SELECT
+			distributed_query_campaign_id,
+			host_id,
+			hostname,
+			data,
+			row_count,
+			truncated,
+			error,
+			created_at
+		FROM distributed_query_campaign_results
+		WHERE distributed_query_campaign_id = ?
+	`
+	args := []interface{}{campaignID}
+	if opt.HostID != nil {
+		sqlStatement += ` AND host_id = ?`
+		args = append(args, *opt.HostID)
+	}
+	if opt.OnlyErrors {
+		sqlStatement += ` AND error IS NOT NULL`
+	}
+	// the results are always ordered by host, using the unique index.
+	opt.ListOptions.OrderKey = "host_id"
+	opt.ListOptions.OrderDirection = fleet.OrderAscending
+	opt.ListOptions.After = ""
+	sqlStatement = appendListOptionsToSQL(sqlStatement, opt.ListOptions)
+
+	var dbResults []struct {
+		fleet.DistributedQueryCampaignResult
+		Data []byte `db:"data"`
+	}
+	if err := sqlx.SelectContext(ctx, ds.reader, &dbResults, sqlStatement, args...); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select campaign results")
+	}
+
+	results := make([]*fleet.DistributedQueryCampaignResult, 0, len(dbResults))
+	for i := range dbResults {
+		result := dbResults[i].DistributedQueryCampaignResult
+		result.Rows = []map[string]string{}
+		if len(dbResults[i].Data) > 0 {
+			if err := json.Unmarshal(dbResults[i].Data, &result.Rows); err != nil {
+				return nil, ctxerr.Wrap(ctx, err, "unmarshal campaign result rows")
+			}
+		}
+		results = append(results, &result)
+	}
+	return results, nil
+}
+
+// campaignResultsCleanupBatchSize is the maximum number of campaign results
+// deleted by a single statement, to avoid long-running deletes.
+const campaignResultsCleanupBatchSize = 1000
+
+func (ds *Datastore) CleanupDistributedQueryCampaignResults(ctx context.Context, before time.Time) (uint, error) {
+	var deleted uint
+	for {
+		res, err := ds.writer.ExecContext(ctx,
+			`DELETE FROM distributed_query_campaign_results WHERE created_at < ? LIMIT ?`,
+			before, campaignResultsCleanupBatchSize,
+		)
+		if err != nil {
+			return deleted, ctxerr.Wrap(ctx, err, "delete campaign results")
+		}
+		n, _ := res.RowsAffected()
+		deleted += uint(n)
+		if n < campaignResultsCleanupBatchSize {
+			break
+		}
+	}
+
+	// the hosts of an asynchronous campaign are deleted once all its results
+	// expired, that is once its collection window ended before that time.
+	for {
+		res, err := ds.writer.ExecContext(ctx, `
+			DELETE FROM distributed_query_campaign_hosts
+			WHERE distributed_query_campaign_id IN (
+				SELECT id FROM distributed_query_campaigns WHERE collect_until < ?
+			)
+			LIMIT ?`,
+			before, campaignResultsCleanupBatchSize,
+		)
+		if err != nil {
+			return deleted, ctxerr.Wrap(ctx, err, "delete campaign hosts")
+		}
+		if n, _ := res.RowsAffected(); n < campaignResultsCleanupBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// campaignHostsBatchSize is the number of hosts inserted per statement when
+// storing the hosts targeted by an asynchronous campaign.
+const campaignHostsBatchSize = 1000
+
+func (ds *Datastore) NewDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, hostIDs []uint) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		const values = `(?, ?),`
+		for len(hostIDs) > 0 {
+			batch := hostIDs
+			if len(batch) > campaignHostsBatchSize {
+				batch = batch[:campaignHostsBatchSize]
+			}
+			hostIDs = hostIDs[len(batch):]
+
+			args := make([]interface{}, 0, len(batch)*2)
+			for _, hostID := range batch {
+				args = append(args, campaignID, hostID)
+			}
+			stmt := `INSERT IGNORE INTO distributed_query_campaign_hosts (distributed_query_campaign_id, host_id) VALUES ` +
+				strings.TrimSuffix(strings.Repeat(values, len(batch)), ",")
+			if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+				return ctxerr.Wrap(ctx, err, "insert campaign hosts")
+			}
+		}
+		return nil
+	})
+}
+
+func (ds *Datastore) ListDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) ([]*fleet.DistributedQueryCampaignHost, error) {
+	sqlStatement := `
+		SELECT
+			dqch.host_id,
+			COALESCE(h.hostname, '') AS hostname,
+			dqch.status,
+			dqch.responded_at
+		FROM distributed_query_campaign_hosts dqch
+		LEFT JOIN hosts h ON h.id = dqch.host_id
+		WHERE dqch.distributed_query_campaign_id = ?
+	`
+	args := []interface{}{campaignID}
+	if opt.Status != "" {
+		sqlStatement += ` AND dqch.status = ?`
+		args = append(args, opt.Status)
+	}
+	// the hosts are always ordered by host, using the primary key.
+	opt.ListOptions.OrderKey = "dqch.host_id"
+	opt.ListOptions.OrderDirection = fleet.OrderAscending
+	opt.ListOptions.After = ""
+	sqlStatement = appendListOptionsToSQL(sqlStatement, opt.ListOptions)
+
+	hosts := []*fleet.DistributedQueryCampaignHost{}
+	if err := sqlx.SelectContext(ctx, ds.reader, &hosts, sqlStatement, args...); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select campaign hosts")
+	}
+	return hosts, nil
+}
+
+func (ds *Datastore) CountDistributedQueryCampaignHosts(ctx context.Context, campaignID uint) (*fleet.CampaignHostCounts, error) {
+	sqlStatement := `
+		SELECT
+			COALESCE(SUM(status = ?), 0) AS pending,
+			COALESCE(SUM(status = ?), 0) AS responded,
+			COALESCE(SUM(status = ?), 0) AS errored
+		FROM distributed_query_campaign_hosts
+		WHERE distributed_query_campaign_id = ?
+	`
+	var counts fleet.CampaignHostCounts
+	if err := sqlx.GetContext(ctx, ds.reader, &counts, sqlStatement,
+		fleet.CampaignHostPending, fleet.CampaignHostResponded, fleet.CampaignHostErrored, campaignID,
+	); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "count campaign hosts")
+	}
+	return &counts, nil
+}
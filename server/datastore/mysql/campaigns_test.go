@@ -2,11 +2,13 @@ package mysql
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/WatchBeam/clock"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,6 +24,10 @@ func TestCampaigns(t *testing.T) {
 		{"DistributedQuery", testCampaignsDistributedQuery},
 		{"CleanupDistributedQuery", testCampaignsCleanupDistributedQuery},
 		{"SaveDistributedQuery", testCampaignsSaveDistributedQuery},
+		{"DistributedQueryResults", testCampaignsDistributedQueryResults},
+		{"CleanupDistributedQueryResults", testCampaignsCleanupDistributedQueryResults},
+		{"AsyncDistributedQuery", testCampaignsAsyncDistributedQuery},
+		{"DistributedQueryExport", testCampaignsDistributedQueryExport},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -43,6 +49,9 @@ func testCampaignsDistributedQuery(t *testing.T, ds *Datastore) {
 		require.Nil(t, err)
 		assert.Equal(t, campaign.QueryID, retrieved.QueryID)
 		assert.Equal(t, campaign.Status, retrieved.Status)
+
+		_, err = ds.DistributedQueryCampaign(context.Background(), campaign.ID+1000)
+		require.True(t, fleet.IsNotFound(err))
 	}
 
 	h1 := test.NewHost(t, ds, "foo.local", "192.168.1.10", "1", "1", mockClock.Now())
@@ -180,3 +189,301 @@ func checkTargets(t *testing.T, ds fleet.Datastore, campaignID uint, expectedTar
 	assert.ElementsMatch(t, expectedTargets.LabelIDs, targets.LabelIDs)
 	assert.ElementsMatch(t, expectedTargets.TeamIDs, targets.TeamIDs)
 }
+
+func testCampaignsDistributedQueryResults(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Zach", "zwass@fleet.co", true)
+	query := test.NewQuery(t, ds, "test", "select * from time", user.ID, false)
+	campaign := test.NewCampaign(t, ds, query.ID, fleet.QueryRunning, time.Now())
+	other := test.NewCampaign(t, ds, query.ID, fleet.QueryRunning, time.Now())
+
+	newRows := func(n int) []map[string]string {
+		rows := []map[string]string{}
+		for i := 0; i < n; i++ {
+			rows = append(rows, map[string]string{"i": fmt.Sprint(i)})
+		}
+		return rows
+	}
+	newResult := func(campaignID, hostID uint, rows int) *fleet.DistributedQueryCampaignResult {
+		return &fleet.DistributedQueryCampaignResult{
+			DistributedQueryCampaignID: campaignID,
+			HostID:                     hostID,
+			Hostname:                   fmt.Sprintf("host%d.local", hostID),
+			Rows:                       newRows(rows),
+			RowCount:                   rows,
+		}
+	}
+
+	// the campaign stores at most 5 rows
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(campaign.ID, 3, 2), 5))
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(campaign.ID, 1, 2), 5))
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(campaign.ID, 2, 2), 5))
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(campaign.ID, 4, 2), 5))
+	failed := newResult(campaign.ID, 5, 0)
+	failed.Error = ptr.String("no such table")
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, failed, 5))
+	// only the first result of a host is kept
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(campaign.ID, 1, 1), 5))
+	// the limit is per campaign
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(other.ID, 1, 3), 5))
+
+	results, err := ds.ListDistributedQueryCampaignResults(ctx, campaign.ID, fleet.ListCampaignResultsOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	var hostIDs []uint
+	var stored []int
+	var truncated []bool
+	for _, r := range results {
+		hostIDs = append(hostIDs, r.HostID)
+		stored = append(stored, len(r.Rows))
+		truncated = append(truncated, r.Truncated)
+		assert.Equal(t, fmt.Sprintf("host%d.local", r.HostID), r.Hostname)
+	}
+	assert.Equal(t, []uint{1, 2, 3, 4, 5}, hostIDs)
+	// hosts 3, 1 and 2 were stored in that order
+	assert.Equal(t, []int{2, 1, 2, 0, 0}, stored)
+	assert.Equal(t, []bool{false, true, false, true, false}, truncated)
+	assert.Equal(t, 2, results[1].RowCount)
+	assert.Equal(t, []map[string]string{{"i": "0"}, {"i": "1"}}, results[0].Rows)
+	assert.Nil(t, results[0].Error)
+	require.NotNil(t, results[4].Error)
+	assert.Equal(t, "no such table", *results[4].Error)
+
+	results, err = ds.ListDistributedQueryCampaignResults(ctx, campaign.ID, fleet.ListCampaignResultsOptions{
+		ListOptions: fleet.ListOptions{Page: 1, PerPage: 2},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, uint(3), results[0].HostID)
+	assert.Equal(t, uint(4), results[1].HostID)
+
+	results, err = ds.ListDistributedQueryCampaignResults(ctx, campaign.ID, fleet.ListCampaignResultsOptions{HostID: ptr.Uint(2)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, uint(2), results[0].HostID)
+
+	results, err = ds.ListDistributedQueryCampaignResults(ctx, campaign.ID, fleet.ListCampaignResultsOptions{OnlyErrors: true})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, uint(5), results[0].HostID)
+
+	results, err = ds.ListDistributedQueryCampaignResults(ctx, other.ID, fleet.ListCampaignResultsOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Rows, 3)
+	assert.False(t, results[0].Truncated)
+
+	// without a limit, all the rows are stored
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, newResult(other.ID, 2, 10), 0))
+	results, err = ds.ListDistributedQueryCampaignResults(ctx, other.ID, fleet.ListCampaignResultsOptions{HostID: ptr.Uint(2)})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Rows, 10)
+}
+
+func testCampaignsCleanupDistributedQueryResults(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Zach", "zwass@fleet.co", true)
+	query := test.NewQuery(t, ds, "test", "select * from time", user.ID, false)
+	campaign := test.NewCampaign(t, ds, query.ID, fleet.QueryComplete, time.Now())
+
+	for hostID := uint(1); hostID <= 3; hostID++ {
+		require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, &fleet.DistributedQueryCampaignResult{
+			DistributedQueryCampaignID: campaign.ID,
+			HostID:                     hostID,
+		}, 0))
+	}
+	_, err := ds.writer.ExecContext(ctx,
+		`UPDATE distributed_query_campaign_results SET created_at = ? WHERE host_id < 3`,
+		time.Now().Add(-48*time.Hour),
+	)
+	require.NoError(t, err)
+
+	deleted, err := ds.CleanupDistributedQueryCampaignResults(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), deleted)
+
+	results, err := ds.ListDistributedQueryCampaignResults(ctx, campaign.ID, fleet.ListCampaignResultsOptions{})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, uint(3), results[0].HostID)
+	assert.Empty(t, results[0].Rows)
+}
+
+func testCampaignsAsyncDistributedQuery(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Zach", "zwass@fleet.co", true)
+	query := test.NewQuery(t, ds, "test", "select * from time", user.ID, false)
+	host1 := test.NewHost(t, ds, "host1.local", "10.0.0.1", "1", "1", time.Now())
+	host2 := test.NewHost(t, ds, "host2.local", "10.0.0.2", "2", "2", time.Now())
+	host3 := test.NewHost(t, ds, "host3.local", "10.0.0.3", "3", "3", time.Now())
+
+	now := time.Now().UTC().Truncate(time.Second)
+	campaign, err := ds.NewDistributedQueryCampaign(ctx, &fleet.DistributedQueryCampaign{
+		QueryID:      query.ID,
+		Status:       fleet.QueryRunning,
+		UserID:       user.ID,
+		CollectUntil: ptr.Time(now.Add(48 * time.Hour)),
+		ExportFormat: fleet.LiveQueryExportCSV,
+	})
+	require.NoError(t, err)
+	campaign, err = ds.DistributedQueryCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.LiveQueryExportCSV, campaign.ExportFormat)
+	require.NotNil(t, campaign.CollectUntil)
+	assert.Equal(t, now.Add(48*time.Hour), campaign.CollectUntil.UTC())
+	assert.True(t, campaign.IsCollecting(now))
+	assert.False(t, campaign.IsCollecting(now.Add(48*time.Hour)))
+
+	// the host of the unknown host ID 999 is listed without a hostname
+	require.NoError(t, ds.NewDistributedQueryCampaignHosts(ctx, campaign.ID, []uint{host1.ID, host2.ID, host3.ID, 999}))
+	// storing the hosts again is a no-op
+	require.NoError(t, ds.NewDistributedQueryCampaignHosts(ctx, campaign.ID, []uint{host1.ID}))
+
+	counts, err := ds.CountDistributedQueryCampaignHosts(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.CampaignHostCounts{Pending: 4}, *counts)
+
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, &fleet.DistributedQueryCampaignResult{
+		DistributedQueryCampaignID: campaign.ID,
+		HostID:                     host1.ID,
+		Hostname:                   host1.Hostname,
+		Rows:                       []map[string]string{{"a": "b"}},
+		RowCount:                   1,
+	}, 0))
+	require.NoError(t, ds.NewDistributedQueryCampaignResult(ctx, &fleet.DistributedQueryCampaignResult{
+		DistributedQueryCampaignID: campaign.ID,
+		HostID:                     host2.ID,
+		Hostname:                   host2.Hostname,
+		Error:                      ptr.String("no such table"),
+	}, 0))
+
+	counts, err = ds.CountDistributedQueryCampaignHosts(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.CampaignHostCounts{Pending: 2, Responded: 1, Errored: 1}, *counts)
+
+	hosts, err := ds.ListDistributedQueryCampaignHosts(ctx, campaign.ID, fleet.ListCampaignHostsOptions{})
+	require.NoError(t, err)
+	require.Len(t, hosts, 4)
+	assert.Equal(t, host1.ID, hosts[0].HostID)
+	assert.Equal(t, "host1.local", hosts[0].Hostname)
+	assert.Equal(t, fleet.CampaignHostResponded, hosts[0].Status)
+	assert.NotNil(t, hosts[0].RespondedAt)
+	assert.Equal(t, fleet.CampaignHostErrored, hosts[1].Status)
+	assert.NotNil(t, hosts[1].RespondedAt)
+	assert.Equal(t, fleet.CampaignHostPending, hosts[2].Status)
+	assert.Nil(t, hosts[2].RespondedAt)
+	assert.Equal(t, "host3.local", hosts[2].Hostname)
+	assert.Equal(t, uint(999), hosts[3].HostID)
+	assert.Equal(t, "", hosts[3].Hostname)
+
+	for status, want := range map[fleet.CampaignHostStatus][]uint{
+		fleet.CampaignHostPending:   {host3.ID, 999},
+		fleet.CampaignHostResponded: {host1.ID},
+		fleet.CampaignHostErrored:   {host2.ID},
+	} {
+		hosts, err := ds.ListDistributedQueryCampaignHosts(ctx, campaign.ID, fleet.ListCampaignHostsOptions{Status: status})
+		require.NoError(t, err)
+		var got []uint
+		for _, h := range hosts {
+			got = append(got, h.HostID)
+		}
+		assert.Equal(t, want, got, string(status))
+	}
+
+	hosts, err = ds.ListDistributedQueryCampaignHosts(ctx, campaign.ID, fleet.ListCampaignHostsOptions{
+		ListOptions: fleet.ListOptions{Page: 1, PerPage: 3},
+	})
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, uint(999), hosts[0].HostID)
+
+	// deleting a host removes it from the campaign
+	require.NoError(t, ds.DeleteHost(ctx, host3.ID))
+	counts, err = ds.CountDistributedQueryCampaignHosts(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.CampaignHostCounts{Pending: 1, Responded: 1, Errored: 1}, *counts)
+
+	// the asynchronous campaign is not expired after a day, only once its
+	// collection window is over
+	expired, err := ds.CleanupDistributedQueryCampaigns(ctx, now.Add(25*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uint(0), expired)
+	campaign, err = ds.DistributedQueryCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.QueryRunning, campaign.Status)
+
+	expired, err = ds.CleanupDistributedQueryCampaigns(ctx, now.Add(48*time.Hour+time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), expired)
+	campaign, err = ds.DistributedQueryCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.QueryComplete, campaign.Status)
+
+	// the status of the hosts outlives their results, until the collection
+	// window ended before the retention period
+	deleted, err := ds.CleanupDistributedQueryCampaignResults(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), deleted)
+	counts, err = ds.CountDistributedQueryCampaignHosts(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.CampaignHostCounts{Pending: 1, Responded: 1, Errored: 1}, *counts)
+
+	_, err = ds.CleanupDistributedQueryCampaignResults(ctx, now.Add(48*time.Hour+time.Second))
+	require.NoError(t, err)
+	counts, err = ds.CountDistributedQueryCampaignHosts(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, fleet.CampaignHostCounts{}, *counts)
+}
+
+func testCampaignsDistributedQueryExport(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Zach", "zwass@fleet.co", true)
+	query := test.NewQuery(t, ds, "test", "select * from time", user.ID, false)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	newCampaign := func(status fleet.DistributedQueryStatus, format fleet.LiveQueryExportFormat, collectUntil *time.Time) uint {
+		campaign, err := ds.NewDistributedQueryCampaign(ctx, &fleet.DistributedQueryCampaign{
+			QueryID:      query.ID,
+			Status:       status,
+			UserID:       user.ID,
+			CollectUntil: collectUntil,
+			ExportFormat: format,
+		})
+		require.NoError(t, err)
+		return campaign.ID
+	}
+	listIDs := func(exportedSince time.Time) []uint {
+		campaigns, err := ds.ListDistributedQueryCampaignsToExport(ctx, now, exportedSince)
+		require.NoError(t, err)
+		ids := []uint{}
+		for _, c := range campaigns {
+			ids = append(ids, c.ID)
+		}
+		return ids
+	}
+
+	complete := newCampaign(fleet.QueryComplete, fleet.LiveQueryExportCSV, nil)
+	newCampaign(fleet.QueryComplete, "", nil)
+	newCampaign(fleet.QueryRunning, fleet.LiveQueryExportCSV, nil)
+	newCampaign(fleet.QueryRunning, fleet.LiveQueryExportNDJSON, ptr.Time(now.Add(time.Hour)))
+	// the collection window of the asynchronous campaign ended
+	collected := newCampaign(fleet.QueryRunning, fleet.LiveQueryExportNDJSON, ptr.Time(now.Add(-time.Minute)))
+
+	assert.Equal(t, []uint{complete, collected}, listIDs(now))
+
+	// the campaigns are listed until they were exported before exportedSince
+	require.NoError(t, ds.MarkDistributedQueryCampaignExported(ctx, complete, now))
+	campaign, err := ds.DistributedQueryCampaign(ctx, complete)
+	require.NoError(t, err)
+	require.NotNil(t, campaign.ExportedAt)
+	assert.Equal(t, now, campaign.ExportedAt.UTC())
+	assert.Equal(t, []uint{complete, collected}, listIDs(now))
+	assert.Equal(t, []uint{collected}, listIDs(now.Add(time.Second)))
+
+	// the first export time is kept
+	require.NoError(t, ds.MarkDistributedQueryCampaignExported(ctx, complete, now.Add(time.Hour)))
+	campaign, err = ds.DistributedQueryCampaign(ctx, complete)
+	require.NoError(t, err)
+	assert.Equal(t, now, campaign.ExportedAt.UTC())
+}
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/jmoiron/sqlx"
+)
+
+const customRoleColumns = `id, created_at, updated_at, name, description, permissions`
+
+func (ds *Datastore) ApplyCustomRoleSpecs(ctx context.Context, specs []*fleet.CustomRoleSpec) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		sqlStatement := `
+		INSERT INTO custom_roles (
+			name,
+			description,
+			permissions
+		) VALUES ( ?, ?, ? )
+		ON DUPLICATE KEY UPDATE
+			description = VALUES(description),
+			permissions = VALUES(permissions)
+	`
+		for _, s := range specs {
+			if s.Name == "" {
+				return ctxerr.New(ctx, "custom role name must not be empty")
+			}
+			if _, err := tx.ExecContext(ctx, sqlStatement, s.Name, s.Description, s.Permissions); err != nil {
+				return ctxerr.Wrap(ctx, err, "exec ApplyCustomRoleSpecs insert")
+			}
+		}
+		return nil
+	})
+}
+
+func (ds *Datastore) ListCustomRoles(ctx context.Context) ([]*fleet.CustomRole, error) {
+	roles := []*fleet.CustomRole{}
+	if err := sqlx.SelectContext(ctx, ds.reader, &roles, `SELECT `+customRoleColumns+` FROM custom_roles ORDER BY name`); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "selecting custom roles")
+	}
+	return roles, nil
+}
+
+func (ds *Datastore) CustomRoleByName(ctx context.Context, name string) (*fleet.CustomRole, error) {
+	var role fleet.CustomRole
+	err := sqlx.GetContext(ctx, ds.reader, &role, `SELECT `+customRoleColumns+` FROM custom_roles WHERE name = ?`, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("CustomRole").WithName(name))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "selecting custom role by name")
+	}
+	return &role, nil
+}
+
+func (ds *Datastore) DeleteCustomRole(ctx context.Context, name string) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		// the roles are stored by name in the role columns, there are no
+		// foreign keys to prevent deleting a role that is still assigned.
+		var inUse bool
+		err := sqlx.GetContext(ctx, tx, &inUse, `
+		SELECT
+			EXISTS (SELECT 1 FROM users WHERE global_role = ?) OR
+			EXISTS (SELECT 1 FROM user_teams WHERE role = ?) OR
+			EXISTS (SELECT 1 FROM invites WHERE global_role = ?) OR
+			EXISTS (SELECT 1 FROM invite_teams WHERE role = ?)
+	`, name, name, name, name)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "check custom role usage")
+		}
+		if inUse {
+			return ctxerr.Wrap(ctx, foreignKey("CustomRole", name))
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM custom_roles WHERE name = ?`, name)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "delete custom role")
+		}
+		rows, _ := res.RowsAffected()
+		if rows == 0 {
+			return ctxerr.Wrap(ctx, notFound("CustomRole").WithName(name))
+		}
+		return nil
+	})
+}
+
+// validateRole validates the global and team roles of a user or an invite.
+// The names of the custom roles are only loaded if one of the roles is not a
+// built-in role.
+func validateRole(ctx context.Context, q sqlx.QueryerContext, globalRole *string, teams []fleet.UserTeam) error {
+	var customRoles map[string]bool
+	if (&fleet.User{GlobalRole: globalRole, Teams: teams}).HasCustomRole() {
+		var names []string
+		if err := sqlx.SelectContext(ctx, q, &names, `SELECT name FROM custom_roles`); err != nil {
+			return ctxerr.Wrap(ctx, err, "selecting custom role names")
+		}
+		customRoles = make(map[string]bool, len(names))
+		for _, name := range names {
+			customRoles[name] = true
+		}
+	}
+	return fleet.ValidateRoleWithCustomRoles(globalRole, teams, customRoles)
+}
@@ -0,0 +1,131 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomRoles(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"Apply", testCustomRolesApply},
+		{"AssignAndDelete", testCustomRolesAssignAndDelete},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testCustomRolesApply(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	roles, err := ds.ListCustomRoles(ctx)
+	require.NoError(t, err)
+	require.Empty(t, roles)
+
+	err = ds.ApplyCustomRoleSpecs(ctx, []*fleet.CustomRoleSpec{
+		{
+			Name:        "query author",
+			Description: "Writes queries",
+			Permissions: fleet.CustomRolePermissions{{Object: "query", Actions: []string{"read", "write"}}},
+		},
+		{
+			Name:        "helpdesk",
+			Permissions: fleet.CustomRolePermissions{{Object: "host", Actions: []string{"read", "list"}}},
+		},
+	})
+	require.NoError(t, err)
+
+	roles, err = ds.ListCustomRoles(ctx)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+	assert.Equal(t, "helpdesk", roles[0].Name)
+	assert.Equal(t, "query author", roles[1].Name)
+	assert.Equal(t, "Writes queries", roles[1].Description)
+	assert.Equal(t, fleet.CustomRolePermissions{{Object: "query", Actions: []string{"read", "write"}}}, roles[1].Permissions)
+
+	// applying again updates the existing role
+	err = ds.ApplyCustomRoleSpecs(ctx, []*fleet.CustomRoleSpec{
+		{
+			Name:        "helpdesk",
+			Description: "Helps",
+			Permissions: fleet.CustomRolePermissions{
+				{Object: "host", Actions: []string{"read", "list"}},
+				{Object: "policy", Actions: []string{"read"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	role, err := ds.CustomRoleByName(ctx, "helpdesk")
+	require.NoError(t, err)
+	assert.Equal(t, roles[0].ID, role.ID)
+	assert.Equal(t, "Helps", role.Description)
+	assert.Len(t, role.Permissions, 2)
+
+	_, err = ds.CustomRoleByName(ctx, "nope")
+	require.True(t, fleet.IsNotFound(err))
+
+	require.NoError(t, ds.DeleteCustomRole(ctx, "helpdesk"))
+	_, err = ds.CustomRoleByName(ctx, "helpdesk")
+	require.True(t, fleet.IsNotFound(err))
+	err = ds.DeleteCustomRole(ctx, "helpdesk")
+	require.True(t, fleet.IsNotFound(err))
+}
+
+func testCustomRolesAssignAndDelete(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	team, err := ds.NewTeam(ctx, &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+
+	// unknown custom roles can't be assigned
+	_, err = ds.NewUser(ctx, &fleet.User{Name: "Alice", Email: "alice@example.com", Password: []byte("foo"), GlobalRole: ptr.String("helpdesk")})
+	require.Error(t, err)
+
+	err = ds.ApplyCustomRoleSpecs(ctx, []*fleet.CustomRoleSpec{
+		{Name: "helpdesk", Permissions: fleet.CustomRolePermissions{{Object: "host", Actions: []string{"read"}}}},
+	})
+	require.NoError(t, err)
+
+	user, err := ds.NewUser(ctx, &fleet.User{Name: "Alice", Email: "alice@example.com", Password: []byte("foo"), GlobalRole: ptr.String("helpdesk")})
+	require.NoError(t, err)
+
+	// the role can't be deleted while it is assigned
+	err = ds.DeleteCustomRole(ctx, "helpdesk")
+	require.Error(t, err)
+	assert.True(t, fleet.IsForeignKey(err))
+
+	// assign it on a team instead
+	user.GlobalRole = nil
+	user.Teams = []fleet.UserTeam{{Team: *team, Role: "helpdesk"}}
+	require.NoError(t, ds.SaveUser(ctx, user))
+	user, err = ds.UserByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.Len(t, user.Teams, 1)
+	assert.Equal(t, "helpdesk", user.Teams[0].Role)
+
+	err = ds.DeleteCustomRole(ctx, "helpdesk")
+	require.Error(t, err)
+	assert.True(t, fleet.IsForeignKey(err))
+
+	user.Teams = []fleet.UserTeam{{Team: *team, Role: fleet.RoleObserver}}
+	require.NoError(t, ds.SaveUser(ctx, user))
+	require.NoError(t, ds.DeleteCustomRole(ctx, "helpdesk"))
+
+	// now that it is deleted, it can't be assigned anymore
+	user.Teams = []fleet.UserTeam{{Team: *team, Role: "helpdesk"}}
+	require.Error(t, ds.SaveUser(ctx, user))
+}
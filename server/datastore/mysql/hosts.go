@@ -293,6 +293,8 @@ var hostRefs = []string{
 	"host_operating_system",
 	"host_munki_issues",
 	"windows_updates",
+	"query_report_rows",
+	"distributed_query_campaign_hosts",
 }
 
 func (ds *Datastore) DeleteHost(ctx context.Context, hid uint) error {
@@ -1104,30 +1106,34 @@ func saveHostUsersDB(ctx context.Context, tx sqlx.ExtContext, hostID uint, users
 	return nil
 }
 
-func (ds *Datastore) TotalAndUnseenHostsSince(ctx context.Context, daysCount int) (total int, unseen int, err error) {
+func (ds *Datastore) TotalAndUnseenHostsSince(ctx context.Context, teamID *uint, daysCount int) (total int, unseen int, err error) {
 	var counts struct {
-		Total  int `db:"total"`
-		Unseen int `db:"unseen"`
+		Total  int           `db:"total"`
+		Unseen sql.NullInt64 `db:"unseen"`
 	}
 
 	// convert daysCount to integer number of seconds for more precision in sql query
 	unseenSeconds := daysCount * 24 * 60 * 60
 
-	err = sqlx.GetContext(ctx, ds.reader, &counts,
-		`SELECT
+	query := `SELECT
 			COUNT(*) as total,
 			SUM(IF(TIMESTAMPDIFF(SECOND, COALESCE(hst.seen_time, h.created_at), CURRENT_TIMESTAMP) >= ?, 1, 0)) as unseen
 		FROM hosts h
 		LEFT JOIN host_seen_times hst
-		ON h.id = hst.host_id`,
-		unseenSeconds,
-	)
+		ON h.id = hst.host_id`
+	args := []interface{}{unseenSeconds}
+	if teamID != nil {
+		query += ` WHERE h.team_id = ?`
+		args = append(args, *teamID)
+	}
+
+	err = sqlx.GetContext(ctx, ds.reader, &counts, query, args...)
 
 	if err != nil {
 		return 0, 0, ctxerr.Wrap(ctx, err, "getting total and unseen host counts")
 	}
 
-	return counts.Total, counts.Unseen, nil
+	return counts.Total, int(counts.Unseen.Int64), nil
 }
 
 func (ds *Datastore) DeleteHosts(ctx context.Context, ids []uint) error {
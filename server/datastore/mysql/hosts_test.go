@@ -1955,7 +1955,7 @@ func addHostSeenLast(t *testing.T, ds fleet.Datastore, i, days int) {
 func testHostsTotalAndUnseenSince(t *testing.T, ds *Datastore) {
 	addHostSeenLast(t, ds, 1, 0)
 
-	total, unseen, err := ds.TotalAndUnseenHostsSince(context.Background(), 1)
+	total, unseen, err := ds.TotalAndUnseenHostsSince(context.Background(), nil, 1)
 	require.NoError(t, err)
 	assert.Equal(t, 1, total)
 	assert.Equal(t, 0, unseen)
@@ -1963,7 +1963,7 @@ func testHostsTotalAndUnseenSince(t *testing.T, ds *Datastore) {
 	addHostSeenLast(t, ds, 2, 2)
 	addHostSeenLast(t, ds, 3, 4)
 
-	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), 1)
+	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), nil, 1)
 	require.NoError(t, err)
 	assert.Equal(t, 3, total)
 	assert.Equal(t, 2, unseen)
@@ -1972,7 +1972,7 @@ func testHostsTotalAndUnseenSince(t *testing.T, ds *Datastore) {
 	_, err = ds.writer.ExecContext(context.Background(), `UPDATE host_seen_times SET seen_time = ? WHERE host_id = 2`, time.Now().Add(-1*time.Duration(1)*86399*time.Second))
 	require.NoError(t, err)
 
-	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), 1)
+	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), nil, 1)
 	require.NoError(t, err)
 	assert.Equal(t, 3, total)
 	assert.Equal(t, 1, unseen)
@@ -1981,10 +1981,30 @@ func testHostsTotalAndUnseenSince(t *testing.T, ds *Datastore) {
 	_, err = ds.writer.ExecContext(context.Background(), `UPDATE host_seen_times SET seen_time = ? WHERE host_id = 2`, time.Now().Add(-1*time.Duration(1)*86401*time.Second))
 	require.NoError(t, err)
 
-	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), 1)
+	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), nil, 1)
 	require.NoError(t, err)
 	assert.Equal(t, 3, total)
 	assert.Equal(t, 2, unseen)
+
+	// only the hosts of the team are counted when a team is provided
+	team, err := ds.NewTeam(context.Background(), &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+
+	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), &team.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, 0, unseen)
+
+	h1, err := ds.HostByIdentifier(context.Background(), "1")
+	require.NoError(t, err)
+	h3, err := ds.HostByIdentifier(context.Background(), "3")
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(context.Background(), &team.ID, []uint{h1.ID, h3.ID}))
+
+	total, unseen, err = ds.TotalAndUnseenHostsSince(context.Background(), &team.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, unseen)
 }
 
 func testHostsListByPolicy(t *testing.T, ds *Datastore) {
@@ -3558,7 +3578,7 @@ func testHostsNoSeenTime(t *testing.T, ds *Datastore) {
 	require.Equal(t, h1.ID, foundHosts[1].ID)
 	require.Equal(t, foundHosts[1].SeenTime, foundHosts[1].CreatedAt)
 
-	total, unseen, err := ds.TotalAndUnseenHostsSince(context.Background(), 1)
+	total, unseen, err := ds.TotalAndUnseenHostsSince(context.Background(), nil, 1)
 	require.NoError(t, err)
 	require.Equal(t, total, 2)
 	require.Equal(t, unseen, 0)
@@ -4779,6 +4799,13 @@ func testHostsDeleteHosts(t *testing.T, ds *Datastore) {
 	stmt := `INSERT INTO windows_updates (host_id, date_epoch, kb_id) VALUES (?, ?, ?)`
 	_, err = ds.writer.Exec(stmt, host.ID, 1, 123)
 	require.NoError(t, err)
+	// Update query_report_rows
+	err = ds.SaveQueryReportRows(context.Background(), squery.ID, host.ID, []map[string]string{{"hour": "1"}}, time.Now(), 0)
+	require.NoError(t, err)
+	// Update distributed_query_campaign_hosts
+	campaign := test.NewCampaign(t, ds, query.ID, fleet.QueryRunning, time.Now())
+	err = ds.NewDistributedQueryCampaignHosts(context.Background(), campaign.ID, []uint{host.ID})
+	require.NoError(t, err)
 	// Check there's an entry for the host in all the associated tables.
 	for _, hostRef := range hostRefs {
 		var ok bool
@@ -15,7 +15,7 @@ var inviteSearchColumns = []string{"name", "email"}
 
 // NewInvite generates a new invitation.
 func (ds *Datastore) NewInvite(ctx context.Context, i *fleet.Invite) (*fleet.Invite, error) {
-	if err := fleet.ValidateRole(i.GlobalRole.Ptr(), i.Teams); err != nil {
+	if err := validateRole(ctx, ds.writer, i.GlobalRole.Ptr(), i.Teams); err != nil {
 		return nil, err
 	}
 
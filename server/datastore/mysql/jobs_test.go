@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"QueueAndProcess", testJobsQueueAndProcess},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testJobsQueueAndProcess(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	jobs, err := ds.GetQueuedJobs(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+
+	j1, err := ds.NewJob(ctx, &fleet.Job{Name: "j1", State: fleet.JobStateQueued})
+	require.NoError(t, err)
+	// j2 is delayed in the future
+	j2, err := ds.NewJob(ctx, &fleet.Job{Name: "j2", State: fleet.JobStateQueued, NotBefore: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	jobs, err = ds.GetQueuedJobs(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, j1.ID, jobs[0].ID)
+
+	// delay j1 in the future, and make j2 ready
+	j1.Retries = 1
+	j1.NotBefore = time.Now().Add(time.Hour)
+	_, err = ds.UpdateJob(ctx, j1.ID, j1)
+	require.NoError(t, err)
+	j2.NotBefore = time.Now().Add(-time.Minute)
+	_, err = ds.UpdateJob(ctx, j2.ID, j2)
+	require.NoError(t, err)
+
+	jobs, err = ds.GetQueuedJobs(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	require.Equal(t, j2.ID, jobs[0].ID)
+
+	// mark j2 as done, no more job to process
+	j2.State = fleet.JobStateSuccess
+	_, err = ds.UpdateJob(ctx, j2.ID, j2)
+	require.NoError(t, err)
+
+	jobs, err = ds.GetQueuedJobs(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
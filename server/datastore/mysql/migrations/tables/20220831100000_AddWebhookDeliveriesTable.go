@@ -0,0 +1,50 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220831100000, Down_20220831100000)
+}
+
+func Up_20220831100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE webhook_deliveries (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    webhook_type VARCHAR(255) NOT NULL,
+    team_id INT UNSIGNED NULL,
+    url TEXT NOT NULL,
+    payload JSON NOT NULL,
+    status VARCHAR(255) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_status_code INT NULL,
+    last_latency_ms BIGINT NULL,
+    last_attempt_at TIMESTAMP NULL,
+    error TEXT,
+
+    KEY idx_webhook_deliveries_status (status),
+    KEY idx_webhook_deliveries_created_at (created_at)
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create table")
+	}
+
+	// not_before is used to delay the next attempt of a failed job, for
+	// exponential backoff.
+	_, err = tx.Exec(`ALTER TABLE jobs ADD COLUMN not_before TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP`)
+	if err != nil {
+		return errors.Wrapf(err, "add not_before to jobs")
+	}
+
+	return nil
+}
+
+func Down_20220831100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,35 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220831100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	// insert a job before the migration, it should get a not_before value
+	_, err := db.Exec(`INSERT INTO jobs (name, args, state, retries, error) VALUES (?, ?, ?, ?, ?)`, "test", nil, "queued", 0, "")
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	var count int
+	err = db.Get(&count, `SELECT COUNT(*) FROM jobs WHERE not_before <= NOW()`)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	query := `
+INSERT INTO webhook_deliveries (
+    webhook_type,
+    team_id,
+    url,
+    payload,
+    status
+)
+VALUES (?, ?, ?, ?, ?)
+`
+	_, err = db.Exec(query, "host_status", nil, "http://example.com", `{"text": "test"}`, "pending")
+	require.NoError(t, err)
+}
@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220902100000, Down_20220902100000)
+}
+
+func Up_20220902100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE policy_automation_tickets (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    policy_id INT UNSIGNED NOT NULL,
+    host_id INT UNSIGNED NOT NULL,
+    team_id INT UNSIGNED NULL,
+    integration VARCHAR(255) NOT NULL,
+    ticket_id VARCHAR(255) NOT NULL,
+    resolved_at TIMESTAMP NULL,
+
+    KEY idx_policy_automation_tickets_policy_host (policy_id, host_id),
+    KEY idx_policy_automation_tickets_ticket (integration, ticket_id),
+    CONSTRAINT fk_policy_automation_tickets_policy_id FOREIGN KEY (policy_id) REFERENCES policies (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create table")
+	}
+	return nil
+}
+
+func Down_20220902100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220902100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO policies (name, query, description) VALUES (?, ?, ?)`, "p1", "SELECT 1", "")
+	require.NoError(t, err)
+	policyID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	query := `
+INSERT INTO policy_automation_tickets (
+    policy_id,
+    host_id,
+    team_id,
+    integration,
+    ticket_id
+)
+VALUES (?, ?, ?, ?, ?)
+`
+	_, err = db.Exec(query, policyID, 1, nil, "jira", "PROJ-1")
+	require.NoError(t, err)
+
+	// deleting the policy deletes its tickets
+	_, err = db.Exec(`DELETE FROM policies WHERE id = ?`, policyID)
+	require.NoError(t, err)
+	var count int
+	err = db.Get(&count, `SELECT COUNT(*) FROM policy_automation_tickets`)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
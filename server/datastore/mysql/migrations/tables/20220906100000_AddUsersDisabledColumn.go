@@ -0,0 +1,23 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220906100000, Down_20220906100000)
+}
+
+func Up_20220906100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`ALTER TABLE users ADD COLUMN disabled TINYINT(1) NOT NULL DEFAULT 0`)
+	if err != nil {
+		return errors.Wrapf(err, "add disabled to users")
+	}
+	return nil
+}
+
+func Down_20220906100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220906100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	_, err := db.Exec(`INSERT INTO users (name, email, password, salt) VALUES (?, ?, ?, ?)`, "u1", "u1@example.com", "", "")
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	// existing users are not disabled
+	var disabled bool
+	err = db.Get(&disabled, `SELECT disabled FROM users WHERE email = ?`, "u1@example.com")
+	require.NoError(t, err)
+	require.False(t, disabled)
+
+	_, err = db.Exec(`UPDATE users SET disabled = 1 WHERE email = ?`, "u1@example.com")
+	require.NoError(t, err)
+	err = db.Get(&disabled, `SELECT disabled FROM users WHERE email = ?`, "u1@example.com")
+	require.NoError(t, err)
+	require.True(t, disabled)
+}
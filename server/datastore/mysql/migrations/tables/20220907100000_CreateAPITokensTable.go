@@ -0,0 +1,41 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220907100000, Down_20220907100000)
+}
+
+func Up_20220907100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE api_tokens (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    user_id INT UNSIGNED NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    key_hash CHAR(64) NOT NULL,
+    team_id INT UNSIGNED NULL,
+    scopes JSON NOT NULL,
+    expires_at TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+
+    UNIQUE KEY idx_api_tokens_key_hash (key_hash),
+    UNIQUE KEY idx_api_tokens_user_name (user_id, name),
+    CONSTRAINT fk_api_tokens_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_api_tokens_team_id FOREIGN KEY (team_id) REFERENCES teams (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create table")
+	}
+	return nil
+}
+
+func Down_20220907100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220907100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO users (name, email, password, salt) VALUES (?, ?, ?, ?)`, "u1", "u1@example.com", "", "")
+	require.NoError(t, err)
+	userID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	query := `
+INSERT INTO api_tokens (
+    user_id,
+    name,
+    key_hash,
+    scopes
+)
+VALUES (?, ?, ?, ?)
+`
+	_, err = db.Exec(query, userID, "automation", "abc", `[{"object": "host", "actions": ["read"]}]`)
+	require.NoError(t, err)
+
+	// names are unique per user
+	_, err = db.Exec(query, userID, "automation", "def", `[]`)
+	require.Error(t, err)
+
+	// deleting the user deletes its tokens
+	_, err = db.Exec(`DELETE FROM users WHERE id = ?`, userID)
+	require.NoError(t, err)
+	var count int
+	err = db.Get(&count, `SELECT COUNT(*) FROM api_tokens`)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+}
@@ -0,0 +1,36 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220908100000, Down_20220908100000)
+}
+
+func Up_20220908100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE custom_roles (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    -- the name is stored in the role columns of the users, user_teams,
+    -- invites and invite_teams tables, hence the length.
+    name VARCHAR(64) NOT NULL,
+    description TEXT NOT NULL,
+    permissions JSON NOT NULL,
+
+    UNIQUE KEY idx_custom_roles_name (name)
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create table")
+	}
+	return nil
+}
+
+func Down_20220908100000(tx *sql.Tx) error {
+	return nil
+}
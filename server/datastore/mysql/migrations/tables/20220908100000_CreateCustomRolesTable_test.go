@@ -0,0 +1,25 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220908100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	applyNext(t, db)
+
+	query := `INSERT INTO custom_roles (name, description, permissions) VALUES (?, ?, ?)`
+	_, err := db.Exec(query, "helpdesk", "", `[{"object": "host", "actions": ["read"]}]`)
+	require.NoError(t, err)
+
+	// names are unique
+	_, err = db.Exec(query, "helpdesk", "", `[]`)
+	require.Error(t, err)
+
+	// custom roles can be stored as user roles
+	_, err = db.Exec(`INSERT INTO users (name, email, password, salt, global_role) VALUES (?, ?, ?, ?, ?)`, "u1", "u1@example.com", "", "", "helpdesk")
+	require.NoError(t, err)
+}
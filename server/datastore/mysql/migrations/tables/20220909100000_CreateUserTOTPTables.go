@@ -0,0 +1,63 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220909100000, Down_20220909100000)
+}
+
+func Up_20220909100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE user_totp (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    secret VARCHAR(64) NOT NULL,
+    enabled TINYINT(1) NOT NULL DEFAULT FALSE,
+    last_step BIGINT NOT NULL DEFAULT 0,
+
+    CONSTRAINT fk_user_totp_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create user_totp table")
+	}
+
+	_, err = tx.Exec(`
+CREATE TABLE user_totp_recovery_codes (
+    user_id INT UNSIGNED NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (user_id, code_hash),
+    CONSTRAINT fk_user_totp_recovery_codes_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create user_totp_recovery_codes table")
+	}
+
+	_, err = tx.Exec(`
+CREATE TABLE totp_login_challenges (
+    token VARCHAR(255) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_totp_login_challenges_created_at (created_at),
+    CONSTRAINT fk_totp_login_challenges_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create totp_login_challenges table")
+	}
+	return nil
+}
+
+func Down_20220909100000(tx *sql.Tx) error {
+	return nil
+}
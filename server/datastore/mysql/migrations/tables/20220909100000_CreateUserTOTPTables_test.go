@@ -0,0 +1,33 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220909100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO users (name, email, password, salt) VALUES (?, ?, ?, ?)`, "u1", "u1@example.com", "", "")
+	require.NoError(t, err)
+	userID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	_, err = db.Exec(`INSERT INTO user_totp (user_id, secret) VALUES (?, ?)`, userID, "ABCDEF")
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO user_totp_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, "abc")
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO totp_login_challenges (token, user_id) VALUES (?, ?)`, "tok", userID)
+	require.NoError(t, err)
+
+	// deleting the user deletes its two-factor authentication data
+	_, err = db.Exec(`DELETE FROM users WHERE id = ?`, userID)
+	require.NoError(t, err)
+	for _, table := range []string{"user_totp", "user_totp_recovery_codes", "totp_login_challenges"} {
+		var count int
+		require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM `+table))
+		require.Zero(t, count, table)
+	}
+}
@@ -0,0 +1,31 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220910100000, Down_20220910100000)
+}
+
+func Up_20220910100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+ALTER TABLE activities
+    ADD COLUMN ip_address VARCHAR(64) NULL,
+    ADD COLUMN user_agent VARCHAR(255) NULL,
+    ADD COLUMN streamed TINYINT(1) NOT NULL DEFAULT 0,
+    ADD KEY idx_activities_activity_type (activity_type),
+    ADD KEY idx_activities_created_at (created_at),
+    ADD KEY idx_activities_streamed (streamed)
+`)
+	if err != nil {
+		return errors.Wrapf(err, "add audit columns to activities")
+	}
+	return nil
+}
+
+func Down_20220910100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,32 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220910100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	_, err := db.Exec(`INSERT INTO activities (user_name, activity_type) VALUES (?, ?)`, "u1", "created_pack")
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	// existing activities have no ip nor user agent and are not streamed yet
+	var row struct {
+		IPAddress *string `db:"ip_address"`
+		UserAgent *string `db:"user_agent"`
+		Streamed  bool    `db:"streamed"`
+	}
+	err = db.Get(&row, `SELECT ip_address, user_agent, streamed FROM activities`)
+	require.NoError(t, err)
+	require.Nil(t, row.IPAddress)
+	require.Nil(t, row.UserAgent)
+	require.False(t, row.Streamed)
+
+	_, err = db.Exec(`INSERT INTO activities (user_name, activity_type, ip_address, user_agent) VALUES (?, ?, ?, ?)`,
+		"u1", "edited_user", "10.0.0.1", "fleetctl")
+	require.NoError(t, err)
+}
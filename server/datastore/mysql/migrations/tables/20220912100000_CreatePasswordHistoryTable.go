@@ -0,0 +1,44 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220912100000, Down_20220912100000)
+}
+
+func Up_20220912100000(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+CREATE TABLE password_history (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    password VARBINARY(255) NOT NULL,
+    salt VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_password_history_user_id (user_id, id),
+    CONSTRAINT fk_password_history_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create password_history table")
+	}
+
+	// The current passwords are recorded as set now, so that the existing
+	// passwords don't expire as soon as a maximum age is configured.
+	_, err = tx.Exec(`
+INSERT INTO password_history (user_id, password, salt)
+SELECT id, password, salt FROM users WHERE sso_enabled = 0
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "record current passwords")
+	}
+	return nil
+}
+
+func Down_20220912100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220912100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO users (name, email, password, salt) VALUES (?, ?, ?, ?)`, "u1", "u1@example.com", "pwd", "salt")
+	require.NoError(t, err)
+	userID, _ := res.LastInsertId()
+	_, err = db.Exec(`INSERT INTO users (name, email, password, salt, sso_enabled) VALUES (?, ?, ?, ?, 1)`, "u2", "u2@example.com", "fake", "fake")
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	// only the password of the non-SSO user is recorded
+	var history []struct {
+		UserID   int64  `db:"user_id"`
+		Password []byte `db:"password"`
+		Salt     string `db:"salt"`
+	}
+	require.NoError(t, db.Select(&history, `SELECT user_id, password, salt FROM password_history`))
+	require.Len(t, history, 1)
+	require.Equal(t, userID, history[0].UserID)
+	require.Equal(t, "pwd", string(history[0].Password))
+	require.Equal(t, "salt", history[0].Salt)
+
+	// deleting the user deletes its password history
+	_, err = db.Exec(`DELETE FROM users WHERE id = ?`, userID)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM password_history`))
+	require.Zero(t, count)
+}
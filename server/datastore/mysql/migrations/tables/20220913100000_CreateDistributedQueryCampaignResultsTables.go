@@ -0,0 +1,55 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220913100000, Down_20220913100000)
+}
+
+func Up_20220913100000(tx *sql.Tx) error {
+	// The hostname is stored along with the results so that they can still be
+	// reviewed after the host is deleted, hence no foreign key on hosts.
+	_, err := tx.Exec(`
+CREATE TABLE distributed_query_campaign_results (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    distributed_query_campaign_id INT UNSIGNED NOT NULL,
+    host_id INT UNSIGNED NOT NULL,
+    hostname VARCHAR(255) NOT NULL DEFAULT '',
+    data JSON NULL,
+    row_count INT UNSIGNED NOT NULL DEFAULT 0,
+    truncated TINYINT(1) NOT NULL DEFAULT 0,
+    error TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY idx_dqc_results_campaign_host (distributed_query_campaign_id, host_id),
+    KEY idx_dqc_results_created_at (created_at),
+    CONSTRAINT fk_dqc_results_campaign_id FOREIGN KEY (distributed_query_campaign_id) REFERENCES distributed_query_campaigns (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create distributed_query_campaign_results table")
+	}
+
+	// The number of rows stored for each campaign, locked when storing results
+	// to enforce the per-campaign limit.
+	_, err = tx.Exec(`
+CREATE TABLE distributed_query_campaign_result_counts (
+    distributed_query_campaign_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    row_count INT UNSIGNED NOT NULL DEFAULT 0,
+
+    CONSTRAINT fk_dqc_result_counts_campaign_id FOREIGN KEY (distributed_query_campaign_id) REFERENCES distributed_query_campaigns (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create distributed_query_campaign_result_counts table")
+	}
+	return nil
+}
+
+func Down_20220913100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,39 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220913100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO distributed_query_campaigns (query_id, status, user_id) VALUES (1, 0, 1)`)
+	require.NoError(t, err)
+	campaignID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	_, err = db.Exec(`
+		INSERT INTO distributed_query_campaign_results (distributed_query_campaign_id, host_id, hostname, data, row_count)
+		VALUES (?, 1, 'foo', '[{"a": "b"}]', 1)`, campaignID)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO distributed_query_campaign_result_counts (distributed_query_campaign_id, row_count) VALUES (?, 1)`, campaignID)
+	require.NoError(t, err)
+
+	// a host has a single result per campaign
+	_, err = db.Exec(`
+		INSERT INTO distributed_query_campaign_results (distributed_query_campaign_id, host_id, hostname, data, row_count)
+		VALUES (?, 1, 'foo', '[]', 0)`, campaignID)
+	require.Error(t, err)
+
+	// deleting the campaign deletes its results
+	_, err = db.Exec(`DELETE FROM distributed_query_campaigns WHERE id = ?`, campaignID)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM distributed_query_campaign_results`))
+	require.Zero(t, count)
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM distributed_query_campaign_result_counts`))
+	require.Zero(t, count)
+}
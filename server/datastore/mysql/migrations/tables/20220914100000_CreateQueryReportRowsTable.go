@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220914100000, Down_20220914100000)
+}
+
+func Up_20220914100000(tx *sql.Tx) error {
+	// Each row of the latest snapshot result of a scheduled query on a host is
+	// stored separately so that the reports can be paginated by row. The rows
+	// of a host are deleted along with the host, see hostRefs.
+	_, err := tx.Exec(`
+CREATE TABLE query_report_rows (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    scheduled_query_id INT UNSIGNED NOT NULL,
+    host_id INT UNSIGNED NOT NULL,
+    data JSON NOT NULL,
+    last_fetched TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_query_report_rows_scheduled_query_host (scheduled_query_id, host_id),
+    KEY idx_query_report_rows_host_id (host_id),
+    CONSTRAINT fk_query_report_rows_scheduled_query_id FOREIGN KEY (scheduled_query_id) REFERENCES scheduled_queries (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create query_report_rows table")
+	}
+	return nil
+}
+
+func Down_20220914100000(tx *sql.Tx) error {
+	return nil
+}
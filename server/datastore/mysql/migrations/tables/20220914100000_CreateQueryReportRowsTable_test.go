@@ -0,0 +1,32 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220914100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	_, err := db.Exec(`INSERT INTO queries (name, description, query) VALUES ('q', '', 'SELECT 1')`)
+	require.NoError(t, err)
+	res, err := db.Exec(`INSERT INTO packs (name) VALUES ('p')`)
+	require.NoError(t, err)
+	packID, _ := res.LastInsertId()
+	res, err = db.Exec(`INSERT INTO scheduled_queries (pack_id, query_name, name) VALUES (?, 'q', 'sq')`, packID)
+	require.NoError(t, err)
+	sqID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	_, err = db.Exec(`INSERT INTO query_report_rows (scheduled_query_id, host_id, data) VALUES (?, 1, '{"a": "b"}'), (?, 1, '{"a": "c"}')`, sqID, sqID)
+	require.NoError(t, err)
+
+	// deleting the scheduled query deletes its rows
+	_, err = db.Exec(`DELETE FROM scheduled_queries WHERE id = ?`, sqID)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM query_report_rows`))
+	require.Zero(t, count)
+}
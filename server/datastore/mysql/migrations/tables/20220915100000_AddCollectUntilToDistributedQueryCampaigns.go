@@ -0,0 +1,43 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220915100000, Down_20220915100000)
+}
+
+func Up_20220915100000(tx *sql.Tx) error {
+	// An asynchronous campaign keeps targeting its hosts until collect_until,
+	// the hosts it targets are stored so that the pending hosts can be listed.
+	// The rows of a host are deleted along with the host, see hostRefs.
+	_, err := tx.Exec(`
+ALTER TABLE distributed_query_campaigns
+    ADD COLUMN collect_until TIMESTAMP NULL DEFAULT NULL
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "add collect_until to distributed_query_campaigns")
+	}
+
+	_, err = tx.Exec(`
+CREATE TABLE distributed_query_campaign_hosts (
+    distributed_query_campaign_id INT UNSIGNED NOT NULL,
+    host_id INT UNSIGNED NOT NULL,
+
+    PRIMARY KEY (distributed_query_campaign_id, host_id),
+    KEY idx_dqc_hosts_host_id (host_id),
+    CONSTRAINT fk_dqc_hosts_campaign_id FOREIGN KEY (distributed_query_campaign_id) REFERENCES distributed_query_campaigns (id) ON DELETE CASCADE
+)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "create distributed_query_campaign_hosts table")
+	}
+	return nil
+}
+
+func Down_20220915100000(tx *sql.Tx) error {
+	return nil
+}
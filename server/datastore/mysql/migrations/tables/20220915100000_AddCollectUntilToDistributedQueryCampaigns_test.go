@@ -0,0 +1,35 @@
+package tables
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220915100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO distributed_query_campaigns (query_id, status, user_id) VALUES (1, 0, 1)`)
+	require.NoError(t, err)
+	campaignID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	// existing campaigns are not asynchronous
+	var collectUntil *time.Time
+	require.NoError(t, db.Get(&collectUntil, `SELECT collect_until FROM distributed_query_campaigns WHERE id = ?`, campaignID))
+	require.Nil(t, collectUntil)
+
+	_, err = db.Exec(`UPDATE distributed_query_campaigns SET collect_until = NOW() WHERE id = ?`, campaignID)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO distributed_query_campaign_hosts (distributed_query_campaign_id, host_id) VALUES (?, 1), (?, 2)`, campaignID, campaignID)
+	require.NoError(t, err)
+
+	// deleting the campaign deletes its hosts
+	_, err = db.Exec(`DELETE FROM distributed_query_campaigns WHERE id = ?`, campaignID)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, db.Get(&count, `SELECT COUNT(*) FROM distributed_query_campaign_hosts`))
+	require.Zero(t, count)
+}
@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220916100000, Down_20220916100000)
+}
+
+func Up_20220916100000(tx *sql.Tx) error {
+	// The results of a campaign with an export format are exported to the
+	// live query export store as the hosts respond.
+	_, err := tx.Exec(`
+ALTER TABLE distributed_query_campaigns
+    ADD COLUMN export_format VARCHAR(16) NOT NULL DEFAULT ''
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "add export_format to distributed_query_campaigns")
+	}
+	return nil
+}
+
+func Down_20220916100000(tx *sql.Tx) error {
+	return nil
+}
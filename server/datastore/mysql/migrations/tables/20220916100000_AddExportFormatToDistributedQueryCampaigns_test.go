@@ -0,0 +1,27 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220916100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO distributed_query_campaigns (query_id, status, user_id) VALUES (1, 0, 1)`)
+	require.NoError(t, err)
+	campaignID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	// existing campaigns are not exported
+	var format string
+	require.NoError(t, db.Get(&format, `SELECT export_format FROM distributed_query_campaigns WHERE id = ?`, campaignID))
+	require.Empty(t, format)
+
+	_, err = db.Exec(`UPDATE distributed_query_campaigns SET export_format = 'csv' WHERE id = ?`, campaignID)
+	require.NoError(t, err)
+	require.NoError(t, db.Get(&format, `SELECT export_format FROM distributed_query_campaigns WHERE id = ?`, campaignID))
+	require.Equal(t, "csv", format)
+}
@@ -0,0 +1,43 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220917100000, Down_20220917100000)
+}
+
+func Up_20220917100000(tx *sql.Tx) error {
+	// The status of the hosts of an asynchronous campaign is stored as they
+	// respond, so that the hosts are listed and counted without joining the
+	// results, which are deleted after the retention period.
+	_, err := tx.Exec(`
+ALTER TABLE distributed_query_campaign_hosts
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    ADD COLUMN responded_at TIMESTAMP NULL DEFAULT NULL,
+    ADD KEY idx_dqc_hosts_campaign_status (distributed_query_campaign_id, status)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "add status to distributed_query_campaign_hosts")
+	}
+
+	_, err = tx.Exec(`
+UPDATE distributed_query_campaign_hosts dqch
+JOIN distributed_query_campaign_results r
+    ON r.distributed_query_campaign_id = dqch.distributed_query_campaign_id AND r.host_id = dqch.host_id
+SET
+    dqch.status = IF(r.error IS NULL, 'responded', 'errored'),
+    dqch.responded_at = r.created_at
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "set status of distributed_query_campaign_hosts")
+	}
+	return nil
+}
+
+func Down_20220917100000(tx *sql.Tx) error {
+	return nil
+}
@@ -0,0 +1,43 @@
+package tables
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220917100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO distributed_query_campaigns (query_id, status, user_id, collect_until) VALUES (1, 0, 1, NOW())`)
+	require.NoError(t, err)
+	campaignID, _ := res.LastInsertId()
+	_, err = db.Exec(`INSERT INTO distributed_query_campaign_hosts (distributed_query_campaign_id, host_id) VALUES (?, 1), (?, 2), (?, 3)`,
+		campaignID, campaignID, campaignID)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO distributed_query_campaign_results (distributed_query_campaign_id, host_id, data, error)
+		VALUES (?, 1, '[]', NULL), (?, 2, '[]', 'no such table')`,
+		campaignID, campaignID)
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	// the status of the existing hosts is set from their results
+	var hosts []struct {
+		HostID      uint       `db:"host_id"`
+		Status      string     `db:"status"`
+		RespondedAt *time.Time `db:"responded_at"`
+	}
+	require.NoError(t, db.Select(&hosts, `
+		SELECT host_id, status, responded_at FROM distributed_query_campaign_hosts
+		WHERE distributed_query_campaign_id = ? ORDER BY host_id`, campaignID))
+	require.Len(t, hosts, 3)
+	require.Equal(t, "responded", hosts[0].Status)
+	require.NotNil(t, hosts[0].RespondedAt)
+	require.Equal(t, "errored", hosts[1].Status)
+	require.NotNil(t, hosts[1].RespondedAt)
+	require.Equal(t, "pending", hosts[2].Status)
+	require.Nil(t, hosts[2].RespondedAt)
+}
@@ -0,0 +1,31 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220918100000, Down_20220918100000)
+}
+
+func Up_20220918100000(tx *sql.Tx) error {
+	// The exported results of a campaign are combined by a cron job once it
+	// completes, exported_at is set the first time they are combined. The
+	// index finds the exported campaigns that were not combined yet, or
+	// recently so, to include the late results.
+	_, err := tx.Exec(`
+ALTER TABLE distributed_query_campaigns
+    ADD COLUMN exported_at TIMESTAMP NULL DEFAULT NULL,
+    ADD KEY idx_dqc_export_format_exported_at (export_format, exported_at)
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "add exported_at to distributed_query_campaigns")
+	}
+	return nil
+}
+
+func Down_20220918100000(tx *sql.Tx) error {
+	return nil
+}
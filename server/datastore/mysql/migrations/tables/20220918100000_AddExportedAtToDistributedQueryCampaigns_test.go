@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220918100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	res, err := db.Exec(`INSERT INTO distributed_query_campaigns (query_id, status, user_id, export_format) VALUES (1, 2, 1, 'csv')`)
+	require.NoError(t, err)
+	campaignID, _ := res.LastInsertId()
+
+	applyNext(t, db)
+
+	// the existing campaigns are combined by the cron job
+	var exportedAt *time.Time
+	require.NoError(t, db.Get(&exportedAt, `SELECT exported_at FROM distributed_query_campaigns WHERE id = ?`, campaignID))
+	require.Nil(t, exportedAt)
+
+	_, err = db.Exec(`UPDATE distributed_query_campaigns SET exported_at = NOW() WHERE id = ?`, campaignID)
+	require.NoError(t, err)
+	require.NoError(t, db.Get(&exportedAt, `SELECT exported_at FROM distributed_query_campaigns WHERE id = ?`, campaignID))
+	require.NotNil(t, exportedAt)
+}
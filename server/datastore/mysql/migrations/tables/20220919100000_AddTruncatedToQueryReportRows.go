@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	MigrationClient.AddMigration(Up_20220919100000, Down_20220919100000)
+}
+
+func Up_20220919100000(tx *sql.Tx) error {
+	// truncated is set on the rows of a host whose result was truncated
+	// because of the maximum number of rows per query.
+	_, err := tx.Exec(`
+ALTER TABLE query_report_rows
+    ADD COLUMN truncated TINYINT(1) NOT NULL DEFAULT 0
+	`)
+	if err != nil {
+		return errors.Wrapf(err, "add truncated to query_report_rows")
+	}
+	return nil
+}
+
+func Down_20220919100000(tx *sql.Tx) error {
+	return nil
+}
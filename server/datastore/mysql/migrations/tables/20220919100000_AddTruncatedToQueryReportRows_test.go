@@ -0,0 +1,34 @@
+package tables
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUp_20220919100000(t *testing.T) {
+	db := applyUpToPrev(t)
+
+	_, err := db.Exec(`INSERT INTO queries (name, description, query) VALUES ('q', '', 'SELECT 1')`)
+	require.NoError(t, err)
+	res, err := db.Exec(`INSERT INTO packs (name) VALUES ('p')`)
+	require.NoError(t, err)
+	packID, _ := res.LastInsertId()
+	res, err = db.Exec(`INSERT INTO scheduled_queries (pack_id, query_name, name) VALUES (?, 'q', 'sq')`, packID)
+	require.NoError(t, err)
+	sqID, _ := res.LastInsertId()
+	_, err = db.Exec(`INSERT INTO query_report_rows (scheduled_query_id, host_id, data) VALUES (?, 1, '{"a": "b"}')`, sqID)
+	require.NoError(t, err)
+
+	applyNext(t, db)
+
+	// the existing rows are not truncated
+	var truncated bool
+	require.NoError(t, db.Get(&truncated, `SELECT truncated FROM query_report_rows WHERE scheduled_query_id = ?`, sqID))
+	require.False(t, truncated)
+
+	_, err = db.Exec(`INSERT INTO query_report_rows (scheduled_query_id, host_id, data, truncated) VALUES (?, 2, '{"a": "c"}', 1)`, sqID)
+	require.NoError(t, err)
+	require.NoError(t, db.Get(&truncated, `SELECT truncated FROM query_report_rows WHERE host_id = 2`))
+	require.True(t, truncated)
+}
@@ -718,6 +718,12 @@ func (ds *Datastore) whereFilterHostsByTeams(filter fleet.TeamFilter, hostKey st
 			return "FALSE"
 
 		default:
+			// Users with a custom global role see the hosts like observers,
+			// their permissions are enforced by the authorization
+			// policy.
+			if fleet.IsCustomRole(*filter.User.GlobalRole) && filter.IncludeObserver {
+				return defaultAllowClause
+			}
 			// Fall through to specific teams
 		}
 	}
@@ -727,7 +733,7 @@ func (ds *Datastore) whereFilterHostsByTeams(filter fleet.TeamFilter, hostKey st
 	var teamIDSeen bool
 	for _, team := range filter.User.Teams {
 		if team.Role == fleet.RoleAdmin || team.Role == fleet.RoleMaintainer ||
-			(team.Role == fleet.RoleObserver && filter.IncludeObserver) {
+			((team.Role == fleet.RoleObserver || fleet.IsCustomRole(team.Role)) && filter.IncludeObserver) {
 			idStrs = append(idStrs, strconv.Itoa(int(team.ID)))
 			if filter.TeamID != nil && *filter.TeamID == team.ID {
 				teamIDSeen = true
@@ -778,6 +784,10 @@ func (ds *Datastore) whereFilterTeams(filter fleet.TeamFilter, teamKey string) s
 			return "FALSE"
 
 		default:
+			// Users with a custom global role see the teams like observers.
+			if fleet.IsCustomRole(*filter.User.GlobalRole) && filter.IncludeObserver {
+				return "TRUE"
+			}
 			// Fall through to specific teams
 		}
 	}
@@ -786,7 +796,7 @@ func (ds *Datastore) whereFilterTeams(filter fleet.TeamFilter, teamKey string) s
 	var idStrs []string
 	for _, team := range filter.User.Teams {
 		if team.Role == fleet.RoleAdmin || team.Role == fleet.RoleMaintainer ||
-			(team.Role == fleet.RoleObserver && filter.IncludeObserver) {
+			((team.Role == fleet.RoleObserver || fleet.IsCustomRole(team.Role)) && filter.IncludeObserver) {
 			idStrs = append(idStrs, strconv.Itoa(int(team.ID)))
 		}
 	}
@@ -0,0 +1,112 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/jmoiron/sqlx"
+)
+
+func (ds *Datastore) NewPolicyAutomationTickets(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+	if len(hostIDs) == 0 {
+		return nil
+	}
+
+	values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?),", len(hostIDs)), ",")
+	query := `
+INSERT INTO policy_automation_tickets (
+    policy_id,
+    host_id,
+    team_id,
+    integration,
+    ticket_id
+)
+VALUES ` + values
+
+	args := make([]interface{}, 0, len(hostIDs)*5)
+	for _, hostID := range hostIDs {
+		args = append(args, policyID, hostID, teamID, integration, ticketID)
+	}
+	if _, err := ds.writer.ExecContext(ctx, query, args...); err != nil {
+		return ctxerr.Wrap(ctx, err, "insert policy automation tickets")
+	}
+	return nil
+}
+
+func (ds *Datastore) ListOpenPolicyAutomationTickets(ctx context.Context, policyID uint, hostIDs []uint) ([]*fleet.PolicyAutomationTicket, error) {
+	if len(hostIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+SELECT
+    id,
+    created_at,
+    policy_id,
+    host_id,
+    team_id,
+    integration,
+    ticket_id,
+    resolved_at
+FROM
+    policy_automation_tickets
+WHERE
+    policy_id = ? AND
+    host_id IN (?) AND
+    resolved_at IS NULL
+ORDER BY
+    id
+`
+	query, args, err := sqlx.In(query, policyID, hostIDs)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "build list open policy automation tickets query")
+	}
+
+	var tickets []*fleet.PolicyAutomationTicket
+	if err := sqlx.SelectContext(ctx, ds.reader, &tickets, query, args...); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list open policy automation tickets")
+	}
+	return tickets, nil
+}
+
+func (ds *Datastore) ListPolicyAutomationTicketOpenHosts(ctx context.Context, integration, ticketID string) ([]uint, error) {
+	const stmt = `
+SELECT
+    host_id
+FROM
+    policy_automation_tickets
+WHERE
+    integration = ? AND
+    ticket_id = ? AND
+    resolved_at IS NULL
+ORDER BY
+    host_id
+`
+	// use the primary so that the tickets resolved by the previous jobs are
+	// taken into account
+	var hostIDs []uint
+	if err := sqlx.SelectContext(ctx, ds.writer, &hostIDs, stmt, integration, ticketID); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list policy automation ticket open hosts")
+	}
+	return hostIDs, nil
+}
+
+func (ds *Datastore) ResolvePolicyAutomationTicket(ctx context.Context, integration, ticketID string, hostID uint) error {
+	const stmt = `
+UPDATE
+    policy_automation_tickets
+SET
+    resolved_at = CURRENT_TIMESTAMP
+WHERE
+    integration = ? AND
+    ticket_id = ? AND
+    host_id = ? AND
+    resolved_at IS NULL
+`
+	if _, err := ds.writer.ExecContext(ctx, stmt, integration, ticketID, hostID); err != nil {
+		return ctxerr.Wrap(ctx, err, "resolve policy automation ticket")
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyAutomationTickets(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"CreateList", testPolicyAutomationTicketsCreateList},
+		{"Resolve", testPolicyAutomationTicketsResolve},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testPolicyAutomationTicketsCreateList(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	p1, err := ds.NewGlobalPolicy(ctx, nil, fleet.PolicyPayload{Name: "p1", Query: "SELECT 1"})
+	require.NoError(t, err)
+	p2, err := ds.NewGlobalPolicy(ctx, nil, fleet.PolicyPayload{Name: "p2", Query: "SELECT 2"})
+	require.NoError(t, err)
+
+	// no host is a no-op
+	err = ds.NewPolicyAutomationTickets(ctx, "jira", "PROJ-1", p1.ID, nil, nil)
+	require.NoError(t, err)
+
+	err = ds.NewPolicyAutomationTickets(ctx, "jira", "PROJ-1", p1.ID, nil, []uint{1, 2})
+	require.NoError(t, err)
+	err = ds.NewPolicyAutomationTickets(ctx, "zendesk", "123", p2.ID, ptr.Uint(3), []uint{1})
+	require.NoError(t, err)
+
+	tickets, err := ds.ListOpenPolicyAutomationTickets(ctx, p1.ID, []uint{1, 2, 3})
+	require.NoError(t, err)
+	require.Len(t, tickets, 2)
+	require.Equal(t, p1.ID, tickets[0].PolicyID)
+	require.Equal(t, uint(1), tickets[0].HostID)
+	require.Nil(t, tickets[0].TeamID)
+	require.Equal(t, "jira", tickets[0].Integration)
+	require.Equal(t, "PROJ-1", tickets[0].TicketID)
+	require.Nil(t, tickets[0].ResolvedAt)
+	require.Equal(t, uint(2), tickets[1].HostID)
+
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p2.ID, []uint{1, 2})
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+	require.Equal(t, p2.ID, tickets[0].PolicyID)
+	require.Equal(t, uint(1), tickets[0].HostID)
+	require.Equal(t, ptr.Uint(3), tickets[0].TeamID)
+	require.Equal(t, "zendesk", tickets[0].Integration)
+	require.Equal(t, "123", tickets[0].TicketID)
+
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p2.ID, []uint{2})
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p2.ID, nil)
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+
+	// deleting the policy deletes its tickets
+	_, err = ds.DeleteGlobalPolicies(ctx, []uint{p1.ID})
+	require.NoError(t, err)
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p1.ID, []uint{1, 2})
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p2.ID, []uint{1})
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+}
+
+func testPolicyAutomationTicketsResolve(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	p1, err := ds.NewGlobalPolicy(ctx, nil, fleet.PolicyPayload{Name: "p1", Query: "SELECT 1"})
+	require.NoError(t, err)
+
+	err = ds.NewPolicyAutomationTickets(ctx, "jira", "PROJ-1", p1.ID, nil, []uint{1, 2})
+	require.NoError(t, err)
+	err = ds.NewPolicyAutomationTickets(ctx, "zendesk", "PROJ-1", p1.ID, nil, []uint{1})
+	require.NoError(t, err)
+
+	hostIDs, err := ds.ListPolicyAutomationTicketOpenHosts(ctx, "jira", "PROJ-1")
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2}, hostIDs)
+
+	err = ds.ResolvePolicyAutomationTicket(ctx, "jira", "PROJ-1", 1)
+	require.NoError(t, err)
+	hostIDs, err = ds.ListPolicyAutomationTicketOpenHosts(ctx, "jira", "PROJ-1")
+	require.NoError(t, err)
+	require.Equal(t, []uint{2}, hostIDs)
+
+	// resolving again is a no-op
+	err = ds.ResolvePolicyAutomationTicket(ctx, "jira", "PROJ-1", 1)
+	require.NoError(t, err)
+	hostIDs, err = ds.ListPolicyAutomationTicketOpenHosts(ctx, "jira", "PROJ-1")
+	require.NoError(t, err)
+	require.Equal(t, []uint{2}, hostIDs)
+
+	// the ticket of the other integration is still open for the host
+	tickets, err := ds.ListOpenPolicyAutomationTickets(ctx, p1.ID, []uint{1})
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+	require.Equal(t, "zendesk", tickets[0].Integration)
+
+	err = ds.ResolvePolicyAutomationTicket(ctx, "jira", "PROJ-1", 2)
+	require.NoError(t, err)
+	hostIDs, err = ds.ListPolicyAutomationTicketOpenHosts(ctx, "jira", "PROJ-1")
+	require.NoError(t, err)
+	require.Empty(t, hostIDs)
+
+	tickets, err = ds.ListOpenPolicyAutomationTickets(ctx, p1.ID, []uint{2})
+	require.NoError(t, err)
+	require.Empty(t, tickets)
+}
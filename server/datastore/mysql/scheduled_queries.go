@@ -3,9 +3,11 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -360,3 +362,122 @@ func (ds *Datastore) AsyncBatchSaveHostsScheduledQueryStats(ctx context.Context,
 
 	return countExecs, nil
 }
+
+// queryReportRowsBatchSize is the number of rows inserted per statement when
+// storing the result of a scheduled query.
+const queryReportRowsBatchSize = 500
+
+func (ds *Datastore) SaveQueryReportRows(ctx context.Context, scheduledQueryID, hostID uint, rows []map[string]string, fetchedAt time.Time, maxRowsPerQuery int) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM query_report_rows WHERE scheduled_query_id = ? AND host_id = ?`, scheduledQueryID, hostID,
+		); err != nil {
+			return ctxerr.Wrap(ctx, err, "delete previous query report rows")
+		}
+
+		// the rows of the other hosts are counted without locking, so that the
+		// results of a scheduled query are not serialized, the limit may thus be
+		// exceeded by the rows of the hosts that send their results concurrently.
+		// At least one row is stored for a host with results, so that the
+		// truncation of its rows is reported even once the limit is reached.
+		var truncated bool
+		if maxRowsPerQuery > 0 && len(rows) > 0 {
+			var count int
+			if err := sqlx.GetContext(ctx, tx, &count,
+				`SELECT COUNT(*) FROM query_report_rows WHERE scheduled_query_id = ?`, scheduledQueryID,
+			); err != nil {
+				return ctxerr.Wrap(ctx, err, "count query report rows")
+			}
+			remaining := maxRowsPerQuery - count
+			if remaining < 1 {
+				remaining = 1
+			}
+			if len(rows) > remaining {
+				rows = rows[:remaining]
+				truncated = true
+			}
+		}
+
+		const values = `(?, ?, ?, ?, ?),`
+		for len(rows) > 0 {
+			batch := rows
+			if len(batch) > queryReportRowsBatchSize {
+				batch = batch[:queryReportRowsBatchSize]
+			}
+			rows = rows[len(batch):]
+
+			args := make([]interface{}, 0, len(batch)*5)
+			for _, row := range batch {
+				data, err := json.Marshal(row)
+				if err != nil {
+					return ctxerr.Wrap(ctx, err, "marshal query report row")
+				}
+				args = append(args, scheduledQueryID, hostID, data, truncated, fetchedAt)
+			}
+			stmt := `INSERT INTO query_report_rows (scheduled_query_id, host_id, data, truncated, last_fetched) VALUES ` +
+				strings.TrimSuffix(strings.Repeat(values, len(batch)), ",")
+			if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+				if isChildForeignKeyError(err) {
+					// the scheduled query was deleted since the host ran it
+					return nil
+				}
+				return ctxerr.Wrap(ctx, err, "insert query report rows")
+			}
+		}
+		return nil
+	})
+}
+
+func (ds *Datastore) ListQueryReportRows(ctx context.Context, queryID uint, filter fleet.TeamFilter, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error) {
+	stmt := fmt.Sprintf(`
+		SELECT
+			qr.scheduled_query_id,
+			qr.host_id,
+			h.hostname,
+			h.team_id,
+			qr.data,
+			qr.truncated,
+			qr.last_fetched
+		FROM query_report_rows qr
+		JOIN scheduled_queries sq ON sq.id = qr.scheduled_query_id
+		JOIN queries q ON q.name = sq.query_name
+		JOIN hosts h ON h.id = qr.host_id
+		WHERE q.id = ? AND %s
+	`, ds.whereFilterHostsByTeams(filter, "h"))
+	args := []interface{}{queryID}
+
+	if opt.TeamID != nil {
+		stmt += ` AND h.team_id = ?`
+		args = append(args, *opt.TeamID)
+	}
+	if opt.HostID != nil {
+		stmt += ` AND qr.host_id = ?`
+		args = append(args, *opt.HostID)
+	}
+	stmt, args = searchLike(stmt, args, opt.MatchQuery, "h.hostname", "qr.data")
+
+	if opt.OrderKey == "" {
+		opt.OrderKey = "qr.id"
+	}
+	// the cursor is not supported, the pages are by offset
+	opt.After = ""
+	stmt = appendListOptionsToSQL(stmt, opt.ListOptions)
+
+	var dbRows []struct {
+		fleet.QueryReportRow
+		Data []byte `db:"data"`
+	}
+	if err := sqlx.SelectContext(ctx, ds.reader, &dbRows, stmt, args...); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select query report rows")
+	}
+
+	rows := make([]*fleet.QueryReportRow, 0, len(dbRows))
+	for i := range dbRows {
+		row := dbRows[i].QueryReportRow
+		if err := json.Unmarshal(dbRows[i].Data, &row.Columns); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "unmarshal query report row")
+		}
+		rows = append(rows, &row)
+	}
+	return rows, nil
+}
@@ -29,6 +29,7 @@ func TestScheduledQueries(t *testing.T) {
 		{"CascadingDelete", testScheduledQueriesCascadingDelete},
 		{"ScheduledQueryIDsByName", testScheduledQueriesIDsByName},
 		{"AsyncBatchSaveHostsScheduledQueryStats", testScheduledQueriesAsyncBatchSaveStats},
+		{"QueryReportRows", testScheduledQueriesQueryReportRows},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -612,3 +613,105 @@ func testScheduledQueriesAsyncBatchSaveStats(t *testing.T, ds *Datastore) {
 	require.Equal(t, 4, execs)
 	assertStats(m)
 }
+
+func testScheduledQueriesQueryReportRows(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	u1 := test.NewUser(t, ds, "Admin", "admin@fleet.co", true)
+	q1 := test.NewQuery(t, ds, "foo", "select * from foo;", u1.ID, true)
+	q2 := test.NewQuery(t, ds, "bar", "select * from bar;", u1.ID, true)
+	p1 := test.NewPack(t, ds, "p1")
+	p2 := test.NewPack(t, ds, "p2")
+	sq1 := test.NewScheduledQuery(t, ds, p1.ID, q1.ID, 60, true, false, "sq1")
+	sq2 := test.NewScheduledQuery(t, ds, p2.ID, q1.ID, 60, true, false, "sq2")
+	sq3 := test.NewScheduledQuery(t, ds, p1.ID, q2.ID, 60, true, false, "sq3")
+
+	team1, err := ds.NewTeam(ctx, &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	h1 := test.NewHost(t, ds, "h1.local", "10.0.0.1", "1", "1", time.Now())
+	h2 := test.NewHost(t, ds, "h2.local", "10.0.0.2", "2", "2", time.Now())
+	require.NoError(t, ds.AddHostsToTeam(ctx, &team1.ID, []uint{h1.ID}))
+
+	fetchedAt := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq1.ID, h1.ID, []map[string]string{{"a": "v1"}, {"a": "v2"}}, fetchedAt, 0))
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq1.ID, h2.ID, []map[string]string{{"a": "v3"}}, fetchedAt, 0))
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq2.ID, h1.ID, []map[string]string{{"a": "v4"}}, fetchedAt, 0))
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq3.ID, h1.ID, []map[string]string{{"b": "v5"}}, fetchedAt, 0))
+	// the scheduled query may have been deleted since the host ran it
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq3.ID+100, h1.ID, []map[string]string{{"b": "v6"}}, fetchedAt, 0))
+
+	values := func(rows []*fleet.QueryReportRow) []string {
+		var res []string
+		for _, row := range rows {
+			res = append(res, row.Columns["a"])
+		}
+		return res
+	}
+
+	adminFilter := fleet.TeamFilter{User: u1}
+	rows, err := ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v2", "v3", "v4"}, values(rows))
+	require.Equal(t, sq1.ID, rows[0].ScheduledQueryID)
+	require.Equal(t, h1.ID, rows[0].HostID)
+	require.Equal(t, "h1.local", rows[0].Hostname)
+	require.Equal(t, &team1.ID, rows[0].TeamID)
+	require.Equal(t, fetchedAt, rows[0].LastFetched.UTC())
+	require.Nil(t, rows[2].TeamID)
+
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{TeamID: &team1.ID})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v2", "v4"}, values(rows))
+
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{HostID: &h2.ID})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v3"}, values(rows))
+
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{ListOptions: fleet.ListOptions{MatchQuery: "v4"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v4"}, values(rows))
+
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{ListOptions: fleet.ListOptions{Page: 1, PerPage: 3}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v4"}, values(rows))
+
+	// a team user only sees the rows of the hosts of its teams
+	teamFilter := fleet.TeamFilter{User: &fleet.User{Teams: []fleet.UserTeam{{Team: *team1, Role: fleet.RoleObserver}}}, IncludeObserver: true}
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, teamFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v1", "v2", "v4"}, values(rows))
+
+	// the latest result of a host replaces the previous one
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq1.ID, h1.ID, []map[string]string{{"a": "v7"}}, fetchedAt, 0))
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v3", "v4", "v7"}, values(rows))
+
+	// the rows are limited per scheduled query, across the hosts
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq1.ID, h2.ID, []map[string]string{{"a": "v8"}, {"a": "v9"}, {"a": "v10"}}, fetchedAt, 3))
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v4", "v7", "v8", "v9"}, values(rows))
+	require.False(t, rows[1].Truncated)
+	require.True(t, rows[2].Truncated)
+	require.True(t, rows[3].Truncated)
+
+	// a host keeps one row once the limit is reached, to report the truncation
+	require.NoError(t, ds.SaveQueryReportRows(ctx, sq1.ID, h1.ID, []map[string]string{{"a": "v11"}, {"a": "v12"}}, fetchedAt, 2))
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{HostID: &h1.ID})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v4", "v11"}, values(rows))
+	require.False(t, rows[0].Truncated)
+	require.True(t, rows[1].Truncated)
+
+	// no rows for other queries
+	rows, err = ds.ListQueryReportRows(ctx, q2.ID+100, adminFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Empty(t, rows)
+
+	// deleting the scheduled query deletes its rows
+	require.NoError(t, ds.DeleteScheduledQuery(ctx, sq1.ID))
+	rows, err = ds.ListQueryReportRows(ctx, q1.ID, adminFilter, fleet.QueryReportOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"v4"}, values(rows))
+}
@@ -957,7 +957,8 @@ func (ds *Datastore) HostsBySoftwareIDs(ctx context.Context, softwareIDs []uint)
 	queryStmt := `
     SELECT 
       h.id,
-      h.hostname
+      h.hostname,
+      h.team_id
     FROM
       hosts h
     INNER JOIN
@@ -966,7 +967,7 @@ func (ds *Datastore) HostsBySoftwareIDs(ctx context.Context, softwareIDs []uint)
       h.id = hs.host_id
     WHERE
       hs.software_id IN (?)
-	GROUP BY h.id, h.hostname
+	GROUP BY h.id, h.hostname, h.team_id
     ORDER BY
       h.id`
 
@@ -984,7 +985,7 @@ func (ds *Datastore) HostsBySoftwareIDs(ctx context.Context, softwareIDs []uint)
 func (ds *Datastore) HostsByCVE(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
 	query := `
 SELECT
-    DISTINCT(h.id), h.hostname
+    DISTINCT(h.id), h.hostname, h.team_id
 FROM
     hosts h
     INNER JOIN host_software hs ON h.id = hs.host_id
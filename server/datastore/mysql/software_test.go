@@ -1227,6 +1227,18 @@ func testHostsByCVE(t *testing.T, ds *Datastore) {
 	require.NoError(t, err)
 	require.Len(t, hosts, 1)
 	require.Equal(t, hosts[0].Hostname, "host2")
+	require.Nil(t, hosts[0].TeamID)
+
+	// the team of the hosts is loaded
+	team, err := ds.NewTeam(ctx, &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(ctx, &team.ID, []uint{hosts[0].ID}))
+
+	hosts, err = ds.HostsByCVE(ctx, "CVE-2022-0002")
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	require.NotNil(t, hosts[0].TeamID)
+	require.Equal(t, team.ID, *hosts[0].TeamID)
 }
 
 func testHostsBySoftwareIDs(t *testing.T, ds *Datastore) {
@@ -1274,6 +1286,20 @@ func testHostsBySoftwareIDs(t *testing.T, ds *Datastore) {
 	require.Len(t, hosts, 2)
 	require.Equal(t, hosts[0].Hostname, "host1")
 	require.Equal(t, hosts[1].Hostname, "host2")
+	require.Nil(t, hosts[0].TeamID)
+	require.Nil(t, hosts[1].TeamID)
+
+	// the team of the hosts is loaded
+	team, err := ds.NewTeam(ctx, &fleet.Team{Name: "team1"})
+	require.NoError(t, err)
+	require.NoError(t, ds.AddHostsToTeam(ctx, &team.ID, []uint{hosts[1].ID}))
+
+	hosts, err = ds.HostsBySoftwareIDs(ctx, []uint{chrome3.ID, barRpm.ID})
+	require.NoError(t, err)
+	require.Len(t, hosts, 2)
+	require.Nil(t, hosts[0].TeamID)
+	require.NotNil(t, hosts[1].TeamID)
+	require.Equal(t, team.ID, *hosts[1].TeamID)
 }
 
 func testUpdateHostSoftware(t *testing.T, ds *Datastore) {
@@ -248,6 +248,14 @@ func (ds *Datastore) TeamsSummary(ctx context.Context) ([]*fleet.TeamSummary, er
 	return teamsSummary, nil
 }
 
+func (ds *Datastore) TeamsWithConfig(ctx context.Context) ([]*fleet.Team, error) {
+	teams := []*fleet.Team{}
+	if err := sqlx.SelectContext(ctx, ds.reader, &teams, "SELECT * FROM teams ORDER BY id"); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "teams with config")
+	}
+	return teams, nil
+}
+
 func (ds *Datastore) SearchTeams(ctx context.Context, filter fleet.TeamFilter, matchQuery string, omit ...uint) ([]*fleet.Team, error) {
 	sql := fmt.Sprintf(`
 			SELECT *,
@@ -324,9 +332,12 @@ func (ds *Datastore) DeleteIntegrationsFromTeams(ctx context.Context, deletedInt
 		// ignore errors, it's ok for some integrations to not match with the
 		// batch of deleted integrations, we're only interested in knowing if
 		// some did match.
-		if matches, _ := tm.Config.Integrations.MatchWithIntegrations(deletedIntgs); len(matches.Jira)+len(matches.Zendesk) > 0 {
+		if matches, _ := tm.Config.Integrations.MatchWithIntegrations(deletedIntgs); len(matches.Jira)+len(matches.Zendesk)+len(matches.Webhook)+len(matches.Slack)+len(matches.MicrosoftTeams) > 0 {
 			delJira, _ := fleet.IndexJiraIntegrations(matches.Jira)
 			delZendesk, _ := fleet.IndexZendeskIntegrations(matches.Zendesk)
+			delWebhook, _ := fleet.IndexWebhookIntegrations(matches.Webhook)
+			delSlack, _ := fleet.IndexSlackIntegrations(matches.Slack)
+			delMSTeams, _ := fleet.IndexMicrosoftTeamsIntegrations(matches.MicrosoftTeams)
 
 			var keepJira []*fleet.TeamJiraIntegration
 			for _, tmIntg := range tm.Config.Integrations.Jira {
@@ -342,8 +353,32 @@ func (ds *Datastore) DeleteIntegrationsFromTeams(ctx context.Context, deletedInt
 				}
 			}
 
+			var keepWebhook []*fleet.TeamWebhookIntegration
+			for _, tmIntg := range tm.Config.Integrations.Webhook {
+				if _, ok := delWebhook[tmIntg.UniqueKey()]; !ok {
+					keepWebhook = append(keepWebhook, tmIntg)
+				}
+			}
+
+			var keepSlack []*fleet.TeamSlackIntegration
+			for _, tmIntg := range tm.Config.Integrations.Slack {
+				if _, ok := delSlack[tmIntg.UniqueKey()]; !ok {
+					keepSlack = append(keepSlack, tmIntg)
+				}
+			}
+
+			var keepMSTeams []*fleet.TeamMicrosoftTeamsIntegration
+			for _, tmIntg := range tm.Config.Integrations.MicrosoftTeams {
+				if _, ok := delMSTeams[tmIntg.UniqueKey()]; !ok {
+					keepMSTeams = append(keepMSTeams, tmIntg)
+				}
+			}
+
 			tm.Config.Integrations.Jira = keepJira
 			tm.Config.Integrations.Zendesk = keepZendesk
+			tm.Config.Integrations.Webhook = keepWebhook
+			tm.Config.Integrations.Slack = keepSlack
+			tm.Config.Integrations.MicrosoftTeams = keepMSTeams
 			if _, err := ds.writer.ExecContext(ctx, updateTeam, tm.Config, tm.ID); err != nil {
 				return ctxerr.Wrap(ctx, err, "update team config")
 			}
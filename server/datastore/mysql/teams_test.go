@@ -25,6 +25,7 @@ func TestTeams(t *testing.T) {
 		{"Users", testTeamsUsers},
 		{"List", testTeamsList},
 		{"Summary", testTeamsSummary},
+		{"WithConfig", testTeamsWithConfig},
 		{"Search", testTeamsSearch},
 		{"EnrollSecrets", testTeamsEnrollSecrets},
 		{"TeamAgentOptions", testTeamsAgentOptions},
@@ -240,6 +241,32 @@ func testTeamsSummary(t *testing.T, ds *Datastore) {
 	assert.Equal(t, "ts2", teams[1].Name)
 }
 
+func testTeamsWithConfig(t *testing.T, ds *Datastore) {
+	teams, err := ds.TeamsWithConfig(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, teams)
+
+	_, err = ds.NewTeam(context.Background(), &fleet.Team{Name: "tc1"})
+	require.NoError(t, err)
+	_, err = ds.NewTeam(context.Background(), &fleet.Team{
+		Name: "tc2",
+		Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+			HostStatusWebhook: &fleet.HostStatusWebhookSettings{Enable: true, DestinationURL: "https://example.com"},
+		}},
+	})
+	require.NoError(t, err)
+
+	teams, err = ds.TeamsWithConfig(context.Background())
+	require.NoError(t, err)
+	require.Len(t, teams, 2)
+	assert.Equal(t, "tc1", teams[0].Name)
+	assert.Nil(t, teams[0].Config.WebhookSettings.HostStatusWebhook)
+	assert.Equal(t, "tc2", teams[1].Name)
+	require.NotNil(t, teams[1].Config.WebhookSettings.HostStatusWebhook)
+	assert.True(t, teams[1].Config.WebhookSettings.HostStatusWebhook.Enable)
+	assert.Equal(t, "https://example.com", teams[1].Config.WebhookSettings.HostStatusWebhook.DestinationURL)
+}
+
 func testTeamsSearch(t *testing.T, ds *Datastore) {
 	team1, err := ds.NewTeam(context.Background(), &fleet.Team{Name: "team1"})
 	require.NoError(t, err)
@@ -0,0 +1,135 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/jmoiron/sqlx"
+)
+
+// The two-factor authentication data is read from the primary, as it is
+// usually read right after being written (e.g. the enrollment is verified or
+// the login challenge is completed a few seconds after being created).
+
+func (ds *Datastore) UserTOTP(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+	var totp fleet.UserTOTP
+	err := sqlx.GetContext(ctx, ds.writer, &totp, `
+		SELECT user_id, created_at, updated_at, secret, enabled, last_step
+		FROM user_totp WHERE user_id = ?`, userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("UserTOTP").WithID(userID))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "selecting user totp")
+	}
+	return &totp, nil
+}
+
+func (ds *Datastore) SaveUserTOTP(ctx context.Context, totp *fleet.UserTOTP) error {
+	_, err := ds.writer.ExecContext(ctx, `
+		INSERT INTO user_totp (user_id, secret, enabled, last_step)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			secret = VALUES(secret),
+			enabled = VALUES(enabled),
+			last_step = VALUES(last_step)`,
+		totp.UserID, totp.Secret, totp.Enabled, totp.LastStep)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "saving user totp")
+	}
+	return nil
+}
+
+func (ds *Datastore) DeleteUserTOTP(ctx context.Context, userID uint) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return ctxerr.Wrap(ctx, err, "deleting user totp recovery codes")
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = ?`, userID); err != nil {
+			return ctxerr.Wrap(ctx, err, "deleting user totp")
+		}
+		return nil
+	})
+}
+
+func (ds *Datastore) ReplaceUserTOTPRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp_recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return ctxerr.Wrap(ctx, err, "deleting user totp recovery codes")
+		}
+		if len(codeHashes) == 0 {
+			return nil
+		}
+
+		values := make([]interface{}, 0, len(codeHashes)*2)
+		for _, hash := range codeHashes {
+			values = append(values, userID, hash)
+		}
+		stmt := `INSERT INTO user_totp_recovery_codes (user_id, code_hash) VALUES ` +
+			strings.TrimSuffix(strings.Repeat("(?, ?),", len(codeHashes)), ",")
+		if _, err := tx.ExecContext(ctx, stmt, values...); err != nil {
+			return ctxerr.Wrap(ctx, err, "inserting user totp recovery codes")
+		}
+		return nil
+	})
+}
+
+func (ds *Datastore) DeleteUserTOTPRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	res, err := ds.writer.ExecContext(ctx, `DELETE FROM user_totp_recovery_codes WHERE user_id = ? AND code_hash = ?`, userID, codeHash)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "deleting user totp recovery code")
+	}
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ctxerr.Wrap(ctx, notFound("TOTPRecoveryCode"))
+	}
+	return nil
+}
+
+func (ds *Datastore) NewTOTPLoginChallenge(ctx context.Context, challenge *fleet.TOTPLoginChallenge) error {
+	now := ds.clock.Now()
+	if _, err := ds.writer.ExecContext(ctx, `DELETE FROM totp_login_challenges WHERE created_at < ?`, now.Add(-fleet.TOTPLoginChallengeTTL)); err != nil {
+		return ctxerr.Wrap(ctx, err, "deleting expired totp login challenges")
+	}
+
+	_, err := ds.writer.ExecContext(ctx, `INSERT INTO totp_login_challenges (token, user_id, created_at) VALUES (?, ?, ?)`,
+		challenge.Token, challenge.UserID, now)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "inserting totp login challenge")
+	}
+	challenge.CreatedAt = now
+	return nil
+}
+
+func (ds *Datastore) TOTPLoginChallengeByToken(ctx context.Context, token string) (*fleet.TOTPLoginChallenge, error) {
+	var challenge fleet.TOTPLoginChallenge
+	err := sqlx.GetContext(ctx, ds.writer, &challenge, `
+		SELECT token, user_id, attempts, created_at
+		FROM totp_login_challenges WHERE token = ?`, token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("TOTPLoginChallenge"))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "selecting totp login challenge")
+	}
+	return &challenge, nil
+}
+
+func (ds *Datastore) IncrementTOTPLoginChallengeAttempts(ctx context.Context, token string) error {
+	_, err := ds.writer.ExecContext(ctx, `UPDATE totp_login_challenges SET attempts = attempts + 1 WHERE token = ?`, token)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "incrementing totp login challenge attempts")
+	}
+	return nil
+}
+
+func (ds *Datastore) DeleteTOTPLoginChallenge(ctx context.Context, token string) error {
+	_, err := ds.writer.ExecContext(ctx, `DELETE FROM totp_login_challenges WHERE token = ?`, token)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "deleting totp login challenge")
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/clock"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserTOTP(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"SaveAndDelete", testUserTOTPSaveAndDelete},
+		{"RecoveryCodes", testUserTOTPRecoveryCodes},
+		{"LoginChallenges", testUserTOTPLoginChallenges},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testUserTOTPSaveAndDelete(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Alice", "alice@example.com", true)
+
+	_, err := ds.UserTOTP(ctx, user.ID)
+	require.True(t, fleet.IsNotFound(err))
+
+	require.NoError(t, ds.SaveUserTOTP(ctx, &fleet.UserTOTP{UserID: user.ID, Secret: "ABC"}))
+	totp, err := ds.UserTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ABC", totp.Secret)
+	assert.False(t, totp.Enabled)
+	assert.Zero(t, totp.LastStep)
+
+	totp.Enabled = true
+	totp.LastStep = 42
+	require.NoError(t, ds.SaveUserTOTP(ctx, totp))
+	totp, err = ds.UserTOTP(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ABC", totp.Secret)
+	assert.True(t, totp.Enabled)
+	assert.EqualValues(t, 42, totp.LastStep)
+
+	require.NoError(t, ds.ReplaceUserTOTPRecoveryCodes(ctx, user.ID, []string{"a", "b"}))
+	require.NoError(t, ds.DeleteUserTOTP(ctx, user.ID))
+	_, err = ds.UserTOTP(ctx, user.ID)
+	require.True(t, fleet.IsNotFound(err))
+	err = ds.DeleteUserTOTPRecoveryCode(ctx, user.ID, "a")
+	require.True(t, fleet.IsNotFound(err))
+
+	// deleting is idempotent
+	require.NoError(t, ds.DeleteUserTOTP(ctx, user.ID))
+}
+
+func testUserTOTPRecoveryCodes(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	alice := test.NewUser(t, ds, "Alice", "alice@example.com", true)
+	bob := test.NewUser(t, ds, "Bob", "bob@example.com", true)
+
+	require.NoError(t, ds.ReplaceUserTOTPRecoveryCodes(ctx, alice.ID, []string{"a", "b", "c"}))
+	require.NoError(t, ds.ReplaceUserTOTPRecoveryCodes(ctx, bob.ID, []string{"d"}))
+
+	// a code can only be used once, and only by its user
+	require.NoError(t, ds.DeleteUserTOTPRecoveryCode(ctx, alice.ID, "a"))
+	err := ds.DeleteUserTOTPRecoveryCode(ctx, alice.ID, "a")
+	require.True(t, fleet.IsNotFound(err))
+	err = ds.DeleteUserTOTPRecoveryCode(ctx, alice.ID, "d")
+	require.True(t, fleet.IsNotFound(err))
+
+	// replacing the codes invalidates the previous ones
+	require.NoError(t, ds.ReplaceUserTOTPRecoveryCodes(ctx, alice.ID, []string{"e"}))
+	err = ds.DeleteUserTOTPRecoveryCode(ctx, alice.ID, "b")
+	require.True(t, fleet.IsNotFound(err))
+	require.NoError(t, ds.DeleteUserTOTPRecoveryCode(ctx, alice.ID, "e"))
+	require.NoError(t, ds.DeleteUserTOTPRecoveryCode(ctx, bob.ID, "d"))
+}
+
+func testUserTOTPLoginChallenges(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	user := test.NewUser(t, ds, "Alice", "alice@example.com", true)
+
+	_, err := ds.TOTPLoginChallengeByToken(ctx, "nope")
+	require.True(t, fleet.IsNotFound(err))
+
+	c1 := &fleet.TOTPLoginChallenge{Token: "c1", UserID: user.ID}
+	require.NoError(t, ds.NewTOTPLoginChallenge(ctx, c1))
+	require.False(t, c1.CreatedAt.IsZero())
+
+	got, err := ds.TOTPLoginChallengeByToken(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.UserID)
+	assert.Zero(t, got.Attempts)
+
+	require.NoError(t, ds.IncrementTOTPLoginChallengeAttempts(ctx, "c1"))
+	require.NoError(t, ds.IncrementTOTPLoginChallengeAttempts(ctx, "c1"))
+	got, err = ds.TOTPLoginChallengeByToken(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Attempts)
+
+	// the expired challenges are deleted when a new one is created
+	mc := ds.clock.(*clock.MockClock)
+	mc.AddTime(fleet.TOTPLoginChallengeTTL + time.Minute)
+	require.NoError(t, ds.NewTOTPLoginChallenge(ctx, &fleet.TOTPLoginChallenge{Token: "c2", UserID: user.ID}))
+	_, err = ds.TOTPLoginChallengeByToken(ctx, "c1")
+	require.True(t, fleet.IsNotFound(err))
+
+	require.NoError(t, ds.DeleteTOTPLoginChallenge(ctx, "c2"))
+	_, err = ds.TOTPLoginChallengeByToken(ctx, "c2")
+	require.True(t, fleet.IsNotFound(err))
+}
@@ -16,7 +16,7 @@ var userSearchColumns = []string{"name", "email"}
 
 // NewUser creates a new user
 func (ds *Datastore) NewUser(ctx context.Context, user *fleet.User) (*fleet.User, error) {
-	if err := fleet.ValidateRole(user.GlobalRole, user.Teams); err != nil {
+	if err := validateRole(ctx, ds.writer, user.GlobalRole, user.Teams); err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "validate role")
 	}
 
@@ -32,8 +32,9 @@ func (ds *Datastore) NewUser(ctx context.Context, user *fleet.User) (*fleet.User
       	position,
         sso_enabled,
 		api_only,
-		global_role
-      ) VALUES (?,?,?,?,?,?,?,?,?,?)
+		global_role,
+		disabled
+      ) VALUES (?,?,?,?,?,?,?,?,?,?,?)
       `
 		result, err := tx.ExecContext(ctx, sqlStatement,
 			user.Password,
@@ -45,7 +46,8 @@ func (ds *Datastore) NewUser(ctx context.Context, user *fleet.User) (*fleet.User
 			user.Position,
 			user.SSOEnabled,
 			user.APIOnly,
-			user.GlobalRole)
+			user.GlobalRole,
+			user.Disabled)
 		if err != nil {
 			return ctxerr.Wrap(ctx, err, "create new user")
 		}
@@ -150,7 +152,7 @@ func (ds *Datastore) SaveUsers(ctx context.Context, users []*fleet.User) error {
 }
 
 func saveUserDB(ctx context.Context, tx sqlx.ExtContext, user *fleet.User) error {
-	if err := fleet.ValidateRole(user.GlobalRole, user.Teams); err != nil {
+	if err := validateRole(ctx, tx, user.GlobalRole, user.Teams); err != nil {
 		return ctxerr.Wrap(ctx, err, "validate role")
 	}
 	sqlStatement := `
@@ -164,7 +166,8 @@ func saveUserDB(ctx context.Context, tx sqlx.ExtContext, user *fleet.User) error
       	position = ?,
         sso_enabled = ?,
         api_only = ?,
-		global_role = ?
+		global_role = ?,
+		disabled = ?
       WHERE id = ?
       `
 	result, err := tx.ExecContext(ctx, sqlStatement,
@@ -178,6 +181,7 @@ func saveUserDB(ctx context.Context, tx sqlx.ExtContext, user *fleet.User) error
 		user.SSOEnabled,
 		user.APIOnly,
 		user.GlobalRole,
+		user.Disabled,
 		user.ID)
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "save user")
@@ -301,3 +305,51 @@ func amountActiveUsersSinceDB(ctx context.Context, db sqlx.QueryerContext, since
 	}
 	return amount, nil
 }
+
+func (ds *Datastore) NewPasswordHistory(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+	return ds.withRetryTxx(ctx, func(tx sqlx.ExtContext) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO password_history (user_id, password, salt) VALUES (?, ?, ?)`,
+			userID, password, salt)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "inserting password history")
+		}
+
+		// the derived table is required as MySQL does not allow to select from
+		// the table being deleted from.
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM password_history
+			WHERE user_id = ? AND id < (
+				SELECT id FROM (
+					SELECT id FROM password_history
+					WHERE user_id = ?
+					ORDER BY id DESC
+					LIMIT 1 OFFSET ?
+				) AS oldest_kept
+			)`, userID, userID, keep-1)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "deleting old password history")
+		}
+		return nil
+	})
+}
+
+// ListPasswordHistory reads from the primary, as the history is checked right
+// after a password is set (e.g. the expiry of the password on login after a
+// required password reset).
+func (ds *Datastore) ListPasswordHistory(ctx context.Context, userID uint, limit int) ([]*fleet.PasswordHistoryEntry, error) {
+	var entries []*fleet.PasswordHistoryEntry
+	err := sqlx.SelectContext(ctx, ds.writer, &entries, `
+		SELECT user_id, password, salt, created_at
+		FROM password_history
+		WHERE user_id = ?
+		ORDER BY id DESC
+		LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "listing password history")
+	}
+	return entries, nil
+}
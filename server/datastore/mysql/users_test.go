@@ -30,6 +30,7 @@ func TestUsers(t *testing.T) {
 		{"Teams", testUsersTeams},
 		{"CreateWithTeams", testUsersCreateWithTeams},
 		{"SaveMany", testUsersSaveMany},
+		{"PasswordHistory", testUsersPasswordHistory},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -119,6 +120,19 @@ func testUsersSave(t *testing.T, ds *Datastore) {
 	testUserGlobalRole(t, ds, users)
 	testEmailAttribute(t, ds, users)
 	testPasswordAttribute(t, ds, users)
+	testDisabledAttribute(t, ds, users)
+}
+
+func testDisabledAttribute(t *testing.T, ds fleet.Datastore, users []*fleet.User) {
+	for _, user := range users {
+		user.Disabled = true
+		err := ds.SaveUser(context.Background(), user)
+		assert.Nil(t, err)
+
+		verify, err := ds.UserByID(context.Background(), user.ID)
+		assert.Nil(t, err)
+		assert.True(t, verify.Disabled)
+	}
 }
 
 func testPasswordAttribute(t *testing.T, ds fleet.Datastore, users []*fleet.User) {
@@ -340,3 +354,46 @@ func testUsersSaveMany(t *testing.T, ds *Datastore) {
 	require.NoError(t, err)
 	assert.True(t, strings.HasSuffix(gotU3.Email, "fleet.com"))
 }
+
+func testUsersPasswordHistory(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+	u1 := test.NewUser(t, ds, "Admin1", "admin1@fleet.co", true)
+	u2 := test.NewUser(t, ds, "Admin2", "admin2@fleet.co", true)
+
+	history, err := ds.ListPasswordHistory(ctx, u1.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	for _, pwd := range []string{"p1", "p2", "p3", "p4"} {
+		require.NoError(t, ds.NewPasswordHistory(ctx, u1.ID, []byte(pwd), "salt-"+pwd, 3))
+	}
+	require.NoError(t, ds.NewPasswordHistory(ctx, u2.ID, []byte("other"), "salt", 3))
+
+	// only the 3 most recent passwords are kept, most recent first
+	history, err = ds.ListPasswordHistory(ctx, u1.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	for i, pwd := range []string{"p4", "p3", "p2"} {
+		assert.Equal(t, u1.ID, history[i].UserID)
+		assert.Equal(t, pwd, string(history[i].Password))
+		assert.Equal(t, "salt-"+pwd, history[i].Salt)
+		assert.False(t, history[i].CreatedAt.IsZero())
+	}
+
+	history, err = ds.ListPasswordHistory(ctx, u1.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "p4", string(history[0].Password))
+
+	// keeping fewer passwords removes the older ones, the other users are not
+	// affected
+	require.NoError(t, ds.NewPasswordHistory(ctx, u1.ID, []byte("p5"), "salt-p5", 0))
+	history, err = ds.ListPasswordHistory(ctx, u1.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "p5", string(history[0].Password))
+
+	history, err = ds.ListPasswordHistory(ctx, u2.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+}
@@ -0,0 +1,152 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/jmoiron/sqlx"
+)
+
+const webhookDeliveryFields = `
+    id,
+    created_at,
+    updated_at,
+    webhook_type,
+    team_id,
+    url,
+    payload,
+    status,
+    attempts,
+    last_status_code,
+    last_latency_ms,
+    last_attempt_at,
+    error`
+
+func (ds *Datastore) NewWebhookDelivery(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+	query := `
+INSERT INTO webhook_deliveries (
+    webhook_type,
+    team_id,
+    url,
+    payload,
+    status,
+    attempts,
+    last_status_code,
+    last_latency_ms,
+    last_attempt_at,
+    error
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+	result, err := ds.writer.ExecContext(ctx, query,
+		delivery.WebhookType,
+		delivery.TeamID,
+		delivery.URL,
+		[]byte(delivery.Payload),
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastLatencyMs,
+		delivery.LastAttemptAt,
+		delivery.Error,
+	)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "insert webhook delivery")
+	}
+
+	id, _ := result.LastInsertId()
+	delivery.ID = uint(id)
+	return delivery, nil
+}
+
+func (ds *Datastore) WebhookDelivery(ctx context.Context, id uint) (*fleet.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryFields + ` FROM webhook_deliveries WHERE id = ?`
+
+	var delivery fleet.WebhookDelivery
+	if err := sqlx.GetContext(ctx, ds.reader, &delivery, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ctxerr.Wrap(ctx, notFound("WebhookDelivery").WithID(id))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "select webhook delivery")
+	}
+	return &delivery, nil
+}
+
+func (ds *Datastore) UpdateWebhookDelivery(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+	query := `
+UPDATE webhook_deliveries
+SET
+    status = ?,
+    attempts = ?,
+    last_status_code = ?,
+    last_latency_ms = ?,
+    last_attempt_at = ?,
+    error = ?
+WHERE
+    id = ?
+`
+	_, err := ds.writer.ExecContext(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastLatencyMs,
+		delivery.LastAttemptAt,
+		delivery.Error,
+		delivery.ID,
+	)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "update webhook delivery")
+	}
+	return nil
+}
+
+func (ds *Datastore) ListWebhookDeliveries(ctx context.Context, opts fleet.WebhookDeliveryListOptions) ([]*fleet.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryFields + ` FROM webhook_deliveries WHERE true`
+
+	var args []interface{}
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, opts.Status)
+	}
+	if opts.WebhookType != "" {
+		query += ` AND webhook_type = ?`
+		args = append(args, opts.WebhookType)
+	}
+	if opts.OrderKey == "" {
+		// most recent deliveries first by default
+		opts.OrderKey = "id"
+		opts.OrderDirection = fleet.OrderDescending
+	}
+	query, args = appendListOptionsWithCursorToSQL(query, args, opts.ListOptions)
+
+	deliveries := []*fleet.WebhookDelivery{}
+	if err := sqlx.SelectContext(ctx, ds.reader, &deliveries, query, args...); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "select webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+// webhookDeliveriesCleanupBatchSize is the number of webhook deliveries
+// deleted per statement.
+const webhookDeliveriesCleanupBatchSize = 1000
+
+func (ds *Datastore) CleanupWebhookDeliveries(ctx context.Context, before time.Time) (uint, error) {
+	var deleted uint
+	for {
+		res, err := ds.writer.ExecContext(ctx,
+			`DELETE FROM webhook_deliveries WHERE created_at < ? LIMIT ?`,
+			before, webhookDeliveriesCleanupBatchSize,
+		)
+		if err != nil {
+			return deleted, ctxerr.Wrap(ctx, err, "delete webhook deliveries")
+		}
+		n, _ := res.RowsAffected()
+		deleted += uint(n)
+		if n < webhookDeliveriesCleanupBatchSize {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	ds := CreateMySQLDS(t)
+
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, ds *Datastore)
+	}{
+		{"CreateUpdate", testWebhookDeliveriesCreateUpdate},
+		{"List", testWebhookDeliveriesList},
+		{"Cleanup", testWebhookDeliveriesCleanup},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			defer TruncateTables(t, ds)
+			c.fn(t, ds)
+		})
+	}
+}
+
+func testWebhookDeliveriesCreateUpdate(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	_, err := ds.WebhookDelivery(ctx, 1)
+	require.Error(t, err)
+	require.True(t, fleet.IsNotFound(err))
+
+	d, err := ds.NewWebhookDelivery(ctx, &fleet.WebhookDelivery{
+		WebhookType: fleet.WebhookTypeFailingPolicies,
+		TeamID:      ptr.Uint(1),
+		URL:         "http://example.com",
+		Payload:     json.RawMessage(`{"a": 1}`),
+		Status:      fleet.WebhookDeliveryPending,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, d.ID)
+
+	got, err := ds.WebhookDelivery(ctx, d.ID)
+	require.NoError(t, err)
+	require.Equal(t, fleet.WebhookTypeFailingPolicies, got.WebhookType)
+	require.Equal(t, ptr.Uint(1), got.TeamID)
+	require.Equal(t, "http://example.com", got.URL)
+	require.JSONEq(t, `{"a": 1}`, string(got.Payload))
+	require.Equal(t, fleet.WebhookDeliveryPending, got.Status)
+	require.Zero(t, got.Attempts)
+	require.Nil(t, got.LastStatusCode)
+	require.Nil(t, got.LastLatencyMs)
+	require.Nil(t, got.LastAttemptAt)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	got.Status = fleet.WebhookDeliveryFailed
+	got.Attempts = 1
+	got.LastStatusCode = ptr.Int(500)
+	got.LastLatencyMs = ptr.Int64(123)
+	got.LastAttemptAt = &now
+	got.Error = "server error"
+	err = ds.UpdateWebhookDelivery(ctx, got)
+	require.NoError(t, err)
+
+	got, err = ds.WebhookDelivery(ctx, d.ID)
+	require.NoError(t, err)
+	require.Equal(t, fleet.WebhookDeliveryFailed, got.Status)
+	require.Equal(t, 1, got.Attempts)
+	require.Equal(t, 500, *got.LastStatusCode)
+	require.Equal(t, int64(123), *got.LastLatencyMs)
+	require.Equal(t, now, got.LastAttemptAt.UTC())
+	require.Equal(t, "server error", got.Error)
+}
+
+func testWebhookDeliveriesList(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	deliveries := []struct {
+		typ    string
+		status fleet.WebhookDeliveryStatus
+	}{
+		{fleet.WebhookTypeHostStatus, fleet.WebhookDeliverySuccess},
+		{fleet.WebhookTypeFailingPolicies, fleet.WebhookDeliverySuccess},
+		{fleet.WebhookTypeFailingPolicies, fleet.WebhookDeliveryDeadLetter},
+		{fleet.WebhookTypeVulnerabilities, fleet.WebhookDeliveryFailed},
+	}
+	var ids []uint
+	for _, d := range deliveries {
+		created, err := ds.NewWebhookDelivery(ctx, &fleet.WebhookDelivery{
+			WebhookType: d.typ,
+			URL:         "http://example.com",
+			Payload:     json.RawMessage(`{}`),
+			Status:      d.status,
+		})
+		require.NoError(t, err)
+		ids = append(ids, created.ID)
+	}
+
+	idsOf := func(list []*fleet.WebhookDelivery) []uint {
+		var res []uint
+		for _, d := range list {
+			res = append(res, d.ID)
+		}
+		return res
+	}
+
+	cases := []struct {
+		desc string
+		opts fleet.WebhookDeliveryListOptions
+		want []uint
+	}{
+		{"all", fleet.WebhookDeliveryListOptions{}, []uint{ids[3], ids[2], ids[1], ids[0]}},
+		{"by status", fleet.WebhookDeliveryListOptions{Status: fleet.WebhookDeliverySuccess}, []uint{ids[1], ids[0]}},
+		{"by type", fleet.WebhookDeliveryListOptions{WebhookType: fleet.WebhookTypeFailingPolicies}, []uint{ids[2], ids[1]}},
+		{"by status and type", fleet.WebhookDeliveryListOptions{Status: fleet.WebhookDeliveryDeadLetter, WebhookType: fleet.WebhookTypeFailingPolicies}, []uint{ids[2]}},
+		{"no match", fleet.WebhookDeliveryListOptions{Status: fleet.WebhookDeliveryPending}, nil},
+		{"ordered asc", fleet.WebhookDeliveryListOptions{ListOptions: fleet.ListOptions{OrderKey: "id", OrderDirection: fleet.OrderAscending}}, ids},
+		{"paginated", fleet.WebhookDeliveryListOptions{ListOptions: fleet.ListOptions{Page: 1, PerPage: 3}}, []uint{ids[0]}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			list, err := ds.ListWebhookDeliveries(ctx, c.opts)
+			require.NoError(t, err)
+			require.Equal(t, c.want, idsOf(list))
+		})
+	}
+}
+
+func testWebhookDeliveriesCleanup(t *testing.T, ds *Datastore) {
+	ctx := context.Background()
+
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		d, err := ds.NewWebhookDelivery(ctx, &fleet.WebhookDelivery{
+			WebhookType: fleet.WebhookTypeHostStatus,
+			URL:         "http://example.com",
+			Payload:     json.RawMessage(`{}`),
+			Status:      fleet.WebhookDeliverySuccess,
+		})
+		require.NoError(t, err)
+		ids = append(ids, d.ID)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := ds.writer.ExecContext(ctx, `UPDATE webhook_deliveries SET created_at = ? WHERE id < ?`, now.Add(-48*time.Hour), ids[2])
+	require.NoError(t, err)
+
+	deleted, err := ds.CleanupWebhookDeliveries(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, uint(2), deleted)
+
+	list, err := ds.ListWebhookDeliveries(ctx, fleet.WebhookDeliveryListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	require.Equal(t, ids[2], list[0].ID)
+}
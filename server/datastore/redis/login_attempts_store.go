@@ -0,0 +1,113 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/gomodule/redigo/redis"
+)
+
+// LoginAttemptsStore is the Redis implementation of fleet.LoginAttemptsStore.
+// The count and lock keys of an account share the same hash tag so that they
+// live on the same node in Redis Cluster.
+type LoginAttemptsStore struct {
+	Pool      fleet.RedisPool
+	KeyPrefix string
+}
+
+var _ fleet.LoginAttemptsStore = (*LoginAttemptsStore)(nil)
+
+const incrWithTTLScript = `
+local n = redis.call('INCR', KEYS[1])
+if n == 1 then
+  redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`
+
+func (s *LoginAttemptsStore) countKey(email string) string {
+	return s.KeyPrefix + "{" + email + "}:count"
+}
+
+func (s *LoginAttemptsStore) lockedKey(email string) string {
+	return s.KeyPrefix + "{" + email + "}:locked"
+}
+
+func (s *LoginAttemptsStore) IncrFailedLogins(ctx context.Context, email string, window time.Duration) (int, error) {
+	key := s.countKey(email)
+
+	conn := s.Pool.Get()
+	defer conn.Close()
+	if err := BindConn(s.Pool, conn, key); err != nil {
+		return 0, ctxerr.Wrap(ctx, err, "bind redis connection")
+	}
+	// must come after BindConn due to redisc restrictions
+	conn = ConfigureDoer(s.Pool, conn)
+
+	script := redis.NewScript(1, incrWithTTLScript)
+	n, err := redis.Int(script.Do(conn, key, ttlSeconds(window)))
+	if err != nil {
+		return 0, ctxerr.Wrap(ctx, err, "increment failed logins")
+	}
+	return n, nil
+}
+
+func (s *LoginAttemptsStore) ResetFailedLogins(ctx context.Context, email string) error {
+	conn := ConfigureDoer(s.Pool, s.Pool.Get())
+	defer conn.Close()
+
+	if _, err := conn.Do("DEL", s.countKey(email)); err != nil {
+		return ctxerr.Wrap(ctx, err, "reset failed logins")
+	}
+	return nil
+}
+
+func (s *LoginAttemptsStore) LockAccount(ctx context.Context, email string, duration time.Duration) error {
+	conn := ConfigureDoer(s.Pool, s.Pool.Get())
+	defer conn.Close()
+
+	if _, err := conn.Do("SET", s.lockedKey(email), 1, "EX", ttlSeconds(duration)); err != nil {
+		return ctxerr.Wrap(ctx, err, "lock account")
+	}
+	return nil
+}
+
+func (s *LoginAttemptsStore) IsAccountLocked(ctx context.Context, email string) (bool, error) {
+	conn := ConfigureDoer(s.Pool, s.Pool.Get())
+	defer conn.Close()
+
+	locked, err := redis.Bool(conn.Do("EXISTS", s.lockedKey(email)))
+	if err != nil {
+		return false, ctxerr.Wrap(ctx, err, "check account locked")
+	}
+	return locked, nil
+}
+
+func (s *LoginAttemptsStore) UnlockAccount(ctx context.Context, email string) error {
+	countKey, lockedKey := s.countKey(email), s.lockedKey(email)
+
+	conn := s.Pool.Get()
+	defer conn.Close()
+	if err := BindConn(s.Pool, conn, countKey, lockedKey); err != nil {
+		return ctxerr.Wrap(ctx, err, "bind redis connection")
+	}
+	// must come after BindConn due to redisc restrictions
+	conn = ConfigureDoer(s.Pool, conn)
+
+	if _, err := conn.Do("DEL", countKey, lockedKey); err != nil {
+		return ctxerr.Wrap(ctx, err, "unlock account")
+	}
+	return nil
+}
+
+// ttlSeconds returns the duration in seconds to use as expiration, an `EX 0`
+// fails so it is at least one second.
+func ttlSeconds(d time.Duration) int {
+	secs := int(d.Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
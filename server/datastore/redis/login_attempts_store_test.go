@@ -0,0 +1,93 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/datastore/redis"
+	"github.com/fleetdm/fleet/v4/server/datastore/redis/redistest"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginAttemptsStore(t *testing.T) {
+	const prefix = "TestLoginAttemptsStore:"
+	ctx := context.Background()
+
+	runTest := func(t *testing.T, pool fleet.RedisPool) {
+		store := &redis.LoginAttemptsStore{
+			Pool:      pool,
+			KeyPrefix: prefix,
+		}
+
+		t.Run("FailedLogins", func(t *testing.T) {
+			n, err := store.IncrFailedLogins(ctx, "a@example.com", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+			n, err = store.IncrFailedLogins(ctx, "a@example.com", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 2, n)
+
+			// other accounts are not affected
+			n, err = store.IncrFailedLogins(ctx, "b@example.com", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+
+			// the count expires after the window
+			conn := redis.ConfigureDoer(pool, pool.Get())
+			defer conn.Close()
+			ttl, err := redigo.Int(conn.Do("TTL", prefix+"{a@example.com}:count"))
+			require.NoError(t, err)
+			require.True(t, ttl > 0 && ttl <= 60, ttl)
+
+			require.NoError(t, store.ResetFailedLogins(ctx, "a@example.com"))
+			n, err = store.IncrFailedLogins(ctx, "a@example.com", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+		})
+
+		t.Run("Lock", func(t *testing.T) {
+			locked, err := store.IsAccountLocked(ctx, "c@example.com")
+			require.NoError(t, err)
+			require.False(t, locked)
+
+			_, err = store.IncrFailedLogins(ctx, "c@example.com", time.Minute)
+			require.NoError(t, err)
+			require.NoError(t, store.LockAccount(ctx, "c@example.com", time.Minute))
+			locked, err = store.IsAccountLocked(ctx, "c@example.com")
+			require.NoError(t, err)
+			require.True(t, locked)
+
+			// unlocking also resets the count
+			require.NoError(t, store.UnlockAccount(ctx, "c@example.com"))
+			locked, err = store.IsAccountLocked(ctx, "c@example.com")
+			require.NoError(t, err)
+			require.False(t, locked)
+			n, err := store.IncrFailedLogins(ctx, "c@example.com", time.Minute)
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+
+			// the lock expires, a duration of less than a second locks for a second
+			require.NoError(t, store.LockAccount(ctx, "d@example.com", time.Millisecond))
+			locked, err = store.IsAccountLocked(ctx, "d@example.com")
+			require.NoError(t, err)
+			require.True(t, locked)
+			time.Sleep(1100 * time.Millisecond)
+			locked, err = store.IsAccountLocked(ctx, "d@example.com")
+			require.NoError(t, err)
+			require.False(t, locked)
+		})
+	}
+
+	t.Run("standalone", func(t *testing.T) {
+		pool := redistest.SetupRedis(t, prefix, false, false, false)
+		runTest(t, pool)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		pool := redistest.SetupRedis(t, prefix, true, true, false)
+		runTest(t, pool)
+	})
+}
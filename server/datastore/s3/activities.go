@@ -0,0 +1,227 @@
+package s3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/fleetdm/fleet/v4/server/config"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+const activitiesArchivePath = "activities"
+
+// ActivityArchiveStore archives the activities to S3. Each call to
+// ArchiveActivities stores a gzip-compressed, newline-delimited JSON object
+// under the month of the activities, named after the range of their IDs:
+//
+//	<prefix>/activities/2022-08/00000000000000000001-00000000000000000500.ndjson.gz
+//
+// The IDs are zero-padded so that the objects are listed in ID order.
+type ActivityArchiveStore struct {
+	*s3store
+}
+
+var _ fleet.ActivityArchiveStore = (*ActivityArchiveStore)(nil)
+
+// NewActivityArchiveStore creates a new instance with the given S3 config
+func NewActivityArchiveStore(config config.S3Config) (*ActivityArchiveStore, error) {
+	s3store, err := newS3store(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ActivityArchiveStore{s3store}, nil
+}
+
+func (a *ActivityArchiveStore) archivePrefix() string {
+	return path.Join(a.prefix, activitiesArchivePath) + "/"
+}
+
+func (a *ActivityArchiveStore) monthPrefix(month string) string {
+	return a.archivePrefix() + month + "/"
+}
+
+// ArchiveActivities uploads the activities, which must be ordered by ID and
+// all have been created in the same month. Archiving the same activities again
+// overwrites the same object.
+func (a *ActivityArchiveStore) ArchiveActivities(ctx context.Context, activities []*fleet.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+
+	month := activities[0].CreatedAt.UTC().Format(fleet.ActivityArchiveMonthFormat)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, activity := range activities {
+		if m := activity.CreatedAt.UTC().Format(fleet.ActivityArchiveMonthFormat); m != month {
+			return ctxerr.Errorf(ctx, "activity %d was created in %s, not %s", activity.ID, m, month)
+		}
+		if err := enc.Encode(activity); err != nil {
+			return ctxerr.Wrap(ctx, err, "encode activity")
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return ctxerr.Wrap(ctx, err, "compress activities")
+	}
+
+	key := fmt.Sprintf("%s%020d-%020d.ndjson.gz", a.monthPrefix(month), activities[0].ID, activities[len(activities)-1].ID)
+	_, err := a.s3client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      &a.bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String("application/x-ndjson"),
+	})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "put archived activities")
+	}
+	return nil
+}
+
+// ListArchivedActivityMonths returns the months that have archived activities,
+// oldest first.
+func (a *ActivityArchiveStore) ListArchivedActivityMonths(ctx context.Context) ([]string, error) {
+	prefix := a.archivePrefix()
+	months := []string{}
+	err := a.s3client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:    &a.bucket,
+		Prefix:    &prefix,
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, p := range page.CommonPrefixes {
+			months = append(months, strings.TrimSuffix(strings.TrimPrefix(*p.Prefix, prefix), "/"))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list archived activity months")
+	}
+	return months, nil
+}
+
+// parseArchivedActivitiesKey returns the range of the IDs of the activities
+// stored in the object, from its key.
+func parseArchivedActivitiesKey(key string) (first, last uint, ok bool) {
+	name := strings.TrimSuffix(path.Base(key), ".ndjson.gz")
+	firstStr, lastStr, found := strings.Cut(name, "-")
+	if !found {
+		return 0, 0, false
+	}
+	f, err := strconv.ParseUint(firstStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	l, err := strconv.ParseUint(lastStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(f), uint(l), true
+}
+
+// ListArchivedActivities returns a page of the activities archived for the
+// month, ordered by ID. If PerPage is 0, all the activities of the month are
+// returned. If After is set, only the activities with a greater ID are
+// returned. The objects that only contain activities up to After, or that
+// were already read, are skipped without being downloaded using the range of
+// IDs in their key.
+func (a *ActivityArchiveStore) ListArchivedActivities(ctx context.Context, month string, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+	// an activity may have been archived twice if its deletion failed, the
+	// objects are in ID order so the duplicates are skipped using the last ID.
+	var lastID uint
+	if opt.After != "" {
+		after, err := strconv.ParseUint(opt.After, 10, 64)
+		if err != nil {
+			return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("after", "must be an activity ID"))
+		}
+		lastID = uint(after)
+	}
+
+	prefix := a.monthPrefix(month)
+	var keys []string
+	err := a.s3client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: &a.bucket,
+		Prefix: &prefix,
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, *obj.Key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list archived activities objects")
+	}
+
+	skip := opt.Page * opt.PerPage
+	activities := []*fleet.Activity{}
+	for _, key := range keys {
+		if _, last, ok := parseArchivedActivitiesKey(key); ok && last <= lastID {
+			continue
+		}
+		done := false
+		err := a.readArchivedActivities(ctx, key, func(activity *fleet.Activity) bool {
+			if activity.ID <= lastID {
+				return true
+			}
+			lastID = activity.ID
+			if skip > 0 {
+				skip--
+				return true
+			}
+			activities = append(activities, activity)
+			done = opt.PerPage > 0 && uint(len(activities)) >= opt.PerPage
+			return !done
+		})
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			break
+		}
+	}
+	return activities, nil
+}
+
+// readArchivedActivities decodes the activities of the archived object and
+// calls fn for each of them until it returns false.
+func (a *ActivityArchiveStore) readArchivedActivities(ctx context.Context, key string, fn func(*fleet.Activity) bool) error {
+	res, err := a.s3client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &a.bucket, Key: &key})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			// the object was removed after being listed, e.g. by a bucket
+			// lifecycle rule
+			return nil
+		}
+		return ctxerr.Wrap(ctx, err, "get archived activities")
+	}
+	defer res.Body.Close()
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "decompress archived activities")
+	}
+	defer zr.Close()
+
+	dec := json.NewDecoder(zr)
+	for {
+		var activity fleet.Activity
+		if err := dec.Decode(&activity); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return ctxerr.Wrap(ctx, err, "decode archived activity")
+		}
+		if !fn(&activity) {
+			return nil
+		}
+	}
+}
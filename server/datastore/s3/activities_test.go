@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityArchive(t *testing.T) {
+	ctx := context.Background()
+	store := SetupTestActivityArchiveStore(t, "activities-unit-test", "archive-prefix")
+
+	newActivities := func(month time.Time, ids ...uint) []*fleet.Activity {
+		var activities []*fleet.Activity
+		for _, id := range ids {
+			a := &fleet.Activity{ID: id, Type: "test"}
+			a.CreatedAt = month.Add(time.Duration(id) * time.Minute)
+			activities = append(activities, a)
+		}
+		return activities
+	}
+	ids := func(activities []*fleet.Activity) []uint {
+		var res []uint
+		for _, a := range activities {
+			res = append(res, a.ID)
+		}
+		return res
+	}
+
+	months, err := store.ListArchivedActivityMonths(ctx)
+	require.NoError(t, err)
+	require.Empty(t, months)
+
+	aug := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	sep := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, store.ArchiveActivities(ctx, newActivities(aug, 1, 2, 3)))
+	require.NoError(t, store.ArchiveActivities(ctx, newActivities(aug, 4, 5)))
+	require.NoError(t, store.ArchiveActivities(ctx, newActivities(sep, 6)))
+	// archiving again, e.g. after a failed deletion, does not duplicate
+	require.NoError(t, store.ArchiveActivities(ctx, newActivities(aug, 3, 4)))
+
+	// activities of different months cannot be archived together
+	mixed := append(newActivities(aug, 7), newActivities(sep, 8)...)
+	require.Error(t, store.ArchiveActivities(ctx, mixed))
+
+	months, err = store.ListArchivedActivityMonths(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2022-08", "2022-09"}, months)
+
+	activities, err := store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []uint{1, 2, 3, 4, 5}, ids(activities))
+	require.Equal(t, "test", activities[0].Type)
+	require.Equal(t, aug.Add(time.Minute), activities[0].CreatedAt.UTC())
+
+	activities, err = store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{Page: 1, PerPage: 2})
+	require.NoError(t, err)
+	require.Equal(t, []uint{3, 4}, ids(activities))
+
+	activities, err = store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{Page: 3, PerPage: 2})
+	require.NoError(t, err)
+	require.Empty(t, activities)
+
+	// the activities after a cursor, the objects before it are skipped
+	activities, err = store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{After: "3", PerPage: 1})
+	require.NoError(t, err)
+	require.Equal(t, []uint{4}, ids(activities))
+
+	activities, err = store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{After: "5"})
+	require.NoError(t, err)
+	require.Empty(t, activities)
+
+	_, err = store.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{After: "foo"})
+	require.Error(t, err)
+
+	activities, err = store.ListArchivedActivities(ctx, "2022-07", fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, activities)
+}
@@ -0,0 +1,380 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/fleetdm/fleet/v4/server/config"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+const liveQueryExportPath = "live-query-results"
+
+// liveQueryExportCSVColumns are the leading columns of the CSV records, the
+// columns of the query follow.
+var liveQueryExportCSVColumns = []string{"host_id", "hostname", "error"}
+
+// The metadata of the combined results object record the parts it combined:
+// their number and the last modification time of the most recent one.
+const (
+	liveQueryExportPartsMetadata    = "Parts"
+	liveQueryExportModifiedMetadata = "Parts-Modified"
+)
+
+// LiveQueryExportStore exports the results of the live query campaigns to S3.
+// The result of each host is stored as a part named after the host ID, and the
+// parts are combined in a single object once the campaign completes:
+//
+//	<prefix>/live-query-results/42/parts/00000000000000000001.csv
+//	<prefix>/live-query-results/42/results.csv
+//
+// The IDs are zero-padded so that the parts are listed in host ID order.
+type LiveQueryExportStore struct {
+	*s3store
+}
+
+var _ fleet.LiveQueryExportStore = (*LiveQueryExportStore)(nil)
+
+// NewLiveQueryExportStore creates a new instance with the given S3 config
+func NewLiveQueryExportStore(config config.S3Config) (*LiveQueryExportStore, error) {
+	s3store, err := newS3store(config)
+	if err != nil {
+		return nil, err
+	}
+	return &LiveQueryExportStore{s3store}, nil
+}
+
+func (l *LiveQueryExportStore) campaignPrefix(campaignID uint) string {
+	return path.Join(l.prefix, liveQueryExportPath, strconv.FormatUint(uint64(campaignID), 10)) + "/"
+}
+
+func (l *LiveQueryExportStore) partsPrefix(campaignID uint) string {
+	return l.campaignPrefix(campaignID) + "parts/"
+}
+
+func (l *LiveQueryExportStore) resultsKey(campaignID uint, format fleet.LiveQueryExportFormat) string {
+	return l.campaignPrefix(campaignID) + "results." + string(format)
+}
+
+func liveQueryExportContentType(format fleet.LiveQueryExportFormat) string {
+	if format == fleet.LiveQueryExportCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// WriteCampaignResultPart uploads the result of the host as a part of the
+// export of the campaign.
+func (l *LiveQueryExportStore) WriteCampaignResultPart(ctx context.Context, format fleet.LiveQueryExportFormat, result *fleet.DistributedQueryCampaignResult) error {
+	var buf bytes.Buffer
+	switch format {
+	case fleet.LiveQueryExportNDJSON:
+		if err := json.NewEncoder(&buf).Encode(result); err != nil {
+			return ctxerr.Wrap(ctx, err, "encode result part")
+		}
+	case fleet.LiveQueryExportCSV:
+		if err := writeResultCSV(&buf, result); err != nil {
+			return ctxerr.Wrap(ctx, err, "encode result part")
+		}
+	default:
+		return ctxerr.Errorf(ctx, "unsupported export format %q", format)
+	}
+
+	key := fmt.Sprintf("%s%020d.%s", l.partsPrefix(result.DistributedQueryCampaignID), result.HostID, format)
+	_, err := l.s3client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      &l.bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(buf.Bytes()),
+		ContentType: aws.String(liveQueryExportContentType(format)),
+	})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "put result part")
+	}
+	return nil
+}
+
+// writeResultCSV writes the header and the records of the result. The
+// columns of the query are sorted, and a failed host has a single record
+// with its error.
+func writeResultCSV(w io.Writer, result *fleet.DistributedQueryCampaignResult) error {
+	colSet := make(map[string]struct{})
+	for _, row := range result.Rows {
+		for col := range row {
+			colSet[col] = struct{}{}
+		}
+	}
+	cols := make([]string, 0, len(colSet))
+	for col := range colSet {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, liveQueryExportCSVColumns...), cols...)); err != nil {
+		return err
+	}
+
+	hostID := strconv.FormatUint(uint64(result.HostID), 10)
+	if result.Error != nil {
+		rec := make([]string, len(liveQueryExportCSVColumns)+len(cols))
+		rec[0], rec[1], rec[2] = hostID, result.Hostname, *result.Error
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	for _, row := range result.Rows {
+		rec := []string{hostID, result.Hostname, ""}
+		for _, col := range cols {
+			rec = append(rec, row[col])
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CombineCampaignExport concatenates the parts of the campaign in the results
+// object. For the CSV format, the records are written with the union of the
+// columns of all the parts. The parts are combined again if some of them were
+// written or replaced since, as the results of the hosts may arrive after the
+// campaign completed.
+func (l *LiveQueryExportStore) CombineCampaignExport(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat) error {
+	keys, modified, err := l.listParts(ctx, campaignID, format)
+	if err != nil {
+		return err
+	}
+
+	key := l.resultsKey(campaignID, format)
+	metadata, err := l.objectMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		combinedParts, _ := strconv.Atoi(metadataValue(metadata, liveQueryExportPartsMetadata))
+		combinedModified, _ := time.Parse(time.RFC3339Nano, metadataValue(metadata, liveQueryExportModifiedMetadata))
+		if combinedParts == len(keys) && !modified.After(combinedModified) {
+			return nil
+		}
+	}
+
+	var combine func(w io.Writer) error
+	switch format {
+	case fleet.LiveQueryExportNDJSON:
+		combine = func(w io.Writer) error { return l.combineNDJSON(ctx, w, keys) }
+	case fleet.LiveQueryExportCSV:
+		cols, err := l.csvColumns(ctx, keys)
+		if err != nil {
+			return err
+		}
+		combine = func(w io.Writer) error { return l.combineCSV(ctx, w, keys, cols) }
+	default:
+		return ctxerr.Errorf(ctx, "unsupported export format %q", format)
+	}
+
+	// the parts are streamed to the upload, so that the results of a large
+	// campaign are never held in memory
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(combine(pw))
+	}()
+	uploader := s3manager.NewUploaderWithClient(l.s3client)
+	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      &l.bucket,
+		Key:         &key,
+		Body:        pr,
+		ContentType: aws.String(liveQueryExportContentType(format)),
+		Metadata: map[string]*string{
+			liveQueryExportPartsMetadata:    aws.String(strconv.Itoa(len(keys))),
+			liveQueryExportModifiedMetadata: aws.String(modified.UTC().Format(time.RFC3339Nano)),
+		},
+	})
+	// unblock the writer if the upload failed before reading everything
+	pr.Close()
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "upload combined results")
+	}
+	return nil
+}
+
+func (l *LiveQueryExportStore) combineNDJSON(ctx context.Context, w io.Writer, keys []string) error {
+	for _, key := range keys {
+		err := l.readPart(ctx, key, func(r io.Reader) error {
+			_, err := io.Copy(w, r)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvColumns returns the sorted union of the query columns of the parts.
+func (l *LiveQueryExportStore) csvColumns(ctx context.Context, keys []string) ([]string, error) {
+	colSet := make(map[string]struct{})
+	for _, key := range keys {
+		err := l.readPart(ctx, key, func(r io.Reader) error {
+			header, err := csv.NewReader(r).Read()
+			if err != nil {
+				return err
+			}
+			for _, col := range header[len(liveQueryExportCSVColumns):] {
+				colSet[col] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	cols := make([]string, 0, len(colSet))
+	for col := range colSet {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols, nil
+}
+
+func (l *LiveQueryExportStore) combineCSV(ctx context.Context, w io.Writer, keys []string, cols []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, liveQueryExportCSVColumns...), cols...)); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		err := l.readPart(ctx, key, func(r io.Reader) error {
+			cr := csv.NewReader(r)
+			header, err := cr.Read()
+			if err != nil {
+				return err
+			}
+			index := make(map[string]int, len(header))
+			for i, col := range header {
+				index[col] = i
+			}
+			for {
+				rec, err := cr.Read()
+				if err == io.EOF {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				out := append([]string{}, rec[:len(liveQueryExportCSVColumns)]...)
+				for _, col := range cols {
+					if i, ok := index[col]; ok {
+						out = append(out, rec[i])
+					} else {
+						out = append(out, "")
+					}
+				}
+				if err := cw.Write(out); err != nil {
+					return err
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CampaignExportURL presigns the download of the combined results of the
+// campaign.
+func (l *LiveQueryExportStore) CampaignExportURL(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat, expiration time.Duration) (string, error) {
+	key := l.resultsKey(campaignID, format)
+	req, _ := l.s3client.GetObjectRequest(&s3.GetObjectInput{Bucket: &l.bucket, Key: &key})
+	url, err := req.Presign(expiration)
+	if err != nil {
+		return "", ctxerr.Wrap(ctx, err, "presign combined results")
+	}
+	return url, nil
+}
+
+// objectMetadata returns the metadata of the object, or nil if it does not
+// exist.
+func (l *LiveQueryExportStore) objectMetadata(ctx context.Context, key string) (map[string]*string, error) {
+	res, err := l.s3client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: &l.bucket, Key: &key})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok {
+			switch aerr.Code() {
+			case s3.ErrCodeNoSuchKey, "NotFound":
+				return nil, nil
+			}
+		}
+		return nil, ctxerr.Wrap(ctx, err, "get combined results metadata")
+	}
+	if res.Metadata == nil {
+		return map[string]*string{}, nil
+	}
+	return res.Metadata, nil
+}
+
+// metadataValue returns the value of the metadata, the case of whose keys
+// depends on the S3 implementation.
+func metadataValue(metadata map[string]*string, name string) string {
+	for k, v := range metadata {
+		if strings.EqualFold(k, name) {
+			return aws.StringValue(v)
+		}
+	}
+	return ""
+}
+
+// listParts returns the keys of the parts of the campaign, in host ID order,
+// and the last modification time of the most recent one.
+func (l *LiveQueryExportStore) listParts(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat) ([]string, time.Time, error) {
+	prefix := l.partsPrefix(campaignID)
+	var keys []string
+	var modified time.Time
+	err := l.s3client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: &l.bucket,
+		Prefix: &prefix,
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			if path.Ext(*obj.Key) != "."+string(format) {
+				continue
+			}
+			keys = append(keys, *obj.Key)
+			if obj.LastModified != nil && obj.LastModified.After(modified) {
+				modified = *obj.LastModified
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, time.Time{}, ctxerr.Wrap(ctx, err, "list result parts")
+	}
+	return keys, modified, nil
+}
+
+// readPart calls fn with the content of the part.
+func (l *LiveQueryExportStore) readPart(ctx context.Context, key string, fn func(io.Reader) error) error {
+	res, err := l.s3client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: &l.bucket, Key: &key})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get result part")
+	}
+	defer res.Body.Close()
+
+	if err := fn(res.Body); err != nil {
+		return ctxerr.Wrap(ctx, err, "read result part")
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveQueryExport(t *testing.T) {
+	ctx := context.Background()
+	store := SetupTestLiveQueryExportStore(t, "live-query-export-unit-test", "export-prefix")
+
+	download := func(campaignID uint, format fleet.LiveQueryExportFormat) string {
+		url, err := store.CampaignExportURL(ctx, campaignID, format, time.Minute)
+		require.NoError(t, err)
+		res, err := http.Get(url)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	results := []*fleet.DistributedQueryCampaignResult{
+		{
+			DistributedQueryCampaignID: 1,
+			HostID:                     2,
+			Hostname:                   "host2",
+			Rows:                       []map[string]string{{"name": "chrome", "version": "105"}},
+			RowCount:                   1,
+		},
+		{
+			DistributedQueryCampaignID: 1,
+			HostID:                     1,
+			Hostname:                   "host1",
+			Rows: []map[string]string{
+				{"name": "firefox", "path": "/a"},
+				{"name": "safari", "path": "/b,c"},
+			},
+			RowCount: 2,
+		},
+		{
+			DistributedQueryCampaignID: 1,
+			HostID:                     3,
+			Hostname:                   "host3",
+			Error:                      ptr.String("failed"),
+		},
+	}
+	for _, res := range results {
+		require.NoError(t, store.WriteCampaignResultPart(ctx, fleet.LiveQueryExportCSV, res))
+		require.NoError(t, store.WriteCampaignResultPart(ctx, fleet.LiveQueryExportNDJSON, res))
+	}
+	// writing the result of a host again replaces it
+	require.NoError(t, store.WriteCampaignResultPart(ctx, fleet.LiveQueryExportCSV, results[0]))
+	// the parts of other campaigns are not combined
+	other := *results[0]
+	other.DistributedQueryCampaignID = 10
+	require.NoError(t, store.WriteCampaignResultPart(ctx, fleet.LiveQueryExportCSV, &other))
+
+	require.Error(t, store.WriteCampaignResultPart(ctx, "xml", results[0]))
+
+	require.NoError(t, store.CombineCampaignExport(ctx, 1, fleet.LiveQueryExportCSV))
+	require.Equal(t, `host_id,hostname,error,name,path,version
+1,host1,,firefox,/a,
+1,host1,,safari,"/b,c",
+2,host2,,chrome,,105
+3,host3,failed,,,
+`, download(1, fleet.LiveQueryExportCSV))
+
+	require.NoError(t, store.CombineCampaignExport(ctx, 1, fleet.LiveQueryExportNDJSON))
+	require.Equal(t, `{"campaign_id":1,"host_id":1,"hostname":"host1","rows":[{"name":"firefox","path":"/a"},{"name":"safari","path":"/b,c"}],"row_count":2,"truncated":false,"error":null,"created_at":"0001-01-01T00:00:00Z"}
+{"campaign_id":1,"host_id":2,"hostname":"host2","rows":[{"name":"chrome","version":"105"}],"row_count":1,"truncated":false,"error":null,"created_at":"0001-01-01T00:00:00Z"}
+{"campaign_id":1,"host_id":3,"hostname":"host3","rows":null,"row_count":0,"truncated":false,"error":"failed","created_at":"0001-01-01T00:00:00Z"}
+`, download(1, fleet.LiveQueryExportNDJSON))
+
+	// combining again without new parts keeps the combined results
+	headModified := func(format fleet.LiveQueryExportFormat) time.Time {
+		key := store.resultsKey(1, format)
+		res, err := store.s3client.HeadObject(&s3.HeadObjectInput{Bucket: &store.bucket, Key: &key})
+		require.NoError(t, err)
+		return *res.LastModified
+	}
+	modified := headModified(fleet.LiveQueryExportCSV)
+	time.Sleep(time.Second)
+	require.NoError(t, store.CombineCampaignExport(ctx, 1, fleet.LiveQueryExportCSV))
+	require.Equal(t, modified, headModified(fleet.LiveQueryExportCSV))
+
+	// the late results are combined again
+	require.NoError(t, store.WriteCampaignResultPart(ctx, fleet.LiveQueryExportCSV, &fleet.DistributedQueryCampaignResult{
+		DistributedQueryCampaignID: 1, HostID: 4, Hostname: "host4",
+		Rows: []map[string]string{{"name": "edge"}}, RowCount: 1,
+	}))
+	require.NoError(t, store.CombineCampaignExport(ctx, 1, fleet.LiveQueryExportCSV))
+	require.Contains(t, download(1, fleet.LiveQueryExportCSV), "3,host3,failed,,,\n4,host4,,edge,,\n")
+
+	// a campaign without results has an empty export
+	require.NoError(t, store.CombineCampaignExport(ctx, 2, fleet.LiveQueryExportCSV))
+	require.Equal(t, "host_id,hostname,error\n", download(2, fleet.LiveQueryExportCSV))
+	require.NoError(t, store.CombineCampaignExport(ctx, 2, fleet.LiveQueryExportNDJSON))
+	require.Empty(t, download(2, fleet.LiveQueryExportNDJSON))
+}
@@ -2,6 +2,7 @@ package fleet
 
 import (
 	"encoding/json"
+	"time"
 )
 
 const (
@@ -40,13 +41,68 @@ const (
 	// ActivityTypeUserAddedBySSO is the activity type for new users added
 	// via SSO JIT provisioning
 	ActivityTypeUserAddedBySSO = "user_added_by_sso"
+	// ActivityTypeUserRolesChangedBySSO is the activity type for users whose
+	// roles are changed from their groups when logging in via SSO
+	ActivityTypeUserRolesChangedBySSO = "user_roles_changed_by_sso"
 	// ActivityTypeEditedAgentOptions is the activity type for when the agent
 	// options are edited (either globally or for a team).
 	ActivityTypeEditedAgentOptions = "edited_agent_options"
 	// ActivityTypeAppliedSpecTeam is the activity type for a team spec applied
 	ActivityTypeAppliedSpecTeam = "applied_spec_team"
+	// ActivityTypeAppliedSpecCustomRole is the activity type for custom role
+	// specs applied
+	ActivityTypeAppliedSpecCustomRole = "applied_spec_custom_role"
+	// ActivityTypeDeletedCustomRole is the activity type for deleted custom
+	// roles
+	ActivityTypeDeletedCustomRole = "deleted_custom_role"
+	// ActivityTypeUserLockedOut is the activity type for users whose account
+	// is locked after too many failed logins
+	ActivityTypeUserLockedOut = "user_locked_out"
+	// ActivityTypeUnlockedUser is the activity type for users unlocked by an
+	// admin
+	ActivityTypeUnlockedUser = "unlocked_user"
+	// ActivityTypeCreatedUser is the activity type for created users
+	ActivityTypeCreatedUser = "created_user"
+	// ActivityTypeEditedUser is the activity type for edited users, including
+	// their roles
+	ActivityTypeEditedUser = "edited_user"
+	// ActivityTypeEditedTeamUsers is the activity type for users added to,
+	// removed from or whose role changed in a team
+	ActivityTypeEditedTeamUsers = "edited_team_users"
+	// ActivityTypeDeletedUser is the activity type for deleted users
+	ActivityTypeDeletedUser = "deleted_user"
+	// ActivityTypeUserLoggedIn is the activity type for successful logins
+	ActivityTypeUserLoggedIn = "user_logged_in"
+	// ActivityTypeUserFailedLogin is the activity type for failed logins of
+	// existing users
+	ActivityTypeUserFailedLogin = "user_failed_login"
+	// ActivityTypeDeletedHost is the activity type for deleted hosts
+	ActivityTypeDeletedHost = "deleted_host"
+	// ActivityTypeDeletedMultipleHosts is the activity type for hosts deleted
+	// in bulk
+	ActivityTypeDeletedMultipleHosts = "deleted_multiple_hosts"
+	// ActivityTypeTransferredHosts is the activity type for hosts transferred
+	// to a team (or to no team)
+	ActivityTypeTransferredHosts = "transferred_hosts"
+	// ActivityTypeEditedEnrollSecrets is the activity type for enroll secrets
+	// edited (either globally or for a team)
+	ActivityTypeEditedEnrollSecrets = "edited_enroll_secrets"
+	// ActivityTypeEditedAppConfig is the activity type for app config edits
+	ActivityTypeEditedAppConfig = "edited_app_config"
+	// ActivityTypeCreatedLabel is the activity type for created labels
+	ActivityTypeCreatedLabel = "created_label"
+	// ActivityTypeEditedLabel is the activity type for edited labels
+	ActivityTypeEditedLabel = "edited_label"
+	// ActivityTypeDeletedLabel is the activity type for deleted labels
+	ActivityTypeDeletedLabel = "deleted_label"
+	// ActivityTypeAppliedSpecLabel is the activity type for label specs applied
+	ActivityTypeAppliedSpecLabel = "applied_spec_label"
 )
 
+// ActivityArchiveMonthFormat is the layout of the months by which the
+// activities are archived, e.g. "2022-08".
+const ActivityArchiveMonthFormat = "2006-01"
+
 type Activity struct {
 	CreateTimestamp
 	ID            uint             `json:"id" db:"id"`
@@ -56,6 +112,31 @@ type Activity struct {
 	ActorEmail    *string          `json:"actor_email" db:"email"`
 	Type          string           `json:"type" db:"activity_type"`
 	Details       *json.RawMessage `json:"details" db:"details"`
+	// ActorIP is the IP address from which the actor performed the activity.
+	ActorIP *string `json:"actor_ip" db:"ip_address"`
+	// ActorUserAgent is the user agent used by the actor to perform the
+	// activity.
+	ActorUserAgent *string `json:"actor_user_agent" db:"user_agent"`
+	// Streamed is true once the activity was exported to the audit log.
+	Streamed bool `json:"-" db:"streamed"`
+}
+
+// ListActivitiesOptions defines the options to filter and page through the
+// activities.
+type ListActivitiesOptions struct {
+	ListOptions
+
+	// ActivityType filters the activities of that type only.
+	ActivityType string
+	// ActorID filters the activities performed by that user only.
+	ActorID *uint
+	// StartCreatedAt filters the activities created at or after that time.
+	StartCreatedAt *time.Time
+	// EndCreatedAt filters the activities created before that time.
+	EndCreatedAt *time.Time
+	// Streamed filters the activities that were (or were not) exported to the
+	// audit log.
+	Streamed *bool
 }
 
 // AuthzType implement AuthzTyper to be able to verify access to activities
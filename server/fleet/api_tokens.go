@@ -0,0 +1,190 @@
+package fleet
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix is the prefix of the keys of the personal API tokens. It
+// allows to tell them apart from the session keys, which are base64-encoded
+// and can't contain an underscore.
+const APITokenPrefix = "fleet_pat_"
+
+// APITokenScopeAll matches any object type or action in an API token scope.
+const APITokenScopeAll = "*"
+
+// APIToken is a named, personal API token of a user. Requests authenticated
+// with the token are authorized with the intersection of the role of the user
+// and the scopes of the token.
+type APIToken struct {
+	UpdateCreateTimestamps
+	ID     uint   `json:"id" db:"id"`
+	UserID uint   `json:"user_id" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	// Key is the secret of the token, it is only returned when the token is
+	// created, only its hash is stored.
+	Key string `json:"key,omitempty" db:"-"`
+	// KeyHash is the SHA-256 hash of the key, see HashAPITokenKey.
+	KeyHash string `json:"-" db:"key_hash"`
+	// TeamID, if set, restricts the token to the team: the user acts as if
+	// they only had their role on that team (or their global role, if they
+	// have one).
+	TeamID     *uint          `json:"team_id" db:"team_id"`
+	Scopes     APITokenScopes `json:"scopes" db:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+}
+
+func (t APIToken) AuthzType() string {
+	return "api_token"
+}
+
+// APITokenScope allows the actions on a type of object, identified by its
+// authorization type (e.g. "host", "query" or "targeted_query").
+type APITokenScope struct {
+	Object  string   `json:"object"`
+	Actions []string `json:"actions"`
+}
+
+// APITokenScopes is the list of scopes of an API token, stored as JSON.
+type APITokenScopes []APITokenScope
+
+// Scan implements the sql.Scanner interface
+func (s *APITokenScopes) Scan(val interface{}) error {
+	switch v := val.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	case nil: // sql NULL
+		return nil
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+}
+
+// Value implements the sql.Valuer interface
+func (s APITokenScopes) Value() (driver.Value, error) {
+	if s == nil {
+		s = APITokenScopes{}
+	}
+	return json.Marshal(s)
+}
+
+// validAPITokenActions are the actions that can be allowed by a scope.
+var validAPITokenActions = map[string]bool{
+	APITokenScopeAll: true,
+	ActionRead:       true,
+	ActionList:       true,
+	ActionWrite:      true,
+	ActionRun:        true,
+	ActionRunNew:     true,
+}
+
+// Validate returns an error if the scopes are empty or if a scope is
+// invalid.
+func (s APITokenScopes) Validate() error {
+	if len(s) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range s {
+		if scope.Object == "" {
+			return errors.New("scope object is required")
+		}
+		if len(scope.Actions) == 0 {
+			return fmt.Errorf("scope %q: at least one action is required", scope.Object)
+		}
+		for _, action := range scope.Actions {
+			if !validAPITokenActions[action] {
+				return fmt.Errorf("scope %q: invalid action %q", scope.Object, action)
+			}
+		}
+	}
+	return nil
+}
+
+// Allows returns true if one of the scopes allows the action on the type of
+// object. Requests authenticated with an API token are never allowed to
+// change passwords, roles or to manage API tokens, regardless of the scopes,
+// so that a token can't be used to escalate its own privileges.
+func (s APITokenScopes) Allows(objectType, action string) bool {
+	if objectType == "api_token" || action == ActionChangePassword || action == ActionWriteRole {
+		return false
+	}
+	for _, scope := range s {
+		if scope.Object != APITokenScopeAll && scope.Object != objectType {
+			continue
+		}
+		for _, a := range scope.Actions {
+			if a == APITokenScopeAll || a == action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseAPITokenScope parses a scope in the "object:action,action" format,
+// e.g. "host:read,list".
+func ParseAPITokenScope(s string) (APITokenScope, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return APITokenScope{}, fmt.Errorf("unable to parse %q as object:actions", s)
+	}
+	return APITokenScope{Object: parts[0], Actions: strings.Split(parts[1], ",")}, nil
+}
+
+// IsExpired returns true if the token has an expiration time and it is
+// passed.
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// ScopedUser returns the user as seen by the requests authenticated with the
+// token. If the token is restricted to a team, the returned copy of the user
+// only has their role on that team, a global role being applied as the role
+// on the team. Users without access to the team get no role at all.
+func (t *APIToken) ScopedUser(user *User) *User {
+	if t.TeamID == nil {
+		return user
+	}
+
+	scoped := *user
+	scoped.GlobalRole = nil
+	scoped.Teams = []UserTeam{}
+
+	scopedTeam := UserTeam{Team: Team{ID: *t.TeamID}}
+	if user.GlobalRole != nil {
+		scopedTeam.Role = *user.GlobalRole
+	}
+	for _, team := range user.Teams {
+		if team.ID == *t.TeamID {
+			scopedTeam = team
+		}
+	}
+	if scopedTeam.Role != "" {
+		scoped.Teams = []UserTeam{scopedTeam}
+	}
+	return &scoped
+}
+
+// HashAPITokenKey returns the hash of the key of an API token, as stored in
+// the database.
+func HashAPITokenKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APITokenPayload is the payload used to create an API token.
+type APITokenPayload struct {
+	Name      string         `json:"name"`
+	TeamID    *uint          `json:"team_id"`
+	Scopes    APITokenScopes `json:"scopes"`
+	ExpiresAt *time.Time     `json:"expires_at"`
+}
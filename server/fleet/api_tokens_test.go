@@ -0,0 +1,67 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenScopesValidate(t *testing.T) {
+	require.Error(t, APITokenScopes{}.Validate())
+	require.Error(t, APITokenScopes{{Object: "", Actions: []string{ActionRead}}}.Validate())
+	require.Error(t, APITokenScopes{{Object: "host"}}.Validate())
+	require.Error(t, APITokenScopes{{Object: "host", Actions: []string{ActionChangePassword}}}.Validate())
+
+	require.NoError(t, APITokenScopes{{Object: "host", Actions: []string{ActionRead, ActionList}}}.Validate())
+	require.NoError(t, APITokenScopes{{Object: APITokenScopeAll, Actions: []string{APITokenScopeAll}}}.Validate())
+}
+
+func TestParseAPITokenScope(t *testing.T) {
+	scope, err := ParseAPITokenScope("host:read,list")
+	require.NoError(t, err)
+	assert.Equal(t, APITokenScope{Object: "host", Actions: []string{"read", "list"}}, scope)
+
+	for _, s := range []string{"", "host", "host:", ":read"} {
+		_, err := ParseAPITokenScope(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestAPITokenScopedUser(t *testing.T) {
+	globalAdmin := &User{ID: 1, GlobalRole: ptr.String(RoleAdmin)}
+	teamUser := &User{ID: 2, Teams: []UserTeam{
+		{Team: Team{ID: 1}, Role: RoleMaintainer},
+		{Team: Team{ID: 2}, Role: RoleObserver},
+	}}
+
+	// tokens without team don't modify the user
+	token := &APIToken{}
+	assert.Same(t, globalAdmin, token.ScopedUser(globalAdmin))
+
+	token = &APIToken{TeamID: ptr.Uint(2)}
+	scoped := token.ScopedUser(globalAdmin)
+	assert.Nil(t, scoped.GlobalRole)
+	assert.Equal(t, []UserTeam{{Team: Team{ID: 2}, Role: RoleAdmin}}, scoped.Teams)
+	// the original user is not modified
+	assert.Equal(t, ptr.String(RoleAdmin), globalAdmin.GlobalRole)
+
+	scoped = token.ScopedUser(teamUser)
+	assert.Equal(t, []UserTeam{{Team: Team{ID: 2}, Role: RoleObserver}}, scoped.Teams)
+	assert.Len(t, teamUser.Teams, 2)
+
+	token = &APIToken{TeamID: ptr.Uint(3)}
+	scoped = token.ScopedUser(teamUser)
+	assert.Nil(t, scoped.GlobalRole)
+	assert.Empty(t, scoped.Teams)
+}
+
+func TestAPITokenIsExpired(t *testing.T) {
+	now := time.Now()
+	assert.False(t, (&APIToken{}).IsExpired(now))
+	assert.False(t, (&APIToken{ExpiresAt: ptr.Time(now.Add(time.Minute))}).IsExpired(now))
+	assert.True(t, (&APIToken{ExpiresAt: ptr.Time(now)}).IsExpired(now))
+	assert.True(t, (&APIToken{ExpiresAt: ptr.Time(now.Add(-time.Minute))}).IsExpired(now))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"time"
+	"unicode"
 
 	"github.com/fleetdm/fleet/v4/server/config"
 )
@@ -63,6 +64,198 @@ type SSOSettings struct {
 	// EnableJITProvisioning allows user accounts to be created the first time
 	// users try to log in
 	EnableJITProvisioning bool `json:"enable_jit_provisioning"`
+	// Protocol is the SSO protocol used to authenticate with the IDP, either
+	// SSOProtocolSAML (the default if empty) or SSOProtocolOIDC.
+	Protocol string `json:"protocol"`
+	// OIDC contains the settings of the OpenID Connect provider, used if
+	// Protocol is SSOProtocolOIDC.
+	OIDC OIDCSettings `json:"oidc"`
+	// GroupsAttribute is the SAML attribute or OIDC claim that contains the
+	// groups of the user, matched against the RoleMappings.
+	GroupsAttribute string `json:"groups_attribute"`
+	// RoleMappings map the groups of the user to Fleet roles. If set, the roles
+	// of the SSO users are set from their groups every time they log in.
+	RoleMappings []SSORoleMapping `json:"role_mappings"`
+	// RejectUnmappedUsers rejects the login of users whose groups don't match
+	// any of the RoleMappings, instead of making them global observers.
+	RejectUnmappedUsers bool `json:"reject_unmapped_users"`
+}
+
+// SSORoleMapping gives a role to the SSO users that are members of a group.
+type SSORoleMapping struct {
+	// Group is the group of the user, as sent by the IDP.
+	Group string `json:"group"`
+	// Role is the role given to the members of the group.
+	Role string `json:"role"`
+	// Team is the name of the team the role is given on, the role is a global
+	// role if empty.
+	Team string `json:"team,omitempty"`
+}
+
+// ssoRolePriority is used to pick the role of a user that matches multiple
+// role mappings, the most privileged role wins.
+var ssoRolePriority = map[string]int{
+	RoleObserver:   1,
+	RoleMaintainer: 2,
+	RoleAdmin:      3,
+}
+
+// MappedRoles returns the roles that the RoleMappings give to a member of the
+// groups. As a user can't have both, a mapped global role takes precedence
+// over the team roles if it is at least as privileged as all of them,
+// otherwise the global role is ignored so that a lower global role doesn't
+// remove a higher team role. The team roles are keyed by team name. ok is
+// false if none of the groups is mapped.
+func (s SSOSettings) MappedRoles(groups []string) (globalRole string, teamRoles map[string]string, ok bool) {
+	member := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		member[g] = true
+	}
+
+	teamRoles = make(map[string]string)
+	for _, m := range s.RoleMappings {
+		if !member[m.Group] {
+			continue
+		}
+		ok = true
+		if m.Team == "" {
+			if ssoRolePriority[m.Role] > ssoRolePriority[globalRole] {
+				globalRole = m.Role
+			}
+			continue
+		}
+		if ssoRolePriority[m.Role] > ssoRolePriority[teamRoles[m.Team]] {
+			teamRoles[m.Team] = m.Role
+		}
+	}
+	maxTeamPriority := 0
+	for _, role := range teamRoles {
+		if ssoRolePriority[role] > maxTeamPriority {
+			maxTeamPriority = ssoRolePriority[role]
+		}
+	}
+	if globalRole != "" && ssoRolePriority[globalRole] >= maxTeamPriority {
+		teamRoles = nil
+	} else {
+		globalRole = ""
+	}
+	return globalRole, teamRoles, ok
+}
+
+const (
+	SSOProtocolSAML = "saml"
+	SSOProtocolOIDC = "oidc"
+)
+
+// IsOIDC returns true if the SSO settings use OpenID Connect.
+func (s SSOSettings) IsOIDC() bool {
+	return s.Protocol == SSOProtocolOIDC
+}
+
+// MaskSecrets replaces the OIDC client secret with MaskedPassword.
+func (s *SSOSettings) MaskSecrets() {
+	maskSecret(&s.OIDC.ClientSecret)
+}
+
+// RestoreMaskedSecrets replaces the OIDC client secret with the stored one if
+// it is set to MaskedPassword.
+func (s *SSOSettings) RestoreMaskedSecrets(stored SSOSettings) {
+	restoreMaskedSecret(&s.OIDC.ClientSecret, stored.OIDC.ClientSecret)
+}
+
+// OIDCSettings are the settings of an OpenID Connect identity provider.
+type OIDCSettings struct {
+	// IssuerURL is the URL of the provider, its configuration is discovered
+	// from IssuerURL/.well-known/openid-configuration.
+	IssuerURL string `json:"issuer_url"`
+	// ClientID and ClientSecret are the credentials of the Fleet client
+	// registered with the provider.
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	// Scopes are the scopes requested in addition to openid, "email" and
+	// "profile" if empty.
+	Scopes []string `json:"scopes"`
+	// EmailClaim is the ID token claim that contains the email of the user,
+	// "email" if empty.
+	EmailClaim string `json:"email_claim"`
+	// NameClaim is the ID token claim that contains the name of the user,
+	// "name" if empty.
+	NameClaim string `json:"name_claim"`
+}
+
+// TwoFactorAuthSettings are the settings of the two-factor authentication of
+// the password logins.
+type TwoFactorAuthSettings struct {
+	// Enforced requires all the users that log in with a password to use
+	// two-factor authentication. Users that are not enrolled yet must enroll
+	// when they log in.
+	Enforced bool `json:"enforced"`
+}
+
+// PasswordPolicy is the admin-configurable policy of the user passwords. It
+// can only make the base requirements of ValidatePasswordRequirements
+// stricter, the zero value applies the base requirements only.
+type PasswordPolicy struct {
+	// MinLength is the minimum length of the passwords. Values lower than the
+	// base requirement have no effect.
+	MinLength int `json:"min_length"`
+	// RequireUppercase requires at least one uppercase letter.
+	RequireUppercase bool `json:"require_uppercase"`
+	// RequireLowercase requires at least one lowercase letter.
+	RequireLowercase bool `json:"require_lowercase"`
+	// HistoryCount is the number of previous passwords of the user that cannot
+	// be reused, including the current one.
+	HistoryCount int `json:"history_count"`
+	// MaxAgeDays is the number of days after which a password expires, the
+	// user must reset it on their next login. 0 means the passwords never
+	// expire.
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// MaxPasswordHistoryCount is the maximum number of previous passwords that
+// the password policy can prevent from being reused.
+const MaxPasswordHistoryCount = 24
+
+// MaxPasswordMaxAgeDays is the highest maximum age of the passwords that can
+// be configured, about 10 years.
+const MaxPasswordMaxAgeDays = 3650
+
+// ValidatePassword checks the password against the base password
+// requirements and the policy.
+func (p PasswordPolicy) ValidatePassword(password string) error {
+	if err := ValidatePasswordRequirements(password); err != nil {
+		return err
+	}
+
+	var upper, lower bool
+	for _, s := range password {
+		switch {
+		case unicode.IsUpper(s):
+			upper = true
+		case unicode.IsLower(s):
+			lower = true
+		}
+	}
+	if len(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if p.RequireUppercase && !upper {
+		return errors.New("Password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		return errors.New("Password must contain a lowercase letter")
+	}
+	return nil
+}
+
+// PasswordExpired returns whether a password set at setAt has expired at now.
+func (p PasswordPolicy) PasswordExpired(setAt, now time.Time) bool {
+	if p.MaxAgeDays <= 0 {
+		return false
+	}
+	// the date is computed rather than the duration, that overflows for
+	// large values.
+	return !now.Before(setAt.AddDate(0, 0, p.MaxAgeDays))
 }
 
 // SMTPSettings is part of the AppConfig which defines the wire representation
@@ -117,6 +310,12 @@ type AppConfig struct {
 	ServerSettings     ServerSettings     `json:"server_settings"`
 	SMTPSettings       SMTPSettings       `json:"smtp_settings"`
 	HostExpirySettings HostExpirySettings `json:"host_expiry_settings"`
+	// ActivityExpirySettings defines the retention of the activities.
+	ActivityExpirySettings ActivityExpirySettings `json:"activity_expiry_settings"`
+	// LiveQueryResultsSettings defines the storage of the live query results.
+	LiveQueryResultsSettings LiveQueryResultsSettings `json:"live_query_results_settings"`
+	// QueryReportsSettings defines the storage of the scheduled query results.
+	QueryReportsSettings QueryReportsSettings `json:"query_reports_settings"`
 	// Features allows to globally enable or disable features
 	Features     Features         `json:"features"`
 	AgentOptions *json.RawMessage `json:"agent_options,omitempty"`
@@ -124,6 +323,12 @@ type AppConfig struct {
 	SMTPTest bool `json:"smtp_test,omitempty"`
 	// SSOSettings is single sign on settings
 	SSOSettings SSOSettings `json:"sso_settings"`
+	// TwoFactorAuthSettings are the settings of the two-factor authentication
+	// of the password logins.
+	TwoFactorAuthSettings TwoFactorAuthSettings `json:"two_factor_auth_settings"`
+	// PasswordPolicy is the policy applied to the passwords of the users, on
+	// top of the base password requirements.
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
 	// FleetDesktop holds settings for Fleet Desktop that can be changed via the API.
 	FleetDesktop FleetDesktopSettings `json:"fleet_desktop"`
 
@@ -226,6 +431,8 @@ type HostStatusWebhookSettings struct {
 	DestinationURL string  `json:"destination_url"`
 	HostPercentage float64 `json:"host_percentage"`
 	DaysCount      int     `json:"days_count"`
+	// Secret is the optional shared secret used to sign the webhook requests.
+	Secret string `json:"secret,omitempty"`
 }
 
 // FailingPoliciesWebhookSettings holds the settings for failing policy webhooks.
@@ -239,6 +446,8 @@ type FailingPoliciesWebhookSettings struct {
 	// HostBatchSize allows sending multiple requests in batches of hosts for each policy.
 	// A value of 0 means no batching.
 	HostBatchSize int `json:"host_batch_size"`
+	// Secret is the optional shared secret used to sign the webhook requests.
+	Secret string `json:"secret,omitempty"`
 }
 
 // VulnerabilitiesWebhookSettings holds the settings for vulnerabilities webhooks.
@@ -250,6 +459,50 @@ type VulnerabilitiesWebhookSettings struct {
 	// HostBatchSize allows sending multiple requests in batches of hosts for each vulnerable software found.
 	// A value of 0 means no batching.
 	HostBatchSize int `json:"host_batch_size"`
+	// Secret is the optional shared secret used to sign the webhook requests.
+	Secret string `json:"secret,omitempty"`
+}
+
+// SecretFor returns the secret configured for the webhook of the specified
+// type (one of the WebhookType* constants).
+func (s WebhookSettings) SecretFor(webhookType string) string {
+	switch webhookType {
+	case WebhookTypeHostStatus:
+		return s.HostStatusWebhook.Secret
+	case WebhookTypeFailingPolicies:
+		return s.FailingPoliciesWebhook.Secret
+	case WebhookTypeVulnerabilities:
+		return s.VulnerabilitiesWebhook.Secret
+	}
+	return ""
+}
+
+// MaskSecrets replaces the configured webhook secrets with MaskedPassword.
+func (s *WebhookSettings) MaskSecrets() {
+	maskSecret(&s.HostStatusWebhook.Secret)
+	maskSecret(&s.FailingPoliciesWebhook.Secret)
+	maskSecret(&s.VulnerabilitiesWebhook.Secret)
+}
+
+// RestoreMaskedSecrets replaces the webhook secrets that are set to
+// MaskedPassword (e.g. when a config previously retrieved from the API is
+// applied back) with the corresponding secret of the stored settings.
+func (s *WebhookSettings) RestoreMaskedSecrets(stored WebhookSettings) {
+	restoreMaskedSecret(&s.HostStatusWebhook.Secret, stored.HostStatusWebhook.Secret)
+	restoreMaskedSecret(&s.FailingPoliciesWebhook.Secret, stored.FailingPoliciesWebhook.Secret)
+	restoreMaskedSecret(&s.VulnerabilitiesWebhook.Secret, stored.VulnerabilitiesWebhook.Secret)
+}
+
+func maskSecret(secret *string) {
+	if *secret != "" {
+		*secret = MaskedPassword
+	}
+}
+
+func restoreMaskedSecret(secret *string, stored string) {
+	if *secret == MaskedPassword {
+		*secret = stored
+	}
 }
 
 func (c *AppConfig) ApplyDefaultsForNewInstalls() {
@@ -273,6 +526,12 @@ func (c *AppConfig) ApplyDefaultsForNewInstalls() {
 func (c *AppConfig) ApplyDefaults() {
 	c.Features.EnableHostUsers = true
 	c.WebhookSettings.Interval.Duration = 24 * time.Hour
+	c.LiveQueryResultsSettings = LiveQueryResultsSettings{
+		RetentionDays:      30,
+		MaxRowsPerHost:     1000,
+		MaxRowsPerCampaign: 100000,
+	}
+	c.QueryReportsSettings.MaxRowsPerQuery = 1000
 }
 
 // EnableStrictDecoding enables strict decoding of the AppConfig struct.
@@ -333,6 +592,43 @@ type HostExpirySettings struct {
 	HostExpiryWindow  int  `json:"host_expiry_window"`
 }
 
+// ActivityExpirySettings contains settings pertaining to the retention of the
+// activities.
+type ActivityExpirySettings struct {
+	ActivityExpiryEnabled bool `json:"activity_expiry_enabled"`
+	// ActivityExpiryWindow is the number of days after which the activities
+	// are deleted.
+	ActivityExpiryWindow int `json:"activity_expiry_window"`
+	// ArchiveEnabled archives the expired activities to S3 before deleting
+	// them, it requires the activity_archive_s3 configuration.
+	ArchiveEnabled bool `json:"archive_enabled"`
+}
+
+// LiveQueryResultsSettings contains settings pertaining to the storage of the
+// live query campaign results.
+type LiveQueryResultsSettings struct {
+	// RetentionDays is the number of days after which the stored results are
+	// deleted, they are never deleted if 0.
+	RetentionDays int `json:"retention_days"`
+	// MaxRowsPerHost is the maximum number of rows stored for a host in a
+	// campaign, the rows are not limited if 0.
+	MaxRowsPerHost int `json:"max_rows_per_host"`
+	// MaxRowsPerCampaign is the maximum number of rows stored for all the
+	// hosts of a campaign, the rows are not limited if 0.
+	MaxRowsPerCampaign int `json:"max_rows_per_campaign"`
+}
+
+// QueryReportsSettings contains settings pertaining to the storage of the
+// latest snapshot results of the scheduled queries.
+type QueryReportsSettings struct {
+	// Enabled stores the snapshot results of the scheduled queries, in
+	// addition to sending them to the result log destination.
+	Enabled bool `json:"enabled"`
+	// MaxRowsPerQuery is the maximum number of rows stored for all the hosts
+	// of a scheduled query, the rows are not limited if 0.
+	MaxRowsPerQuery int `json:"max_rows_per_query"`
+}
+
 type Features struct {
 	EnableHostUsers         bool             `json:"enable_host_users"`
 	EnableSoftwareInventory bool             `json:"enable_software_inventory"`
@@ -524,6 +820,30 @@ type KafkaRESTConfig struct {
 	ProxyHost   string `json:"proxyhost"`
 }
 
+// SplunkConfig shadows config.SplunkConfig only exposing a subset of fields
+type SplunkConfig struct {
+	URL              string `json:"url"`
+	Index            string `json:"index"`
+	StatusSourcetype string `json:"status_sourcetype"`
+	ResultSourcetype string `json:"result_sourcetype"`
+}
+
+// ElasticsearchConfig shadows config.ElasticsearchConfig only exposing a
+// subset of fields
+type ElasticsearchConfig struct {
+	URL         string `json:"url"`
+	StatusIndex string `json:"status_index"`
+	ResultIndex string `json:"result_index"`
+}
+
+// SyslogConfig shadows config.SyslogConfig only exposing a subset of fields
+type SyslogConfig struct {
+	Address  string `json:"address"`
+	Facility string `json:"facility"`
+	AppName  string `json:"app_name"`
+	TLS      bool   `json:"tls"`
+}
+
 // DeviceAPIFeatures specifies a list of features supported
 // by the current API version. Each field in the struct is
 // meant to be a boolean value.
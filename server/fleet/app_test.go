@@ -0,0 +1,131 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSettingsSecrets(t *testing.T) {
+	stored := WebhookSettings{
+		HostStatusWebhook:      HostStatusWebhookSettings{Secret: "host"},
+		FailingPoliciesWebhook: FailingPoliciesWebhookSettings{Secret: "policies"},
+	}
+	require.Equal(t, "host", stored.SecretFor(WebhookTypeHostStatus))
+	require.Equal(t, "policies", stored.SecretFor(WebhookTypeFailingPolicies))
+	require.Empty(t, stored.SecretFor(WebhookTypeVulnerabilities))
+	require.Empty(t, stored.SecretFor("unknown"))
+
+	masked := stored
+	masked.MaskSecrets()
+	require.Equal(t, MaskedPassword, masked.HostStatusWebhook.Secret)
+	require.Equal(t, MaskedPassword, masked.FailingPoliciesWebhook.Secret)
+	require.Empty(t, masked.VulnerabilitiesWebhook.Secret)
+	// the original settings are left untouched
+	require.Equal(t, "host", stored.HostStatusWebhook.Secret)
+
+	// applying the masked settings back keeps the stored secrets, while a new
+	// secret replaces the stored one.
+	masked.FailingPoliciesWebhook.Secret = "rotated"
+	masked.VulnerabilitiesWebhook.Secret = "vulns"
+	masked.RestoreMaskedSecrets(stored)
+	require.Equal(t, "host", masked.HostStatusWebhook.Secret)
+	require.Equal(t, "rotated", masked.FailingPoliciesWebhook.Secret)
+	require.Equal(t, "vulns", masked.VulnerabilitiesWebhook.Secret)
+}
+
+func TestSSOSettingsSecrets(t *testing.T) {
+	stored := SSOSettings{Protocol: SSOProtocolOIDC, OIDC: OIDCSettings{ClientID: "fleet", ClientSecret: "secret"}}
+	require.True(t, stored.IsOIDC())
+
+	masked := stored
+	masked.MaskSecrets()
+	require.Equal(t, MaskedPassword, masked.OIDC.ClientSecret)
+	require.Equal(t, "fleet", masked.OIDC.ClientID)
+
+	masked.RestoreMaskedSecrets(stored)
+	require.Equal(t, "secret", masked.OIDC.ClientSecret)
+
+	masked.OIDC.ClientSecret = "rotated"
+	masked.RestoreMaskedSecrets(stored)
+	require.Equal(t, "rotated", masked.OIDC.ClientSecret)
+}
+
+func TestSSOSettingsMappedRoles(t *testing.T) {
+	settings := SSOSettings{
+		RoleMappings: []SSORoleMapping{
+			{Group: "fleet-admins", Role: RoleAdmin},
+			{Group: "fleet-observers", Role: RoleObserver},
+			{Group: "eng-laptops", Role: RoleMaintainer, Team: "Engineering"},
+			{Group: "eng", Role: RoleObserver, Team: "Engineering"},
+			{Group: "sales", Role: RoleObserver, Team: "Sales"},
+		},
+	}
+
+	global, teams, ok := settings.MappedRoles([]string{"other"})
+	require.False(t, ok)
+	require.Empty(t, global)
+	require.Empty(t, teams)
+
+	// the most privileged team role wins
+	global, teams, ok = settings.MappedRoles([]string{"eng", "eng-laptops", "sales"})
+	require.True(t, ok)
+	require.Empty(t, global)
+	require.Equal(t, map[string]string{"Engineering": RoleMaintainer, "Sales": RoleObserver}, teams)
+
+	// a global role overrides the team roles that are not more privileged
+	global, teams, ok = settings.MappedRoles([]string{"eng-laptops", "fleet-observers", "fleet-admins"})
+	require.True(t, ok)
+	require.Equal(t, RoleAdmin, global)
+	require.Empty(t, teams)
+
+	global, teams, ok = settings.MappedRoles([]string{"eng", "fleet-observers"})
+	require.True(t, ok)
+	require.Equal(t, RoleObserver, global)
+	require.Empty(t, teams)
+
+	// but not a more privileged team role
+	global, teams, ok = settings.MappedRoles([]string{"eng-laptops", "fleet-observers"})
+	require.True(t, ok)
+	require.Empty(t, global)
+	require.Equal(t, map[string]string{"Engineering": RoleMaintainer}, teams)
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	cases := []struct {
+		policy   PasswordPolicy
+		password string
+		wantErr  string
+	}{
+		{PasswordPolicy{}, "foobarbaz!3!", ""},
+		// the base requirements always apply
+		{PasswordPolicy{}, "foobarbaz!3", "Password does not meet required criteria"},
+		{PasswordPolicy{MinLength: 8}, "foobar!3", "Password does not meet required criteria"},
+		{PasswordPolicy{MinLength: 16}, "foobarbaz!3!", "Password must be at least 16 characters long"},
+		{PasswordPolicy{MinLength: 16}, "foobarbazqux!3!!", ""},
+		{PasswordPolicy{RequireUppercase: true}, "foobarbaz!3!", "Password must contain an uppercase letter"},
+		{PasswordPolicy{RequireUppercase: true}, "Foobarbaz!3!", ""},
+		{PasswordPolicy{RequireLowercase: true}, "FOOBARBAZ!3!", "Password must contain a lowercase letter"},
+		{PasswordPolicy{RequireUppercase: true, RequireLowercase: true}, "FOOBARBAz!3!", ""},
+	}
+	for _, c := range cases {
+		err := c.policy.ValidatePassword(c.password)
+		if c.wantErr == "" {
+			require.NoError(t, err, c.password)
+		} else {
+			require.EqualError(t, err, c.wantErr, c.password)
+		}
+	}
+
+	now := time.Now()
+	require.False(t, PasswordPolicy{}.PasswordExpired(now.AddDate(-10, 0, 0), now))
+	policy := PasswordPolicy{MaxAgeDays: 90}
+	require.False(t, policy.PasswordExpired(now.AddDate(0, 0, -89), now))
+	require.True(t, policy.PasswordExpired(now.AddDate(0, 0, -90), now))
+
+	// large values don't overflow
+	policy = PasswordPolicy{MaxAgeDays: 200000}
+	require.False(t, policy.PasswordExpired(now.AddDate(0, 0, -90), now))
+	require.False(t, policy.PasswordExpired(now.AddDate(-100, 0, 0), now))
+}
@@ -1,5 +1,10 @@
 package fleet
 
+import (
+	"fmt"
+	"time"
+)
+
 // DistributedQueryStatus is the lifecycle status of a distributed query
 // campaign.
 type DistributedQueryStatus int
@@ -19,6 +24,117 @@ type DistributedQueryCampaign struct {
 	QueryID uint                   `json:"query_id" db:"query_id"`
 	Status  DistributedQueryStatus `json:"status"`
 	UserID  uint                   `json:"user_id" db:"user_id"`
+	// CollectUntil is set for the asynchronous campaigns, which keep targeting
+	// the hosts that did not respond yet until that time, so that the results
+	// of the hosts that are offline when the campaign starts can be collected
+	// later on.
+	CollectUntil *time.Time `json:"collect_until,omitempty" db:"collect_until"`
+	// HostCounts are the number of targeted hosts by status, only set for
+	// the asynchronous campaigns when listing their results or hosts.
+	HostCounts *CampaignHostCounts `json:"host_counts,omitempty" db:"-"`
+	// ExportFormat is set for the campaigns whose results are exported to the
+	// live query export store as the hosts respond.
+	ExportFormat LiveQueryExportFormat `json:"export_format,omitempty" db:"export_format"`
+	// ExportedAt is the time the exported results of the campaign were first
+	// combined, once it completed.
+	ExportedAt *time.Time `json:"exported_at,omitempty" db:"exported_at"`
+}
+
+// CampaignOptions are the options of a new distributed query campaign.
+type CampaignOptions struct {
+	// CollectWindow makes the campaign asynchronous if positive, see
+	// DistributedQueryCampaign.CollectUntil.
+	CollectWindow time.Duration
+	// ExportFormat exports the results of the campaign in that format if set.
+	ExportFormat LiveQueryExportFormat
+}
+
+// IsCollecting returns true if the campaign is asynchronous and its
+// collection window is not over at the provided time.
+func (c *DistributedQueryCampaign) IsCollecting(now time.Time) bool {
+	return c.CollectUntil != nil && now.Before(*c.CollectUntil)
+}
+
+// LiveQueryExportFormat is the format of the exported results of a campaign.
+type LiveQueryExportFormat string
+
+const (
+	// LiveQueryExportNDJSON exports a JSON object per host, as returned by the
+	// campaign results API.
+	LiveQueryExportNDJSON LiveQueryExportFormat = "ndjson"
+	// LiveQueryExportCSV exports a CSV record per row, with the host_id,
+	// hostname and error columns followed by the columns of the query.
+	LiveQueryExportCSV LiveQueryExportFormat = "csv"
+)
+
+// IsValid returns true if the format is one of the supported formats.
+func (f LiveQueryExportFormat) IsValid() bool {
+	switch f {
+	case LiveQueryExportNDJSON, LiveQueryExportCSV:
+		return true
+	}
+	return false
+}
+
+// CampaignExport is the download link of the exported results of a campaign.
+type CampaignExport struct {
+	CampaignID uint                  `json:"campaign_id"`
+	Format     LiveQueryExportFormat `json:"format"`
+	URL        string                `json:"url"`
+	ExpiresAt  time.Time             `json:"expires_at"`
+}
+
+// MaxCampaignCollectWindow is the maximum collection window of an
+// asynchronous campaign.
+const MaxCampaignCollectWindow = 7 * 24 * time.Hour
+
+// CampaignHostStatus is the status of a host targeted by an asynchronous
+// campaign.
+type CampaignHostStatus string
+
+const (
+	// CampaignHostPending is the status of a host that did not respond yet.
+	CampaignHostPending CampaignHostStatus = "pending"
+	// CampaignHostResponded is the status of a host that ran the query.
+	CampaignHostResponded CampaignHostStatus = "responded"
+	// CampaignHostErrored is the status of a host that failed to run the
+	// query.
+	CampaignHostErrored CampaignHostStatus = "errored"
+)
+
+// IsValid returns true if the status is one of the known statuses.
+func (s CampaignHostStatus) IsValid() bool {
+	switch s {
+	case CampaignHostPending, CampaignHostResponded, CampaignHostErrored:
+		return true
+	}
+	return false
+}
+
+// DistributedQueryCampaignHost is a host targeted by an asynchronous
+// campaign. Its status is updated when the result of the host is stored.
+type DistributedQueryCampaignHost struct {
+	HostID      uint               `json:"host_id" db:"host_id"`
+	Hostname    string             `json:"hostname" db:"hostname"`
+	Status      CampaignHostStatus `json:"status" db:"status"`
+	RespondedAt *time.Time         `json:"responded_at" db:"responded_at"`
+}
+
+// CampaignHostCounts are the number of hosts of an asynchronous campaign by
+// status.
+type CampaignHostCounts struct {
+	Pending   uint `json:"pending" db:"pending"`
+	Responded uint `json:"responded" db:"responded"`
+	Errored   uint `json:"errored" db:"errored"`
+}
+
+// ListCampaignHostsOptions defines the options to filter and page through the
+// hosts of an asynchronous campaign.
+type ListCampaignHostsOptions struct {
+	ListOptions
+
+	// Status filters the hosts with that status only.
+	Status CampaignHostStatus
 }
 
 // DistributedQueryCampaignTarget stores a target (host or label) for a
@@ -44,6 +160,37 @@ type DistributedQueryResult struct {
 	Error *string `json:"error"`
 }
 
+// DistributedQueryCampaignResult is the result of a distributed query
+// campaign stored for a host, so that it can be reviewed after the campaign
+// completed.
+type DistributedQueryCampaignResult struct {
+	DistributedQueryCampaignID uint   `json:"campaign_id" db:"distributed_query_campaign_id"`
+	HostID                     uint   `json:"host_id" db:"host_id"`
+	Hostname                   string `json:"hostname" db:"hostname"`
+	// Rows are the stored rows, which may be fewer than RowCount if the
+	// result was truncated.
+	Rows []map[string]string `json:"rows" db:"-"`
+	// RowCount is the number of rows returned by the host.
+	RowCount int `json:"row_count" db:"row_count"`
+	// Truncated is true if not all the rows returned by the host were stored,
+	// due to the limits of the live query results settings.
+	Truncated bool      `json:"truncated" db:"truncated"`
+	Error     *string   `json:"error" db:"error"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ListCampaignResultsOptions defines the options to filter and page through
+// the stored results of a campaign.
+type ListCampaignResultsOptions struct {
+	ListOptions
+
+	// HostID filters the result of that host only.
+	HostID *uint
+	// OnlyErrors filters the results of the hosts that failed to run the
+	// query.
+	OnlyErrors bool
+}
+
 type QueryResult struct {
 	HostID uint                `json:"host_id"`
 	Rows   []map[string]string `json:"rows"`
@@ -54,4 +201,130 @@ type QueryCampaignResult struct {
 	QueryID uint          `json:"query_id"`
 	Error   *string       `json:"error,omitempty"`
 	Results []QueryResult `json:"results"`
+	// Aggregate and Hosts are set instead of Results when the live query is
+	// aggregated.
+	Aggregate *LiveQueryAggregate   `json:"aggregate,omitempty"`
+	Hosts     []LiveQueryHostStatus `json:"hosts,omitempty"`
+}
+
+// LiveQueryAggregateFunc is an aggregate function evaluated over the rows of
+// a group of an aggregated live query.
+type LiveQueryAggregateFunc string
+
+const (
+	// LiveQueryAggregateCount counts the rows of the group.
+	LiveQueryAggregateCount LiveQueryAggregateFunc = "count"
+	// LiveQueryAggregateMin is the smallest value of the column in the group,
+	// the values are compared as numbers if they all are numbers.
+	LiveQueryAggregateMin LiveQueryAggregateFunc = "min"
+	// LiveQueryAggregateMax is the largest value of the column in the group.
+	LiveQueryAggregateMax LiveQueryAggregateFunc = "max"
+	// LiveQueryAggregateDistinct counts the distinct values of the column in
+	// the group.
+	LiveQueryAggregateDistinct LiveQueryAggregateFunc = "distinct"
+)
+
+// LiveQueryAggregateSpec is an aggregate function applied to a column.
+type LiveQueryAggregateSpec struct {
+	Func LiveQueryAggregateFunc `json:"func"`
+	// Column is required by all the functions but count.
+	Column string `json:"column,omitempty"`
+}
+
+// Name returns the name of the aggregate in the aggregated rows, e.g.
+// "count" or "max(version)".
+func (s LiveQueryAggregateSpec) Name() string {
+	if s.Column == "" {
+		return string(s.Func)
+	}
+	return fmt.Sprintf("%s(%s)", s.Func, s.Column)
+}
+
+// LiveQueryAggregation defines how the rows returned by the hosts for a live
+// query are aggregated by the server, so that only the aggregated rows are
+// returned instead of the rows of every host.
+type LiveQueryAggregation struct {
+	// GroupBy are the columns whose values define the groups of rows. All the
+	// rows are in the same group if empty.
+	GroupBy    []string                 `json:"group_by"`
+	Aggregates []LiveQueryAggregateSpec `json:"aggregates"`
+}
+
+// Validate returns an InvalidArgumentError if the aggregation is invalid.
+func (a *LiveQueryAggregation) Validate() error {
+	invalid := &InvalidArgumentError{}
+	if len(a.GroupBy) == 0 && len(a.Aggregates) == 0 {
+		invalid.Append("aggregation", "must define group_by columns or aggregates")
+	}
+	groupBy := make(map[string]bool, len(a.GroupBy))
+	for _, col := range a.GroupBy {
+		switch {
+		case col == "":
+			invalid.Append("group_by", "column must not be empty")
+		case col == LiveQueryAggregateHostCount:
+			invalid.Appendf("group_by", "column %s is reserved", col)
+		case groupBy[col]:
+			invalid.Appendf("group_by", "duplicate column %s", col)
+		}
+		groupBy[col] = true
+	}
+	names := make(map[string]bool, len(a.Aggregates))
+	for _, spec := range a.Aggregates {
+		switch spec.Func {
+		case LiveQueryAggregateCount:
+			if spec.Column != "" {
+				invalid.Append("aggregates", "count does not take a column")
+				continue
+			}
+		case LiveQueryAggregateMin, LiveQueryAggregateMax, LiveQueryAggregateDistinct:
+			if spec.Column == "" {
+				invalid.Appendf("aggregates", "%s requires a column", spec.Func)
+				continue
+			}
+		default:
+			invalid.Appendf("aggregates", "unsupported function %q, must be one of count, min, max or distinct", spec.Func)
+			continue
+		}
+		if name := spec.Name(); names[name] || groupBy[name] {
+			invalid.Appendf("aggregates", "duplicate aggregate %s", name)
+		}
+		names[spec.Name()] = true
+	}
+	if invalid.HasErrors() {
+		return invalid
+	}
+	return nil
+}
+
+// LiveQueryAggregateHostCount is the column of the aggregated rows with the
+// number of distinct hosts that returned rows in the group.
+const LiveQueryAggregateHostCount = "host_count"
+
+// LiveQueryAggregate is the running aggregate of the rows returned by the
+// hosts that responded to a live query so far.
+type LiveQueryAggregate struct {
+	// Rows has a row per group with the group_by columns, the host_count
+	// column and a column per aggregate named after LiveQueryAggregateSpec.Name.
+	// The rows are ordered by the values of the group_by columns.
+	Rows []map[string]string `json:"rows"`
+	// HostCount is the number of hosts that responded, with or without rows.
+	HostCount uint `json:"host_count"`
+	// ErrorCount is the number of hosts that failed to run the query.
+	ErrorCount uint `json:"error_count"`
+	// RowCount is the number of rows aggregated.
+	RowCount uint `json:"row_count"`
+	// Truncated is true if the maximum number of groups was reached, so that
+	// the rows of the other groups were not aggregated, or if the maximum
+	// number of distinct values of a group was reached, so that the distinct
+	// counts are lower bounds.
+	Truncated bool `json:"truncated"`
+}
+
+// LiveQueryHostStatus is the status of a host that responded to an
+// aggregated live query, its rows are only part of the aggregate.
+type LiveQueryHostStatus struct {
+	HostID   uint    `json:"host_id"`
+	Hostname string  `json:"hostname"`
+	RowCount int     `json:"row_count"`
+	Error    *string `json:"error,omitempty"`
 }
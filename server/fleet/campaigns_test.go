@@ -0,0 +1,86 @@
+package fleet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveQueryAggregationValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		aggregation LiveQueryAggregation
+		wantErr     string
+	}{
+		{
+			name:    "empty",
+			wantErr: "aggregation must define group_by columns or aggregates",
+		},
+		{
+			name:        "group by only",
+			aggregation: LiveQueryAggregation{GroupBy: []string{"version"}},
+		},
+		{
+			name: "all functions",
+			aggregation: LiveQueryAggregation{
+				GroupBy: []string{"name", "version"},
+				Aggregates: []LiveQueryAggregateSpec{
+					{Func: LiveQueryAggregateCount},
+					{Func: LiveQueryAggregateMin, Column: "size"},
+					{Func: LiveQueryAggregateMax, Column: "size"},
+					{Func: LiveQueryAggregateDistinct, Column: "path"},
+				},
+			},
+		},
+		{
+			name:        "empty group by column",
+			aggregation: LiveQueryAggregation{GroupBy: []string{"version", ""}},
+			wantErr:     "group_by column must not be empty",
+		},
+		{
+			name:        "reserved group by column",
+			aggregation: LiveQueryAggregation{GroupBy: []string{"host_count"}},
+			wantErr:     "group_by column host_count is reserved",
+		},
+		{
+			name:        "duplicate group by column",
+			aggregation: LiveQueryAggregation{GroupBy: []string{"version", "version"}},
+			wantErr:     "group_by duplicate column version",
+		},
+		{
+			name:        "unsupported function",
+			aggregation: LiveQueryAggregation{Aggregates: []LiveQueryAggregateSpec{{Func: "avg", Column: "size"}}},
+			wantErr:     `aggregates unsupported function "avg"`,
+		},
+		{
+			name:        "count with column",
+			aggregation: LiveQueryAggregation{Aggregates: []LiveQueryAggregateSpec{{Func: LiveQueryAggregateCount, Column: "size"}}},
+			wantErr:     "aggregates count does not take a column",
+		},
+		{
+			name:        "max without column",
+			aggregation: LiveQueryAggregation{Aggregates: []LiveQueryAggregateSpec{{Func: LiveQueryAggregateMax}}},
+			wantErr:     "aggregates max requires a column",
+		},
+		{
+			name: "duplicate aggregate",
+			aggregation: LiveQueryAggregation{Aggregates: []LiveQueryAggregateSpec{
+				{Func: LiveQueryAggregateMax, Column: "size"},
+				{Func: LiveQueryAggregateMax, Column: "size"},
+			}},
+			wantErr: "aggregates duplicate aggregate max(size)",
+		},
+	}
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.aggregation.Validate()
+			if c.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.wantErr)
+		})
+	}
+}
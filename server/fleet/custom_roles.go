@@ -0,0 +1,163 @@
+package fleet
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	CustomRoleKind = "custom_role"
+)
+
+// CustomRoleMaxNameLength is the maximum length of the name of a custom role,
+// as it is stored in the role columns of the users, teams and invites.
+const CustomRoleMaxNameLength = 64
+
+// CustomRolePermissionAll matches any action in a custom role permission.
+const CustomRolePermissionAll = "*"
+
+// CustomRole is a role defined by the admins as a set of permissions. Custom
+// roles can be assigned globally or per team, like the built-in roles.
+type CustomRole struct {
+	UpdateCreateTimestamps
+	ID          uint                  `json:"id" db:"id"`
+	Name        string                `json:"name" db:"name"`
+	Description string                `json:"description" db:"description"`
+	Permissions CustomRolePermissions `json:"permissions" db:"permissions"`
+}
+
+func (r CustomRole) AuthzType() string {
+	return "custom_role"
+}
+
+// CustomRoleSpec is the spec used to apply (create or update) a custom role.
+type CustomRoleSpec struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Permissions CustomRolePermissions `json:"permissions"`
+}
+
+// CustomRolePermission allows the actions on a type of object, identified by
+// its authorization type (e.g. "host", "query" or "targeted_query").
+type CustomRolePermission struct {
+	Object  string   `json:"object"`
+	Actions []string `json:"actions"`
+}
+
+// CustomRolePermissions is the list of permissions of a custom role, stored
+// as JSON.
+type CustomRolePermissions []CustomRolePermission
+
+// Scan implements the sql.Scanner interface
+func (p *CustomRolePermissions) Scan(val interface{}) error {
+	switch v := val.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	case nil: // sql NULL
+		return nil
+	default:
+		return fmt.Errorf("unsupported type: %T", v)
+	}
+}
+
+// Value implements the sql.Valuer interface
+func (p CustomRolePermissions) Value() (driver.Value, error) {
+	if p == nil {
+		p = CustomRolePermissions{}
+	}
+	return json.Marshal(p)
+}
+
+// customRoleObjects are the types of objects that custom roles can give
+// permissions on. The objects that give control over the users and their
+// access (users, invites, sessions, API tokens, enroll secrets, the app
+// config with its SSO settings, and the custom roles themselves) are reserved
+// to the built-in roles, see custom_role_excluded_objects in policy.rego. There
+// is no wildcard object, so that objects added later are not granted
+// implicitly. Note that the write permission on teams allows to manage the
+// users of the team.
+var customRoleObjects = map[string]bool{
+	"activity":           true,
+	"carve":              true,
+	"host":               true,
+	"label":              true,
+	"pack":               true,
+	"policy":             true,
+	"query":              true,
+	"software_inventory": true,
+	"target":             true,
+	"targeted_query":     true,
+	"team":               true,
+	"webhook_delivery":   true,
+}
+
+// customRoleActions are the actions that custom roles can allow. Changing
+// passwords and roles is reserved to the built-in roles.
+var customRoleActions = map[string]bool{
+	CustomRolePermissionAll: true,
+	ActionRead:              true,
+	ActionList:              true,
+	ActionWrite:             true,
+	ActionRun:               true,
+	ActionRunNew:            true,
+}
+
+// Validate returns an error if the spec can't be applied.
+func (s *CustomRoleSpec) Validate() error {
+	if s.Name == "" {
+		return errors.New("custom role name cannot be empty")
+	}
+	if len(s.Name) > CustomRoleMaxNameLength {
+		return fmt.Errorf("custom role name %q is longer than %d characters", s.Name, CustomRoleMaxNameLength)
+	}
+	if IsBuiltinRole(s.Name) {
+		return fmt.Errorf("custom role name %q is reserved for a built-in role", s.Name)
+	}
+	if len(s.Permissions) == 0 {
+		return fmt.Errorf("custom role %q: at least one permission is required", s.Name)
+	}
+	for _, p := range s.Permissions {
+		if !customRoleObjects[p.Object] {
+			return fmt.Errorf("custom role %q: invalid object %q", s.Name, p.Object)
+		}
+		if len(p.Actions) == 0 {
+			return fmt.Errorf("custom role %q: at least one action is required for object %q", s.Name, p.Object)
+		}
+		for _, action := range p.Actions {
+			if !customRoleActions[action] {
+				return fmt.Errorf("custom role %q: invalid action %q for object %q", s.Name, action, p.Object)
+			}
+		}
+	}
+	return nil
+}
+
+// IsBuiltinRole returns true if the role is one of the roles built in Fleet,
+// as opposed to the custom roles.
+func IsBuiltinRole(role string) bool {
+	return globalRoles[role] || teamRoles[role]
+}
+
+// IsCustomRole returns true if the role is not empty and is not a built-in
+// role. It doesn't check that the custom role exists.
+func IsCustomRole(role string) bool {
+	return role != "" && !IsBuiltinRole(role)
+}
+
+// HasCustomRole returns true if the user has a custom role, either globally
+// or on one of their teams.
+func (u *User) HasCustomRole() bool {
+	if u.GlobalRole != nil && IsCustomRole(*u.GlobalRole) {
+		return true
+	}
+	for _, team := range u.Teams {
+		if IsCustomRole(team.Role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package fleet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomRoleSpecValidate(t *testing.T) {
+	testCases := []struct {
+		spec    CustomRoleSpec
+		wantErr string
+	}{
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "host", Actions: []string{"read", "list"}}}}, ""},
+		{CustomRoleSpec{Name: "all", Permissions: CustomRolePermissions{{Object: "host", Actions: []string{"*"}}}}, ""},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "*", Actions: []string{"*"}}}}, `invalid object "*"`},
+		{CustomRoleSpec{Permissions: CustomRolePermissions{{Object: "host", Actions: []string{"read"}}}}, "name cannot be empty"},
+		{CustomRoleSpec{Name: strings.Repeat("a", 65), Permissions: CustomRolePermissions{{Object: "host", Actions: []string{"read"}}}}, "longer than 64 characters"},
+		{CustomRoleSpec{Name: RoleMaintainer, Permissions: CustomRolePermissions{{Object: "host", Actions: []string{"read"}}}}, "reserved for a built-in role"},
+		{CustomRoleSpec{Name: "helpdesk"}, "at least one permission is required"},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "hosts", Actions: []string{"read"}}}}, `invalid object "hosts"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "custom_role", Actions: []string{"write"}}}}, `invalid object "custom_role"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "host"}}}, "at least one action is required"},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "user", Actions: []string{"read"}}}}, `invalid object "user"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "invite", Actions: []string{"write"}}}}, `invalid object "invite"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "app_config", Actions: []string{"write"}}}}, `invalid object "app_config"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "enroll_secret", Actions: []string{"read"}}}}, `invalid object "enroll_secret"`},
+		{CustomRoleSpec{Name: "helpdesk", Permissions: CustomRolePermissions{{Object: "host", Actions: []string{ActionWriteRole}}}}, `invalid action "write_role"`},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.spec.Name, func(t *testing.T) {
+			err := tt.spec.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserHasCustomRole(t *testing.T) {
+	assert.False(t, (&User{}).HasCustomRole())
+	assert.False(t, (&User{GlobalRole: ptr.String(RoleAdmin)}).HasCustomRole())
+	assert.False(t, (&User{Teams: []UserTeam{{Role: RoleObserver}}}).HasCustomRole())
+	assert.True(t, (&User{GlobalRole: ptr.String("helpdesk")}).HasCustomRole())
+	assert.True(t, (&User{Teams: []UserTeam{{Role: RoleObserver}, {Role: "helpdesk"}}}).HasCustomRole())
+}
+
+func TestValidateRoleWithCustomRoles(t *testing.T) {
+	customRoles := map[string]bool{"helpdesk": true}
+
+	require.NoError(t, ValidateRoleWithCustomRoles(ptr.String(RoleAdmin), nil, customRoles))
+	require.NoError(t, ValidateRoleWithCustomRoles(ptr.String("helpdesk"), nil, customRoles))
+	require.NoError(t, ValidateRoleWithCustomRoles(nil, []UserTeam{{Role: "helpdesk"}}, customRoles))
+	require.Error(t, ValidateRoleWithCustomRoles(ptr.String("unknown"), nil, customRoles))
+	require.Error(t, ValidateRoleWithCustomRoles(nil, []UserTeam{{Role: "unknown"}}, customRoles))
+	require.Error(t, ValidateRoleWithCustomRoles(ptr.String("helpdesk"), []UserTeam{{Role: "helpdesk"}}, customRoles))
+
+	// custom roles are not accepted by ValidateRole
+	require.Error(t, ValidateRole(ptr.String("helpdesk"), nil))
+}
@@ -33,6 +33,36 @@ type InstallerStore interface {
 	Exists(ctx context.Context, installer Installer) (bool, error)
 }
 
+// ActivityArchiveStore stores the expired activities before they are deleted
+// from the database, grouped by month of creation (see
+// ActivityArchiveMonthFormat).
+type ActivityArchiveStore interface {
+	// ArchiveActivities stores the activities, which must all have been
+	// created in the same month.
+	ArchiveActivities(ctx context.Context, activities []*Activity) error
+	// ListArchivedActivityMonths returns the months that have archived
+	// activities, oldest first.
+	ListArchivedActivityMonths(ctx context.Context) ([]string, error)
+	// ListArchivedActivities returns a page of the activities archived for the
+	// month, ordered by ID. Only the Page and PerPage options are supported.
+	ListArchivedActivities(ctx context.Context, month string, opt ListOptions) ([]*Activity, error)
+}
+
+// LiveQueryExportStore stores the exported results of the live query
+// campaigns. The result of each host is written as a part while the campaign
+// runs, and the parts are combined in a single object once it completes.
+type LiveQueryExportStore interface {
+	// WriteCampaignResultPart stores the result of the host for the campaign in
+	// the format. Writing the result of a host again replaces it.
+	WriteCampaignResultPart(ctx context.Context, format LiveQueryExportFormat, result *DistributedQueryCampaignResult) error
+	// CombineCampaignExport combines the parts of the campaign in a single
+	// object, unless it is up to date with the parts.
+	CombineCampaignExport(ctx context.Context, campaignID uint, format LiveQueryExportFormat) error
+	// CampaignExportURL returns a presigned URL to download the combined
+	// results of the campaign, valid for the expiration.
+	CampaignExportURL(ctx context.Context, campaignID uint, format LiveQueryExportFormat, expiration time.Duration) (string, error)
+}
+
 // Datastore combines all the interfaces in the Fleet DAL
 type Datastore interface {
 	health.Checker
@@ -56,6 +86,12 @@ type Datastore interface {
 	// ConfirmPendingEmailChange will confirm new email address identified by token is valid. The new email will be
 	// written to user record. userID is the ID of the user whose e-mail is being changed.
 	ConfirmPendingEmailChange(ctx context.Context, userID uint, token string) (string, error)
+	// NewPasswordHistory records the password that was set for the user, and
+	// keeps only the keep most recent passwords of the user.
+	NewPasswordHistory(ctx context.Context, userID uint, password []byte, salt string, keep int) error
+	// ListPasswordHistory returns the limit most recent passwords of the user,
+	// most recent first.
+	ListPasswordHistory(ctx context.Context, userID uint, limit int) ([]*PasswordHistoryEntry, error)
 
 	///////////////////////////////////////////////////////////////////////////////
 	// QueryStore
@@ -101,12 +137,45 @@ type Datastore interface {
 
 	// CleanupDistributedQueryCampaigns will clean and trim metadata for old distributed query campaigns. Any campaign
 	// in the QueryWaiting state will be moved to QueryComplete after one minute. Any campaign in the QueryRunning state
-	// will be moved to QueryComplete after one day. Times are from creation time. The now parameter makes this method
-	// easier to test. The return values indicate how many campaigns were expired and any error.
+	// will be moved to QueryComplete after one day, or once its collect_until time passed if it is asynchronous. Times
+	// are from creation time. The now parameter makes this method easier to test. The return values indicate how many
+	// campaigns were expired and any error.
 	CleanupDistributedQueryCampaigns(ctx context.Context, now time.Time) (expired uint, err error)
 
 	DistributedQueryCampaignsForQuery(ctx context.Context, queryID uint) ([]*DistributedQueryCampaign, error)
 
+	// ListDistributedQueryCampaignsToExport returns the exported campaigns that
+	// completed as of now and whose results were not combined yet, or first
+	// combined after exportedSince.
+	ListDistributedQueryCampaignsToExport(ctx context.Context, now, exportedSince time.Time) ([]*DistributedQueryCampaign, error)
+	// MarkDistributedQueryCampaignExported sets the time the exported results
+	// of the campaign were first combined.
+	MarkDistributedQueryCampaignExported(ctx context.Context, id uint, exportedAt time.Time) error
+
+	// NewDistributedQueryCampaignResult stores the result of a campaign for a
+	// host, only the first result of a host is kept. If maxCampaignRows is
+	// positive, the rows are truncated so that no more than maxCampaignRows
+	// rows are stored for the campaign.
+	NewDistributedQueryCampaignResult(ctx context.Context, result *DistributedQueryCampaignResult, maxCampaignRows int) error
+	// ListDistributedQueryCampaignResults returns a page of the stored results
+	// of the campaign, ordered by host ID.
+	ListDistributedQueryCampaignResults(ctx context.Context, campaignID uint, opt ListCampaignResultsOptions) ([]*DistributedQueryCampaignResult, error)
+	// CleanupDistributedQueryCampaignResults deletes the campaign results
+	// stored before the provided time and returns how many were deleted. The
+	// hosts of the asynchronous campaigns whose collection window ended before
+	// that time are deleted too.
+	CleanupDistributedQueryCampaignResults(ctx context.Context, before time.Time) (deleted uint, err error)
+
+	// NewDistributedQueryCampaignHosts stores the hosts targeted by an
+	// asynchronous campaign.
+	NewDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, hostIDs []uint) error
+	// ListDistributedQueryCampaignHosts returns a page of the hosts targeted
+	// by an asynchronous campaign with their status, ordered by host ID.
+	ListDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, opt ListCampaignHostsOptions) ([]*DistributedQueryCampaignHost, error)
+	// CountDistributedQueryCampaignHosts returns the number of hosts targeted
+	// by an asynchronous campaign by status.
+	CountDistributedQueryCampaignHosts(ctx context.Context, campaignID uint) (*CampaignHostCounts, error)
+
 	///////////////////////////////////////////////////////////////////////////////
 	// PackStore is the datastore interface for managing query packs.
 
@@ -217,7 +286,10 @@ type Datastore interface {
 	// AddHostsToTeam adds hosts to an existing team, clearing their team settings if teamID is nil.
 	AddHostsToTeam(ctx context.Context, teamID *uint, hostIDs []uint) error
 
-	TotalAndUnseenHostsSince(ctx context.Context, daysCount int) (total int, unseen int, err error)
+	// TotalAndUnseenHostsSince returns the total number of hosts and the number
+	// of those hosts that have not been seen for at least daysCount days. If
+	// teamID is not nil, only the hosts of that team are counted.
+	TotalAndUnseenHostsSince(ctx context.Context, teamID *uint, daysCount int) (total int, unseen int, err error)
 
 	// DeleteHosts deletes associated tables for multiple hosts.
 	//
@@ -295,6 +367,81 @@ type Datastore interface {
 	// MarkSessionAccessed marks the currently tracked session as access to extend expiration
 	MarkSessionAccessed(ctx context.Context, session *Session) error
 
+	///////////////////////////////////////////////////////////////////////////////
+	// APITokenStore contains the methods for managing the personal API tokens of the users.
+
+	// NewAPIToken stores a new API token, identified by the hash of its key.
+	NewAPIToken(ctx context.Context, token *APIToken) (*APIToken, error)
+
+	// APITokenByKeyHash returns the API token with the given key hash.
+	APITokenByKeyHash(ctx context.Context, keyHash string) (*APIToken, error)
+
+	// ListAPITokens lists the API tokens of the user, ordered by name.
+	ListAPITokens(ctx context.Context, userID uint) ([]*APIToken, error)
+
+	// DeleteAPIToken deletes the API token of the user with the given id.
+	DeleteAPIToken(ctx context.Context, userID, id uint) error
+
+	// MarkAPITokenUsed sets the last time the API token was used to now.
+	MarkAPITokenUsed(ctx context.Context, id uint) error
+
+	///////////////////////////////////////////////////////////////////////////////
+	// CustomRoleStore contains the methods for managing the custom roles.
+
+	// ApplyCustomRoleSpecs creates the custom roles of the specs, or updates
+	// them if they already exist.
+	ApplyCustomRoleSpecs(ctx context.Context, specs []*CustomRoleSpec) error
+
+	// ListCustomRoles lists all the custom roles, ordered by name.
+	ListCustomRoles(ctx context.Context) ([]*CustomRole, error)
+
+	// CustomRoleByName returns the custom role with the given name.
+	CustomRoleByName(ctx context.Context, name string) (*CustomRole, error)
+
+	// DeleteCustomRole deletes the custom role with the given name. It fails
+	// if the role is assigned to users or invites.
+	DeleteCustomRole(ctx context.Context, name string) error
+
+	///////////////////////////////////////////////////////////////////////////////
+	// UserTOTPStore contains the methods for the two-factor authentication of
+	// the users.
+
+	// UserTOTP returns the TOTP configuration of the user, or a not found error
+	// if the user never enrolled.
+	UserTOTP(ctx context.Context, userID uint) (*UserTOTP, error)
+
+	// SaveUserTOTP creates or updates the TOTP configuration of the user.
+	SaveUserTOTP(ctx context.Context, totp *UserTOTP) error
+
+	// DeleteUserTOTP deletes the TOTP configuration and the recovery codes of
+	// the user.
+	DeleteUserTOTP(ctx context.Context, userID uint) error
+
+	// ReplaceUserTOTPRecoveryCodes replaces the recovery codes of the user with
+	// the given hashes, see HashTOTPRecoveryCode.
+	ReplaceUserTOTPRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+
+	// DeleteUserTOTPRecoveryCode deletes the recovery code of the user with the
+	// given hash, so that it can't be used again. It returns a not found error
+	// if the user has no such recovery code.
+	DeleteUserTOTPRecoveryCode(ctx context.Context, userID uint, codeHash string) error
+
+	// NewTOTPLoginChallenge stores a new two-factor login challenge. It also
+	// deletes the expired challenges.
+	NewTOTPLoginChallenge(ctx context.Context, challenge *TOTPLoginChallenge) error
+
+	// TOTPLoginChallengeByToken returns the two-factor login challenge with the
+	// given token.
+	TOTPLoginChallengeByToken(ctx context.Context, token string) (*TOTPLoginChallenge, error)
+
+	// IncrementTOTPLoginChallengeAttempts records an invalid code for the
+	// two-factor login challenge.
+	IncrementTOTPLoginChallengeAttempts(ctx context.Context, token string) error
+
+	// DeleteTOTPLoginChallenge deletes the two-factor login challenge once it
+	// is completed.
+	DeleteTOTPLoginChallenge(ctx context.Context, token string) error
+
 	///////////////////////////////////////////////////////////////////////////////
 	// AppConfigStore contains method for saving and retrieving application configuration
 
@@ -345,6 +492,15 @@ type Datastore interface {
 	// packAndSchedQueryNames, with the ID set to 0 if the corresponding
 	// scheduled query did not exist.
 	ScheduledQueryIDsByName(ctx context.Context, batchSize int, packAndSchedQueryNames ...[2]string) ([]uint, error)
+	// SaveQueryReportRows replaces the rows stored for the host with the
+	// latest snapshot result of the scheduled query. If maxRowsPerQuery is
+	// positive, the rows are truncated so that at most that many rows are
+	// stored for the scheduled query across all the hosts (but at least one
+	// row per host), and the stored rows of the host are flagged as truncated.
+	SaveQueryReportRows(ctx context.Context, scheduledQueryID, hostID uint, rows []map[string]string, fetchedAt time.Time, maxRowsPerQuery int) error
+	// ListQueryReportRows returns the rows stored for the scheduled queries
+	// of the query, for the hosts visible with the team filter.
+	ListQueryReportRows(ctx context.Context, queryID uint, filter TeamFilter, opt QueryReportOptions) ([]*QueryReportRow, error)
 
 	///////////////////////////////////////////////////////////////////////////////
 	// TeamStore
@@ -363,6 +519,9 @@ type Datastore interface {
 	ListTeams(ctx context.Context, filter TeamFilter, opt ListOptions) ([]*Team, error)
 	// TeamsSummary lists id, name and description for all teams.
 	TeamsSummary(ctx context.Context) ([]*TeamSummary, error)
+	// TeamsWithConfig lists all teams with their config, but without their
+	// users, enroll secrets and counts.
+	TeamsWithConfig(ctx context.Context) ([]*Team, error)
 	// SearchTeams searches teams using the provided query and ommitting the provided existing selection.
 	SearchTeams(ctx context.Context, filter TeamFilter, matchQuery string, omit ...uint) ([]*Team, error)
 	// TeamEnrollSecrets lists the enroll secrets for the team.
@@ -423,8 +582,15 @@ type Datastore interface {
 	///////////////////////////////////////////////////////////////////////////////
 	// ActivitiesStore
 
+	// NewActivity stores an activity performed by the user, along with the IP
+	// address and user agent of the request found in the context, if any.
 	NewActivity(ctx context.Context, user *User, activityType string, details *map[string]interface{}) error
-	ListActivities(ctx context.Context, opt ListOptions) ([]*Activity, error)
+	ListActivities(ctx context.Context, opt ListActivitiesOptions) ([]*Activity, error)
+	// MarkActivitiesAsStreamed marks the activities as exported to the audit
+	// log.
+	MarkActivitiesAsStreamed(ctx context.Context, activityIDs []uint) error
+	// DeleteActivities deletes the activities, e.g. once they have expired.
+	DeleteActivities(ctx context.Context, activityIDs []uint) error
 
 	///////////////////////////////////////////////////////////////////////////////
 	// StatisticsStore
@@ -604,6 +770,46 @@ type Datastore interface {
 	// UpdateJobs updates an existing job. Call this after processing a job.
 	UpdateJob(ctx context.Context, id uint, job *Job) (*Job, error)
 
+	///////////////////////////////////////////////////////////////////////////////
+	// WebhookDeliveryStore
+
+	// NewWebhookDelivery records a new webhook delivery.
+	NewWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) (*WebhookDelivery, error)
+
+	// WebhookDelivery returns the webhook delivery identified by id.
+	WebhookDelivery(ctx context.Context, id uint) (*WebhookDelivery, error)
+
+	// UpdateWebhookDelivery updates the status and last attempt's result of an
+	// existing webhook delivery.
+	UpdateWebhookDelivery(ctx context.Context, delivery *WebhookDelivery) error
+
+	// ListWebhookDeliveries lists the webhook deliveries, most recent first
+	// unless a different order is requested.
+	ListWebhookDeliveries(ctx context.Context, opts WebhookDeliveryListOptions) ([]*WebhookDelivery, error)
+
+	// CleanupWebhookDeliveries deletes the webhook deliveries created before
+	// the provided time and returns how many were deleted.
+	CleanupWebhookDeliveries(ctx context.Context, before time.Time) (deleted uint, err error)
+
+	///////////////////////////////////////////////////////////////////////////////
+	// PolicyAutomationTicketStore
+
+	// NewPolicyAutomationTickets records the ticket created by a failing
+	// policies integration (e.g. "jira" or "zendesk") for each of the hosts
+	// failing the policy.
+	NewPolicyAutomationTickets(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error
+
+	// ListOpenPolicyAutomationTickets returns the unresolved tickets created for
+	// any of the hosts failing the policy.
+	ListOpenPolicyAutomationTickets(ctx context.Context, policyID uint, hostIDs []uint) ([]*PolicyAutomationTicket, error)
+
+	// ListPolicyAutomationTicketOpenHosts returns the IDs of the hosts for
+	// which the ticket is not resolved yet.
+	ListPolicyAutomationTicketOpenHosts(ctx context.Context, integration, ticketID string) ([]uint, error)
+
+	// ResolvePolicyAutomationTicket marks the ticket as resolved for the host.
+	ResolvePolicyAutomationTicket(ctx context.Context, integration, ticketID string, hostID uint) error
+
 	///////////////////////////////////////////////////////////////////////////////
 	// Debug
 
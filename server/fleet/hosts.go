@@ -445,6 +445,11 @@ type AggregatedMacadminsData struct {
 type HostShort struct {
 	ID       uint   `json:"id" db:"id"`
 	Hostname string `json:"hostname" db:"hostname"`
+	// TeamID is the team of the host. It is only loaded by
+	// Datastore.HostsBySoftwareIDs and Datastore.HostsByCVE, to route
+	// vulnerabilities to the team automations, and is not part of the JSON
+	// representation.
+	TeamID *uint `json:"-" db:"team_id"`
 }
 
 type OSVersions struct {
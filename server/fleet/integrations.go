@@ -2,10 +2,13 @@ package fleet
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
+	"text/template"
 
 	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
 )
@@ -13,8 +16,11 @@ import (
 // TeamIntegrations contains the configuration for external services'
 // integrations for a specific team.
 type TeamIntegrations struct {
-	Jira    []*TeamJiraIntegration    `json:"jira"`
-	Zendesk []*TeamZendeskIntegration `json:"zendesk"`
+	Jira           []*TeamJiraIntegration           `json:"jira"`
+	Zendesk        []*TeamZendeskIntegration        `json:"zendesk"`
+	Webhook        []*TeamWebhookIntegration        `json:"webhook"`
+	Slack          []*TeamSlackIntegration          `json:"slack"`
+	MicrosoftTeams []*TeamMicrosoftTeamsIntegration `json:"microsoft_teams"`
 }
 
 // MatchWithIntegrations matches the team integrations to their corresponding
@@ -33,6 +39,18 @@ func (ti TeamIntegrations) MatchWithIntegrations(globalIntgs Integrations) (Inte
 	if err != nil {
 		return result, err
 	}
+	webhookIntgs, err := IndexWebhookIntegrations(globalIntgs.Webhook)
+	if err != nil {
+		return result, err
+	}
+	slackIntgs, err := IndexSlackIntegrations(globalIntgs.Slack)
+	if err != nil {
+		return result, err
+	}
+	msTeamsIntgs, err := IndexMicrosoftTeamsIntegrations(globalIntgs.MicrosoftTeams)
+	if err != nil {
+		return result, err
+	}
 
 	var errs []string
 	for _, tmJira := range ti.Jira {
@@ -43,6 +61,7 @@ func (ti TeamIntegrations) MatchWithIntegrations(globalIntgs Integrations) (Inte
 			continue
 		}
 		intg.EnableFailingPolicies = tmJira.EnableFailingPolicies
+		intg.EnableSoftwareVulnerabilities = tmJira.EnableSoftwareVulnerabilities
 		result.Jira = append(result.Jira, &intg)
 	}
 	for _, tmZendesk := range ti.Zendesk {
@@ -53,8 +72,39 @@ func (ti TeamIntegrations) MatchWithIntegrations(globalIntgs Integrations) (Inte
 			continue
 		}
 		intg.EnableFailingPolicies = tmZendesk.EnableFailingPolicies
+		intg.EnableSoftwareVulnerabilities = tmZendesk.EnableSoftwareVulnerabilities
 		result.Zendesk = append(result.Zendesk, &intg)
 	}
+	for _, tmWebhook := range ti.Webhook {
+		key := tmWebhook.UniqueKey()
+		intg, ok := webhookIntgs[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown webhook integration for url %s", tmWebhook.URL))
+			continue
+		}
+		intg.EnableFailingPolicies = tmWebhook.EnableFailingPolicies
+		result.Webhook = append(result.Webhook, &intg)
+	}
+	for _, tmSlack := range ti.Slack {
+		key := tmSlack.UniqueKey()
+		intg, ok := slackIntgs[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown Slack integration for webhook url %s and channel %s", tmSlack.WebhookURL, tmSlack.Channel))
+			continue
+		}
+		intg.EnableFailingPolicies = tmSlack.EnableFailingPolicies
+		result.Slack = append(result.Slack, &intg)
+	}
+	for _, tmMSTeams := range ti.MicrosoftTeams {
+		key := tmMSTeams.UniqueKey()
+		intg, ok := msTeamsIntgs[key]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown Microsoft Teams integration for url %s", tmMSTeams.URL))
+			continue
+		}
+		intg.EnableFailingPolicies = tmMSTeams.EnableFailingPolicies
+		result.MicrosoftTeams = append(result.MicrosoftTeams, &intg)
+	}
 
 	if len(errs) > 0 {
 		err = errors.New(strings.Join(errs, "\n"))
@@ -62,6 +112,37 @@ func (ti TeamIntegrations) MatchWithIntegrations(globalIntgs Integrations) (Inte
 	return result, err
 }
 
+// FailingPoliciesEnabled returns true if any of the team integrations is
+// enabled for failing policies.
+func (ti TeamIntegrations) FailingPoliciesEnabled() bool {
+	for _, intg := range ti.Jira {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range ti.Zendesk {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range ti.Webhook {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range ti.Slack {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range ti.MicrosoftTeams {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	return false
+}
+
 // Validate validates the team integrations for uniqueness.
 func (ti TeamIntegrations) Validate() error {
 	jira := make(map[string]*TeamJiraIntegration, len(ti.Jira))
@@ -81,6 +162,33 @@ func (ti TeamIntegrations) Validate() error {
 		}
 		zendesk[key] = z
 	}
+
+	webhook := make(map[string]*TeamWebhookIntegration, len(ti.Webhook))
+	for _, w := range ti.Webhook {
+		key := w.UniqueKey()
+		if _, ok := webhook[key]; ok {
+			return fmt.Errorf("duplicate webhook integration for url %s", w.URL)
+		}
+		webhook[key] = w
+	}
+
+	slack := make(map[string]*TeamSlackIntegration, len(ti.Slack))
+	for _, sl := range ti.Slack {
+		key := sl.UniqueKey()
+		if _, ok := slack[key]; ok {
+			return fmt.Errorf("duplicate Slack integration for webhook url %s and channel %s", sl.WebhookURL, sl.Channel)
+		}
+		slack[key] = sl
+	}
+
+	msTeams := make(map[string]*TeamMicrosoftTeamsIntegration, len(ti.MicrosoftTeams))
+	for _, mt := range ti.MicrosoftTeams {
+		key := mt.UniqueKey()
+		if _, ok := msTeams[key]; ok {
+			return fmt.Errorf("duplicate Microsoft Teams integration for url %s", mt.URL)
+		}
+		msTeams[key] = mt
+	}
 	return nil
 }
 
@@ -90,6 +198,9 @@ type TeamJiraIntegration struct {
 	URL                   string `json:"url"`
 	ProjectKey            string `json:"project_key"`
 	EnableFailingPolicies bool   `json:"enable_failing_policies"`
+	// EnableSoftwareVulnerabilities enables the creation of issues for the
+	// vulnerabilities affecting hosts of the team, listing only those hosts.
+	EnableSoftwareVulnerabilities bool `json:"enable_software_vulnerabilities"`
 }
 
 // UniqueKey returns the unique key of this integration.
@@ -103,6 +214,9 @@ type TeamZendeskIntegration struct {
 	URL                   string `json:"url"`
 	GroupID               int64  `json:"group_id"`
 	EnableFailingPolicies bool   `json:"enable_failing_policies"`
+	// EnableSoftwareVulnerabilities enables the creation of tickets for the
+	// vulnerabilities affecting hosts of the team, listing only those hosts.
+	EnableSoftwareVulnerabilities bool `json:"enable_software_vulnerabilities"`
 }
 
 // UniqueKey returns the unique key of this integration.
@@ -110,6 +224,71 @@ func (z TeamZendeskIntegration) UniqueKey() string {
 	return z.URL + "\n" + strconv.FormatInt(z.GroupID, 10)
 }
 
+// TeamWebhookIntegration configures an instance of an integration with a
+// generic HTTP webhook destination for a team.
+type TeamWebhookIntegration struct {
+	URL                   string `json:"url"`
+	EnableFailingPolicies bool   `json:"enable_failing_policies"`
+}
+
+// UniqueKey returns the unique key of this integration.
+func (w TeamWebhookIntegration) UniqueKey() string {
+	return w.URL
+}
+
+// TeamSlackIntegration configures an instance of an integration with Slack
+// for a team.
+type TeamSlackIntegration struct {
+	WebhookURL            string `json:"webhook_url"`
+	Channel               string `json:"channel"`
+	EnableFailingPolicies bool   `json:"enable_failing_policies"`
+}
+
+// UniqueKey returns the unique key of this integration.
+func (s TeamSlackIntegration) UniqueKey() string {
+	return s.WebhookURL + "\n" + s.Channel
+}
+
+// TeamMicrosoftTeamsIntegration configures an instance of an integration with
+// Microsoft Teams for a team.
+type TeamMicrosoftTeamsIntegration struct {
+	URL                   string `json:"url"`
+	EnableFailingPolicies bool   `json:"enable_failing_policies"`
+}
+
+// UniqueKey returns the unique key of this integration.
+func (t TeamMicrosoftTeamsIntegration) UniqueKey() string {
+	return t.URL
+}
+
+// MaskURLs replaces the URLs of the Slack and Microsoft Teams webhooks with
+// MaskedPassword, as anyone knowing those URLs can post to the channels.
+func (ti *TeamIntegrations) MaskURLs() {
+	for _, intg := range ti.Slack {
+		maskSecret(&intg.WebhookURL)
+	}
+	for _, intg := range ti.MicrosoftTeams {
+		maskSecret(&intg.URL)
+	}
+}
+
+// RestoreMaskedURLs replaces the URLs of the Slack and Microsoft Teams
+// webhooks that are set to MaskedPassword (e.g. when integrations previously
+// retrieved from the API are applied back) with the URL of the stored
+// integration at the same position.
+func (ti *TeamIntegrations) RestoreMaskedURLs(stored TeamIntegrations) {
+	for i, intg := range ti.Slack {
+		if i < len(stored.Slack) {
+			restoreMaskedSecret(&intg.WebhookURL, stored.Slack[i].WebhookURL)
+		}
+	}
+	for i, intg := range ti.MicrosoftTeams {
+		if i < len(stored.MicrosoftTeams) {
+			restoreMaskedSecret(&intg.URL, stored.MicrosoftTeams[i].URL)
+		}
+	}
+}
+
 // JiraIntegration configures an instance of an integration with the Jira
 // system.
 type JiraIntegration struct {
@@ -335,10 +514,355 @@ func makeTestZendeskRequest(ctx context.Context, intg *ZendeskIntegration) error
 	return nil
 }
 
+// WebhookIntegration configures an instance of an integration with a generic
+// HTTP webhook destination (e.g. ServiceNow, PagerDuty, Slack, etc.). Unlike
+// the WebhookSettings, deliveries go through the worker and are retried on
+// failure.
+type WebhookIntegration struct {
+	// URL is the destination URL of the webhook, requests are sent using the
+	// POST method.
+	URL string `json:"url"`
+	// Headers are additional HTTP headers to set on each request (e.g. for
+	// authentication).
+	Headers map[string]string `json:"headers,omitempty"`
+	// Secret is an optional shared secret used to sign the request body with
+	// HMAC-SHA256.
+	Secret string `json:"secret"`
+	// BodyTemplate is an optional Go template used to render the request body.
+	// If empty, a default JSON payload is sent.
+	BodyTemplate                  string `json:"body_template"`
+	EnableFailingPolicies         bool   `json:"enable_failing_policies"`
+	EnableSoftwareVulnerabilities bool   `json:"enable_software_vulnerabilities"`
+}
+
+func (w WebhookIntegration) uniqueKey() string {
+	return w.URL
+}
+
+// equal returns true if w and other have the same configuration. It is
+// required because the Headers map makes WebhookIntegration non-comparable.
+func (w WebhookIntegration) equal(other WebhookIntegration) bool {
+	if w.URL != other.URL || w.Secret != other.Secret || w.BodyTemplate != other.BodyTemplate ||
+		w.EnableFailingPolicies != other.EnableFailingPolicies ||
+		w.EnableSoftwareVulnerabilities != other.EnableSoftwareVulnerabilities {
+		return false
+	}
+	if len(w.Headers) != len(other.Headers) {
+		return false
+	}
+	for k, v := range w.Headers {
+		if ov, ok := other.Headers[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookIntegrationTemplateFuncs are the functions available to the body
+// template of a webhook integration.
+var WebhookIntegrationTemplateFuncs = template.FuncMap{
+	// json renders the value as a JSON-encoded string, so that it can safely
+	// be embedded in a JSON body.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseWebhookIntegrationTemplate parses the body template of a webhook
+// integration with the supported template functions.
+func ParseWebhookIntegrationTemplate(body string) (*template.Template, error) {
+	return template.New("").Funcs(WebhookIntegrationTemplateFuncs).Parse(body)
+}
+
+// IndexWebhookIntegrations indexes the provided webhook integrations in a map
+// keyed by URL. It returns an error if a duplicate configuration is found for
+// the same URL. This is typically used to index the original integrations
+// before applying the changes requested to modify the AppConfig.
+func IndexWebhookIntegrations(webhookIntgs []*WebhookIntegration) (map[string]WebhookIntegration, error) {
+	indexed := make(map[string]WebhookIntegration, len(webhookIntgs))
+	for _, intg := range webhookIntgs {
+		key := intg.uniqueKey()
+		if _, ok := indexed[key]; ok {
+			return nil, fmt.Errorf("duplicate webhook integration for url %s", intg.URL)
+		}
+		indexed[key] = *intg
+	}
+	return indexed, nil
+}
+
+// ValidateWebhookIntegrations validates that the merge of the original and
+// new webhook integrations does not result in any duplicate configuration,
+// and that each modified or added integration has a valid URL and body
+// template. No request is made to the destination, as it is not possible to
+// know what a harmless request would be for an arbitrary receiver. It
+// returns the list of integrations that were deleted, if any.
+//
+// On successful return, the newWebhookIntgs slice is ready to be saved - it
+// may have been updated using the original integrations if the secret or
+// header values were masked.
+func ValidateWebhookIntegrations(ctx context.Context, oriWebhookIntgsIndexed map[string]WebhookIntegration, newWebhookIntgs []*WebhookIntegration) (deleted []*WebhookIntegration, err error) {
+	newIndexed := make(map[string]*WebhookIntegration, len(newWebhookIntgs))
+	for i, new := range newWebhookIntgs {
+		key := new.uniqueKey()
+		// first check for uniqueness
+		if _, ok := newIndexed[key]; ok {
+			return nil, fmt.Errorf("duplicate webhook integration for url %s", new.URL)
+		}
+		newIndexed[key] = new
+
+		// check if existing integration is being edited
+		if old, ok := oriWebhookIntgsIndexed[key]; ok {
+			if old.equal(*new) {
+				// no further validation for unchanged integration
+				continue
+			}
+			// use stored secrets if request contains the masked values
+			if new.Secret == MaskedPassword {
+				new.Secret = old.Secret
+			}
+			for k, v := range new.Headers {
+				if v == MaskedPassword {
+					if oldV, ok := old.Headers[k]; ok {
+						new.Headers[k] = oldV
+					}
+				}
+			}
+		}
+
+		// new or updated, validate it
+		if err := validateIntegrationURL(new.URL); err != nil {
+			return nil, fmt.Errorf("webhook integration at index %d: %w", i, err)
+		}
+		// a masked value that has no stored value to restore, e.g. for a new
+		// integration, would be saved as is.
+		if new.Secret == MaskedPassword {
+			return nil, fmt.Errorf("webhook integration at index %d: the secret can't be the masked value", i)
+		}
+		for k, v := range new.Headers {
+			if v == MaskedPassword {
+				return nil, fmt.Errorf("webhook integration at index %d: the value of header %s can't be the masked value", i, k)
+			}
+		}
+		if new.BodyTemplate != "" {
+			if _, err := ParseWebhookIntegrationTemplate(new.BodyTemplate); err != nil {
+				return nil, fmt.Errorf("webhook integration at index %d: invalid body template: %w", i, err)
+			}
+		}
+	}
+
+	// collect any deleted integration
+	for key, intg := range oriWebhookIntgsIndexed {
+		intg := intg // do not take address of iteration variable
+		if _, ok := newIndexed[key]; !ok {
+			deleted = append(deleted, &intg)
+		}
+	}
+	return deleted, nil
+}
+
+// validateIntegrationURL validates that the raw URL of an integration is an
+// absolute http or https URL.
+func validateIntegrationURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid url scheme: %q", u.Scheme)
+	}
+	return nil
+}
+
+// SlackIntegration configures an instance of an integration with Slack.
+// Messages are posted either via an incoming webhook (WebhookURL) or via the
+// chat.postMessage method of the Slack API (APIToken and Channel).
+type SlackIntegration struct {
+	WebhookURL                    string `json:"webhook_url"`
+	APIToken                      string `json:"api_token"`
+	Channel                       string `json:"channel"`
+	EnableFailingPolicies         bool   `json:"enable_failing_policies"`
+	EnableSoftwareVulnerabilities bool   `json:"enable_software_vulnerabilities"`
+}
+
+func (s SlackIntegration) uniqueKey() string {
+	return s.WebhookURL + "\n" + s.Channel
+}
+
+// IndexSlackIntegrations indexes the provided Slack integrations in a map
+// keyed by 'WebhookURL\nChannel'. It returns an error if a duplicate
+// configuration is found for the same combination. This is typically used to
+// index the original integrations before applying the changes requested to
+// modify the AppConfig.
+func IndexSlackIntegrations(slackIntgs []*SlackIntegration) (map[string]SlackIntegration, error) {
+	indexed := make(map[string]SlackIntegration, len(slackIntgs))
+	for _, intg := range slackIntgs {
+		key := intg.uniqueKey()
+		if _, ok := indexed[key]; ok {
+			return nil, fmt.Errorf("duplicate Slack integration for webhook url %s and channel %s", intg.WebhookURL, intg.Channel)
+		}
+		indexed[key] = *intg
+	}
+	return indexed, nil
+}
+
+// ValidateSlackIntegrations validates that the merge of the original and new
+// Slack integrations does not result in any duplicate configuration, and that
+// each modified or added integration sets either a valid webhook URL or an
+// API token and channel. As for the webhook integrations, no message is
+// posted to validate it. It returns the list of integrations that were
+// deleted, if any.
+//
+// On successful return, the newSlackIntgs slice is ready to be saved - it may
+// have been updated using the original integrations if the API token was
+// masked.
+func ValidateSlackIntegrations(ctx context.Context, oriSlackIntgsIndexed map[string]SlackIntegration, newSlackIntgs []*SlackIntegration) (deleted []*SlackIntegration, err error) {
+	newIndexed := make(map[string]*SlackIntegration, len(newSlackIntgs))
+	for i, new := range newSlackIntgs {
+		key := new.uniqueKey()
+		// first check for uniqueness
+		if _, ok := newIndexed[key]; ok {
+			return nil, fmt.Errorf("duplicate Slack integration for webhook url %s and channel %s", new.WebhookURL, new.Channel)
+		}
+		newIndexed[key] = new
+
+		// check if existing integration is being edited
+		if old, ok := oriSlackIntgsIndexed[key]; ok {
+			if old == *new {
+				// no further validation for unchanged integration
+				continue
+			}
+			// use stored API token if request contains the masked value
+			if new.APIToken == MaskedPassword {
+				new.APIToken = old.APIToken
+			}
+		}
+
+		// new or updated, validate it
+		switch {
+		case new.WebhookURL != "" && new.APIToken != "":
+			return nil, fmt.Errorf("Slack integration at index %d: cannot set both webhook_url and api_token", i)
+		case new.WebhookURL != "":
+			if err := validateIntegrationURL(new.WebhookURL); err != nil {
+				return nil, fmt.Errorf("Slack integration at index %d: %w", i, err)
+			}
+		case new.APIToken == "" || new.APIToken == MaskedPassword || new.Channel == "":
+			return nil, fmt.Errorf("Slack integration at index %d: missing webhook_url, or api_token and channel", i)
+		}
+	}
+
+	// collect any deleted integration
+	for key, intg := range oriSlackIntgsIndexed {
+		intg := intg // do not take address of iteration variable
+		if _, ok := newIndexed[key]; !ok {
+			deleted = append(deleted, &intg)
+		}
+	}
+	return deleted, nil
+}
+
+// MicrosoftTeamsIntegration configures an instance of an integration with a
+// Microsoft Teams channel via an incoming webhook connector.
+type MicrosoftTeamsIntegration struct {
+	URL                           string `json:"url"`
+	EnableFailingPolicies         bool   `json:"enable_failing_policies"`
+	EnableSoftwareVulnerabilities bool   `json:"enable_software_vulnerabilities"`
+}
+
+func (t MicrosoftTeamsIntegration) uniqueKey() string {
+	return t.URL
+}
+
+// IndexMicrosoftTeamsIntegrations indexes the provided Microsoft Teams
+// integrations in a map keyed by URL. It returns an error if a duplicate
+// configuration is found for the same URL. This is typically used to index
+// the original integrations before applying the changes requested to modify
+// the AppConfig.
+func IndexMicrosoftTeamsIntegrations(msTeamsIntgs []*MicrosoftTeamsIntegration) (map[string]MicrosoftTeamsIntegration, error) {
+	indexed := make(map[string]MicrosoftTeamsIntegration, len(msTeamsIntgs))
+	for _, intg := range msTeamsIntgs {
+		key := intg.uniqueKey()
+		if _, ok := indexed[key]; ok {
+			return nil, fmt.Errorf("duplicate Microsoft Teams integration for url %s", intg.URL)
+		}
+		indexed[key] = *intg
+	}
+	return indexed, nil
+}
+
+// ValidateMicrosoftTeamsIntegrations validates that the merge of the original
+// and new Microsoft Teams integrations does not result in any duplicate
+// configuration, and that each modified or added integration has a valid URL.
+// It returns the list of integrations that were deleted, if any.
+func ValidateMicrosoftTeamsIntegrations(ctx context.Context, oriMSTeamsIntgsIndexed map[string]MicrosoftTeamsIntegration, newMSTeamsIntgs []*MicrosoftTeamsIntegration) (deleted []*MicrosoftTeamsIntegration, err error) {
+	newIndexed := make(map[string]*MicrosoftTeamsIntegration, len(newMSTeamsIntgs))
+	for i, new := range newMSTeamsIntgs {
+		key := new.uniqueKey()
+		// first check for uniqueness
+		if _, ok := newIndexed[key]; ok {
+			return nil, fmt.Errorf("duplicate Microsoft Teams integration for url %s", new.URL)
+		}
+		newIndexed[key] = new
+
+		if old, ok := oriMSTeamsIntgsIndexed[key]; ok && old == *new {
+			// no further validation for unchanged integration
+			continue
+		}
+
+		// new or updated, validate it
+		if err := validateIntegrationURL(new.URL); err != nil {
+			return nil, fmt.Errorf("Microsoft Teams integration at index %d: %w", i, err)
+		}
+	}
+
+	// collect any deleted integration
+	for key, intg := range oriMSTeamsIntgsIndexed {
+		intg := intg // do not take address of iteration variable
+		if _, ok := newIndexed[key]; !ok {
+			deleted = append(deleted, &intg)
+		}
+	}
+	return deleted, nil
+}
+
 // Integrations configures the integrations with external systems.
 type Integrations struct {
-	Jira    []*JiraIntegration    `json:"jira"`
-	Zendesk []*ZendeskIntegration `json:"zendesk"`
+	Jira           []*JiraIntegration           `json:"jira"`
+	Zendesk        []*ZendeskIntegration        `json:"zendesk"`
+	Webhook        []*WebhookIntegration        `json:"webhook"`
+	Slack          []*SlackIntegration          `json:"slack"`
+	MicrosoftTeams []*MicrosoftTeamsIntegration `json:"microsoft_teams"`
+}
+
+// FailingPoliciesEnabled returns true if any of the integrations is enabled
+// for failing policies.
+func (i Integrations) FailingPoliciesEnabled() bool {
+	for _, intg := range i.Jira {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range i.Zendesk {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range i.Webhook {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range i.Slack {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	for _, intg := range i.MicrosoftTeams {
+		if intg.EnableFailingPolicies {
+			return true
+		}
+	}
+	return false
 }
 
 // ValidateEnabledVulnerabilitiesIntegrations checks that a single integration
@@ -359,8 +883,26 @@ func ValidateEnabledVulnerabilitiesIntegrations(webhook VulnerabilitiesWebhookSe
 			zendeskEnabledCount++
 		}
 	}
+	var webhookIntgEnabledCount int
+	for _, webhook := range intgs.Webhook {
+		if webhook.EnableSoftwareVulnerabilities {
+			webhookIntgEnabledCount++
+		}
+	}
+	var slackEnabledCount int
+	for _, slack := range intgs.Slack {
+		if slack.EnableSoftwareVulnerabilities {
+			slackEnabledCount++
+		}
+	}
+	var msTeamsEnabledCount int
+	for _, msTeams := range intgs.MicrosoftTeams {
+		if msTeams.EnableSoftwareVulnerabilities {
+			msTeamsEnabledCount++
+		}
+	}
 
-	if webhookEnabled && (jiraEnabledCount > 0 || zendeskEnabledCount > 0) {
+	if webhookEnabled && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0 || slackEnabledCount > 0 || msTeamsEnabledCount > 0) {
 		invalid.Append("vulnerabilities", "cannot enable both webhook vulnerabilities and integration automations")
 	}
 	if jiraEnabledCount > 0 && zendeskEnabledCount > 0 {
@@ -372,6 +914,24 @@ func ValidateEnabledVulnerabilitiesIntegrations(webhook VulnerabilitiesWebhookSe
 	if zendeskEnabledCount > 1 {
 		invalid.Append("vulnerabilities", "cannot enable more than one zendesk integration")
 	}
+	if webhookIntgEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0) {
+		invalid.Append("vulnerabilities", "cannot enable both webhook integration and jira or zendesk automations")
+	}
+	if webhookIntgEnabledCount > 1 {
+		invalid.Append("vulnerabilities", "cannot enable more than one webhook integration")
+	}
+	if slackEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0) {
+		invalid.Append("vulnerabilities", "cannot enable both slack integration and jira, zendesk or webhook automations")
+	}
+	if slackEnabledCount > 1 {
+		invalid.Append("vulnerabilities", "cannot enable more than one slack integration")
+	}
+	if msTeamsEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0 || slackEnabledCount > 0) {
+		invalid.Append("vulnerabilities", "cannot enable both microsoft teams integration and jira, zendesk, webhook or slack automations")
+	}
+	if msTeamsEnabledCount > 1 {
+		invalid.Append("vulnerabilities", "cannot enable more than one microsoft teams integration")
+	}
 }
 
 // ValidateEnabledFailingPoliciesIntegrations checks that a single integration
@@ -392,8 +952,26 @@ func ValidateEnabledFailingPoliciesIntegrations(webhook FailingPoliciesWebhookSe
 			zendeskEnabledCount++
 		}
 	}
+	var webhookIntgEnabledCount int
+	for _, webhook := range intgs.Webhook {
+		if webhook.EnableFailingPolicies {
+			webhookIntgEnabledCount++
+		}
+	}
+	var slackEnabledCount int
+	for _, slack := range intgs.Slack {
+		if slack.EnableFailingPolicies {
+			slackEnabledCount++
+		}
+	}
+	var msTeamsEnabledCount int
+	for _, msTeams := range intgs.MicrosoftTeams {
+		if msTeams.EnableFailingPolicies {
+			msTeamsEnabledCount++
+		}
+	}
 
-	if webhookEnabled && (jiraEnabledCount > 0 || zendeskEnabledCount > 0) {
+	if webhookEnabled && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0 || slackEnabledCount > 0 || msTeamsEnabledCount > 0) {
 		invalid.Append("failing policies", "cannot enable both webhook failing policies and integration automations")
 	}
 	if jiraEnabledCount > 0 && zendeskEnabledCount > 0 {
@@ -405,6 +983,24 @@ func ValidateEnabledFailingPoliciesIntegrations(webhook FailingPoliciesWebhookSe
 	if zendeskEnabledCount > 1 {
 		invalid.Append("failing policies", "cannot enable more than one zendesk integration")
 	}
+	if webhookIntgEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0) {
+		invalid.Append("failing policies", "cannot enable both webhook integration and jira or zendesk automations")
+	}
+	if webhookIntgEnabledCount > 1 {
+		invalid.Append("failing policies", "cannot enable more than one webhook integration")
+	}
+	if slackEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0) {
+		invalid.Append("failing policies", "cannot enable both slack integration and jira, zendesk or webhook automations")
+	}
+	if slackEnabledCount > 1 {
+		invalid.Append("failing policies", "cannot enable more than one slack integration")
+	}
+	if msTeamsEnabledCount > 0 && (jiraEnabledCount > 0 || zendeskEnabledCount > 0 || webhookIntgEnabledCount > 0 || slackEnabledCount > 0) {
+		invalid.Append("failing policies", "cannot enable both microsoft teams integration and jira, zendesk, webhook or slack automations")
+	}
+	if msTeamsEnabledCount > 1 {
+		invalid.Append("failing policies", "cannot enable more than one microsoft teams integration")
+	}
 }
 
 // ValidateEnabledFailingPoliciesTeamIntegrations is like
@@ -412,8 +1008,11 @@ func ValidateEnabledFailingPoliciesIntegrations(webhook FailingPoliciesWebhookSe
 // integration structs.
 func ValidateEnabledFailingPoliciesTeamIntegrations(webhook FailingPoliciesWebhookSettings, teamIntgs TeamIntegrations, invalid *InvalidArgumentError) {
 	intgs := Integrations{
-		Jira:    make([]*JiraIntegration, len(teamIntgs.Jira)),
-		Zendesk: make([]*ZendeskIntegration, len(teamIntgs.Zendesk)),
+		Jira:           make([]*JiraIntegration, len(teamIntgs.Jira)),
+		Zendesk:        make([]*ZendeskIntegration, len(teamIntgs.Zendesk)),
+		Webhook:        make([]*WebhookIntegration, len(teamIntgs.Webhook)),
+		Slack:          make([]*SlackIntegration, len(teamIntgs.Slack)),
+		MicrosoftTeams: make([]*MicrosoftTeamsIntegration, len(teamIntgs.MicrosoftTeams)),
 	}
 	for i, j := range teamIntgs.Jira {
 		intgs.Jira[i] = &JiraIntegration{
@@ -429,5 +1028,54 @@ func ValidateEnabledFailingPoliciesTeamIntegrations(webhook FailingPoliciesWebho
 			EnableFailingPolicies: z.EnableFailingPolicies,
 		}
 	}
+	for i, w := range teamIntgs.Webhook {
+		intgs.Webhook[i] = &WebhookIntegration{
+			URL:                   w.URL,
+			EnableFailingPolicies: w.EnableFailingPolicies,
+		}
+	}
+	for i, sl := range teamIntgs.Slack {
+		intgs.Slack[i] = &SlackIntegration{
+			WebhookURL:            sl.WebhookURL,
+			Channel:               sl.Channel,
+			EnableFailingPolicies: sl.EnableFailingPolicies,
+		}
+	}
+	for i, mt := range teamIntgs.MicrosoftTeams {
+		intgs.MicrosoftTeams[i] = &MicrosoftTeamsIntegration{
+			URL:                   mt.URL,
+			EnableFailingPolicies: mt.EnableFailingPolicies,
+		}
+	}
 	ValidateEnabledFailingPoliciesIntegrations(webhook, intgs, invalid)
 }
+
+// ValidateEnabledVulnerabilitiesTeamIntegrations is like
+// ValidateEnabledVulnerabilitiesIntegrations, but for team-specific
+// integration structs. The webhook is nil if the team's vulnerabilities
+// webhook was never set.
+func ValidateEnabledVulnerabilitiesTeamIntegrations(webhook *VulnerabilitiesWebhookSettings, teamIntgs TeamIntegrations, invalid *InvalidArgumentError) {
+	intgs := Integrations{
+		Jira:    make([]*JiraIntegration, len(teamIntgs.Jira)),
+		Zendesk: make([]*ZendeskIntegration, len(teamIntgs.Zendesk)),
+	}
+	for i, j := range teamIntgs.Jira {
+		intgs.Jira[i] = &JiraIntegration{
+			URL:                           j.URL,
+			ProjectKey:                    j.ProjectKey,
+			EnableSoftwareVulnerabilities: j.EnableSoftwareVulnerabilities,
+		}
+	}
+	for i, z := range teamIntgs.Zendesk {
+		intgs.Zendesk[i] = &ZendeskIntegration{
+			URL:                           z.URL,
+			GroupID:                       z.GroupID,
+			EnableSoftwareVulnerabilities: z.EnableSoftwareVulnerabilities,
+		}
+	}
+	var webhookSettings VulnerabilitiesWebhookSettings
+	if webhook != nil {
+		webhookSettings = *webhook
+	}
+	ValidateEnabledVulnerabilitiesIntegrations(webhookSettings, intgs, invalid)
+}
@@ -0,0 +1,32 @@
+package fleet
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateWebhookIntegrationsMaskedValues(t *testing.T) {
+	ctx := context.Background()
+	stored, err := IndexWebhookIntegrations([]*WebhookIntegration{
+		{URL: "https://example.com/a", Secret: "secret", Headers: map[string]string{"Authorization": "token"}},
+	})
+	require.NoError(t, err)
+
+	// the masked values of an existing integration are restored
+	intg := &WebhookIntegration{URL: "https://example.com/a", Secret: MaskedPassword, Headers: map[string]string{"Authorization": MaskedPassword}, EnableFailingPolicies: true}
+	_, err = ValidateWebhookIntegrations(ctx, stored, []*WebhookIntegration{intg})
+	require.NoError(t, err)
+	require.Equal(t, "secret", intg.Secret)
+	require.Equal(t, "token", intg.Headers["Authorization"])
+
+	// but can't be set on a new integration
+	_, err = ValidateWebhookIntegrations(ctx, stored, []*WebhookIntegration{{URL: "https://example.com/b", Secret: MaskedPassword}})
+	require.ErrorContains(t, err, "the secret can't be the masked value")
+
+	// nor on a new header of an existing integration
+	intg = &WebhookIntegration{URL: "https://example.com/a", Secret: "secret", Headers: map[string]string{"X-Other": MaskedPassword}}
+	_, err = ValidateWebhookIntegrations(ctx, stored, []*WebhookIntegration{intg})
+	require.ErrorContains(t, err, "the value of header X-Other can't be the masked value")
+}
@@ -29,4 +29,7 @@ type Job struct {
 	State     JobState         `json:"state" db:"state"`
 	Retries   int              `json:"retries" db:"retries"`
 	Error     string           `json:"error" db:"error"`
+	// NotBefore is the earliest time at which the job can be processed, it is
+	// used to delay the retry of a failed job.
+	NotBefore time.Time `json:"not_before" db:"not_before"`
 }
@@ -1,11 +1,17 @@
 package fleet
 
+import "time"
+
 // LiveQueryStore defines an interface for storing and retrieving the status of
 // live queries in the Fleet system.
 type LiveQueryStore interface {
 	// RunQuery starts a query with the given name and SQL, targeting the
 	// provided host IDs.
 	RunQuery(name, sql string, hostIDs []uint) error
+	// RunQueryWithExpiration is like RunQuery, but the hosts keep receiving
+	// the query until it is stopped or the expiration is reached, e.g. for the
+	// collection window of an asynchronous live query.
+	RunQueryWithExpiration(name, sql string, hostIDs []uint, expiration time.Duration) error
 	// StopQuery stops a running query with the given name. Hosts will no longer
 	// receive the query after StopQuery has been called.
 	StopQuery(name string) error
@@ -3,6 +3,7 @@ package fleet
 import (
 	"errors"
 	"strings"
+	"time"
 )
 
 // PolicyPayload holds data for policy creation.
@@ -244,6 +245,23 @@ type PolicySetHost struct {
 	Hostname string
 }
 
+// PolicyAutomationTicket is a ticket created by a failing policies
+// integration for a host failing a policy. The ticket is resolved when all of
+// its hosts pass the policy again.
+type PolicyAutomationTicket struct {
+	ID        uint      `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+	PolicyID  uint      `db:"policy_id"`
+	HostID    uint      `db:"host_id"`
+	TeamID    *uint     `db:"team_id"`
+	// Integration is the integration that created the ticket, either "jira" or
+	// "zendesk".
+	Integration string `db:"integration"`
+	// TicketID is the Jira issue key or the Zendesk ticket ID.
+	TicketID   string     `db:"ticket_id"`
+	ResolvedAt *time.Time `db:"resolved_at"`
+}
+
 type PolicyMembershipResult struct {
 	HostID   uint
 	PolicyID uint
@@ -92,3 +92,28 @@ type ScheduledQueryStats struct {
 	UserTime     int       `json:"user_time" db:"user_time"`
 	WallTime     int       `json:"wall_time" db:"wall_time"`
 }
+
+// QueryReportRow is a row of the latest snapshot result of a scheduled query
+// on a host.
+type QueryReportRow struct {
+	ScheduledQueryID uint   `json:"scheduled_query_id" db:"scheduled_query_id"`
+	HostID           uint   `json:"host_id" db:"host_id"`
+	Hostname         string `json:"hostname" db:"hostname"`
+	// TeamID is the team of the host, nil if it has no team.
+	TeamID  *uint             `json:"team_id" db:"team_id"`
+	Columns map[string]string `json:"columns" db:"-"`
+	// Truncated is true if some rows of the result of the host were not stored
+	// because of the maximum number of rows per query.
+	Truncated   bool      `json:"truncated" db:"truncated"`
+	LastFetched time.Time `json:"last_fetched" db:"last_fetched"`
+}
+
+// QueryReportOptions are the options to list the rows of a query report.
+type QueryReportOptions struct {
+	ListOptions
+
+	// TeamID filters the rows of the hosts in this team.
+	TeamID *uint
+	// HostID filters the rows of this host.
+	HostID *uint
+}
@@ -0,0 +1,9 @@
+package fleet
+
+// SCIMUserPayload holds the attributes of a user that can be set by the
+// identity provider via SCIM provisioning.
+type SCIMUserPayload struct {
+	Name   *string
+	Email  *string
+	Active *bool
+}
@@ -85,6 +85,9 @@ type Service interface {
 	// false will take a user out of this state. The updated user is returned.
 	RequirePasswordReset(ctx context.Context, uid uint, require bool) (*User, error)
 
+	// UnlockUser unlocks the account of a user locked after too many failed logins, and resets its failed logins.
+	UnlockUser(ctx context.Context, id uint) error
+
 	// PerformRequiredPasswordReset resets a password for a user that is in the required reset state. It must be called
 	// with the logged in viewer context of that user.
 	PerformRequiredPasswordReset(ctx context.Context, password string) (*User, error)
@@ -102,6 +105,44 @@ type Service interface {
 	// write the new email address to user.
 	ChangeUserEmail(ctx context.Context, token string) (string, error)
 
+	///////////////////////////////////////////////////////////////////////////////
+	// SCIMService
+
+	// AuthenticateSCIM validates the bearer token of a SCIM provisioning request
+	// against the token of the server configuration.
+	AuthenticateSCIM(ctx context.Context, token string) error
+
+	// SCIMNewUser creates a SSO user provisioned by the identity provider. The
+	// user is a global observer until it is added to a group.
+	SCIMNewUser(ctx context.Context, p SCIMUserPayload) (*User, error)
+
+	// SCIMUser returns a user managed by SCIM. Only the SSO users are managed
+	// by SCIM, the other users are not found.
+	SCIMUser(ctx context.Context, id uint) (*User, error)
+
+	// SCIMModifyUser modifies a user provisioned by the identity provider.
+	// Deactivating a user disables it and deletes all of its sessions.
+	SCIMModifyUser(ctx context.Context, id uint, p SCIMUserPayload) (*User, error)
+
+	// SCIMDeleteUser deletes a user managed by SCIM and all of its sessions.
+	SCIMDeleteUser(ctx context.Context, id uint) error
+
+	// SCIMListGroups returns the teams backing the SCIM groups, filtered by
+	// name if not empty.
+	SCIMListGroups(ctx context.Context, name string) ([]*Team, error)
+
+	// SCIMGroup returns the team backing a SCIM group and its members.
+	SCIMGroup(ctx context.Context, teamID uint) (*Team, []*User, error)
+
+	// SCIMNewGroup creates the team backing a SCIM group.
+	SCIMNewGroup(ctx context.Context, name string) (*Team, error)
+
+	// SCIMSetGroupMembers sets the members of the team backing a SCIM group.
+	// Users added to the team are team observers (unless they already have a
+	// role in the team), users with a global admin or maintainer role are left
+	// untouched.
+	SCIMSetGroupMembers(ctx context.Context, teamID uint, userIDs []uint) error
+
 	///////////////////////////////////////////////////////////////////////////////
 	// Session
 
@@ -130,6 +171,56 @@ type Service interface {
 	GetSessionByKey(ctx context.Context, key string) (session *Session, err error)
 	DeleteSession(ctx context.Context, id uint) (err error)
 
+	///////////////////////////////////////////////////////////////////////////////
+	// TOTPService is the service interface for the two-factor authentication of the password logins.
+
+	// LoginTOTP completes the two-factor login challenge returned by Login with a TOTP code or a recovery code and
+	// returns the new session. If the user enrolled as part of the login (see EnrollTOTPForLogin), their new
+	// recovery codes are returned.
+	LoginTOTP(ctx context.Context, token, code string) (user *User, session *Session, recoveryCodes []string, err error)
+	// EnrollTOTPForLogin enrolls the user of the two-factor login challenge when two-factor authentication is
+	// enforced and the user is not enrolled yet.
+	EnrollTOTPForLogin(ctx context.Context, token string) (*TOTPEnrollment, error)
+	// EnrollTOTP starts the two-factor authentication enrollment of the current user, it is only enabled once
+	// verified with VerifyTOTP.
+	EnrollTOTP(ctx context.Context) (*TOTPEnrollment, error)
+	// VerifyTOTP verifies a code of the current enrollment of the user, enables the two-factor authentication and
+	// returns the recovery codes of the user.
+	VerifyTOTP(ctx context.Context, code string) (recoveryCodes []string, err error)
+	// DeleteUserTOTP disables the two-factor authentication of the user. Users disabling their own two-factor
+	// authentication must provide their password or a valid code.
+	DeleteUserTOTP(ctx context.Context, userID uint, password, code string) error
+
+	///////////////////////////////////////////////////////////////////////////////
+	// APITokenService is the service interface for managing the personal API tokens of the users.
+
+	// NewAPIToken creates a new API token for the user, the key of the token is only returned by this method.
+	NewAPIToken(ctx context.Context, userID uint, p APITokenPayload) (*APIToken, error)
+
+	// ListAPITokens lists the API tokens of the user.
+	ListAPITokens(ctx context.Context, userID uint) ([]*APIToken, error)
+
+	// DeleteAPIToken revokes the API token of the user.
+	DeleteAPIToken(ctx context.Context, userID, id uint) error
+
+	// GetAPITokenByKey returns the API token with the given key if it is not expired, and marks it as used.
+	GetAPITokenByKey(ctx context.Context, key string) (*APIToken, error)
+
+	///////////////////////////////////////////////////////////////////////////////
+	// CustomRoleService is the service interface for managing the custom roles.
+
+	// ApplyCustomRoleSpecs creates or updates the custom roles of the specs.
+	ApplyCustomRoleSpecs(ctx context.Context, specs []*CustomRoleSpec) error
+
+	// GetCustomRoleSpecs returns the specs of all the custom roles.
+	GetCustomRoleSpecs(ctx context.Context) ([]*CustomRoleSpec, error)
+
+	// GetCustomRoleSpec returns the spec of the custom role with the given name.
+	GetCustomRoleSpec(ctx context.Context, name string) (*CustomRoleSpec, error)
+
+	// DeleteCustomRole deletes the custom role with the given name, it must not be assigned to any user.
+	DeleteCustomRole(ctx context.Context, name string) error
+
 	///////////////////////////////////////////////////////////////////////////////
 	// PackService is the service interface for managing query packs.
 
@@ -200,6 +291,9 @@ type Service interface {
 	// for distributed queries but not saved should not be returned).
 	ListQueries(ctx context.Context, opt ListOptions) ([]*Query, error)
 	GetQuery(ctx context.Context, id uint) (*Query, error)
+	// GetQueryReport returns the rows of the latest snapshot results of the
+	// scheduled queries of the query, for the hosts the user can see.
+	GetQueryReport(ctx context.Context, id uint, opt QueryReportOptions) ([]*QueryReportRow, error)
 	NewQuery(ctx context.Context, p QueryPayload) (*Query, error)
 	ModifyQuery(ctx context.Context, id uint, p QueryPayload) (*Query, error)
 	DeleteQuery(ctx context.Context, name string) error
@@ -213,25 +307,47 @@ type Service interface {
 	// CampaignService defines the distributed query campaign related service methods
 
 	// NewDistributedQueryCampaignByNames creates a new distributed query campaign with the provided query (or the query
-	// referenced by ID) and host/label targets (specified by name).
+	// referenced by ID) and host/label targets (specified by name). See NewDistributedQueryCampaign for the
+	// options.
 	NewDistributedQueryCampaignByNames(
-		ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string,
+		ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string, opts CampaignOptions,
 	) (*DistributedQueryCampaign, error)
 
 	// NewDistributedQueryCampaign creates a new distributed query campaign with the provided query (or the query
-	// referenced by ID) and host/label targets
+	// referenced by ID) and host/label targets. If the CollectWindow option is positive, the campaign is
+	// asynchronous: it keeps targeting the hosts that did not respond yet for that duration, whether or not its
+	// results are streamed, and the results are retrieved later on with ListCampaignResults. If the ExportFormat
+	// option is set, the results are also exported as the hosts respond, see CampaignExport.
 	NewDistributedQueryCampaign(
-		ctx context.Context, queryString string, queryID *uint, targets HostTargets,
+		ctx context.Context, queryString string, queryID *uint, targets HostTargets, opts CampaignOptions,
 	) (*DistributedQueryCampaign, error)
 
 	// StreamCampaignResults streams updates with query results and expected host totals over the provided websocket.
 	// Note that the type signature is somewhat inconsistent due to this being a streaming API and not the typical
-	// go-kit RPC style.
-	StreamCampaignResults(ctx context.Context, conn *websocket.Conn, campaignID uint)
+	// go-kit RPC style. If aggregation is set, the rows are aggregated by the server and a running aggregate plus
+	// the status of each responding host are streamed instead of the rows.
+	StreamCampaignResults(ctx context.Context, conn *websocket.Conn, campaignID uint, aggregation *LiveQueryAggregation)
+
+	// ListCampaignResults returns the campaign and a page of its stored results. Only the user that started the
+	// campaign can list its results, as for StreamCampaignResults.
+	ListCampaignResults(ctx context.Context, campaignID uint, opt ListCampaignResultsOptions) (*DistributedQueryCampaign, []*DistributedQueryCampaignResult, error)
+
+	// ListCampaignHosts returns the asynchronous campaign and a page of its targeted hosts with their status. Only the
+	// user that started the campaign can list its hosts.
+	ListCampaignHosts(ctx context.Context, campaignID uint, opt ListCampaignHostsOptions) (*DistributedQueryCampaign, []*DistributedQueryCampaignHost, error)
+
+	// CampaignExport returns the download link of the exported results of the completed campaign. Only the user that
+	// started the campaign can download its results.
+	CampaignExport(ctx context.Context, campaignID uint) (*CampaignExport, error)
 
 	GetCampaignReader(ctx context.Context, campaign *DistributedQueryCampaign) (<-chan interface{}, context.CancelFunc, error)
 	CompleteCampaign(ctx context.Context, campaign *DistributedQueryCampaign) error
-	RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, hostIDs []uint, deadline time.Duration) ([]QueryCampaignResult, int)
+	// RunLiveQueryDeadline runs the queries against the hosts and returns the results received before the deadline,
+	// along with the number of hosts that responded. If aggregation is set, the aggregate of the rows and the status
+	// of each responding host are returned for each query instead of the rows.
+	RunLiveQueryDeadline(
+		ctx context.Context, queryIDs []uint, hostIDs []uint, deadline time.Duration, aggregation *LiveQueryAggregation,
+	) ([]QueryCampaignResult, int, error)
 
 	///////////////////////////////////////////////////////////////////////////////
 	// AgentOptionsService
@@ -423,7 +539,24 @@ type Service interface {
 	///////////////////////////////////////////////////////////////////////////////
 	// ActivitiesService
 
-	ListActivities(ctx context.Context, opt ListOptions) ([]*Activity, error)
+	ListActivities(ctx context.Context, opt ListActivitiesOptions) ([]*Activity, error)
+	// ListArchivedActivityMonths returns the months that have archived
+	// activities, oldest first.
+	ListArchivedActivityMonths(ctx context.Context) ([]string, error)
+	// ListArchivedActivities returns a page of the activities archived for the
+	// month, ordered by ID.
+	ListArchivedActivities(ctx context.Context, month string, opt ListOptions) ([]*Activity, error)
+
+	///////////////////////////////////////////////////////////////////////////////
+	// WebhookDeliveriesService
+
+	// ListWebhookDeliveries lists the deliveries of the webhooks configured in
+	// the webhook settings, with the result of their last attempt.
+	ListWebhookDeliveries(ctx context.Context, opts WebhookDeliveryListOptions) ([]*WebhookDelivery, error)
+
+	// RedeliverWebhookDelivery attempts to deliver an existing webhook delivery
+	// again, e.g. one that was moved to the dead-letter state.
+	RedeliverWebhookDelivery(ctx context.Context, id uint) (*WebhookDelivery, error)
 
 	///////////////////////////////////////////////////////////////////////////////
 	// UserRolesService
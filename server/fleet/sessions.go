@@ -1,6 +1,7 @@
 package fleet
 
 import (
+	"context"
 	"time"
 )
 
@@ -17,6 +18,9 @@ type Auth interface {
 	// isn't a defined spec for this, so the return value is in a best-effort
 	// basis
 	UserDisplayName() string
+	// UserGroups returns the values of the attribute (SAML) or claim (OIDC)
+	// identified by name in the SSO response, typically the groups of the user.
+	UserGroups(name string) []string
 	// RequestID returns the request id associated with this SSO session
 	RequestID() string
 }
@@ -49,3 +53,23 @@ type Session struct {
 func (s Session) AuthzType() string {
 	return "session"
 }
+
+// LoginAttemptsStore tracks the failed password logins of the accounts, to
+// lock the accounts targeted by brute-force attacks. The accounts are
+// identified by the email used to log in, so that the logins with an email
+// that doesn't match any user are tracked the same way.
+type LoginAttemptsStore interface {
+	// IncrFailedLogins increments the number of failed logins of the account
+	// and returns the new count. The count is reset window after the first
+	// failed login.
+	IncrFailedLogins(ctx context.Context, email string, window time.Duration) (int, error)
+	// ResetFailedLogins resets the number of failed logins of the account.
+	ResetFailedLogins(ctx context.Context, email string) error
+	// LockAccount locks the account for the given duration.
+	LockAccount(ctx context.Context, email string, duration time.Duration) error
+	// IsAccountLocked returns true if the account is currently locked.
+	IsAccountLocked(ctx context.Context, email string) (bool, error)
+	// UnlockAccount unlocks the account and resets its number of failed
+	// logins.
+	UnlockAccount(ctx context.Context, email string) error
+}
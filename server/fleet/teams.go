@@ -126,6 +126,86 @@ type TeamConfig struct {
 
 type TeamWebhookSettings struct {
 	FailingPoliciesWebhook FailingPoliciesWebhookSettings `json:"failing_policies_webhook"`
+	// HostStatusWebhook is the host status webhook of the team, evaluated
+	// against the hosts of the team only. It is nil if it was never set.
+	HostStatusWebhook *HostStatusWebhookSettings `json:"host_status_webhook,omitempty"`
+	// VulnerabilitiesWebhook is the vulnerabilities webhook of the team, sent
+	// with the affected hosts of the team only. It is nil if it was never set.
+	VulnerabilitiesWebhook *VulnerabilitiesWebhookSettings `json:"vulnerabilities_webhook,omitempty"`
+}
+
+// HostStatusWebhookEnabled returns true if the team's host status webhook is
+// set and enabled.
+func (s TeamWebhookSettings) HostStatusWebhookEnabled() bool {
+	return s.HostStatusWebhook != nil && s.HostStatusWebhook.Enable
+}
+
+// VulnerabilitiesWebhookEnabled returns true if the team's vulnerabilities
+// webhook is set and enabled.
+func (s TeamWebhookSettings) VulnerabilitiesWebhookEnabled() bool {
+	return s.VulnerabilitiesWebhook != nil && s.VulnerabilitiesWebhook.Enable
+}
+
+// Apply applies the provided settings to the team's webhook settings, as done
+// when modifying a team. The failing policies webhook is always replaced,
+// while the host status and vulnerabilities webhooks are only replaced if
+// they are set in the provided settings. Masked secrets are kept unchanged.
+func (s *TeamWebhookSettings) Apply(settings TeamWebhookSettings) {
+	settings.RestoreMaskedSecrets(*s)
+	s.FailingPoliciesWebhook = settings.FailingPoliciesWebhook
+	if settings.HostStatusWebhook != nil {
+		s.HostStatusWebhook = settings.HostStatusWebhook
+	}
+	if settings.VulnerabilitiesWebhook != nil {
+		s.VulnerabilitiesWebhook = settings.VulnerabilitiesWebhook
+	}
+}
+
+// SecretFor returns the secret configured for the team's webhook of the
+// specified type (one of the WebhookType* constants).
+func (s TeamWebhookSettings) SecretFor(webhookType string) string {
+	switch webhookType {
+	case WebhookTypeFailingPolicies:
+		return s.FailingPoliciesWebhook.Secret
+	case WebhookTypeHostStatus:
+		if s.HostStatusWebhook != nil {
+			return s.HostStatusWebhook.Secret
+		}
+	case WebhookTypeVulnerabilities:
+		if s.VulnerabilitiesWebhook != nil {
+			return s.VulnerabilitiesWebhook.Secret
+		}
+	}
+	return ""
+}
+
+// MaskSecrets replaces the configured webhook secrets with MaskedPassword.
+// The host status and vulnerabilities webhooks are copied before being
+// masked, so that the original settings are left untouched.
+func (s *TeamWebhookSettings) MaskSecrets() {
+	maskSecret(&s.FailingPoliciesWebhook.Secret)
+	if s.HostStatusWebhook != nil {
+		hs := *s.HostStatusWebhook
+		maskSecret(&hs.Secret)
+		s.HostStatusWebhook = &hs
+	}
+	if s.VulnerabilitiesWebhook != nil {
+		vs := *s.VulnerabilitiesWebhook
+		maskSecret(&vs.Secret)
+		s.VulnerabilitiesWebhook = &vs
+	}
+}
+
+// RestoreMaskedSecrets replaces the webhook secrets that are set to
+// MaskedPassword with the corresponding secret of the stored settings.
+func (s *TeamWebhookSettings) RestoreMaskedSecrets(stored TeamWebhookSettings) {
+	restoreMaskedSecret(&s.FailingPoliciesWebhook.Secret, stored.FailingPoliciesWebhook.Secret)
+	if s.HostStatusWebhook != nil {
+		restoreMaskedSecret(&s.HostStatusWebhook.Secret, stored.SecretFor(WebhookTypeHostStatus))
+	}
+	if s.VulnerabilitiesWebhook != nil {
+		restoreMaskedSecret(&s.VulnerabilitiesWebhook.Secret, stored.SecretFor(WebhookTypeVulnerabilities))
+	}
 }
 
 // Scan implements the sql.Scanner interface
@@ -208,12 +288,18 @@ func ValidGlobalRoles() []string {
 // ValidateRole returns nil if the global and team roles combination is a valid
 // one within fleet, or a fleet Error otherwise.
 func ValidateRole(globalRole *string, teamUsers []UserTeam) error {
+	return ValidateRoleWithCustomRoles(globalRole, teamUsers, nil)
+}
+
+// ValidateRoleWithCustomRoles is like ValidateRole, but the names of the
+// existing custom roles are also accepted as global and team roles.
+func ValidateRoleWithCustomRoles(globalRole *string, teamUsers []UserTeam, customRoles map[string]bool) error {
 	if globalRole == nil || *globalRole == "" {
 		if len(teamUsers) == 0 {
 			return NewError(ErrNoRoleNeeded, "either global role or team role needs to be defined")
 		}
 		for _, t := range teamUsers {
-			if !ValidTeamRole(t.Role) {
+			if !ValidTeamRole(t.Role) && !customRoles[t.Role] {
 				return NewError(ErrNoRoleNeeded, "Team roles can be observer or maintainer")
 			}
 		}
@@ -224,7 +310,7 @@ func ValidateRole(globalRole *string, teamUsers []UserTeam) error {
 		return NewError(ErrNoRoleNeeded, "Cannot specify both Global Role and Team Roles")
 	}
 
-	if !ValidGlobalRole(*globalRole) {
+	if !ValidGlobalRole(*globalRole) && !customRoles[*globalRole] {
 		return NewError(ErrNoRoleNeeded, "GlobalRole role can only be admin, observer, or maintainer.")
 	}
 
@@ -252,4 +338,7 @@ type TeamSpec struct {
 	Name         string           `json:"name"`
 	AgentOptions *json.RawMessage `json:"agent_options"`
 	Secrets      []EnrollSecret   `json:"secrets"`
+	// WebhookSettings are the webhook settings of the team, left unchanged if
+	// not provided.
+	WebhookSettings *TeamWebhookSettings `json:"webhook_settings"`
 }
@@ -0,0 +1,45 @@
+package fleet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamWebhookSettingsApply(t *testing.T) {
+	settings := TeamWebhookSettings{
+		FailingPoliciesWebhook: FailingPoliciesWebhookSettings{Enable: true, Secret: "policies"},
+		HostStatusWebhook:      &HostStatusWebhookSettings{Enable: true, Secret: "host"},
+	}
+	require.True(t, settings.HostStatusWebhookEnabled())
+	require.False(t, settings.VulnerabilitiesWebhookEnabled())
+	require.Equal(t, "host", settings.SecretFor(WebhookTypeHostStatus))
+	require.Empty(t, settings.SecretFor(WebhookTypeVulnerabilities))
+
+	masked := settings
+	masked.MaskSecrets()
+	require.Equal(t, MaskedPassword, masked.FailingPoliciesWebhook.Secret)
+	require.Equal(t, MaskedPassword, masked.HostStatusWebhook.Secret)
+	// the original settings are left untouched
+	require.Equal(t, "host", settings.HostStatusWebhook.Secret)
+
+	// the host status webhook is not provided, it is kept as is
+	settings.Apply(TeamWebhookSettings{
+		FailingPoliciesWebhook: FailingPoliciesWebhookSettings{Secret: MaskedPassword},
+		VulnerabilitiesWebhook: &VulnerabilitiesWebhookSettings{Enable: true, Secret: "vulns"},
+	})
+	require.False(t, settings.FailingPoliciesWebhook.Enable)
+	require.Equal(t, "policies", settings.FailingPoliciesWebhook.Secret)
+	require.True(t, settings.HostStatusWebhookEnabled())
+	require.Equal(t, "host", settings.HostStatusWebhook.Secret)
+	require.True(t, settings.VulnerabilitiesWebhookEnabled())
+	require.Equal(t, "vulns", settings.VulnerabilitiesWebhook.Secret)
+
+	// the host status webhook is applied back with its masked secret
+	settings.Apply(TeamWebhookSettings{
+		HostStatusWebhook: &HostStatusWebhookSettings{Secret: MaskedPassword},
+	})
+	require.False(t, settings.HostStatusWebhookEnabled())
+	require.Equal(t, "host", settings.HostStatusWebhook.Secret)
+	require.Empty(t, settings.FailingPoliciesWebhook.Secret)
+}
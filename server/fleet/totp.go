@@ -0,0 +1,79 @@
+package fleet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPLoginChallengeTTL is the duration during which a two-factor login
+	// challenge can be completed after the password was verified.
+	TOTPLoginChallengeTTL = 5 * time.Minute
+	// TOTPLoginChallengeMaxAttempts is the number of invalid codes after which
+	// a two-factor login challenge is rejected and the user must log in with
+	// their password again.
+	TOTPLoginChallengeMaxAttempts = 5
+	// TOTPRecoveryCodesCount is the number of recovery codes generated when a
+	// user enrolls in two-factor authentication.
+	TOTPRecoveryCodesCount = 10
+)
+
+// UserTOTP is the time-based one-time password (TOTP) configuration of a
+// user. It is created by the enrollment and only enabled once the user proved
+// it was added to their authenticator app by verifying a code.
+type UserTOTP struct {
+	UpdateCreateTimestamps
+	UserID  uint   `db:"user_id"`
+	Secret  string `db:"secret"`
+	Enabled bool   `db:"enabled"`
+	// LastStep is the time step of the last code used, codes can't be used
+	// more than once.
+	LastStep int64 `db:"last_step"`
+}
+
+// TOTPEnrollment is returned when a user enrolls in two-factor
+// authentication, to be added to an authenticator app.
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TOTPLoginChallenge is created when a user with two-factor authentication
+// logs in with a valid password. The session is only issued once a valid code
+// is provided for the challenge.
+type TOTPLoginChallenge struct {
+	Token     string    `db:"token"`
+	UserID    uint      `db:"user_id"`
+	Attempts  int       `db:"attempts"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// IsExpired returns true if the challenge can't be completed anymore.
+func (c *TOTPLoginChallenge) IsExpired(now time.Time) bool {
+	return now.After(c.CreatedAt.Add(TOTPLoginChallengeTTL)) || c.Attempts >= TOTPLoginChallengeMaxAttempts
+}
+
+// HashTOTPRecoveryCode returns the hash of a recovery code, as stored in the
+// database. Dashes and spaces are ignored so that the codes can be typed as
+// displayed.
+func HashTOTPRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// TOTPRequiredError is returned by the password login when the user must
+// complete a two-factor authentication challenge before a session is issued.
+type TOTPRequiredError struct {
+	// Token identifies the challenge to complete.
+	Token string
+	// EnrollmentRequired is true if two-factor authentication is enforced and
+	// the user must enroll before completing the challenge.
+	EnrollmentRequired bool
+}
+
+func (e *TOTPRequiredError) Error() string {
+	return "two-factor authentication required"
+}
@@ -27,6 +27,9 @@ type User struct {
 	SSOEnabled bool    `json:"sso_enabled" db:"sso_enabled"`
 	GlobalRole *string `json:"global_role" db:"global_role"`
 	APIOnly    bool    `json:"api_only" db:"api_only"`
+	// Disabled if true, the user can't log in nor use the API. Users are
+	// disabled when they are deactivated by SCIM provisioning.
+	Disabled bool `json:"disabled" db:"disabled"`
 
 	// Teams is the teams this user has roles in. For users with a global role, Teams is expected to be empty.
 	Teams []UserTeam `json:"teams"`
@@ -292,6 +295,22 @@ func (u *User) ValidatePassword(password string) error {
 	return bcrypt.CompareHashAndPassword(u.Password, saltAndPass)
 }
 
+// PasswordHistoryEntry is a password that was set for a user. It is used to
+// prevent the reuse of the previous passwords and to expire the current one.
+type PasswordHistoryEntry struct {
+	UserID    uint      `db:"user_id"`
+	Password  []byte    `db:"password"`
+	Salt      string    `db:"salt"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// MatchesPassword returns whether the plaintext password is the password of
+// the entry.
+func (e *PasswordHistoryEntry) MatchesPassword(password string) bool {
+	u := User{Password: e.Password, Salt: e.Salt}
+	return u.ValidatePassword(password) == nil
+}
+
 func (u *User) SetPassword(plaintext string, keySize, cost int) error {
 	if err := ValidatePasswordRequirements(plaintext); err != nil {
 		return err
@@ -0,0 +1,89 @@
+package fleet
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of webhooks sent via the webhook settings, used to identify the
+// webhook that generated a delivery.
+const (
+	WebhookTypeHostStatus      = "host_status"
+	WebhookTypeFailingPolicies = "failing_policies"
+	WebhookTypeVulnerabilities = "vulnerabilities"
+)
+
+// WebhookDeliveryStatus is the status of a webhook delivery.
+type WebhookDeliveryStatus string
+
+// The possible statuses for a webhook delivery
+//
+//	Pending ───► Success
+//	  │  ▲
+//	  ▼  │
+//	 Failed ───► DeadLetter
+const (
+	// WebhookDeliveryPending is the status of a delivery that has not been
+	// attempted yet (or that was requested to be redelivered).
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySuccess is the status of a delivery that was accepted by
+	// the receiver.
+	WebhookDeliverySuccess WebhookDeliveryStatus = "success"
+	// WebhookDeliveryFailed is the status of a delivery for which the last
+	// attempt failed, but that will be retried.
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+	// WebhookDeliveryDeadLetter is the status of a delivery that permanently
+	// failed, after all retries were exhausted. It can only be delivered again
+	// by an explicit redeliver request.
+	WebhookDeliveryDeadLetter WebhookDeliveryStatus = "dead_letter"
+)
+
+// WebhookDeliveryRetention is how long the webhook deliveries are kept before
+// being deleted.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+// WebhookDelivery is the record of the delivery of a webhook request, along
+// with the result of its last attempt.
+type WebhookDelivery struct {
+	UpdateCreateTimestamps
+	ID uint `json:"id" db:"id"`
+	// WebhookType is the type of webhook that generated the delivery, one of
+	// the WebhookType* constants.
+	WebhookType string `json:"webhook_type" db:"webhook_type"`
+	// TeamID is the team of the webhook settings that generated the delivery,
+	// nil for global webhook settings.
+	TeamID *uint `json:"team_id" db:"team_id"`
+	// URL is the destination URL of the webhook.
+	URL string `json:"url" db:"url"`
+	// Payload is the JSON body sent to the destination.
+	Payload json.RawMessage `json:"payload" db:"payload"`
+	// Status is the current status of the delivery.
+	Status WebhookDeliveryStatus `json:"status" db:"status"`
+	// Attempts is the number of delivery attempts made so far.
+	Attempts int `json:"attempts" db:"attempts"`
+	// LastStatusCode is the HTTP status code received on the last attempt,
+	// nil if no response was received.
+	LastStatusCode *int `json:"last_status_code" db:"last_status_code"`
+	// LastLatencyMs is the duration in milliseconds of the last attempt.
+	LastLatencyMs *int64 `json:"last_latency_ms" db:"last_latency_ms"`
+	// LastAttemptAt is the timestamp of the last attempt.
+	LastAttemptAt *time.Time `json:"last_attempt_at" db:"last_attempt_at"`
+	// Error is the error of the last attempt, if it failed.
+	Error string `json:"error" db:"error"`
+}
+
+// AuthzType implements authz.AuthzTyper.
+func (d *WebhookDelivery) AuthzType() string {
+	return "webhook_delivery"
+}
+
+// WebhookDeliveryListOptions are the options available to list webhook
+// deliveries.
+type WebhookDeliveryListOptions struct {
+	ListOptions
+
+	// Status filters the deliveries by status.
+	Status WebhookDeliveryStatus `query:"status,optional"`
+	// WebhookType filters the deliveries by webhook type.
+	WebhookType string `query:"webhook_type,optional"`
+}
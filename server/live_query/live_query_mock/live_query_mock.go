@@ -2,6 +2,7 @@ package live_query_mock
 
 import (
 	"testing"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/stretchr/testify/mock"
@@ -28,6 +29,12 @@ func (m *MockLiveQuery) RunQuery(name, sql string, hostIDs []uint) error {
 	return args.Error(0)
 }
 
+// RunQueryWithExpiration mocks the live query store RunQueryWithExpiration method.
+func (m *MockLiveQuery) RunQueryWithExpiration(name, sql string, hostIDs []uint, expiration time.Duration) error {
+	args := m.Called(name, sql, hostIDs, expiration)
+	return args.Error(0)
+}
+
 // StopQuery mocks the live query store StopQuery method.
 func (m *MockLiveQuery) StopQuery(name string) error {
 	args := m.Called(name)
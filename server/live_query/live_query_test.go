@@ -2,6 +2,7 @@ package live_query
 
 import (
 	"testing"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/datastore/redis"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -16,6 +17,7 @@ var testFunctions = [...]func(*testing.T, fleet.LiveQueryStore){
 	testLiveQueryStopQuery,
 	testLiveQueryExpiredQuery,
 	testLiveQueryOnlyExpired,
+	testLiveQueryWithExpiration,
 }
 
 func testLiveQuery(t *testing.T, store fleet.LiveQueryStore) {
@@ -135,3 +137,44 @@ func testLiveQueryOnlyExpired(t *testing.T, store fleet.LiveQueryStore) {
 	require.NoError(t, err)
 	require.Len(t, activeNames, 0)
 }
+
+func testLiveQueryWithExpiration(t *testing.T, store fleet.LiveQueryStore) {
+	oldModulo := cleanupExpiredQueriesModulo
+	cleanupExpiredQueriesModulo = 1 // run the cleanup each time
+	t.Cleanup(func() { cleanupExpiredQueriesModulo = oldModulo })
+
+	require.Error(t, store.RunQueryWithExpiration("test", "select 1", []uint{1}, time.Millisecond))
+	require.NoError(t, store.RunQueryWithExpiration("test", "select 1", []uint{1, 2}, time.Second))
+	require.NoError(t, store.RunQueryWithExpiration("test2", "select 2", []uint{2}, time.Hour))
+
+	pool := store.(*redisLiveQuery).pool
+	conn := redis.ConfigureDoer(pool, pool.Get())
+	defer conn.Close()
+	targetKey, sqlKey := generateKeys("test2")
+	for _, key := range []string{targetKey, sqlKey} {
+		ttl, err := redigo.Int(conn.Do("TTL", key))
+		require.NoError(t, err)
+		require.True(t, ttl > 0 && ttl <= 3600, ttl)
+	}
+
+	queries, err := store.QueriesForHost(1)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"test": "select 1"}, queries)
+	queries, err = store.QueriesForHost(2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"test": "select 1", "test2": "select 2"}, queries)
+
+	// the hosts no longer receive the query once it expired, and it is removed
+	// from the active set
+	time.Sleep(1100 * time.Millisecond)
+	queries, err = store.QueriesForHost(1)
+	require.NoError(t, err)
+	assert.Len(t, queries, 0)
+	queries, err = store.QueriesForHost(2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"test2": "select 2"}, queries)
+
+	activeNames, err := redigo.Strings(conn.Do("SMEMBERS", activeQueriesKey))
+	require.NoError(t, err)
+	require.Equal(t, []string{"test2"}, activeNames)
+}
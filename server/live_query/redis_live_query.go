@@ -38,6 +38,13 @@
 // are always stored on the same node (as they hash to the same cluster slot).
 // See https://redis.io/topics/cluster-spec#keys-hash-tags for details.
 //
+// Live queries run asynchronously (see fleet.DistributedQueryCampaign's
+// CollectUntil) keep targeting the hosts for their collection window, so there
+// can be many long-lived live queries in the active set. To keep the host
+// checkins cheap in that case, only the bitfields are read for all the active
+// queries, the SQL is only read for the queries that target the host, and the
+// expired queries are only looked for when cleaning up the active set.
+//
 // It is a noted downside that the active live queries set will necessarily
 // live on a single node in cluster mode (a "hot key"), and that node will see
 // increased activity due to that. Should that become a significant problem, an
@@ -102,12 +109,21 @@ func extractTargetKeyName(key string) string {
 // duration of the query or its TTL. Note that hostIDs *must* be sorted
 // in ascending order.
 func (r *redisLiveQuery) RunQuery(name, sql string, hostIDs []uint) error {
+	return r.RunQueryWithExpiration(name, sql, hostIDs, queryExpiration)
+}
+
+// RunQueryWithExpiration is like RunQuery, but the live query information
+// expires after the provided duration instead of the default TTL.
+func (r *redisLiveQuery) RunQueryWithExpiration(name, sql string, hostIDs []uint, expiration time.Duration) error {
 	if len(hostIDs) == 0 {
 		return errors.New("no hosts targeted")
 	}
+	if expiration < time.Second {
+		return errors.New("expiration must be at least one second")
+	}
 
 	// store the sql and targeted hosts information
-	if err := r.storeQueryInfo(name, sql, hostIDs); err != nil {
+	if err := r.storeQueryInfo(name, sql, hostIDs, expiration); err != nil {
 		return fmt.Errorf("store query info: %w", err)
 	}
 
@@ -149,33 +165,33 @@ func (r *redisLiveQuery) QueriesForHost(hostID uint) (map[string]string, error)
 		names[i] = tkey
 	}
 
+	// a certain percentage of the time so that we don't overwhelm redis with a
+	// bunch of similar deletion commands at the same time, look for the expired
+	// queries to clean them up.
+	cleanup := time.Now().UnixNano()%cleanupExpiredQueriesModulo == 0
+
 	keysBySlot := redis.SplitKeysBySlot(r.pool, names...)
 	queries := make(map[string]string)
 	expired := make(map[string]struct{})
 	for _, qkeys := range keysBySlot {
-		if err := r.collectBatchQueriesForHost(hostID, qkeys, queries, expired); err != nil {
+		if err := r.collectBatchQueriesForHost(hostID, qkeys, cleanup, queries, expired); err != nil {
 			return nil, err
 		}
 	}
 
 	if len(expired) > 0 {
-		// a certain percentage of the time so that we don't overwhelm redis with a
-		// bunch of similar deletion commands at the same time, clean up the
-		// expired queries.
-		if time.Now().UnixNano()%cleanupExpiredQueriesModulo == 0 {
-			names := make([]string, 0, len(expired))
-			for k := range expired {
-				names = append(names, k)
-			}
-			// ignore error, best effort removal
-			_ = r.removeQueryNames(names...)
+		names := make([]string, 0, len(expired))
+		for k := range expired {
+			names = append(names, k)
 		}
+		// ignore error, best effort removal
+		_ = r.removeQueryNames(names...)
 	}
 
 	return queries, nil
 }
 
-func (r *redisLiveQuery) collectBatchQueriesForHost(hostID uint, queryKeys []string, queriesByHost map[string]string, expiredQueries map[string]struct{}) error {
+func (r *redisLiveQuery) collectBatchQueriesForHost(hostID uint, queryKeys []string, checkExpired bool, queriesByHost map[string]string, expiredQueries map[string]struct{}) error {
 	conn := redis.ReadOnlyConn(r.pool, r.pool.Get())
 	defer conn.Close()
 
@@ -186,12 +202,13 @@ func (r *redisLiveQuery) collectBatchQueriesForHost(hostID uint, queryKeys []str
 			return fmt.Errorf("getbit query targets: %w", err)
 		}
 
-		// Additionally get SQL even though we don't yet know whether this query
-		// is targeted to the host. This allows us to avoid an additional
-		// roundtrip to the Redis server and likely has little cost due to the
-		// small number of queries and limited size of SQL
-		if err := conn.Send("GET", sqlKeyPrefix+key); err != nil {
-			return fmt.Errorf("get query sql: %w", err)
+		// the result of GETBIT will not fail if the key does not exist, it will
+		// just return 0, so it can't be used to detect if the livequery still
+		// exists.
+		if checkExpired {
+			if err := conn.Send("EXISTS", key); err != nil {
+				return fmt.Errorf("check query exists: %w", err)
+			}
 		}
 	}
 
@@ -200,38 +217,63 @@ func (r *redisLiveQuery) collectBatchQueriesForHost(hostID uint, queryKeys []str
 		return fmt.Errorf("flush pipeline: %w", err)
 	}
 
-	// Receive target and SQL in order of pipelined calls.
+	// Receive the targets (and existence) in order of pipelined calls.
+	var targetedKeys []string
 	for _, key := range queryKeys {
-		name := extractTargetKeyName(key)
-
-		// the result of GETBIT will not fail if the key does not exist, it will
-		// just return 0, so it can't be used to detect if the livequery still
-		// exists.
 		targeted, err := redigo.Int(conn.Receive())
 		if err != nil {
 			return fmt.Errorf("receive target: %w", err)
 		}
 
-		// Be sure to read SQL even if we are not going to include this query.
-		// Otherwise we will read an incorrect number of returned results from
-		// the pipeline.
+		if checkExpired {
+			// Be sure to read the existence even if the query is targeted.
+			// Otherwise we will read an incorrect number of returned results
+			// from the pipeline.
+			exists, err := redigo.Bool(conn.Receive())
+			if err != nil {
+				return fmt.Errorf("receive exists: %w", err)
+			}
+			if !exists {
+				// It is possible the livequery key has expired but was still in
+				// the set - handle this gracefully by collecting the keys to
+				// remove them from the set and keep going.
+				expiredQueries[extractTargetKeyName(key)] = struct{}{}
+				continue
+			}
+		}
+
+		if targeted == 0 {
+			// Host not targeted with this query
+			continue
+		}
+		targetedKeys = append(targetedKeys, key)
+	}
+
+	if len(targetedKeys) == 0 {
+		return nil
+	}
+
+	// Only get the SQL of the queries that target this host, as there may be
+	// many long-lived queries in the active set.
+	for _, key := range targetedKeys {
+		if err := conn.Send("GET", sqlKeyPrefix+key); err != nil {
+			return fmt.Errorf("get query sql: %w", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("flush pipeline: %w", err)
+	}
+	for _, key := range targetedKeys {
+		name := extractTargetKeyName(key)
 		sql, err := redigo.String(conn.Receive())
 		if err != nil {
 			if err != redigo.ErrNil {
 				return fmt.Errorf("receive sql: %w", err)
 			}
-
-			// It is possible the livequery key has expired but was still in the set
-			// - handle this gracefully by collecting the keys to remove them from
-			// the set and keep going.
+			// the query expired since its bitfield was read
 			expiredQueries[name] = struct{}{}
 			continue
 		}
-
-		if targeted == 0 {
-			// Host not targeted with this query
-			continue
-		}
 		queriesByHost[name] = sql
 	}
 	return nil
@@ -257,7 +299,7 @@ func (r *redisLiveQuery) QueryCompletedByHost(name string, hostID uint) error {
 	return nil
 }
 
-func (r *redisLiveQuery) storeQueryInfo(name, sql string, hostIDs []uint) error {
+func (r *redisLiveQuery) storeQueryInfo(name, sql string, hostIDs []uint, expiration time.Duration) error {
 	conn := r.pool.Get()
 	defer conn.Close()
 
@@ -268,11 +310,11 @@ func (r *redisLiveQuery) storeQueryInfo(name, sql string, hostIDs []uint) error
 
 	// Ensure to set SQL first or else we can end up in a weird state in which a
 	// client reads that the query exists but cannot look up the SQL.
-	err := conn.Send("SET", sqlKey, sql, "EX", queryExpiration.Seconds())
+	err := conn.Send("SET", sqlKey, sql, "EX", int(expiration.Seconds()))
 	if err != nil {
 		return fmt.Errorf("set sql: %w", err)
 	}
-	_, err = conn.Do("SET", targetKey, targets, "EX", queryExpiration.Seconds())
+	_, err = conn.Do("SET", targetKey, targets, "EX", int(expiration.Seconds()))
 	if err != nil {
 		return fmt.Errorf("set targets: %w", err)
 	}
@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// httpLogMaxRetries is the maximum number of times a request of the HTTP-based
+// log writers (Splunk and Elasticsearch) is retried.
+const httpLogMaxRetries = 5
+
+// batchLimits defines the limits of the batches of records sent by a log
+// writer.
+type batchLimits struct {
+	// maxRecords is the maximum number of records in a batch.
+	maxRecords int
+	// maxRecordSize is the maximum size of an encoded record, larger records
+	// are dropped.
+	maxRecordSize int
+	// maxBatchSize is the maximum size of the encoded records in a batch.
+	maxBatchSize int
+}
+
+// writeBatches encodes each log with encode and calls flush with batches of
+// encoded records that respect the limits.
+//
+// Similar to the firehose writer, logs that are too big once encoded are
+// dropped (and logged with the name of the writer), as are logs that cannot
+// be encoded.
+func writeBatches(
+	logger log.Logger,
+	name string,
+	logs []json.RawMessage,
+	limits batchLimits,
+	encode func(json.RawMessage) ([]byte, error),
+	flush func(records [][]byte) error,
+) error {
+	var records [][]byte
+	totalBytes := 0
+	for _, log := range logs {
+		record, err := encode(log)
+		if err != nil {
+			level.Info(logger).Log(
+				"msg", fmt.Sprintf("dropping log that cannot be encoded for %s", name),
+				"err", err,
+				"log", logPrefix(log),
+			)
+			continue
+		}
+
+		// The beginning bytes of the log should help the Fleet admin diagnose
+		// the query generating huge results.
+		if len(record) > limits.maxRecordSize {
+			level.Info(logger).Log(
+				"msg", fmt.Sprintf("dropping log over %d bytes %s limit", limits.maxRecordSize, name),
+				"size", len(record),
+				"log", logPrefix(log),
+			)
+			continue
+		}
+
+		// If adding this record will exceed the limit on number of records in
+		// the batch, or the limit on total size of the records in the batch, we
+		// need to push this batch before adding any more.
+		if len(records) >= limits.maxRecords || totalBytes+len(record) > limits.maxBatchSize {
+			if err := flush(records); err != nil {
+				return err
+			}
+			totalBytes = 0
+			records = nil
+		}
+
+		records = append(records, record)
+		totalBytes += len(record)
+	}
+
+	// Push the final batch
+	if len(records) > 0 {
+		return flush(records)
+	}
+	return nil
+}
+
+func logPrefix(log json.RawMessage) string {
+	if len(log) > 100 {
+		return string(log[:100]) + "..."
+	}
+	return string(log)
+}
+
+// httpLogStatusError is returned by doHTTPLogRequest when the server responds
+// with an unexpected status code.
+type httpLogStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpLogStatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// doHTTPLogRequest executes the request created by newRequest and returns the
+// body of the response. The request is retried with exponential backoff on
+// temporary network errors, 429 and 5xx status codes, up to httpLogMaxRetries
+// times.
+func doHTTPLogRequest(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) ([]byte, error) {
+	for try := 0; ; try++ {
+		if try > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(100 * time.Millisecond * time.Duration(math.Pow(2.0, float64(try)))):
+			}
+		}
+
+		body, retryable, err := doHTTPLogRequestOnce(client, newRequest)
+		if err == nil {
+			return body, nil
+		}
+		if !retryable || try >= httpLogMaxRetries {
+			return nil, err
+		}
+	}
+}
+
+func doHTTPLogRequestOnce(client *http.Client, newRequest func() (*http.Request, error)) (body []byte, retryable bool, err error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, false, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary()) {
+			return nil, true, err
+		}
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, fmt.Errorf("read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return nil, retryable, &httpLogStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, false, nil
+}
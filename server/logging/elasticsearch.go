@@ -0,0 +1,219 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// Elasticsearch rejects requests larger than http.max_content_length (100MB
+	// by default), but recommends bulk requests of a few MBs. See
+	// https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
+	elasticsearchMaxDocsInBatch = 5000
+	elasticsearchMaxSizeOfDoc   = 1000 * 1000      // 1MB
+	elasticsearchMaxSizeOfBatch = 10 * 1000 * 1000 // 10MB
+)
+
+type elasticsearchLogWriter struct {
+	client   *http.Client
+	url      string
+	username string
+	password string
+	apiKey   string
+	index    string
+	logger   log.Logger
+}
+
+// NewElasticsearchLogWriter returns a log writer that indexes the logs in the
+// index of the Elasticsearch cluster at baseURL, using the _bulk API. If apiKey
+// is set, it is used to authenticate, otherwise basic authentication is used if
+// username is set.
+func NewElasticsearchLogWriter(baseURL, username, password, apiKey, index string, timeout time.Duration, logger log.Logger) (*elasticsearchLogWriter, error) {
+	if baseURL == "" {
+		return nil, errors.New("elasticsearch url missing")
+	}
+	if index == "" {
+		return nil, errors.New("elasticsearch index missing")
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	e := &elasticsearchLogWriter{
+		client:   fleethttp.NewClient(fleethttp.WithTimeout(timeout)),
+		url:      baseURL,
+		username: username,
+		password: password,
+		apiKey:   apiKey,
+		index:    index,
+		logger:   logger,
+	}
+	if err := e.checkCluster(); err != nil {
+		return nil, fmt.Errorf("create Elasticsearch writer: %w", err)
+	}
+	return e, nil
+}
+
+func (e *elasticsearchLogWriter) checkCluster() error {
+	_, err := doHTTPLogRequest(context.Background(), e.client, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, e.url+"/", nil)
+		if err != nil {
+			return nil, err
+		}
+		e.setAuth(req)
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("elasticsearch cluster check: %w", err)
+	}
+	return nil
+}
+
+func (e *elasticsearchLogWriter) setAuth(req *http.Request) {
+	switch {
+	case e.apiKey != "":
+		req.Header.Set("Authorization", "ApiKey "+e.apiKey)
+	case e.username != "":
+		req.SetBasicAuth(e.username, e.password)
+	}
+}
+
+func (e *elasticsearchLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	limits := batchLimits{
+		maxRecords:    elasticsearchMaxDocsInBatch,
+		maxRecordSize: elasticsearchMaxSizeOfDoc,
+		maxBatchSize:  elasticsearchMaxSizeOfBatch,
+	}
+	return writeBatches(e.logger, "Elasticsearch", logs, limits, e.encode, func(records [][]byte) error {
+		if err := e.bulk(ctx, records); err != nil {
+			return ctxerr.Wrap(ctx, err, "bulk index logs in Elasticsearch")
+		}
+		return nil
+	})
+}
+
+// encode returns the bulk request lines to index the log: the action and the
+// document, each terminated by a newline.
+func (e *elasticsearchLogWriter) encode(log json.RawMessage) ([]byte, error) {
+	action, err := json.Marshal(map[string]map[string]string{
+		"create": {"_index": e.index},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the bulk API uses newline delimited JSON, so the document must fit on a
+	// single line.
+	var buf bytes.Buffer
+	buf.Write(action)
+	buf.WriteByte('\n')
+	if err := json.Compact(&buf, log); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+type elasticsearchBulkResponse struct {
+	Errors bool                                     `json:"errors"`
+	Items  []map[string]elasticsearchBulkItemResult `json:"items"`
+}
+
+type elasticsearchBulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// bulk sends the records with the _bulk API. The API responds with 200 even
+// if some of the documents failed, so the status of each item is checked: the
+// items rejected because the cluster is overloaded (429) are retried, and the
+// items rejected for any other reason (e.g. a mapping conflict) are dropped,
+// as retrying them would fail the same way.
+func (e *elasticsearchLogWriter) bulk(ctx context.Context, records [][]byte) error {
+	for try := 0; ; try++ {
+		if try > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(100 * time.Millisecond * time.Duration(math.Pow(2.0, float64(try)))):
+			}
+		}
+
+		resp, err := e.send(ctx, bytes.Join(records, nil))
+		if err != nil {
+			return err
+		}
+		if !resp.Errors {
+			return nil
+		}
+		if len(resp.Items) != len(records) {
+			return fmt.Errorf("unexpected number of items in bulk response: %d, expected %d", len(resp.Items), len(records))
+		}
+
+		var retry [][]byte
+		for i, item := range resp.Items {
+			for _, res := range item {
+				if res.Status >= 200 && res.Status <= 299 {
+					continue
+				}
+				if res.Status == http.StatusTooManyRequests {
+					retry = append(retry, records[i])
+					continue
+				}
+				reason := http.StatusText(res.Status)
+				if res.Error != nil {
+					reason = res.Error.Type + ": " + res.Error.Reason
+				}
+				// the record is the action line followed by the document
+				doc := records[i][bytes.IndexByte(records[i], '\n')+1:]
+				level.Info(e.logger).Log(
+					"msg", "dropping log rejected by Elasticsearch",
+					"status", res.Status,
+					"reason", reason,
+					"log", logPrefix(doc),
+				)
+			}
+		}
+		if len(retry) == 0 {
+			return nil
+		}
+		if try >= httpLogMaxRetries {
+			return fmt.Errorf("bulk items rejected after %d retries: %d", try, len(retry))
+		}
+		records = retry
+	}
+}
+
+func (e *elasticsearchLogWriter) send(ctx context.Context, body []byte) (*elasticsearchBulkResponse, error) {
+	respBody, err := doHTTPLogRequest(ctx, e.client, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url+"/_bulk", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		e.setAuth(req)
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp elasticsearchBulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return nil, fmt.Errorf("decode bulk response: %w", err)
+	}
+	return &resp, nil
+}
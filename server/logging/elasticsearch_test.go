@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElasticsearchWrite(t *testing.T) {
+	ctx := context.Background()
+
+	var docs []json.RawMessage
+	// itemStatus returns the status of the n-th item received by the bulk
+	// endpoint.
+	var received int
+	itemStatus := func(n int) int { return http.StatusCreated }
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "ApiKey key", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`{"version":{"number":"8.4.0"}}`))
+		case "/_bulk":
+			require.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+			var resp elasticsearchBulkResponse
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var action map[string]map[string]string
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &action))
+				require.Equal(t, "osquery-result", action["create"]["_index"])
+
+				require.True(t, scanner.Scan())
+				status := itemStatus(received)
+				received++
+				if status == http.StatusCreated {
+					docs = append(docs, json.RawMessage(scanner.Text()))
+				} else {
+					resp.Errors = true
+				}
+				resp.Items = append(resp.Items, map[string]elasticsearchBulkItemResult{
+					"create": {Status: status},
+				})
+			}
+			require.NoError(t, scanner.Err())
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	writer, err := NewElasticsearchLogWriter(server.URL, "", "", "key", "osquery-result", time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		docs, received = nil, 0
+		err := writer.Write(ctx, logsWithNewlines)
+		require.NoError(t, err)
+		require.Len(t, docs, len(logs))
+		for i, doc := range docs {
+			require.JSONEq(t, string(logs[i]), string(doc))
+		}
+	})
+
+	t.Run("rejected items are retried", func(t *testing.T) {
+		docs, received = nil, 0
+		itemStatus = func(n int) int {
+			if n == 1 {
+				return http.StatusTooManyRequests
+			}
+			return http.StatusCreated
+		}
+		err := writer.Write(ctx, logs)
+		require.NoError(t, err)
+		require.Equal(t, 4, received)
+		require.Len(t, docs, len(logs))
+		require.JSONEq(t, string(logs[1]), string(docs[2]))
+	})
+
+	t.Run("failed items are dropped", func(t *testing.T) {
+		docs, received = nil, 0
+		itemStatus = func(n int) int {
+			switch n {
+			case 1:
+				return http.StatusTooManyRequests
+			case 2:
+				return http.StatusBadRequest
+			}
+			return http.StatusCreated
+		}
+		err := writer.Write(ctx, logs)
+		require.NoError(t, err)
+		// only the item rejected with 429 is retried
+		require.Equal(t, len(logs)+1, received)
+		require.Len(t, docs, len(logs)-1)
+		for _, doc := range docs {
+			require.NotEqual(t, string(logs[2]), string(doc))
+		}
+	})
+}
+
+func TestElasticsearchBasicAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, err := NewElasticsearchLogWriter(server.URL, "elastic", "secret", "", "osquery-status", time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+
+	_, err = NewElasticsearchLogWriter(server.URL, "elastic", "wrong", "", "osquery-status", time.Second, log.NewNopLogger())
+	require.Error(t, err)
+	var statusErr *httpLogStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+}
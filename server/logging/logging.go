@@ -1,9 +1,12 @@
 // Package logging provides logger "plugins" for writing osquery status and
-// result logs to various destinations.
+// result logs, as well as the audit log, to various destinations.
 package logging
 
 import (
+	"crypto/tls"
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/config"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -16,11 +19,89 @@ type OsqueryLogger struct {
 	Result fleet.JSONLogger
 }
 
+// New creates the osquery status and result loggers. Each of
+// osquery_status_log_plugin and osquery_result_log_plugin may be a
+// comma-separated list of plugins, in which case the logs are written to all of
+// them. If osquery_log_spool_dir is set, the logs that fail to be written are
+// spooled on disk and replayed in the background.
 func New(config config.FleetConfig, logger log.Logger) (*OsqueryLogger, error) {
-	var status, result fleet.JSONLogger
+	status, err := newMultiPlugin(config, logger, "status", config.Osquery.StatusLogPlugin, newStatusLogWriter)
+	if err != nil {
+		return nil, err
+	}
+	result, err := newMultiPlugin(config, logger, "result", config.Osquery.ResultLogPlugin, newResultLogWriter)
+	if err != nil {
+		return nil, err
+	}
+
+	if dir := config.Osquery.LogSpoolDir; dir != "" {
+		status, err = NewSpoolLogWriter(filepath.Join(dir, "status"), config.Osquery.LogSpoolMaxSize, "status", status, logger)
+		if err != nil {
+			return nil, fmt.Errorf("create status log spool: %w", err)
+		}
+		result, err = NewSpoolLogWriter(filepath.Join(dir, "result"), config.Osquery.LogSpoolMaxSize, "result", result, logger)
+		if err != nil {
+			return nil, fmt.Errorf("create result log spool: %w", err)
+		}
+	}
+	return &OsqueryLogger{Status: status, Result: result}, nil
+}
+
+// NewAuditLogger creates the logger to which the activities (audit log) are
+// streamed. Like the osquery loggers, activity_audit_log_plugin may be a
+// comma-separated list of plugins.
+func NewAuditLogger(config config.FleetConfig, logger log.Logger) (fleet.JSONLogger, error) {
+	return newMultiPlugin(config, logger, "audit", config.Activity.AuditLogPlugin, newAuditLogWriter)
+}
+
+// SplitPlugins returns the names of the plugins in the comma-separated list.
+func SplitPlugins(plugins string) []string {
+	var names []string
+	for _, name := range strings.Split(plugins, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func newMultiPlugin(
+	config config.FleetConfig,
+	logger log.Logger,
+	logType string,
+	plugins string,
+	newWriter func(config.FleetConfig, log.Logger, string) (fleet.JSONLogger, error),
+) (fleet.JSONLogger, error) {
+	names := SplitPlugins(plugins)
+	switch len(names) {
+	case 0:
+		return newWriter(config, logger, "")
+	case 1:
+		return newWriter(config, logger, names[0])
+	}
+
+	seen := make(map[string]bool, len(names))
+	destinations := make([]multiLogDestination, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate %s log plugin: %s", logType, name)
+		}
+		seen[name] = true
+
+		writer, err := newWriter(config, logger, name)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, multiLogDestination{plugin: name, writer: writer})
+	}
+	return newMultiLogWriter(logType, config.Osquery.MultiLogPluginMode, destinations, logger)
+}
+
+func newStatusLogWriter(config config.FleetConfig, logger log.Logger, plugin string) (fleet.JSONLogger, error) {
+	var status fleet.JSONLogger
 	var err error
 
-	switch config.Osquery.StatusLogPlugin {
+	switch plugin {
 	case "":
 		// Allow "" to mean filesystem for backwards compatibility
 		level.Info(logger).Log("msg", "osquery_status_log_plugin not explicitly specified. Assuming 'filesystem'")
@@ -98,13 +179,61 @@ func New(config config.FleetConfig, logger log.Logger) (*OsqueryLogger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("create kafka rest status logger: %w", err)
 		}
+	case "splunk":
+		status, err = NewSplunkLogWriter(
+			config.Splunk.URL,
+			config.Splunk.Token,
+			config.Splunk.Index,
+			config.Splunk.StatusSourcetype,
+			config.Splunk.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create splunk status logger: %w", err)
+		}
+	case "elasticsearch":
+		status, err = NewElasticsearchLogWriter(
+			config.Elasticsearch.URL,
+			config.Elasticsearch.Username,
+			config.Elasticsearch.Password,
+			config.Elasticsearch.APIKey,
+			config.Elasticsearch.StatusIndex,
+			config.Elasticsearch.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create elasticsearch status logger: %w", err)
+		}
+	case "syslog":
+		tlsConfig, err := syslogTLSConfig(config.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog status logger: %w", err)
+		}
+		status, err = NewSyslogLogWriter(
+			config.Syslog.Address,
+			config.Syslog.Facility,
+			config.Syslog.AppName,
+			"status",
+			tlsConfig,
+			config.Syslog.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog status logger: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf(
-			"unknown status log plugin: %s", config.Osquery.StatusLogPlugin,
+			"unknown status log plugin: %s", plugin,
 		)
 	}
+	return status, nil
+}
+
+func newResultLogWriter(config config.FleetConfig, logger log.Logger, plugin string) (fleet.JSONLogger, error) {
+	var result fleet.JSONLogger
+	var err error
 
-	switch config.Osquery.ResultLogPlugin {
+	switch plugin {
 	case "":
 		// Allow "" to mean filesystem for backwards compatibility
 		level.Info(logger).Log("msg", "osquery_result_log_plugin not explicitly specified. Assuming 'filesystem'")
@@ -182,10 +311,195 @@ func New(config config.FleetConfig, logger log.Logger) (*OsqueryLogger, error) {
 		if err != nil {
 			return nil, fmt.Errorf("create kafka rest result logger: %w", err)
 		}
+	case "splunk":
+		result, err = NewSplunkLogWriter(
+			config.Splunk.URL,
+			config.Splunk.Token,
+			config.Splunk.Index,
+			config.Splunk.ResultSourcetype,
+			config.Splunk.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create splunk result logger: %w", err)
+		}
+	case "elasticsearch":
+		result, err = NewElasticsearchLogWriter(
+			config.Elasticsearch.URL,
+			config.Elasticsearch.Username,
+			config.Elasticsearch.Password,
+			config.Elasticsearch.APIKey,
+			config.Elasticsearch.ResultIndex,
+			config.Elasticsearch.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create elasticsearch result logger: %w", err)
+		}
+	case "syslog":
+		tlsConfig, err := syslogTLSConfig(config.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog result logger: %w", err)
+		}
+		result, err = NewSyslogLogWriter(
+			config.Syslog.Address,
+			config.Syslog.Facility,
+			config.Syslog.AppName,
+			"result",
+			tlsConfig,
+			config.Syslog.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog result logger: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf(
-			"unknown result log plugin: %s", config.Osquery.ResultLogPlugin,
+			"unknown result log plugin: %s", plugin,
 		)
 	}
-	return &OsqueryLogger{Status: status, Result: result}, nil
+	return result, nil
+}
+
+// syslogTLSConfig returns the TLS configuration of the connection to the
+// syslog server, or nil if TLS is not enabled.
+func newAuditLogWriter(config config.FleetConfig, logger log.Logger, plugin string) (fleet.JSONLogger, error) {
+	var audit fleet.JSONLogger
+	var err error
+
+	switch plugin {
+	case "", "filesystem":
+		audit, err = NewFilesystemLogWriter(
+			config.Filesystem.AuditLogFile,
+			logger,
+			config.Filesystem.EnableLogRotation,
+			config.Filesystem.EnableLogCompression,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create filesystem audit logger: %w", err)
+		}
+	case "firehose":
+		audit, err = NewFirehoseLogWriter(
+			config.Firehose.Region,
+			config.Firehose.EndpointURL,
+			config.Firehose.AccessKeyID,
+			config.Firehose.SecretAccessKey,
+			config.Firehose.StsAssumeRoleArn,
+			config.Firehose.AuditStream,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create firehose audit logger: %w", err)
+		}
+	case "kinesis":
+		audit, err = NewKinesisLogWriter(
+			config.Kinesis.Region,
+			config.Kinesis.EndpointURL,
+			config.Kinesis.AccessKeyID,
+			config.Kinesis.SecretAccessKey,
+			config.Kinesis.StsAssumeRoleArn,
+			config.Kinesis.AuditStream,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create kinesis audit logger: %w", err)
+		}
+	case "lambda":
+		audit, err = NewLambdaLogWriter(
+			config.Lambda.Region,
+			config.Lambda.AccessKeyID,
+			config.Lambda.SecretAccessKey,
+			config.Lambda.StsAssumeRoleArn,
+			config.Lambda.AuditFunction,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create lambda audit logger: %w", err)
+		}
+	case "pubsub":
+		audit, err = NewPubSubLogWriter(
+			config.PubSub.Project,
+			config.PubSub.AuditTopic,
+			false,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create pubsub audit logger: %w", err)
+		}
+	case "stdout":
+		audit, err = NewStdoutLogWriter()
+		if err != nil {
+			return nil, fmt.Errorf("create stdout audit logger: %w", err)
+		}
+	case "kafkarest":
+		audit, err = NewKafkaRESTWriter(&KafkaRESTParams{
+			KafkaProxyHost:        config.KafkaREST.ProxyHost,
+			KafkaTopic:            config.KafkaREST.AuditTopic,
+			KafkaContentTypeValue: config.KafkaREST.ContentTypeValue,
+			KafkaTimeout:          config.KafkaREST.Timeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create kafka rest audit logger: %w", err)
+		}
+	case "splunk":
+		audit, err = NewSplunkLogWriter(
+			config.Splunk.URL,
+			config.Splunk.Token,
+			config.Splunk.Index,
+			config.Splunk.AuditSourcetype,
+			config.Splunk.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create splunk audit logger: %w", err)
+		}
+	case "elasticsearch":
+		audit, err = NewElasticsearchLogWriter(
+			config.Elasticsearch.URL,
+			config.Elasticsearch.Username,
+			config.Elasticsearch.Password,
+			config.Elasticsearch.APIKey,
+			config.Elasticsearch.AuditIndex,
+			config.Elasticsearch.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create elasticsearch audit logger: %w", err)
+		}
+	case "syslog":
+		tlsConfig, err := syslogTLSConfig(config.Syslog)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog audit logger: %w", err)
+		}
+		audit, err = NewSyslogLogWriter(
+			config.Syslog.Address,
+			config.Syslog.Facility,
+			config.Syslog.AppName,
+			"audit",
+			tlsConfig,
+			config.Syslog.Timeout,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create syslog audit logger: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf(
+			"unknown audit log plugin: %s", plugin,
+		)
+	}
+	return audit, nil
+}
+
+func syslogTLSConfig(cfg config.SyslogConfig) (*tls.Config, error) {
+	if !cfg.TLS {
+		return nil, nil
+	}
+	t := config.TLS{
+		TLSCert:       cfg.TLSCert,
+		TLSKey:        cfg.TLSKey,
+		TLSCA:         cfg.TLSCA,
+		TLSServerName: cfg.TLSServerName,
+	}
+	return t.ToTLSConfig()
 }
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// MultiLogPluginModeAll makes a write to multiple log plugins fail if any
+	// of the plugins fails.
+	MultiLogPluginModeAll = "all"
+	// MultiLogPluginModeBestEffort makes a write to multiple log plugins
+	// succeed if at least one of the plugins succeeds, the errors of the other
+	// plugins are logged.
+	MultiLogPluginModeBestEffort = "best_effort"
+)
+
+var (
+	multiLogWritesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "osquery_logs",
+			Name:      "destination_writes_total",
+			Help:      "Total number of writes of osquery logs to a destination of multiple log plugins.",
+		},
+		[]string{"log_type", "plugin"},
+	)
+	multiLogErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "osquery_logs",
+			Name:      "destination_errors_total",
+			Help:      "Total number of failed writes of osquery logs to a destination of multiple log plugins.",
+		},
+		[]string{"log_type", "plugin"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(multiLogWritesTotal, multiLogErrorsTotal)
+}
+
+type multiLogDestination struct {
+	plugin string
+	writer fleet.JSONLogger
+}
+
+// multiLogWriter writes the logs to multiple destinations concurrently.
+type multiLogWriter struct {
+	logType      string
+	mode         string
+	destinations []multiLogDestination
+	logger       log.Logger
+}
+
+func newMultiLogWriter(logType, mode string, destinations []multiLogDestination, logger log.Logger) (*multiLogWriter, error) {
+	switch mode {
+	case "":
+		mode = MultiLogPluginModeAll
+	case MultiLogPluginModeAll, MultiLogPluginModeBestEffort:
+	default:
+		return nil, fmt.Errorf("unknown multi log plugin mode: %s", mode)
+	}
+	return &multiLogWriter{
+		logType:      logType,
+		mode:         mode,
+		destinations: destinations,
+		logger:       logger,
+	}, nil
+}
+
+func (m *multiLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	errs := make([]error, len(m.destinations))
+
+	var wg sync.WaitGroup
+	for i, dest := range m.destinations {
+		wg.Add(1)
+		go func(i int, dest multiLogDestination) {
+			defer wg.Done()
+
+			multiLogWritesTotal.WithLabelValues(m.logType, dest.plugin).Inc()
+			if err := dest.writer.Write(ctx, logs); err != nil {
+				multiLogErrorsTotal.WithLabelValues(m.logType, dest.plugin).Inc()
+				errs[i] = err
+			}
+		}(i, dest)
+	}
+	wg.Wait()
+
+	var failed []string
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		plugin := m.destinations[i].plugin
+		failed = append(failed, plugin)
+		if firstErr == nil {
+			firstErr = err
+		}
+		if m.mode == MultiLogPluginModeBestEffort {
+			level.Error(m.logger).Log(
+				"msg", fmt.Sprintf("failed to write %s logs", m.logType),
+				"plugin", plugin,
+				"err", err,
+			)
+		}
+	}
+
+	if firstErr == nil {
+		return nil
+	}
+	if m.mode == MultiLogPluginModeBestEffort && len(failed) < len(m.destinations) {
+		return nil
+	}
+	return fmt.Errorf("write %s logs to %s: %w", m.logType, strings.Join(failed, ", "), firstErr)
+}
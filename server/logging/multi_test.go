@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/config"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLogWriter struct {
+	mu   sync.Mutex
+	err  error
+	logs []json.RawMessage
+}
+
+func (w *fakeLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.logs = append(w.logs, logs...)
+	return nil
+}
+
+func TestMultiLogWriter(t *testing.T) {
+	ctx := context.Background()
+
+	newWriter := func(t *testing.T, mode string, errA, errB error) (*multiLogWriter, *fakeLogWriter, *fakeLogWriter) {
+		a, b := &fakeLogWriter{err: errA}, &fakeLogWriter{err: errB}
+		w, err := newMultiLogWriter("status", mode, []multiLogDestination{
+			{plugin: "a-" + t.Name(), writer: a},
+			{plugin: "b-" + t.Name(), writer: b},
+		}, log.NewNopLogger())
+		require.NoError(t, err)
+		return w, a, b
+	}
+
+	t.Run("all succeed", func(t *testing.T) {
+		w, a, b := newWriter(t, "", nil, nil)
+		require.Equal(t, MultiLogPluginModeAll, w.mode)
+		require.NoError(t, w.Write(ctx, logs))
+		require.Equal(t, logs, a.logs)
+		require.Equal(t, logs, b.logs)
+		require.Equal(t, 1.0, testutil.ToFloat64(multiLogWritesTotal.WithLabelValues("status", "a-"+t.Name())))
+		require.Equal(t, 0.0, testutil.ToFloat64(multiLogErrorsTotal.WithLabelValues("status", "a-"+t.Name())))
+	})
+
+	t.Run("all mode fails if one fails", func(t *testing.T) {
+		w, a, _ := newWriter(t, MultiLogPluginModeAll, nil, errors.New("boom"))
+		err := w.Write(ctx, logs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "b-"+t.Name())
+		require.Contains(t, err.Error(), "boom")
+		// the other destination still got the logs
+		require.Equal(t, logs, a.logs)
+		require.Equal(t, 1.0, testutil.ToFloat64(multiLogErrorsTotal.WithLabelValues("status", "b-"+t.Name())))
+		require.Equal(t, 0.0, testutil.ToFloat64(multiLogErrorsTotal.WithLabelValues("status", "a-"+t.Name())))
+	})
+
+	t.Run("best effort succeeds if one succeeds", func(t *testing.T) {
+		w, _, b := newWriter(t, MultiLogPluginModeBestEffort, errors.New("boom"), nil)
+		require.NoError(t, w.Write(ctx, logs))
+		require.Equal(t, logs, b.logs)
+		require.Equal(t, 1.0, testutil.ToFloat64(multiLogErrorsTotal.WithLabelValues("status", "a-"+t.Name())))
+	})
+
+	t.Run("best effort fails if all fail", func(t *testing.T) {
+		w, _, _ := newWriter(t, MultiLogPluginModeBestEffort, errors.New("boom a"), errors.New("boom b"))
+		err := w.Write(ctx, logs)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "a-"+t.Name())
+		require.Contains(t, err.Error(), "b-"+t.Name())
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		_, err := newMultiLogWriter("status", "nope", nil, log.NewNopLogger())
+		require.Error(t, err)
+	})
+}
+
+func TestNewMultiplePlugins(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := config.TestConfig()
+	cfg.Osquery.StatusLogPlugin = "filesystem, stdout"
+	cfg.Osquery.ResultLogPlugin = "stdout"
+	cfg.Filesystem.StatusLogFile = filepath.Join(dir, "status.log")
+
+	osqLogger, err := New(cfg, log.NewNopLogger())
+	require.NoError(t, err)
+
+	multi, ok := osqLogger.Status.(*multiLogWriter)
+	require.True(t, ok)
+	require.Len(t, multi.destinations, 2)
+	require.Equal(t, "filesystem", multi.destinations[0].plugin)
+	require.Equal(t, "stdout", multi.destinations[1].plugin)
+
+	_, ok = osqLogger.Result.(*multiLogWriter)
+	require.False(t, ok)
+
+	cfg.Osquery.ResultLogPlugin = "stdout,stdout"
+	_, err = New(cfg, log.NewNopLogger())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "duplicate result log plugin")
+}
+
+func TestSplitPlugins(t *testing.T) {
+	require.Nil(t, SplitPlugins(""))
+	require.Equal(t, []string{"kinesis"}, SplitPlugins("kinesis"))
+	require.Equal(t, []string{"kinesis", "pubsub"}, SplitPlugins(" kinesis, ,pubsub "))
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	splunkEventPath  = "/services/collector/event"
+	splunkHealthPath = "/services/collector/health"
+
+	// Splunk accepts requests up to the max_content_length of limits.conf
+	// (800MB by default), but recommends to keep batches of events small. See
+	// https://docs.splunk.com/Documentation/Splunk/latest/Data/TroubleshootHTTPEventCollector
+	splunkMaxEventsInBatch = 1000
+	splunkMaxSizeOfEvent   = 1000 * 1000     // 1MB
+	splunkMaxSizeOfBatch   = 5 * 1000 * 1000 // 5MB
+)
+
+type splunkLogWriter struct {
+	client     *http.Client
+	url        string
+	token      string
+	index      string
+	sourcetype string
+	logger     log.Logger
+}
+
+// splunkEvent is an event sent to the Splunk HTTP Event Collector.
+type splunkEvent struct {
+	Index      string          `json:"index,omitempty"`
+	Sourcetype string          `json:"sourcetype,omitempty"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// NewSplunkLogWriter returns a log writer that sends the logs to the Splunk
+// HTTP Event Collector at baseURL. The index is optional, the default index of
+// the token is used if it is empty.
+func NewSplunkLogWriter(baseURL, token, index, sourcetype string, timeout time.Duration, logger log.Logger) (*splunkLogWriter, error) {
+	if baseURL == "" {
+		return nil, errors.New("splunk url missing")
+	}
+	if token == "" {
+		return nil, errors.New("splunk token missing")
+	}
+
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	s := &splunkLogWriter{
+		client:     fleethttp.NewClient(fleethttp.WithTimeout(timeout)),
+		url:        baseURL + splunkEventPath,
+		token:      token,
+		index:      index,
+		sourcetype: sourcetype,
+		logger:     logger,
+	}
+	if err := s.checkHealth(baseURL + splunkHealthPath); err != nil {
+		return nil, fmt.Errorf("create Splunk writer: %w", err)
+	}
+	return s, nil
+}
+
+func (s *splunkLogWriter) checkHealth(healthURL string) error {
+	_, err := doHTTPLogRequest(context.Background(), s.client, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, healthURL, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("splunk health check: %w", err)
+	}
+	return nil
+}
+
+func (s *splunkLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	limits := batchLimits{
+		maxRecords:    splunkMaxEventsInBatch,
+		maxRecordSize: splunkMaxSizeOfEvent,
+		maxBatchSize:  splunkMaxSizeOfBatch,
+	}
+	return writeBatches(s.logger, "Splunk", logs, limits, s.encode, func(records [][]byte) error {
+		if err := s.send(ctx, bytes.Join(records, nil)); err != nil {
+			return ctxerr.Wrap(ctx, err, "send events to Splunk")
+		}
+		return nil
+	})
+}
+
+func (s *splunkLogWriter) encode(log json.RawMessage) ([]byte, error) {
+	// the HTTP Event Collector accepts multiple events in a single request,
+	// as a concatenation of JSON objects.
+	return json.Marshal(splunkEvent{
+		Index:      s.index,
+		Sourcetype: s.sourcetype,
+		Event:      log,
+	})
+}
+
+func (s *splunkLogWriter) send(ctx context.Context, body []byte) error {
+	_, err := doHTTPLogRequest(ctx, s.client, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Splunk "+s.token)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	return err
+}
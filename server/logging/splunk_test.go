@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplunkWrite(t *testing.T) {
+	ctx := context.Background()
+
+	var requests [][]splunkEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Splunk token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case splunkHealthPath:
+			w.WriteHeader(http.StatusOK)
+		case splunkEventPath:
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			var events []splunkEvent
+			dec := json.NewDecoder(bytes.NewReader(body))
+			for {
+				var ev splunkEvent
+				err := dec.Decode(&ev)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				events = append(events, ev)
+			}
+			requests = append(requests, events)
+			w.Write([]byte(`{"text":"Success","code":0}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	writer, err := NewSplunkLogWriter(server.URL+"/", "token", "main", "osquery:result", time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+
+	t.Run("single batch", func(t *testing.T) {
+		requests = nil
+		err := writer.Write(ctx, logs)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		require.Len(t, requests[0], len(logs))
+		for i, ev := range requests[0] {
+			require.Equal(t, "main", ev.Index)
+			require.Equal(t, "osquery:result", ev.Sourcetype)
+			require.JSONEq(t, string(logs[i]), string(ev.Event))
+		}
+	})
+
+	t.Run("too big log is dropped", func(t *testing.T) {
+		requests = nil
+		bigLog := json.RawMessage(`{"big":"` + strings.Repeat("a", splunkMaxSizeOfEvent) + `"}`)
+		err := writer.Write(ctx, []json.RawMessage{logs[0], bigLog, logs[1]})
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		require.Len(t, requests[0], 2)
+	})
+
+	t.Run("many logs are split in batches", func(t *testing.T) {
+		requests = nil
+		var many []json.RawMessage
+		for i := 0; i < splunkMaxEventsInBatch+1; i++ {
+			many = append(many, logs[i%len(logs)])
+		}
+		err := writer.Write(ctx, many)
+		require.NoError(t, err)
+		require.Len(t, requests, 2)
+		require.Len(t, requests[0], splunkMaxEventsInBatch)
+		require.Len(t, requests[1], 1)
+	})
+}
+
+func TestSplunkWriteError(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == splunkHealthPath && healthy {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"text":"Invalid data format","code":6}`))
+	}))
+	defer server.Close()
+
+	writer, err := NewSplunkLogWriter(server.URL, "token", "", "", time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+
+	err = writer.Write(context.Background(), logs)
+	require.Error(t, err)
+	var statusErr *httpLogStatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+	require.Contains(t, statusErr.Body, "Invalid data format")
+
+	healthy = false
+	_, err = NewSplunkLogWriter(server.URL, "token", "", "", time.Second, log.NewNopLogger())
+	require.Error(t, err)
+
+	_, err = NewSplunkLogWriter(server.URL, "", "", "", time.Second, log.NewNopLogger())
+	require.Error(t, err)
+}
@@ -0,0 +1,690 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	spoolSegmentExt     = ".seg"
+	spoolCheckpointFile = "checkpoint"
+	spoolQuarantineDir  = "quarantine"
+	// spoolMaxSegmentSize is the size after which a new segment file is
+	// started, so that replayed segments can be deleted.
+	spoolMaxSegmentSize = 16 * 1024 * 1024 // 16MiB
+	spoolMinBackoff     = time.Second
+	spoolMaxBackoff     = time.Minute
+	// spoolProbeAttempts is the number of times a batch fails to be replayed
+	// before the following batch is written to check whether the destination
+	// is up. If it is, the failing batch is rejected by the destination and is
+	// moved to the quarantine so that it does not block the replay.
+	spoolProbeAttempts = 5
+	// spoolMaxReplayDuration is the time after which a batch that still fails
+	// to be replayed is moved to the quarantine, even if the destination could
+	// not be verified to be up.
+	spoolMaxReplayDuration = 24 * time.Hour
+	// spoolQuarantineMaxAge is the time after which the quarantined batches
+	// are deleted.
+	spoolQuarantineMaxAge        = 7 * 24 * time.Hour
+	spoolQuarantinePruneInterval = time.Hour
+)
+
+// errSpoolFull is returned when a batch of logs cannot be spooled because the
+// spool reached its maximum size.
+var errSpoolFull = errors.New("log spool is full")
+
+var (
+	spoolBacklogBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "osquery_logs",
+			Name:      "spool_backlog_bytes",
+			Help:      "Size in bytes of the osquery logs spooled on disk waiting to be replayed.",
+		},
+		[]string{"log_type"},
+	)
+	spoolBatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "osquery_logs",
+			Name:      "spool_batches_total",
+			Help:      "Total number of batches of osquery logs spooled on disk.",
+		},
+		[]string{"log_type"},
+	)
+	spoolReplayedBatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "osquery_logs",
+			Name:      "spool_replayed_batches_total",
+			Help:      "Total number of batches of osquery logs replayed from the spool.",
+		},
+		[]string{"log_type"},
+	)
+	spoolQuarantinedBatchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "osquery_logs",
+			Name:      "spool_quarantined_batches_total",
+			Help:      "Total number of batches of osquery logs moved to the quarantine after failing to be replayed.",
+		},
+		[]string{"log_type"},
+	)
+	spoolQuarantineBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "osquery_logs",
+			Name:      "spool_quarantine_bytes",
+			Help:      "Size in bytes of the quarantined osquery logs.",
+		},
+		[]string{"log_type"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(spoolBacklogBytes, spoolBatchesTotal, spoolReplayedBatchesTotal, spoolQuarantinedBatchesTotal, spoolQuarantineBytes)
+}
+
+type spoolCheckpoint struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+type spoolSegment struct {
+	seq  uint64
+	size int64
+}
+
+// spoolLogWriter wraps a log writer so that the batches of logs that the
+// writer fails to write are spooled to segment files on disk and replayed in
+// the background, with backoff, once the destination recovers.
+//
+// Each segment file contains one batch per line, encoded as a JSON array. The
+// position of the replay is stored in a checkpoint file so that it survives a
+// restart, batches may be replayed twice if Fleet stops while replaying.
+//
+// The replay is retried as long as the destination is down. A batch is moved
+// to a file of the quarantine directory, where it is kept for inspection
+// until it expires, only if the destination accepts the following batch
+// while rejecting it, or if it keeps failing for maxReplayDuration. The
+// quarantine counts towards the maximum size of the spool.
+type spoolLogWriter struct {
+	dest    fleet.JSONLogger
+	dir     string
+	maxSize int64
+	logType string
+	logger  log.Logger
+
+	minBackoff        time.Duration
+	maxBackoff        time.Duration
+	probeAttempts     int
+	maxReplayDuration time.Duration
+	quarantineMaxAge  time.Duration
+
+	wake chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// quarantineMu serializes the changes of the quarantine directory.
+	quarantineMu sync.Mutex
+
+	mu             sync.Mutex
+	segments       []spoolSegment // oldest first
+	current        *os.File       // open for writing, the last of segments if not nil
+	offset         int64          // replay offset in the oldest segment
+	size           int64          // total size of the segments
+	quarantineSize int64          // total size of the quarantined batches
+}
+
+// NewSpoolLogWriter returns a log writer that writes the logs to dest, and
+// spools them in dir if dest fails. The spooled logs are replayed in the
+// background until Close is called. The spool is limited to maxSize bytes,
+// when the spool is full the logs are written to dest out of order, and the
+// write fails if dest fails.
+func NewSpoolLogWriter(dir string, maxSize int64, logType string, dest fleet.JSONLogger, logger log.Logger) (*spoolLogWriter, error) {
+	s, err := openSpoolLogWriter(dir, maxSize, logType, dest, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.start()
+	return s, nil
+}
+
+// openSpoolLogWriter loads the spool in dir, the replay is started by start.
+func openSpoolLogWriter(dir string, maxSize int64, logType string, dest fleet.JSONLogger, logger log.Logger) (*spoolLogWriter, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+
+	s := &spoolLogWriter{
+		dest:              dest,
+		dir:               dir,
+		maxSize:           maxSize,
+		logType:           logType,
+		logger:            log.With(logger, "component", "log-spool", "log_type", logType),
+		minBackoff:        spoolMinBackoff,
+		maxBackoff:        spoolMaxBackoff,
+		probeAttempts:     spoolProbeAttempts,
+		maxReplayDuration: spoolMaxReplayDuration,
+		quarantineMaxAge:  spoolQuarantineMaxAge,
+		wake:              make(chan struct{}, 1),
+		done:              make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("load spool: %w", err)
+	}
+	if err := s.pruneQuarantine(); err != nil {
+		return nil, fmt.Errorf("prune spool quarantine: %w", err)
+	}
+	s.updateBacklogMetric()
+	return s, nil
+}
+
+func (s *spoolLogWriter) start() {
+	s.wg.Add(2)
+	go s.replayLoop()
+	go s.pruneLoop()
+}
+
+// load reads the existing segments and checkpoint of the spool directory.
+func (s *spoolLogWriter) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq, size: info.Size()})
+		s.size += info.Size()
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+
+	b, err := os.ReadFile(filepath.Join(s.dir, spoolCheckpointFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	var cp spoolCheckpoint
+	if err := json.Unmarshal(b, &cp); err != nil {
+		level.Info(s.logger).Log("msg", "ignoring invalid spool checkpoint", "err", err)
+		return nil
+	}
+	// segments before the checkpoint were replayed, but may not have been
+	// deleted if Fleet stopped right after.
+	for len(s.segments) > 0 && s.segments[0].seq < cp.Segment {
+		if err := s.removeOldestSegment(); err != nil {
+			return err
+		}
+	}
+	if len(s.segments) > 0 && s.segments[0].seq == cp.Segment && cp.Offset <= s.segments[0].size {
+		s.offset = cp.Offset
+	}
+	return nil
+}
+
+func (s *spoolLogWriter) segmentPath(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+func (s *spoolLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	backlog := s.hasBacklog()
+	if !backlog {
+		err := s.dest.Write(ctx, logs)
+		if err == nil {
+			return nil
+		}
+		level.Info(s.logger).Log("msg", "spooling logs after write failure", "err", err)
+	}
+
+	// once there is a backlog, new logs are spooled too so that they are
+	// replayed in order.
+	err := s.spool(logs)
+	if errors.Is(err, errSpoolFull) && backlog {
+		// the destination may have recovered while the backlog is replayed,
+		// writing the logs out of order is better than failing.
+		if destErr := s.dest.Write(ctx, logs); destErr == nil {
+			return nil
+		}
+	}
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "spool logs")
+	}
+	return nil
+}
+
+// Close stops the replay of the spooled logs. The logs that are still in the
+// spool are replayed when a new spool writer is created for the directory.
+func (s *spoolLogWriter) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		err := s.current.Close()
+		s.current = nil
+		return err
+	}
+	return nil
+}
+
+func (s *spoolLogWriter) hasBacklog() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size > s.offset
+}
+
+func (s *spoolLogWriter) spool(logs []json.RawMessage) error {
+	line, err := json.Marshal(logs)
+	if err != nil {
+		return fmt.Errorf("encode logs: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+s.quarantineSize+int64(len(line)) > s.maxSize {
+		return errSpoolFull
+	}
+	if s.current == nil || s.segments[len(s.segments)-1].size >= spoolMaxSegmentSize {
+		if err := s.newSegment(); err != nil {
+			return err
+		}
+	}
+	n, err := s.current.Write(line)
+	last := &s.segments[len(s.segments)-1]
+	last.size += int64(n)
+	s.size += int64(n)
+	if err != nil {
+		// start a new segment on the next write, the partial line is skipped
+		// by the replay.
+		s.current.Close()
+		s.current = nil
+		return fmt.Errorf("write spool segment: %w", err)
+	}
+
+	spoolBatchesTotal.WithLabelValues(s.logType).Inc()
+	s.updateBacklogMetric()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// newSegment starts a new segment file. It must be called with the mutex held.
+func (s *spoolLogWriter) newSegment() error {
+	if s.current != nil {
+		if err := s.current.Close(); err != nil {
+			return fmt.Errorf("close spool segment: %w", err)
+		}
+		s.current = nil
+	}
+
+	var seq uint64 = 1
+	if len(s.segments) > 0 {
+		seq = s.segments[len(s.segments)-1].seq + 1
+	}
+	f, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("create spool segment: %w", err)
+	}
+	s.current = f
+	s.segments = append(s.segments, spoolSegment{seq: seq})
+	return nil
+}
+
+// removeOldestSegment deletes the oldest segment, it must be called with the
+// mutex held.
+func (s *spoolLogWriter) removeOldestSegment() error {
+	oldest := s.segments[0]
+	if s.current != nil && len(s.segments) == 1 {
+		if err := s.current.Close(); err != nil {
+			return fmt.Errorf("close spool segment: %w", err)
+		}
+		s.current = nil
+	}
+	if err := os.Remove(s.segmentPath(oldest.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove spool segment: %w", err)
+	}
+	s.segments = s.segments[1:]
+	s.size -= oldest.size
+	s.offset = 0
+	return nil
+}
+
+// saveCheckpoint stores the replay position, it must be called with the mutex
+// held.
+func (s *spoolLogWriter) saveCheckpoint() error {
+	var cp spoolCheckpoint
+	if len(s.segments) > 0 {
+		cp = spoolCheckpoint{Segment: s.segments[0].seq, Offset: s.offset}
+	}
+	b, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, spoolCheckpointFile)
+	if err := os.WriteFile(path+".tmp", b, 0o600); err != nil {
+		return fmt.Errorf("write spool checkpoint: %w", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("rename spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+func (s *spoolLogWriter) updateBacklogMetric() {
+	spoolBacklogBytes.WithLabelValues(s.logType).Set(float64(s.size - s.offset))
+}
+
+// nextBatch returns the next batch of logs to replay and the offset in the
+// oldest segment after that batch. It returns false if there is nothing to
+// replay.
+func (s *spoolLogWriter) nextBatch() ([]json.RawMessage, int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.segments) > 0 {
+		oldest := s.segments[0]
+		if s.offset >= oldest.size {
+			// the segment is fully replayed (if it is the current segment, a
+			// new one is started when logs are spooled again).
+			if err := s.removeOldestSegment(); err != nil {
+				return nil, 0, false, err
+			}
+			if err := s.saveCheckpoint(); err != nil {
+				return nil, 0, false, err
+			}
+			continue
+		}
+
+		line, err := readSpoolLine(s.segmentPath(oldest.seq), s.offset)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		next := s.offset + int64(len(line))
+
+		var logs []json.RawMessage
+		if len(line) == 0 || line[len(line)-1] != '\n' || json.Unmarshal(line, &logs) != nil {
+			// partial or corrupted line, e.g. after a crash or a failed write.
+			level.Info(s.logger).Log("msg", "skipping invalid spooled batch", "segment", oldest.seq, "offset", s.offset)
+			if len(line) == 0 {
+				next = oldest.size
+			}
+			s.offset = next
+			s.updateBacklogMetric()
+			continue
+		}
+		return logs, next, true, nil
+	}
+	return nil, 0, false, nil
+}
+
+func readSpoolLine(path string, offset int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek spool segment: %w", err)
+	}
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read spool segment: %w", err)
+	}
+	return line, nil
+}
+
+// ack marks the batch that ends at offset next as replayed.
+func (s *spoolLogWriter) ack(next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset = next
+	s.updateBacklogMetric()
+	return s.saveCheckpoint()
+}
+
+// peekBatch returns the batch that follows the batch ending at offset next in
+// the oldest segment, with the segment and the offset after it. It returns
+// false if there is no valid batch to read.
+func (s *spoolLogWriter) peekBatch(next int64) ([]json.RawMessage, uint64, int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		return nil, 0, 0, false
+	}
+	seg, offset := s.segments[0], next
+	if offset >= seg.size {
+		if len(s.segments) < 2 {
+			return nil, 0, 0, false
+		}
+		seg, offset = s.segments[1], 0
+	}
+	line, err := readSpoolLine(s.segmentPath(seg.seq), offset)
+	if err != nil || len(line) == 0 || line[len(line)-1] != '\n' {
+		return nil, 0, 0, false
+	}
+	var logs []json.RawMessage
+	if err := json.Unmarshal(line, &logs); err != nil {
+		return nil, 0, 0, false
+	}
+	return logs, seg.seq, offset + int64(len(line)), true
+}
+
+// ackPeeked marks the batches up to the batch returned by peekBatch as
+// replayed.
+func (s *spoolLogWriter) ackPeeked(seq uint64, next int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.segments) > 0 && s.segments[0].seq < seq {
+		if err := s.removeOldestSegment(); err != nil {
+			return err
+		}
+	}
+	s.offset = next
+	s.updateBacklogMetric()
+	return s.saveCheckpoint()
+}
+
+// quarantine stores the batch that ends at offset next in a file of the
+// quarantine directory.
+func (s *spoolLogWriter) quarantine(logs []json.RawMessage, next int64) error {
+	s.mu.Lock()
+	seq := s.segments[0].seq
+	s.mu.Unlock()
+
+	s.quarantineMu.Lock()
+	defer s.quarantineMu.Unlock()
+
+	dir := filepath.Join(s.dir, spoolQuarantineDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create quarantine directory: %w", err)
+	}
+	b, err := json.Marshal(logs)
+	if err != nil {
+		return fmt.Errorf("encode logs: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%020d-%020d.json", seq, next))
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("write quarantined logs: %w", err)
+	}
+
+	s.mu.Lock()
+	s.quarantineSize += int64(len(b))
+	spoolQuarantineBytes.WithLabelValues(s.logType).Set(float64(s.quarantineSize))
+	s.mu.Unlock()
+	return nil
+}
+
+// pruneQuarantine deletes the quarantined batches older than
+// quarantineMaxAge and updates the size of the quarantine.
+func (s *spoolLogWriter) pruneQuarantine() error {
+	s.quarantineMu.Lock()
+	defer s.quarantineMu.Unlock()
+
+	dir := filepath.Join(s.dir, spoolQuarantineDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	var size int64
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if time.Since(info.ModTime()) > s.quarantineMaxAge {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("remove quarantined logs: %w", err)
+			}
+			continue
+		}
+		size += info.Size()
+	}
+
+	s.mu.Lock()
+	s.quarantineSize = size
+	spoolQuarantineBytes.WithLabelValues(s.logType).Set(float64(size))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *spoolLogWriter) pruneLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(spoolQuarantinePruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.pruneQuarantine(); err != nil {
+				level.Error(s.logger).Log("msg", "prune spool quarantine", "err", err)
+			}
+		}
+	}
+}
+
+func (s *spoolLogWriter) replayLoop() {
+	defer s.wg.Done()
+
+	backoff := s.minBackoff
+	attempts := 0
+	var failingSince time.Time
+	for {
+		logs, next, ok, err := s.nextBatch()
+		if err != nil {
+			level.Error(s.logger).Log("msg", "read spooled logs", "err", err)
+		}
+		if !ok || err != nil {
+			var retry <-chan time.Time
+			if err != nil {
+				retry = time.After(backoff)
+			}
+			select {
+			case <-s.done:
+				return
+			case <-s.wake:
+			case <-retry:
+			}
+			continue
+		}
+
+		err = s.dest.Write(context.Background(), logs)
+		if err == nil {
+			backoff = s.minBackoff
+			spoolReplayedBatchesTotal.WithLabelValues(s.logType).Inc()
+			attempts, failingSince = 0, time.Time{}
+			if err := s.ack(next); err != nil {
+				level.Error(s.logger).Log("msg", "save spool checkpoint", "err", err)
+			}
+			continue
+		}
+
+		attempts++
+		if failingSince.IsZero() {
+			failingSince = time.Now()
+		}
+		rejected, expired := false, time.Since(failingSince) >= s.maxReplayDuration
+		var peekedSeq uint64
+		var peekedNext int64
+		if attempts >= s.probeAttempts {
+			// the batch is rejected if the destination accepts the following
+			// one, otherwise the destination is most likely down.
+			if peeked, seq, after, ok := s.peekBatch(next); ok && s.dest.Write(context.Background(), peeked) == nil {
+				rejected, peekedSeq, peekedNext = true, seq, after
+			}
+		}
+		if !rejected && !expired {
+			level.Debug(s.logger).Log("msg", "replay spooled logs", "err", err, "backoff", backoff)
+			select {
+			case <-s.done:
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+			continue
+		}
+
+		level.Error(s.logger).Log("msg", "quarantining spooled logs", "rejected", rejected, "attempts", attempts, "failing_since", failingSince, "err", err)
+		if err := s.quarantine(logs, next); err != nil {
+			level.Error(s.logger).Log("msg", "dropping spooled logs that could not be quarantined", "err", err)
+		}
+		spoolQuarantinedBatchesTotal.WithLabelValues(s.logType).Inc()
+		attempts, failingSince = 0, time.Time{}
+
+		if rejected {
+			// the destination is up, the following batch was replayed.
+			backoff = s.minBackoff
+			spoolReplayedBatchesTotal.WithLabelValues(s.logType).Inc()
+			err = s.ackPeeked(peekedSeq, peekedNext)
+		} else {
+			// the backoff is kept, the destination may still be down.
+			err = s.ack(next)
+		}
+		if err != nil {
+			level.Error(s.logger).Log("msg", "save spool checkpoint", "err", err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func (w *fakeLogWriter) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+func (w *fakeLogWriter) written() []json.RawMessage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]json.RawMessage(nil), w.logs...)
+}
+
+func TestSpoolLogWriter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	dest := &fakeLogWriter{}
+	spool, err := NewSpoolLogWriter(dir, 1024*1024, "result", dest, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// written directly while the destination is healthy
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	require.Equal(t, logs[:1], dest.written())
+	require.False(t, spool.hasBacklog())
+
+	// spooled while the destination is down
+	dest.setErr(errors.New("destination down"))
+	require.NoError(t, spool.Write(ctx, logs[1:2]))
+	require.True(t, spool.hasBacklog())
+	require.Greater(t, testutil.ToFloat64(spoolBacklogBytes.WithLabelValues("result")), 0.0)
+
+	// new logs are spooled after the backlog, even if the destination recovers
+	dest.setErr(nil)
+	require.NoError(t, spool.Write(ctx, logs[2:]))
+
+	require.Eventually(t, func() bool {
+		return len(dest.written()) == len(logs)
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, logs, dest.written())
+	require.Eventually(t, func() bool { return !spool.hasBacklog() }, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, 0.0, testutil.ToFloat64(spoolBacklogBytes.WithLabelValues("result")))
+
+	require.NoError(t, spool.Close())
+
+	// the replayed segments are removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		require.NotContains(t, e.Name(), spoolSegmentExt)
+	}
+}
+
+func TestSpoolLogWriterRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := NewSpoolLogWriter(dir, 1024*1024, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	for _, l := range logs {
+		require.NoError(t, spool.Write(ctx, []json.RawMessage{l}))
+	}
+	require.NoError(t, spool.Close())
+	require.Empty(t, dest.written())
+
+	// the spooled logs are replayed by a new writer
+	dest.setErr(nil)
+	spool, err = NewSpoolLogWriter(dir, 1024*1024, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	require.Eventually(t, func() bool {
+		return len(dest.written()) == len(logs)
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, logs, dest.written())
+}
+
+func TestSpoolLogWriterFull(t *testing.T) {
+	ctx := context.Background()
+
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := NewSpoolLogWriter(t.TempDir(), 32, "result", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	err = spool.Write(ctx, logs)
+	require.Error(t, err)
+	require.ErrorIs(t, err, errSpoolFull)
+}
+
+func TestSpoolLogWriterFullDestinationRecovered(t *testing.T) {
+	ctx := context.Background()
+
+	// the replay is not started, so that the backlog is kept
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := openSpoolLogWriter(t.TempDir(), 32, "result", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	require.True(t, spool.hasBacklog())
+
+	// the logs that do not fit in the spool are written directly
+	dest.setErr(nil)
+	require.NoError(t, spool.Write(ctx, logs))
+	require.Equal(t, logs, dest.written())
+	require.True(t, spool.hasBacklog())
+}
+
+// rejectingLogWriter rejects the batches that contain the rejected log.
+type rejectingLogWriter struct {
+	fakeLogWriter
+	rejected json.RawMessage
+}
+
+func (w *rejectingLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	for _, l := range logs {
+		if string(l) == string(w.rejected) {
+			return errors.New("rejected")
+		}
+	}
+	return w.fakeLogWriter.Write(ctx, logs)
+}
+
+func TestSpoolLogWriterQuarantine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	dest := &rejectingLogWriter{rejected: logs[0]}
+	dest.setErr(errors.New("destination down"))
+	spool, err := openSpoolLogWriter(dir, 1024*1024, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	spool.minBackoff, spool.maxBackoff = time.Millisecond, time.Millisecond
+	spool.probeAttempts = 3
+
+	quarantined := testutil.ToFloat64(spoolQuarantinedBatchesTotal.WithLabelValues("status"))
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	require.NoError(t, spool.Write(ctx, logs[1:2]))
+	require.NoError(t, spool.Write(ctx, logs[2:]))
+	dest.setErr(nil)
+	spool.start()
+	defer spool.Close()
+
+	// only the batch rejected by the destination is moved to the quarantine
+	require.Eventually(t, func() bool { return !spool.hasBacklog() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, quarantined+1, testutil.ToFloat64(spoolQuarantinedBatchesTotal.WithLabelValues("status")))
+	require.Equal(t, logs[1:], dest.written())
+
+	entries, err := os.ReadDir(filepath.Join(dir, spoolQuarantineDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	b, err := os.ReadFile(filepath.Join(dir, spoolQuarantineDir, entries[0].Name()))
+	require.NoError(t, err)
+	var got []json.RawMessage
+	require.NoError(t, json.Unmarshal(b, &got))
+	require.Equal(t, logs[:1], got)
+}
+
+func TestSpoolLogWriterDestinationDown(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := openSpoolLogWriter(dir, 1024*1024, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	spool.minBackoff, spool.maxBackoff = time.Millisecond, time.Millisecond
+	spool.probeAttempts = 3
+	spool.start()
+	defer spool.Close()
+
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	require.NoError(t, spool.Write(ctx, logs[1:]))
+
+	// nothing is quarantined while the destination is down
+	time.Sleep(100 * time.Millisecond)
+	require.True(t, spool.hasBacklog())
+	_, err = os.Stat(filepath.Join(dir, spoolQuarantineDir))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	dest.setErr(nil)
+	require.Eventually(t, func() bool { return !spool.hasBacklog() }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, logs, dest.written())
+}
+
+func TestSpoolLogWriterMaxReplayDuration(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := openSpoolLogWriter(dir, 1024*1024, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	spool.minBackoff, spool.maxBackoff = time.Millisecond, time.Millisecond
+	spool.maxReplayDuration = 20 * time.Millisecond
+	spool.start()
+	defer spool.Close()
+
+	require.NoError(t, spool.Write(ctx, logs[:1]))
+	require.NoError(t, spool.Write(ctx, logs[1:]))
+
+	// the batches are quarantined once they fail for too long
+	require.Eventually(t, func() bool { return !spool.hasBacklog() }, 5*time.Second, 10*time.Millisecond)
+	entries, err := os.ReadDir(filepath.Join(dir, spoolQuarantineDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Empty(t, dest.written())
+}
+
+func TestSpoolLogWriterQuarantineSize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	quarantineDir := filepath.Join(dir, spoolQuarantineDir)
+	require.NoError(t, os.MkdirAll(quarantineDir, 0o700))
+	kept := filepath.Join(quarantineDir, "00000000000000000001-00000000000000000010.json")
+	require.NoError(t, os.WriteFile(kept, make([]byte, 64), 0o600))
+	expired := filepath.Join(quarantineDir, "00000000000000000000-00000000000000000010.json")
+	require.NoError(t, os.WriteFile(expired, make([]byte, 64), 0o600))
+	old := time.Now().Add(-spoolQuarantineMaxAge - time.Hour)
+	require.NoError(t, os.Chtimes(expired, old, old))
+
+	dest := &fakeLogWriter{err: errors.New("destination down")}
+	spool, err := openSpoolLogWriter(dir, 96, "status", dest, log.NewNopLogger())
+	require.NoError(t, err)
+	defer spool.Close()
+
+	// the expired batch is deleted, the other one counts in the size limit
+	_, err = os.Stat(expired)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.EqualValues(t, 64, spool.quarantineSize)
+	err = spool.Write(ctx, logs)
+	require.ErrorIs(t, err, errSpoolFull)
+
+	require.NoError(t, os.Remove(kept))
+	require.NoError(t, spool.pruneQuarantine())
+	require.NoError(t, spool.Write(ctx, logs))
+	require.True(t, spool.hasBacklog())
+}
@@ -0,0 +1,194 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/go-kit/kit/log"
+)
+
+const (
+	// RFC 5425 requires receivers to support messages of at least 2KB and
+	// recommends 8KB, most receivers accept larger messages when using TCP.
+	syslogMaxSizeOfMessage = 64 * 1024   // 64KiB
+	syslogMaxSizeOfBatch   = 1000 * 1000 // 1MB
+	syslogMaxMsgsInBatch   = 1000
+	syslogSeverityInfo     = 6
+	syslogTimestampFormat  = "2006-01-02T15:04:05.000000Z07:00"
+	syslogNilValue         = "-"
+)
+
+// syslogFacilities maps the facility names to their code, as defined in
+// RFC 5424 section 6.2.1.
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+type syslogLogWriter struct {
+	address   string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	priority  int
+	hostname  string
+	appName   string
+	msgID     string
+	logger    log.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogLogWriter returns a log writer that sends the logs as RFC 5424
+// syslog messages to the TCP address, using TLS if tlsConfig is not nil. The
+// messages are framed using octet counting (RFC 6587) and msgID identifies the
+// type of logs (e.g. status or result).
+func NewSyslogLogWriter(address, facility, appName, msgID string, tlsConfig *tls.Config, timeout time.Duration, logger log.Logger) (*syslogLogWriter, error) {
+	if address == "" {
+		return nil, errors.New("syslog address missing")
+	}
+	code, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility: %s", facility)
+	}
+	if appName == "" {
+		appName = syslogNilValue
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = syslogNilValue
+	}
+
+	s := &syslogLogWriter{
+		address:   address,
+		tlsConfig: tlsConfig,
+		timeout:   timeout,
+		priority:  code*8 + syslogSeverityInfo,
+		hostname:  hostname,
+		appName:   appName,
+		msgID:     msgID,
+		logger:    logger,
+	}
+	if err := s.connect(); err != nil {
+		return nil, fmt.Errorf("create syslog writer: %w", err)
+	}
+	return s, nil
+}
+
+// connect dials the syslog server. It must be called with the mutex held (or
+// during creation of the writer).
+func (s *syslogLogWriter) connect() error {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	dialer := &net.Dialer{Timeout: s.timeout}
+	var conn net.Conn
+	var err error
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.address)
+	}
+	if err != nil {
+		return fmt.Errorf("dial syslog server: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *syslogLogWriter) Write(ctx context.Context, logs []json.RawMessage) error {
+	limits := batchLimits{
+		maxRecords:    syslogMaxMsgsInBatch,
+		maxRecordSize: syslogMaxSizeOfMessage,
+		maxBatchSize:  syslogMaxSizeOfBatch,
+	}
+	return writeBatches(s.logger, "syslog", logs, limits, s.encode, func(records [][]byte) error {
+		if err := s.send(bytes.Join(records, nil)); err != nil {
+			return ctxerr.Wrap(ctx, err, "send messages to syslog")
+		}
+		return nil
+	})
+}
+
+// encode returns the RFC 5424 message of the log, framed with its length.
+func (s *syslogLogWriter) encode(log json.RawMessage) ([]byte, error) {
+	// the JSON log is sent as the MSG part, structured data is not used.
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s %s ",
+		s.priority,
+		time.Now().UTC().Format(syslogTimestampFormat),
+		s.hostname,
+		s.appName,
+		syslogNilValue, // PROCID
+		s.msgID,
+		syslogNilValue, // STRUCTURED-DATA
+	)
+	if err := json.Compact(&buf, log); err != nil {
+		return nil, err
+	}
+
+	msg := buf.Bytes()
+	framed := make([]byte, 0, len(msg)+8)
+	framed = strconv.AppendInt(framed, int64(len(msg)), 10)
+	framed = append(framed, ' ')
+	return append(framed, msg...), nil
+}
+
+// send writes the messages on the connection. If the write fails (e.g. the
+// server closed the connection), it reconnects and tries once more.
+func (s *syslogLogWriter) send(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		if err := s.write(b); err == nil {
+			return nil
+		}
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(b)
+}
+
+func (s *syslogLogWriter) write(b []byte) error {
+	if s.timeout > 0 {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout)); err != nil {
+			return fmt.Errorf("set write deadline: %w", err)
+		}
+	}
+	if _, err := s.conn.Write(b); err != nil {
+		return fmt.Errorf("write to syslog server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+// readSyslogMessages reads the octet-counting framed messages received on
+// the listener and sends them on the returned channel.
+func readSyslogMessages(t *testing.T, ln net.Listener) <-chan string {
+	msgs := make(chan string, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					length, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+					if err != nil {
+						t.Errorf("invalid message length: %q", length)
+						return
+					}
+					buf := make([]byte, n)
+					if _, err := io.ReadFull(r, buf); err != nil {
+						return
+					}
+					msgs <- string(buf)
+				}
+			}(conn)
+		}
+	}()
+	return msgs
+}
+
+func TestSyslogWrite(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	msgs := readSyslogMessages(t, ln)
+
+	writer, err := NewSyslogLogWriter(ln.Addr().String(), "local3", "fleet", "result", nil, time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+
+	// local3 (19) * 8 + info (6) = 158
+	msgRx := regexp.MustCompile(`^<158>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}Z \S+ fleet - result - (.+)$`)
+	requireMessages := func(expected []json.RawMessage) {
+		for _, log := range expected {
+			select {
+			case msg := <-msgs:
+				matches := msgRx.FindStringSubmatch(msg)
+				require.Len(t, matches, 2, msg)
+				require.JSONEq(t, string(log), matches[1])
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for syslog message")
+			}
+		}
+	}
+
+	err = writer.Write(context.Background(), logsWithNewlines)
+	require.NoError(t, err)
+	requireMessages(logs)
+
+	// too big logs are dropped
+	bigLog := json.RawMessage(`{"big":"` + strings.Repeat("a", syslogMaxSizeOfMessage) + `"}`)
+	err = writer.Write(context.Background(), []json.RawMessage{bigLog, logs[2]})
+	require.NoError(t, err)
+	requireMessages(logs[2:])
+
+	// the writer reconnects if the connection is closed
+	writer.conn.Close()
+	err = writer.Write(context.Background(), logs[:1])
+	require.NoError(t, err)
+	requireMessages(logs[:1])
+}
+
+func TestSyslogNewErrors(t *testing.T) {
+	_, err := NewSyslogLogWriter("", "local0", "fleet", "status", nil, time.Second, log.NewNopLogger())
+	require.Error(t, err)
+
+	_, err = NewSyslogLogWriter("127.0.0.1:514", "nosuchfacility", "fleet", "status", nil, time.Second, log.NewNopLogger())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown syslog facility")
+
+	// nothing listening on that address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	_, err = NewSyslogLogWriter(addr, "local0", "fleet", "status", nil, time.Second, log.NewNopLogger())
+	require.Error(t, err)
+}
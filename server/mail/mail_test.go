@@ -155,3 +155,17 @@ func TestTemplateProcessor(t *testing.T) {
 	require.Nil(t, err)
 	assert.NotNil(t, out)
 }
+
+func TestAccountLockedTemplate(t *testing.T) {
+	mailer := AccountLockedMailer{
+		BaseURL:        "https://localhost.com:8080",
+		FailedLogins:   10,
+		LockoutMinutes: 15,
+	}
+
+	out, err := mailer.Message()
+	require.Nil(t, err)
+	assert.Contains(t, string(out), "10 failed")
+	assert.Contains(t, string(out), "15 minutes")
+	assert.Contains(t, string(out), "https://localhost.com:8080/login/forgot")
+}
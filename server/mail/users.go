@@ -45,3 +45,27 @@ func (r PasswordResetMailer) Message() ([]byte, error) {
 	}
 	return msg.Bytes(), nil
 }
+
+type AccountLockedMailer struct {
+	// Base URL to use for Fleet endpoints
+	BaseURL template.URL
+	// URL for loading image assets
+	AssetURL template.URL
+	// FailedLogins is the number of failed logins that locked the account
+	FailedLogins int
+	// LockoutMinutes is the number of minutes the account remains locked
+	LockoutMinutes int
+}
+
+func (r AccountLockedMailer) Message() ([]byte, error) {
+	t, err := getTemplate("server/mail/templates/account_locked.html")
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	if err = t.Execute(&msg, r); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
@@ -8,6 +8,8 @@ import (
 
 //go:generate mockimpl -o datastore_mock.go "s *DataStore" "fleet.Datastore"
 //go:generate mockimpl -o datastore_installers.go "s *InstallerStore" "fleet.InstallerStore"
+//go:generate mockimpl -o datastore_activity_archive.go "s *ActivityArchiveStore" "fleet.ActivityArchiveStore"
+//go:generate mockimpl -o datastore_live_query_export.go "s *LiveQueryExportStore" "fleet.LiveQueryExportStore"
 
 var _ fleet.Datastore = (*Store)(nil)
 
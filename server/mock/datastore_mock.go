@@ -171,7 +171,7 @@ type HostByIdentifierFunc func(ctx context.Context, identifier string) (*fleet.H
 
 type AddHostsToTeamFunc func(ctx context.Context, teamID *uint, hostIDs []uint) error
 
-type TotalAndUnseenHostsSinceFunc func(ctx context.Context, daysCount int) (total int, unseen int, err error)
+type TotalAndUnseenHostsSinceFunc func(ctx context.Context, teamID *uint, daysCount int) (total int, unseen int, err error)
 
 type DeleteHostsFunc func(ctx context.Context, ids []uint) error
 
@@ -327,7 +327,7 @@ type CleanupHostOperatingSystemsFunc func(ctx context.Context) error
 
 type NewActivityFunc func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error
 
-type ListActivitiesFunc func(ctx context.Context, opt fleet.ListOptions) ([]*fleet.Activity, error)
+type ListActivitiesFunc func(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error)
 
 type ShouldSendStatisticsFunc func(ctx context.Context, frequency time.Duration, config config.FleetConfig, license *fleet.LicenseInfo) (fleet.StatisticsPayload, bool, error)
 
@@ -441,6 +441,90 @@ type ProcessListFunc func(ctx context.Context) ([]fleet.MySQLProcess, error)
 
 type InsertWindowsUpdatesFunc func(ctx context.Context, hostID uint, updates []fleet.WindowsUpdate) error
 
+type NewWebhookDeliveryFunc func(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error)
+
+type WebhookDeliveryFunc func(ctx context.Context, id uint) (*fleet.WebhookDelivery, error)
+
+type UpdateWebhookDeliveryFunc func(ctx context.Context, delivery *fleet.WebhookDelivery) error
+
+type ListWebhookDeliveriesFunc func(ctx context.Context, opts fleet.WebhookDeliveryListOptions) ([]*fleet.WebhookDelivery, error)
+
+type NewPolicyAutomationTicketsFunc func(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error
+
+type ListOpenPolicyAutomationTicketsFunc func(ctx context.Context, policyID uint, hostIDs []uint) ([]*fleet.PolicyAutomationTicket, error)
+
+type ListPolicyAutomationTicketOpenHostsFunc func(ctx context.Context, integration, ticketID string) ([]uint, error)
+
+type ResolvePolicyAutomationTicketFunc func(ctx context.Context, integration, ticketID string, hostID uint) error
+
+type NewAPITokenFunc func(ctx context.Context, token *fleet.APIToken) (*fleet.APIToken, error)
+
+type APITokenByKeyHashFunc func(ctx context.Context, keyHash string) (*fleet.APIToken, error)
+
+type ListAPITokensFunc func(ctx context.Context, userID uint) ([]*fleet.APIToken, error)
+
+type DeleteAPITokenFunc func(ctx context.Context, userID uint, id uint) error
+
+type MarkAPITokenUsedFunc func(ctx context.Context, id uint) error
+
+type ApplyCustomRoleSpecsFunc func(ctx context.Context, specs []*fleet.CustomRoleSpec) error
+
+type ListCustomRolesFunc func(ctx context.Context) ([]*fleet.CustomRole, error)
+
+type CustomRoleByNameFunc func(ctx context.Context, name string) (*fleet.CustomRole, error)
+
+type DeleteCustomRoleFunc func(ctx context.Context, name string) error
+
+type UserTOTPFunc func(ctx context.Context, userID uint) (*fleet.UserTOTP, error)
+
+type SaveUserTOTPFunc func(ctx context.Context, totp *fleet.UserTOTP) error
+
+type DeleteUserTOTPFunc func(ctx context.Context, userID uint) error
+
+type ReplaceUserTOTPRecoveryCodesFunc func(ctx context.Context, userID uint, codeHashes []string) error
+
+type DeleteUserTOTPRecoveryCodeFunc func(ctx context.Context, userID uint, codeHash string) error
+
+type NewTOTPLoginChallengeFunc func(ctx context.Context, challenge *fleet.TOTPLoginChallenge) error
+
+type TOTPLoginChallengeByTokenFunc func(ctx context.Context, token string) (*fleet.TOTPLoginChallenge, error)
+
+type IncrementTOTPLoginChallengeAttemptsFunc func(ctx context.Context, token string) error
+
+type DeleteTOTPLoginChallengeFunc func(ctx context.Context, token string) error
+
+type MarkActivitiesAsStreamedFunc func(ctx context.Context, activityIDs []uint) error
+
+type DeleteActivitiesFunc func(ctx context.Context, activityIDs []uint) error
+
+type NewPasswordHistoryFunc func(ctx context.Context, userID uint, password []byte, salt string, keep int) error
+
+type ListPasswordHistoryFunc func(ctx context.Context, userID uint, limit int) ([]*fleet.PasswordHistoryEntry, error)
+
+type NewDistributedQueryCampaignResultFunc func(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error
+
+type ListDistributedQueryCampaignResultsFunc func(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) ([]*fleet.DistributedQueryCampaignResult, error)
+
+type CleanupDistributedQueryCampaignResultsFunc func(ctx context.Context, before time.Time) (deleted uint, err error)
+
+type SaveQueryReportRowsFunc func(ctx context.Context, scheduledQueryID, hostID uint, rows []map[string]string, fetchedAt time.Time, maxRowsPerQuery int) error
+
+type ListQueryReportRowsFunc func(ctx context.Context, queryID uint, filter fleet.TeamFilter, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error)
+
+type NewDistributedQueryCampaignHostsFunc func(ctx context.Context, campaignID uint, hostIDs []uint) error
+
+type ListDistributedQueryCampaignHostsFunc func(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) ([]*fleet.DistributedQueryCampaignHost, error)
+
+type CountDistributedQueryCampaignHostsFunc func(ctx context.Context, campaignID uint) (*fleet.CampaignHostCounts, error)
+
+type CleanupWebhookDeliveriesFunc func(ctx context.Context, before time.Time) (deleted uint, err error)
+
+type ListDistributedQueryCampaignsToExportFunc func(ctx context.Context, now, exportedSince time.Time) ([]*fleet.DistributedQueryCampaign, error)
+
+type MarkDistributedQueryCampaignExportedFunc func(ctx context.Context, id uint, exportedAt time.Time) error
+
+type TeamsWithConfigFunc func(ctx context.Context) ([]*fleet.Team, error)
+
 type DataStore struct {
 	HealthCheckFunc        HealthCheckFunc
 	HealthCheckFuncInvoked bool
@@ -1083,6 +1167,99 @@ type DataStore struct {
 
 	InsertWindowsUpdatesFunc        InsertWindowsUpdatesFunc
 	InsertWindowsUpdatesFuncInvoked bool
+	NewWebhookDeliveryFunc          NewWebhookDeliveryFunc
+	NewWebhookDeliveryFuncInvoked   bool
+
+	WebhookDeliveryFunc        WebhookDeliveryFunc
+	WebhookDeliveryFuncInvoked bool
+
+	UpdateWebhookDeliveryFunc        UpdateWebhookDeliveryFunc
+	UpdateWebhookDeliveryFuncInvoked bool
+
+	ListWebhookDeliveriesFunc                      ListWebhookDeliveriesFunc
+	ListWebhookDeliveriesFuncInvoked               bool
+	NewPolicyAutomationTicketsFunc                 NewPolicyAutomationTicketsFunc
+	NewPolicyAutomationTicketsFuncInvoked          bool
+	ListOpenPolicyAutomationTicketsFunc            ListOpenPolicyAutomationTicketsFunc
+	ListOpenPolicyAutomationTicketsFuncInvoked     bool
+	ListPolicyAutomationTicketOpenHostsFunc        ListPolicyAutomationTicketOpenHostsFunc
+	ListPolicyAutomationTicketOpenHostsFuncInvoked bool
+	ResolvePolicyAutomationTicketFunc              ResolvePolicyAutomationTicketFunc
+	ResolvePolicyAutomationTicketFuncInvoked       bool
+	NewAPITokenFunc                                NewAPITokenFunc
+	NewAPITokenFuncInvoked                         bool
+	APITokenByKeyHashFunc                          APITokenByKeyHashFunc
+	APITokenByKeyHashFuncInvoked                   bool
+	ListAPITokensFunc                              ListAPITokensFunc
+	ListAPITokensFuncInvoked                       bool
+	DeleteAPITokenFunc                             DeleteAPITokenFunc
+	DeleteAPITokenFuncInvoked                      bool
+	MarkAPITokenUsedFunc                           MarkAPITokenUsedFunc
+	MarkAPITokenUsedFuncInvoked                    bool
+	ApplyCustomRoleSpecsFunc                       ApplyCustomRoleSpecsFunc
+	ApplyCustomRoleSpecsFuncInvoked                bool
+	ListCustomRolesFunc                            ListCustomRolesFunc
+	ListCustomRolesFuncInvoked                     bool
+	CustomRoleByNameFunc                           CustomRoleByNameFunc
+	CustomRoleByNameFuncInvoked                    bool
+	DeleteCustomRoleFunc                           DeleteCustomRoleFunc
+	DeleteCustomRoleFuncInvoked                    bool
+	UserTOTPFunc                                   UserTOTPFunc
+	UserTOTPFuncInvoked                            bool
+	SaveUserTOTPFunc                               SaveUserTOTPFunc
+	SaveUserTOTPFuncInvoked                        bool
+	DeleteUserTOTPFunc                             DeleteUserTOTPFunc
+	DeleteUserTOTPFuncInvoked                      bool
+	ReplaceUserTOTPRecoveryCodesFunc               ReplaceUserTOTPRecoveryCodesFunc
+	ReplaceUserTOTPRecoveryCodesFuncInvoked        bool
+	DeleteUserTOTPRecoveryCodeFunc                 DeleteUserTOTPRecoveryCodeFunc
+	DeleteUserTOTPRecoveryCodeFuncInvoked          bool
+	NewTOTPLoginChallengeFunc                      NewTOTPLoginChallengeFunc
+	NewTOTPLoginChallengeFuncInvoked               bool
+	TOTPLoginChallengeByTokenFunc                  TOTPLoginChallengeByTokenFunc
+	TOTPLoginChallengeByTokenFuncInvoked           bool
+	IncrementTOTPLoginChallengeAttemptsFunc        IncrementTOTPLoginChallengeAttemptsFunc
+	IncrementTOTPLoginChallengeAttemptsFuncInvoked bool
+	DeleteTOTPLoginChallengeFunc                   DeleteTOTPLoginChallengeFunc
+	DeleteTOTPLoginChallengeFuncInvoked            bool
+	MarkActivitiesAsStreamedFunc                   MarkActivitiesAsStreamedFunc
+	MarkActivitiesAsStreamedFuncInvoked            bool
+	DeleteActivitiesFunc                           DeleteActivitiesFunc
+	DeleteActivitiesFuncInvoked                    bool
+	NewPasswordHistoryFunc                         NewPasswordHistoryFunc
+	NewPasswordHistoryFuncInvoked                  bool
+
+	ListPasswordHistoryFunc                      ListPasswordHistoryFunc
+	ListPasswordHistoryFuncInvoked               bool
+	NewDistributedQueryCampaignResultFunc        NewDistributedQueryCampaignResultFunc
+	NewDistributedQueryCampaignResultFuncInvoked bool
+
+	ListDistributedQueryCampaignResultsFunc        ListDistributedQueryCampaignResultsFunc
+	ListDistributedQueryCampaignResultsFuncInvoked bool
+
+	CleanupDistributedQueryCampaignResultsFunc        CleanupDistributedQueryCampaignResultsFunc
+	CleanupDistributedQueryCampaignResultsFuncInvoked bool
+	SaveQueryReportRowsFunc                           SaveQueryReportRowsFunc
+	SaveQueryReportRowsFuncInvoked                    bool
+
+	ListQueryReportRowsFunc                     ListQueryReportRowsFunc
+	ListQueryReportRowsFuncInvoked              bool
+	NewDistributedQueryCampaignHostsFunc        NewDistributedQueryCampaignHostsFunc
+	NewDistributedQueryCampaignHostsFuncInvoked bool
+
+	ListDistributedQueryCampaignHostsFunc        ListDistributedQueryCampaignHostsFunc
+	ListDistributedQueryCampaignHostsFuncInvoked bool
+
+	CountDistributedQueryCampaignHostsFunc           CountDistributedQueryCampaignHostsFunc
+	CountDistributedQueryCampaignHostsFuncInvoked    bool
+	CleanupWebhookDeliveriesFunc                     CleanupWebhookDeliveriesFunc
+	CleanupWebhookDeliveriesFuncInvoked              bool
+	ListDistributedQueryCampaignsToExportFunc        ListDistributedQueryCampaignsToExportFunc
+	ListDistributedQueryCampaignsToExportFuncInvoked bool
+	MarkDistributedQueryCampaignExportedFunc         MarkDistributedQueryCampaignExportedFunc
+	MarkDistributedQueryCampaignExportedFuncInvoked  bool
+	TeamsWithConfigFunc                              TeamsWithConfigFunc
+	TeamsWithConfigFuncInvoked                       bool
 }
 
 func (s *DataStore) HealthCheck() error {
@@ -1480,9 +1657,9 @@ func (s *DataStore) AddHostsToTeam(ctx context.Context, teamID *uint, hostIDs []
 	return s.AddHostsToTeamFunc(ctx, teamID, hostIDs)
 }
 
-func (s *DataStore) TotalAndUnseenHostsSince(ctx context.Context, daysCount int) (total int, unseen int, err error) {
+func (s *DataStore) TotalAndUnseenHostsSince(ctx context.Context, teamID *uint, daysCount int) (total int, unseen int, err error) {
 	s.TotalAndUnseenHostsSinceFuncInvoked = true
-	return s.TotalAndUnseenHostsSinceFunc(ctx, daysCount)
+	return s.TotalAndUnseenHostsSinceFunc(ctx, teamID, daysCount)
 }
 
 func (s *DataStore) DeleteHosts(ctx context.Context, ids []uint) error {
@@ -1870,7 +2047,7 @@ func (s *DataStore) NewActivity(ctx context.Context, user *fleet.User, activityT
 	return s.NewActivityFunc(ctx, user, activityType, details)
 }
 
-func (s *DataStore) ListActivities(ctx context.Context, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+func (s *DataStore) ListActivities(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
 	s.ListActivitiesFuncInvoked = true
 	return s.ListActivitiesFunc(ctx, opt)
 }
@@ -2154,3 +2331,213 @@ func (s *DataStore) InsertWindowsUpdates(ctx context.Context, hostID uint, updat
 	s.InsertWindowsUpdatesFuncInvoked = true
 	return s.InsertWindowsUpdatesFunc(ctx, hostID, updates)
 }
+
+func (s *DataStore) NewWebhookDelivery(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+	s.NewWebhookDeliveryFuncInvoked = true
+	return s.NewWebhookDeliveryFunc(ctx, delivery)
+}
+
+func (s *DataStore) WebhookDelivery(ctx context.Context, id uint) (*fleet.WebhookDelivery, error) {
+	s.WebhookDeliveryFuncInvoked = true
+	return s.WebhookDeliveryFunc(ctx, id)
+}
+
+func (s *DataStore) UpdateWebhookDelivery(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+	s.UpdateWebhookDeliveryFuncInvoked = true
+	return s.UpdateWebhookDeliveryFunc(ctx, delivery)
+}
+
+func (s *DataStore) ListWebhookDeliveries(ctx context.Context, opts fleet.WebhookDeliveryListOptions) ([]*fleet.WebhookDelivery, error) {
+	s.ListWebhookDeliveriesFuncInvoked = true
+	return s.ListWebhookDeliveriesFunc(ctx, opts)
+}
+
+func (s *DataStore) NewPolicyAutomationTickets(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+	s.NewPolicyAutomationTicketsFuncInvoked = true
+	return s.NewPolicyAutomationTicketsFunc(ctx, integration, ticketID, policyID, teamID, hostIDs)
+}
+
+func (s *DataStore) ListOpenPolicyAutomationTickets(ctx context.Context, policyID uint, hostIDs []uint) ([]*fleet.PolicyAutomationTicket, error) {
+	s.ListOpenPolicyAutomationTicketsFuncInvoked = true
+	return s.ListOpenPolicyAutomationTicketsFunc(ctx, policyID, hostIDs)
+}
+
+func (s *DataStore) ListPolicyAutomationTicketOpenHosts(ctx context.Context, integration, ticketID string) ([]uint, error) {
+	s.ListPolicyAutomationTicketOpenHostsFuncInvoked = true
+	return s.ListPolicyAutomationTicketOpenHostsFunc(ctx, integration, ticketID)
+}
+
+func (s *DataStore) ResolvePolicyAutomationTicket(ctx context.Context, integration, ticketID string, hostID uint) error {
+	s.ResolvePolicyAutomationTicketFuncInvoked = true
+	return s.ResolvePolicyAutomationTicketFunc(ctx, integration, ticketID, hostID)
+}
+
+func (s *DataStore) NewAPIToken(ctx context.Context, token *fleet.APIToken) (*fleet.APIToken, error) {
+	s.NewAPITokenFuncInvoked = true
+	return s.NewAPITokenFunc(ctx, token)
+}
+
+func (s *DataStore) APITokenByKeyHash(ctx context.Context, keyHash string) (*fleet.APIToken, error) {
+	s.APITokenByKeyHashFuncInvoked = true
+	return s.APITokenByKeyHashFunc(ctx, keyHash)
+}
+
+func (s *DataStore) ListAPITokens(ctx context.Context, userID uint) ([]*fleet.APIToken, error) {
+	s.ListAPITokensFuncInvoked = true
+	return s.ListAPITokensFunc(ctx, userID)
+}
+
+func (s *DataStore) DeleteAPIToken(ctx context.Context, userID uint, id uint) error {
+	s.DeleteAPITokenFuncInvoked = true
+	return s.DeleteAPITokenFunc(ctx, userID, id)
+}
+
+func (s *DataStore) MarkAPITokenUsed(ctx context.Context, id uint) error {
+	s.MarkAPITokenUsedFuncInvoked = true
+	return s.MarkAPITokenUsedFunc(ctx, id)
+}
+
+func (s *DataStore) ApplyCustomRoleSpecs(ctx context.Context, specs []*fleet.CustomRoleSpec) error {
+	s.ApplyCustomRoleSpecsFuncInvoked = true
+	return s.ApplyCustomRoleSpecsFunc(ctx, specs)
+}
+
+func (s *DataStore) ListCustomRoles(ctx context.Context) ([]*fleet.CustomRole, error) {
+	s.ListCustomRolesFuncInvoked = true
+	return s.ListCustomRolesFunc(ctx)
+}
+
+func (s *DataStore) CustomRoleByName(ctx context.Context, name string) (*fleet.CustomRole, error) {
+	s.CustomRoleByNameFuncInvoked = true
+	return s.CustomRoleByNameFunc(ctx, name)
+}
+
+func (s *DataStore) DeleteCustomRole(ctx context.Context, name string) error {
+	s.DeleteCustomRoleFuncInvoked = true
+	return s.DeleteCustomRoleFunc(ctx, name)
+}
+
+func (s *DataStore) UserTOTP(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+	s.UserTOTPFuncInvoked = true
+	return s.UserTOTPFunc(ctx, userID)
+}
+
+func (s *DataStore) SaveUserTOTP(ctx context.Context, totp *fleet.UserTOTP) error {
+	s.SaveUserTOTPFuncInvoked = true
+	return s.SaveUserTOTPFunc(ctx, totp)
+}
+
+func (s *DataStore) DeleteUserTOTP(ctx context.Context, userID uint) error {
+	s.DeleteUserTOTPFuncInvoked = true
+	return s.DeleteUserTOTPFunc(ctx, userID)
+}
+
+func (s *DataStore) ReplaceUserTOTPRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	s.ReplaceUserTOTPRecoveryCodesFuncInvoked = true
+	return s.ReplaceUserTOTPRecoveryCodesFunc(ctx, userID, codeHashes)
+}
+
+func (s *DataStore) DeleteUserTOTPRecoveryCode(ctx context.Context, userID uint, codeHash string) error {
+	s.DeleteUserTOTPRecoveryCodeFuncInvoked = true
+	return s.DeleteUserTOTPRecoveryCodeFunc(ctx, userID, codeHash)
+}
+
+func (s *DataStore) NewTOTPLoginChallenge(ctx context.Context, challenge *fleet.TOTPLoginChallenge) error {
+	s.NewTOTPLoginChallengeFuncInvoked = true
+	return s.NewTOTPLoginChallengeFunc(ctx, challenge)
+}
+
+func (s *DataStore) TOTPLoginChallengeByToken(ctx context.Context, token string) (*fleet.TOTPLoginChallenge, error) {
+	s.TOTPLoginChallengeByTokenFuncInvoked = true
+	return s.TOTPLoginChallengeByTokenFunc(ctx, token)
+}
+
+func (s *DataStore) IncrementTOTPLoginChallengeAttempts(ctx context.Context, token string) error {
+	s.IncrementTOTPLoginChallengeAttemptsFuncInvoked = true
+	return s.IncrementTOTPLoginChallengeAttemptsFunc(ctx, token)
+}
+
+func (s *DataStore) DeleteTOTPLoginChallenge(ctx context.Context, token string) error {
+	s.DeleteTOTPLoginChallengeFuncInvoked = true
+	return s.DeleteTOTPLoginChallengeFunc(ctx, token)
+}
+
+func (s *DataStore) MarkActivitiesAsStreamed(ctx context.Context, activityIDs []uint) error {
+	s.MarkActivitiesAsStreamedFuncInvoked = true
+	return s.MarkActivitiesAsStreamedFunc(ctx, activityIDs)
+}
+
+func (s *DataStore) DeleteActivities(ctx context.Context, activityIDs []uint) error {
+	s.DeleteActivitiesFuncInvoked = true
+	return s.DeleteActivitiesFunc(ctx, activityIDs)
+}
+
+func (s *DataStore) NewPasswordHistory(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+	s.NewPasswordHistoryFuncInvoked = true
+	return s.NewPasswordHistoryFunc(ctx, userID, password, salt, keep)
+}
+
+func (s *DataStore) ListPasswordHistory(ctx context.Context, userID uint, limit int) ([]*fleet.PasswordHistoryEntry, error) {
+	s.ListPasswordHistoryFuncInvoked = true
+	return s.ListPasswordHistoryFunc(ctx, userID, limit)
+}
+
+func (s *DataStore) NewDistributedQueryCampaignResult(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error {
+	s.NewDistributedQueryCampaignResultFuncInvoked = true
+	return s.NewDistributedQueryCampaignResultFunc(ctx, result, maxCampaignRows)
+}
+
+func (s *DataStore) ListDistributedQueryCampaignResults(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) ([]*fleet.DistributedQueryCampaignResult, error) {
+	s.ListDistributedQueryCampaignResultsFuncInvoked = true
+	return s.ListDistributedQueryCampaignResultsFunc(ctx, campaignID, opt)
+}
+
+func (s *DataStore) CleanupDistributedQueryCampaignResults(ctx context.Context, before time.Time) (deleted uint, err error) {
+	s.CleanupDistributedQueryCampaignResultsFuncInvoked = true
+	return s.CleanupDistributedQueryCampaignResultsFunc(ctx, before)
+}
+
+func (s *DataStore) SaveQueryReportRows(ctx context.Context, scheduledQueryID, hostID uint, rows []map[string]string, fetchedAt time.Time, maxRowsPerQuery int) error {
+	s.SaveQueryReportRowsFuncInvoked = true
+	return s.SaveQueryReportRowsFunc(ctx, scheduledQueryID, hostID, rows, fetchedAt, maxRowsPerQuery)
+}
+
+func (s *DataStore) ListQueryReportRows(ctx context.Context, queryID uint, filter fleet.TeamFilter, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error) {
+	s.ListQueryReportRowsFuncInvoked = true
+	return s.ListQueryReportRowsFunc(ctx, queryID, filter, opt)
+}
+
+func (s *DataStore) NewDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, hostIDs []uint) error {
+	s.NewDistributedQueryCampaignHostsFuncInvoked = true
+	return s.NewDistributedQueryCampaignHostsFunc(ctx, campaignID, hostIDs)
+}
+
+func (s *DataStore) ListDistributedQueryCampaignHosts(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) ([]*fleet.DistributedQueryCampaignHost, error) {
+	s.ListDistributedQueryCampaignHostsFuncInvoked = true
+	return s.ListDistributedQueryCampaignHostsFunc(ctx, campaignID, opt)
+}
+
+func (s *DataStore) CountDistributedQueryCampaignHosts(ctx context.Context, campaignID uint) (*fleet.CampaignHostCounts, error) {
+	s.CountDistributedQueryCampaignHostsFuncInvoked = true
+	return s.CountDistributedQueryCampaignHostsFunc(ctx, campaignID)
+}
+
+func (s *DataStore) CleanupWebhookDeliveries(ctx context.Context, before time.Time) (deleted uint, err error) {
+	s.CleanupWebhookDeliveriesFuncInvoked = true
+	return s.CleanupWebhookDeliveriesFunc(ctx, before)
+}
+
+func (s *DataStore) ListDistributedQueryCampaignsToExport(ctx context.Context, now, exportedSince time.Time) ([]*fleet.DistributedQueryCampaign, error) {
+	s.ListDistributedQueryCampaignsToExportFuncInvoked = true
+	return s.ListDistributedQueryCampaignsToExportFunc(ctx, now, exportedSince)
+}
+
+func (s *DataStore) MarkDistributedQueryCampaignExported(ctx context.Context, id uint, exportedAt time.Time) error {
+	s.MarkDistributedQueryCampaignExportedFuncInvoked = true
+	return s.MarkDistributedQueryCampaignExportedFunc(ctx, id, exportedAt)
+}
+
+func (s *DataStore) TeamsWithConfig(ctx context.Context) ([]*fleet.Team, error) {
+	s.TeamsWithConfigFuncInvoked = true
+	return s.TeamsWithConfigFunc(ctx)
+}
@@ -22,6 +22,12 @@ const (
 	FailingPolicyWebhook FailingPolicyAutomationType = "webhook"
 	FailingPolicyJira    FailingPolicyAutomationType = "jira"
 	FailingPolicyZendesk FailingPolicyAutomationType = "zendesk"
+	// FailingPolicyWebhookIntegration is the generic webhook integration, as
+	// opposed to FailingPolicyWebhook which is the webhook configured in the
+	// webhook settings.
+	FailingPolicyWebhookIntegration FailingPolicyAutomationType = "webhook_integration"
+	FailingPolicySlack              FailingPolicyAutomationType = "slack"
+	FailingPolicyMicrosoftTeams     FailingPolicyAutomationType = "microsoft_teams"
 )
 
 // FailingPolicyAutomationConfig holds the configuration for proessing a
@@ -30,6 +36,7 @@ type FailingPolicyAutomationConfig struct {
 	AutomationType FailingPolicyAutomationType
 	PolicyIDs      map[uint]bool
 	WebhookURL     *url.URL // for webhook automation type only
+	WebhookSecret  string   // for webhook automation type only
 	HostBatchSize  int      // for webhook automation type only
 }
 
@@ -74,6 +81,7 @@ func TriggerFailingPoliciesAutomation(
 				return ctxerr.Wrapf(ctx, err, "parse global webhook url: %s", globalSettings.DestinationURL)
 			}
 			globalCfg.WebhookURL = wurl
+			globalCfg.WebhookSecret = globalSettings.Secret
 			globalCfg.HostBatchSize = globalSettings.HostBatchSize
 		}
 	}
@@ -188,6 +196,7 @@ func makeTeamConfigCache(ds fleet.Datastore, globalIntgs fleet.Integrations) fun
 					return cfg, ctxerr.Wrapf(ctx, err, "parse webhook url: %s", settings.DestinationURL)
 				}
 				teamCfg.WebhookURL = wurl
+				teamCfg.WebhookSecret = settings.Secret
 				teamCfg.HostBatchSize = settings.HostBatchSize
 			}
 		}
@@ -217,5 +226,26 @@ func getActiveAutomation(webhook fleet.FailingPoliciesWebhookSettings, intgs fle
 			return FailingPolicyZendesk
 		}
 	}
+
+	// check for generic webhook integrations
+	for _, w := range intgs.Webhook {
+		if w.EnableFailingPolicies {
+			return FailingPolicyWebhookIntegration
+		}
+	}
+
+	// check for Slack integrations
+	for _, sl := range intgs.Slack {
+		if sl.EnableFailingPolicies {
+			return FailingPolicySlack
+		}
+	}
+
+	// check for Microsoft Teams integrations
+	for _, mt := range intgs.MicrosoftTeams {
+		if mt.EnableFailingPolicies {
+			return FailingPolicyMicrosoftTeams
+		}
+	}
 	return ""
 }
@@ -16,6 +16,11 @@ func Int(x int) *int {
 	return &x
 }
 
+// Int64 returns a pointer to the provided int64.
+func Int64(x int64) *int64 {
+	return &x
+}
+
 // Uint returns a pointer to the provided uint.
 func Uint(x uint) *uint {
 	return &x
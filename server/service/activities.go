@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
+	"time"
 
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 )
 
@@ -11,7 +15,11 @@ import (
 ////////////////////////////////////////////////////////////////////////////////
 
 type listActivitiesRequest struct {
-	ListOptions fleet.ListOptions `url:"list_options"`
+	ListOptions    fleet.ListOptions `url:"list_options"`
+	ActivityType   string            `query:"activity_type,optional"`
+	ActorID        *uint             `query:"actor_id,optional"`
+	StartCreatedAt string            `query:"start_created_at,optional"`
+	EndCreatedAt   string            `query:"end_created_at,optional"`
 }
 
 type listActivitiesResponse struct {
@@ -23,7 +31,21 @@ func (r listActivitiesResponse) error() error { return r.Err }
 
 func listActivitiesEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
 	req := request.(*listActivitiesRequest)
-	activities, err := svc.ListActivities(ctx, req.ListOptions)
+
+	opt := fleet.ListActivitiesOptions{
+		ListOptions:  req.ListOptions,
+		ActivityType: req.ActivityType,
+		ActorID:      req.ActorID,
+	}
+	var err error
+	if opt.StartCreatedAt, err = parseActivityTime(ctx, "start_created_at", req.StartCreatedAt); err != nil {
+		return listActivitiesResponse{Err: err}, nil
+	}
+	if opt.EndCreatedAt, err = parseActivityTime(ctx, "end_created_at", req.EndCreatedAt); err != nil {
+		return listActivitiesResponse{Err: err}, nil
+	}
+
+	activities, err := svc.ListActivities(ctx, opt)
 	if err != nil {
 		return listActivitiesResponse{Err: err}, nil
 	}
@@ -31,10 +53,153 @@ func listActivitiesEndpoint(ctx context.Context, request interface{}, svc fleet.
 	return listActivitiesResponse{Activities: activities}, nil
 }
 
+// parseActivityTime parses the optional RFC3339 time of the named query
+// parameter.
+func parseActivityTime(ctx context.Context, name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError(name, "must be a RFC3339 timestamp, e.g. 2022-09-10T15:04:05Z"))
+	}
+	return &t, nil
+}
+
 // ListActivities returns a slice of activities for the whole organization
-func (svc *Service) ListActivities(ctx context.Context, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+func (svc *Service) ListActivities(ctx context.Context, opt fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
 	if err := svc.authz.Authorize(ctx, &fleet.Activity{}, fleet.ActionRead); err != nil {
 		return nil, err
 	}
 	return svc.ds.ListActivities(ctx, opt)
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// List archived activity months
+////////////////////////////////////////////////////////////////////////////////
+
+type listArchivedActivityMonthsResponse struct {
+	Months []string `json:"months"`
+	Err    error    `json:"error,omitempty"`
+}
+
+func (r listArchivedActivityMonthsResponse) error() error { return r.Err }
+
+func listArchivedActivityMonthsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	months, err := svc.ListArchivedActivityMonths(ctx)
+	if err != nil {
+		return listArchivedActivityMonthsResponse{Err: err}, nil
+	}
+	return listArchivedActivityMonthsResponse{Months: months}, nil
+}
+
+// ListArchivedActivityMonths returns the months that have archived
+// activities, oldest first. It is empty if the activity archive is not
+// configured.
+func (svc *Service) ListArchivedActivityMonths(ctx context.Context) ([]string, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Activity{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if svc.activityArchive == nil {
+		return []string{}, nil
+	}
+	return svc.activityArchive.ListArchivedActivityMonths(ctx)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List archived activities
+////////////////////////////////////////////////////////////////////////////////
+
+// defaultArchivedActivitiesPerPage is the number of archived activities
+// returned if per_page is not set, like the default limit of the datastore
+// lists.
+const defaultArchivedActivitiesPerPage = 1000
+
+type listArchivedActivitiesRequest struct {
+	Month       string            `url:"month"`
+	ListOptions fleet.ListOptions `url:"list_options"`
+}
+
+func listArchivedActivitiesEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listArchivedActivitiesRequest)
+	activities, err := svc.ListArchivedActivities(ctx, req.Month, req.ListOptions)
+	if err != nil {
+		return listActivitiesResponse{Err: err}, nil
+	}
+	return listActivitiesResponse{Activities: activities}, nil
+}
+
+// ListArchivedActivities returns a page of the activities archived for the
+// month (e.g. "2022-08"), ordered by ID.
+func (svc *Service) ListArchivedActivities(ctx context.Context, month string, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Activity{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	if _, err := time.Parse(fleet.ActivityArchiveMonthFormat, month); err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("month", "must be a month in the YYYY-MM format, e.g. 2022-08"))
+	}
+	if svc.activityArchive == nil {
+		return []*fleet.Activity{}, nil
+	}
+
+	if opt.PerPage == 0 {
+		opt.PerPage = defaultArchivedActivitiesPerPage
+	}
+	return svc.activityArchive.ListArchivedActivities(ctx, month, opt)
+}
+
+// activityDiff returns the fields that differ between before and after, as
+// marshaled to JSON, to be stored in the "before" and "after" details of an
+// activity. Nested objects are compared recursively so that only the changed
+// fields are returned, a field missing on one side is returned as nil.
+func activityDiff(before, after interface{}) (changedBefore, changedAfter map[string]interface{}, err error) {
+	toMap := func(v interface{}) (map[string]interface{}, error) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	beforeMap, err := toMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterMap, err := toMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+	changedBefore, changedAfter = diffMaps(beforeMap, afterMap)
+	return changedBefore, changedAfter, nil
+}
+
+func diffMaps(before, after map[string]interface{}) (map[string]interface{}, map[string]interface{}) {
+	changedBefore, changedAfter := make(map[string]interface{}), make(map[string]interface{})
+	diffKey := func(k string) {
+		bv, av := before[k], after[k]
+		if reflect.DeepEqual(bv, av) {
+			return
+		}
+		bm, bIsMap := bv.(map[string]interface{})
+		am, aIsMap := av.(map[string]interface{})
+		if bIsMap && aIsMap {
+			changedBefore[k], changedAfter[k] = diffMaps(bm, am)
+			return
+		}
+		changedBefore[k], changedAfter[k] = bv, av
+	}
+
+	for k := range before {
+		diffKey(k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			diffKey(k)
+		}
+	}
+	return changedBefore, changedAfter
+}
@@ -18,7 +18,7 @@ func TestListActivities(t *testing.T) {
 	globalUsers := []*fleet.User{test.UserAdmin, test.UserMaintainer, test.UserObserver}
 	teamUsers := []*fleet.User{test.UserTeamAdminTeam1, test.UserTeamMaintainerTeam1, test.UserTeamObserverTeam1}
 
-	ds.ListActivitiesFunc = func(ctx context.Context, opts fleet.ListOptions) ([]*fleet.Activity, error) {
+	ds.ListActivitiesFunc = func(ctx context.Context, opts fleet.ListActivitiesOptions) ([]*fleet.Activity, error) {
 		return []*fleet.Activity{
 			{ID: 1},
 			{ID: 2},
@@ -27,25 +27,70 @@ func TestListActivities(t *testing.T) {
 
 	// any global user can read activities
 	for _, u := range globalUsers {
-		activities, err := svc.ListActivities(test.UserContext(u), fleet.ListOptions{})
+		activities, err := svc.ListActivities(test.UserContext(u), fleet.ListActivitiesOptions{})
 		require.NoError(t, err)
 		require.Len(t, activities, 2)
 	}
 
 	// team users cannot read activities
 	for _, u := range teamUsers {
-		_, err := svc.ListActivities(test.UserContext(u), fleet.ListOptions{})
+		_, err := svc.ListActivities(test.UserContext(u), fleet.ListActivitiesOptions{})
 		require.Error(t, err)
 		require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
 	}
 
 	// user with no roles cannot read activities
-	_, err := svc.ListActivities(test.UserContext(test.UserNoRoles), fleet.ListOptions{})
+	_, err := svc.ListActivities(test.UserContext(test.UserNoRoles), fleet.ListActivitiesOptions{})
 	require.Error(t, err)
 	require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
 
 	// no user in context
-	_, err = svc.ListActivities(context.Background(), fleet.ListOptions{})
+	_, err = svc.ListActivities(context.Background(), fleet.ListActivitiesOptions{})
 	require.Error(t, err)
 	require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
 }
+
+func TestListArchivedActivities(t *testing.T) {
+	ds := new(mock.Store)
+	archive := new(mock.ActivityArchiveStore)
+	svc := newTestService(t, ds, nil, nil, &TestServerOpts{ActivityArchive: archive})
+
+	archive.ListArchivedActivityMonthsFunc = func(ctx context.Context) ([]string, error) {
+		return []string{"2022-07", "2022-08"}, nil
+	}
+	archive.ListArchivedActivitiesFunc = func(ctx context.Context, month string, opt fleet.ListOptions) ([]*fleet.Activity, error) {
+		require.Equal(t, "2022-08", month)
+		require.Equal(t, uint(defaultArchivedActivitiesPerPage), opt.PerPage)
+		return []*fleet.Activity{{ID: 1}}, nil
+	}
+
+	ctx := test.UserContext(test.UserObserver)
+	months, err := svc.ListArchivedActivityMonths(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2022-07", "2022-08"}, months)
+
+	activities, err := svc.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, activities, 1)
+
+	_, err = svc.ListArchivedActivities(ctx, "August", fleet.ListOptions{})
+	var invalid *fleet.InvalidArgumentError
+	require.ErrorAs(t, err, &invalid)
+
+	// team users cannot read the archived activities
+	_, err = svc.ListArchivedActivityMonths(test.UserContext(test.UserTeamAdminTeam1))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
+	_, err = svc.ListArchivedActivities(test.UserContext(test.UserTeamAdminTeam1), "2022-08", fleet.ListOptions{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
+
+	// without archive, there are no archived activities
+	svc = newTestService(t, ds, nil, nil)
+	months, err = svc.ListArchivedActivityMonths(ctx)
+	require.NoError(t, err)
+	require.Empty(t, months)
+	activities, err = svc.ListArchivedActivities(ctx, "2022-08", fleet.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, activities)
+}
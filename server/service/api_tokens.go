@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Create API Token
+////////////////////////////////////////////////////////////////////////////////
+
+type createAPITokenRequest struct {
+	UserID uint `url:"id"`
+	fleet.APITokenPayload
+}
+
+type createAPITokenResponse struct {
+	APIToken *fleet.APIToken `json:"api_token,omitempty"`
+	Err      error           `json:"error,omitempty"`
+}
+
+func (r createAPITokenResponse) error() error { return r.Err }
+
+func createAPITokenEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*createAPITokenRequest)
+	token, err := svc.NewAPIToken(ctx, req.UserID, req.APITokenPayload)
+	if err != nil {
+		return createAPITokenResponse{Err: err}, nil
+	}
+	return createAPITokenResponse{APIToken: token}, nil
+}
+
+func (svc *Service) NewAPIToken(ctx context.Context, userID uint, p fleet.APITokenPayload) (*fleet.APIToken, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.APIToken{UserID: userID}, fleet.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, fleet.ErrNoContext
+	}
+	// requests authenticated with the token act as the user, so even admins
+	// can't create tokens for other users.
+	if !vc.IsUserID(userID) {
+		return nil, authz.ForbiddenWithInternal("API tokens can only be created by their user", vc.User, &fleet.APIToken{UserID: userID}, fleet.ActionWrite)
+	}
+
+	if p.Name == "" {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("name", "API token name cannot be empty"))
+	}
+	if err := p.Scopes.Validate(); err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("scopes", err.Error()))
+	}
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(svc.clock.Now()) {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("expires_at", "API token expiration must be in the future"))
+	}
+
+	token := &fleet.APIToken{
+		UserID:    userID,
+		Name:      p.Name,
+		TeamID:    p.TeamID,
+		Scopes:    p.Scopes,
+		ExpiresAt: p.ExpiresAt,
+	}
+	if p.TeamID != nil {
+		if _, err := svc.ds.Team(ctx, *p.TeamID); err != nil {
+			if fleet.IsNotFound(err) {
+				return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("team_id", "team does not exist"))
+			}
+			return nil, ctxerr.Wrap(ctx, err, "get API token team")
+		}
+		if len(token.ScopedUser(vc.User).Teams) == 0 {
+			return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("team_id", "user does not have a role on the team"))
+		}
+	}
+
+	rawKey := make([]byte, svc.config.Session.KeySize)
+	if _, err := rand.Read(rawKey); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "generate API token key")
+	}
+	key := fleet.APITokenPrefix + base64.RawURLEncoding.EncodeToString(rawKey)
+	token.KeyHash = fleet.HashAPITokenKey(key)
+
+	token, err := svc.ds.NewAPIToken(ctx, token)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create API token")
+	}
+	token.Key = key
+	return token, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List API Tokens
+////////////////////////////////////////////////////////////////////////////////
+
+type listAPITokensRequest struct {
+	UserID uint `url:"id"`
+}
+
+type listAPITokensResponse struct {
+	APITokens []*fleet.APIToken `json:"api_tokens"`
+	Err       error             `json:"error,omitempty"`
+}
+
+func (r listAPITokensResponse) error() error { return r.Err }
+
+func listAPITokensEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listAPITokensRequest)
+	tokens, err := svc.ListAPITokens(ctx, req.UserID)
+	if err != nil {
+		return listAPITokensResponse{Err: err}, nil
+	}
+	return listAPITokensResponse{APITokens: tokens}, nil
+}
+
+func (svc *Service) ListAPITokens(ctx context.Context, userID uint) ([]*fleet.APIToken, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.APIToken{UserID: userID}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+	return svc.ds.ListAPITokens(ctx, userID)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Delete API Token
+////////////////////////////////////////////////////////////////////////////////
+
+type deleteAPITokenRequest struct {
+	UserID  uint `url:"id"`
+	TokenID uint `url:"token_id"`
+}
+
+type deleteAPITokenResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r deleteAPITokenResponse) error() error { return r.Err }
+
+func deleteAPITokenEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*deleteAPITokenRequest)
+	if err := svc.DeleteAPIToken(ctx, req.UserID, req.TokenID); err != nil {
+		return deleteAPITokenResponse{Err: err}, nil
+	}
+	return deleteAPITokenResponse{}, nil
+}
+
+func (svc *Service) DeleteAPIToken(ctx context.Context, userID, id uint) error {
+	if err := svc.authz.Authorize(ctx, &fleet.APIToken{UserID: userID}, fleet.ActionWrite); err != nil {
+		return err
+	}
+	return svc.ds.DeleteAPIToken(ctx, userID, id)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Authenticate with an API Token
+////////////////////////////////////////////////////////////////////////////////
+
+// apiTokenUsedInterval is the precision of the last use time of the API
+// tokens, so that a token used for many requests is not updated every time.
+const apiTokenUsedInterval = time.Minute
+
+func (svc *Service) GetAPITokenByKey(ctx context.Context, key string) (*fleet.APIToken, error) {
+	token, err := svc.ds.APITokenByKeyHash(ctx, fleet.HashAPITokenKey(key))
+	if err != nil {
+		return nil, err
+	}
+	now := svc.clock.Now()
+	if token.IsExpired(now) {
+		return nil, fleet.NewAuthRequiredError("expired API token")
+	}
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenUsedInterval {
+		if err := svc.ds.MarkAPITokenUsed(ctx, token.ID); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "mark API token used")
+		}
+	}
+	return token, nil
+}
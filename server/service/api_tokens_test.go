@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/clock"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAPITokenByKeyMarkUsed(t *testing.T) {
+	ds := new(mock.Store)
+	mockClock := clock.NewMockClock()
+	svc := &Service{ds: ds, clock: mockClock}
+
+	var lastUsedAt *time.Time
+	ds.APITokenByKeyHashFunc = func(ctx context.Context, hash string) (*fleet.APIToken, error) {
+		return &fleet.APIToken{ID: 1, LastUsedAt: lastUsedAt}, nil
+	}
+	ds.MarkAPITokenUsedFunc = func(ctx context.Context, id uint) error {
+		require.Equal(t, uint(1), id)
+		now := mockClock.Now()
+		lastUsedAt = &now
+		return nil
+	}
+
+	// the first use is recorded
+	_, err := svc.GetAPITokenByKey(context.Background(), "key")
+	require.NoError(t, err)
+	require.True(t, ds.MarkAPITokenUsedFuncInvoked)
+
+	// not again within the interval
+	ds.MarkAPITokenUsedFuncInvoked = false
+	mockClock.AddTime(apiTokenUsedInterval - time.Second)
+	_, err = svc.GetAPITokenByKey(context.Background(), "key")
+	require.NoError(t, err)
+	require.False(t, ds.MarkAPITokenUsedFuncInvoked)
+
+	// but once it elapsed
+	mockClock.AddTime(time.Second)
+	_, err = svc.GetAPITokenByKey(context.Background(), "key")
+	require.NoError(t, err)
+	require.True(t, ds.MarkAPITokenUsedFuncInvoked)
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/url"
@@ -92,13 +93,21 @@ func getAppConfigEndpoint(ctx context.Context, request interface{}, svc fleet.Se
 	var smtpSettings fleet.SMTPSettings
 	var ssoSettings fleet.SSOSettings
 	var hostExpirySettings fleet.HostExpirySettings
+	var activityExpirySettings fleet.ActivityExpirySettings
+	var liveQueryResultsSettings fleet.LiveQueryResultsSettings
 	var agentOptions *json.RawMessage
-	// only admin can see smtp, sso, and host expiry settings
+	integrations := config.Integrations
+	// only admin can see smtp, sso, host and activity expiry, and live query
+	// results settings, and the URLs of the Slack and Microsoft Teams webhooks
 	if vc.User.GlobalRole != nil && *vc.User.GlobalRole == fleet.RoleAdmin {
 		smtpSettings = config.SMTPSettings
 		ssoSettings = config.SSOSettings
 		hostExpirySettings = config.HostExpirySettings
+		activityExpirySettings = config.ActivityExpirySettings
+		liveQueryResultsSettings = config.LiveQueryResultsSettings
 		agentOptions = config.AgentOptions
+	} else {
+		integrations = obfuscateIntegrationURLs(integrations)
 	}
 
 	transparencyURL := fleet.DefaultTransparencyURL
@@ -115,16 +124,21 @@ func getAppConfigEndpoint(ctx context.Context, request interface{}, svc fleet.Se
 			ServerSettings:        config.ServerSettings,
 			Features:              features,
 			VulnerabilitySettings: config.VulnerabilitySettings,
+			TwoFactorAuthSettings: config.TwoFactorAuthSettings,
+			PasswordPolicy:        config.PasswordPolicy,
+			QueryReportsSettings:  config.QueryReportsSettings,
 
-			SMTPSettings:       smtpSettings,
-			SSOSettings:        ssoSettings,
-			HostExpirySettings: hostExpirySettings,
-			AgentOptions:       agentOptions,
+			SMTPSettings:             smtpSettings,
+			SSOSettings:              ssoSettings,
+			HostExpirySettings:       hostExpirySettings,
+			ActivityExpirySettings:   activityExpirySettings,
+			LiveQueryResultsSettings: liveQueryResultsSettings,
+			AgentOptions:             agentOptions,
 
 			FleetDesktop: fleetDesktop,
 
 			WebhookSettings: config.WebhookSettings,
-			Integrations:    config.Integrations,
+			Integrations:    integrations,
 		},
 		appConfigResponseFields: appConfigResponseFields{
 			UpdateInterval:  updateIntervalConfig,
@@ -152,11 +166,19 @@ func (svc *Service) AppConfig(ctx context.Context) (*fleet.AppConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	obfuscateAppConfig(ac)
+	return ac, nil
+}
 
+// obfuscateAppConfig masks the secrets of the app config.
+func obfuscateAppConfig(ac *fleet.AppConfig) {
 	if ac.SMTPSettings.SMTPPassword != "" {
 		ac.SMTPSettings.SMTPPassword = fleet.MaskedPassword
 	}
 
+	ac.WebhookSettings.MaskSecrets()
+	ac.SSOSettings.MaskSecrets()
+
 	for _, jiraIntegration := range ac.Integrations.Jira {
 		jiraIntegration.APIToken = fleet.MaskedPassword
 	}
@@ -165,7 +187,91 @@ func (svc *Service) AppConfig(ctx context.Context) (*fleet.AppConfig, error) {
 		zdIntegration.APIToken = fleet.MaskedPassword
 	}
 
-	return ac, nil
+	for _, whIntegration := range ac.Integrations.Webhook {
+		if whIntegration.Secret != "" {
+			whIntegration.Secret = fleet.MaskedPassword
+		}
+		if len(whIntegration.Headers) > 0 {
+			// do not modify the original map, header names are preserved but
+			// values are masked.
+			masked := make(map[string]string, len(whIntegration.Headers))
+			for k := range whIntegration.Headers {
+				masked[k] = fleet.MaskedPassword
+			}
+			whIntegration.Headers = masked
+		}
+	}
+
+	for _, slIntegration := range ac.Integrations.Slack {
+		if slIntegration.APIToken != "" {
+			slIntegration.APIToken = fleet.MaskedPassword
+		}
+	}
+}
+
+// obfuscateIntegrationURLs returns a copy of the integrations with the
+// URLs of the Slack and Microsoft Teams webhooks masked, as anyone knowing
+// those URLs can post to the channels.
+func obfuscateIntegrationURLs(intgs fleet.Integrations) fleet.Integrations {
+	var slack []*fleet.SlackIntegration
+	for _, intg := range intgs.Slack {
+		masked := *intg
+		if masked.WebhookURL != "" {
+			masked.WebhookURL = fleet.MaskedPassword
+		}
+		slack = append(slack, &masked)
+	}
+	intgs.Slack = slack
+
+	var msTeams []*fleet.MicrosoftTeamsIntegration
+	for _, intg := range intgs.MicrosoftTeams {
+		masked := *intg
+		masked.URL = fleet.MaskedPassword
+		msTeams = append(msTeams, &masked)
+	}
+	intgs.MicrosoftTeams = msTeams
+	return intgs
+}
+
+// appConfigActivityAdminOnlyKeys are the settings only visible to the global
+// admins, only the names of their changed fields are recorded in the edited
+// app config activity.
+var appConfigActivityAdminOnlyKeys = []string{"smtp_settings", "sso_settings"}
+
+// appConfigActivityDiff returns the changes between the obfuscated app
+// configs to record in the edited app config activity, which is visible to
+// all the users. The URLs of the Slack and Microsoft Teams webhooks are
+// masked and the values of the admin-only settings are not recorded.
+func appConfigActivityDiff(before, after fleet.AppConfig) (changedBefore, changedAfter map[string]interface{}, err error) {
+	before.Integrations = obfuscateIntegrationURLs(before.Integrations)
+	after.Integrations = obfuscateIntegrationURLs(after.Integrations)
+	changedBefore, changedAfter, err = activityDiff(before, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range appConfigActivityAdminOnlyKeys {
+		if v, ok := changedBefore[k]; ok {
+			changedBefore[k] = maskActivityDiffValues(v)
+		}
+		if v, ok := changedAfter[k]; ok {
+			changedAfter[k] = maskActivityDiffValues(v)
+		}
+	}
+	return changedBefore, changedAfter, nil
+}
+
+// maskActivityDiffValues replaces the values of the changed fields with the
+// mask, keeping only the names of the fields.
+func maskActivityDiffValues(v interface{}) interface{} {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fleet.MaskedPassword
+	}
+	masked := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		masked[k] = maskActivityDiffValues(v)
+	}
+	return masked
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -226,7 +332,20 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p []byte) (*fleet.AppCo
 		return nil, err
 	}
 
+	// keep an obfuscated copy of the current config to record the changes
+	oldConfigJSON, err := json.Marshal(appConfig)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "marshal current AppConfig")
+	}
+	var oldObfuscatedConfig fleet.AppConfig
+	if err := json.Unmarshal(oldConfigJSON, &oldObfuscatedConfig); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "unmarshal current AppConfig")
+	}
+	obfuscateAppConfig(&oldObfuscatedConfig)
+
 	oldSmtpSettings := appConfig.SMTPSettings
+	oldWebhookSettings := appConfig.WebhookSettings
+	oldSSOSettings := appConfig.SSOSettings
 	oldAgentOptions := ""
 	if appConfig.AgentOptions != nil {
 		oldAgentOptions = string(*appConfig.AgentOptions)
@@ -242,6 +361,21 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p []byte) (*fleet.AppCo
 		return nil, ctxerr.Wrap(ctx, err, "modify AppConfig")
 	}
 
+	storedWebhookByURL, err := fleet.IndexWebhookIntegrations(appConfig.Integrations.Webhook)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "modify AppConfig")
+	}
+
+	storedSlackByKey, err := fleet.IndexSlackIntegrations(appConfig.Integrations.Slack)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "modify AppConfig")
+	}
+
+	storedMSTeamsByURL, err := fleet.IndexMicrosoftTeamsIntegrations(appConfig.Integrations.MicrosoftTeams)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "modify AppConfig")
+	}
+
 	// TODO(mna): this ports the validations from the old validationMiddleware
 	// correctly, but this could be optimized so that we don't unmarshal the
 	// incoming bytes twice.
@@ -272,9 +406,21 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p []byte) (*fleet.AppCo
 	if err := json.Unmarshal(p, &appConfig); err != nil {
 		return nil, ctxerr.Wrap(ctx, &badRequestError{message: err.Error()})
 	}
+	// keep the webhook and SSO secrets that were sent back masked
+	appConfig.WebhookSettings.RestoreMaskedSecrets(oldWebhookSettings)
+	appConfig.SSOSettings.RestoreMaskedSecrets(oldSSOSettings)
 
 	fleet.ValidateEnabledVulnerabilitiesIntegrations(appConfig.WebhookSettings.VulnerabilitiesWebhook, appConfig.Integrations, invalid)
 	fleet.ValidateEnabledFailingPoliciesIntegrations(appConfig.WebhookSettings.FailingPoliciesWebhook, appConfig.Integrations, invalid)
+	svc.validateActivityExpirySettings(appConfig.ActivityExpirySettings, invalid)
+	if appConfig.TwoFactorAuthSettings.Enforced && !oldObfuscatedConfig.TwoFactorAuthSettings.Enforced && !svc.config.Auth.TOTPEnabled {
+		invalid.Append("two_factor_auth_settings.enforced", "two-factor authentication is not enabled on this server (auth.totp_enabled)")
+	}
+	validatePasswordPolicy(appConfig.PasswordPolicy, invalid)
+	validateLiveQueryResultsSettings(appConfig.LiveQueryResultsSettings, invalid)
+	if appConfig.QueryReportsSettings.MaxRowsPerQuery < 0 {
+		invalid.Append("max_rows_per_query", "must be 0 or a positive number of rows")
+	}
 	if invalid.HasErrors() {
 		return nil, ctxerr.Wrap(ctx, invalid)
 	}
@@ -313,9 +459,33 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p []byte) (*fleet.AppCo
 	}
 	appConfig.Integrations.Zendesk = newAppConfig.Integrations.Zendesk
 
+	delWebhook, err := fleet.ValidateWebhookIntegrations(ctx, storedWebhookByURL, newAppConfig.Integrations.Webhook)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("webhook integration", err.Error()))
+	}
+	appConfig.Integrations.Webhook = newAppConfig.Integrations.Webhook
+
+	delSlack, err := fleet.ValidateSlackIntegrations(ctx, storedSlackByKey, newAppConfig.Integrations.Slack)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("Slack integration", err.Error()))
+	}
+	appConfig.Integrations.Slack = newAppConfig.Integrations.Slack
+
+	delMSTeams, err := fleet.ValidateMicrosoftTeamsIntegrations(ctx, storedMSTeamsByURL, newAppConfig.Integrations.MicrosoftTeams)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("Microsoft Teams integration", err.Error()))
+	}
+	appConfig.Integrations.MicrosoftTeams = newAppConfig.Integrations.MicrosoftTeams
+
 	// if any integration was deleted, remove it from any team that uses it
-	if len(delJira)+len(delZendesk) > 0 {
-		if err := svc.ds.DeleteIntegrationsFromTeams(ctx, fleet.Integrations{Jira: delJira, Zendesk: delZendesk}); err != nil {
+	if len(delJira)+len(delZendesk)+len(delWebhook)+len(delSlack)+len(delMSTeams) > 0 {
+		if err := svc.ds.DeleteIntegrationsFromTeams(ctx, fleet.Integrations{
+			Jira:           delJira,
+			Zendesk:        delZendesk,
+			Webhook:        delWebhook,
+			Slack:          delSlack,
+			MicrosoftTeams: delMSTeams,
+		}); err != nil {
 			return nil, ctxerr.Wrap(ctx, err, "delete integrations from teams")
 		}
 	}
@@ -351,10 +521,116 @@ func (svc *Service) ModifyAppConfig(ctx context.Context, p []byte) (*fleet.AppCo
 		}
 	}
 
+	changedBefore, changedAfter, err := appConfigActivityDiff(oldObfuscatedConfig, *obfuscatedConfig)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "diff AppConfig changes")
+	}
+	if len(changedAfter) > 0 {
+		if err := svc.ds.NewActivity(
+			ctx,
+			authz.UserFromContext(ctx),
+			fleet.ActivityTypeEditedAppConfig,
+			&map[string]interface{}{"before": changedBefore, "after": changedAfter},
+		); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "create edited app config activity")
+		}
+	}
+
 	return obfuscatedConfig, nil
 }
 
+// validateActivityExpirySettings validates the activity expiry settings, the
+// archive can only be enabled if its S3 bucket is configured.
+func (svc *Service) validateActivityExpirySettings(settings fleet.ActivityExpirySettings, invalid *fleet.InvalidArgumentError) {
+	if settings.ActivityExpiryEnabled && settings.ActivityExpiryWindow <= 0 {
+		invalid.Append("activity_expiry_window", "must be a positive number of days when activity expiry is enabled")
+	}
+	if settings.ArchiveEnabled && svc.activityArchive == nil {
+		invalid.Append("archive_enabled", "the activity archive S3 bucket (activity.archive_s3.bucket) is not configured")
+	}
+}
+
+// maxPasswordMinLength is the highest minimum length of the passwords that can
+// be configured, bcrypt only uses the first 72 bytes of the salted password.
+const maxPasswordMinLength = 64
+
+func validatePasswordPolicy(policy fleet.PasswordPolicy, invalid *fleet.InvalidArgumentError) {
+	if policy.MinLength < 0 || policy.MinLength > maxPasswordMinLength {
+		invalid.Append("min_length", fmt.Sprintf("must be between 0 and %d", maxPasswordMinLength))
+	}
+	if policy.HistoryCount < 0 || policy.HistoryCount > fleet.MaxPasswordHistoryCount {
+		invalid.Append("history_count", fmt.Sprintf("must be between 0 and %d", fleet.MaxPasswordHistoryCount))
+	}
+	if policy.MaxAgeDays < 0 || policy.MaxAgeDays > fleet.MaxPasswordMaxAgeDays {
+		invalid.Append("max_age_days", fmt.Sprintf("must be between 0 and %d days", fleet.MaxPasswordMaxAgeDays))
+	}
+}
+
+func validateLiveQueryResultsSettings(settings fleet.LiveQueryResultsSettings, invalid *fleet.InvalidArgumentError) {
+	if settings.RetentionDays < 0 {
+		invalid.Append("retention_days", "must be 0 or a positive number of days")
+	}
+	if settings.MaxRowsPerHost < 0 {
+		invalid.Append("max_rows_per_host", "must be 0 or a positive number of rows")
+	}
+	if settings.MaxRowsPerCampaign < 0 {
+		invalid.Append("max_rows_per_campaign", "must be 0 or a positive number of rows")
+	}
+}
+
 func validateSSOSettings(p fleet.AppConfig, existing *fleet.AppConfig, invalid *fleet.InvalidArgumentError, license *fleet.LicenseInfo) {
+	switch p.SSOSettings.Protocol {
+	case "", fleet.SSOProtocolSAML, fleet.SSOProtocolOIDC:
+	default:
+		invalid.Append("protocol", fmt.Sprintf("unsupported protocol %q, must be %q or %q", p.SSOSettings.Protocol, fleet.SSOProtocolSAML, fleet.SSOProtocolOIDC))
+		return
+	}
+
+	if len(p.SSOSettings.RoleMappings) > 0 {
+		if !license.IsPremium() {
+			invalid.Append("role_mappings", ErrMissingLicense.Error())
+		}
+		if p.SSOSettings.GroupsAttribute == "" && existing.SSOSettings.GroupsAttribute == "" {
+			invalid.Append("groups_attribute", "required when role_mappings are defined")
+		}
+		for _, m := range p.SSOSettings.RoleMappings {
+			if m.Group == "" {
+				invalid.Append("role_mappings", "group is required")
+			}
+			if m.Team == "" && !fleet.ValidGlobalRole(m.Role) {
+				invalid.Append("role_mappings", fmt.Sprintf("invalid global role %q for group %q", m.Role, m.Group))
+			}
+			if m.Team != "" && !fleet.ValidTeamRole(m.Role) {
+				invalid.Append("role_mappings", fmt.Sprintf("invalid team role %q for group %q", m.Role, m.Group))
+			}
+		}
+	}
+
+	// the settings in the payload are applied on top of the existing ones, so
+	// the existing protocol is kept if it is not part of the payload.
+	isOIDC := p.SSOSettings.IsOIDC() || (p.SSOSettings.Protocol == "" && existing.SSOSettings.IsOIDC())
+	if p.SSOSettings.EnableSSO && isOIDC {
+		issuerURL := p.SSOSettings.OIDC.IssuerURL
+		if issuerURL == "" {
+			issuerURL = existing.SSOSettings.OIDC.IssuerURL
+		}
+		if issuerURL == "" {
+			invalid.Append("oidc.issuer_url", "required")
+		} else if u, err := url.Parse(issuerURL); err != nil || u.Scheme == "" || u.Host == "" {
+			invalid.Append("oidc.issuer_url", "must be an absolute URL")
+		}
+		if p.SSOSettings.OIDC.ClientID == "" && existing.SSOSettings.OIDC.ClientID == "" {
+			invalid.Append("oidc.client_id", "required")
+		}
+		if p.SSOSettings.IDPName == "" && existing.SSOSettings.IDPName == "" {
+			invalid.Append("idp_name", "required")
+		}
+		if !license.IsPremium() && p.SSOSettings.EnableJITProvisioning {
+			invalid.Append("enable_jit_provisioning", ErrMissingLicense.Error())
+		}
+		return
+	}
+
 	if p.SSOSettings.EnableSSO {
 		if p.SSOSettings.Metadata == "" && p.SSOSettings.MetadataURL == "" {
 			if existing.SSOSettings.Metadata == "" && existing.SSOSettings.MetadataURL == "" {
@@ -422,7 +698,25 @@ func (svc *Service) ApplyEnrollSecretSpec(ctx context.Context, spec *fleet.Enrol
 		return ctxerr.New(ctx, "enroll secret cannot be changed when fleet_packaging.global_enroll_secret is set")
 	}
 
-	return svc.ds.ApplyEnrollSecrets(ctx, nil, spec.Secrets)
+	if err := svc.ds.ApplyEnrollSecrets(ctx, nil, spec.Secrets); err != nil {
+		return err
+	}
+	return svc.newEditedEnrollSecretsActivity(ctx, nil, len(spec.Secrets))
+}
+
+// newEditedEnrollSecretsActivity records the change of the enroll secrets of
+// the team, or the global ones if teamID is nil. The secrets themselves are
+// never recorded.
+func (svc *Service) newEditedEnrollSecretsActivity(ctx context.Context, teamID *uint, count int) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeEditedEnrollSecrets,
+		&map[string]interface{}{"global": teamID == nil, "team_id": teamID, "secrets_count": count},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create edited enroll secrets activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -23,6 +23,9 @@ import (
 
 func TestAppConfigAuth(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	// start a TLS server and use its URL as the server URL in the app config,
@@ -111,6 +114,9 @@ func TestAppConfigAuth(t *testing.T) {
 
 func TestEnrollSecretAuth(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	ds.ApplyEnrollSecretsFunc = func(ctx context.Context, tid *uint, secrets []*fleet.EnrollSecret) error {
@@ -184,6 +190,9 @@ func TestEnrollSecretAuth(t *testing.T) {
 
 func TestApplyEnrollSecretWithGlobalEnrollConfig(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	ds.ApplyEnrollSecretsFunc = func(ctx context.Context, teamID *uint, secrets []*fleet.EnrollSecret) error {
 		return nil
 	}
@@ -343,6 +352,125 @@ func TestJITProvisioning(t *testing.T) {
 	})
 }
 
+func TestSSORoleMappings(t *testing.T) {
+	config := fleet.AppConfig{
+		SSOSettings: fleet.SSOSettings{
+			EnableSSO:       true,
+			EntityID:        "fleet",
+			IssuerURI:       "http://issuer.idp.com",
+			IDPName:         "onelogin",
+			MetadataURL:     "http://isser.metadata.com",
+			GroupsAttribute: "groups",
+			RoleMappings: []fleet.SSORoleMapping{
+				{Group: "fleet-admins", Role: fleet.RoleAdmin},
+				{Group: "eng-laptops", Role: fleet.RoleMaintainer, Team: "Engineering"},
+			},
+		},
+	}
+
+	t.Run("requires a premium license", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "role_mappings")
+		assert.Contains(t, invalid.Error(), "missing or invalid license")
+	})
+
+	t.Run("valid mappings", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{Tier: fleet.TierPremium})
+		assert.False(t, invalid.HasErrors())
+	})
+
+	t.Run("invalid mappings", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		config := config
+		config.SSOSettings.GroupsAttribute = ""
+		config.SSOSettings.RoleMappings = []fleet.SSORoleMapping{
+			{Group: "", Role: fleet.RoleAdmin},
+			{Group: "superusers", Role: "root"},
+			{Group: "eng", Role: "owner", Team: "Engineering"},
+		}
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{Tier: fleet.TierPremium})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "groups_attribute")
+		assert.Contains(t, invalid.Error(), "group is required")
+		assert.Contains(t, invalid.Error(), `invalid global role "root"`)
+		assert.Contains(t, invalid.Error(), `invalid team role "owner"`)
+	})
+}
+
+func TestOIDCSSOSettings(t *testing.T) {
+	oidcConfig := func() fleet.AppConfig {
+		return fleet.AppConfig{
+			SSOSettings: fleet.SSOSettings{
+				EnableSSO: true,
+				IDPName:   "okta",
+				Protocol:  fleet.SSOProtocolOIDC,
+				OIDC: fleet.OIDCSettings{
+					IssuerURL: "https://example.okta.com",
+					ClientID:  "fleet",
+				},
+			},
+		}
+	}
+
+	t.Run("valid settings do not require SAML metadata", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		validateSSOSettings(oidcConfig(), &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		assert.False(t, invalid.HasErrors())
+	})
+
+	t.Run("missing issuer and client id", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		config := oidcConfig()
+		config.SSOSettings.OIDC = fleet.OIDCSettings{}
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "oidc.issuer_url")
+		assert.Contains(t, invalid.Error(), "oidc.client_id")
+	})
+
+	t.Run("relative issuer url", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		config := oidcConfig()
+		config.SSOSettings.OIDC.IssuerURL = "/oauth2"
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "must be an absolute URL")
+	})
+
+	t.Run("existing settings are used for missing fields", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		existing := oidcConfig()
+		config := fleet.AppConfig{SSOSettings: fleet.SSOSettings{EnableSSO: true}}
+		validateSSOSettings(config, &existing, invalid, &fleet.LicenseInfo{})
+		assert.False(t, invalid.HasErrors())
+	})
+
+	t.Run("JIT provisioning requires premium", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		config := oidcConfig()
+		config.SSOSettings.EnableJITProvisioning = true
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "enable_jit_provisioning")
+
+		invalid = &fleet.InvalidArgumentError{}
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{Tier: fleet.TierPremium})
+		assert.False(t, invalid.HasErrors())
+	})
+
+	t.Run("unknown protocol", func(t *testing.T) {
+		invalid := &fleet.InvalidArgumentError{}
+		config := oidcConfig()
+		config.SSOSettings.Protocol = "cas"
+		validateSSOSettings(config, &fleet.AppConfig{}, invalid, &fleet.LicenseInfo{})
+		require.True(t, invalid.HasErrors())
+		assert.Contains(t, invalid.Error(), "protocol")
+	})
+}
+
 func TestAppConfigSecretsObfuscated(t *testing.T) {
 	ds := new(mock.Store)
 	svc := newTestService(t, ds, nil, nil)
@@ -355,6 +483,10 @@ func TestAppConfigSecretsObfuscated(t *testing.T) {
 	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
 		return &fleet.AppConfig{
 			SMTPSettings: fleet.SMTPSettings{SMTPPassword: "smtppassword"},
+			WebhookSettings: fleet.WebhookSettings{
+				HostStatusWebhook:      fleet.HostStatusWebhookSettings{Secret: "hostsecret"},
+				FailingPoliciesWebhook: fleet.FailingPoliciesWebhookSettings{Secret: "policysecret"},
+			},
 			Integrations: fleet.Integrations{
 				Jira: []*fleet.JiraIntegration{
 					{APIToken: "jiratoken"},
@@ -362,6 +494,10 @@ func TestAppConfigSecretsObfuscated(t *testing.T) {
 				Zendesk: []*fleet.ZendeskIntegration{
 					{APIToken: "zendesktoken"},
 				},
+				Slack: []*fleet.SlackIntegration{
+					{APIToken: "slacktoken", Channel: "#secops"},
+					{WebhookURL: "https://hooks.slack.com/services/xyz"},
+				},
 			},
 		}, nil
 	}
@@ -408,14 +544,129 @@ func TestAppConfigSecretsObfuscated(t *testing.T) {
 			require.Equal(t, ac.SMTPSettings.SMTPPassword, fleet.MaskedPassword)
 			require.Equal(t, ac.Integrations.Jira[0].APIToken, fleet.MaskedPassword)
 			require.Equal(t, ac.Integrations.Zendesk[0].APIToken, fleet.MaskedPassword)
+			require.Equal(t, ac.Integrations.Slack[0].APIToken, fleet.MaskedPassword)
+			require.Empty(t, ac.Integrations.Slack[1].APIToken)
+			require.Equal(t, ac.WebhookSettings.HostStatusWebhook.Secret, fleet.MaskedPassword)
+			require.Equal(t, ac.WebhookSettings.FailingPoliciesWebhook.Secret, fleet.MaskedPassword)
+			// an unset secret is not masked
+			require.Empty(t, ac.WebhookSettings.VulnerabilitiesWebhook.Secret)
+		})
+	}
+}
+
+func TestAppConfigIntegrationURLsObfuscated(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{
+			Integrations: fleet.Integrations{
+				Slack: []*fleet.SlackIntegration{
+					{WebhookURL: "https://hooks.slack.com/services/xyz"},
+					{APIToken: "slacktoken", Channel: "#secops"},
+				},
+				MicrosoftTeams: []*fleet.MicrosoftTeamsIntegration{
+					{URL: "https://example.webhook.office.com/webhookb2/xyz"},
+				},
+			},
+		}, nil
+	}
+
+	testCases := []struct {
+		name   string
+		user   *fleet.User
+		masked bool
+	}{
+		{
+			"global admin",
+			&fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)},
+			false,
+		},
+		{
+			"global maintainer",
+			&fleet.User{GlobalRole: ptr.String(fleet.RoleMaintainer)},
+			true,
+		},
+		{
+			"global observer",
+			&fleet.User{GlobalRole: ptr.String(fleet.RoleObserver)},
+			true,
+		},
+		{
+			"team admin",
+			&fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: fleet.RoleAdmin}}},
+			true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: tt.user})
+
+			res, err := getAppConfigEndpoint(ctx, nil, svc)
+			require.NoError(t, err)
+			ac := res.(appConfigResponse).AppConfig
+			require.Len(t, ac.Integrations.Slack, 2)
+			require.Len(t, ac.Integrations.MicrosoftTeams, 1)
+			if tt.masked {
+				require.Equal(t, fleet.MaskedPassword, ac.Integrations.Slack[0].WebhookURL)
+				require.Equal(t, fleet.MaskedPassword, ac.Integrations.MicrosoftTeams[0].URL)
+			} else {
+				require.Equal(t, "https://hooks.slack.com/services/xyz", ac.Integrations.Slack[0].WebhookURL)
+				require.Equal(t, "https://example.webhook.office.com/webhookb2/xyz", ac.Integrations.MicrosoftTeams[0].URL)
+			}
+			// an unset webhook URL is not masked
+			require.Empty(t, ac.Integrations.Slack[1].WebhookURL)
+			require.Equal(t, "#secops", ac.Integrations.Slack[1].Channel)
 		})
 	}
 }
 
+func TestAppConfigActivityDiff(t *testing.T) {
+	before := fleet.AppConfig{
+		OrgInfo:      fleet.OrgInfo{OrgName: "Acme"},
+		SMTPSettings: fleet.SMTPSettings{SMTPServer: "smtp.example.com", SMTPUserName: "user"},
+		SSOSettings:  fleet.SSOSettings{EntityID: "fleet", Metadata: "<xml/>"},
+		Integrations: fleet.Integrations{
+			Slack: []*fleet.SlackIntegration{{WebhookURL: "https://hooks.slack.com/services/old"}},
+		},
+	}
+	after := before
+	after.OrgInfo.OrgName = "Acme Corp"
+	after.SMTPSettings.SMTPServer = "smtp2.example.com"
+	after.SSOSettings.Metadata = "<xml>new</xml>"
+	after.Integrations = fleet.Integrations{
+		Slack:          []*fleet.SlackIntegration{{WebhookURL: "https://hooks.slack.com/services/new"}},
+		MicrosoftTeams: []*fleet.MicrosoftTeamsIntegration{{URL: "https://example.webhook.office.com/webhookb2/new"}},
+	}
+
+	changedBefore, changedAfter, err := appConfigActivityDiff(before, after)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"org_name": "Acme"}, changedBefore["org_info"])
+	assert.Equal(t, map[string]interface{}{"org_name": "Acme Corp"}, changedAfter["org_info"])
+
+	// only the names of the changed SMTP and SSO settings are recorded
+	assert.Equal(t, map[string]interface{}{"server": fleet.MaskedPassword}, changedBefore["smtp_settings"])
+	assert.Equal(t, map[string]interface{}{"server": fleet.MaskedPassword}, changedAfter["smtp_settings"])
+	assert.Equal(t, map[string]interface{}{"metadata": fleet.MaskedPassword}, changedAfter["sso_settings"])
+
+	// the webhook URLs are masked, only the added Microsoft Teams integration
+	// is a change
+	b, err := json.Marshal(changedAfter)
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "hooks.slack.com")
+	assert.NotContains(t, string(b), "webhook.office.com")
+	assert.NotContains(t, string(b), "smtp2.example.com")
+	assert.NotContains(t, string(b), "<xml>new</xml>")
+	require.Contains(t, changedAfter, "integrations")
+}
+
 // TestModifyAppConfigSMTPConfigured tests that disabling SMTP
 // should set the SMTPConfigured field to false.
 func TestModifyAppConfigSMTPConfigured(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	// SMTP is initially enabled and configured.
@@ -456,10 +707,151 @@ func TestModifyAppConfigSMTPConfigured(t *testing.T) {
 	require.False(t, dsAppConfig.SMTPSettings.SMTPConfigured)
 }
 
+func TestModifyAppConfigActivityExpiry(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	dsAppConfig := &fleet.AppConfig{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return dsAppConfig, nil
+	}
+	ds.SaveAppConfigFunc = func(ctx context.Context, conf *fleet.AppConfig) error {
+		*dsAppConfig = *conf
+		return nil
+	}
+
+	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
+
+	// the window is required
+	svc := newTestService(t, ds, nil, nil)
+	_, err := svc.ModifyAppConfig(ctx, []byte(`{"activity_expiry_settings": {"activity_expiry_enabled": true}}`))
+	require.ErrorContains(t, err, "activity_expiry_window")
+
+	// the archive requires the S3 bucket
+	_, err = svc.ModifyAppConfig(ctx, []byte(`{"activity_expiry_settings": {"activity_expiry_enabled": true, "activity_expiry_window": 30, "archive_enabled": true}}`))
+	require.ErrorContains(t, err, "archive_enabled")
+
+	svc = newTestService(t, ds, nil, nil, &TestServerOpts{ActivityArchive: new(mock.ActivityArchiveStore)})
+	updatedAppConfig, err := svc.ModifyAppConfig(ctx, []byte(`{"activity_expiry_settings": {"activity_expiry_enabled": true, "activity_expiry_window": 30, "archive_enabled": true}}`))
+	require.NoError(t, err)
+	require.Equal(t, fleet.ActivityExpirySettings{ActivityExpiryEnabled: true, ActivityExpiryWindow: 30, ArchiveEnabled: true}, updatedAppConfig.ActivityExpirySettings)
+	require.Equal(t, updatedAppConfig.ActivityExpirySettings, dsAppConfig.ActivityExpirySettings)
+}
+
+func TestModifyAppConfigPasswordPolicy(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	dsAppConfig := &fleet.AppConfig{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return dsAppConfig, nil
+	}
+	ds.SaveAppConfigFunc = func(ctx context.Context, conf *fleet.AppConfig) error {
+		*dsAppConfig = *conf
+		return nil
+	}
+
+	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
+	svc := newTestService(t, ds, nil, nil)
+
+	for _, invalid := range []string{
+		`{"password_policy": {"min_length": -1}}`,
+		`{"password_policy": {"min_length": 65}}`,
+		`{"password_policy": {"history_count": 25}}`,
+		`{"password_policy": {"max_age_days": -1}}`,
+		`{"password_policy": {"max_age_days": 3651}}`,
+	} {
+		_, err := svc.ModifyAppConfig(ctx, []byte(invalid))
+		var iae *fleet.InvalidArgumentError
+		require.ErrorAs(t, err, &iae, invalid)
+	}
+
+	updatedAppConfig, err := svc.ModifyAppConfig(ctx, []byte(`{"password_policy": {"min_length": 16, "require_uppercase": true, "history_count": 5, "max_age_days": 90}}`))
+	require.NoError(t, err)
+	want := fleet.PasswordPolicy{MinLength: 16, RequireUppercase: true, HistoryCount: 5, MaxAgeDays: 90}
+	require.Equal(t, want, updatedAppConfig.PasswordPolicy)
+	require.Equal(t, want, dsAppConfig.PasswordPolicy)
+}
+
+func TestModifyAppConfigLiveQueryResultsSettings(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	dsAppConfig := &fleet.AppConfig{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return dsAppConfig, nil
+	}
+	ds.SaveAppConfigFunc = func(ctx context.Context, conf *fleet.AppConfig) error {
+		*dsAppConfig = *conf
+		return nil
+	}
+
+	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
+	svc := newTestService(t, ds, nil, nil)
+
+	for _, invalid := range []string{
+		`{"live_query_results_settings": {"retention_days": -1}}`,
+		`{"live_query_results_settings": {"max_rows_per_host": -1}}`,
+		`{"live_query_results_settings": {"max_rows_per_campaign": -1}}`,
+	} {
+		_, err := svc.ModifyAppConfig(ctx, []byte(invalid))
+		var iae *fleet.InvalidArgumentError
+		require.ErrorAs(t, err, &iae, invalid)
+	}
+
+	updatedAppConfig, err := svc.ModifyAppConfig(ctx, []byte(`{"live_query_results_settings": {"retention_days": 7, "max_rows_per_host": 50, "max_rows_per_campaign": 0}}`))
+	require.NoError(t, err)
+	want := fleet.LiveQueryResultsSettings{RetentionDays: 7, MaxRowsPerHost: 50}
+	require.Equal(t, want, updatedAppConfig.LiveQueryResultsSettings)
+	require.Equal(t, want, dsAppConfig.LiveQueryResultsSettings)
+}
+
+func TestModifyAppConfigQueryReportsSettings(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	dsAppConfig := &fleet.AppConfig{}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return dsAppConfig, nil
+	}
+	ds.SaveAppConfigFunc = func(ctx context.Context, conf *fleet.AppConfig) error {
+		*dsAppConfig = *conf
+		return nil
+	}
+
+	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
+	svc := newTestService(t, ds, nil, nil)
+
+	_, err := svc.ModifyAppConfig(ctx, []byte(`{"query_reports_settings": {"max_rows_per_query": -1}}`))
+	var iae *fleet.InvalidArgumentError
+	require.ErrorAs(t, err, &iae)
+
+	updatedAppConfig, err := svc.ModifyAppConfig(ctx, []byte(`{"query_reports_settings": {"enabled": true, "max_rows_per_query": 10}}`))
+	require.NoError(t, err)
+	want := fleet.QueryReportsSettings{Enabled: true, MaxRowsPerQuery: 10}
+	require.Equal(t, want, updatedAppConfig.QueryReportsSettings)
+	require.Equal(t, want, dsAppConfig.QueryReportsSettings)
+}
+
 // TestTransparencyURL tests that Fleet Premium licensees can use custom transparency urls and Fleet
 // Free licensees are restricted to the default transparency url.
 func TestTransparencyURL(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
 	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
@@ -552,6 +944,9 @@ func TestTransparencyURL(t *testing.T) {
 // been stored (for example, if a licensee downgraded without manually resetting the transparency url)
 func TestTransparencyURLDowngradeLicense(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 
 	admin := &fleet.User{GlobalRole: ptr.String(fleet.RoleAdmin)}
 	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: admin})
@@ -42,6 +42,20 @@ func (e notSetupErr) NotSetup() bool {
 	return true
 }
 
+// TOTPRequiredErr is returned by the login when the user must complete a
+// two-factor authentication challenge, see Client.LoginTOTP.
+type TOTPRequiredErr struct {
+	// Token identifies the challenge to complete.
+	Token string
+	// EnrollmentRequired is true if the user must enroll first, see
+	// Client.EnrollTOTPForLogin.
+	EnrollmentRequired bool
+}
+
+func (e *TOTPRequiredErr) Error() string {
+	return "two-factor authentication required"
+}
+
 type NotFoundErr interface {
 	NotFound() bool
 	Error() string
@@ -19,9 +19,11 @@ import (
 ////////////////////////////////////////////////////////////////////////////////
 
 type createDistributedQueryCampaignRequest struct {
-	QuerySQL string            `json:"query"`
-	QueryID  *uint             `json:"query_id"`
-	Selected fleet.HostTargets `json:"selected"`
+	QuerySQL      string                      `json:"query"`
+	QueryID       *uint                       `json:"query_id"`
+	Selected      fleet.HostTargets           `json:"selected"`
+	CollectWindow fleet.Duration              `json:"collect_window"`
+	ExportFormat  fleet.LiveQueryExportFormat `json:"export_format"`
 }
 
 type createDistributedQueryCampaignResponse struct {
@@ -33,14 +35,17 @@ func (r createDistributedQueryCampaignResponse) error() error { return r.Err }
 
 func createDistributedQueryCampaignEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
 	req := request.(*createDistributedQueryCampaignRequest)
-	campaign, err := svc.NewDistributedQueryCampaign(ctx, req.QuerySQL, req.QueryID, req.Selected)
+	campaign, err := svc.NewDistributedQueryCampaign(ctx, req.QuerySQL, req.QueryID, req.Selected, fleet.CampaignOptions{
+		CollectWindow: req.CollectWindow.Duration,
+		ExportFormat:  req.ExportFormat,
+	})
 	if err != nil {
 		return createDistributedQueryCampaignResponse{Err: err}, nil
 	}
 	return createDistributedQueryCampaignResponse{Campaign: campaign}, nil
 }
 
-func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString string, queryID *uint, targets fleet.HostTargets) (*fleet.DistributedQueryCampaign, error) {
+func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString string, queryID *uint, targets fleet.HostTargets, opts fleet.CampaignOptions) (*fleet.DistributedQueryCampaign, error) {
 	if err := svc.StatusLiveQuery(ctx); err != nil {
 		return nil, err
 	}
@@ -53,6 +58,18 @@ func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString
 	if queryID == nil && queryString == "" {
 		return nil, fleet.NewInvalidArgumentError("query", "one of query or query_id must be specified")
 	}
+	collectWindow := opts.CollectWindow
+	if collectWindow < 0 || collectWindow > fleet.MaxCampaignCollectWindow {
+		return nil, fleet.NewInvalidArgumentError("collect_window", fmt.Sprintf("must be between 0 and %s", fleet.MaxCampaignCollectWindow))
+	}
+	if opts.ExportFormat != "" {
+		if !opts.ExportFormat.IsValid() {
+			return nil, fleet.NewInvalidArgumentError("export_format", fmt.Sprintf("must be %s or %s", fleet.LiveQueryExportNDJSON, fleet.LiveQueryExportCSV))
+		}
+		if svc.liveQueryExport == nil {
+			return nil, fleet.NewInvalidArgumentError("export_format", "the live query export storage is not configured")
+		}
+	}
 
 	var query *fleet.Query
 	var err error
@@ -88,11 +105,18 @@ func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString
 
 	filter := fleet.TeamFilter{User: vc.User, IncludeObserver: query.ObserverCanRun}
 
-	campaign, err := svc.ds.NewDistributedQueryCampaign(ctx, &fleet.DistributedQueryCampaign{
-		QueryID: query.ID,
-		Status:  fleet.QueryWaiting,
-		UserID:  vc.UserID(),
-	})
+	campaign := &fleet.DistributedQueryCampaign{
+		QueryID:      query.ID,
+		Status:       fleet.QueryWaiting,
+		UserID:       vc.UserID(),
+		ExportFormat: opts.ExportFormat,
+	}
+	if collectWindow > 0 {
+		// an asynchronous campaign runs without waiting for a listener.
+		campaign.Status = fleet.QueryRunning
+		campaign.CollectUntil = ptr.Time(svc.clock.Now().Add(collectWindow).UTC().Truncate(time.Second))
+	}
+	campaign, err = svc.ds.NewDistributedQueryCampaign(ctx, campaign)
 	if err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "new campaign")
 	}
@@ -152,7 +176,16 @@ func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString
 		}
 	}
 
-	err = svc.liveQueryStore.RunQuery(strconv.Itoa(int(campaign.ID)), queryString, hostIDs)
+	if campaign.CollectUntil != nil {
+		// the hosts are stored to know which ones are still pending, the
+		// query expires from the live query store with the collection window.
+		if err := svc.ds.NewDistributedQueryCampaignHosts(ctx, campaign.ID, hostIDs); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "adding campaign hosts")
+		}
+		err = svc.liveQueryStore.RunQueryWithExpiration(strconv.Itoa(int(campaign.ID)), queryString, hostIDs, collectWindow)
+	} else {
+		err = svc.liveQueryStore.RunQuery(strconv.Itoa(int(campaign.ID)), queryString, hostIDs)
+	}
 	if err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "run query")
 	}
@@ -178,9 +211,11 @@ func (svc *Service) NewDistributedQueryCampaign(ctx context.Context, queryString
 ////////////////////////////////////////////////////////////////////////////////
 
 type createDistributedQueryCampaignByNamesRequest struct {
-	QuerySQL string                                 `json:"query"`
-	QueryID  *uint                                  `json:"query_id"`
-	Selected distributedQueryCampaignTargetsByNames `json:"selected"`
+	QuerySQL      string                                 `json:"query"`
+	QueryID       *uint                                  `json:"query_id"`
+	Selected      distributedQueryCampaignTargetsByNames `json:"selected"`
+	CollectWindow fleet.Duration                         `json:"collect_window"`
+	ExportFormat  fleet.LiveQueryExportFormat            `json:"export_format"`
 }
 
 type distributedQueryCampaignTargetsByNames struct {
@@ -190,14 +225,17 @@ type distributedQueryCampaignTargetsByNames struct {
 
 func createDistributedQueryCampaignByNamesEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
 	req := request.(*createDistributedQueryCampaignByNamesRequest)
-	campaign, err := svc.NewDistributedQueryCampaignByNames(ctx, req.QuerySQL, req.QueryID, req.Selected.Hosts, req.Selected.Labels)
+	campaign, err := svc.NewDistributedQueryCampaignByNames(ctx, req.QuerySQL, req.QueryID, req.Selected.Hosts, req.Selected.Labels, fleet.CampaignOptions{
+		CollectWindow: req.CollectWindow.Duration,
+		ExportFormat:  req.ExportFormat,
+	})
 	if err != nil {
 		return createDistributedQueryCampaignResponse{Err: err}, nil
 	}
 	return createDistributedQueryCampaignResponse{Campaign: campaign}, nil
 }
 
-func (svc *Service) NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string) (*fleet.DistributedQueryCampaign, error) {
+func (svc *Service) NewDistributedQueryCampaignByNames(ctx context.Context, queryString string, queryID *uint, hosts []string, labels []string, opts fleet.CampaignOptions) (*fleet.DistributedQueryCampaign, error) {
 	vc, ok := viewer.FromContext(ctx)
 	if !ok {
 		return nil, fleet.ErrNoContext
@@ -215,5 +253,260 @@ func (svc *Service) NewDistributedQueryCampaignByNames(ctx context.Context, quer
 	}
 
 	targets := fleet.HostTargets{HostIDs: hostIDs, LabelIDs: labelIDs}
-	return svc.NewDistributedQueryCampaign(ctx, queryString, queryID, targets)
+	return svc.NewDistributedQueryCampaign(ctx, queryString, queryID, targets, opts)
+}
+
+// saveCampaignResult stores the result of a host for the campaign, within the
+// limits of the live query results settings.
+func (svc *Service) saveCampaignResult(ctx context.Context, res fleet.DistributedQueryResult) error {
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	settings := appConfig.LiveQueryResultsSettings
+
+	result := newCampaignResult(res)
+	if settings.MaxRowsPerHost > 0 && len(result.Rows) > settings.MaxRowsPerHost {
+		result.Rows = result.Rows[:settings.MaxRowsPerHost]
+		result.Truncated = true
+	}
+	if err := svc.ds.NewDistributedQueryCampaignResult(ctx, result, settings.MaxRowsPerCampaign); err != nil {
+		return ctxerr.Wrap(ctx, err, "save campaign result")
+	}
+	return nil
+}
+
+// exportCampaignResult queues the result of a host to be written to the live
+// query export store if the campaign is exported. Unlike the stored results,
+// the exported results are never truncated.
+func (svc *Service) exportCampaignResult(ctx context.Context, res fleet.DistributedQueryResult) error {
+	if svc.liveQueryExporter == nil {
+		return nil
+	}
+
+	result := newCampaignResult(res)
+	result.CreatedAt = svc.clock.Now().UTC()
+	return svc.liveQueryExporter.export(ctx, result)
+}
+
+// newCampaignResult returns the campaign result of the host with all its
+// non-nil rows.
+func newCampaignResult(res fleet.DistributedQueryResult) *fleet.DistributedQueryCampaignResult {
+	rows := make([]map[string]string, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		if row != nil {
+			rows = append(rows, row)
+		}
+	}
+	return &fleet.DistributedQueryCampaignResult{
+		DistributedQueryCampaignID: res.DistributedQueryCampaignID,
+		HostID:                     res.Host.ID,
+		Hostname:                   res.Host.Hostname,
+		Rows:                       rows,
+		RowCount:                   len(rows),
+		Error:                      res.Error,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List Distributed Query Campaign Results
+////////////////////////////////////////////////////////////////////////////////
+
+type listCampaignResultsRequest struct {
+	ID          uint              `url:"id"`
+	ListOptions fleet.ListOptions `url:"list_options"`
+	HostID      *uint             `query:"host_id,optional"`
+	OnlyErrors  bool              `query:"only_errors,optional"`
+}
+
+type listCampaignResultsResponse struct {
+	Campaign *fleet.DistributedQueryCampaign         `json:"campaign,omitempty"`
+	Results  []*fleet.DistributedQueryCampaignResult `json:"results"`
+	Err      error                                   `json:"error,omitempty"`
+}
+
+func (r listCampaignResultsResponse) error() error { return r.Err }
+
+func listCampaignResultsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listCampaignResultsRequest)
+	campaign, results, err := svc.ListCampaignResults(ctx, req.ID, fleet.ListCampaignResultsOptions{
+		ListOptions: req.ListOptions,
+		HostID:      req.HostID,
+		OnlyErrors:  req.OnlyErrors,
+	})
+	if err != nil {
+		return listCampaignResultsResponse{Err: err}, nil
+	}
+	return listCampaignResultsResponse{Campaign: campaign, Results: results}, nil
+}
+
+func (svc *Service) ListCampaignResults(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) (*fleet.DistributedQueryCampaign, []*fleet.DistributedQueryCampaignResult, error) {
+	// As for StreamCampaignResults, ObserverCanRun is set because only the user
+	// that started the campaign can read its results, so the observer check
+	// already happened with the actual value for the query.
+	if err := svc.authz.Authorize(ctx, &fleet.TargetedQuery{Query: &fleet.Query{ObserverCanRun: true}}, fleet.ActionRun); err != nil {
+		return nil, nil, err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, nil, fleet.ErrNoContext
+	}
+
+	campaign, err := svc.ds.DistributedQueryCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "get campaign")
+	}
+	if campaign.UserID != vc.UserID() {
+		return nil, nil, authz.ForbiddenWithInternal("campaign results can only be read by their user", vc.User, campaign, fleet.ActionRead)
+	}
+
+	results, err := svc.ds.ListDistributedQueryCampaignResults(ctx, campaignID, opt)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "list campaign results")
+	}
+	if campaign.CollectUntil != nil {
+		campaign.HostCounts, err = svc.ds.CountDistributedQueryCampaignHosts(ctx, campaignID)
+		if err != nil {
+			return nil, nil, ctxerr.Wrap(ctx, err, "count campaign hosts")
+		}
+	}
+	return campaign, results, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// List Distributed Query Campaign Hosts
+////////////////////////////////////////////////////////////////////////////////
+
+type listCampaignHostsRequest struct {
+	ID          uint              `url:"id"`
+	ListOptions fleet.ListOptions `url:"list_options"`
+	Status      string            `query:"status,optional"`
+}
+
+type listCampaignHostsResponse struct {
+	Campaign *fleet.DistributedQueryCampaign       `json:"campaign,omitempty"`
+	Hosts    []*fleet.DistributedQueryCampaignHost `json:"hosts"`
+	Err      error                                 `json:"error,omitempty"`
+}
+
+func (r listCampaignHostsResponse) error() error { return r.Err }
+
+func listCampaignHostsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listCampaignHostsRequest)
+	campaign, hosts, err := svc.ListCampaignHosts(ctx, req.ID, fleet.ListCampaignHostsOptions{
+		ListOptions: req.ListOptions,
+		Status:      fleet.CampaignHostStatus(req.Status),
+	})
+	if err != nil {
+		return listCampaignHostsResponse{Err: err}, nil
+	}
+	return listCampaignHostsResponse{Campaign: campaign, Hosts: hosts}, nil
+}
+
+func (svc *Service) ListCampaignHosts(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) (*fleet.DistributedQueryCampaign, []*fleet.DistributedQueryCampaignHost, error) {
+	// Same as ListCampaignResults, only the user that started the campaign can
+	// list its hosts.
+	if err := svc.authz.Authorize(ctx, &fleet.TargetedQuery{Query: &fleet.Query{ObserverCanRun: true}}, fleet.ActionRun); err != nil {
+		return nil, nil, err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, nil, fleet.ErrNoContext
+	}
+
+	if opt.Status != "" && !opt.Status.IsValid() {
+		return nil, nil, fleet.NewInvalidArgumentError("status", "must be one of pending, responded or errored")
+	}
+
+	campaign, err := svc.ds.DistributedQueryCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "get campaign")
+	}
+	if campaign.UserID != vc.UserID() {
+		return nil, nil, authz.ForbiddenWithInternal("campaign hosts can only be read by their user", vc.User, campaign, fleet.ActionRead)
+	}
+	if campaign.CollectUntil == nil {
+		return nil, nil, &badRequestError{message: "the hosts are only recorded for asynchronous campaigns"}
+	}
+
+	campaign.HostCounts, err = svc.ds.CountDistributedQueryCampaignHosts(ctx, campaignID)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "count campaign hosts")
+	}
+	hosts, err := svc.ds.ListDistributedQueryCampaignHosts(ctx, campaignID, opt)
+	if err != nil {
+		return nil, nil, ctxerr.Wrap(ctx, err, "list campaign hosts")
+	}
+	return campaign, hosts, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Get Distributed Query Campaign Export
+////////////////////////////////////////////////////////////////////////////////
+
+type getCampaignExportRequest struct {
+	ID uint `url:"id"`
+}
+
+type getCampaignExportResponse struct {
+	Export *fleet.CampaignExport `json:"export,omitempty"`
+	Err    error                 `json:"error,omitempty"`
+}
+
+func (r getCampaignExportResponse) error() error { return r.Err }
+
+func getCampaignExportEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*getCampaignExportRequest)
+	export, err := svc.CampaignExport(ctx, req.ID)
+	if err != nil {
+		return getCampaignExportResponse{Err: err}, nil
+	}
+	return getCampaignExportResponse{Export: export}, nil
+}
+
+func (svc *Service) CampaignExport(ctx context.Context, campaignID uint) (*fleet.CampaignExport, error) {
+	// Same as ListCampaignResults, only the user that started the campaign can
+	// download its results.
+	if err := svc.authz.Authorize(ctx, &fleet.TargetedQuery{Query: &fleet.Query{ObserverCanRun: true}}, fleet.ActionRun); err != nil {
+		return nil, err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, fleet.ErrNoContext
+	}
+
+	if svc.liveQueryExport == nil {
+		return nil, &badRequestError{message: "the live query export storage is not configured"}
+	}
+
+	campaign, err := svc.ds.DistributedQueryCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "get campaign")
+	}
+	if campaign.UserID != vc.UserID() {
+		return nil, authz.ForbiddenWithInternal("campaign results can only be read by their user", vc.User, campaign, fleet.ActionRead)
+	}
+	if campaign.ExportFormat == "" {
+		return nil, &badRequestError{message: "the results of the campaign are not exported"}
+	}
+	// the results are combined by a cron job once the campaign completed.
+	if campaign.ExportedAt == nil {
+		return nil, &badRequestError{message: "the export is not available until the campaign completed and its results were combined"}
+	}
+
+	now := svc.clock.Now()
+	expiration := svc.config.LiveQuery.ExportURLExpiration
+	url, err := svc.liveQueryExport.CampaignExportURL(ctx, campaignID, campaign.ExportFormat, expiration)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "campaign export URL")
+	}
+	return &fleet.CampaignExport{
+		CampaignID: campaignID,
+		Format:     campaign.ExportFormat,
+		URL:        url,
+		ExpiresAt:  now.Add(expiration).UTC(),
+	}, nil
 }
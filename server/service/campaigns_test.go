@@ -2,14 +2,19 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/WatchBeam/clock"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/mock"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/pubsub"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
 )
 
 type nopLiveQuery struct{}
@@ -18,6 +23,10 @@ func (nopLiveQuery) RunQuery(name, sql string, hostIDs []uint) error {
 	return nil
 }
 
+func (nopLiveQuery) RunQueryWithExpiration(name, sql string, hostIDs []uint, expiration time.Duration) error {
+	return nil
+}
+
 func (nopLiveQuery) StopQuery(name string) error {
 	return nil
 }
@@ -205,30 +214,354 @@ func TestLiveQueryAuth(t *testing.T) {
 			if len(tt.user.Teams) > 0 {
 				tms = []uint{tt.user.Teams[0].ID}
 			}
-			_, err := svc.NewDistributedQueryCampaign(ctx, query1ObsCanRun.Query, nil, fleet.HostTargets{TeamIDs: tms})
+			_, err := svc.NewDistributedQueryCampaign(ctx, query1ObsCanRun.Query, nil, fleet.HostTargets{TeamIDs: tms}, fleet.CampaignOptions{})
 			checkAuthErr(t, tt.shouldFailRunNew, err)
 
 			if tt.teamID != nil {
 				tms = []uint{*tt.teamID}
 			}
-			_, err = svc.NewDistributedQueryCampaign(ctx, query1ObsCanRun.Query, ptr.Uint(query1ObsCanRun.ID), fleet.HostTargets{TeamIDs: tms})
+			_, err = svc.NewDistributedQueryCampaign(ctx, query1ObsCanRun.Query, ptr.Uint(query1ObsCanRun.ID), fleet.HostTargets{TeamIDs: tms}, fleet.CampaignOptions{})
 			checkAuthErr(t, tt.shouldFailRunObsCan, err)
 
-			_, err = svc.NewDistributedQueryCampaign(ctx, query2ObsCannotRun.Query, ptr.Uint(query2ObsCannotRun.ID), fleet.HostTargets{TeamIDs: tms})
+			_, err = svc.NewDistributedQueryCampaign(ctx, query2ObsCannotRun.Query, ptr.Uint(query2ObsCannotRun.ID), fleet.HostTargets{TeamIDs: tms}, fleet.CampaignOptions{})
 			checkAuthErr(t, tt.shouldFailRunObsCannot, err)
 
 			// tests with a team target cannot run the "ByNames" calls, as there's no way
 			// to pass a team target with this call.
 			if tt.teamID == nil {
-				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query1ObsCanRun.Query, nil, nil, nil)
+				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query1ObsCanRun.Query, nil, nil, nil, fleet.CampaignOptions{})
 				checkAuthErr(t, tt.shouldFailRunNew, err)
 
-				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query1ObsCanRun.Query, ptr.Uint(query1ObsCanRun.ID), nil, nil)
+				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query1ObsCanRun.Query, ptr.Uint(query1ObsCanRun.ID), nil, nil, fleet.CampaignOptions{})
 				checkAuthErr(t, tt.shouldFailRunObsCan, err)
 
-				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query2ObsCannotRun.Query, ptr.Uint(query2ObsCannotRun.ID), nil, nil)
+				_, err = svc.NewDistributedQueryCampaignByNames(ctx, query2ObsCannotRun.Query, ptr.Uint(query2ObsCannotRun.ID), nil, nil, fleet.CampaignOptions{})
 				checkAuthErr(t, tt.shouldFailRunObsCannot, err)
 			}
 		})
 	}
 }
+
+func TestListCampaignResults(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	owner := &fleet.User{ID: 1, GlobalRole: ptr.String(fleet.RoleObserver)}
+	admin := &fleet.User{ID: 2, GlobalRole: ptr.String(fleet.RoleAdmin)}
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		if id != 42 {
+			return nil, notFoundError{}
+		}
+		return &fleet.DistributedQueryCampaign{ID: 42, UserID: owner.ID, Status: fleet.QueryComplete}, nil
+	}
+	ds.ListDistributedQueryCampaignResultsFunc = func(ctx context.Context, campaignID uint, opt fleet.ListCampaignResultsOptions) ([]*fleet.DistributedQueryCampaignResult, error) {
+		require.Equal(t, uint(42), campaignID)
+		require.True(t, opt.OnlyErrors)
+		return []*fleet.DistributedQueryCampaignResult{{DistributedQueryCampaignID: 42, HostID: 3, Error: ptr.String("failed")}}, nil
+	}
+	opt := fleet.ListCampaignResultsOptions{OnlyErrors: true}
+
+	// the user that started the campaign can list its results
+	campaign, results, err := svc.ListCampaignResults(viewer.NewContext(context.Background(), viewer.Viewer{User: owner}), 42, opt)
+	require.NoError(t, err)
+	require.Equal(t, uint(42), campaign.ID)
+	require.Len(t, results, 1)
+	require.Equal(t, uint(3), results[0].HostID)
+
+	// other users cannot, even admins
+	_, _, err = svc.ListCampaignResults(viewer.NewContext(context.Background(), viewer.Viewer{User: admin}), 42, opt)
+	checkAuthErr(t, true, err)
+
+	_, _, err = svc.ListCampaignResults(viewer.NewContext(context.Background(), viewer.Viewer{User: owner}), 43, opt)
+	require.True(t, fleet.IsNotFound(err))
+}
+
+func TestListCampaignHosts(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	owner := &fleet.User{ID: 1, GlobalRole: ptr.String(fleet.RoleObserver)}
+	admin := &fleet.User{ID: 2, GlobalRole: ptr.String(fleet.RoleAdmin)}
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		switch id {
+		case 42:
+			return &fleet.DistributedQueryCampaign{ID: 42, UserID: owner.ID, Status: fleet.QueryRunning, CollectUntil: ptr.Time(time.Now().Add(time.Hour))}, nil
+		case 43:
+			return &fleet.DistributedQueryCampaign{ID: 43, UserID: owner.ID, Status: fleet.QueryComplete}, nil
+		}
+		return nil, notFoundError{}
+	}
+	ds.CountDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint) (*fleet.CampaignHostCounts, error) {
+		return &fleet.CampaignHostCounts{Pending: 1, Responded: 2}, nil
+	}
+	ds.ListDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint, opt fleet.ListCampaignHostsOptions) ([]*fleet.DistributedQueryCampaignHost, error) {
+		require.Equal(t, uint(42), campaignID)
+		require.Equal(t, fleet.CampaignHostPending, opt.Status)
+		return []*fleet.DistributedQueryCampaignHost{{HostID: 3, Status: fleet.CampaignHostPending}}, nil
+	}
+	opt := fleet.ListCampaignHostsOptions{Status: fleet.CampaignHostPending}
+	ownerCtx := viewer.NewContext(context.Background(), viewer.Viewer{User: owner})
+
+	// the user that started the campaign can list its hosts
+	campaign, hosts, err := svc.ListCampaignHosts(ownerCtx, 42, opt)
+	require.NoError(t, err)
+	require.Equal(t, uint(42), campaign.ID)
+	require.Equal(t, &fleet.CampaignHostCounts{Pending: 1, Responded: 2}, campaign.HostCounts)
+	require.Len(t, hosts, 1)
+	require.Equal(t, uint(3), hosts[0].HostID)
+
+	// other users cannot, even admins
+	_, _, err = svc.ListCampaignHosts(viewer.NewContext(context.Background(), viewer.Viewer{User: admin}), 42, opt)
+	checkAuthErr(t, true, err)
+
+	// the hosts are only recorded for asynchronous campaigns
+	_, _, err = svc.ListCampaignHosts(ownerCtx, 43, opt)
+	var bre *badRequestError
+	require.ErrorAs(t, err, &bre)
+
+	_, _, err = svc.ListCampaignHosts(ownerCtx, 42, fleet.ListCampaignHostsOptions{Status: "unknown"})
+	var iae *fleet.InvalidArgumentError
+	require.ErrorAs(t, err, &iae)
+
+	_, _, err = svc.ListCampaignHosts(ownerCtx, 44, opt)
+	require.True(t, fleet.IsNotFound(err))
+}
+
+func TestSaveCampaignResult(t *testing.T) {
+	ds := new(mock.Store)
+	svc := &Service{ds: ds}
+
+	settings := fleet.LiveQueryResultsSettings{MaxRowsPerHost: 2, MaxRowsPerCampaign: 10}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{LiveQueryResultsSettings: settings}, nil
+	}
+	var stored *fleet.DistributedQueryCampaignResult
+	ds.NewDistributedQueryCampaignResultFunc = func(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error {
+		require.Equal(t, 10, maxCampaignRows)
+		stored = result
+		return nil
+	}
+
+	res := fleet.DistributedQueryResult{
+		DistributedQueryCampaignID: 42,
+		Host:                       fleet.Host{ID: 1, Hostname: "foo.local"},
+		Rows:                       []map[string]string{{"a": "1"}, nil, {"a": "2"}, {"a": "3"}},
+	}
+	require.NoError(t, svc.saveCampaignResult(context.Background(), res))
+	require.Equal(t, uint(42), stored.DistributedQueryCampaignID)
+	require.Equal(t, uint(1), stored.HostID)
+	require.Equal(t, "foo.local", stored.Hostname)
+	// the nil row is ignored, and the rows are truncated to the host limit
+	require.Equal(t, []map[string]string{{"a": "1"}, {"a": "2"}}, stored.Rows)
+	require.Equal(t, 3, stored.RowCount)
+	require.True(t, stored.Truncated)
+
+	// without limit, all the rows are stored
+	settings.MaxRowsPerHost = 0
+	require.NoError(t, svc.saveCampaignResult(context.Background(), res))
+	require.Len(t, stored.Rows, 3)
+	require.False(t, stored.Truncated)
+}
+
+func TestExportCampaignResult(t *testing.T) {
+	ds := new(mock.Store)
+	store := new(mock.LiveQueryExportStore)
+	mockClock := clock.NewMockClock()
+	exporter := newLiveQueryExporter(ds, store, kitlog.NewNopLogger())
+	svc := &Service{ds: ds, liveQueryExport: store, liveQueryExporter: exporter, clock: mockClock}
+
+	campaignReads := 0
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		campaignReads++
+		if id == 42 {
+			return &fleet.DistributedQueryCampaign{ID: 42, ExportFormat: fleet.LiveQueryExportCSV}, nil
+		}
+		return &fleet.DistributedQueryCampaign{ID: id}, nil
+	}
+	var exported []*fleet.DistributedQueryCampaignResult
+	store.WriteCampaignResultPartFunc = func(ctx context.Context, format fleet.LiveQueryExportFormat, result *fleet.DistributedQueryCampaignResult) error {
+		require.Equal(t, fleet.LiveQueryExportCSV, format)
+		exported = append(exported, result)
+		return nil
+	}
+
+	res := fleet.DistributedQueryResult{
+		DistributedQueryCampaignID: 42,
+		Host:                       fleet.Host{ID: 1, Hostname: "foo.local"},
+		Rows:                       []map[string]string{{"a": "1"}, nil, {"a": "2"}, {"a": "3"}},
+	}
+	// the result is queued, without reading the campaign nor writing it
+	require.NoError(t, svc.exportCampaignResult(context.Background(), res))
+	require.Zero(t, campaignReads)
+	require.False(t, store.WriteCampaignResultPartFuncInvoked)
+	require.Len(t, exporter.queue, 1)
+
+	queued := <-exporter.queue
+	require.NoError(t, exporter.write(context.Background(), queued))
+	require.Len(t, exported, 1)
+	require.Equal(t, uint(42), exported[0].DistributedQueryCampaignID)
+	require.Equal(t, uint(1), exported[0].HostID)
+	require.Equal(t, "foo.local", exported[0].Hostname)
+	// the nil row is ignored, and the rows are never truncated
+	require.Equal(t, []map[string]string{{"a": "1"}, {"a": "2"}, {"a": "3"}}, exported[0].Rows)
+	require.False(t, exported[0].Truncated)
+	require.Equal(t, mockClock.Now().UTC(), exported[0].CreatedAt)
+
+	// the export format of the campaign is only read once
+	require.NoError(t, exporter.write(context.Background(), queued))
+	require.Len(t, exported, 2)
+	require.Equal(t, 1, campaignReads)
+
+	// the results of the campaigns that are not exported are not written
+	require.NoError(t, exporter.write(context.Background(), &fleet.DistributedQueryCampaignResult{DistributedQueryCampaignID: 43, HostID: 1}))
+	require.Len(t, exported, 2)
+	require.Equal(t, 2, campaignReads)
+
+	// once the queue is full, the results wait for room in the queue and are
+	// never written synchronously
+	exporter.queueTimeout = 10 * time.Millisecond
+	for i := 0; i < liveQueryExportQueueSize; i++ {
+		require.NoError(t, svc.exportCampaignResult(context.Background(), res))
+	}
+	require.Error(t, svc.exportCampaignResult(context.Background(), res))
+	require.Len(t, exported, 2)
+
+	// nothing is exported if the export is not configured
+	svc.liveQueryExporter = nil
+	require.NoError(t, svc.exportCampaignResult(context.Background(), res))
+	require.Len(t, exported, 2)
+	require.Len(t, exporter.queue, liveQueryExportQueueSize)
+
+	// the queued results are written in the background
+	written := make(chan *fleet.DistributedQueryCampaignResult, liveQueryExportQueueSize)
+	exporter = newLiveQueryExporter(ds, chanLiveQueryExportStore{written}, kitlog.NewNopLogger())
+	exporter.formats[42] = fleet.LiveQueryExportCSV
+	for i := 0; i < liveQueryExportQueueSize; i++ {
+		require.NoError(t, exporter.export(context.Background(), queued))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exporter.start(ctx)
+	for i := 0; i < liveQueryExportQueueSize; i++ {
+		select {
+		case <-written:
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for the queued results to be written")
+		}
+	}
+}
+
+func TestLiveQueryExporterDrain(t *testing.T) {
+	ds := new(mock.Store)
+	written := make(chan *fleet.DistributedQueryCampaignResult, liveQueryExportQueueSize)
+	exporter := newLiveQueryExporter(ds, chanLiveQueryExportStore{written}, kitlog.NewNopLogger())
+	exporter.formats[42] = fleet.LiveQueryExportCSV
+
+	result := &fleet.DistributedQueryCampaignResult{DistributedQueryCampaignID: 42, HostID: 1}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, exporter.export(context.Background(), result))
+	}
+
+	// the queued results are written on shutdown, and waited for
+	var tasks sync.WaitGroup
+	ctx, cancel := context.WithCancel(WithBackgroundTasks(context.Background(), &tasks))
+	cancel()
+	exporter.start(ctx)
+	tasks.Wait()
+	require.Len(t, written, 10)
+	require.Empty(t, exporter.queue)
+
+	// the results can't be queued anymore once the exporter is stopped
+	require.Error(t, exporter.export(context.Background(), result))
+	require.Empty(t, exporter.queue)
+}
+
+// chanLiveQueryExportStore sends the written results to a channel.
+type chanLiveQueryExportStore struct {
+	written chan<- *fleet.DistributedQueryCampaignResult
+}
+
+func (s chanLiveQueryExportStore) WriteCampaignResultPart(ctx context.Context, format fleet.LiveQueryExportFormat, result *fleet.DistributedQueryCampaignResult) error {
+	s.written <- result
+	return nil
+}
+
+func (s chanLiveQueryExportStore) CombineCampaignExport(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat) error {
+	return nil
+}
+
+func (s chanLiveQueryExportStore) CampaignExportURL(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat, expiration time.Duration) (string, error) {
+	return "", nil
+}
+
+func TestCampaignExport(t *testing.T) {
+	ds := new(mock.Store)
+	store := new(mock.LiveQueryExportStore)
+	mockClock := clock.NewMockClock()
+	svc := newTestService(t, ds, nil, nil, &TestServerOpts{Clock: mockClock, LiveQueryExport: store})
+
+	owner := &fleet.User{ID: 1, GlobalRole: ptr.String(fleet.RoleObserver)}
+	admin := &fleet.User{ID: 2, GlobalRole: ptr.String(fleet.RoleAdmin)}
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		exportedAt := ptr.Time(mockClock.Now().Add(-time.Minute))
+		switch id {
+		case 42:
+			return &fleet.DistributedQueryCampaign{ID: 42, UserID: owner.ID, Status: fleet.QueryComplete, ExportFormat: fleet.LiveQueryExportCSV, ExportedAt: exportedAt}, nil
+		case 43:
+			return &fleet.DistributedQueryCampaign{ID: 43, UserID: owner.ID, Status: fleet.QueryComplete}, nil
+		case 44:
+			// the results of the campaign were not combined yet
+			return &fleet.DistributedQueryCampaign{ID: 44, UserID: owner.ID, Status: fleet.QueryComplete, ExportFormat: fleet.LiveQueryExportCSV}, nil
+		case 45:
+			// the collection window of the asynchronous campaign ended
+			return &fleet.DistributedQueryCampaign{
+				ID: 45, UserID: owner.ID, Status: fleet.QueryRunning, ExportFormat: fleet.LiveQueryExportNDJSON,
+				CollectUntil: ptr.Time(mockClock.Now().Add(-time.Minute)), ExportedAt: exportedAt,
+			}, nil
+		}
+		return nil, notFoundError{}
+	}
+	store.CampaignExportURLFunc = func(ctx context.Context, campaignID uint, format fleet.LiveQueryExportFormat, expiration time.Duration) (string, error) {
+		require.Equal(t, time.Hour, expiration)
+		return fmt.Sprintf("https://example.com/%d/results.%s", campaignID, format), nil
+	}
+	ownerCtx := viewer.NewContext(context.Background(), viewer.Viewer{User: owner})
+
+	// the user that started the campaign can download its results
+	export, err := svc.CampaignExport(ownerCtx, 42)
+	require.NoError(t, err)
+	// the results are only combined by the cron job
+	require.False(t, store.CombineCampaignExportFuncInvoked)
+	require.Equal(t, &fleet.CampaignExport{
+		CampaignID: 42,
+		Format:     fleet.LiveQueryExportCSV,
+		URL:        "https://example.com/42/results.csv",
+		ExpiresAt:  mockClock.Now().Add(time.Hour).UTC(),
+	}, export)
+
+	export, err = svc.CampaignExport(ownerCtx, 45)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/45/results.ndjson", export.URL)
+
+	// other users cannot, even admins
+	_, err = svc.CampaignExport(viewer.NewContext(context.Background(), viewer.Viewer{User: admin}), 42)
+	checkAuthErr(t, true, err)
+
+	// the campaign must be exported and its results combined
+	var bre *badRequestError
+	_, err = svc.CampaignExport(ownerCtx, 43)
+	require.ErrorAs(t, err, &bre)
+	_, err = svc.CampaignExport(ownerCtx, 44)
+	require.ErrorAs(t, err, &bre)
+
+	_, err = svc.CampaignExport(ownerCtx, 46)
+	require.True(t, fleet.IsNotFound(err))
+
+	// the export storage must be configured
+	svc = newTestService(t, ds, nil, nil)
+	_, err = svc.CampaignExport(ownerCtx, 42)
+	require.ErrorAs(t, err, &bre)
+}
@@ -267,6 +267,15 @@ func (c *Client) ApplyGroup(ctx context.Context, specs *spec.Group, logf func(fo
 		logfn("[+] applied enroll secrets\n")
 	}
 
+	// custom roles are applied before the teams and the user roles, that may
+	// assign them.
+	if len(specs.CustomRoles) > 0 {
+		if err := c.ApplyCustomRoles(specs.CustomRoles); err != nil {
+			return fmt.Errorf("applying custom roles: %w", err)
+		}
+		logfn("[+] applied %d custom roles\n", len(specs.CustomRoles))
+	}
+
 	if len(specs.Teams) > 0 {
 		if err := c.ApplyTeams(specs.Teams); err != nil {
 			return fmt.Errorf("applying teams: %w", err)
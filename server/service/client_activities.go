@@ -0,0 +1,42 @@
+package service
+
+import (
+	"net/url"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// ListActivities retrieves the activities matching the query.
+func (c *Client) ListActivities(query string) ([]*fleet.Activity, error) {
+	verb, path := "GET", "/api/latest/fleet/activities"
+	var responseBody listActivitiesResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	if err != nil {
+		return nil, err
+	}
+	return responseBody.Activities, nil
+}
+
+// ListArchivedActivityMonths retrieves the months that have archived
+// activities.
+func (c *Client) ListArchivedActivityMonths() ([]string, error) {
+	verb, path := "GET", "/api/latest/fleet/activities/archive"
+	var responseBody listArchivedActivityMonthsResponse
+	err := c.authenticatedRequest(nil, verb, path, &responseBody)
+	if err != nil {
+		return nil, err
+	}
+	return responseBody.Months, nil
+}
+
+// ListArchivedActivities retrieves the activities archived for the month
+// (e.g. "2022-08") matching the query.
+func (c *Client) ListArchivedActivities(month, query string) ([]*fleet.Activity, error) {
+	verb, path := "GET", "/api/latest/fleet/activities/archive/"+url.PathEscape(month)
+	var responseBody listActivitiesResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	if err != nil {
+		return nil, err
+	}
+	return responseBody.Activities, nil
+}
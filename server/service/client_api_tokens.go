@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// Me returns the user authenticated with the token of the client.
+func (c *Client) Me() (*fleet.User, error) {
+	verb, path := "GET", "/api/latest/fleet/me"
+	var responseBody getUserResponse
+	if err := c.authenticatedRequest(nil, verb, path, &responseBody); err != nil {
+		return nil, err
+	}
+	return responseBody.User, nil
+}
+
+// CreateAPIToken creates a personal API token for the current user. The key
+// of the token is only returned by this call.
+func (c *Client) CreateAPIToken(p fleet.APITokenPayload) (*fleet.APIToken, error) {
+	me, err := c.Me()
+	if err != nil {
+		return nil, err
+	}
+
+	verb, path := "POST", fmt.Sprintf("/api/latest/fleet/users/%d/api_tokens", me.ID)
+	var responseBody createAPITokenResponse
+	if err := c.authenticatedRequest(p, verb, path, &responseBody); err != nil {
+		return nil, err
+	}
+	return responseBody.APIToken, nil
+}
+
+// ListAPITokens retrieves the personal API tokens of the current user.
+func (c *Client) ListAPITokens() ([]*fleet.APIToken, error) {
+	me, err := c.Me()
+	if err != nil {
+		return nil, err
+	}
+
+	verb, path := "GET", fmt.Sprintf("/api/latest/fleet/users/%d/api_tokens", me.ID)
+	var responseBody listAPITokensResponse
+	if err := c.authenticatedRequest(nil, verb, path, &responseBody); err != nil {
+		return nil, err
+	}
+	return responseBody.APITokens, nil
+}
+
+// DeleteAPIToken revokes the personal API token of the current user.
+func (c *Client) DeleteAPIToken(id uint) error {
+	me, err := c.Me()
+	if err != nil {
+		return err
+	}
+
+	verb, path := "DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/api_tokens/%d", me.ID, id)
+	var responseBody deleteAPITokenResponse
+	return c.authenticatedRequest(nil, verb, path, &responseBody)
+}
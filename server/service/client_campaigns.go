@@ -0,0 +1,43 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// ListCampaignResults retrieves the campaign and its stored results matching
+// the query.
+func (c *Client) ListCampaignResults(campaignID uint, query string) (*fleet.DistributedQueryCampaign, []*fleet.DistributedQueryCampaignResult, error) {
+	verb, path := "GET", fmt.Sprintf("/api/latest/fleet/queries/run/%d/results", campaignID)
+	var responseBody listCampaignResultsResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return responseBody.Campaign, responseBody.Results, nil
+}
+
+// ListCampaignHosts retrieves the asynchronous campaign and its targeted hosts
+// matching the query.
+func (c *Client) ListCampaignHosts(campaignID uint, query string) (*fleet.DistributedQueryCampaign, []*fleet.DistributedQueryCampaignHost, error) {
+	verb, path := "GET", fmt.Sprintf("/api/latest/fleet/queries/run/%d/hosts", campaignID)
+	var responseBody listCampaignHostsResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return responseBody.Campaign, responseBody.Hosts, nil
+}
+
+// CampaignExport retrieves the download link of the exported results of the
+// completed campaign.
+func (c *Client) CampaignExport(campaignID uint) (*fleet.CampaignExport, error) {
+	verb, path := "GET", fmt.Sprintf("/api/latest/fleet/queries/run/%d/export", campaignID)
+	var responseBody getCampaignExportResponse
+	err := c.authenticatedRequest(nil, verb, path, &responseBody)
+	if err != nil {
+		return nil, err
+	}
+	return responseBody.Export, nil
+}
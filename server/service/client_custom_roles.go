@@ -0,0 +1,39 @@
+package service
+
+import (
+	"net/url"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// ApplyCustomRoles sends the list of custom roles to be applied (upserted) to
+// the Fleet instance.
+func (c *Client) ApplyCustomRoles(specs []*fleet.CustomRoleSpec) error {
+	req := applyCustomRoleSpecsRequest{Specs: specs}
+	verb, path := "POST", "/api/latest/fleet/spec/custom_roles"
+	var responseBody applyCustomRoleSpecsResponse
+	return c.authenticatedRequest(req, verb, path, &responseBody)
+}
+
+// GetCustomRole retrieves the spec of a custom role by name.
+func (c *Client) GetCustomRole(name string) (*fleet.CustomRoleSpec, error) {
+	verb, path := "GET", "/api/latest/fleet/spec/custom_roles/"+url.PathEscape(name)
+	var responseBody getCustomRoleSpecResponse
+	err := c.authenticatedRequest(nil, verb, path, &responseBody)
+	return responseBody.Spec, err
+}
+
+// GetCustomRoles retrieves the specs of all the custom roles.
+func (c *Client) GetCustomRoles() ([]*fleet.CustomRoleSpec, error) {
+	verb, path := "GET", "/api/latest/fleet/spec/custom_roles"
+	var responseBody getCustomRoleSpecsResponse
+	err := c.authenticatedRequest(nil, verb, path, &responseBody)
+	return responseBody.Specs, err
+}
+
+// DeleteCustomRole deletes the custom role with the matching name.
+func (c *Client) DeleteCustomRole(name string) error {
+	verb, path := "DELETE", "/api/latest/fleet/custom_roles/"+url.PathEscape(name)
+	var responseBody deleteCustomRoleResponse
+	return c.authenticatedRequest(nil, verb, path, &responseBody)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"sync/atomic"
 	"time"
@@ -20,16 +21,20 @@ import (
 // LiveQueryResultsHandler provides access to all of the information about an
 // incoming stream of live query results.
 type LiveQueryResultsHandler struct {
-	errors  chan error
-	results chan fleet.DistributedQueryResult
-	totals  atomic.Value // real type: targetTotals
-	status  atomic.Value // real type: campaignStatus
+	errors       chan error
+	results      chan fleet.DistributedQueryResult
+	hostStatuses chan fleet.LiveQueryHostStatus
+	totals       atomic.Value // real type: targetTotals
+	status       atomic.Value // real type: campaignStatus
+	aggregate    atomic.Value // real type: fleet.LiveQueryAggregate
+	campaignID   uint
 }
 
 func NewLiveQueryResultsHandler() *LiveQueryResultsHandler {
 	return &LiveQueryResultsHandler{
-		errors:  make(chan error),
-		results: make(chan fleet.DistributedQueryResult),
+		errors:       make(chan error),
+		results:      make(chan fleet.DistributedQueryResult),
+		hostStatuses: make(chan fleet.LiveQueryHostStatus),
 	}
 }
 
@@ -44,6 +49,22 @@ func (h *LiveQueryResultsHandler) Results() <-chan fleet.DistributedQueryResult
 	return h.results
 }
 
+// HostStatuses returns a read channel including the status of the hosts that
+// responded to an aggregated live query.
+func (h *LiveQueryResultsHandler) HostStatuses() <-chan fleet.LiveQueryHostStatus {
+	return h.hostStatuses
+}
+
+// Aggregate returns the latest running aggregate of an aggregated live query,
+// or nil if none was received yet.
+func (h *LiveQueryResultsHandler) Aggregate() *fleet.LiveQueryAggregate {
+	a := h.aggregate.Load()
+	if a != nil {
+		return a.(*fleet.LiveQueryAggregate)
+	}
+	return nil
+}
+
 // Totals returns the current metadata of hosts targeted by the query
 func (h *LiveQueryResultsHandler) Totals() *targetTotals {
 	t := h.totals.Load()
@@ -53,6 +74,12 @@ func (h *LiveQueryResultsHandler) Totals() *targetTotals {
 	return nil
 }
 
+// CampaignID returns the ID of the campaign of the live query, its stored
+// results can be listed with ListCampaignResults.
+func (h *LiveQueryResultsHandler) CampaignID() uint {
+	return h.campaignID
+}
+
 func (h *LiveQueryResultsHandler) Status() *campaignStatus {
 	s := h.status.Load()
 	if s != nil {
@@ -61,15 +88,61 @@ func (h *LiveQueryResultsHandler) Status() *campaignStatus {
 	return nil
 }
 
+// AsyncLiveQuery creates a new asynchronous live query, which collects the
+// results of the targeted hosts for the collection window of the options. The
+// results are retrieved later on with ListCampaignResults, or CampaignExport if
+// the export format of the options is set.
+func (c *Client) AsyncLiveQuery(query string, labels []string, hosts []string, opts fleet.CampaignOptions) (*fleet.DistributedQueryCampaign, error) {
+	req := createDistributedQueryCampaignByNamesRequest{
+		QuerySQL:      query,
+		Selected:      distributedQueryCampaignTargetsByNames{Labels: labels, Hosts: hosts},
+		CollectWindow: fleet.Duration{Duration: opts.CollectWindow},
+		ExportFormat:  opts.ExportFormat,
+	}
+	verb, path := "POST", "/api/latest/fleet/queries/run_by_names"
+	var responseBody createDistributedQueryCampaignResponse
+	if err := c.authenticatedRequest(req, verb, path, &responseBody); err != nil {
+		return nil, fmt.Errorf("create async live query: %w", err)
+	}
+	return responseBody.Campaign, nil
+}
+
 // LiveQuery creates a new live query and begins streaming results.
 func (c *Client) LiveQuery(query string, labels []string, hosts []string) (*LiveQueryResultsHandler, error) {
 	return c.LiveQueryWithContext(context.Background(), query, labels, hosts)
 }
 
 func (c *Client) LiveQueryWithContext(ctx context.Context, query string, labels []string, hosts []string) (*LiveQueryResultsHandler, error) {
+	return c.LiveQueryWithOptions(ctx, query, labels, hosts, LiveQueryOptions{})
+}
+
+// AggregatedLiveQuery creates a new live query whose results are aggregated
+// by the server. The running aggregate is available from the Aggregate method
+// of the handler, and the status of the hosts that responded is received
+// instead of their results.
+func (c *Client) AggregatedLiveQuery(query string, labels []string, hosts []string, aggregation fleet.LiveQueryAggregation) (*LiveQueryResultsHandler, error) {
+	return c.LiveQueryWithOptions(context.Background(), query, labels, hosts, LiveQueryOptions{Aggregation: &aggregation})
+}
+
+// LiveQueryOptions are the options of a streamed live query.
+type LiveQueryOptions struct {
+	// Aggregation makes the server aggregate the results, see
+	// AggregatedLiveQuery.
+	Aggregation *fleet.LiveQueryAggregation
+	// ExportFormat exports the results of the campaign in that format, the
+	// download link is retrieved with CampaignExport once it completed.
+	ExportFormat fleet.LiveQueryExportFormat
+}
+
+// LiveQueryWithOptions creates a new live query with the options and begins
+// streaming results.
+func (c *Client) LiveQueryWithOptions(
+	ctx context.Context, query string, labels []string, hosts []string, opts LiveQueryOptions,
+) (*LiveQueryResultsHandler, error) {
 	req := createDistributedQueryCampaignByNamesRequest{
-		QuerySQL: query,
-		Selected: distributedQueryCampaignTargetsByNames{Labels: labels, Hosts: hosts},
+		QuerySQL:     query,
+		Selected:     distributedQueryCampaignTargetsByNames{Labels: labels, Hosts: hosts},
+		ExportFormat: opts.ExportFormat,
 	}
 	verb, path := "POST", "/api/latest/fleet/queries/run_by_names"
 	var responseBody createDistributedQueryCampaignResponse
@@ -108,9 +181,13 @@ func (c *Client) LiveQueryWithContext(ctx context.Context, query string, labels
 		return nil, ctxerr.Wrap(ctx, err, "auth for results")
 	}
 
+	selectData := map[string]interface{}{"campaign_id": responseBody.Campaign.ID}
+	if opts.Aggregation != nil {
+		selectData["aggregation"] = opts.Aggregation
+	}
 	err = conn.WriteJSON(ws.JSONMessage{
 		Type: "select_campaign",
-		Data: map[string]interface{}{"campaign_id": responseBody.Campaign.ID},
+		Data: selectData,
 	})
 	if err != nil {
 		_ = conn.Close()
@@ -118,6 +195,7 @@ func (c *Client) LiveQueryWithContext(ctx context.Context, query string, labels
 	}
 
 	resHandler := NewLiveQueryResultsHandler()
+	resHandler.campaignID = responseBody.Campaign.ID
 	go func() {
 		defer conn.Close()
 		for {
@@ -153,6 +231,20 @@ func (c *Client) LiveQueryWithContext(ctx context.Context, query string, labels
 				}
 				resHandler.results <- res
 
+			case "host_status":
+				var status fleet.LiveQueryHostStatus
+				if err := json.Unmarshal(msg.Data, &status); err != nil {
+					resHandler.errors <- ctxerr.Wrap(ctx, err, "unmarshal host status")
+				}
+				resHandler.hostStatuses <- status
+
+			case "aggregate":
+				var aggregate fleet.LiveQueryAggregate
+				if err := json.Unmarshal(msg.Data, &aggregate); err != nil {
+					resHandler.errors <- ctxerr.Wrap(ctx, err, "unmarshal aggregate")
+				}
+				resHandler.aggregate.Store(&aggregate)
+
 			case "totals":
 				var totals targetTotals
 				if err := json.Unmarshal(msg.Data, &totals); err != nil {
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"net/url"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -37,3 +38,20 @@ func (c *Client) DeleteQuery(name string) error {
 	var responseBody deleteQueryResponse
 	return c.authenticatedRequest(nil, verb, path, &responseBody)
 }
+
+// ListQueries retrieves the saved queries matching the query.
+func (c *Client) ListQueries(query string) ([]fleet.Query, error) {
+	verb, path := "GET", "/api/latest/fleet/queries"
+	var responseBody listQueriesResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	return responseBody.Queries, err
+}
+
+// GetQueryReport retrieves the rows of the report of the query matching the
+// query.
+func (c *Client) GetQueryReport(queryID uint, query string) ([]*fleet.QueryReportRow, error) {
+	verb, path := "GET", fmt.Sprintf("/api/latest/fleet/queries/%d/report", queryID)
+	var responseBody getQueryReportResponse
+	err := c.authenticatedRequestWithQuery(nil, verb, path, &responseBody, query)
+	return responseBody.Results, err
+}
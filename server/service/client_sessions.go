@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
 )
 
 // Login attempts to login to the current Fleet instance. If login is successful,
@@ -23,6 +25,15 @@ func (c *Client) Login(email, password string) (string, error) {
 	switch response.StatusCode {
 	case http.StatusNotFound:
 		return "", notSetupErr{}
+	case http.StatusAccepted:
+		var totpResponse loginTOTPRequiredResponse
+		if err := json.NewDecoder(response.Body).Decode(&totpResponse); err != nil {
+			return "", fmt.Errorf("decode login response: %w", err)
+		}
+		return "", &TOTPRequiredErr{
+			Token:              totpResponse.TOTPToken,
+			EnrollmentRequired: totpResponse.TOTPEnrollmentRequired,
+		}
 	}
 	if response.StatusCode != http.StatusOK {
 		return "", fmt.Errorf(
@@ -45,6 +56,68 @@ func (c *Client) Login(email, password string) (string, error) {
 	return responseBody.Token, nil
 }
 
+// LoginTOTP completes the two-factor authentication challenge of a login
+// with a TOTP code or a recovery code. If login is successful, an auth token
+// is returned, along with the recovery codes of the user if they enrolled as
+// part of the login.
+func (c *Client) LoginTOTP(token, code string) (string, []string, error) {
+	params := loginTOTPRequest{
+		TOTPToken: token,
+		Code:      code,
+	}
+
+	response, err := c.Do("POST", "/api/latest/fleet/login/totp", "", params)
+	if err != nil {
+		return "", nil, fmt.Errorf("POST /api/latest/fleet/login/totp: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf(
+			"login received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+
+	var responseBody loginResponse
+	if err := json.NewDecoder(response.Body).Decode(&responseBody); err != nil {
+		return "", nil, fmt.Errorf("decode login response: %w", err)
+	}
+	if responseBody.Err != nil {
+		return "", nil, fmt.Errorf("login: %s", responseBody.Err)
+	}
+
+	return responseBody.Token, responseBody.RecoveryCodes, nil
+}
+
+// EnrollTOTPForLogin enrolls the user of a two-factor authentication
+// challenge when the enrollment is required. The returned secret must be added
+// to an authenticator app, then the challenge is completed with LoginTOTP.
+func (c *Client) EnrollTOTPForLogin(token string) (*fleet.TOTPEnrollment, error) {
+	params := enrollTOTPForLoginRequest{TOTPToken: token}
+
+	response, err := c.Do("POST", "/api/latest/fleet/login/totp/enroll", "", params)
+	if err != nil {
+		return nil, fmt.Errorf("POST /api/latest/fleet/login/totp/enroll: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf(
+			"two-factor enrollment received status %d %s",
+			response.StatusCode,
+			extractServerErrorText(response.Body),
+		)
+	}
+
+	var enrollment fleet.TOTPEnrollment
+	if err := json.NewDecoder(response.Body).Decode(&enrollment); err != nil {
+		return nil, fmt.Errorf("decode two-factor enrollment response: %w", err)
+	}
+	return &enrollment, nil
+}
+
 // Logout attempts to logout to the current Fleet instance.
 func (c *Client) Logout() error {
 	verb, path := "POST", "/api/latest/fleet/logout"
@@ -56,6 +56,18 @@ func (c *Client) userIdFromEmail(email string) (uint, error) {
 	return responseBody.List[0].Payload.ID, nil
 }
 
+// UnlockUser unlocks the account of the user specified by the email
+func (c *Client) UnlockUser(email string) error {
+	userID, err := c.userIdFromEmail(email)
+	if err != nil {
+		return err
+	}
+
+	verb, path := "POST", fmt.Sprintf("/api/latest/fleet/users/%d/unlock", userID)
+	var responseBody unlockUserResponse
+	return c.authenticatedRequest(nil, verb, path, &responseBody)
+}
+
 // DeleteUser deletes the user specified by the email
 func (c *Client) DeleteUser(email string) error {
 	userID, err := c.userIdFromEmail(email)
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Apply Custom Role Specs
+////////////////////////////////////////////////////////////////////////////////
+
+type applyCustomRoleSpecsRequest struct {
+	Specs []*fleet.CustomRoleSpec `json:"specs"`
+}
+
+type applyCustomRoleSpecsResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r applyCustomRoleSpecsResponse) error() error { return r.Err }
+
+func applyCustomRoleSpecsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*applyCustomRoleSpecsRequest)
+	if err := svc.ApplyCustomRoleSpecs(ctx, req.Specs); err != nil {
+		return applyCustomRoleSpecsResponse{Err: err}, nil
+	}
+	return applyCustomRoleSpecsResponse{}, nil
+}
+
+func (svc *Service) ApplyCustomRoleSpecs(ctx context.Context, specs []*fleet.CustomRoleSpec) error {
+	if err := svc.authz.Authorize(ctx, &fleet.CustomRole{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("specs", err.Error()))
+		}
+	}
+
+	if err := svc.ds.ApplyCustomRoleSpecs(ctx, specs); err != nil {
+		return ctxerr.Wrap(ctx, err, "apply custom role specs")
+	}
+	if err := svc.authz.ReloadCustomRoles(ctx); err != nil {
+		return ctxerr.Wrap(ctx, err, "reload custom roles")
+	}
+
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeAppliedSpecCustomRole,
+		&map[string]interface{}{"names": names},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create applied custom role spec activity")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Get Custom Role Specs
+////////////////////////////////////////////////////////////////////////////////
+
+type getCustomRoleSpecsResponse struct {
+	Specs []*fleet.CustomRoleSpec `json:"specs"`
+	Err   error                   `json:"error,omitempty"`
+}
+
+func (r getCustomRoleSpecsResponse) error() error { return r.Err }
+
+func getCustomRoleSpecsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	specs, err := svc.GetCustomRoleSpecs(ctx)
+	if err != nil {
+		return getCustomRoleSpecsResponse{Err: err}, nil
+	}
+	return getCustomRoleSpecsResponse{Specs: specs}, nil
+}
+
+func (svc *Service) GetCustomRoleSpecs(ctx context.Context) ([]*fleet.CustomRoleSpec, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.CustomRole{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+
+	roles, err := svc.ds.ListCustomRoles(ctx)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list custom roles")
+	}
+	specs := make([]*fleet.CustomRoleSpec, 0, len(roles))
+	for _, role := range roles {
+		specs = append(specs, customRoleToSpec(role))
+	}
+	return specs, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Get Custom Role Spec
+////////////////////////////////////////////////////////////////////////////////
+
+type getCustomRoleSpecResponse struct {
+	Spec *fleet.CustomRoleSpec `json:"specs,omitempty"`
+	Err  error                 `json:"error,omitempty"`
+}
+
+func (r getCustomRoleSpecResponse) error() error { return r.Err }
+
+func getCustomRoleSpecEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*getGenericSpecRequest)
+	spec, err := svc.GetCustomRoleSpec(ctx, req.Name)
+	if err != nil {
+		return getCustomRoleSpecResponse{Err: err}, nil
+	}
+	return getCustomRoleSpecResponse{Spec: spec}, nil
+}
+
+func (svc *Service) GetCustomRoleSpec(ctx context.Context, name string) (*fleet.CustomRoleSpec, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.CustomRole{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+
+	role, err := svc.ds.CustomRoleByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return customRoleToSpec(role), nil
+}
+
+func customRoleToSpec(role *fleet.CustomRole) *fleet.CustomRoleSpec {
+	return &fleet.CustomRoleSpec{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Delete Custom Role
+////////////////////////////////////////////////////////////////////////////////
+
+type deleteCustomRoleRequest struct {
+	Name string `url:"name"`
+}
+
+type deleteCustomRoleResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r deleteCustomRoleResponse) error() error { return r.Err }
+
+func deleteCustomRoleEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*deleteCustomRoleRequest)
+	if err := svc.DeleteCustomRole(ctx, req.Name); err != nil {
+		return deleteCustomRoleResponse{Err: err}, nil
+	}
+	return deleteCustomRoleResponse{}, nil
+}
+
+func (svc *Service) DeleteCustomRole(ctx context.Context, name string) error {
+	if err := svc.authz.Authorize(ctx, &fleet.CustomRole{}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	if err := svc.ds.DeleteCustomRole(ctx, name); err != nil {
+		return err
+	}
+	if err := svc.authz.ReloadCustomRoles(ctx); err != nil {
+		return ctxerr.Wrap(ctx, err, "reload custom roles")
+	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeDeletedCustomRole,
+		&map[string]interface{}{"name": name},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create deleted custom role activity")
+	}
+	return nil
+}
+
+// validateRole validates the global and team roles of a user or an invite.
+// The custom roles are only loaded if one of the roles is not a built-in role.
+func (svc *Service) validateRole(ctx context.Context, globalRole *string, teams []fleet.UserTeam) error {
+	var customRoles map[string]bool
+	if (&fleet.User{GlobalRole: globalRole, Teams: teams}).HasCustomRole() {
+		roles, err := svc.ds.ListCustomRoles(ctx)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "list custom roles")
+		}
+		customRoles = make(map[string]bool, len(roles))
+		for _, role := range roles {
+			customRoles[role.Name] = true
+		}
+	}
+	return fleet.ValidateRoleWithCustomRoles(globalRole, teams, customRoles)
+}
@@ -35,7 +35,8 @@ func (m *debugAuthenticationMiddleware) Middleware(next http.Handler) http.Handl
 			return
 		}
 
-		if !v.CanPerformActions() {
+		// the debug endpoints are not covered by the scopes of the API tokens
+		if !v.CanPerformActions() || v.APIToken != nil {
 			http.Error(w, "Unauthorized", http.StatusForbidden)
 			return
 		}
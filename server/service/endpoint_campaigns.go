@@ -82,7 +82,8 @@ func makeStreamDistributedQueryCampaignResultsHandler(svc fleet.Service, logger
 			}
 
 			var info struct {
-				CampaignID uint `json:"campaign_id"`
+				CampaignID  uint                        `json:"campaign_id"`
+				Aggregation *fleet.LiveQueryAggregation `json:"aggregation"`
 			}
 			err = json.Unmarshal(*(msg.Data.(*json.RawMessage)), &info)
 			if err != nil {
@@ -96,7 +97,7 @@ func makeStreamDistributedQueryCampaignResultsHandler(svc fleet.Service, logger
 				return
 			}
 
-			svc.StreamCampaignResults(ctx, conn, info.CampaignID)
+			svc.StreamCampaignResults(ctx, conn, info.CampaignID, info.Aggregation)
 		}
 
 		// multiplex the requests to each literal path that this endpoint support,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/fleetdm/fleet/v4/server/contexts/logging"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -170,6 +171,28 @@ func authenticatedUser(svc fleet.Service, next endpoint.Endpoint) endpoint.Endpo
 	return logged(authUserFunc)
 }
 
+// authenticatedSCIM wraps an endpoint and requires that the request is
+// authenticated with the SCIM bearer token of the server configuration.
+func authenticatedSCIM(svc fleet.Service, next endpoint.Endpoint) endpoint.Endpoint {
+	authSCIMFunc := func(ctx context.Context, request interface{}) (interface{}, error) {
+		bearer, ok := token.FromContext(ctx)
+		if !ok {
+			return nil, fleet.NewAuthHeaderRequiredError("no auth token")
+		}
+
+		if err := svc.AuthenticateSCIM(ctx, string(bearer)); err != nil {
+			return nil, err
+		}
+
+		logging.WithExtras(logging.WithNoUser(ctx), "authn", "scim")
+		if ac, ok := authz_ctx.FromContext(ctx); ok {
+			ac.SetAuthnMethod(authz_ctx.AuthnSCIMToken)
+		}
+		return next(ctx, request)
+	}
+	return logged(authSCIMFunc)
+}
+
 func unauthenticatedRequest(svc fleet.Service, next endpoint.Endpoint) endpoint.Endpoint {
 	return logged(next)
 }
@@ -192,8 +215,13 @@ func logged(next endpoint.Endpoint) endpoint.Endpoint {
 	}
 }
 
-// authViewer creates an authenticated viewer by validating the session key.
+// authViewer creates an authenticated viewer by validating the session key,
+// or the key of a personal API token.
 func authViewer(ctx context.Context, sessionKey string, svc fleet.Service) (*viewer.Viewer, error) {
+	if strings.HasPrefix(sessionKey, fleet.APITokenPrefix) {
+		return authAPITokenViewer(ctx, sessionKey, svc)
+	}
+
 	session, err := svc.GetSessionByKey(ctx, sessionKey)
 	if err != nil {
 		return nil, fleet.NewAuthRequiredError(err.Error())
@@ -202,5 +230,26 @@ func authViewer(ctx context.Context, sessionKey string, svc fleet.Service) (*vie
 	if err != nil {
 		return nil, fleet.NewAuthRequiredError(err.Error())
 	}
+	if user.Disabled {
+		return nil, fleet.NewAuthRequiredError("user is disabled")
+	}
 	return &viewer.Viewer{User: user, Session: session}, nil
 }
+
+// authAPITokenViewer creates an authenticated viewer by validating the key of
+// a personal API token. The user of the viewer is restricted to the team of
+// the token, if any.
+func authAPITokenViewer(ctx context.Context, key string, svc fleet.Service) (*viewer.Viewer, error) {
+	token, err := svc.GetAPITokenByKey(ctx, key)
+	if err != nil {
+		return nil, fleet.NewAuthRequiredError(err.Error())
+	}
+	user, err := svc.UserUnauthorized(ctx, token.UserID)
+	if err != nil {
+		return nil, fleet.NewAuthRequiredError(err.Error())
+	}
+	if user.Disabled {
+		return nil, fleet.NewAuthRequiredError("user is disabled")
+	}
+	return &viewer.Viewer{User: token.ScopedUser(user), APIToken: token}, nil
+}
@@ -3,9 +3,11 @@ package externalsvc
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/andygrunwald/go-jira"
@@ -13,6 +15,10 @@ import (
 	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
 )
 
+// jiraDoneStatusCategory is the key of the status category of resolved
+// issues, regardless of the workflow used by the project.
+const jiraDoneStatusCategory = "done"
+
 // Jira is a Jira client to be used to make requests to a jira external
 // service.
 type Jira struct {
@@ -94,6 +100,91 @@ func (j *Jira) CreateJiraIssue(ctx context.Context, issue *jira.Issue) (*jira.Is
 	return createdIssue, nil
 }
 
+// AddJiraComment adds a comment to the issue identified by issueKey (the
+// issue's key or ID) on the jira server targeted by the Jira client. The
+// comment is not added if the issue already has a comment with the same body,
+// so that it can be safely retried.
+func (j *Jira) AddJiraComment(ctx context.Context, issueKey, body string) error {
+	issue, err := j.getJiraIssue(ctx, issueKey, "comment")
+	if err != nil {
+		return err
+	}
+	if issue.Fields != nil && issue.Fields.Comments != nil {
+		for _, c := range issue.Fields.Comments.Comments {
+			if c != nil && strings.TrimSpace(c.Body) == strings.TrimSpace(body) {
+				return nil
+			}
+		}
+	}
+
+	op := func() (*jira.Response, error) {
+		_, resp, err := j.client.Issue.AddCommentWithContext(ctx, issueKey, &jira.Comment{Body: body})
+		return resp, err
+	}
+	return doWithRetry(op)
+}
+
+// ResolveJiraIssue transitions the issue identified by issueKey (the issue's
+// key or ID) to the first status of the "done" category that is available
+// from its current status. It does nothing if the issue is already in a
+// "done" status, and returns an error if there is no such transition available
+// for the issue.
+func (j *Jira) ResolveJiraIssue(ctx context.Context, issueKey string) error {
+	issue, err := j.getJiraIssue(ctx, issueKey, "status")
+	if err != nil {
+		return err
+	}
+	if issue.Fields != nil && issue.Fields.Status != nil && issue.Fields.Status.StatusCategory.Key == jiraDoneStatusCategory {
+		return nil
+	}
+
+	var transitions []jira.Transition
+	op := func() (*jira.Response, error) {
+		var (
+			err  error
+			resp *jira.Response
+		)
+		transitions, resp, err = j.client.Issue.GetTransitionsWithContext(ctx, issueKey)
+		return resp, err
+	}
+	if err := doWithRetry(op); err != nil {
+		return err
+	}
+
+	var transitionID string
+	for _, tr := range transitions {
+		if tr.To.StatusCategory.Key == jiraDoneStatusCategory {
+			transitionID = tr.ID
+			break
+		}
+	}
+	if transitionID == "" {
+		return fmt.Errorf("no transition to a %q status available for issue %s", jiraDoneStatusCategory, issueKey)
+	}
+
+	op = func() (*jira.Response, error) {
+		return j.client.Issue.DoTransitionWithContext(ctx, issueKey, transitionID)
+	}
+	return doWithRetry(op)
+}
+
+// getJiraIssue returns the fields of the issue identified by issueKey.
+func (j *Jira) getJiraIssue(ctx context.Context, issueKey, fields string) (*jira.Issue, error) {
+	var issue *jira.Issue
+	op := func() (*jira.Response, error) {
+		var (
+			err  error
+			resp *jira.Response
+		)
+		issue, resp, err = j.client.Issue.GetWithContext(ctx, issueKey, &jira.GetQueryOptions{Fields: fields})
+		return resp, err
+	}
+	if err := doWithRetry(op); err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
+
 // JiraConfigMatches returns true if the jira client has been configured using
 // those same options. The Jira in the name is required so that the interface
 // method is not the same as the one for Zendesk (for mock or wrapper
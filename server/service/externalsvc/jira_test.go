@@ -2,6 +2,9 @@ package externalsvc
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -121,3 +124,81 @@ func TestJira(t *testing.T) {
 		require.Equal(t, 1, countCalls)
 	})
 }
+
+func TestJiraResolveIssue(t *testing.T) {
+	var comments []string
+	var gotTransition, status string
+	var doneAvailable bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/issue/ED-24":
+			var existing string
+			for i, c := range comments {
+				if i > 0 {
+					existing += ","
+				}
+				existing += fmt.Sprintf(`{"id": "%d", "body": %q}`, i+1, c)
+			}
+			w.Write([]byte(`{"key": "ED-24", "fields": {"status": {"statusCategory": {"key": "` + status + `"}}, "comment": {"comments": [` + existing + `]}}}`))
+
+		case r.Method == "POST" && r.URL.Path == "/rest/api/2/issue/ED-24/comment":
+			var c struct{ Body string }
+			_ = json.Unmarshal(body, &c)
+			comments = append(comments, c.Body)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "1"}`))
+
+		case r.Method == "GET" && r.URL.Path == "/rest/api/2/issue/ED-24/transitions":
+			transitions := `{"id": "11", "name": "In Progress", "to": {"statusCategory": {"key": "indeterminate"}}}`
+			if doneAvailable {
+				transitions += `, {"id": "31", "name": "Done", "to": {"statusCategory": {"key": "done"}}}`
+			}
+			w.Write([]byte(`{"transitions": [` + transitions + `]}`))
+
+		case r.Method == "POST" && r.URL.Path == "/rest/api/2/issue/ED-24/transitions":
+			gotTransition = string(body)
+			status = "done"
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewJiraClient(&JiraOptions{
+		BaseURL:           srv.URL,
+		BasicAuthUsername: "ok",
+		BasicAuthPassword: "ok",
+	})
+	require.NoError(t, err)
+
+	status = "new"
+	err = client.AddJiraComment(context.Background(), "ED-24", "test comment")
+	require.NoError(t, err)
+	require.Equal(t, []string{"test comment"}, comments)
+
+	// the same comment is not added twice
+	err = client.AddJiraComment(context.Background(), "ED-24", "test comment\n")
+	require.NoError(t, err)
+	require.Equal(t, []string{"test comment"}, comments)
+
+	err = client.ResolveJiraIssue(context.Background(), "ED-24")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no transition")
+	require.Empty(t, gotTransition)
+
+	doneAvailable = true
+	err = client.ResolveJiraIssue(context.Background(), "ED-24")
+	require.NoError(t, err)
+	require.Contains(t, gotTransition, `"transition":{"id":"31"}`)
+
+	// resolving an issue that is already done is a no-op
+	gotTransition = ""
+	err = client.ResolveJiraIssue(context.Background(), "ED-24")
+	require.NoError(t, err)
+	require.Empty(t, gotTransition)
+}
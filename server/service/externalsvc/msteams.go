@@ -0,0 +1,131 @@
+package externalsvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+)
+
+// MicrosoftTeams is a client to be used to post messages to a Microsoft Teams
+// channel via an incoming webhook connector.
+type MicrosoftTeams struct {
+	client *http.Client
+	opts   MicrosoftTeamsOptions
+}
+
+// MicrosoftTeamsOptions defines the options to configure a MicrosoftTeams
+// client.
+type MicrosoftTeamsOptions struct {
+	// URL is the URL of the incoming webhook connector of the channel.
+	URL string
+}
+
+// MicrosoftTeamsMessage is a Microsoft Teams message, formatted as a
+// connector message card.
+type MicrosoftTeamsMessage struct {
+	Summary         string                  `json:"summary"`
+	ThemeColor      string                  `json:"themeColor,omitempty"`
+	Title           string                  `json:"title"`
+	Text            string                  `json:"text,omitempty"`
+	Sections        []MicrosoftTeamsSection `json:"sections,omitempty"`
+	PotentialAction []MicrosoftTeamsAction  `json:"potentialAction,omitempty"`
+}
+
+// MicrosoftTeamsSection is a section of a Microsoft Teams message card.
+type MicrosoftTeamsSection struct {
+	Title string               `json:"title,omitempty"`
+	Text  string               `json:"text,omitempty"`
+	Facts []MicrosoftTeamsFact `json:"facts,omitempty"`
+}
+
+// MicrosoftTeamsFact is a name-value pair displayed in a section of a
+// Microsoft Teams message card.
+type MicrosoftTeamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MicrosoftTeamsAction is an action of a Microsoft Teams message card. Only
+// the OpenUri action is supported.
+type MicrosoftTeamsAction struct {
+	Name string `json:"name"`
+	URI  string `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler for the OpenUri action.
+func (a MicrosoftTeamsAction) MarshalJSON() ([]byte, error) {
+	type target struct {
+		OS  string `json:"os"`
+		URI string `json:"uri"`
+	}
+	return json.Marshal(struct {
+		Type    string   `json:"@type"`
+		Name    string   `json:"name"`
+		Targets []target `json:"targets"`
+	}{
+		Type:    "OpenUri",
+		Name:    a.Name,
+		Targets: []target{{OS: "default", URI: a.URI}},
+	})
+}
+
+// NewMicrosoftTeamsClient returns a client to use to post messages to a
+// Microsoft Teams channel.
+func NewMicrosoftTeamsClient(opts *MicrosoftTeamsOptions) (*MicrosoftTeams, error) {
+	if opts.URL == "" {
+		return nil, errors.New("missing Microsoft Teams webhook url")
+	}
+	return &MicrosoftTeams{
+		client: fleethttp.NewClient(fleethttp.WithTimeout(30 * time.Second)),
+		opts:   *opts,
+	}, nil
+}
+
+// PostMicrosoftTeamsMessage posts the message card to the Microsoft Teams
+// channel.
+func (t *MicrosoftTeams) PostMicrosoftTeamsMessage(ctx context.Context, msg *MicrosoftTeamsMessage) error {
+	body, err := json.Marshal(struct {
+		Type    string `json:"@type"`
+		Context string `json:"@context"`
+		*MicrosoftTeamsMessage
+	}{
+		Type:                  "MessageCard",
+		Context:               "https://schema.org/extensions",
+		MicrosoftTeamsMessage: msg,
+	})
+	if err != nil {
+		return err
+	}
+
+	return doWebhookWithRetry(func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.opts.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return nil
+		}
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return &WebhookError{StatusCode: resp.StatusCode, Body: string(respBody), header: resp.Header}
+	})
+}
+
+// MicrosoftTeamsConfigMatches returns true if the Microsoft Teams client has
+// been configured using those same options.
+func (t *MicrosoftTeams) MicrosoftTeamsConfigMatches(opts *MicrosoftTeamsOptions) bool {
+	return t.opts == *opts
+}
@@ -0,0 +1,76 @@
+package externalsvc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMicrosoftTeams(t *testing.T) {
+	var countCalls int
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		countCalls++
+		gotBody, _ = ioutil.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("1"))
+	}))
+	defer srv.Close()
+
+	msg := &MicrosoftTeamsMessage{
+		Summary:  "summary",
+		Title:    "title",
+		Sections: []MicrosoftTeamsSection{{Facts: []MicrosoftTeamsFact{{Name: "a", Value: "b"}}}},
+		PotentialAction: []MicrosoftTeamsAction{
+			{Name: "View in Fleet", URI: "https://fleet.example.com"},
+		},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewMicrosoftTeamsClient(&MicrosoftTeamsOptions{URL: srv.URL + "/ok"})
+		require.NoError(t, err)
+		err = client.PostMicrosoftTeamsMessage(context.Background(), msg)
+		require.NoError(t, err)
+		require.Equal(t, 1, countCalls)
+		require.JSONEq(t, `{
+			"@type": "MessageCard",
+			"@context": "https://schema.org/extensions",
+			"summary": "summary",
+			"title": "title",
+			"sections": [{"facts": [{"name": "a", "value": "b"}]}],
+			"potentialAction": [{
+				"@type": "OpenUri",
+				"name": "View in Fleet",
+				"targets": [{"os": "default", "uri": "https://fleet.example.com"}]
+			}]
+		}`, string(gotBody))
+	})
+
+	t.Run("permanent failure", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewMicrosoftTeamsClient(&MicrosoftTeamsOptions{URL: srv.URL + "/fail"})
+		require.NoError(t, err)
+		err = client.PostMicrosoftTeamsMessage(context.Background(), msg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 400")
+		require.Equal(t, 1, countCalls)
+	})
+
+	t.Run("config matches", func(t *testing.T) {
+		client, err := NewMicrosoftTeamsClient(&MicrosoftTeamsOptions{URL: "http://a"})
+		require.NoError(t, err)
+		require.True(t, client.MicrosoftTeamsConfigMatches(&MicrosoftTeamsOptions{URL: "http://a"}))
+		require.False(t, client.MicrosoftTeamsConfigMatches(&MicrosoftTeamsOptions{URL: "http://b"}))
+	})
+}
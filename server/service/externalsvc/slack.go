@@ -0,0 +1,146 @@
+package externalsvc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+)
+
+// DefaultSlackAPIURL is the base URL of the Slack Web API.
+const DefaultSlackAPIURL = "https://slack.com/api"
+
+// Slack is a client to be used to post messages to Slack, either via an
+// incoming webhook or via the chat.postMessage method of the Web API.
+type Slack struct {
+	client *http.Client
+	opts   SlackOptions
+}
+
+// SlackOptions defines the options to configure a Slack client. Either
+// WebhookURL, or APIToken and Channel must be set.
+type SlackOptions struct {
+	// WebhookURL is the URL of the incoming webhook to post messages to.
+	WebhookURL string
+	// APIToken is the bot token used to call chat.postMessage.
+	APIToken string
+	// Channel is the channel to post messages to with chat.postMessage.
+	Channel string
+	// APIURL is the base URL of the Slack Web API, DefaultSlackAPIURL if
+	// empty. It is typically only set in tests.
+	APIURL string
+}
+
+// SlackMessage is a Slack message, formatted using Block Kit. Text is used as
+// fallback in notifications.
+type SlackMessage struct {
+	Channel string       `json:"channel,omitempty"`
+	Text    string       `json:"text"`
+	Blocks  []SlackBlock `json:"blocks,omitempty"`
+}
+
+// SlackBlock is a Block Kit layout block of a Slack message.
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+// SlackText is a Block Kit text object.
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewSlackClient returns a client to use to post messages to Slack.
+func NewSlackClient(opts *SlackOptions) (*Slack, error) {
+	if opts.WebhookURL == "" && (opts.APIToken == "" || opts.Channel == "") {
+		return nil, errors.New("missing Slack webhook url or api token and channel")
+	}
+	return &Slack{
+		client: fleethttp.NewClient(fleethttp.WithTimeout(30 * time.Second)),
+		opts:   *opts,
+	}, nil
+}
+
+// PostSlackMessage posts the message to Slack, using the incoming webhook if
+// one is configured, chat.postMessage otherwise.
+func (s *Slack) PostSlackMessage(ctx context.Context, msg *SlackMessage) error {
+	if s.opts.WebhookURL != "" {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return doWebhookWithRetry(func() error {
+			_, err := s.post(ctx, s.opts.WebhookURL, "", body)
+			return err
+		})
+	}
+
+	cp := *msg
+	cp.Channel = s.opts.Channel
+	body, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	apiURL := s.opts.APIURL
+	if apiURL == "" {
+		apiURL = DefaultSlackAPIURL
+	}
+	return doWebhookWithRetry(func() error {
+		respBody, err := s.post(ctx, strings.TrimSuffix(apiURL, "/")+"/chat.postMessage", s.opts.APIToken, body)
+		if err != nil {
+			return err
+		}
+
+		// the Web API returns errors with a 200 status code
+		var resp struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &resp); err != nil {
+			return fmt.Errorf("decode Slack response: %w", err)
+		}
+		if !resp.OK {
+			return fmt.Errorf("Slack chat.postMessage failed: %s", resp.Error)
+		}
+		return nil
+	})
+}
+
+func (s *Slack) post(ctx context.Context, url, token string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return respBody, nil
+	}
+	return nil, &WebhookError{StatusCode: resp.StatusCode, Body: string(respBody), header: resp.Header}
+}
+
+// SlackConfigMatches returns true if the Slack client has been configured
+// using those same options.
+func (s *Slack) SlackConfigMatches(opts *SlackOptions) bool {
+	return s.opts == *opts
+}
@@ -0,0 +1,107 @@
+package externalsvc
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlack(t *testing.T) {
+	var countCalls int
+	var gotHeaders http.Header
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		countCalls++
+		gotHeaders = r.Header
+		gotBody, _ = ioutil.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/webhook/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/webhook/ok":
+			w.Write([]byte("ok"))
+			return
+		case "/api/chat.postMessage":
+			if r.Header.Get("Authorization") != "Bearer xoxb-ok" {
+				w.Write([]byte(`{"ok":false,"error":"invalid_auth"}`))
+				return
+			}
+			w.Write([]byte(`{"ok":true}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	msg := &SlackMessage{
+		Text: "hello",
+		Blocks: []SlackBlock{
+			{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: "*hello*"}},
+		},
+	}
+
+	t.Run("missing options", func(t *testing.T) {
+		_, err := NewSlackClient(&SlackOptions{APIToken: "xoxb-ok"})
+		require.Error(t, err)
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewSlackClient(&SlackOptions{WebhookURL: srv.URL + "/webhook/ok"})
+		require.NoError(t, err)
+		err = client.PostSlackMessage(context.Background(), msg)
+		require.NoError(t, err)
+		require.Equal(t, 1, countCalls)
+		require.JSONEq(t, `{"text":"hello","blocks":[{"type":"section","text":{"type":"mrkdwn","text":"*hello*"}}]}`, string(gotBody))
+		require.Empty(t, gotHeaders.Get("Authorization"))
+	})
+
+	t.Run("webhook failure", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewSlackClient(&SlackOptions{WebhookURL: srv.URL + "/webhook/fail"})
+		require.NoError(t, err)
+		err = client.PostSlackMessage(context.Background(), msg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 500")
+		require.Equal(t, 6, countCalls)
+	})
+
+	t.Run("chat.postMessage", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewSlackClient(&SlackOptions{APIToken: "xoxb-ok", Channel: "#secops", APIURL: srv.URL + "/api"})
+		require.NoError(t, err)
+		err = client.PostSlackMessage(context.Background(), msg)
+		require.NoError(t, err)
+		require.Equal(t, 1, countCalls)
+
+		var got SlackMessage
+		require.NoError(t, json.Unmarshal(gotBody, &got))
+		require.Equal(t, "#secops", got.Channel)
+		require.Equal(t, msg.Blocks, got.Blocks)
+		require.Empty(t, msg.Channel) // the provided message is not modified
+	})
+
+	t.Run("chat.postMessage failure", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewSlackClient(&SlackOptions{APIToken: "xoxb-invalid", Channel: "#secops", APIURL: srv.URL + "/api"})
+		require.NoError(t, err)
+		err = client.PostSlackMessage(context.Background(), msg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid_auth")
+		require.Equal(t, 1, countCalls)
+	})
+
+	t.Run("config matches", func(t *testing.T) {
+		client, err := NewSlackClient(&SlackOptions{APIToken: "a", Channel: "b"})
+		require.NoError(t, err)
+		require.True(t, client.SlackConfigMatches(&SlackOptions{APIToken: "a", Channel: "b"}))
+		require.False(t, client.SlackConfigMatches(&SlackOptions{APIToken: "a", Channel: "c"}))
+	})
+}
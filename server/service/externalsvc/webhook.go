@@ -0,0 +1,144 @@
+package externalsvc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+	"github.com/fleetdm/fleet/v4/pkg/webhooksig"
+)
+
+// Webhook is a client to be used to make requests to a generic HTTP webhook
+// destination.
+type Webhook struct {
+	client *http.Client
+	opts   WebhookOptions
+}
+
+// WebhookOptions defines the options to configure a Webhook client.
+type WebhookOptions struct {
+	URL     string
+	Headers map[string]string
+	Secret  string
+}
+
+// WebhookError is the error returned when the webhook destination responds
+// with a non-successful status code.
+type WebhookError struct {
+	StatusCode int
+	Body       string
+	header     http.Header
+}
+
+// Error implements the error interface for WebhookError.
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// NewWebhookClient returns a client to use to make requests to a generic HTTP
+// webhook destination.
+func NewWebhookClient(opts *WebhookOptions) (*Webhook, error) {
+	if opts.URL == "" {
+		return nil, errors.New("missing webhook url")
+	}
+	return &Webhook{
+		client: fleethttp.NewClient(fleethttp.WithTimeout(30 * time.Second)),
+		opts:   *opts,
+	}, nil
+}
+
+// SendWebhook sends the body to the webhook destination using the POST
+// method. The body is signed (see the webhooksig package) if a secret is set
+// in the client options.
+func (w *Webhook) SendWebhook(ctx context.Context, body []byte) error {
+	op := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range w.opts.Headers {
+			req.Header.Set(k, v)
+		}
+		if w.opts.Secret != "" {
+			// sign on each attempt so that the timestamp is up-to-date
+			webhooksig.SetHeaders(req.Header, w.opts.Secret, time.Now(), body)
+		}
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return nil
+		}
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return &WebhookError{StatusCode: resp.StatusCode, Body: string(respBody), header: resp.Header}
+	}
+
+	return doWebhookWithRetry(op)
+}
+
+// WebhookConfigMatches returns true if the webhook client has been configured
+// using those same options.
+func (w *Webhook) WebhookConfigMatches(opts *WebhookOptions) bool {
+	if w.opts.URL != opts.URL || w.opts.Secret != opts.Secret || len(w.opts.Headers) != len(opts.Headers) {
+		return false
+	}
+	for k, v := range w.opts.Headers {
+		if ov, ok := opts.Headers[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+func doWebhookWithRetry(fn func() error) error {
+	op := func() error {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			if netErr.Temporary() || netErr.Timeout() {
+				// retryable error
+				return err
+			}
+		}
+
+		var whErr *WebhookError
+		if errors.As(err, &whErr) {
+			if whErr.StatusCode >= http.StatusInternalServerError {
+				// 500+ status, can be worth retrying
+				return err
+			}
+			if whErr.StatusCode == http.StatusTooManyRequests {
+				rawAfter := whErr.header.Get("Retry-After")
+				afterSecs, err := strconv.ParseInt(rawAfter, 10, 0)
+				if err == nil && (time.Duration(afterSecs)*time.Second) < maxWaitForRetryAfter {
+					// the retry-after duration is reasonable, wait for it and return a
+					// retryable error so that we try again.
+					time.Sleep(time.Duration(afterSecs) * time.Second)
+					return errors.New("retry after requested delay")
+				}
+			}
+		}
+
+		// at this point, this is a non-retryable error
+		return backoff.Permanent(err)
+	}
+
+	boff := backoff.WithMaxRetries(backoff.NewConstantBackOff(retryBackoff), uint64(maxRetries))
+	return backoff.Retry(op, boff)
+}
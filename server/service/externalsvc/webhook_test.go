@@ -0,0 +1,96 @@
+package externalsvc
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/pkg/webhooksig"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhook(t *testing.T) {
+	var countCalls int
+	var gotHeaders http.Header
+	var gotBody []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		countCalls++
+		gotHeaders = r.Header
+		gotBody, _ = ioutil.ReadAll(r.Body)
+
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/badrequest":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case "/retrysmall":
+			if countCalls == 1 {
+				w.Header().Add("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	t.Run("failure", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewWebhookClient(&WebhookOptions{URL: srv.URL + "/fail"})
+		require.NoError(t, err)
+		err = client.SendWebhook(context.Background(), []byte(`{}`))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 500")
+		require.Equal(t, 6, countCalls)
+	})
+
+	t.Run("permanent failure", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewWebhookClient(&WebhookOptions{URL: srv.URL + "/badrequest"})
+		require.NoError(t, err)
+		err = client.SendWebhook(context.Background(), []byte(`{}`))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 400")
+		require.Equal(t, 1, countCalls)
+	})
+
+	t.Run("retry-after-small", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewWebhookClient(&WebhookOptions{URL: srv.URL + "/retrysmall"})
+		require.NoError(t, err)
+		err = client.SendWebhook(context.Background(), []byte(`{}`))
+		require.NoError(t, err)
+		require.Equal(t, 2, countCalls)
+	})
+
+	t.Run("headers and signature", func(t *testing.T) {
+		countCalls = 0
+		client, err := NewWebhookClient(&WebhookOptions{
+			URL:     srv.URL + "/ok",
+			Headers: map[string]string{"Authorization": "Bearer abc"},
+			Secret:  "s3cr3t",
+		})
+		require.NoError(t, err)
+		err = client.SendWebhook(context.Background(), []byte(`{"a":1}`))
+		require.NoError(t, err)
+		require.Equal(t, 1, countCalls)
+		require.Equal(t, `{"a":1}`, string(gotBody))
+		require.Equal(t, "Bearer abc", gotHeaders.Get("Authorization"))
+		require.Equal(t, "application/json", gotHeaders.Get("Content-Type"))
+		require.NoError(t, webhooksig.Verify("s3cr3t", gotHeaders, gotBody, webhooksig.DefaultTolerance))
+	})
+
+	t.Run("config matches", func(t *testing.T) {
+		opts := &WebhookOptions{URL: "http://a", Headers: map[string]string{"a": "b"}, Secret: "c"}
+		client, err := NewWebhookClient(opts)
+		require.NoError(t, err)
+		require.True(t, client.WebhookConfigMatches(&WebhookOptions{URL: "http://a", Headers: map[string]string{"a": "b"}, Secret: "c"}))
+		require.False(t, client.WebhookConfigMatches(&WebhookOptions{URL: "http://a", Headers: map[string]string{"a": "x"}, Secret: "c"}))
+		require.False(t, client.WebhookConfigMatches(&WebhookOptions{URL: "http://a", Secret: "c"}))
+	})
+}
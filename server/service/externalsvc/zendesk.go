@@ -94,6 +94,44 @@ func (z *Zendesk) CreateZendeskTicket(ctx context.Context, ticket *zendesk.Ticke
 	return createdTicket, nil
 }
 
+// UpdateZendeskTicket updates the ticket identified by ticketID on the
+// Zendesk server targeted by the Zendesk client, e.g. to add a comment or
+// change its status. The comment is not added if the ticket already has a
+// comment with the same body, so that the update can be safely retried. It
+// returns the updated ticket or an error.
+func (z *Zendesk) UpdateZendeskTicket(ctx context.Context, ticketID int64, ticket *zendesk.Ticket) (*zendesk.Ticket, error) {
+	update := *ticket
+	if update.Comment != nil {
+		var comments []zendesk.TicketComment
+		op := func() (interface{}, error) {
+			var err error
+			comments, err = z.client.ListTicketComments(ctx, ticketID)
+			return comments, err
+		}
+		if err := doZendeskWithRetry(op); err != nil {
+			return nil, err
+		}
+		for _, c := range comments {
+			if strings.TrimSpace(c.Body) == strings.TrimSpace(update.Comment.Body) {
+				update.Comment = nil
+				break
+			}
+		}
+	}
+
+	var updatedTicket *zendesk.Ticket
+	op := func() (interface{}, error) {
+		t, err := z.client.UpdateTicket(ctx, ticketID, update)
+		updatedTicket = &t
+		return updatedTicket, err
+	}
+
+	if err := doZendeskWithRetry(op); err != nil {
+		return nil, err
+	}
+	return updatedTicket, nil
+}
+
 // ZendeskConfigMatches returns true if the zendesk client has been configured
 // using those same options. The Zendesk in the name is required so that the
 // interface method is not the same as the one for Jira (for mock or wrapper
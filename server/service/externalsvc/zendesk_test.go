@@ -2,6 +2,7 @@ package externalsvc
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -125,3 +126,51 @@ func TestZendesk(t *testing.T) {
 		require.Equal(t, 1, countCalls)
 	})
 }
+
+func TestZendeskUpdateTicket(t *testing.T) {
+	var gotBody []byte
+	var comments string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v2/tickets/35436/comments.json":
+			w.Write([]byte(`{"comments": [` + comments + `]}`))
+		case r.Method == "PUT" && r.URL.Path == "/api/v2/tickets/35436.json":
+			gotBody, _ = ioutil.ReadAll(r.Body)
+			w.Write([]byte(`{"ticket": {"id": 35436, "status": "solved"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewZendeskTestClient(&ZendeskOptions{
+		URL:      srv.URL,
+		Email:    "ok",
+		APIToken: "ok",
+	})
+	require.NoError(t, err)
+
+	tkt, err := client.UpdateZendeskTicket(context.Background(), 35436, &zendesk.Ticket{
+		Status:  "solved",
+		Comment: &zendesk.TicketComment{Body: "test comment"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(35436), tkt.ID)
+	require.Equal(t, "solved", tkt.Status)
+	require.Contains(t, string(gotBody), `"status":"solved"`)
+	require.Contains(t, string(gotBody), `"body":"test comment"`)
+
+	// the comment is not added again if the ticket already has it
+	comments = `{"id": 1, "body": "test comment\n"}`
+	_, err = client.UpdateZendeskTicket(context.Background(), 35436, &zendesk.Ticket{
+		Status:  "solved",
+		Comment: &zendesk.TicketComment{Body: "test comment"},
+	})
+	require.NoError(t, err)
+	require.Contains(t, string(gotBody), `"status":"solved"`)
+	require.NotContains(t, string(gotBody), `"body"`)
+
+	_, err = client.UpdateZendeskTicket(context.Background(), 1, &zendesk.Ticket{Status: "solved"})
+	require.Error(t, err)
+}
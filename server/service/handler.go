@@ -3,10 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"regexp"
 
 	"github.com/fleetdm/fleet/v4/server/config"
+	"github.com/fleetdm/fleet/v4/server/contexts/auditinfo"
 	"github.com/fleetdm/fleet/v4/server/contexts/logging"
 	"github.com/fleetdm/fleet/v4/server/contexts/publicip"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -122,6 +124,7 @@ func MakeHandler(
 	}
 
 	r.Use(publicIP)
+	r.Use(auditInfo)
 
 	attachFleetAPIRoutes(r, svc, config, logger, limitStore, fleetAPIOptions, eopts)
 	addMetrics(r)
@@ -139,6 +142,20 @@ func publicIP(handler http.Handler) http.Handler {
 	})
 }
 
+// auditInfo stores the client IP and user agent in the context, to be
+// recorded with the activities. It must run after publicIP, so that the remote
+// address is the one forwarded by the proxies, if any.
+func auditInfo(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		info := auditinfo.AuditInfo{RemoteIP: ip, UserAgent: r.UserAgent()}
+		handler.ServeHTTP(w, r.WithContext(auditinfo.NewContext(r.Context(), info)))
+	})
+}
+
 // PrometheusMetricsHandler wraps the provided handler with prometheus metrics
 // middleware and returns the resulting handler that should be mounted for that
 // route.
@@ -265,9 +282,16 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 	ue.PATCH("/api/_version_/fleet/users/{id:[0-9]+}", modifyUserEndpoint, modifyUserRequest{})
 	ue.DELETE("/api/_version_/fleet/users/{id:[0-9]+}", deleteUserEndpoint, deleteUserRequest{})
 	ue.POST("/api/_version_/fleet/users/{id:[0-9]+}/require_password_reset", requirePasswordResetEndpoint, requirePasswordResetRequest{})
+	ue.POST("/api/_version_/fleet/users/{id:[0-9]+}/unlock", unlockUserEndpoint, unlockUserRequest{})
 	ue.GET("/api/_version_/fleet/users/{id:[0-9]+}/sessions", getInfoAboutSessionsForUserEndpoint, getInfoAboutSessionsForUserRequest{})
 	ue.DELETE("/api/_version_/fleet/users/{id:[0-9]+}/sessions", deleteSessionsForUserEndpoint, deleteSessionsForUserRequest{})
+	ue.GET("/api/_version_/fleet/users/{id:[0-9]+}/api_tokens", listAPITokensEndpoint, listAPITokensRequest{})
+	ue.POST("/api/_version_/fleet/users/{id:[0-9]+}/api_tokens", createAPITokenEndpoint, createAPITokenRequest{})
+	ue.DELETE("/api/_version_/fleet/users/{id:[0-9]+}/api_tokens/{token_id:[0-9]+}", deleteAPITokenEndpoint, deleteAPITokenRequest{})
+	ue.DELETE("/api/_version_/fleet/users/{id:[0-9]+}/totp", deleteUserTOTPEndpoint, deleteUserTOTPRequest{})
 	ue.POST("/api/_version_/fleet/change_password", changePasswordEndpoint, changePasswordRequest{})
+	ue.POST("/api/_version_/fleet/me/totp", enrollTOTPEndpoint, nil)
+	ue.POST("/api/_version_/fleet/me/totp/verify", verifyTOTPEndpoint, verifyTOTPRequest{})
 
 	ue.GET("/api/_version_/fleet/email/change/{token}", changeEmailEndpoint, changeEmailRequest{})
 	// TODO: searchTargetsEndpoint will be removed in Fleet 5.0
@@ -303,6 +327,7 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 	ue.POST("/api/_version_/fleet/spec/policies", applyPolicySpecsEndpoint, applyPolicySpecsRequest{})
 
 	ue.GET("/api/_version_/fleet/queries/{id:[0-9]+}", getQueryEndpoint, getQueryRequest{})
+	ue.GET("/api/_version_/fleet/queries/{id:[0-9]+}/report", getQueryReportEndpoint, getQueryReportRequest{})
 	ue.GET("/api/_version_/fleet/queries", listQueriesEndpoint, listQueriesRequest{})
 	ue.POST("/api/_version_/fleet/queries", createQueryEndpoint, createQueryRequest{})
 	ue.PATCH("/api/_version_/fleet/queries/{id:[0-9]+}", modifyQueryEndpoint, modifyQueryRequest{})
@@ -354,11 +379,24 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 	ue.GET("/api/_version_/fleet/spec/labels", getLabelSpecsEndpoint, nil)
 	ue.GET("/api/_version_/fleet/spec/labels/{name}", getLabelSpecEndpoint, getGenericSpecRequest{})
 
+	ue.POST("/api/_version_/fleet/spec/custom_roles", applyCustomRoleSpecsEndpoint, applyCustomRoleSpecsRequest{})
+	ue.GET("/api/_version_/fleet/spec/custom_roles", getCustomRoleSpecsEndpoint, nil)
+	ue.GET("/api/_version_/fleet/spec/custom_roles/{name}", getCustomRoleSpecEndpoint, getGenericSpecRequest{})
+	ue.DELETE("/api/_version_/fleet/custom_roles/{name}", deleteCustomRoleEndpoint, deleteCustomRoleRequest{})
+
 	ue.GET("/api/_version_/fleet/queries/run", runLiveQueryEndpoint, runLiveQueryRequest{})
 	ue.POST("/api/_version_/fleet/queries/run", createDistributedQueryCampaignEndpoint, createDistributedQueryCampaignRequest{})
 	ue.POST("/api/_version_/fleet/queries/run_by_names", createDistributedQueryCampaignByNamesEndpoint, createDistributedQueryCampaignByNamesRequest{})
+	ue.GET("/api/_version_/fleet/queries/run/{id:[0-9]+}/results", listCampaignResultsEndpoint, listCampaignResultsRequest{})
+	ue.GET("/api/_version_/fleet/queries/run/{id:[0-9]+}/hosts", listCampaignHostsEndpoint, listCampaignHostsRequest{})
+	ue.GET("/api/_version_/fleet/queries/run/{id:[0-9]+}/export", getCampaignExportEndpoint, getCampaignExportRequest{})
 
 	ue.GET("/api/_version_/fleet/activities", listActivitiesEndpoint, listActivitiesRequest{})
+	ue.GET("/api/_version_/fleet/activities/archive", listArchivedActivityMonthsEndpoint, nil)
+	ue.GET("/api/_version_/fleet/activities/archive/{month}", listArchivedActivitiesEndpoint, listArchivedActivitiesRequest{})
+
+	ue.GET("/api/_version_/fleet/webhooks/deliveries", listWebhookDeliveriesEndpoint, listWebhookDeliveriesRequest{})
+	ue.POST("/api/_version_/fleet/webhooks/deliveries/{id:[0-9]+}/redeliver", redeliverWebhookDeliveryEndpoint, redeliverWebhookDeliveryRequest{})
 
 	ue.POST("/api/_version_/fleet/download_installer/{kind}", getInstallerEndpoint, getInstallerRequest{})
 	ue.HEAD("/api/_version_/fleet/download_installer/{kind}", checkInstallerEndpoint, checkInstallerRequest{})
@@ -430,6 +468,22 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 		errorLimiter.Limit("get_device_transparency", desktopQuota),
 	).GET("/api/_version_/fleet/device/{token}/transparency", transparencyURL, transparencyURLRequest{})
 
+	// SCIM-authenticated endpoints, used by the identity provider to provision
+	// the users and their teams (the SCIM groups).
+	se := newSCIMAuthenticatedEndpointer(svc, opts, r, apiVersions...)
+	se.GET("/api/_version_/fleet/scim/Users", listSCIMUsersEndpoint, listSCIMUsersRequest{})
+	se.POST("/api/_version_/fleet/scim/Users", createSCIMUserEndpoint, createSCIMUserRequest{})
+	se.GET("/api/_version_/fleet/scim/Users/{id}", getSCIMUserEndpoint, getSCIMUserRequest{})
+	se.PUT("/api/_version_/fleet/scim/Users/{id}", replaceSCIMUserEndpoint, replaceSCIMUserRequest{})
+	se.PATCH("/api/_version_/fleet/scim/Users/{id}", patchSCIMUserEndpoint, patchSCIMUserRequest{})
+	se.DELETE("/api/_version_/fleet/scim/Users/{id}", deleteSCIMUserEndpoint, deleteSCIMUserRequest{})
+	se.GET("/api/_version_/fleet/scim/Groups", listSCIMGroupsEndpoint, listSCIMGroupsRequest{})
+	se.POST("/api/_version_/fleet/scim/Groups", createSCIMGroupEndpoint, createSCIMGroupRequest{})
+	se.GET("/api/_version_/fleet/scim/Groups/{id}", getSCIMGroupEndpoint, getSCIMGroupRequest{})
+	se.PUT("/api/_version_/fleet/scim/Groups/{id}", replaceSCIMGroupEndpoint, replaceSCIMGroupRequest{})
+	se.PATCH("/api/_version_/fleet/scim/Groups/{id}", patchSCIMGroupEndpoint, patchSCIMGroupRequest{})
+	se.DELETE("/api/_version_/fleet/scim/Groups/{id}", deleteSCIMGroupEndpoint, deleteSCIMGroupRequest{})
+
 	// host-authenticated endpoints
 	he := newHostAuthenticatedEndpointer(svc, logger, opts, r, apiVersions...)
 
@@ -471,6 +525,7 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 	ne.POST("/api/_version_/fleet/logout", logoutEndpoint, nil)
 	ne.POST("/api/v1/fleet/sso", initiateSSOEndpoint, initiateSSORequest{})
 	ne.POST("/api/v1/fleet/sso/callback", makeCallbackSSOEndpoint(config.Server.URLPrefix), callbackSSORequest{})
+	ne.GET("/api/v1/fleet/sso/callback", makeCallbackSSOEndpoint(config.Server.URLPrefix), callbackOIDCRequest{})
 	ne.GET("/api/v1/fleet/sso", settingsSSOEndpoint, nil)
 
 	// the websocket distributed query results endpoint is a bit different - the
@@ -493,6 +548,10 @@ func attachFleetAPIRoutes(r *mux.Router, svc fleet.Service, config config.FleetC
 
 	ne.WithCustomMiddleware(limiter.Limit("login", throttled.RateQuota{MaxRate: loginRateLimit, MaxBurst: 9})).
 		POST("/api/_version_/fleet/login", loginEndpoint, loginRequest{})
+	ne.WithCustomMiddleware(limiter.Limit("login", throttled.RateQuota{MaxRate: loginRateLimit, MaxBurst: 9})).
+		POST("/api/_version_/fleet/login/totp", loginTOTPEndpoint, loginTOTPRequest{})
+	ne.WithCustomMiddleware(limiter.Limit("login", throttled.RateQuota{MaxRate: loginRateLimit, MaxBurst: 9})).
+		POST("/api/_version_/fleet/login/totp/enroll", enrollTOTPForLoginEndpoint, enrollTOTPForLoginRequest{})
 
 	// Fleet Sandbox demo login (always errors unless config.server.sandbox_enabled is set)
 	ne.WithCustomMiddleware(limiter.Limit("login", throttled.RateQuota{MaxRate: loginRateLimit, MaxBurst: 9})).
@@ -11,7 +11,8 @@ import (
 	"strings"
 	"time"
 
-	"github.com/fleetdm/fleet/v4/server/contexts/authz"
+	"github.com/fleetdm/fleet/v4/server/authz"
+	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/contexts/logging"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
@@ -263,7 +264,10 @@ func (svc *Service) DeleteHosts(ctx context.Context, ids []uint, opts fleet.Host
 		if err != nil {
 			return err
 		}
-		return svc.ds.DeleteHosts(ctx, ids)
+		if err := svc.ds.DeleteHosts(ctx, ids); err != nil {
+			return err
+		}
+		return svc.newDeletedHostsActivity(ctx, ids)
 	}
 
 	hostIDs, err := svc.hostIDsFromFilters(ctx, opts, lid)
@@ -279,7 +283,22 @@ func (svc *Service) DeleteHosts(ctx context.Context, ids []uint, opts fleet.Host
 	if err != nil {
 		return err
 	}
-	return svc.ds.DeleteHosts(ctx, hostIDs)
+	if err := svc.ds.DeleteHosts(ctx, hostIDs); err != nil {
+		return err
+	}
+	return svc.newDeletedHostsActivity(ctx, hostIDs)
+}
+
+func (svc *Service) newDeletedHostsActivity(ctx context.Context, hostIDs []uint) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeDeletedMultipleHosts,
+		&map[string]interface{}{"host_ids": hostIDs},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create deleted hosts activity")
+	}
+	return nil
 }
 
 /////////////////////////////////////////////////////////////////////////////////
@@ -451,7 +470,7 @@ func getHostEndpoint(ctx context.Context, request interface{}, svc fleet.Service
 }
 
 func (svc *Service) GetHost(ctx context.Context, id uint, opts fleet.HostDetailOptions) (*fleet.HostDetail, error) {
-	alreadyAuthd := svc.authz.IsAuthenticatedWith(ctx, authz.AuthnDeviceToken)
+	alreadyAuthd := svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnDeviceToken)
 	if !alreadyAuthd {
 		// First ensure the user has access to list hosts, then check the specific
 		// host once team_id is loaded.
@@ -655,7 +674,24 @@ func (svc *Service) DeleteHost(ctx context.Context, id uint) error {
 		return err
 	}
 
-	return svc.ds.DeleteHost(ctx, id)
+	if err := svc.ds.DeleteHost(ctx, id); err != nil {
+		return err
+	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeDeletedHost,
+		&map[string]interface{}{
+			"host_id":       host.ID,
+			"host_hostname": host.Hostname,
+			"host_uuid":     host.UUID,
+			"team_id":       host.TeamID,
+		},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create deleted host activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -692,7 +728,22 @@ func (svc *Service) AddHostsToTeam(ctx context.Context, teamID *uint, hostIDs []
 		return err
 	}
 
-	return svc.ds.AddHostsToTeam(ctx, teamID, hostIDs)
+	if err := svc.ds.AddHostsToTeam(ctx, teamID, hostIDs); err != nil {
+		return err
+	}
+	return svc.newTransferredHostsActivity(ctx, teamID, hostIDs)
+}
+
+func (svc *Service) newTransferredHostsActivity(ctx context.Context, teamID *uint, hostIDs []uint) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeTransferredHosts,
+		&map[string]interface{}{"team_id": teamID, "host_ids": hostIDs},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create transferred hosts activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -747,7 +798,10 @@ func (svc *Service) AddHostsToTeamByFilter(ctx context.Context, teamID *uint, op
 	}
 
 	// Apply the team to the selected hosts.
-	return svc.ds.AddHostsToTeam(ctx, teamID, hostIDs)
+	if err := svc.ds.AddHostsToTeam(ctx, teamID, hostIDs); err != nil {
+		return err
+	}
+	return svc.newTransferredHostsActivity(ctx, teamID, hostIDs)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -774,7 +828,7 @@ func refetchHostEndpoint(ctx context.Context, request interface{}, svc fleet.Ser
 }
 
 func (svc *Service) RefetchHost(ctx context.Context, id uint) error {
-	if !svc.authz.IsAuthenticatedWith(ctx, authz.AuthnDeviceToken) {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnDeviceToken) {
 		if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
 			return err
 		}
@@ -922,7 +976,7 @@ func listHostDeviceMappingEndpoint(ctx context.Context, request interface{}, svc
 }
 
 func (svc *Service) ListHostDeviceMapping(ctx context.Context, id uint) ([]*fleet.HostDeviceMapping, error) {
-	if !svc.authz.IsAuthenticatedWith(ctx, authz.AuthnDeviceToken) {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnDeviceToken) {
 		if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
 			return nil, err
 		}
@@ -966,7 +1020,7 @@ func getMacadminsDataEndpoint(ctx context.Context, request interface{}, svc flee
 }
 
 func (svc *Service) MacadminsData(ctx context.Context, id uint) (*fleet.MacadminsData, error) {
-	if !svc.authz.IsAuthenticatedWith(ctx, authz.AuthnDeviceToken) {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnDeviceToken) {
 		if err := svc.authz.Authorize(ctx, &fleet.Host{}, fleet.ActionList); err != nil {
 			return nil, err
 		}
@@ -1204,7 +1258,7 @@ func hostsReportEndpoint(ctx context.Context, request interface{}, svc fleet.Ser
 	// for now, only csv format is allowed
 	if req.Format != "csv" {
 		// prevent returning an "unauthorized" error, we want that specific error
-		if az, ok := authz.FromContext(ctx); ok {
+		if az, ok := authz_ctx.FromContext(ctx); ok {
 			az.SetChecked()
 		}
 		err := ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("format", "unsupported or unspecified report format").
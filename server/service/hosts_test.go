@@ -71,6 +71,9 @@ func TestHostDetails(t *testing.T) {
 
 func TestHostAuth(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	teamHost := &fleet.Host{TeamID: ptr.Uint(1)}
@@ -327,6 +330,9 @@ func TestDeleteHost(t *testing.T) {
 
 func TestAddHostsToTeamByFilter(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	expectedHostIDs := []uint{1, 2, 4}
@@ -352,6 +358,9 @@ func TestAddHostsToTeamByFilter(t *testing.T) {
 
 func TestAddHostsToTeamByFilterLabel(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	expectedHostIDs := []uint{6}
@@ -378,6 +387,9 @@ func TestAddHostsToTeamByFilterLabel(t *testing.T) {
 
 func TestAddHostsToTeamByFilterEmptyHosts(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	ds.ListHostsFunc = func(ctx context.Context, filter fleet.TeamFilter, opt fleet.HostListOptions) ([]*fleet.Host, error) {
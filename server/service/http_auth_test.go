@@ -163,6 +163,12 @@ func setupAuthTest(t *testing.T) (fleet.Datastore, map[string]fleet.User, *httpt
 		user := usersMap[email]
 		return &user, nil
 	}
+	ds.UserTOTPFunc = func(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+		return nil, &mock.Error{Message: "not found"}
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
 	ds.NewSessionFunc = func(ctx context.Context, userID uint, sessionKey string) (*fleet.Session, error) {
 		session := &fleet.Session{
 			UserID:     userID,
@@ -25,6 +25,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/fleetdm/fleet/v4/server/totp"
 	"github.com/ghodss/yaml"
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -1990,7 +1991,7 @@ func (s *integrationTestSuite) TestListActivities() {
 	u := s.users["admin1@example.com"]
 	details := make(map[string]interface{})
 
-	prevActivities, err := s.ds.ListActivities(ctx, fleet.ListOptions{})
+	prevActivities, err := s.ds.ListActivities(ctx, fleet.ListActivitiesOptions{})
 	require.NoError(t, err)
 
 	err = s.ds.NewActivity(ctx, &u, fleet.ActivityTypeAppliedSpecPack, &details)
@@ -2017,6 +2018,42 @@ func (s *integrationTestSuite) TestListActivities() {
 	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp, "per_page", "1", "order_key", "id", "order_direction", "desc")
 	require.Len(t, listResp.Activities, 1)
 	assert.Equal(t, fleet.ActivityTypeEditedPack, listResp.Activities[0].Type)
+
+	// filter by type, actor and date range
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp, "activity_type", fleet.ActivityTypeDeletedPack)
+	require.NotEmpty(t, listResp.Activities)
+	for _, a := range listResp.Activities {
+		assert.Equal(t, fleet.ActivityTypeDeletedPack, a.Type)
+	}
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp, "actor_id", fmt.Sprint(u.ID+1000))
+	require.Empty(t, listResp.Activities)
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp,
+		"actor_id", fmt.Sprint(u.ID), "start_created_at", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), "order_key", "id")
+	require.GreaterOrEqual(t, len(listResp.Activities), 3)
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp,
+		"end_created_at", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	for _, a := range listResp.Activities {
+		assert.True(t, a.CreatedAt.Before(time.Now().Add(-time.Hour)))
+	}
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusUnprocessableEntity, &listResp, "start_created_at", "yesterday")
+
+	// activities created via the API record the actor's IP and user agent
+	var createLabelResp createLabelResponse
+	s.DoJSON("POST", "/api/latest/fleet/labels", &fleet.LabelPayload{
+		Name:  ptr.String(t.Name()),
+		Query: ptr.String("SELECT 1"),
+	}, http.StatusOK, &createLabelResp)
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listResp,
+		"activity_type", fleet.ActivityTypeCreatedLabel, "order_key", "id", "order_direction", "desc", "per_page", "1")
+	require.Len(t, listResp.Activities, 1)
+	require.NotNil(t, listResp.Activities[0].ActorIP)
+	assert.Equal(t, "127.0.0.1", *listResp.Activities[0].ActorIP)
+	require.NotNil(t, listResp.Activities[0].ActorUserAgent)
+	assert.Contains(t, *listResp.Activities[0].ActorUserAgent, "Go-http-client")
+	require.NotNil(t, listResp.Activities[0].Details)
+	assert.Contains(t, string(*listResp.Activities[0].Details), t.Name())
+
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/labels/id/%d", createLabelResp.Label.ID), nil, http.StatusOK)
 }
 
 func (s *integrationTestSuite) TestListGetCarves() {
@@ -5390,6 +5427,375 @@ func (s *integrationTestSuite) TestOSVersions() {
 }
 
 // this test can be deleted once the "v1" version is removed.
+func (s *integrationTestSuite) TestAPITokens() {
+	t := s.T()
+	ctx := context.Background()
+
+	// ensure that on exit, the admin token is used
+	defer func() { s.token = s.getTestAdminToken() }()
+
+	admin := s.users["admin1@example.com"]
+	observer := s.users["user2@example.com"]
+	tokensPath := fmt.Sprintf("/api/latest/fleet/users/%d/api_tokens", admin.ID)
+
+	host, err := s.ds.NewHost(ctx, &fleet.Host{
+		DetailUpdatedAt: time.Now(),
+		LabelUpdatedAt:  time.Now(),
+		PolicyUpdatedAt: time.Now(),
+		SeenTime:        time.Now(),
+		NodeKey:         t.Name() + "1",
+		UUID:            t.Name() + "1",
+		Hostname:        t.Name() + "foo.local",
+	})
+	require.NoError(t, err)
+	team, err := s.ds.NewTeam(ctx, &fleet.Team{Name: t.Name()})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, s.ds.DeleteHost(ctx, host.ID))
+		require.NoError(t, s.ds.DeleteTeam(ctx, team.ID))
+	})
+
+	// invalid payloads
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{Name: "no scopes"}, http.StatusUnprocessableEntity, &createAPITokenResponse{})
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{
+		Scopes: fleet.APITokenScopes{{Object: "host", Actions: []string{"read"}}},
+	}, http.StatusUnprocessableEntity, &createAPITokenResponse{})
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{
+		Name:      "expired",
+		Scopes:    fleet.APITokenScopes{{Object: "host", Actions: []string{"read"}}},
+		ExpiresAt: ptr.Time(time.Now().Add(-time.Hour)),
+	}, http.StatusUnprocessableEntity, &createAPITokenResponse{})
+
+	// tokens can't be created for other users, even by admins
+	s.DoJSON("POST", fmt.Sprintf("/api/latest/fleet/users/%d/api_tokens", observer.ID), fleet.APITokenPayload{
+		Name:   "other user",
+		Scopes: fleet.APITokenScopes{{Object: "host", Actions: []string{"read"}}},
+	}, http.StatusForbidden, &createAPITokenResponse{})
+
+	var createResp createAPITokenResponse
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{
+		Name:      "hosts read-only",
+		Scopes:    fleet.APITokenScopes{{Object: "host", Actions: []string{"read", "list"}}},
+		ExpiresAt: ptr.Time(time.Now().Add(time.Hour)),
+	}, http.StatusOK, &createResp)
+	readOnly := createResp.APIToken
+	require.NotNil(t, readOnly)
+	require.True(t, strings.HasPrefix(readOnly.Key, fleet.APITokenPrefix))
+
+	// the name must be unique
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{
+		Name:   "hosts read-only",
+		Scopes: fleet.APITokenScopes{{Object: "*", Actions: []string{"*"}}},
+	}, http.StatusConflict, &createAPITokenResponse{})
+
+	createResp = createAPITokenResponse{}
+	s.DoJSON("POST", tokensPath, fleet.APITokenPayload{
+		Name:   "team hosts",
+		TeamID: &team.ID,
+		Scopes: fleet.APITokenScopes{{Object: "host", Actions: []string{"read", "list"}}},
+	}, http.StatusOK, &createResp)
+	teamHosts := createResp.APIToken
+	require.NotNil(t, teamHosts)
+
+	// the key is not returned when listing
+	var listResp listAPITokensResponse
+	s.DoJSON("GET", tokensPath, nil, http.StatusOK, &listResp)
+	require.Len(t, listResp.APITokens, 2)
+	assert.Equal(t, "hosts read-only", listResp.APITokens[0].Name)
+	assert.Empty(t, listResp.APITokens[0].Key)
+	assert.Nil(t, listResp.APITokens[0].LastUsedAt)
+	assert.Equal(t, &team.ID, listResp.APITokens[1].TeamID)
+
+	// the read-only token can read the hosts, but nothing else
+	s.token = readOnly.Key
+	s.DoJSON("GET", "/api/latest/fleet/me", nil, http.StatusOK, &getUserResponse{})
+	s.DoJSON("GET", "/api/latest/fleet/hosts", nil, http.StatusOK, &listHostsResponse{})
+	s.DoJSON("GET", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusOK, &getHostResponse{})
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusForbidden)
+	s.Do("GET", "/api/latest/fleet/queries", nil, http.StatusForbidden)
+	// and it can't manage the tokens
+	s.Do("GET", tokensPath, nil, http.StatusForbidden)
+	s.Do("DELETE", fmt.Sprintf("%s/%d", tokensPath, teamHosts.ID), nil, http.StatusForbidden)
+
+	// the team token can't read the hosts of other teams
+	s.token = teamHosts.Key
+	s.Do("GET", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusForbidden)
+	var hostsResp listHostsResponse
+	s.DoJSON("GET", "/api/latest/fleet/hosts", nil, http.StatusOK, &hostsResp)
+	for _, h := range hostsResp.Hosts {
+		require.Equal(t, &team.ID, h.TeamID)
+	}
+
+	s.token = s.getTestAdminToken()
+	listResp = listAPITokensResponse{}
+	s.DoJSON("GET", tokensPath, nil, http.StatusOK, &listResp)
+	require.Len(t, listResp.APITokens, 2)
+	assert.NotNil(t, listResp.APITokens[0].LastUsedAt)
+
+	// expired tokens can't be used
+	expiredKey := fleet.APITokenPrefix + "expired"
+	_, err = s.ds.NewAPIToken(ctx, &fleet.APIToken{
+		UserID:    admin.ID,
+		Name:      "expired",
+		KeyHash:   fleet.HashAPITokenKey(expiredKey),
+		Scopes:    fleet.APITokenScopes{{Object: "*", Actions: []string{"*"}}},
+		ExpiresAt: ptr.Time(time.Now().Add(-time.Minute)),
+	})
+	require.NoError(t, err)
+	s.token = expiredKey
+	s.Do("GET", "/api/latest/fleet/me", nil, http.StatusUnauthorized)
+
+	// revoked tokens can't be used
+	s.token = s.getTestAdminToken()
+	s.Do("DELETE", fmt.Sprintf("%s/%d", tokensPath, readOnly.ID), nil, http.StatusOK)
+	s.Do("DELETE", fmt.Sprintf("%s/%d", tokensPath, readOnly.ID), nil, http.StatusNotFound)
+	s.token = readOnly.Key
+	s.Do("GET", "/api/latest/fleet/hosts", nil, http.StatusUnauthorized)
+}
+
+func (s *integrationTestSuite) TestCustomRoles() {
+	t := s.T()
+	ctx := context.Background()
+
+	// ensure that on exit, the admin token is used
+	defer func() { s.token = s.getTestAdminToken() }()
+
+	host, err := s.ds.NewHost(ctx, &fleet.Host{
+		DetailUpdatedAt: time.Now(),
+		LabelUpdatedAt:  time.Now(),
+		PolicyUpdatedAt: time.Now(),
+		SeenTime:        time.Now(),
+		NodeKey:         t.Name() + "1",
+		UUID:            t.Name() + "1",
+		Hostname:        t.Name() + "foo.local",
+	})
+	require.NoError(t, err)
+
+	// invalid specs
+	s.DoJSON("POST", "/api/latest/fleet/spec/custom_roles", applyCustomRoleSpecsRequest{Specs: []*fleet.CustomRoleSpec{
+		{Name: fleet.RoleAdmin, Permissions: fleet.CustomRolePermissions{{Object: "host", Actions: []string{"read"}}}},
+	}}, http.StatusUnprocessableEntity, &applyCustomRoleSpecsResponse{})
+	s.DoJSON("POST", "/api/latest/fleet/spec/custom_roles", applyCustomRoleSpecsRequest{Specs: []*fleet.CustomRoleSpec{
+		{Name: "helpdesk", Permissions: fleet.CustomRolePermissions{{Object: "host", Actions: []string{"write_role"}}}},
+	}}, http.StatusUnprocessableEntity, &applyCustomRoleSpecsResponse{})
+
+	helpdesk := &fleet.CustomRoleSpec{
+		Name:        "helpdesk",
+		Description: "Read hosts and policies",
+		Permissions: fleet.CustomRolePermissions{
+			{Object: "host", Actions: []string{"read", "list"}},
+			{Object: "policy", Actions: []string{"read"}},
+		},
+	}
+	s.DoJSON("POST", "/api/latest/fleet/spec/custom_roles", applyCustomRoleSpecsRequest{Specs: []*fleet.CustomRoleSpec{helpdesk}}, http.StatusOK, &applyCustomRoleSpecsResponse{})
+
+	var getSpecsResp getCustomRoleSpecsResponse
+	s.DoJSON("GET", "/api/latest/fleet/spec/custom_roles", nil, http.StatusOK, &getSpecsResp)
+	require.Len(t, getSpecsResp.Specs, 1)
+	assert.Equal(t, helpdesk, getSpecsResp.Specs[0])
+
+	var getSpecResp getCustomRoleSpecResponse
+	s.DoJSON("GET", "/api/latest/fleet/spec/custom_roles/helpdesk", nil, http.StatusOK, &getSpecResp)
+	assert.Equal(t, helpdesk, getSpecResp.Spec)
+	s.DoJSON("GET", "/api/latest/fleet/spec/custom_roles/nope", nil, http.StatusNotFound, &getCustomRoleSpecResponse{})
+
+	// unknown custom roles can't be assigned
+	_, err = s.ds.NewUser(ctx, &fleet.User{Name: t.Name(), Email: "unknown@example.com", Password: []byte("foo"), GlobalRole: ptr.String("unknown")})
+	require.Error(t, err)
+
+	user := &fleet.User{
+		Name:       t.Name(),
+		Email:      "helpdesk@example.com",
+		GlobalRole: ptr.String("helpdesk"),
+	}
+	userRawPwd := test.GoodPassword
+	require.NoError(t, user.SetPassword(userRawPwd, 10, 10))
+	user, err = s.ds.NewUser(ctx, user)
+	require.NoError(t, err)
+
+	// the user can do what the role allows, and nothing else
+	s.token = s.getTestToken(user.Email, userRawPwd)
+	s.DoJSON("GET", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusOK, &getHostResponse{})
+	s.DoJSON("DELETE", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusForbidden, &deleteHostResponse{})
+	s.DoJSON("POST", "/api/latest/fleet/queries", fleet.QueryPayload{Name: ptr.String(t.Name()), Query: ptr.String("select 1")}, http.StatusForbidden, &createQueryResponse{})
+	s.DoJSON("POST", "/api/latest/fleet/spec/custom_roles", applyCustomRoleSpecsRequest{Specs: []*fleet.CustomRoleSpec{helpdesk}}, http.StatusForbidden, &applyCustomRoleSpecsResponse{})
+	var listHostsResp listHostsResponse
+	s.DoJSON("GET", "/api/latest/fleet/hosts", nil, http.StatusOK, &listHostsResp)
+	require.Len(t, listHostsResp.Hosts, 1)
+
+	// the changes to the role apply immediately
+	s.token = s.getTestAdminToken()
+	helpdesk.Permissions = fleet.CustomRolePermissions{{Object: "policy", Actions: []string{"read"}}}
+	s.DoJSON("POST", "/api/latest/fleet/spec/custom_roles", applyCustomRoleSpecsRequest{Specs: []*fleet.CustomRoleSpec{helpdesk}}, http.StatusOK, &applyCustomRoleSpecsResponse{})
+	s.token = s.getTestToken(user.Email, userRawPwd)
+	s.DoJSON("GET", fmt.Sprintf("/api/latest/fleet/hosts/%d", host.ID), nil, http.StatusForbidden, &getHostResponse{})
+
+	// the role can't be deleted while it is assigned
+	s.token = s.getTestAdminToken()
+	s.DoJSON("DELETE", "/api/latest/fleet/custom_roles/helpdesk", nil, http.StatusUnprocessableEntity, &deleteCustomRoleResponse{})
+
+	require.NoError(t, s.ds.DeleteUser(ctx, user.ID))
+	s.DoJSON("DELETE", "/api/latest/fleet/custom_roles/helpdesk", nil, http.StatusOK, &deleteCustomRoleResponse{})
+	s.DoJSON("DELETE", "/api/latest/fleet/custom_roles/helpdesk", nil, http.StatusNotFound, &deleteCustomRoleResponse{})
+
+	var listActivities listActivitiesResponse
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &listActivities, "order_key", "id", "order_direction", "desc", "per_page", "1")
+	require.Len(t, listActivities.Activities, 1)
+	assert.Equal(t, fleet.ActivityTypeDeletedCustomRole, listActivities.Activities[0].Type)
+}
+
+func (s *integrationTestSuite) TestTwoFactorAuth() {
+	t := s.T()
+
+	// ensure that on exit, the admin token is used and the policy is not
+	// enforced anymore
+	defer func() {
+		s.token = s.getTestAdminToken()
+		s.DoRaw("PATCH", "/api/latest/fleet/config", []byte(`{"two_factor_auth_settings": {"enforced": false}}`), http.StatusOK)
+	}()
+
+	// create a new user, who must reset their password
+	pwd := test.GoodPassword
+	var createResp createUserResponse
+	s.DoJSON("POST", "/api/latest/fleet/users/admin", fleet.UserPayload{
+		Name:       ptr.String("totp"),
+		Email:      ptr.String("totp@example.com"),
+		Password:   ptr.String(pwd),
+		GlobalRole: ptr.String(fleet.RoleObserver),
+	}, http.StatusOK, &createResp)
+	u := *createResp.User
+
+	// not enrolled yet, the login returns a session
+	s.token = s.getTestToken(u.Email, pwd)
+	pwd = test.GoodPassword2
+	s.DoJSON("POST", "/api/latest/fleet/perform_required_password_reset", performRequiredPasswordResetRequest{Password: pwd}, http.StatusOK, &performRequiredPasswordResetResponse{})
+
+	// verifying without enrolling fails
+	s.DoJSON("POST", "/api/latest/fleet/me/totp/verify", verifyTOTPRequest{Code: "123456"}, http.StatusUnprocessableEntity, &verifyTOTPResponse{})
+
+	var enrollResp fleet.TOTPEnrollment
+	s.DoJSON("POST", "/api/latest/fleet/me/totp", nil, http.StatusOK, &enrollResp)
+	require.NotEmpty(t, enrollResp.Secret)
+	require.Contains(t, enrollResp.ProvisioningURI, "otpauth://totp/")
+	require.Contains(t, enrollResp.ProvisioningURI, "secret="+enrollResp.Secret)
+
+	// the codes are relative to the time step of the verification
+	step := totp.Step(time.Now())
+	codeAt := func(offset int64) string {
+		code, err := totp.Code(enrollResp.Secret, step+offset)
+		require.NoError(t, err)
+		return code
+	}
+
+	// invalid code, then valid code
+	s.DoJSON("POST", "/api/latest/fleet/me/totp/verify", verifyTOTPRequest{Code: "abcdef"}, http.StatusUnprocessableEntity, &verifyTOTPResponse{})
+	var verifyResp verifyTOTPResponse
+	s.DoJSON("POST", "/api/latest/fleet/me/totp/verify", verifyTOTPRequest{Code: codeAt(0)}, http.StatusOK, &verifyResp)
+	require.Len(t, verifyResp.RecoveryCodes, fleet.TOTPRecoveryCodesCount)
+
+	// enrolling again is not allowed while enabled
+	s.DoJSON("POST", "/api/latest/fleet/me/totp", nil, http.StatusUnprocessableEntity, &enrollTOTPResponse{})
+
+	// the password login doesn't return a session anymore
+	login := func() loginTOTPRequiredResponse {
+		var resp loginTOTPRequiredResponse
+		s.DoJSON("POST", "/api/latest/fleet/login", loginRequest{Email: u.Email, Password: pwd}, http.StatusAccepted, &resp)
+		require.True(t, resp.TOTPRequired)
+		require.NotEmpty(t, resp.TOTPToken)
+		return resp
+	}
+	challenge := login()
+	require.False(t, challenge.TOTPEnrollmentRequired)
+
+	// the enrollment can't be replaced with just the password
+	s.DoJSON("POST", "/api/latest/fleet/login/totp/enroll", enrollTOTPForLoginRequest{TOTPToken: challenge.TOTPToken}, http.StatusUnprocessableEntity, &enrollTOTPResponse{})
+
+	// invalid token and invalid code
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: "nope", Code: codeAt(1)}, http.StatusUnauthorized, &loginResponse{})
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: "000000"}, http.StatusUnauthorized, &loginResponse{})
+	// the code used to verify the enrollment can't be replayed
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: codeAt(0)}, http.StatusUnauthorized, &loginResponse{})
+
+	var loginResp loginResponse
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: codeAt(1)}, http.StatusOK, &loginResp)
+	require.Equal(t, u.ID, loginResp.User.ID)
+	require.NotEmpty(t, loginResp.Token)
+	require.Empty(t, loginResp.RecoveryCodes)
+	// the challenge can only be completed once
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: codeAt(1)}, http.StatusUnauthorized, &loginResponse{})
+
+	// a recovery code can be used once instead of a code
+	challenge = login()
+	loginResp = loginResponse{}
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: verifyResp.RecoveryCodes[0]}, http.StatusOK, &loginResp)
+	require.Equal(t, u.ID, loginResp.User.ID)
+	challenge = login()
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: verifyResp.RecoveryCodes[0]}, http.StatusUnauthorized, &loginResponse{})
+
+	// the challenge is rejected after too many invalid codes
+	challenge = login()
+	for i := 0; i < fleet.TOTPLoginChallengeMaxAttempts; i++ {
+		s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: "000000"}, http.StatusUnauthorized, &loginResponse{})
+	}
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: verifyResp.RecoveryCodes[1]}, http.StatusUnauthorized, &loginResponse{})
+
+	// when a password reset is required, the second factor is still required
+	// before the session of the password reset is issued
+	s.token = s.getTestAdminToken()
+	s.DoJSON("POST", fmt.Sprintf("/api/latest/fleet/users/%d/require_password_reset", u.ID), map[string]bool{"require": true}, http.StatusOK, &requirePasswordResetResponse{})
+	challenge = login()
+	loginResp = loginResponse{}
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: verifyResp.RecoveryCodes[2]}, http.StatusOK, &loginResp)
+	require.True(t, loginResp.User.AdminForcedPasswordReset)
+	s.token = loginResp.Token
+	s.DoJSON("POST", "/api/latest/fleet/me/totp", nil, http.StatusUnauthorized, &enrollTOTPResponse{})
+	pwd = "n3w-p4ssw0rd!"
+	s.DoJSON("POST", "/api/latest/fleet/perform_required_password_reset", performRequiredPasswordResetRequest{Password: pwd}, http.StatusOK, &performRequiredPasswordResetResponse{})
+	login()
+
+	// other users can't disable the second factor, admins can
+	s.token = s.getTestToken("user2@example.com", test.GoodPassword)
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), nil, http.StatusForbidden)
+	s.token = s.getTestAdminToken()
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), nil, http.StatusOK)
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID+1000), nil, http.StatusNotFound)
+	// the password login returns a session again
+	s.token = s.getTestToken(u.Email, pwd)
+	s.DoJSON("GET", "/api/latest/fleet/me", nil, http.StatusOK, &getUserResponse{})
+
+	// enforce two-factor authentication, the user must enroll when logging in
+	s.token = s.getTestAdminToken()
+	s.DoRaw("PATCH", "/api/latest/fleet/config", []byte(`{"two_factor_auth_settings": {"enforced": true}}`), http.StatusOK)
+	challenge = login()
+	require.True(t, challenge.TOTPEnrollmentRequired)
+	// the enrollment must be started first
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: codeAt(0)}, http.StatusUnauthorized, &loginResponse{})
+
+	enrollResp = fleet.TOTPEnrollment{}
+	s.DoJSON("POST", "/api/latest/fleet/login/totp/enroll", enrollTOTPForLoginRequest{TOTPToken: challenge.TOTPToken}, http.StatusOK, &enrollResp)
+	require.NotEmpty(t, enrollResp.Secret)
+	step = totp.Step(time.Now())
+	loginResp = loginResponse{}
+	s.DoJSON("POST", "/api/latest/fleet/login/totp", loginTOTPRequest{TOTPToken: challenge.TOTPToken, Code: codeAt(0)}, http.StatusOK, &loginResp)
+	require.Len(t, loginResp.RecoveryCodes, fleet.TOTPRecoveryCodesCount)
+
+	// users can't opt out while it is enforced
+	userToken := loginResp.Token
+	s.token = userToken
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), nil, http.StatusForbidden)
+
+	// once it is not enforced anymore, users can opt out with their password
+	// or a code, the session is not enough
+	s.token = s.getTestAdminToken()
+	s.DoRaw("PATCH", "/api/latest/fleet/config", []byte(`{"two_factor_auth_settings": {"enforced": false}}`), http.StatusOK)
+	s.token = userToken
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), nil, http.StatusUnprocessableEntity)
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), map[string]string{"password": "wrong", "code": "000000"}, http.StatusUnprocessableEntity)
+	s.Do("DELETE", fmt.Sprintf("/api/latest/fleet/users/%d/totp", u.ID), map[string]string{"password": pwd}, http.StatusOK)
+	s.token = s.getTestToken(u.Email, pwd)
+	s.DoJSON("GET", "/api/latest/fleet/me", nil, http.StatusOK, &getUserResponse{})
+}
+
 func (s *integrationTestSuite) TestAPIVersion_v1_2022_04() {
 	t := s.T()
 
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/fleetdm/fleet/v4/server/config"
 	"github.com/fleetdm/fleet/v4/server/datastore/mysql"
 	"github.com/fleetdm/fleet/v4/server/datastore/redis/redistest"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -22,6 +23,8 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+const testSCIMToken = "scim-test-token"
+
 func TestIntegrationsEnterprise(t *testing.T) {
 	testingSuite := new(integrationEnterpriseTestSuite)
 	testingSuite.s = &testingSuite.Suite
@@ -38,11 +41,14 @@ func (s *integrationEnterpriseTestSuite) SetupSuite() {
 	s.withDS.SetupSuite("integrationEnterpriseTestSuite")
 
 	s.redisPool = redistest.SetupRedis(s.T(), "integration_enterprise", false, false, false)
+	fleetConfig := config.TestConfig()
+	fleetConfig.Server.SCIMToken = testSCIMToken
 	config := TestServerOpts{
 		License: &fleet.LicenseInfo{
 			Tier: fleet.TierPremium,
 		},
-		Pool: s.redisPool,
+		Pool:        s.redisPool,
+		FleetConfig: &fleetConfig,
 	}
 	users, server := RunServerForTestsWithDS(s.T(), s.ds, &config)
 	s.server = server
@@ -495,6 +501,72 @@ func (s *integrationEnterpriseTestSuite) TestTeamEndpoints() {
 	s.DoJSON("DELETE", fmt.Sprintf("/api/latest/fleet/teams/%d", tm1ID), nil, http.StatusNotFound, &delResp)
 }
 
+func (s *integrationEnterpriseTestSuite) TestTeamWebhookSettings() {
+	t := s.T()
+
+	team := &fleet.Team{Name: t.Name()}
+	var tmResp teamResponse
+	s.DoJSON("POST", "/api/latest/fleet/teams", team, http.StatusOK, &tmResp)
+	team.ID = tmResp.Team.ID
+	require.Nil(t, tmResp.Team.Config.WebhookSettings.HostStatusWebhook)
+	require.Nil(t, tmResp.Team.Config.WebhookSettings.VulnerabilitiesWebhook)
+
+	// enable the team's host status and vulnerabilities webhooks
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", team.ID), fleet.TeamPayload{WebhookSettings: &fleet.TeamWebhookSettings{
+		HostStatusWebhook: &fleet.HostStatusWebhookSettings{
+			Enable:         true,
+			DestinationURL: "http://example.com/host_status",
+			Secret:         "s3cr3t",
+			HostPercentage: 10,
+			DaysCount:      3,
+		},
+		VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{
+			Enable:         true,
+			DestinationURL: "http://example.com/vulns",
+		},
+	}}, http.StatusOK, &tmResp)
+	require.NotNil(t, tmResp.Team.Config.WebhookSettings.HostStatusWebhook)
+	require.True(t, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Enable)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Secret)
+	require.NotNil(t, tmResp.Team.Config.WebhookSettings.VulnerabilitiesWebhook)
+	require.True(t, tmResp.Team.Config.WebhookSettings.VulnerabilitiesWebhook.Enable)
+
+	// modifying only the failing policies webhook keeps the other webhooks
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", team.ID), fleet.TeamPayload{WebhookSettings: &fleet.TeamWebhookSettings{
+		FailingPoliciesWebhook: fleet.FailingPoliciesWebhookSettings{
+			Enable:         true,
+			DestinationURL: "http://example.com/policies",
+		},
+	}}, http.StatusOK, &tmResp)
+	require.True(t, tmResp.Team.Config.WebhookSettings.FailingPoliciesWebhook.Enable)
+	require.NotNil(t, tmResp.Team.Config.WebhookSettings.HostStatusWebhook)
+	require.Equal(t, "http://example.com/host_status", tmResp.Team.Config.WebhookSettings.HostStatusWebhook.DestinationURL)
+	require.NotNil(t, tmResp.Team.Config.WebhookSettings.VulnerabilitiesWebhook)
+
+	// applying back the masked secret keeps the stored secret
+	hostStatus := *tmResp.Team.Config.WebhookSettings.HostStatusWebhook
+	hostStatus.Enable = false
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", team.ID), fleet.TeamPayload{WebhookSettings: &fleet.TeamWebhookSettings{
+		HostStatusWebhook: &hostStatus,
+	}}, http.StatusOK, &tmResp)
+	require.False(t, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Enable)
+
+	stored, err := s.ds.Team(context.Background(), team.ID)
+	require.NoError(t, err)
+	require.Equal(t, "s3cr3t", stored.Config.WebhookSettings.HostStatusWebhook.Secret)
+
+	// the secret is masked by all the endpoints that return the team
+	tmResp.Team = nil
+	s.DoJSON("POST", fmt.Sprintf("/api/latest/fleet/teams/%d/agent_options", team.ID), json.RawMessage(`{"config": {"foo": "bar"}}`), http.StatusOK, &tmResp)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Secret)
+	tmResp.Team = nil
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d/users", team.ID), modifyTeamUsersRequest{Users: []fleet.TeamUser{}}, http.StatusOK, &tmResp)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Secret)
+	tmResp.Team = nil
+	s.DoJSON("DELETE", fmt.Sprintf("/api/latest/fleet/teams/%d/users", team.ID), modifyTeamUsersRequest{Users: []fleet.TeamUser{}}, http.StatusOK, &tmResp)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.WebhookSettings.HostStatusWebhook.Secret)
+}
+
 func (s *integrationEnterpriseTestSuite) TestExternalIntegrationsTeamConfig() {
 	t := s.T()
 
@@ -1021,6 +1093,73 @@ func (s *integrationEnterpriseTestSuite) TestExternalIntegrationsTeamConfig() {
 	}`), http.StatusOK)
 }
 
+func (s *integrationEnterpriseTestSuite) TestTeamIntegrationURLsObfuscated() {
+	t := s.T()
+	ctx := context.Background()
+
+	msTeamsURL := "https://example.webhook.office.com/webhookb2/xyz"
+	s.DoRaw("PATCH", "/api/v1/fleet/config", []byte(fmt.Sprintf(`{
+		"integrations": {
+			"microsoft_teams": [{"url": %q}]
+		}
+	}`, msTeamsURL)), http.StatusOK)
+
+	var tmResp teamResponse
+	s.DoJSON("POST", "/api/latest/fleet/teams", &fleet.Team{Name: t.Name()}, http.StatusOK, &tmResp)
+	teamID := tmResp.Team.ID
+
+	// the global admin sees the URL
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", teamID), fleet.TeamPayload{Integrations: &fleet.TeamIntegrations{
+		MicrosoftTeams: []*fleet.TeamMicrosoftTeamsIntegration{{URL: msTeamsURL}},
+	}}, http.StatusOK, &tmResp)
+	require.Len(t, tmResp.Team.Config.Integrations.MicrosoftTeams, 1)
+	require.Equal(t, msTeamsURL, tmResp.Team.Config.Integrations.MicrosoftTeams[0].URL)
+
+	// the team admin sees the masked URL
+	email, password := "team-admin-integrations@example.com", test.GoodPassword
+	u := &fleet.User{
+		Name:  "team admin",
+		Email: email,
+		Teams: []fleet.UserTeam{{Team: fleet.Team{ID: teamID}, Role: fleet.RoleAdmin}},
+	}
+	require.NoError(t, u.SetPassword(password, 10, 10))
+	_, err := s.ds.NewUser(ctx, u)
+	require.NoError(t, err)
+	s.token = s.getTestToken(email, password)
+	defer func() { s.token = s.getTestAdminToken() }()
+
+	s.DoJSON("GET", fmt.Sprintf("/api/latest/fleet/teams/%d", teamID), nil, http.StatusOK, &tmResp)
+	require.Len(t, tmResp.Team.Config.Integrations.MicrosoftTeams, 1)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.Integrations.MicrosoftTeams[0].URL)
+
+	var listResp listTeamsResponse
+	s.DoJSON("GET", "/api/latest/fleet/teams", nil, http.StatusOK, &listResp)
+	require.Len(t, listResp.Teams, 1)
+	require.Equal(t, fleet.MaskedPassword, listResp.Teams[0].Config.Integrations.MicrosoftTeams[0].URL)
+
+	// applying back the masked URL keeps the stored one
+	intgs := tmResp.Team.Config.Integrations
+	intgs.MicrosoftTeams[0].EnableFailingPolicies = true
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", teamID), fleet.TeamPayload{Integrations: &intgs}, http.StatusOK, &tmResp)
+	require.Equal(t, fleet.MaskedPassword, tmResp.Team.Config.Integrations.MicrosoftTeams[0].URL)
+	require.True(t, tmResp.Team.Config.Integrations.MicrosoftTeams[0].EnableFailingPolicies)
+
+	stored, err := s.ds.Team(ctx, teamID)
+	require.NoError(t, err)
+	require.Len(t, stored.Config.Integrations.MicrosoftTeams, 1)
+	require.Equal(t, msTeamsURL, stored.Config.Integrations.MicrosoftTeams[0].URL)
+	require.True(t, stored.Config.Integrations.MicrosoftTeams[0].EnableFailingPolicies)
+
+	// the masked URL of a new integration is not restored
+	intgs.MicrosoftTeams = append(intgs.MicrosoftTeams, &fleet.TeamMicrosoftTeamsIntegration{URL: fleet.MaskedPassword})
+	s.DoJSON("PATCH", fmt.Sprintf("/api/latest/fleet/teams/%d", teamID), fleet.TeamPayload{Integrations: &intgs}, http.StatusUnprocessableEntity, &tmResp)
+
+	s.token = s.getTestAdminToken()
+	s.DoRaw("PATCH", "/api/v1/fleet/config", []byte(`{
+		"integrations": {}
+	}`), http.StatusOK)
+}
+
 func (s *integrationEnterpriseTestSuite) TestListDevicePolicies() {
 	t := s.T()
 
@@ -1264,3 +1403,283 @@ func (s *integrationEnterpriseTestSuite) TestSSOJITProvisioning() {
 		return false
 	})
 }
+
+func (s *integrationEnterpriseTestSuite) TestSSORoleMappings() {
+	t := s.T()
+	ctx := context.Background()
+
+	team, err := s.ds.NewTeam(ctx, &fleet.Team{Name: "sso role mappings team"})
+	require.NoError(t, err)
+
+	// the SimpleSAML users are members of group1 (eduPersonAffiliation)
+	config := fleet.AppConfig{
+		SSOSettings: fleet.SSOSettings{
+			EnableSSO:             true,
+			EntityID:              "https://localhost:8080",
+			IssuerURI:             "http://localhost:8080/simplesaml/saml2/idp/SSOService.php",
+			IDPName:               "SimpleSAML",
+			MetadataURL:           "http://localhost:9080/simplesaml/saml2/idp/metadata.php",
+			EnableJITProvisioning: true,
+			GroupsAttribute:       "eduPersonAffiliation",
+			RoleMappings: []fleet.SSORoleMapping{
+				{Group: "group1", Role: fleet.RoleMaintainer, Team: team.Name},
+			},
+		},
+	}
+	acResp := appConfigResponse{}
+	s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &acResp)
+	require.Len(t, acResp.SSOSettings.RoleMappings, 1)
+	defer func() {
+		config.SSOSettings.RoleMappings = nil
+		config.SSOSettings.RejectUnmappedUsers = false
+		s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &appConfigResponse{})
+	}()
+
+	// the new user is a maintainer of the mapped team
+	auth, body := s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "Redirecting to Fleet at  ...")
+	user, err := s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Nil(t, user.GlobalRole)
+	require.Len(t, user.Teams, 1)
+	require.Equal(t, team.ID, user.Teams[0].ID)
+	require.Equal(t, fleet.RoleMaintainer, user.Teams[0].Role)
+
+	// a global role mapping takes precedence and is applied on the next login
+	config.SSOSettings.RoleMappings = append(config.SSOSettings.RoleMappings, fleet.SSORoleMapping{Group: "group1", Role: fleet.RoleAdmin})
+	s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &appConfigResponse{})
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "Redirecting to Fleet at  ...")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.NotNil(t, user.GlobalRole)
+	require.Equal(t, fleet.RoleAdmin, *user.GlobalRole)
+	require.Empty(t, user.Teams)
+
+	activitiesResp := listActivitiesResponse{}
+	s.DoJSON("GET", "/api/latest/fleet/activities", nil, http.StatusOK, &activitiesResp)
+	require.Condition(t, func() bool {
+		for _, a := range activitiesResp.Activities {
+			if a.ActorEmail != nil && *a.ActorEmail == auth.UserID() && a.Type == fleet.ActivityTypeUserRolesChangedBySSO {
+				return true
+			}
+		}
+		return false
+	})
+
+	// a lower global role doesn't override a higher team role
+	config.SSOSettings.RoleMappings = []fleet.SSORoleMapping{
+		{Group: "group1", Role: fleet.RoleAdmin, Team: team.Name},
+		{Group: "group1", Role: fleet.RoleObserver},
+	}
+	s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &appConfigResponse{})
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "Redirecting to Fleet at  ...")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Nil(t, user.GlobalRole)
+	require.Len(t, user.Teams, 1)
+	require.Equal(t, fleet.RoleAdmin, user.Teams[0].Role)
+
+	// users whose groups are not mapped anymore lose their sessions and can't
+	// log in, their roles are not removed
+	_, err = s.ds.NewSession(ctx, user.ID, "sso_user2_session")
+	require.NoError(t, err)
+	config.SSOSettings.RoleMappings = []fleet.SSORoleMapping{{Group: "fleet-admins", Role: fleet.RoleAdmin}}
+	config.SSOSettings.RejectUnmappedUsers = true
+	s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &appConfigResponse{})
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "/login?status=")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Nil(t, user.GlobalRole)
+	require.Len(t, user.Teams, 1)
+	_, err = s.ds.SessionByKey(ctx, "sso_user2_session")
+	require.Error(t, err)
+
+	// they don't become global observers if unmapped users are not rejected
+	config.SSOSettings.RejectUnmappedUsers = false
+	s.DoJSON("PATCH", "/api/latest/fleet/config", config, http.StatusOK, &appConfigResponse{})
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "/login?status=")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Nil(t, user.GlobalRole)
+	require.Len(t, user.Teams, 1)
+
+	// unmapped users created by JIT provisioning are global observers
+	require.NoError(t, s.ds.DeleteUser(ctx, user.ID))
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "Redirecting to Fleet at  ...")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Equal(t, fleet.RoleObserver, *user.GlobalRole)
+
+	// and keep their role on the next login
+	_, body = s.LoginSSOUser("sso_user2", "user123#")
+	require.Contains(t, body, "Redirecting to Fleet at  ...")
+	user, err = s.ds.UserByEmail(ctx, auth.UserID())
+	require.NoError(t, err)
+	require.Equal(t, fleet.RoleObserver, *user.GlobalRole)
+}
+
+func (s *integrationEnterpriseTestSuite) TestSCIMProvisioning() {
+	t := s.T()
+	ctx := context.Background()
+
+	doSCIM := func(verb, path string, body interface{}, expectedStatus int, v interface{}, queryParams ...string) {
+		var raw []byte
+		if body != nil {
+			var err error
+			raw, err = json.Marshal(body)
+			require.NoError(t, err)
+		}
+		resp := s.DoRawWithHeaders(verb, path, raw, expectedStatus, map[string]string{
+			"Authorization": "Bearer " + testSCIMToken,
+			"Content-Type":  "application/scim+json",
+		}, queryParams...)
+		defer resp.Body.Close()
+		if v != nil {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+		}
+	}
+
+	// the SCIM endpoints require the SCIM token, user tokens are not accepted
+	s.DoRawWithHeaders("GET", "/api/latest/fleet/scim/Users", nil, http.StatusUnauthorized, nil)
+	s.Do("GET", "/api/latest/fleet/scim/Users", nil, http.StatusUnauthorized)
+
+	// create a user
+	var user scimUser
+	doSCIM("POST", "/api/latest/fleet/scim/Users", map[string]interface{}{
+		"schemas":  []string{scimUserSchema},
+		"userName": "scim1@example.com",
+		"name":     map[string]string{"givenName": "Scim", "familyName": "One"},
+		"emails":   []map[string]interface{}{{"value": "scim1@example.com", "primary": true}},
+		"active":   true,
+	}, http.StatusCreated, &user)
+	assert.Equal(t, "scim1@example.com", user.UserName)
+	assert.Equal(t, "Scim One", user.DisplayName)
+	assert.True(t, user.Active)
+	userID, err := parseSCIMID(user.ID)
+	require.NoError(t, err)
+
+	dbUser, err := s.ds.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, dbUser.SSOEnabled)
+	assert.Equal(t, ptr.String(fleet.RoleObserver), dbUser.GlobalRole)
+
+	// creating it again fails
+	var scimErr scimErrorBody
+	doSCIM("POST", "/api/latest/fleet/scim/Users", map[string]interface{}{"userName": "scim1@example.com"}, http.StatusConflict, &scimErr)
+	assert.Equal(t, "uniqueness", scimErr.ScimType)
+
+	// look it up by userName
+	var list scimListResponse
+	doSCIM("GET", "/api/latest/fleet/scim/Users", nil, http.StatusOK, &list, "filter", `userName eq "scim1@example.com"`)
+	assert.Equal(t, 1, list.TotalResults)
+	doSCIM("GET", "/api/latest/fleet/scim/Users", nil, http.StatusOK, &list, "filter", `userName eq "nosuchuser@example.com"`)
+	assert.Equal(t, 0, list.TotalResults)
+
+	// create a group with the user, it becomes a team observer
+	var group scimGroup
+	doSCIM("POST", "/api/latest/fleet/scim/Groups", map[string]interface{}{
+		"displayName": "scim team",
+		"members":     []map[string]string{{"value": user.ID}},
+	}, http.StatusCreated, &group)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, user.ID, group.Members[0].Value)
+	teamID, err := parseSCIMID(group.ID)
+	require.NoError(t, err)
+
+	dbUser, err = s.ds.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Nil(t, dbUser.GlobalRole)
+	require.Len(t, dbUser.Teams, 1)
+	assert.Equal(t, teamID, dbUser.Teams[0].ID)
+	assert.Equal(t, fleet.RoleObserver, dbUser.Teams[0].Role)
+
+	doSCIM("GET", "/api/latest/fleet/scim/Groups", nil, http.StatusOK, &list, "filter", `displayName eq "scim team"`)
+	assert.Equal(t, 1, list.TotalResults)
+
+	// the local users (not SSO users) can't be added to the group
+	admin := s.users["admin1@example.com"]
+	doSCIM("PATCH", "/api/latest/fleet/scim/Groups/"+group.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "add", "path": "members", "value": []map[string]string{{"value": fmt.Sprint(admin.ID)}}},
+		},
+	}, http.StatusBadRequest, &scimErr)
+	doSCIM("GET", "/api/latest/fleet/scim/Groups/"+group.ID, nil, http.StatusOK, &group)
+	require.Len(t, group.Members, 1)
+
+	// renaming the team is not supported
+	doSCIM("PATCH", "/api/latest/fleet/scim/Groups/"+group.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{{"op": "replace", "path": "displayName", "value": "other"}},
+	}, http.StatusBadRequest, &scimErr)
+	assert.Equal(t, "mutability", scimErr.ScimType)
+
+	// deactivating the user deletes its sessions
+	_, err = s.ds.NewSession(ctx, userID, "scim1-session-key")
+	require.NoError(t, err)
+	doSCIM("PATCH", "/api/latest/fleet/scim/Users/"+user.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{{"op": "replace", "value": map[string]interface{}{"active": false}}},
+	}, http.StatusOK, &user)
+	assert.False(t, user.Active)
+	_, err = s.ds.SessionByKey(ctx, "scim1-session-key")
+	require.Error(t, err)
+	dbUser, err = s.ds.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, dbUser.Disabled)
+
+	doSCIM("PATCH", "/api/latest/fleet/scim/Users/"+user.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{{"op": "replace", "value": map[string]interface{}{"active": true}}},
+	}, http.StatusOK, &user)
+	assert.True(t, user.Active)
+
+	// removing the user from its last group deactivates it, it does not get a
+	// global role
+	_, err = s.ds.NewSession(ctx, userID, "scim1-session-key2")
+	require.NoError(t, err)
+	doSCIM("PATCH", "/api/latest/fleet/scim/Groups/"+group.ID, map[string]interface{}{
+		"Operations": []map[string]interface{}{
+			{"op": "remove", "path": fmt.Sprintf(`members[value eq "%s"]`, user.ID)},
+		},
+	}, http.StatusOK, &group)
+	assert.Empty(t, group.Members)
+	dbUser, err = s.ds.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Nil(t, dbUser.GlobalRole)
+	assert.Empty(t, dbUser.Teams)
+	assert.True(t, dbUser.Disabled)
+	_, err = s.ds.SessionByKey(ctx, "scim1-session-key2")
+	require.Error(t, err)
+
+	// delete the user
+	doSCIM("DELETE", "/api/latest/fleet/scim/Users/"+user.ID, nil, http.StatusNoContent, nil)
+	doSCIM("GET", "/api/latest/fleet/scim/Users/"+user.ID, nil, http.StatusNotFound, &scimErr)
+	assert.Equal(t, "404", scimErr.Status)
+
+	// the users that are not SSO users can't be read, modified or deleted with
+	// the SCIM token
+	localID := fmt.Sprint(admin.ID)
+	doSCIM("GET", "/api/latest/fleet/scim/Users/"+localID, nil, http.StatusNotFound, &scimErr)
+	doSCIM("GET", "/api/latest/fleet/scim/Users", nil, http.StatusOK, &list, "filter", `userName eq "admin1@example.com"`)
+	assert.Zero(t, list.TotalResults)
+	doSCIM("PATCH", "/api/latest/fleet/scim/Users/"+localID, map[string]interface{}{
+		"Operations": []map[string]interface{}{{"op": "replace", "path": "userName", "value": "takeover@example.com"}},
+	}, http.StatusNotFound, &scimErr)
+	doSCIM("PUT", "/api/latest/fleet/scim/Users/"+localID, map[string]interface{}{
+		"userName": "takeover@example.com",
+		"active":   false,
+	}, http.StatusNotFound, &scimErr)
+	doSCIM("DELETE", "/api/latest/fleet/scim/Users/"+localID, nil, http.StatusNotFound, &scimErr)
+	dbAdmin, err := s.ds.UserByID(ctx, admin.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "admin1@example.com", dbAdmin.Email)
+	assert.False(t, dbAdmin.Disabled)
+	assert.Equal(t, ptr.String(fleet.RoleAdmin), dbAdmin.GlobalRole)
+
+	// deleting the group keeps the team
+	doSCIM("DELETE", "/api/latest/fleet/scim/Groups/"+group.ID, nil, http.StatusNoContent, nil)
+	_, err = s.ds.Team(ctx, teamID)
+	require.NoError(t, err)
+}
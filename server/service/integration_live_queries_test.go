@@ -134,6 +134,23 @@ func (s *liveQueriesTestSuite) TestLiveQueriesRestOneHostOneQuery() {
 	require.Len(t, liveQueryResp.Results[0].Results[0].Rows, 1)
 	assert.Equal(t, "a", liveQueryResp.Results[0].Results[0].Rows[0]["col1"])
 	assert.Equal(t, "b", liveQueryResp.Results[0].Results[0].Rows[0]["col2"])
+
+	// the result is also stored for the campaign
+	var resultsResp listCampaignResultsResponse
+	s.DoJSON("GET", "/api/latest/fleet/queries/run/"+cid+"/results", nil, http.StatusOK, &resultsResp)
+	require.NotNil(t, resultsResp.Campaign)
+	assert.Equal(t, cid, fmt.Sprint(resultsResp.Campaign.ID))
+	require.Len(t, resultsResp.Results, 1)
+	assert.Equal(t, host.ID, resultsResp.Results[0].HostID)
+	assert.Equal(t, host.Hostname, resultsResp.Results[0].Hostname)
+	assert.Equal(t, []map[string]string{{"col1": "a", "col2": "b"}}, resultsResp.Results[0].Rows)
+	assert.Nil(t, resultsResp.Results[0].Error)
+
+	resultsResp = listCampaignResultsResponse{}
+	s.DoJSON("GET", "/api/latest/fleet/queries/run/"+cid+"/results", nil, http.StatusOK, &resultsResp, "only_errors", "true")
+	require.Empty(t, resultsResp.Results)
+
+	s.DoJSON("GET", "/api/latest/fleet/queries/run/999999/results", nil, http.StatusNotFound, &resultsResp)
 }
 
 func (s *liveQueriesTestSuite) TestLiveQueriesRestOneHostMultipleQuery() {
@@ -320,6 +337,106 @@ func (s *liveQueriesTestSuite) TestLiveQueriesRestMultipleHostMultipleQuery() {
 	}
 }
 
+func (s *liveQueriesTestSuite) TestLiveQueriesRestAggregated() {
+	t := s.T()
+
+	h1, h2, h3 := s.hosts[0], s.hosts[1], s.hosts[2]
+
+	q1, err := s.ds.NewQuery(context.Background(), &fleet.Query{Query: "select * from osquery_info;", Description: "desc1", Name: t.Name() + "query1"})
+	require.NoError(t, err)
+
+	for _, h := range []*fleet.Host{h1, h2, h3} {
+		s.lq.On("QueriesForHost", h.ID).Return(map[string]string{fmt.Sprint(q1.ID): "select * from osquery_info;"}, nil)
+	}
+	s.lq.On("QueryCompletedByHost", mock.Anything, mock.Anything).Return(nil)
+	s.lq.On("RunQuery", mock.Anything, "select * from osquery_info;", []uint{h1.ID, h2.ID, h3.ID}).Return(nil)
+	s.lq.On("StopQuery", mock.Anything).Return(nil)
+
+	// an invalid aggregation is rejected before any campaign is created
+	liveQueryResp := runLiveQueryResponse{}
+	s.DoJSON("GET", "/api/latest/fleet/queries/run", runLiveQueryRequest{
+		QueryIDs:    []uint{q1.ID},
+		HostIDs:     []uint{h1.ID, h2.ID, h3.ID},
+		Aggregation: &fleet.LiveQueryAggregation{Aggregates: []fleet.LiveQueryAggregateSpec{{Func: "avg", Column: "version"}}},
+	}, http.StatusUnprocessableEntity, &liveQueryResp)
+	campaigns, err := s.ds.DistributedQueryCampaignsForQuery(context.Background(), q1.ID)
+	require.NoError(t, err)
+	require.Empty(t, campaigns)
+
+	liveQueryRequest := runLiveQueryRequest{
+		QueryIDs: []uint{q1.ID},
+		HostIDs:  []uint{h1.ID, h2.ID, h3.ID},
+		Aggregation: &fleet.LiveQueryAggregation{
+			GroupBy: []string{"version"},
+			Aggregates: []fleet.LiveQueryAggregateSpec{
+				{Func: fleet.LiveQueryAggregateCount},
+				{Func: fleet.LiveQueryAggregateMax, Column: "build"},
+			},
+		},
+	}
+	liveQueryResp = runLiveQueryResponse{}
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.DoJSON("GET", "/api/latest/fleet/queries/run", liveQueryRequest, http.StatusOK, &liveQueryResp)
+	}()
+
+	// Give the above call a couple of seconds to create the campaign
+	time.Sleep(2 * time.Second)
+	cid1 := getCIDForQ(s, q1)
+	for i, h := range []*fleet.Host{h1, h2} {
+		distributedReq := SubmitDistributedQueryResultsRequest{
+			NodeKey: h.NodeKey,
+			Results: map[string][]map[string]string{
+				hostDistributedQueryPrefix + cid1: {{"version": "5.4.0", "build": fmt.Sprint(9 + i)}},
+			},
+			Statuses: map[string]fleet.OsqueryStatus{
+				hostDistributedQueryPrefix + cid1: 0,
+			},
+		}
+		distributedResp := submitDistributedQueryResultsResponse{}
+		s.DoJSON("POST", "/api/osquery/distributed/write", distributedReq, http.StatusOK, &distributedResp)
+	}
+	distributedReq := submitDistributedQueryResultsRequestShim{
+		NodeKey: h3.NodeKey,
+		Results: map[string]json.RawMessage{
+			hostDistributedQueryPrefix + cid1: json.RawMessage(`""`),
+		},
+		Statuses: map[string]interface{}{
+			hostDistributedQueryPrefix + cid1: 123,
+		},
+		Messages: map[string]string{
+			hostDistributedQueryPrefix + cid1: "some error!",
+		},
+	}
+	distributedResp := submitDistributedQueryResultsResponse{}
+	s.DoJSON("POST", "/api/osquery/distributed/write", distributedReq, http.StatusOK, &distributedResp)
+
+	wg.Wait()
+
+	require.Len(t, liveQueryResp.Results, 1)
+	assert.Equal(t, 3, liveQueryResp.Summary.RespondedHostCount)
+
+	result := liveQueryResp.Results[0]
+	require.Empty(t, result.Results)
+	require.NotNil(t, result.Aggregate)
+	assert.Equal(t, &fleet.LiveQueryAggregate{
+		Rows:       []map[string]string{{"version": "5.4.0", "host_count": "2", "count": "2", "max(build)": "10"}},
+		HostCount:  3,
+		ErrorCount: 1,
+		RowCount:   2,
+	}, result.Aggregate)
+
+	require.Len(t, result.Hosts, 3)
+	sort.Slice(result.Hosts, func(i, j int) bool { return result.Hosts[i].HostID < result.Hosts[j].HostID })
+	assert.Equal(t, fleet.LiveQueryHostStatus{HostID: h1.ID, Hostname: h1.Hostname, RowCount: 1}, result.Hosts[0])
+	assert.Equal(t, fleet.LiveQueryHostStatus{HostID: h2.ID, Hostname: h2.Hostname, RowCount: 1}, result.Hosts[1])
+	require.NotNil(t, result.Hosts[2].Error)
+	assert.Equal(t, "some error!", *result.Hosts[2].Error)
+}
+
 func (s *liveQueriesTestSuite) TestLiveQueriesRestFailsToCreateCampaign() {
 	t := s.T()
 
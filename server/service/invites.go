@@ -50,6 +50,10 @@ func (svc *Service) InviteNewUser(ctx context.Context, payload fleet.InvitePaylo
 	}
 	*payload.Email = strings.ToLower(*payload.Email)
 
+	if err := svc.validateRole(ctx, payload.GlobalRole.Ptr(), payload.Teams); err != nil {
+		return nil, err
+	}
+
 	// verify that the user with the given email does not already exist
 	_, err := svc.ds.UserByEmail(ctx, *payload.Email)
 	if err == nil {
@@ -228,7 +232,7 @@ func (svc *Service) UpdateInvite(ctx context.Context, id uint, payload fleet.Inv
 	}
 
 	if payload.GlobalRole.Valid || len(payload.Teams) > 0 {
-		if err := fleet.ValidateRole(payload.GlobalRole.Ptr(), payload.Teams); err != nil {
+		if err := svc.validateRole(ctx, payload.GlobalRole.Ptr(), payload.Teams); err != nil {
 			return nil, err
 		}
 		invite.GlobalRole = payload.GlobalRole
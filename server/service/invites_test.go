@@ -40,7 +40,8 @@ func TestInviteNewUserMock(t *testing.T) {
 	}, ms, nil}
 
 	payload := fleet.InvitePayload{
-		Email: ptr.String("user@acme.co"),
+		Email:      ptr.String("user@acme.co"),
+		GlobalRole: null.StringFrom(fleet.RoleObserver),
 	}
 
 	// happy path
@@ -51,6 +52,21 @@ func TestInviteNewUserMock(t *testing.T) {
 	assert.True(t, ms.AppConfigFuncInvoked)
 	assert.True(t, mailer.Invoked)
 
+	// the role is validated
+	ms.ListCustomRolesFunc = func(ctx context.Context) ([]*fleet.CustomRole, error) {
+		return []*fleet.CustomRole{{Name: "helpdesk"}}, nil
+	}
+	ms.NewInviteFuncInvoked = false
+	for _, invalid := range []fleet.InvitePayload{
+		{Email: ptr.String("user@acme.co")},
+		{Email: ptr.String("user@acme.co"), GlobalRole: null.StringFrom("unknown")},
+		{Email: ptr.String("user@acme.co"), Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: fleet.RoleAdmin}}, GlobalRole: null.StringFrom(fleet.RoleAdmin)},
+	} {
+		_, err = svc.InviteNewUser(test.UserContext(test.UserAdmin), invalid)
+		require.Error(t, err)
+	}
+	assert.False(t, ms.NewInviteFuncInvoked)
+
 	ms.UserByEmailFunc = mock.UserByEmailWithUser(new(fleet.User))
 	_, err = svc.InviteNewUser(test.UserContext(test.UserAdmin), payload)
 	require.NotNil(t, err, "should err if the user we're inviting already exists")
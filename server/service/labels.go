@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 
+	"github.com/fleetdm/fleet/v4/server/authz"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -68,6 +69,15 @@ func (svc *Service) NewLabel(ctx context.Context, p fleet.LabelPayload) (*fleet.
 	if err != nil {
 		return nil, err
 	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeCreatedLabel,
+		&map[string]interface{}{"label_id": label.ID, "label_name": label.Name, "label_query": label.Query},
+	); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create created label activity")
+	}
 	return label, nil
 }
 
@@ -111,13 +121,33 @@ func (svc *Service) ModifyLabel(ctx context.Context, id uint, payload fleet.Modi
 	if err != nil {
 		return nil, err
 	}
+	before := map[string]interface{}{"name": label.Name, "description": label.Description}
 	if payload.Name != nil {
 		label.Name = *payload.Name
 	}
 	if payload.Description != nil {
 		label.Description = *payload.Description
 	}
-	return svc.ds.SaveLabel(ctx, label)
+	label, err = svc.ds.SaveLabel(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+
+	changedBefore, changedAfter, err := activityDiff(before, map[string]interface{}{"name": label.Name, "description": label.Description})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "diff label changes")
+	}
+	if len(changedAfter) > 0 {
+		if err := svc.ds.NewActivity(
+			ctx,
+			authz.UserFromContext(ctx),
+			fleet.ActivityTypeEditedLabel,
+			&map[string]interface{}{"label_id": label.ID, "label_name": label.Name, "before": changedBefore, "after": changedAfter},
+		); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "create edited label activity")
+		}
+	}
+	return label, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -312,7 +342,22 @@ func (svc *Service) DeleteLabel(ctx context.Context, name string) error {
 		return err
 	}
 
-	return svc.ds.DeleteLabel(ctx, name)
+	if err := svc.ds.DeleteLabel(ctx, name); err != nil {
+		return err
+	}
+	return svc.newDeletedLabelActivity(ctx, nil, name)
+}
+
+func (svc *Service) newDeletedLabelActivity(ctx context.Context, id *uint, name string) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeDeletedLabel,
+		&map[string]interface{}{"label_id": id, "label_name": name},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create deleted label activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -347,7 +392,10 @@ func (svc *Service) DeleteLabelByID(ctx context.Context, id uint) error {
 	if err != nil {
 		return err
 	}
-	return svc.ds.DeleteLabel(ctx, label.Name)
+	if err := svc.ds.DeleteLabel(ctx, label.Name); err != nil {
+		return err
+	}
+	return svc.newDeletedLabelActivity(ctx, &label.ID, label.Name)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -387,7 +435,23 @@ func (svc *Service) ApplyLabelSpecs(ctx context.Context, specs []*fleet.LabelSpe
 			return ctxerr.Errorf(ctx, "label %s is declared as manual but contains no `hosts key`", spec.Name)
 		}
 	}
-	return svc.ds.ApplyLabelSpecs(ctx, specs)
+	if err := svc.ds.ApplyLabelSpecs(ctx, specs); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeAppliedSpecLabel,
+		&map[string]interface{}{"label_names": names},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create applied label spec activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
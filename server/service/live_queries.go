@@ -15,8 +15,9 @@ import (
 )
 
 type runLiveQueryRequest struct {
-	QueryIDs []uint `json:"query_ids"`
-	HostIDs  []uint `json:"host_ids"`
+	QueryIDs    []uint                      `json:"query_ids"`
+	HostIDs     []uint                      `json:"host_ids"`
+	Aggregation *fleet.LiveQueryAggregation `json:"aggregation"`
 }
 
 type summaryPayload struct {
@@ -55,14 +56,30 @@ func runLiveQueryEndpoint(ctx context.Context, request interface{}, svc fleet.Se
 		},
 	}
 
-	queryResults, respondedHostCount := svc.RunLiveQueryDeadline(ctx, req.QueryIDs, req.HostIDs, duration)
+	queryResults, respondedHostCount, err := svc.RunLiveQueryDeadline(ctx, req.QueryIDs, req.HostIDs, duration, req.Aggregation)
+	if err != nil {
+		return runLiveQueryResponse{Err: err}, nil
+	}
 	res.Results = queryResults
 	res.Summary.RespondedHostCount = respondedHostCount
 
 	return res, nil
 }
 
-func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, hostIDs []uint, deadline time.Duration) ([]fleet.QueryCampaignResult, int) {
+func (svc *Service) RunLiveQueryDeadline(
+	ctx context.Context, queryIDs []uint, hostIDs []uint, deadline time.Duration, aggregation *fleet.LiveQueryAggregation,
+) ([]fleet.QueryCampaignResult, int, error) {
+	if aggregation != nil {
+		// The query-specific authorization check is done when the campaign of
+		// each query is created.
+		if err := svc.authz.Authorize(ctx, &fleet.TargetedQuery{Query: &fleet.Query{ObserverCanRun: true}}, fleet.ActionRun); err != nil {
+			return nil, 0, err
+		}
+		if err := aggregation.Validate(); err != nil {
+			return nil, 0, ctxerr.Wrap(ctx, err, "validate aggregation")
+		}
+	}
+
 	wg := sync.WaitGroup{}
 
 	resultsCh := make(chan fleet.QueryCampaignResult)
@@ -75,7 +92,7 @@ func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, h
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			campaign, err := svc.NewDistributedQueryCampaign(ctx, "", &queryID, fleet.HostTargets{HostIDs: hostIDs})
+			campaign, err := svc.NewDistributedQueryCampaign(ctx, "", &queryID, fleet.HostTargets{HostIDs: hostIDs}, fleet.CampaignOptions{})
 			if err != nil {
 				resultsCh <- fleet.QueryCampaignResult{QueryID: queryID, Error: ptr.String(err.Error())}
 				return
@@ -95,6 +112,12 @@ func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, h
 				}
 			}()
 
+			var aggregator *liveQueryAggregator
+			var hosts []fleet.LiveQueryHostStatus
+			if aggregation != nil {
+				aggregator = newLiveQueryAggregator(*aggregation)
+			}
+
 			var results []fleet.QueryResult
 			timeout := time.After(deadline)
 		loop:
@@ -103,7 +126,11 @@ func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, h
 				case res := <-readChan:
 					switch res := res.(type) {
 					case fleet.DistributedQueryResult:
-						results = append(results, fleet.QueryResult{HostID: res.Host.ID, Rows: res.Rows, Error: res.Error})
+						if aggregator != nil {
+							hosts = append(hosts, aggregator.addResult(res))
+						} else {
+							results = append(results, fleet.QueryResult{HostID: res.Host.ID, Rows: res.Rows, Error: res.Error})
+						}
 						counterMutex.Lock()
 						respondedHostIDs[res.Host.ID] = struct{}{}
 						counterMutex.Unlock()
@@ -117,6 +144,10 @@ func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, h
 					break loop
 				}
 			}
+			if aggregator != nil {
+				resultsCh <- fleet.QueryCampaignResult{QueryID: queryID, Aggregate: aggregator.result(), Hosts: hosts}
+				return
+			}
 			resultsCh <- fleet.QueryCampaignResult{QueryID: queryID, Results: results}
 		}()
 	}
@@ -132,7 +163,7 @@ func (svc *Service) RunLiveQueryDeadline(ctx context.Context, queryIDs []uint, h
 		results = append(results, result)
 	}
 
-	return results, len(respondedHostIDs)
+	return results, len(respondedHostIDs), nil
 }
 
 func (svc *Service) GetCampaignReader(ctx context.Context, campaign *fleet.DistributedQueryCampaign) (<-chan interface{}, context.CancelFunc, error) {
@@ -156,6 +187,12 @@ func (svc *Service) GetCampaignReader(ctx context.Context, campaign *fleet.Distr
 }
 
 func (svc *Service) CompleteCampaign(ctx context.Context, campaign *fleet.DistributedQueryCampaign) error {
+	if campaign.IsCollecting(svc.clock.Now()) {
+		// an asynchronous campaign keeps running when its results are no
+		// longer streamed, it is completed by the cleanup job at the end of
+		// its collection window.
+		return nil
+	}
 	campaign.Status = fleet.QueryComplete
 	err := svc.ds.SaveDistributedQueryCampaign(ctx, campaign)
 	if err != nil {
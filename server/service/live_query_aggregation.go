@@ -0,0 +1,219 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+const (
+	// liveQueryAggregateMaxGroups is the maximum number of groups of an
+	// aggregated live query, the rows of the other groups are dropped.
+	liveQueryAggregateMaxGroups = 10000
+	// liveQueryAggregateMaxDistinct is the maximum number of distinct values
+	// counted per group and distinct aggregate.
+	liveQueryAggregateMaxDistinct = 10000
+)
+
+// liveQueryAggregator evaluates a live query aggregation as the results of
+// the hosts arrive, so that the rows of the hosts do not need to be kept or
+// sent to the client. The number of groups and of distinct values are
+// limited so that the memory used does not grow with the rows, the aggregate
+// is reported as truncated once a limit is reached.
+type liveQueryAggregator struct {
+	aggregation fleet.LiveQueryAggregation
+	maxGroups   int
+	maxDistinct int
+	groups      map[string]*aggregateGroup
+	hosts       map[uint]struct{}
+	errorCount  uint
+	rowCount    uint
+	truncated   bool
+}
+
+// aggregateGroup holds the state of the aggregates of a group, indexed as the
+// aggregates of the aggregation.
+type aggregateGroup struct {
+	values   []string
+	hosts    map[uint]struct{}
+	rowCount uint
+	minMax   []*string
+	distinct []map[string]struct{}
+}
+
+func newLiveQueryAggregator(aggregation fleet.LiveQueryAggregation) *liveQueryAggregator {
+	return &liveQueryAggregator{
+		aggregation: aggregation,
+		maxGroups:   liveQueryAggregateMaxGroups,
+		maxDistinct: liveQueryAggregateMaxDistinct,
+		groups:      make(map[string]*aggregateGroup),
+		hosts:       make(map[uint]struct{}),
+	}
+}
+
+// addResult aggregates the rows of the result and returns the status of the
+// host. Only the first result of a host is aggregated.
+func (a *liveQueryAggregator) addResult(res fleet.DistributedQueryResult) fleet.LiveQueryHostStatus {
+	status := fleet.LiveQueryHostStatus{
+		HostID:   res.Host.ID,
+		Hostname: res.Host.Hostname,
+		Error:    res.Error,
+	}
+	if _, ok := a.hosts[res.Host.ID]; ok {
+		return status
+	}
+	a.hosts[res.Host.ID] = struct{}{}
+	if res.Error != nil {
+		a.errorCount++
+		return status
+	}
+
+	for _, row := range res.Rows {
+		if row == nil {
+			continue
+		}
+		status.RowCount++
+		g := a.group(row)
+		if g == nil {
+			a.truncated = true
+			continue
+		}
+		a.rowCount++
+		if !g.add(a.aggregation.Aggregates, res.Host.ID, row, a.maxDistinct) {
+			a.truncated = true
+		}
+	}
+	return status
+}
+
+// group returns the group of the row, creating it if needed. It returns nil
+// if the group does not exist and the maximum number of groups is reached.
+func (a *liveQueryAggregator) group(row map[string]string) *aggregateGroup {
+	values := make([]string, 0, len(a.aggregation.GroupBy))
+	for _, col := range a.aggregation.GroupBy {
+		values = append(values, row[col])
+	}
+	// the values are quoted so that the key is unambiguous
+	key := fmt.Sprintf("%q", values)
+
+	g, ok := a.groups[key]
+	if !ok {
+		if len(a.groups) >= a.maxGroups {
+			return nil
+		}
+		g = &aggregateGroup{
+			values:   values,
+			hosts:    make(map[uint]struct{}),
+			minMax:   make([]*string, len(a.aggregation.Aggregates)),
+			distinct: make([]map[string]struct{}, len(a.aggregation.Aggregates)),
+		}
+		a.groups[key] = g
+	}
+	return g
+}
+
+// add aggregates the row in the group. It returns false if a distinct value
+// was not counted because of maxDistinct.
+func (g *aggregateGroup) add(specs []fleet.LiveQueryAggregateSpec, hostID uint, row map[string]string, maxDistinct int) bool {
+	g.hosts[hostID] = struct{}{}
+	g.rowCount++
+
+	complete := true
+	for i, spec := range specs {
+		v, ok := row[spec.Column]
+		if !ok {
+			// count has no column, and missing columns are ignored by the
+			// other functions
+			continue
+		}
+		switch spec.Func {
+		case fleet.LiveQueryAggregateMin:
+			if g.minMax[i] == nil || compareAggregateValues(v, *g.minMax[i]) < 0 {
+				g.minMax[i] = &v
+			}
+		case fleet.LiveQueryAggregateMax:
+			if g.minMax[i] == nil || compareAggregateValues(v, *g.minMax[i]) > 0 {
+				g.minMax[i] = &v
+			}
+		case fleet.LiveQueryAggregateDistinct:
+			if g.distinct[i] == nil {
+				g.distinct[i] = make(map[string]struct{})
+			}
+			if _, ok := g.distinct[i][v]; !ok && len(g.distinct[i]) >= maxDistinct {
+				complete = false
+				continue
+			}
+			g.distinct[i][v] = struct{}{}
+		}
+	}
+	return complete
+}
+
+// compareAggregateValues compares the values as numbers if they both are
+// numbers, as strings otherwise.
+func compareAggregateValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// result returns the current aggregate, with the rows ordered by the values of
+// the group_by columns.
+func (a *liveQueryAggregator) result() *fleet.LiveQueryAggregate {
+	groups := make([]*aggregateGroup, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		vi, vj := groups[i].values, groups[j].values
+		for k := range vi {
+			if c := compareAggregateValues(vi[k], vj[k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+
+	rows := make([]map[string]string, 0, len(groups))
+	for _, g := range groups {
+		row := make(map[string]string, len(g.values)+len(a.aggregation.Aggregates)+1)
+		for i, col := range a.aggregation.GroupBy {
+			row[col] = g.values[i]
+		}
+		row[fleet.LiveQueryAggregateHostCount] = strconv.Itoa(len(g.hosts))
+		for i, spec := range a.aggregation.Aggregates {
+			switch spec.Func {
+			case fleet.LiveQueryAggregateCount:
+				row[spec.Name()] = strconv.FormatUint(uint64(g.rowCount), 10)
+			case fleet.LiveQueryAggregateMin, fleet.LiveQueryAggregateMax:
+				// the column is omitted if no row of the group has it
+				if g.minMax[i] != nil {
+					row[spec.Name()] = *g.minMax[i]
+				}
+			case fleet.LiveQueryAggregateDistinct:
+				row[spec.Name()] = strconv.Itoa(len(g.distinct[i]))
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return &fleet.LiveQueryAggregate{
+		Rows:       rows,
+		HostCount:  uint(len(a.hosts)),
+		ErrorCount: a.errorCount,
+		RowCount:   a.rowCount,
+		Truncated:  a.truncated,
+	}
+}
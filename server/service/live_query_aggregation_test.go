@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveQueryAggregator(t *testing.T) {
+	result := func(hostID uint, rows ...map[string]string) fleet.DistributedQueryResult {
+		return fleet.DistributedQueryResult{
+			Host: fleet.Host{ID: hostID, Hostname: fmt.Sprintf("host%d", hostID)},
+			Rows: rows,
+		}
+	}
+
+	agg := newLiveQueryAggregator(fleet.LiveQueryAggregation{
+		GroupBy: []string{"name", "version"},
+		Aggregates: []fleet.LiveQueryAggregateSpec{
+			{Func: fleet.LiveQueryAggregateCount},
+			{Func: fleet.LiveQueryAggregateMin, Column: "size"},
+			{Func: fleet.LiveQueryAggregateMax, Column: "size"},
+			{Func: fleet.LiveQueryAggregateDistinct, Column: "path"},
+		},
+	})
+
+	empty := agg.result()
+	assert.Empty(t, empty.Rows)
+	assert.Zero(t, empty.HostCount)
+
+	status := agg.addResult(result(1,
+		map[string]string{"name": "chrome", "version": "105", "size": "9", "path": "/a"},
+		map[string]string{"name": "chrome", "version": "105", "size": "10", "path": "/b"},
+		nil,
+	))
+	assert.Equal(t, fleet.LiveQueryHostStatus{HostID: 1, Hostname: "host1", RowCount: 2}, status)
+
+	agg.addResult(result(2,
+		map[string]string{"name": "chrome", "version": "105", "size": "100", "path": "/a"},
+		// the size is compared as string if it is not a number
+		map[string]string{"name": "chrome", "version": "99", "size": "big", "path": "/a"},
+		map[string]string{"name": "chrome", "version": "99", "size": "abc"},
+		// the group_by columns missing from a row are empty
+		map[string]string{"name": "firefox"},
+	))
+	// only the first result of a host is aggregated
+	agg.addResult(result(2, map[string]string{"name": "chrome", "version": "105"}))
+
+	status = agg.addResult(fleet.DistributedQueryResult{Host: fleet.Host{ID: 3}, Error: ptr.String("failed")})
+	require.NotNil(t, status.Error)
+	assert.Equal(t, "failed", *status.Error)
+	assert.Zero(t, status.RowCount)
+
+	agg.addResult(result(4))
+
+	res := agg.result()
+	assert.Equal(t, uint(4), res.HostCount)
+	assert.Equal(t, uint(1), res.ErrorCount)
+	assert.Equal(t, uint(6), res.RowCount)
+	assert.Equal(t, []map[string]string{
+		{
+			"name": "chrome", "version": "99", "host_count": "1", "count": "2",
+			"min(size)": "abc", "max(size)": "big", "distinct(path)": "1",
+		},
+		{
+			"name": "chrome", "version": "105", "host_count": "2", "count": "3",
+			"min(size)": "9", "max(size)": "100", "distinct(path)": "2",
+		},
+		{
+			"name": "firefox", "version": "", "host_count": "1", "count": "1",
+			"distinct(path)": "0",
+		},
+	}, res.Rows)
+
+	// without group_by, all the rows are in the same group
+	agg = newLiveQueryAggregator(fleet.LiveQueryAggregation{
+		Aggregates: []fleet.LiveQueryAggregateSpec{{Func: fleet.LiveQueryAggregateCount}},
+	})
+	agg.addResult(result(1, map[string]string{"a": "1"}, map[string]string{"a": "2"}))
+	agg.addResult(result(2, map[string]string{"a": "3"}))
+	assert.Equal(t, []map[string]string{{"host_count": "2", "count": "3"}}, agg.result().Rows)
+}
+
+func TestLiveQueryAggregatorLimits(t *testing.T) {
+	row := func(name, path string) map[string]string {
+		return map[string]string{"name": name, "path": path}
+	}
+
+	agg := newLiveQueryAggregator(fleet.LiveQueryAggregation{
+		GroupBy:    []string{"name"},
+		Aggregates: []fleet.LiveQueryAggregateSpec{{Func: fleet.LiveQueryAggregateDistinct, Column: "path"}},
+	})
+	agg.maxGroups, agg.maxDistinct = 2, 2
+
+	agg.addResult(fleet.DistributedQueryResult{Host: fleet.Host{ID: 1}, Rows: []map[string]string{
+		row("a", "/1"), row("a", "/2"), row("b", "/1"),
+	}})
+	res := agg.result()
+	assert.False(t, res.Truncated)
+	assert.Len(t, res.Rows, 2)
+
+	// the rows of a new group are dropped once the maximum is reached
+	status := agg.addResult(fleet.DistributedQueryResult{Host: fleet.Host{ID: 2}, Rows: []map[string]string{
+		row("c", "/1"), row("a", "/1"),
+	}})
+	assert.Equal(t, 2, status.RowCount)
+	res = agg.result()
+	assert.True(t, res.Truncated)
+	assert.Len(t, res.Rows, 2)
+	assert.Equal(t, uint(4), res.RowCount)
+
+	// as are the distinct values beyond the maximum
+	agg = newLiveQueryAggregator(agg.aggregation)
+	agg.maxDistinct = 2
+	agg.addResult(fleet.DistributedQueryResult{Host: fleet.Host{ID: 1}, Rows: []map[string]string{
+		row("a", "/1"), row("a", "/2"), row("a", "/2"),
+	}})
+	assert.False(t, agg.result().Truncated)
+	agg.addResult(fleet.DistributedQueryResult{Host: fleet.Host{ID: 2}, Rows: []map[string]string{row("a", "/3")}})
+	res = agg.result()
+	assert.True(t, res.Truncated)
+	require.Len(t, res.Rows, 1)
+	assert.Equal(t, "2", res.Rows[0]["distinct(path)"])
+	assert.Equal(t, "2", res.Rows[0][fleet.LiveQueryAggregateHostCount])
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// liveQueryExportQueueSize is the number of host results waiting to be
+	// written to the live query export store.
+	liveQueryExportQueueSize = 1000
+	// liveQueryExportQueueTimeout is how long a host result waits for room in
+	// the queue once it is full, before it is dropped from the export.
+	liveQueryExportQueueTimeout = 5 * time.Second
+	// liveQueryExportDrainTimeout is how long the queued results are written
+	// for on shutdown.
+	liveQueryExportDrainTimeout = 30 * time.Second
+	// liveQueryExportWorkers is the number of results written concurrently.
+	liveQueryExportWorkers = 4
+	// liveQueryExportFormatsCacheSize is the maximum number of campaigns whose
+	// export format is cached.
+	liveQueryExportFormatsCacheSize = 1000
+)
+
+// liveQueryExporter writes the results of the hosts to the live query export
+// store in the background, so that the hosts do not wait for the uploads when
+// they submit their results. The export format of a campaign never changes, it
+// is read once and cached.
+type liveQueryExporter struct {
+	ds           fleet.Datastore
+	store        fleet.LiveQueryExportStore
+	logger       kitlog.Logger
+	queue        chan *fleet.DistributedQueryCampaignResult
+	queueTimeout time.Duration
+	// stopped is closed once the workers have drained the queue on shutdown.
+	stopped chan struct{}
+
+	mu      sync.Mutex
+	formats map[uint]fleet.LiveQueryExportFormat
+}
+
+func newLiveQueryExporter(ds fleet.Datastore, store fleet.LiveQueryExportStore, logger kitlog.Logger) *liveQueryExporter {
+	return &liveQueryExporter{
+		ds:           ds,
+		store:        store,
+		logger:       logger,
+		queue:        make(chan *fleet.DistributedQueryCampaignResult, liveQueryExportQueueSize),
+		queueTimeout: liveQueryExportQueueTimeout,
+		stopped:      make(chan struct{}),
+		formats:      make(map[uint]fleet.LiveQueryExportFormat),
+	}
+}
+
+// start writes the queued results until the context is done, then drains the
+// queue. The workers are tracked by the background tasks of the context.
+func (e *liveQueryExporter) start(ctx context.Context) {
+	tasks := backgroundTasks(ctx)
+	tasks.Add(1)
+	var wg sync.WaitGroup
+	for i := 0; i < liveQueryExportWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					e.drain()
+					return
+				case result := <-e.queue:
+					e.writeAndLog(ctx, result)
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(e.stopped)
+		tasks.Done()
+	}()
+}
+
+// drain writes the results left in the queue, for up to
+// liveQueryExportDrainTimeout.
+func (e *liveQueryExporter) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), liveQueryExportDrainTimeout)
+	defer cancel()
+
+	dropped := 0
+	for {
+		select {
+		case result := <-e.queue:
+			if ctx.Err() != nil {
+				dropped++
+				continue
+			}
+			e.writeAndLog(ctx, result)
+		default:
+			if dropped > 0 {
+				level.Error(e.logger).Log("msg", "drop campaign results not exported before shutdown", "count", dropped)
+			}
+			return
+		}
+	}
+}
+
+func (e *liveQueryExporter) writeAndLog(ctx context.Context, result *fleet.DistributedQueryCampaignResult) {
+	if err := e.write(ctx, result); err != nil {
+		level.Error(e.logger).Log(
+			"msg", "export campaign result",
+			"campaign_id", result.DistributedQueryCampaignID,
+			"host_id", result.HostID,
+			"err", err,
+		)
+	}
+}
+
+// export queues the result to be written in the background. If the queue is
+// full, it waits for up to queueTimeout for room in the queue and returns an
+// error if the result could not be queued, it is never written inline.
+func (e *liveQueryExporter) export(ctx context.Context, result *fleet.DistributedQueryCampaignResult) error {
+	select {
+	case <-e.stopped:
+		return ctxerr.New(ctx, "live query exporter stopped")
+	default:
+	}
+	select {
+	case e.queue <- result:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(e.queueTimeout)
+	defer timer.Stop()
+	select {
+	case e.queue <- result:
+		return nil
+	case <-e.stopped:
+		return ctxerr.New(ctx, "live query exporter stopped")
+	case <-ctx.Done():
+		return ctxerr.Wrap(ctx, ctx.Err(), "queue campaign result export")
+	case <-timer.C:
+		return ctxerr.New(ctx, "live query export queue is full")
+	}
+}
+
+// write writes the result to the store if its campaign is exported.
+func (e *liveQueryExporter) write(ctx context.Context, result *fleet.DistributedQueryCampaignResult) error {
+	format, err := e.exportFormat(ctx, result.DistributedQueryCampaignID)
+	if err != nil {
+		return err
+	}
+	if format == "" {
+		return nil
+	}
+	if err := e.store.WriteCampaignResultPart(ctx, format, result); err != nil {
+		return ctxerr.Wrap(ctx, err, "export campaign result")
+	}
+	return nil
+}
+
+func (e *liveQueryExporter) exportFormat(ctx context.Context, campaignID uint) (fleet.LiveQueryExportFormat, error) {
+	e.mu.Lock()
+	format, ok := e.formats[campaignID]
+	e.mu.Unlock()
+	if ok {
+		return format, nil
+	}
+
+	campaign, err := e.ds.DistributedQueryCampaign(ctx, campaignID)
+	if err != nil {
+		return "", ctxerr.Wrap(ctx, err, "get campaign")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.formats) >= liveQueryExportFormatsCacheSize {
+		// the campaigns are short-lived, so the cache is simply reset once
+		// full rather than evicting the least recently used campaigns.
+		e.formats = make(map[uint]fleet.LiveQueryExportFormat)
+	}
+	e.formats[campaignID] = campaign.ExportFormat
+	return campaign.ExportFormat, nil
+}
@@ -864,9 +864,9 @@ func (svc *Service) SubmitDistributedQueryResults(
 
 	if len(policyResults) > 0 {
 
-		// filter policy results for webhooks
+		// filter policy results for webhooks and integrations automations
 		var policyIDs []uint
-		if ac.WebhookSettings.FailingPoliciesWebhook.Enable {
+		if ac.WebhookSettings.FailingPoliciesWebhook.Enable || ac.Integrations.FailingPoliciesEnabled() {
 			policyIDs = append(policyIDs, ac.WebhookSettings.FailingPoliciesWebhook.PolicyIDs...)
 		}
 
@@ -875,7 +875,7 @@ func (svc *Service) SubmitDistributedQueryResults(
 			if err != nil {
 				logging.WithErr(ctx, err)
 			} else {
-				if team.Config.WebhookSettings.FailingPoliciesWebhook.Enable {
+				if team.Config.WebhookSettings.FailingPoliciesWebhook.Enable || team.Config.Integrations.FailingPoliciesEnabled() {
 					policyIDs = append(policyIDs, team.Config.WebhookSettings.FailingPoliciesWebhook.PolicyIDs...)
 				}
 			}
@@ -998,6 +998,16 @@ func (svc *Service) ingestDistributedQuery(ctx context.Context, host fleet.Host,
 		res.Error = &errMsg
 	}
 
+	// Store and export the result so that it can be reviewed after the
+	// campaign, failing to do so must not prevent the live result from being
+	// written.
+	if err := svc.saveCampaignResult(ctx, res); err != nil {
+		level.Error(svc.logger).Log("op", "saveCampaignResult", "err", err)
+	}
+	if err := svc.exportCampaignResult(ctx, res); err != nil {
+		level.Error(svc.logger).Log("op", "exportCampaignResult", "err", err)
+	}
+
 	err = svc.resultStore.WriteResult(res)
 	if err != nil {
 		var pse pubsub.Error
@@ -1017,6 +1027,16 @@ func (svc *Service) ingestDistributedQuery(ctx context.Context, host fleet.Host,
 			return osqueryError{message: "loading orphaned campaign: " + err.Error()}
 		}
 
+		if campaign.IsCollecting(svc.clock.Now()) {
+			// An asynchronous campaign collects the results without a
+			// listener, record the completion so that the host no longer
+			// receives the query.
+			if err := svc.liveQueryStore.QueryCompletedByHost(strconv.Itoa(campaignID), host.ID); err != nil {
+				return osqueryError{message: "record query completion: " + err.Error()}
+			}
+			return nil
+		}
+
 		if campaign.CreatedAt.After(svc.clock.Now().Add(-1 * time.Minute)) {
 			// Give the client a minute to connect before considering the
 			// campaign orphaned
@@ -1121,11 +1141,20 @@ func (svc *Service) registerFlippedPolicies(ctx context.Context, hostID uint, ho
 		if err := svc.failingPolicySet.AddHost(policyID, host); err != nil {
 			return err
 		}
+		if err := svc.passingPolicySet.RemoveHosts(policyID, []fleet.PolicySetHost{host}); err != nil {
+			return err
+		}
 	}
 	for _, policyID := range newPassing {
 		if err := svc.failingPolicySet.RemoveHosts(policyID, []fleet.PolicySetHost{host}); err != nil {
 			return err
 		}
+		// the Jira and Zendesk tickets created for the host while it was
+		// failing the policy are resolved in batches by the failing policies
+		// automation.
+		if err := svc.passingPolicySet.AddHost(policyID, host); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -1219,5 +1248,87 @@ func (svc *Service) SubmitResultLogs(ctx context.Context, logs []json.RawMessage
 	if err := svc.osqueryLogWriter.Result.Write(ctx, logs); err != nil {
 		return osqueryError{message: "error writing result logs: " + err.Error()}
 	}
+	if err := svc.saveQueryReports(ctx, logs); err != nil {
+		level.Error(svc.logger).Log("op", "saveQueryReports", "err", err)
+	}
+	return nil
+}
+
+// saveQueryReports stores the snapshot results of the scheduled queries found
+// in the result logs when the query reports are enabled. The differential
+// results are not stored, as they do not contain the whole result.
+func (svc *Service) saveQueryReports(ctx context.Context, logs []json.RawMessage) error {
+	host, ok := hostctx.FromContext(ctx)
+	if !ok {
+		return ctxerr.New(ctx, "missing host from request context")
+	}
+
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	if !appConfig.QueryReportsSettings.Enabled {
+		return nil
+	}
+
+	var (
+		names     [][2]string
+		snapshots [][]map[string]string
+	)
+	for _, raw := range logs {
+		var result struct {
+			Name     string                   `json:"name"`
+			Action   string                   `json:"action"`
+			Snapshot []map[string]interface{} `json:"snapshot"`
+		}
+		if err := json.Unmarshal(raw, &result); err != nil || result.Action != "snapshot" {
+			continue
+		}
+
+		// The name is "pack<delimiter><pack name><delimiter><query name>",
+		// the delimiter being the pack_delimiter option of the host. As for
+		// the scheduled query stats, not much can be done if the pack name
+		// includes the delimiter.
+		const packPrefix = "pack"
+		if len(result.Name) <= len(packPrefix) || !strings.HasPrefix(result.Name, packPrefix) {
+			continue
+		}
+		delimiter := result.Name[len(packPrefix) : len(packPrefix)+1]
+		parts := strings.SplitN(result.Name[len(packPrefix)+1:], delimiter, 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		rows := make([]map[string]string, 0, len(result.Snapshot))
+		for _, snapshotRow := range result.Snapshot {
+			// the values are numbers instead of strings if the numerics option
+			// is enabled on the host.
+			row := make(map[string]string, len(snapshotRow))
+			for col, val := range snapshotRow {
+				row[col] = cast.ToString(val)
+			}
+			rows = append(rows, row)
+		}
+		names = append(names, [2]string{parts[0], parts[1]})
+		snapshots = append(snapshots, rows)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	ids, err := svc.ds.ScheduledQueryIDsByName(ctx, fleet.DefaultScheduledQueryIDsByNameBatchSize, names...)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get scheduled query IDs")
+	}
+	now := svc.clock.Now()
+	for i, id := range ids {
+		if id == 0 {
+			// not a scheduled query of fleet, e.g. a pack of the local config
+			continue
+		}
+		if err := svc.ds.SaveQueryReportRows(ctx, id, host.ID, snapshots[i], now, appConfig.QueryReportsSettings.MaxRowsPerQuery); err != nil {
+			return ctxerr.Wrap(ctx, err, "save query report rows")
+		}
+	}
 	return nil
 }
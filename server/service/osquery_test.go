@@ -231,6 +231,9 @@ func TestEnrollAgentEnforceLimit(t *testing.T) {
 
 		var hostIDSeq uint
 		ds := new(mock.Store)
+		ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+			return nil
+		}
 		ds.VerifyEnrollSecretFunc = func(ctx context.Context, secret string) (*fleet.EnrollSecret, error) {
 			switch secret {
 			case "valid_secret":
@@ -456,6 +459,9 @@ func TestSubmitStatusLogs(t *testing.T) {
 
 func TestSubmitResultLogs(t *testing.T) {
 	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	// Hack to get at the service internals and modify the writer
@@ -484,6 +490,63 @@ func TestSubmitResultLogs(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, results, testLogger.logs)
+	assert.False(t, ds.ScheduledQueryIDsByNameFuncInvoked)
+}
+
+func TestSubmitResultLogsQueryReports(t *testing.T) {
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{QueryReportsSettings: fleet.QueryReportsSettings{Enabled: true, MaxRowsPerQuery: 10}}, nil
+	}
+	ds.ScheduledQueryIDsByNameFunc = func(ctx context.Context, batchSize int, packAndSchedQueryNames ...[2]string) ([]uint, error) {
+		ids := make([]uint, len(packAndSchedQueryNames))
+		for i, names := range packAndSchedQueryNames {
+			if names == [2]string{"Global", "time"} {
+				ids[i] = 42
+			}
+		}
+		return ids, nil
+	}
+	type savedRows struct {
+		scheduledQueryID, hostID uint
+		rows                     []map[string]string
+		maxRows                  int
+	}
+	var saved []savedRows
+	ds.SaveQueryReportRowsFunc = func(ctx context.Context, scheduledQueryID, hostID uint, rows []map[string]string, fetchedAt time.Time, maxRowsPerQuery int) error {
+		saved = append(saved, savedRows{scheduledQueryID, hostID, rows, maxRowsPerQuery})
+		return nil
+	}
+	svc := newTestService(t, ds, nil, nil)
+
+	serv := ((svc.(validationMiddleware)).Service).(*Service)
+	serv.osqueryLogWriter = &logging.OsqueryLogger{Result: &testJSONLogger{}}
+
+	logs := []string{
+		// a snapshot of a scheduled query, with numeric values
+		`{"snapshot":[{"hour":20,"minutes":"8"},{"hour":"21","minutes":"9"}],"action":"snapshot","name":"pack/Global/time","hostIdentifier":"1379f59d98f4","unixTime":1484078931}`,
+		// a snapshot of a pack that is not managed by fleet
+		`{"snapshot":[{"hour":"20"}],"action":"snapshot","name":"pack/local/time","hostIdentifier":"1379f59d98f4","unixTime":1484078931}`,
+		// a snapshot of a query that is not in a pack
+		`{"snapshot":[{"hour":"20"}],"action":"snapshot","name":"time","hostIdentifier":"1379f59d98f4","unixTime":1484078931}`,
+		// differential results are not stored
+		`{"diffResults":{"removed":[],"added":[{"hour":"20"}]},"name":"pack/Global/time","hostIdentifier":"1379f59d98f4","unixTime":1484078931}`,
+		`{"columns":{"hour":"20"},"action":"added","name":"pack/Global/time","hostIdentifier":"1379f59d98f4","unixTime":1484078931}`,
+	}
+	var results []json.RawMessage
+	err := json.Unmarshal([]byte(fmt.Sprintf("[%s]", strings.Join(logs, ","))), &results)
+	require.NoError(t, err)
+
+	ctx := hostctx.NewContext(context.Background(), &fleet.Host{ID: 7})
+	err = serv.SubmitResultLogs(ctx, results)
+	require.NoError(t, err)
+
+	require.Equal(t, []savedRows{{
+		scheduledQueryID: 42,
+		hostID:           7,
+		rows:             []map[string]string{{"hour": "20", "minutes": "8"}, {"hour": "21", "minutes": "9"}},
+		maxRows:          10,
+	}}, saved)
 }
 
 func verifyDiscovery(t *testing.T, queries, discovery map[string]string) {
@@ -1317,7 +1380,7 @@ func TestNewDistributedQueryCampaign(t *testing.T) {
 	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
 		return nil
 	}
-	campaign, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}})
+	campaign, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}}, fleet.CampaignOptions{})
 	require.NoError(t, err)
 	assert.Equal(t, gotQuery.ID, gotCampaign.QueryID)
 	assert.True(t, ds.NewActivityFuncInvoked)
@@ -1336,6 +1399,97 @@ func TestNewDistributedQueryCampaign(t *testing.T) {
 	)
 }
 
+func TestNewDistributedQueryCampaignAsync(t *testing.T) {
+	ds := new(mock.Store)
+	rs := &mockresult.QueryResultStore{
+		HealthCheckFunc: func() error {
+			return nil
+		},
+	}
+	lq := live_query_mock.New(t)
+	mockClock := clock.NewMockClock()
+	svc := newTestServiceWithClock(t, ds, rs, lq, mockClock)
+
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewQueryFunc = func(ctx context.Context, query *fleet.Query, opts ...fleet.OptionalArg) (*fleet.Query, error) {
+		query.ID = 42
+		return query, nil
+	}
+	var gotCampaign *fleet.DistributedQueryCampaign
+	ds.NewDistributedQueryCampaignFunc = func(ctx context.Context, camp *fleet.DistributedQueryCampaign) (*fleet.DistributedQueryCampaign, error) {
+		gotCampaign = camp
+		camp.ID = 21
+		return camp, nil
+	}
+	ds.NewDistributedQueryCampaignTargetFunc = func(ctx context.Context, target *fleet.DistributedQueryCampaignTarget) (*fleet.DistributedQueryCampaignTarget, error) {
+		return target, nil
+	}
+	ds.HostIDsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets) ([]uint, error) {
+		return []uint{1, 3, 5}, nil
+	}
+	var gotHostIDs []uint
+	ds.NewDistributedQueryCampaignHostsFunc = func(ctx context.Context, campaignID uint, hostIDs []uint) error {
+		require.Equal(t, uint(21), campaignID)
+		gotHostIDs = hostIDs
+		return nil
+	}
+	ds.CountHostsInTargetsFunc = func(ctx context.Context, filter fleet.TeamFilter, targets fleet.HostTargets, now time.Time) (fleet.TargetMetrics, error) {
+		return fleet.TargetMetrics{TotalHosts: 3}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	ds.SaveDistributedQueryCampaignFunc = func(ctx context.Context, camp *fleet.DistributedQueryCampaign) error {
+		return nil
+	}
+
+	viewerCtx := viewer.NewContext(context.Background(), viewer.Viewer{
+		User: &fleet.User{ID: 1, GlobalRole: ptr.String(fleet.RoleAdmin)},
+	})
+	targets := fleet.HostTargets{HostIDs: []uint{1, 3, 5}}
+
+	for _, window := range []time.Duration{-time.Hour, fleet.MaxCampaignCollectWindow + time.Second} {
+		_, err := svc.NewDistributedQueryCampaign(viewerCtx, "select 1", nil, targets, fleet.CampaignOptions{CollectWindow: window})
+		var iae *fleet.InvalidArgumentError
+		require.ErrorAs(t, err, &iae)
+		require.Contains(t, err.Error(), "collect_window")
+	}
+	// the export format must be valid, and the export storage configured
+	for _, format := range []fleet.LiveQueryExportFormat{"xml", fleet.LiveQueryExportCSV} {
+		_, err := svc.NewDistributedQueryCampaign(viewerCtx, "select 1", nil, targets, fleet.CampaignOptions{ExportFormat: format})
+		var iae *fleet.InvalidArgumentError
+		require.ErrorAs(t, err, &iae)
+		require.Contains(t, err.Error(), "export_format")
+	}
+	require.False(t, ds.NewDistributedQueryCampaignFuncInvoked)
+
+	lq.On("RunQueryWithExpiration", "21", "select 1", []uint{1, 3, 5}, 48*time.Hour).Return(nil)
+	campaign, err := svc.NewDistributedQueryCampaign(viewerCtx, "select 1", nil, targets, fleet.CampaignOptions{CollectWindow: 48 * time.Hour})
+	require.NoError(t, err)
+	lq.AssertExpectations(t)
+	require.Same(t, gotCampaign, campaign)
+	// the asynchronous campaign does not wait for a listener
+	require.Equal(t, fleet.QueryRunning, campaign.Status)
+	require.NotNil(t, campaign.CollectUntil)
+	require.Equal(t, mockClock.Now().Add(48*time.Hour).UTC().Truncate(time.Second), *campaign.CollectUntil)
+	require.Equal(t, []uint{1, 3, 5}, gotHostIDs)
+
+	// it is not completed when its results are no longer streamed
+	require.NoError(t, svc.CompleteCampaign(viewerCtx, campaign))
+	require.False(t, ds.SaveDistributedQueryCampaignFuncInvoked)
+	require.Equal(t, fleet.QueryRunning, campaign.Status)
+
+	// only at the end of the collection window
+	lq.On("StopQuery", "21").Return(nil)
+	mockClock.AddTime(48 * time.Hour)
+	require.NoError(t, svc.CompleteCampaign(viewerCtx, campaign))
+	require.True(t, ds.SaveDistributedQueryCampaignFuncInvoked)
+	require.Equal(t, fleet.QueryComplete, campaign.Status)
+	lq.AssertExpectations(t)
+}
+
 func TestDistributedQueryResults(t *testing.T) {
 	mockClock := clock.NewMockClock()
 	ds := new(mock.Store)
@@ -1370,6 +1524,11 @@ func TestDistributedQueryResults(t *testing.T) {
 	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
 		return &fleet.AppConfig{Features: fleet.Features{EnableHostUsers: true}}, nil
 	}
+	var stored *fleet.DistributedQueryCampaignResult
+	ds.NewDistributedQueryCampaignResultFunc = func(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error {
+		stored = result
+		return nil
+	}
 
 	hostCtx := hostctx.NewContext(context.Background(), host)
 
@@ -1448,6 +1607,26 @@ func TestDistributedQueryResults(t *testing.T) {
 
 	err = svc.SubmitDistributedQueryResults(hostCtx, results, map[string]fleet.OsqueryStatus{}, map[string]string{})
 	require.NoError(t, err)
+
+	// the result is also stored
+	require.True(t, ds.NewDistributedQueryCampaignResultFuncInvoked)
+	require.NotNil(t, stored)
+	assert.Equal(t, campaign.ID, stored.DistributedQueryCampaignID)
+	assert.Equal(t, host.ID, stored.HostID)
+	assert.Equal(t, expectedRows, stored.Rows)
+	assert.Equal(t, 1, stored.RowCount)
+	assert.False(t, stored.Truncated)
+}
+
+// mockCampaignResultsSaved sets the mock datastore functions used to store the
+// campaign results when ingesting distributed queries.
+func mockCampaignResultsSaved(ds *mock.Store) {
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewDistributedQueryCampaignResultFunc = func(ctx context.Context, result *fleet.DistributedQueryCampaignResult, maxCampaignRows int) error {
+		return nil
+	}
 }
 
 func TestIngestDistributedQueryParseIdError(t *testing.T) {
@@ -1481,6 +1660,7 @@ func TestIngestDistributedQueryOrphanedCampaignLoadError(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
 		return nil, errors.New("missing campaign")
@@ -1507,6 +1687,7 @@ func TestIngestDistributedQueryOrphanedCampaignWaitListener(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{
 		ID: 42,
@@ -1540,6 +1721,7 @@ func TestIngestDistributedQueryOrphanedCloseError(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{
 		ID: 42,
@@ -1576,6 +1758,7 @@ func TestIngestDistributedQueryOrphanedStopError(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{
 		ID: 42,
@@ -1613,6 +1796,7 @@ func TestIngestDistributedQueryOrphanedStop(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{
 		ID: 42,
@@ -1639,6 +1823,59 @@ func TestIngestDistributedQueryOrphanedStop(t *testing.T) {
 	lq.AssertExpectations(t)
 }
 
+func TestIngestDistributedQueryAsyncCampaign(t *testing.T) {
+	mockClock := clock.NewMockClock()
+	ds := new(mock.Store)
+	rs := pubsub.NewInmemQueryResults()
+	lq := live_query_mock.New(t)
+	svc := &Service{
+		ds:             ds,
+		resultStore:    rs,
+		liveQueryStore: lq,
+		logger:         log.NewNopLogger(),
+		clock:          mockClock,
+	}
+	mockCampaignResultsSaved(ds)
+
+	// the asynchronous campaign was created long ago and has no listener, but
+	// it is still collecting results
+	campaign := &fleet.DistributedQueryCampaign{
+		ID: 42,
+		UpdateCreateTimestamps: fleet.UpdateCreateTimestamps{
+			CreateTimestamp: fleet.CreateTimestamp{
+				CreatedAt: mockClock.Now().Add(-2 * time.Hour),
+			},
+		},
+		Status:       fleet.QueryRunning,
+		CollectUntil: ptr.Time(mockClock.Now().Add(time.Hour)),
+	}
+
+	ds.DistributedQueryCampaignFunc = func(ctx context.Context, id uint) (*fleet.DistributedQueryCampaign, error) {
+		return campaign, nil
+	}
+	lq.On("QueryCompletedByHost", strconv.Itoa(int(campaign.ID)), uint(1)).Return(nil)
+
+	host := fleet.Host{ID: 1}
+
+	err := svc.ingestDistributedQuery(context.Background(), host, "fleet_distributed_query_42", []map[string]string{}, false, "")
+	require.NoError(t, err)
+	assert.False(t, ds.SaveDistributedQueryCampaignFuncInvoked)
+	lq.AssertExpectations(t)
+
+	// once the collection window is over, the campaign is orphaned
+	ds.SaveDistributedQueryCampaignFunc = func(ctx context.Context, campaign *fleet.DistributedQueryCampaign) error {
+		return nil
+	}
+	lq.On("StopQuery", strconv.Itoa(int(campaign.ID))).Return(nil)
+	mockClock.AddTime(2 * time.Hour)
+
+	err = svc.ingestDistributedQuery(context.Background(), host, "fleet_distributed_query_42", []map[string]string{}, false, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "campaign stopped")
+	assert.Equal(t, fleet.QueryComplete, campaign.Status)
+	lq.AssertExpectations(t)
+}
+
 func TestIngestDistributedQueryRecordCompletionError(t *testing.T) {
 	mockClock := clock.NewMockClock()
 	ds := new(mock.Store)
@@ -1651,6 +1888,7 @@ func TestIngestDistributedQueryRecordCompletionError(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{ID: 42}
 	host := fleet.Host{ID: 1}
@@ -1682,6 +1920,7 @@ func TestIngestDistributedQuery(t *testing.T) {
 		logger:         log.NewNopLogger(),
 		clock:          mockClock,
 	}
+	mockCampaignResultsSaved(ds)
 
 	campaign := &fleet.DistributedQueryCampaign{ID: 42}
 	host := fleet.Host{ID: 1}
@@ -2084,10 +2323,10 @@ func TestObserversCanOnlyRunDistributedCampaigns(t *testing.T) {
 	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
 		return nil
 	}
-	_, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}})
+	_, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}}, fleet.CampaignOptions{})
 	require.Error(t, err)
 
-	_, err = svc.NewDistributedQueryCampaign(viewerCtx, "", ptr.Uint(42), fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}})
+	_, err = svc.NewDistributedQueryCampaign(viewerCtx, "", ptr.Uint(42), fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}}, fleet.CampaignOptions{})
 	require.Error(t, err)
 
 	ds.QueryFunc = func(ctx context.Context, id uint) (*fleet.Query, error) {
@@ -2119,7 +2358,7 @@ func TestObserversCanOnlyRunDistributedCampaigns(t *testing.T) {
 		return nil
 	}
 	lq.On("RunQuery", "21", "select 1;", []uint{1, 3, 5}).Return(nil)
-	_, err = svc.NewDistributedQueryCampaign(viewerCtx, "", ptr.Uint(42), fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}})
+	_, err = svc.NewDistributedQueryCampaign(viewerCtx, "", ptr.Uint(42), fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}}, fleet.CampaignOptions{})
 	require.NoError(t, err)
 }
 
@@ -2177,7 +2416,7 @@ func TestTeamMaintainerCanRunNewDistributedCampaigns(t *testing.T) {
 		return nil
 	}
 	lq.On("RunQuery", "0", "select year, month, day, hour, minutes, seconds from time", []uint{1, 3, 5}).Return(nil)
-	_, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}, TeamIDs: []uint{123}})
+	_, err := svc.NewDistributedQueryCampaign(viewerCtx, q, nil, fleet.HostTargets{HostIDs: []uint{2}, LabelIDs: []uint{1}, TeamIDs: []uint{123}}, fleet.CampaignOptions{})
 	require.NoError(t, err)
 }
 
@@ -2639,6 +2878,76 @@ func TestPolicyWebhooks(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestPolicyIntegrationsResolveTickets(t *testing.T) {
+	ds := new(mock.Store)
+	lq := live_query_mock.New(t)
+	pool := redistest.SetupRedis(t, t.Name(), false, false, false)
+	failingPolicySet := redis_policy_set.NewFailingTest(t, pool)
+	passingPolicySet := redis_policy_set.NewPassingTest(t, pool)
+	svc := newTestServiceWithConfig(t, ds, config.TestConfig(), nil, lq, &TestServerOpts{
+		FailingPolicySet: failingPolicySet,
+		PassingPolicySet: passingPolicySet,
+	})
+
+	host := &fleet.Host{
+		ID:       5,
+		Platform: "darwin",
+		Hostname: "test.hostname",
+	}
+
+	// the failing policies webhook is disabled, but the Jira integration is
+	// enabled for failing policies.
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{
+			WebhookSettings: fleet.WebhookSettings{
+				FailingPoliciesWebhook: fleet.FailingPoliciesWebhookSettings{
+					PolicyIDs: []uint{1, 2},
+				},
+			},
+			Integrations: fleet.Integrations{
+				Jira: []*fleet.JiraIntegration{{EnableFailingPolicies: true}},
+			},
+		}, nil
+	}
+	ds.RecordPolicyQueryExecutionsFunc = func(ctx context.Context, gotHost *fleet.Host, results map[uint]*bool, updated time.Time, deferred bool) error {
+		return nil
+	}
+	ds.FlippingPoliciesForHostFunc = func(ctx context.Context, hostID uint, incomingResults map[uint]*bool) (newFailing []uint, newPassing []uint, err error) {
+		// only the policies of the automation are considered
+		require.Len(t, incomingResults, 2)
+		return []uint{2}, []uint{1}, nil
+	}
+
+	// the host was passing policy 2 before it failed again
+	require.NoError(t, passingPolicySet.AddHost(2, fleet.PolicySetHost{ID: host.ID, Hostname: host.Hostname}))
+
+	ctx := hostctx.NewContext(context.Background(), host)
+	err := svc.SubmitDistributedQueryResults(
+		ctx,
+		map[string][]map[string]string{
+			hostPolicyQueryPrefix + "1": {{"col1": "val1"}}, // now passes
+			hostPolicyQueryPrefix + "2": {},                 // fails
+		},
+		map[string]fleet.OsqueryStatus{},
+		map[string]string{},
+	)
+	require.NoError(t, err)
+	require.True(t, ds.FlippingPoliciesForHostFuncInvoked)
+
+	// the host is added to the passing policies set, its tickets are resolved
+	// in batches by the failing policies automation.
+	assert.Eventually(t, func() bool {
+		hosts, err := passingPolicySet.ListHosts(1)
+		require.NoError(t, err)
+		return len(hosts) == 1 && hosts[0].ID == host.ID
+	}, 10*time.Second, 100*time.Millisecond)
+	hosts, err := passingPolicySet.ListHosts(2)
+	require.NoError(t, err)
+	require.Empty(t, hosts)
+	require.False(t, ds.ListOpenPolicyAutomationTicketsFuncInvoked)
+	require.False(t, ds.NewJobFuncInvoked)
+}
+
 // If the live query store (Redis) is down we still (see #3503)
 // want hosts to get queries and continue to check in.
 func TestLiveQueriesFailing(t *testing.T) {
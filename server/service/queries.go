@@ -46,6 +46,57 @@ func (svc *Service) GetQuery(ctx context.Context, id uint) (*fleet.Query, error)
 	return svc.ds.Query(ctx, id)
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// Get Query Report
+////////////////////////////////////////////////////////////////////////////////
+
+type getQueryReportRequest struct {
+	ID          uint              `url:"id"`
+	ListOptions fleet.ListOptions `url:"list_options"`
+	TeamID      *uint             `query:"team_id,optional"`
+	HostID      *uint             `query:"host_id,optional"`
+}
+
+type getQueryReportResponse struct {
+	QueryID uint                    `json:"query_id"`
+	Results []*fleet.QueryReportRow `json:"results"`
+	Err     error                   `json:"error,omitempty"`
+}
+
+func (r getQueryReportResponse) error() error { return r.Err }
+
+func getQueryReportEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*getQueryReportRequest)
+	rows, err := svc.GetQueryReport(ctx, req.ID, fleet.QueryReportOptions{
+		ListOptions: req.ListOptions,
+		TeamID:      req.TeamID,
+		HostID:      req.HostID,
+	})
+	if err != nil {
+		return getQueryReportResponse{Err: err}, nil
+	}
+	return getQueryReportResponse{QueryID: req.ID, Results: rows}, nil
+}
+
+func (svc *Service) GetQueryReport(ctx context.Context, id uint, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.Query{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, fleet.ErrNoContext
+	}
+
+	if _, err := svc.ds.Query(ctx, id); err != nil {
+		return nil, err
+	}
+
+	// the rows of the hosts the user cannot see are filtered out
+	filter := fleet.TeamFilter{User: vc.User, IncludeObserver: true}
+	return svc.ds.ListQueryReportRows(ctx, id, filter, opt)
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // List Queries
 ////////////////////////////////////////////////////////////////////////////////
@@ -222,3 +222,34 @@ func TestQueryAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestGetQueryReport(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	ds.QueryFunc = func(ctx context.Context, id uint) (*fleet.Query, error) {
+		if id != 1 {
+			return nil, notFoundError{}
+		}
+		return &fleet.Query{ID: id}, nil
+	}
+	var calledWithFilter fleet.TeamFilter
+	var calledWithOpts fleet.QueryReportOptions
+	ds.ListQueryReportRowsFunc = func(ctx context.Context, queryID uint, filter fleet.TeamFilter, opt fleet.QueryReportOptions) ([]*fleet.QueryReportRow, error) {
+		calledWithFilter, calledWithOpts = filter, opt
+		return []*fleet.QueryReportRow{{ScheduledQueryID: 2, HostID: 3, Columns: map[string]string{"a": "b"}}}, nil
+	}
+
+	user := &fleet.User{Teams: []fleet.UserTeam{{Team: fleet.Team{ID: 1}, Role: fleet.RoleObserver}}}
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: user})
+	opts := fleet.QueryReportOptions{TeamID: ptr.Uint(1), ListOptions: fleet.ListOptions{PerPage: 10}}
+	rows, err := svc.GetQueryReport(ctx, 1, opts)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	require.Equal(t, fleet.TeamFilter{User: user, IncludeObserver: true}, calledWithFilter)
+	require.Equal(t, opts, calledWithOpts)
+
+	_, err = svc.GetQueryReport(ctx, 2, opts)
+	require.Error(t, err)
+	require.True(t, fleet.IsNotFound(err))
+}
@@ -13,6 +13,8 @@ import (
 
 type redisFailingPolicySet struct {
 	pool       fleet.RedisPool
+	keyPrefix  string // the key prefix of the policy sets
+	setsKey    string // the key of the set of policy sets
 	testPrefix string // for tests, the key prefix to use to avoid conflicts
 }
 
@@ -21,7 +23,19 @@ var _ fleet.FailingPolicySet = (*redisFailingPolicySet)(nil)
 // NewFailing creates a redis policy set for failing policies.
 func NewFailing(pool fleet.RedisPool) *redisFailingPolicySet {
 	return &redisFailingPolicySet{
-		pool: pool,
+		pool:      pool,
+		keyPrefix: policySetKeyPrefix,
+		setsKey:   policySetsSetKey,
+	}
+}
+
+// NewPassing creates a redis policy set for the hosts that pass again the
+// policies they were failing.
+func NewPassing(pool fleet.RedisPool) *redisFailingPolicySet {
+	return &redisFailingPolicySet{
+		pool:      pool,
+		keyPrefix: passingPolicySetKeyPrefix,
+		setsKey:   passingPolicySetsSetKey,
 	}
 }
 
@@ -32,16 +46,26 @@ type TestNamer interface {
 // NewFailingTest creates a redis policy set for failing policies to be used
 // only in tests.
 func NewFailingTest(t TestNamer, pool fleet.RedisPool) *redisFailingPolicySet {
-	return &redisFailingPolicySet{
-		pool:       pool,
-		testPrefix: t.Name() + ":",
-	}
+	r := NewFailing(pool)
+	r.testPrefix = t.Name() + ":"
+	return r
+}
+
+// NewPassingTest creates a redis policy set for passing policies to be used
+// only in tests.
+func NewPassingTest(t TestNamer, pool fleet.RedisPool) *redisFailingPolicySet {
+	r := NewPassing(pool)
+	r.testPrefix = t.Name() + ":"
+	return r
 }
 
 const (
 	policySetKeyPrefix = "policies:failing:"
 	// policySetsSetKey is used to avoid a SCAN command when listing policy sets.
 	policySetsSetKey = "policies:failing_sets"
+
+	passingPolicySetKeyPrefix = "policies:passing:"
+	passingPolicySetsSetKey   = "policies:passing_sets"
 )
 
 // ListSets lists all the policy sets.
@@ -186,7 +210,7 @@ func (r *redisFailingPolicySet) addPolicyToSetOfSets(policyID uint) error {
 	defer conn.Close()
 
 	if _, err := conn.Do("SADD", r.policySetOfSetsKey(), policyID); err != nil {
-		return fmt.Errorf("add policy id to set of failing sets: %w", err)
+		return fmt.Errorf("add policy id to set of sets: %w", err)
 	}
 	return nil
 }
@@ -196,17 +220,17 @@ func (r *redisFailingPolicySet) removePolicyFromSetOfSets(policyID uint) error {
 	defer conn.Close()
 
 	if _, err := conn.Do("SREM", r.policySetOfSetsKey(), policyID); err != nil {
-		return fmt.Errorf("remove policy id from set of failing sets: %w", err)
+		return fmt.Errorf("remove policy id from set of sets: %w", err)
 	}
 	return nil
 }
 
 func (r *redisFailingPolicySet) policySetKey(policyID uint) string {
-	return r.testPrefix + policySetKeyPrefix + strconv.Itoa(int(policyID))
+	return r.testPrefix + r.keyPrefix + strconv.Itoa(int(policyID))
 }
 
 func (r *redisFailingPolicySet) policySetOfSetsKey() string {
-	return r.testPrefix + policySetsSetKey
+	return r.testPrefix + r.setsKey
 }
 
 func hostEntry(host fleet.PolicySetHost) string {
@@ -11,6 +11,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/fleet/policytest"
 	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRedisFailingPolicySet(t *testing.T) {
@@ -37,6 +38,28 @@ func TestRedisFailingPolicySet(t *testing.T) {
 	}
 }
 
+func TestRedisPassingPolicySet(t *testing.T) {
+	pool := redistest.SetupRedis(t, t.Name(), false, false, true)
+	failing := NewFailingTest(t, pool)
+	passing := NewPassingTest(t, pool)
+
+	// the passing and failing sets are independent
+	host := fleet.PolicySetHost{ID: 1, Hostname: "host1"}
+	require.NoError(t, passing.AddHost(1, host))
+	sets, err := failing.ListSets()
+	require.NoError(t, err)
+	require.Empty(t, sets)
+	hosts, err := failing.ListHosts(1)
+	require.NoError(t, err)
+	require.Empty(t, hosts)
+	hosts, err = passing.ListHosts(1)
+	require.NoError(t, err)
+	require.Equal(t, []fleet.PolicySetHost{host}, hosts)
+	require.NoError(t, passing.RemoveSet(1))
+
+	policytest.RunFailingBasic(t, passing)
+}
+
 func setupRedis(t testing.TB, cluster, redir bool) *redisFailingPolicySet {
 	pool := redistest.SetupRedis(t, t.Name(), cluster, redir, true)
 	return NewFailingTest(t, pool)
@@ -0,0 +1,1006 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/contexts/logging"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/go-sql-driver/mysql"
+)
+
+// SCIM 2.0 provisioning, see RFC 7643 (schemas) and RFC 7644 (protocol).
+//
+// SCIM users are Fleet users that log in via SSO, identified by their email
+// (the SCIM userName). SCIM groups are Fleet teams: the members of a group
+// are the users with a role in the team.
+
+const (
+	scimContentType = "application/scim+json"
+
+	scimUserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimGroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      time.Time  `json:"created"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	UserName    string       `json:"userName"`
+	Name        scimName     `json:"name"`
+	DisplayName string       `json:"displayName"`
+	Emails      []scimEmail  `json:"emails"`
+	Active      bool         `json:"active"`
+	Groups      []scimMember `json:"groups"`
+	Meta        scimMeta     `json:"meta"`
+}
+
+func newSCIMUser(user *fleet.User) scimUser {
+	groups := make([]scimMember, 0, len(user.Teams))
+	for _, t := range user.Teams {
+		groups = append(groups, scimMember{Value: strconv.FormatUint(uint64(t.ID), 10), Display: t.Name})
+	}
+	return scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          strconv.FormatUint(uint64(user.ID), 10),
+		UserName:    user.Email,
+		Name:        scimName{Formatted: user.Name},
+		DisplayName: user.Name,
+		Emails:      []scimEmail{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      !user.Disabled,
+		Groups:      groups,
+		Meta: scimMeta{
+			ResourceType: "User",
+			Created:      user.CreatedAt,
+			LastModified: &user.UpdatedAt,
+		},
+	}
+}
+
+type scimGroup struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members,omitempty"`
+	Meta        scimMeta     `json:"meta"`
+}
+
+func newSCIMGroup(team *fleet.Team, members []*fleet.User) scimGroup {
+	group := scimGroup{
+		Schemas:     []string{scimGroupSchema},
+		ID:          strconv.FormatUint(uint64(team.ID), 10),
+		DisplayName: team.Name,
+		Meta: scimMeta{
+			ResourceType: "Group",
+			Created:      team.CreatedAt,
+		},
+	}
+	for _, user := range members {
+		group.Members = append(group.Members, scimMember{Value: strconv.FormatUint(uint64(user.ID), 10), Display: user.Email})
+	}
+	return group
+}
+
+type scimListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// newSCIMListResponse returns the page of resources starting at the 1-based
+// startIndex with at most count resources (all the resources if count is
+// nil).
+func newSCIMListResponse(resources []interface{}, startIndex int, count *int) scimListResponse {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	start := startIndex - 1
+	if start > len(resources) {
+		start = len(resources)
+	}
+	end := len(resources)
+	if count != nil && *count >= 0 && start+*count < end {
+		end = start + *count
+	}
+	return scimListResponse{
+		Schemas:      []string{scimListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: end - start,
+		Resources:    resources[start:end],
+	}
+}
+
+// scimError is an error of the SCIM protocol, it is rendered with its status
+// and SCIM error type.
+type scimError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e scimError) Error() string {
+	return e.detail
+}
+
+type scimErrorBody struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// newSCIMErrorBody returns the HTTP status and SCIM representation of err,
+// mapping the Fleet errors to the same HTTP status as encodeError.
+func newSCIMErrorBody(err error) (int, scimErrorBody) {
+	status, scimType := http.StatusInternalServerError, ""
+	cause := ctxerr.Cause(err)
+	switch e := cause.(type) {
+	case scimError:
+		status, scimType = e.status, e.scimType
+	case validationErrorInterface:
+		status, scimType = http.StatusBadRequest, "invalidValue"
+		if statusErr, ok := e.(statuser); ok && statusErr.Status() == http.StatusConflict {
+			status, scimType = http.StatusConflict, "uniqueness"
+		}
+	case permissionErrorInterface:
+		status = http.StatusForbidden
+	case notFoundErrorInterface:
+		status = http.StatusNotFound
+	case existsErrorInterface:
+		status, scimType = http.StatusConflict, "uniqueness"
+	case badRequestErrorInterface:
+		status = http.StatusBadRequest
+	case *mysql.MySQLError:
+		status, scimType = http.StatusBadRequest, "invalidValue"
+		if e.Number == 1062 {
+			status, scimType = http.StatusConflict, "uniqueness"
+		}
+	case kithttp.StatusCoder:
+		status = e.StatusCode()
+	}
+	return status, scimErrorBody{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   cause.Error(),
+	}
+}
+
+// scimResponse renders the SCIM resource (or error) in the SCIM format. It
+// doesn't implement the errorer interface as SCIM errors have their own
+// format.
+type scimResponse struct {
+	status   int
+	resource interface{}
+	Err      error
+}
+
+func (r scimResponse) hijackRender(ctx context.Context, w http.ResponseWriter) {
+	status, body := r.status, r.resource
+	if r.Err != nil {
+		ctxerr.Handle(ctx, r.Err)
+		logging.WithErr(ctx, r.Err)
+
+		status, body = newSCIMErrorBody(r.Err)
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(body); err != nil {
+		logging.WithErr(ctx, err)
+	}
+}
+
+var scimFilterRegexp = regexp.MustCompile(`^\s*(\w+)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// parseSCIMFilter returns the value of a filter of the form `attr eq "value"`,
+// which is the only filter supported (it is the one used by the identity
+// providers to look up existing resources). It returns an empty string if
+// the filter is empty.
+func parseSCIMFilter(filter, attr string) (string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", nil
+	}
+	m := scimFilterRegexp.FindStringSubmatch(filter)
+	if m == nil || !strings.EqualFold(m[1], attr) {
+		return "", scimError{
+			status:   http.StatusBadRequest,
+			scimType: "invalidFilter",
+			detail:   fmt.Sprintf("unsupported filter, only `%s eq \"value\"` is supported", attr),
+		}
+	}
+	var value string
+	if err := json.Unmarshal([]byte(`"`+m[2]+`"`), &value); err != nil {
+		return "", scimError{status: http.StatusBadRequest, scimType: "invalidFilter", detail: "invalid filter value"}
+	}
+	return value, nil
+}
+
+func parseSCIMID(id string) (uint, error) {
+	v, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: fmt.Sprintf("invalid id: %q", id)}
+	}
+	return uint(v), nil
+}
+
+type scimPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+func scimStringValue(path string, raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: fmt.Sprintf("%s must be a string", path)}
+	}
+	return s, nil
+}
+
+// scimBoolValue parses a boolean value, which some identity providers send
+// as a string.
+func scimBoolValue(path string, raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+	return false, scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: fmt.Sprintf("%s must be a boolean", path)}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// SCIM Users
+////////////////////////////////////////////////////////////////////////////////
+
+// scimUserAttributes are the attributes of a SCIM user that are stored by
+// Fleet, the others are ignored.
+type scimUserAttributes struct {
+	UserName    string      `json:"userName"`
+	Name        *scimName   `json:"name"`
+	DisplayName string      `json:"displayName"`
+	Emails      []scimEmail `json:"emails"`
+	Active      *bool       `json:"active"`
+}
+
+func (a scimUserAttributes) payload() fleet.SCIMUserPayload {
+	var p fleet.SCIMUserPayload
+
+	email := a.UserName
+	for i, e := range a.Emails {
+		if e.Primary || (i == 0 && email == "") {
+			email = e.Value
+		}
+	}
+	if email != "" {
+		p.Email = &email
+	}
+
+	var name string
+	switch {
+	case a.Name != nil && a.Name.Formatted != "":
+		name = a.Name.Formatted
+	case a.Name != nil && (a.Name.GivenName != "" || a.Name.FamilyName != ""):
+		name = strings.TrimSpace(a.Name.GivenName + " " + a.Name.FamilyName)
+	default:
+		name = a.DisplayName
+	}
+	if name != "" {
+		p.Name = &name
+	}
+
+	p.Active = a.Active
+	return p
+}
+
+type listSCIMUsersRequest struct {
+	Filter     string `query:"filter,optional"`
+	StartIndex int    `query:"startIndex,optional"`
+	Count      *int   `query:"count,optional"`
+}
+
+func listSCIMUsersEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listSCIMUsersRequest)
+	email, err := parseSCIMFilter(req.Filter, "userName")
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+
+	users, err := svc.ListUsers(ctx, fleet.UserListOptions{
+		ListOptions: fleet.ListOptions{OrderKey: "id", MatchQuery: email},
+	})
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+
+	resources := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		// the match query is a substring search, filter the exact matches
+		if email != "" && !strings.EqualFold(user.Email, email) {
+			continue
+		}
+		// only the SSO users are managed by SCIM
+		if !user.SSOEnabled {
+			continue
+		}
+		resources = append(resources, newSCIMUser(user))
+	}
+	return scimResponse{resource: newSCIMListResponse(resources, req.StartIndex, req.Count)}, nil
+}
+
+type getSCIMUserRequest struct {
+	ID string `url:"id"`
+}
+
+func getSCIMUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*getSCIMUserRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	user, err := svc.SCIMUser(ctx, id)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{resource: newSCIMUser(user)}, nil
+}
+
+type createSCIMUserRequest struct {
+	scimUserAttributes
+}
+
+func createSCIMUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*createSCIMUserRequest)
+	user, err := svc.SCIMNewUser(ctx, req.payload())
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{status: http.StatusCreated, resource: newSCIMUser(user)}, nil
+}
+
+type replaceSCIMUserRequest struct {
+	ID string `url:"id"`
+	scimUserAttributes
+}
+
+func replaceSCIMUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*replaceSCIMUserRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	user, err := svc.SCIMModifyUser(ctx, id, req.payload())
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{resource: newSCIMUser(user)}, nil
+}
+
+type patchSCIMUserRequest struct {
+	ID         string               `url:"id"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+// payload returns the changes of the patch operations. Only the add and
+// replace operations of the attributes stored by Fleet are supported.
+func (r patchSCIMUserRequest) payload() (fleet.SCIMUserPayload, error) {
+	var p fleet.SCIMUserPayload
+
+	var apply func(path string, value json.RawMessage) error
+	apply = func(path string, value json.RawMessage) error {
+		lpath := strings.ToLower(path)
+		switch {
+		case lpath == "":
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(value, &attrs); err != nil {
+				return scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "value must be an object when path is not set"}
+			}
+			// sort the attributes so that the result doesn't depend on the
+			// order of the map iteration
+			paths := make([]string, 0, len(attrs))
+			for path := range attrs {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			for _, path := range paths {
+				if err := apply(path, attrs[path]); err != nil {
+					return err
+				}
+			}
+
+		case lpath == "active":
+			active, err := scimBoolValue(path, value)
+			if err != nil {
+				return err
+			}
+			p.Active = &active
+
+		case lpath == "username":
+			email, err := scimStringValue(path, value)
+			if err != nil {
+				return err
+			}
+			p.Email = &email
+
+		case lpath == "emails":
+			var emails []scimEmail
+			if err := json.Unmarshal(value, &emails); err != nil {
+				return scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "emails must be an array"}
+			}
+			if email := (scimUserAttributes{Emails: emails}).payload().Email; email != nil {
+				p.Email = email
+			}
+
+		case strings.HasPrefix(lpath, "emails[") && strings.HasSuffix(lpath, "].value"):
+			email, err := scimStringValue(path, value)
+			if err != nil {
+				return err
+			}
+			p.Email = &email
+
+		case lpath == "name":
+			var name scimName
+			if err := json.Unmarshal(value, &name); err != nil {
+				return scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "name must be an object"}
+			}
+			if n := (scimUserAttributes{Name: &name}).payload().Name; n != nil {
+				p.Name = n
+			}
+
+		case lpath == "name.formatted", lpath == "displayname":
+			name, err := scimStringValue(path, value)
+			if err != nil {
+				return err
+			}
+			p.Name = &name
+		}
+		return nil
+	}
+
+	for _, op := range r.Operations {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if err := apply(op.Path, op.Value); err != nil {
+				return p, err
+			}
+		default:
+			return p, scimError{
+				status:   http.StatusBadRequest,
+				scimType: "mutability",
+				detail:   fmt.Sprintf("unsupported patch operation %q for users", op.Op),
+			}
+		}
+	}
+	return p, nil
+}
+
+func patchSCIMUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*patchSCIMUserRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	p, err := req.payload()
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	user, err := svc.SCIMModifyUser(ctx, id, p)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{resource: newSCIMUser(user)}, nil
+}
+
+type deleteSCIMUserRequest struct {
+	ID string `url:"id"`
+}
+
+func deleteSCIMUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*deleteSCIMUserRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	if err := svc.SCIMDeleteUser(ctx, id); err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{status: http.StatusNoContent}, nil
+}
+
+// checkSCIMAuthenticated returns an error if the request is not
+// authenticated with the SCIM token, the SCIM service methods must only be
+// used by the SCIM endpoints.
+func (svc *Service) checkSCIMAuthenticated(ctx context.Context) error {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		return authz.ForbiddenWithInternal("request not authenticated with the SCIM token", nil, nil, nil)
+	}
+	return nil
+}
+
+func (svc *Service) AuthenticateSCIM(ctx context.Context, token string) error {
+	// skipauth: Authorization is currently for user endpoints only.
+	svc.authz.SkipAuthorization(ctx)
+
+	if svc.config.Server.SCIMToken == "" {
+		return ctxerr.Wrap(ctx, fleet.NewAuthRequiredError("authentication error: SCIM provisioning is not enabled"))
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(svc.config.Server.SCIMToken)) != 1 {
+		return ctxerr.Wrap(ctx, fleet.NewAuthRequiredError("authentication error: invalid SCIM token"))
+	}
+	return nil
+}
+
+func (svc *Service) SCIMNewUser(ctx context.Context, p fleet.SCIMUserPayload) (*fleet.User, error) {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	if p.Email == nil || *p.Email == "" {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("userName", "missing required argument"))
+	}
+	if err := fleet.ValidateEmail(*p.Email); err != nil {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("userName", err.Error()))
+	}
+	switch _, err := svc.ds.UserByEmail(ctx, *p.Email); {
+	case err == nil:
+		return nil, ctxerr.Wrap(ctx, alreadyExistsError{})
+	case fleet.IsNotFound(err):
+		// OK
+	default:
+		return nil, ctxerr.Wrap(ctx, err, "get SCIM user by email")
+	}
+
+	name := *p.Email
+	if p.Name != nil && *p.Name != "" {
+		name = *p.Name
+	}
+	user, err := svc.NewUser(ctx, fleet.UserPayload{
+		Name:       &name,
+		Email:      p.Email,
+		SSOEnabled: ptr.Bool(true),
+		GlobalRole: ptr.String(fleet.RoleObserver),
+	})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create SCIM user")
+	}
+
+	if p.Active != nil && !*p.Active {
+		user.Disabled = true
+		if err := svc.ds.SaveUser(ctx, user); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "disable SCIM user")
+		}
+	}
+
+	// provisioned users have no actor
+	if err := svc.newCreatedUserActivity(ctx, nil, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SCIMUser returns the user if it is managed by SCIM. Only the SSO users
+// (which include the users provisioned by SCIM) are managed by SCIM, the other
+// users (e.g. the local admins) are reported as not found so that the SCIM
+// token can't be used to take over their account.
+func (svc *Service) SCIMUser(ctx context.Context, id uint) (*fleet.User, error) {
+	if err := svc.checkSCIMAuthenticated(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := svc.ds.UserByID(ctx, id)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "get SCIM user")
+	}
+	if !user.SSOEnabled {
+		return nil, ctxerr.Wrap(ctx, scimError{
+			status: http.StatusNotFound,
+			detail: fmt.Sprintf("user %d not found", id),
+		}, "user is not a SSO user")
+	}
+	return user, nil
+}
+
+func (svc *Service) SCIMModifyUser(ctx context.Context, id uint, p fleet.SCIMUserPayload) (*fleet.User, error) {
+	user, err := svc.SCIMUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := newUserAuditFields(user)
+
+	if p.Email != nil && *p.Email != user.Email {
+		if err := fleet.ValidateEmail(*p.Email); err != nil {
+			return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("userName", err.Error()))
+		}
+		user.Email = *p.Email
+	}
+	if p.Name != nil && *p.Name != "" {
+		user.Name = *p.Name
+	}
+	deactivated := false
+	if p.Active != nil {
+		deactivated = !*p.Active && !user.Disabled
+		user.Disabled = !*p.Active
+	}
+
+	if err := svc.ds.SaveUser(ctx, user); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "save SCIM user")
+	}
+	if err := svc.newEditedUserActivity(ctx, before, user, false); err != nil {
+		return nil, err
+	}
+
+	if deactivated {
+		logging.WithExtras(ctx, "deactivated_user_id", user.ID)
+		if err := svc.DeleteSessionsForUser(ctx, user.ID); err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "delete sessions of deactivated SCIM user")
+		}
+	}
+	return user, nil
+}
+
+func (svc *Service) SCIMDeleteUser(ctx context.Context, id uint) error {
+	if _, err := svc.SCIMUser(ctx, id); err != nil {
+		return err
+	}
+
+	// sessions are not deleted with the user, delete them first so that they
+	// can't be used anymore.
+	if err := svc.DeleteSessionsForUser(ctx, id); err != nil {
+		return err
+	}
+	return svc.DeleteUser(ctx, id)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// SCIM Groups
+////////////////////////////////////////////////////////////////////////////////
+
+type listSCIMGroupsRequest struct {
+	Filter     string `query:"filter,optional"`
+	StartIndex int    `query:"startIndex,optional"`
+	Count      *int   `query:"count,optional"`
+}
+
+func listSCIMGroupsEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listSCIMGroupsRequest)
+	name, err := parseSCIMFilter(req.Filter, "displayName")
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+
+	teams, err := svc.SCIMListGroups(ctx, name)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	resources := make([]interface{}, 0, len(teams))
+	for _, team := range teams {
+		resources = append(resources, newSCIMGroup(team, nil))
+	}
+	return scimResponse{resource: newSCIMListResponse(resources, req.StartIndex, req.Count)}, nil
+}
+
+type getSCIMGroupRequest struct {
+	ID string `url:"id"`
+}
+
+func getSCIMGroupEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*getSCIMGroupRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	team, members, err := svc.SCIMGroup(ctx, id)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{resource: newSCIMGroup(team, members)}, nil
+}
+
+func scimMemberIDs(members []scimMember) ([]uint, error) {
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		id, err := parseSCIMID(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// setSCIMGroupMembers sets the members of the group and returns the response
+// with the updated group.
+func setSCIMGroupMembers(ctx context.Context, svc fleet.Service, teamID uint, members []scimMember) scimResponse {
+	ids, err := scimMemberIDs(members)
+	if err != nil {
+		return scimResponse{Err: err}
+	}
+	if err := svc.SCIMSetGroupMembers(ctx, teamID, ids); err != nil {
+		return scimResponse{Err: err}
+	}
+	team, users, err := svc.SCIMGroup(ctx, teamID)
+	if err != nil {
+		return scimResponse{Err: err}
+	}
+	return scimResponse{resource: newSCIMGroup(team, users)}
+}
+
+type createSCIMGroupRequest struct {
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+}
+
+func createSCIMGroupEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*createSCIMGroupRequest)
+	team, err := svc.SCIMNewGroup(ctx, req.DisplayName)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	resp := setSCIMGroupMembers(ctx, svc, team.ID, req.Members)
+	if resp.Err == nil {
+		resp.status = http.StatusCreated
+	}
+	return resp, nil
+}
+
+type replaceSCIMGroupRequest struct {
+	ID          string       `url:"id"`
+	DisplayName string       `json:"displayName"`
+	Members     []scimMember `json:"members"`
+}
+
+func replaceSCIMGroupEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*replaceSCIMGroupRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	team, _, err := svc.SCIMGroup(ctx, id)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	if err := checkSCIMGroupName(team, req.DisplayName); err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return setSCIMGroupMembers(ctx, svc, id, req.Members), nil
+}
+
+// checkSCIMGroupName returns an error if the group is renamed, renaming
+// teams via SCIM is not supported.
+func checkSCIMGroupName(team *fleet.Team, name string) error {
+	if name != "" && name != team.Name {
+		return scimError{
+			status:   http.StatusBadRequest,
+			scimType: "mutability",
+			detail:   "renaming the group of a Fleet team is not supported",
+		}
+	}
+	return nil
+}
+
+type patchSCIMGroupRequest struct {
+	ID         string               `url:"id"`
+	Operations []scimPatchOperation `json:"Operations"`
+}
+
+var scimMemberPathRegexp = regexp.MustCompile(`^(?i:members)\[\s*(?i:value)\s+(?i:eq)\s+"([^"]*)"\s*\]$`)
+
+// members applies the patch operations to the current members of the group
+// and returns the resulting members.
+func (r patchSCIMGroupRequest) members(team *fleet.Team, current []*fleet.User) ([]scimMember, error) {
+	var members []scimMember
+	for _, user := range current {
+		members = append(members, scimMember{Value: strconv.FormatUint(uint64(user.ID), 10)})
+	}
+
+	remove := func(values ...string) {
+		var kept []scimMember
+		for _, m := range members {
+			removed := false
+			for _, v := range values {
+				if m.Value == v {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				kept = append(kept, m)
+			}
+		}
+		members = kept
+	}
+
+	for _, op := range r.Operations {
+		lop := strings.ToLower(op.Op)
+
+		var value []scimMember
+		switch path := strings.ToLower(op.Path); {
+		case path == "":
+			// the value holds the attributes to set
+			var attrs struct {
+				DisplayName string       `json:"displayName"`
+				Members     []scimMember `json:"members"`
+			}
+			if err := json.Unmarshal(op.Value, &attrs); err != nil {
+				return nil, scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "value must be an object when path is not set"}
+			}
+			if err := checkSCIMGroupName(team, attrs.DisplayName); err != nil {
+				return nil, err
+			}
+			value = attrs.Members
+
+		case path == "displayname":
+			name, err := scimStringValue(op.Path, op.Value)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkSCIMGroupName(team, name); err != nil {
+				return nil, err
+			}
+			continue
+
+		case path == "members":
+			if len(op.Value) > 0 {
+				if err := json.Unmarshal(op.Value, &value); err != nil {
+					return nil, scimError{status: http.StatusBadRequest, scimType: "invalidValue", detail: "members must be an array"}
+				}
+			}
+
+		case scimMemberPathRegexp.MatchString(op.Path):
+			if lop != "remove" {
+				return nil, scimError{status: http.StatusBadRequest, scimType: "invalidPath", detail: fmt.Sprintf("unsupported path %q for operation %q", op.Path, op.Op)}
+			}
+			remove(scimMemberPathRegexp.FindStringSubmatch(op.Path)[1])
+			continue
+
+		default:
+			return nil, scimError{status: http.StatusBadRequest, scimType: "invalidPath", detail: fmt.Sprintf("unsupported path %q for groups", op.Path)}
+		}
+
+		switch lop {
+		case "add":
+			for _, v := range value {
+				remove(v.Value)
+				members = append(members, v)
+			}
+		case "replace":
+			members = value
+		case "remove":
+			if op.Path != "" && len(value) == 0 {
+				// remove all members
+				members = nil
+				continue
+			}
+			values := make([]string, 0, len(value))
+			for _, v := range value {
+				values = append(values, v.Value)
+			}
+			remove(values...)
+		default:
+			return nil, scimError{status: http.StatusBadRequest, scimType: "invalidSyntax", detail: fmt.Sprintf("unknown patch operation %q", op.Op)}
+		}
+	}
+	return members, nil
+}
+
+func patchSCIMGroupEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*patchSCIMGroupRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	team, current, err := svc.SCIMGroup(ctx, id)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	members, err := req.members(team, current)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return setSCIMGroupMembers(ctx, svc, id, members), nil
+}
+
+type deleteSCIMGroupRequest struct {
+	ID string `url:"id"`
+}
+
+// deleteSCIMGroupEndpoint removes all the members of the group, but keeps the
+// team: deleting a team would move its hosts to no team and delete its
+// policies and schedule, it must be done explicitly by a Fleet admin.
+func deleteSCIMGroupEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*deleteSCIMGroupRequest)
+	id, err := parseSCIMID(req.ID)
+	if err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	if err := svc.SCIMSetGroupMembers(ctx, id, nil); err != nil {
+		return scimResponse{Err: err}, nil
+	}
+	return scimResponse{status: http.StatusNoContent}, nil
+}
+
+func (svc *Service) SCIMListGroups(ctx context.Context, name string) ([]*fleet.Team, error) {
+	// skipauth: No authorization check needed due to implementation returning
+	// only license error.
+	svc.authz.SkipAuthorization(ctx)
+
+	return nil, fleet.ErrMissingLicense
+}
+
+func (svc *Service) SCIMGroup(ctx context.Context, teamID uint) (*fleet.Team, []*fleet.User, error) {
+	// skipauth: No authorization check needed due to implementation returning
+	// only license error.
+	svc.authz.SkipAuthorization(ctx)
+
+	return nil, nil, fleet.ErrMissingLicense
+}
+
+func (svc *Service) SCIMNewGroup(ctx context.Context, name string) (*fleet.Team, error) {
+	// skipauth: No authorization check needed due to implementation returning
+	// only license error.
+	svc.authz.SkipAuthorization(ctx)
+
+	return nil, fleet.ErrMissingLicense
+}
+
+func (svc *Service) SCIMSetGroupMembers(ctx context.Context, teamID uint, userIDs []uint) error {
+	// skipauth: No authorization check needed due to implementation returning
+	// only license error.
+	svc.authz.SkipAuthorization(ctx)
+
+	return fleet.ErrMissingLicense
+}
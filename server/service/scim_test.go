@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/config"
+	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	cases := []struct {
+		filter string
+		want   string
+		err    bool
+	}{
+		{"", "", false},
+		{`userName eq "a@example.com"`, "a@example.com", false},
+		{`username EQ "a@example.com"`, "a@example.com", false},
+		{`userName eq "with \"quotes\""`, `with "quotes"`, false},
+		{`displayName eq "a"`, "", true},
+		{`userName co "a"`, "", true},
+		{`userName eq "a" and active eq true`, "", true},
+	}
+	for _, c := range cases {
+		t.Run(c.filter, func(t *testing.T) {
+			got, err := parseSCIMFilter(c.filter, "userName")
+			if c.err {
+				require.Error(t, err)
+				status, body := newSCIMErrorBody(err)
+				assert.Equal(t, 400, status)
+				assert.Equal(t, "invalidFilter", body.ScimType)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestSCIMListResponse(t *testing.T) {
+	resources := []interface{}{"a", "b", "c"}
+
+	resp := newSCIMListResponse(resources, 0, nil)
+	assert.Equal(t, 3, resp.TotalResults)
+	assert.Equal(t, 1, resp.StartIndex)
+	assert.Equal(t, []interface{}{"a", "b", "c"}, resp.Resources)
+
+	resp = newSCIMListResponse(resources, 2, ptr.Int(1))
+	assert.Equal(t, 3, resp.TotalResults)
+	assert.Equal(t, 1, resp.ItemsPerPage)
+	assert.Equal(t, []interface{}{"b"}, resp.Resources)
+
+	resp = newSCIMListResponse(resources, 5, ptr.Int(10))
+	assert.Equal(t, 0, resp.ItemsPerPage)
+	assert.Empty(t, resp.Resources)
+}
+
+func TestPatchSCIMUserPayload(t *testing.T) {
+	var req patchSCIMUserRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "new@example.com"},
+			{"op": "add", "value": {"name": {"givenName": "Jane", "familyName": "Doe"}, "title": "ignored"}}
+		]
+	}`), &req))
+	p, err := req.payload()
+	require.NoError(t, err)
+	require.NotNil(t, p.Active)
+	assert.False(t, *p.Active)
+	require.NotNil(t, p.Email)
+	assert.Equal(t, "new@example.com", *p.Email)
+	require.NotNil(t, p.Name)
+	assert.Equal(t, "Jane Doe", *p.Name)
+
+	req = patchSCIMUserRequest{Operations: []scimPatchOperation{{Op: "remove", Path: "active"}}}
+	_, err = req.payload()
+	require.Error(t, err)
+
+	req = patchSCIMUserRequest{Operations: []scimPatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}}}
+	_, err = req.payload()
+	require.Error(t, err)
+}
+
+func TestPatchSCIMGroupMembers(t *testing.T) {
+	team := &fleet.Team{ID: 1, Name: "team1"}
+	current := []*fleet.User{{ID: 1}, {ID: 2}}
+
+	values := func(members []scimMember) []string {
+		var vals []string
+		for _, m := range members {
+			vals = append(vals, m.Value)
+		}
+		return vals
+	}
+
+	cases := []struct {
+		name string
+		ops  string
+		want []string
+		err  bool
+	}{
+		{"add", `[{"op": "add", "path": "members", "value": [{"value": "3"}, {"value": "1"}]}]`, []string{"2", "3", "1"}, false},
+		{"remove by value", `[{"op": "remove", "path": "members", "value": [{"value": "1"}]}]`, []string{"2"}, false},
+		{"remove by filter", `[{"op": "remove", "path": "members[value eq \"2\"]"}]`, []string{"1"}, false},
+		{"remove all", `[{"op": "remove", "path": "members"}]`, nil, false},
+		{"replace", `[{"op": "replace", "path": "members", "value": [{"value": "4"}]}]`, []string{"4"}, false},
+		{"replace without path", `[{"op": "replace", "value": {"displayName": "team1", "members": [{"value": "5"}]}}]`, []string{"5"}, false},
+		{"same name", `[{"op": "replace", "path": "displayName", "value": "team1"}]`, []string{"1", "2"}, false},
+		{"rename", `[{"op": "replace", "path": "displayName", "value": "team2"}]`, nil, true},
+		{"unknown path", `[{"op": "add", "path": "externalId", "value": "x"}]`, nil, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req patchSCIMGroupRequest
+			require.NoError(t, json.Unmarshal([]byte(`{"Operations": `+c.ops+`}`), &req))
+			members, err := req.members(team, current)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.want, values(members))
+		})
+	}
+}
+
+func TestSCIMUsers(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	cfg := config.TestConfig()
+	cfg.Server.SCIMToken = "scimtoken"
+	svc := newTestServiceWithConfig(t, ds, cfg, nil, nil)
+
+	newCtx := func() context.Context {
+		return authz_ctx.NewContext(context.Background(), &authz_ctx.AuthorizationContext{})
+	}
+	scimCtx := func(t *testing.T) context.Context {
+		ctx := newCtx()
+		require.NoError(t, svc.AuthenticateSCIM(ctx, "scimtoken"))
+		ac, _ := authz_ctx.FromContext(ctx)
+		ac.SetAuthnMethod(authz_ctx.AuthnSCIMToken)
+		return ctx
+	}
+
+	t.Run("authenticate", func(t *testing.T) {
+		ctx := newCtx()
+		err := svc.AuthenticateSCIM(ctx, "bad")
+		require.Error(t, err)
+		var authErr *fleet.AuthRequiredError
+		require.ErrorAs(t, err, &authErr)
+		ac, _ := authz_ctx.FromContext(ctx)
+		assert.True(t, ac.Checked())
+
+		require.Error(t, svc.AuthenticateSCIM(newCtx(), ""))
+	})
+
+	t.Run("requires SCIM authentication", func(t *testing.T) {
+		_, err := svc.SCIMNewUser(newCtx(), fleet.SCIMUserPayload{Email: ptr.String("a@example.com")})
+		require.Error(t, err)
+		_, err = svc.SCIMModifyUser(newCtx(), 1, fleet.SCIMUserPayload{Active: ptr.Bool(false)})
+		require.Error(t, err)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+			return nil, &notFoundError{}
+		}
+		var created *fleet.User
+		ds.NewUserFunc = func(ctx context.Context, user *fleet.User) (*fleet.User, error) {
+			user.ID = 1
+			created = user
+			return user, nil
+		}
+		ds.SaveUserFunc = func(ctx context.Context, user *fleet.User) error {
+			return nil
+		}
+
+		user, err := svc.SCIMNewUser(scimCtx(t), fleet.SCIMUserPayload{Email: ptr.String("a@example.com"), Active: ptr.Bool(false)})
+		require.NoError(t, err)
+		assert.Equal(t, "a@example.com", user.Name)
+		assert.True(t, created.SSOEnabled)
+		assert.Equal(t, ptr.String(fleet.RoleObserver), created.GlobalRole)
+		assert.True(t, user.Disabled)
+		assert.True(t, ds.SaveUserFuncInvoked)
+
+		_, err = svc.SCIMNewUser(scimCtx(t), fleet.SCIMUserPayload{Email: ptr.String("invalid")})
+		require.Error(t, err)
+
+		ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+			return &fleet.User{ID: 1, Email: email}, nil
+		}
+		_, err = svc.SCIMNewUser(scimCtx(t), fleet.SCIMUserPayload{Email: ptr.String("a@example.com")})
+		require.Error(t, err)
+		status, body := newSCIMErrorBody(err)
+		assert.Equal(t, 409, status)
+		assert.Equal(t, "uniqueness", body.ScimType)
+	})
+
+	t.Run("deactivate", func(t *testing.T) {
+		user := &fleet.User{ID: 1, Name: "a", Email: "a@example.com", SSOEnabled: true, GlobalRole: ptr.String(fleet.RoleObserver)}
+		ds.UserByIDFunc = func(ctx context.Context, id uint) (*fleet.User, error) {
+			return user, nil
+		}
+		ds.SaveUserFunc = func(ctx context.Context, u *fleet.User) error {
+			user = u
+			return nil
+		}
+		ds.DestroyAllSessionsForUserFunc = func(ctx context.Context, id uint) error {
+			assert.Equal(t, uint(1), id)
+			return nil
+		}
+
+		// renaming doesn't delete the sessions
+		u, err := svc.SCIMModifyUser(scimCtx(t), 1, fleet.SCIMUserPayload{Name: ptr.String("b")})
+		require.NoError(t, err)
+		assert.Equal(t, "b", u.Name)
+		assert.False(t, u.Disabled)
+		assert.False(t, ds.DestroyAllSessionsForUserFuncInvoked)
+
+		u, err = svc.SCIMModifyUser(scimCtx(t), 1, fleet.SCIMUserPayload{Active: ptr.Bool(false)})
+		require.NoError(t, err)
+		assert.True(t, u.Disabled)
+		assert.True(t, ds.DestroyAllSessionsForUserFuncInvoked)
+
+		ds.DestroyAllSessionsForUserFuncInvoked = false
+		u, err = svc.SCIMModifyUser(scimCtx(t), 1, fleet.SCIMUserPayload{Active: ptr.Bool(true)})
+		require.NoError(t, err)
+		assert.False(t, u.Disabled)
+		assert.False(t, ds.DestroyAllSessionsForUserFuncInvoked)
+	})
+
+	t.Run("local users", func(t *testing.T) {
+		user := &fleet.User{ID: 2, Name: "admin", Email: "admin@example.com", GlobalRole: ptr.String(fleet.RoleAdmin)}
+		ds.UserByIDFunc = func(ctx context.Context, id uint) (*fleet.User, error) {
+			return user, nil
+		}
+		ds.SaveUserFuncInvoked = false
+		ds.DeleteUserFuncInvoked = false
+
+		_, err := svc.SCIMUser(scimCtx(t), 2)
+		require.Error(t, err)
+		status, _ := newSCIMErrorBody(err)
+		assert.Equal(t, 404, status)
+
+		_, err = svc.SCIMModifyUser(scimCtx(t), 2, fleet.SCIMUserPayload{Email: ptr.String("takeover@example.com")})
+		require.Error(t, err)
+		status, _ = newSCIMErrorBody(err)
+		assert.Equal(t, 404, status)
+		assert.False(t, ds.SaveUserFuncInvoked)
+		assert.Equal(t, "admin@example.com", user.Email)
+
+		err = svc.SCIMDeleteUser(scimCtx(t), 2)
+		require.Error(t, err)
+		status, _ = newSCIMErrorBody(err)
+		assert.Equal(t, 404, status)
+		assert.False(t, ds.DeleteUserFuncInvoked)
+	})
+
+	t.Run("groups require premium", func(t *testing.T) {
+		_, err := svc.SCIMListGroups(scimCtx(t), "")
+		require.ErrorIs(t, err, fleet.ErrMissingLicense)
+		require.ErrorIs(t, svc.SCIMSetGroupMembers(scimCtx(t), 1, []uint{1}), fleet.ErrMissingLicense)
+	})
+}
+
+func TestLoginDisabledUser(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	svc := newTestService(t, ds, nil, nil)
+
+	user := &fleet.User{ID: 1, Email: "a@example.com", Disabled: true}
+	require.NoError(t, user.SetPassword(test.GoodPassword, 10, 10))
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		return user, nil
+	}
+
+	_, _, err := svc.Login(authz_ctx.NewContext(context.Background(), &authz_ctx.AuthorizationContext{}), "a@example.com", test.GoodPassword)
+	require.Error(t, err)
+	var authErr *fleet.AuthFailedError
+	require.ErrorAs(t, err, &authErr)
+	assert.False(t, ds.NewSessionFuncInvoked)
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/WatchBeam/clock"
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
 	"github.com/fleetdm/fleet/v4/server/authz"
 	"github.com/fleetdm/fleet/v4/server/config"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -38,9 +39,18 @@ type Service struct {
 
 	mailService     fleet.MailService
 	ssoSessionStore sso.SessionStore
+	oidcProviders   *sso.OIDCProviderCache
 
 	failingPolicySet  fleet.FailingPolicySet
+	passingPolicySet  fleet.FailingPolicySet
 	enrollHostLimiter fleet.EnrollHostLimiter
+	loginAttempts     fleet.LoginAttemptsStore
+	// activityArchive is nil if the activity archive is not configured.
+	activityArchive fleet.ActivityArchiveStore
+	// liveQueryExport and liveQueryExporter are nil if the live query export
+	// is not configured.
+	liveQueryExport   fleet.LiveQueryExportStore
+	liveQueryExporter *liveQueryExporter
 
 	authz *authz.Authorizer
 
@@ -65,19 +75,24 @@ func NewService(
 	config config.FleetConfig,
 	mailService fleet.MailService,
 	c clock.Clock,
-	sso sso.SessionStore,
+	ssoStore sso.SessionStore,
 	lq fleet.LiveQueryStore,
 	carveStore fleet.CarveStore,
 	installerStore fleet.InstallerStore,
+	activityArchive fleet.ActivityArchiveStore,
+	liveQueryExport fleet.LiveQueryExportStore,
 	license fleet.LicenseInfo,
 	failingPolicySet fleet.FailingPolicySet,
+	passingPolicySet fleet.FailingPolicySet,
 	geoIP fleet.GeoIP,
 	enrollHostLimiter fleet.EnrollHostLimiter,
+	loginAttempts fleet.LoginAttemptsStore,
 ) (fleet.Service, error) {
 	authorizer, err := authz.NewAuthorizer()
 	if err != nil {
 		return nil, fmt.Errorf("new authorizer: %w", err)
 	}
+	authorizer.SetCustomRolesLoader(ds.ListCustomRoles)
 
 	svc := &Service{
 		ds:                ds,
@@ -91,16 +106,42 @@ func NewService(
 		clock:             c,
 		osqueryLogWriter:  osqueryLogger,
 		mailService:       mailService,
-		ssoSessionStore:   sso,
+		ssoSessionStore:   ssoStore,
+		oidcProviders:     sso.NewOIDCProviderCache(fleethttp.NewClient(fleethttp.WithTimeout(5 * time.Second))),
 		license:           license,
 		failingPolicySet:  failingPolicySet,
+		passingPolicySet:  passingPolicySet,
 		authz:             authorizer,
 		jitterH:           make(map[time.Duration]*jitterHashTable),
 		jitterMu:          new(sync.Mutex),
 		geoIP:             geoIP,
 		enrollHostLimiter: enrollHostLimiter,
+		loginAttempts:     loginAttempts,
+		activityArchive:   activityArchive,
+		liveQueryExport:   liveQueryExport,
 	}
-	return validationMiddleware{svc, ds, sso}, nil
+	if liveQueryExport != nil {
+		svc.liveQueryExporter = newLiveQueryExporter(ds, liveQueryExport, logger)
+		svc.liveQueryExporter.start(ctx)
+	}
+	return validationMiddleware{svc, ds, ssoStore}, nil
+}
+
+type backgroundTasksKey struct{}
+
+// WithBackgroundTasks returns a context to pass to NewService that tracks the
+// background tasks of the service in wg, e.g. the writing of the queued live
+// query exports, so that the caller can wait for them to complete once the
+// context is canceled.
+func WithBackgroundTasks(ctx context.Context, wg *sync.WaitGroup) context.Context {
+	return context.WithValue(ctx, backgroundTasksKey{}, wg)
+}
+
+func backgroundTasks(ctx context.Context) *sync.WaitGroup {
+	if wg, ok := ctx.Value(backgroundTasksKey{}).(*sync.WaitGroup); ok {
+		return wg
+	}
+	return new(sync.WaitGroup)
 }
 
 func (s *Service) SendEmail(mail fleet.Email) error {
@@ -7,10 +7,12 @@ import (
 	"strings"
 
 	"github.com/fleetdm/fleet/v4/server"
+	"github.com/fleetdm/fleet/v4/server/config"
 	authz_ctx "github.com/fleetdm/fleet/v4/server/contexts/authz"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/logging"
 	"github.com/fleetdm/fleet/v4/server/mail"
 )
 
@@ -139,14 +141,60 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 		Json:  conf.Logging.JSON,
 	}
 
-	switch conf.Osquery.StatusLogPlugin {
+	status, err := loggingPlugins(ctx, conf, conf.Osquery.StatusLogPlugin, statusLoggingPlugin)
+	if err != nil {
+		return nil, err
+	}
+	result, err := loggingPlugins(ctx, conf, conf.Osquery.ResultLogPlugin, resultLoggingPlugin)
+	if err != nil {
+		return nil, err
+	}
+	logging.Status = status
+	logging.Result = result
+	return logging, nil
+}
+
+// loggingPlugins returns the configuration of the comma-separated list of
+// logging plugins. If there are many plugins, the configuration of each plugin
+// is listed in the config of the returned plugin.
+func loggingPlugins(
+	ctx context.Context,
+	conf config.FleetConfig,
+	plugins string,
+	pluginFn func(context.Context, config.FleetConfig, string) (fleet.LoggingPlugin, error),
+) (fleet.LoggingPlugin, error) {
+	names := logging.SplitPlugins(plugins)
+	switch len(names) {
+	case 0:
+		return pluginFn(ctx, conf, "")
+	case 1:
+		return pluginFn(ctx, conf, names[0])
+	}
+
+	configs := make([]fleet.LoggingPlugin, 0, len(names))
+	for _, name := range names {
+		p, err := pluginFn(ctx, conf, name)
+		if err != nil {
+			return p, err
+		}
+		configs = append(configs, p)
+	}
+	return fleet.LoggingPlugin{
+		Plugin: strings.Join(names, ","),
+		Config: configs,
+	}, nil
+}
+
+func statusLoggingPlugin(ctx context.Context, conf config.FleetConfig, plugin string) (fleet.LoggingPlugin, error) {
+	var p fleet.LoggingPlugin
+	switch plugin {
 	case "", "filesystem":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "filesystem",
 			Config: fleet.FilesystemConfig{FilesystemConfig: conf.Filesystem},
 		}
 	case "kinesis":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "kinesis",
 			Config: fleet.KinesisConfig{
 				Region:       conf.Kinesis.Region,
@@ -155,7 +203,7 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "firehose":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "firehose",
 			Config: fleet.FirehoseConfig{
 				Region:       conf.Firehose.Region,
@@ -164,7 +212,7 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "lambda":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "lambda",
 			Config: fleet.LambdaConfig{
 				Region:         conf.Lambda.Region,
@@ -173,32 +221,65 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "pubsub":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "pubsub",
 			Config: fleet.PubSubConfig{PubSubConfig: conf.PubSub},
 		}
 	case "stdout":
-		logging.Status = fleet.LoggingPlugin{Plugin: "stdout"}
+		p = fleet.LoggingPlugin{Plugin: "stdout"}
 	case "kafkarest":
-		logging.Status = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "kafkarest",
 			Config: fleet.KafkaRESTConfig{
 				StatusTopic: conf.KafkaREST.StatusTopic,
 				ProxyHost:   conf.KafkaREST.ProxyHost,
 			},
 		}
+	case "splunk":
+		p = fleet.LoggingPlugin{
+			Plugin: "splunk",
+			Config: fleet.SplunkConfig{
+				URL:              conf.Splunk.URL,
+				Index:            conf.Splunk.Index,
+				StatusSourcetype: conf.Splunk.StatusSourcetype,
+				ResultSourcetype: conf.Splunk.ResultSourcetype,
+			},
+		}
+	case "elasticsearch":
+		p = fleet.LoggingPlugin{
+			Plugin: "elasticsearch",
+			Config: fleet.ElasticsearchConfig{
+				URL:         conf.Elasticsearch.URL,
+				StatusIndex: conf.Elasticsearch.StatusIndex,
+				ResultIndex: conf.Elasticsearch.ResultIndex,
+			},
+		}
+	case "syslog":
+		p = fleet.LoggingPlugin{
+			Plugin: "syslog",
+			Config: fleet.SyslogConfig{
+				Address:  conf.Syslog.Address,
+				Facility: conf.Syslog.Facility,
+				AppName:  conf.Syslog.AppName,
+				TLS:      conf.Syslog.TLS,
+			},
+		}
 	default:
-		return nil, ctxerr.Errorf(ctx, "unrecognized logging plugin: %s", conf.Osquery.StatusLogPlugin)
+		return p, ctxerr.Errorf(ctx, "unrecognized logging plugin: %s", plugin)
 	}
+	return p, nil
+}
 
-	switch conf.Osquery.ResultLogPlugin {
+func resultLoggingPlugin(ctx context.Context, conf config.FleetConfig, plugin string) (fleet.LoggingPlugin, error) {
+	var p fleet.LoggingPlugin
+	switch plugin {
 	case "", "filesystem":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "filesystem",
 			Config: fleet.FilesystemConfig{FilesystemConfig: conf.Filesystem},
 		}
 	case "kinesis":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "kinesis",
 			Config: fleet.KinesisConfig{
 				Region:       conf.Kinesis.Region,
@@ -207,7 +288,7 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "firehose":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "firehose",
 			Config: fleet.FirehoseConfig{
 				Region:       conf.Firehose.Region,
@@ -216,7 +297,7 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "lambda":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "lambda",
 			Config: fleet.LambdaConfig{
 				Region:         conf.Lambda.Region,
@@ -225,25 +306,53 @@ func (svc *Service) LoggingConfig(ctx context.Context) (*fleet.Logging, error) {
 			},
 		}
 	case "pubsub":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "pubsub",
 			Config: fleet.PubSubConfig{PubSubConfig: conf.PubSub},
 		}
 	case "stdout":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "stdout",
 		}
 	case "kafkarest":
-		logging.Result = fleet.LoggingPlugin{
+		p = fleet.LoggingPlugin{
 			Plugin: "kafkarest",
 			Config: fleet.KafkaRESTConfig{
 				ResultTopic: conf.KafkaREST.ResultTopic,
 				ProxyHost:   conf.KafkaREST.ProxyHost,
 			},
 		}
+	case "splunk":
+		p = fleet.LoggingPlugin{
+			Plugin: "splunk",
+			Config: fleet.SplunkConfig{
+				URL:              conf.Splunk.URL,
+				Index:            conf.Splunk.Index,
+				StatusSourcetype: conf.Splunk.StatusSourcetype,
+				ResultSourcetype: conf.Splunk.ResultSourcetype,
+			},
+		}
+	case "elasticsearch":
+		p = fleet.LoggingPlugin{
+			Plugin: "elasticsearch",
+			Config: fleet.ElasticsearchConfig{
+				URL:         conf.Elasticsearch.URL,
+				StatusIndex: conf.Elasticsearch.StatusIndex,
+				ResultIndex: conf.Elasticsearch.ResultIndex,
+			},
+		}
+	case "syslog":
+		p = fleet.LoggingPlugin{
+			Plugin: "syslog",
+			Config: fleet.SyslogConfig{
+				Address:  conf.Syslog.Address,
+				Facility: conf.Syslog.Facility,
+				AppName:  conf.Syslog.AppName,
+				TLS:      conf.Syslog.TLS,
+			},
+		}
 	default:
-		return nil, ctxerr.Errorf(ctx, "unrecognized logging plugin: %s", conf.Osquery.ResultLogPlugin)
-
+		return p, ctxerr.Errorf(ctx, "unrecognized logging plugin: %s", plugin)
 	}
-	return logging, nil
+	return p, nil
 }
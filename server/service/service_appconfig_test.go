@@ -91,6 +91,9 @@ func TestCreateAppConfig(t *testing.T) {
 
 func TestEmptyEnrollSecret(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	ds.ApplyEnrollSecretsFunc = func(ctx context.Context, teamID *uint, secrets []*fleet.EnrollSecret) error {
@@ -187,6 +190,20 @@ func TestService_LoggingConfig(t *testing.T) {
 		},
 	}
 
+	splunkConfig := fleet.SplunkConfig{
+		URL:              testSplunkPluginConfig().Splunk.URL,
+		Index:            testSplunkPluginConfig().Splunk.Index,
+		StatusSourcetype: testSplunkPluginConfig().Splunk.StatusSourcetype,
+		ResultSourcetype: testSplunkPluginConfig().Splunk.ResultSourcetype,
+	}
+
+	syslogConfig := fleet.SyslogConfig{
+		Address:  testSyslogPluginConfig().Syslog.Address,
+		Facility: testSyslogPluginConfig().Syslog.Facility,
+		AppName:  testSyslogPluginConfig().Syslog.AppName,
+		TLS:      testSyslogPluginConfig().Syslog.TLS,
+	}
+
 	type fields struct {
 		config config.FleetConfig
 	}
@@ -302,6 +319,60 @@ func TestService_LoggingConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:   "test splunk config",
+			fields: fields{config: testSplunkPluginConfig()},
+			args:   args{ctx: test.UserContext(test.UserAdmin)},
+			want: &fleet.Logging{
+				Debug: true,
+				Json:  false,
+				Result: fleet.LoggingPlugin{
+					Plugin: "splunk",
+					Config: splunkConfig,
+				},
+				Status: fleet.LoggingPlugin{
+					Plugin: "splunk",
+					Config: splunkConfig,
+				},
+			},
+		},
+		{
+			name:   "test syslog config",
+			fields: fields{config: testSyslogPluginConfig()},
+			args:   args{ctx: test.UserContext(test.UserAdmin)},
+			want: &fleet.Logging{
+				Debug: true,
+				Json:  false,
+				Result: fleet.LoggingPlugin{
+					Plugin: "syslog",
+					Config: syslogConfig,
+				},
+				Status: fleet.LoggingPlugin{
+					Plugin: "syslog",
+					Config: syslogConfig,
+				},
+			},
+		},
+		{
+			name:   "test multiple plugins config",
+			fields: fields{config: testMultiplePluginsConfig()},
+			args:   args{ctx: test.UserContext(test.UserAdmin)},
+			want: &fleet.Logging{
+				Debug: true,
+				Json:  false,
+				Result: fleet.LoggingPlugin{
+					Plugin: "stdout",
+					Config: nil,
+				},
+				Status: fleet.LoggingPlugin{
+					Plugin: "kinesis,stdout",
+					Config: []fleet.LoggingPlugin{
+						{Plugin: "kinesis", Config: kinesisConfig},
+						{Plugin: "stdout", Config: nil},
+					},
+				},
+			},
+		},
 		{
 			name:    "test unrecognized config",
 			fields:  fields{config: testUnrecognizedPluginConfig()},
@@ -329,6 +400,9 @@ func TestService_LoggingConfig(t *testing.T) {
 
 func TestModifyAppConfigPatches(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	storedConfig := &fleet.AppConfig{}
@@ -33,7 +33,7 @@ type campaignStatus struct {
 	Status          string `json:"status"`
 }
 
-func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Conn, campaignID uint) {
+func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Conn, campaignID uint, aggregation *fleet.LiveQueryAggregation) {
 	logging.WithExtras(ctx, "campaign_id", campaignID)
 
 	// Explicitly set ObserverCanRun: true in this check because we check that the user trying to
@@ -52,6 +52,15 @@ func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Co
 		return
 	}
 
+	var aggregator *liveQueryAggregator
+	if aggregation != nil {
+		if err := aggregation.Validate(); err != nil {
+			conn.WriteJSONError(err.Error())
+			return
+		}
+		aggregator = newLiveQueryAggregator(*aggregation)
+	}
+
 	// Find the campaign and ensure it is active
 	campaign, err := svc.ds.DistributedQueryCampaign(ctx, campaignID)
 	if err != nil {
@@ -89,6 +98,8 @@ func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Co
 	}
 	lastStatus := status
 	lastTotals := targetTotals{}
+	// the aggregate is only written when it changed since the last update
+	aggregateChanged := false
 
 	// to improve performance of the frontend rendering the results table, we
 	// add the "host_hostname" field to every row and clean null rows.
@@ -133,6 +144,15 @@ func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Co
 			}
 		}
 
+		// the aggregate is written before the status, so that it is up to date
+		// when the client sees that the campaign is finished.
+		if aggregateChanged {
+			aggregateChanged = false
+			if err = conn.WriteJSONMessage("aggregate", aggregator.result()); err != nil {
+				return ctxerr.Wrap(ctx, err, "write aggregate")
+			}
+		}
+
 		status.ExpectedResults = totals.Online
 		if status.ActualResults >= status.ExpectedResults {
 			status.Status = campaignStatusFinished
@@ -166,8 +186,15 @@ func (svc Service) StreamCampaignResults(ctx context.Context, conn *websocket.Co
 			// Receive a result and push it over the websocket
 			switch res := res.(type) {
 			case fleet.DistributedQueryResult:
-				mapHostnameRows(&res)
-				err = conn.WriteJSONMessage("result", res)
+				if aggregator != nil {
+					// only the status of the host is sent, its rows are part
+					// of the aggregate sent with the status updates.
+					err = conn.WriteJSONMessage("host_status", aggregator.addResult(res))
+					aggregateChanged = true
+				} else {
+					mapHostnameRows(&res)
+					err = conn.WriteJSONMessage("result", res)
+				}
 				if ctxerr.Cause(err) == sockjs.ErrSessionNotOpen {
 					// return and stop sending the query if the session was closed
 					// by the client
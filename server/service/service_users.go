@@ -33,11 +33,21 @@ func (svc *Service) NewUser(ctx context.Context, p fleet.UserPayload) (*fleet.Us
 	if err != nil {
 		return nil, err
 	}
+	if !user.SSOEnabled {
+		if err := svc.checkPasswordPolicy(ctx, nil, *p.Password, "password"); err != nil {
+			return nil, err
+		}
+	}
 
 	user, err = svc.ds.NewUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	if !user.SSOEnabled {
+		if err := svc.recordPasswordHistory(ctx, user); err != nil {
+			return nil, err
+		}
+	}
 	return user, nil
 }
 
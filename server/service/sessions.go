@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"math"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,6 +19,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/contexts/logging"
 	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mail"
 	"github.com/fleetdm/fleet/v4/server/sso"
 	"github.com/go-kit/kit/log/level"
 )
@@ -120,19 +122,45 @@ type loginResponse struct {
 	User           *fleet.User          `json:"user,omitempty"`
 	AvailableTeams []*fleet.TeamSummary `json:"available_teams"`
 	Token          string               `json:"token,omitempty"`
-	Err            error                `json:"error,omitempty"`
+	// RecoveryCodes are the two-factor authentication recovery codes of the
+	// user, only set when the user completed their enrollment as part of the
+	// login.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	Err           error    `json:"error,omitempty"`
 }
 
 func (r loginResponse) error() error { return r.Err }
 
+// loginTOTPRequiredResponse is returned instead of the session when the user
+// must complete a two-factor authentication challenge, see loginTOTPEndpoint.
+type loginTOTPRequiredResponse struct {
+	TOTPRequired           bool   `json:"totp_required"`
+	TOTPEnrollmentRequired bool   `json:"totp_enrollment_required"`
+	TOTPToken              string `json:"totp_token"`
+}
+
+func (r loginTOTPRequiredResponse) Status() int { return http.StatusAccepted }
+
 func loginEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
 	req := request.(*loginRequest)
 	req.Email = strings.ToLower(req.Email)
 
 	user, session, err := svc.Login(ctx, req.Email, req.Password)
 	if err != nil {
+		var totpErr *fleet.TOTPRequiredError
+		if errors.As(err, &totpErr) {
+			return loginTOTPRequiredResponse{
+				TOTPRequired:           true,
+				TOTPEnrollmentRequired: totpErr.EnrollmentRequired,
+				TOTPToken:              totpErr.Token,
+			}, nil
+		}
 		return loginResponse{Err: err}, nil
 	}
+	return makeLoginResponse(ctx, svc, user, session), nil
+}
+
+func makeLoginResponse(ctx context.Context, svc fleet.Service, user *fleet.User, session *fleet.Session) loginResponse {
 	// Add viewer to context to allow access to service teams for list of available teams.
 	ctx = viewer.NewContext(ctx, viewer.Viewer{
 		User:    user,
@@ -143,10 +171,10 @@ func loginEndpoint(ctx context.Context, request interface{}, svc fleet.Service)
 		if errors.Is(err, fleet.ErrMissingLicense) {
 			availableTeams = []*fleet.TeamSummary{}
 		} else {
-			return loginResponse{Err: err}, nil
+			return loginResponse{Err: err}
 		}
 	}
-	return loginResponse{user, availableTeams, session.Key, nil}, nil
+	return loginResponse{User: user, AvailableTeams: availableTeams, Token: session.Key}
 }
 
 func (svc *Service) Login(ctx context.Context, email, password string) (*fleet.User, *fleet.Session, error) {
@@ -165,9 +193,22 @@ func (svc *Service) Login(ctx context.Context, email, password string) (*fleet.U
 		}
 	}(time.Now())
 
+	// A locked account gets the same response as an invalid password, and the
+	// emails that don't match any user are locked the same way, so that the
+	// response doesn't reveal whether the account exists.
+	locked, err := svc.isAccountLocked(ctx, email)
+	if err != nil {
+		return nil, nil, err
+	}
+	if locked {
+		err = fleet.NewAuthFailedError("account locked")
+		return nil, nil, err
+	}
+
 	user, err := svc.ds.UserByEmail(ctx, email)
 	var nfe fleet.NotFoundError
 	if errors.As(err, &nfe) {
+		svc.recordFailedLogin(ctx, email, nil)
 		return nil, nil, fleet.NewAuthFailedError("user not found")
 	}
 	if err != nil {
@@ -175,6 +216,8 @@ func (svc *Service) Login(ctx context.Context, email, password string) (*fleet.U
 	}
 
 	if err = user.ValidatePassword(password); err != nil {
+		svc.newFailedLoginActivity(ctx, user, "invalid password")
+		svc.recordFailedLogin(ctx, email, user)
 		return nil, nil, fleet.NewAuthFailedError("invalid password")
 	}
 
@@ -182,14 +225,173 @@ func (svc *Service) Login(ctx context.Context, email, password string) (*fleet.U
 		return nil, nil, fleet.NewAuthFailedError("password login disabled for sso users")
 	}
 
+	if user.Disabled {
+		return nil, nil, fleet.NewAuthFailedError("user is disabled")
+	}
+
+	// An expired password must be reset, as with a reset required by an admin.
+	if err = svc.expirePassword(ctx, user); err != nil {
+		return nil, nil, err
+	}
+
+	// The session is only issued once the second factor is verified, this
+	// includes the users that must reset their password. The failed logins
+	// are reset by LoginTOTP in that case.
+	if err := svc.checkTOTPRequired(ctx, user); err != nil {
+		return nil, nil, err
+	}
+	if err = svc.resetFailedLogins(ctx, email); err != nil {
+		return nil, nil, err
+	}
+
 	session, err := svc.makeSession(ctx, user.ID)
 	if err != nil {
 		return nil, nil, fleet.NewAuthFailedError(err.Error())
 	}
+	if err = svc.newLoggedInActivity(ctx, user, "password"); err != nil {
+		return nil, nil, err
+	}
 
 	return user, session, nil
 }
 
+// newLoggedInActivity records the successful login of the user with the
+// method, one of "password", "two_factor" or "sso".
+func (svc *Service) newLoggedInActivity(ctx context.Context, user *fleet.User, method string) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		user,
+		fleet.ActivityTypeUserLoggedIn,
+		&map[string]interface{}{"method": method},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create user logged in activity")
+	}
+	return nil
+}
+
+// newFailedLoginActivity records the failed login of an existing user. The
+// login fails anyway, so the errors are only logged.
+func (svc *Service) newFailedLoginActivity(ctx context.Context, user *fleet.User, reason string) {
+	if err := svc.ds.NewActivity(
+		ctx,
+		user,
+		fleet.ActivityTypeUserFailedLogin,
+		&map[string]interface{}{"reason": reason},
+	); err != nil {
+		level.Error(svc.logger).Log("msg", "create user failed login activity", "err", err)
+	}
+}
+
+// loginAttemptsKey returns the key identifying the account in the login
+// attempts store.
+func loginAttemptsKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (svc *Service) lockoutEnabled() bool {
+	return svc.config.Auth.LockoutThreshold > 0
+}
+
+func (svc *Service) isAccountLocked(ctx context.Context, email string) (bool, error) {
+	if !svc.lockoutEnabled() {
+		return false, nil
+	}
+	locked, err := svc.loginAttempts.IsAccountLocked(ctx, loginAttemptsKey(email))
+	if err != nil {
+		return false, ctxerr.Wrap(ctx, err, "check account locked")
+	}
+	return locked, nil
+}
+
+func (svc *Service) resetFailedLogins(ctx context.Context, email string) error {
+	if !svc.lockoutEnabled() {
+		return nil
+	}
+	if err := svc.loginAttempts.ResetFailedLogins(ctx, loginAttemptsKey(email)); err != nil {
+		return ctxerr.Wrap(ctx, err, "reset failed logins")
+	}
+	return nil
+}
+
+// unlockAccount unlocks the account and resets its failed logins.
+func (svc *Service) unlockAccount(ctx context.Context, email string) error {
+	if !svc.lockoutEnabled() {
+		return nil
+	}
+	if err := svc.loginAttempts.UnlockAccount(ctx, loginAttemptsKey(email)); err != nil {
+		return ctxerr.Wrap(ctx, err, "unlock account")
+	}
+	return nil
+}
+
+// recordFailedLogin increments the failed logins of the account and locks it
+// once the threshold is reached. The user is nil if the email doesn't match
+// any user. The login fails anyway, so the errors are only logged.
+func (svc *Service) recordFailedLogin(ctx context.Context, email string, user *fleet.User) {
+	if !svc.lockoutEnabled() {
+		return
+	}
+
+	threshold, duration := svc.config.Auth.LockoutThreshold, svc.config.Auth.LockoutDuration
+	key := loginAttemptsKey(email)
+	count, err := svc.loginAttempts.IncrFailedLogins(ctx, key, duration)
+	if err != nil {
+		level.Error(svc.logger).Log("msg", "increment failed logins", "err", err)
+		return
+	}
+	if count < threshold {
+		return
+	}
+	if err := svc.loginAttempts.LockAccount(ctx, key, duration); err != nil {
+		level.Error(svc.logger).Log("msg", "lock account", "err", err)
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	level.Info(svc.logger).Log("msg", "account locked after too many failed logins", "user_id", user.ID, "failed_logins", count)
+	if err := svc.ds.NewActivity(
+		ctx,
+		user,
+		fleet.ActivityTypeUserLockedOut,
+		&map[string]interface{}{
+			"failed_logins":    count,
+			"lockout_duration": int(duration.Seconds()),
+		},
+	); err != nil {
+		level.Error(svc.logger).Log("msg", "create user locked out activity", "err", err)
+	}
+	if err := svc.sendAccountLockedEmail(ctx, user, count); err != nil {
+		level.Error(svc.logger).Log("msg", "send account locked email", "err", err)
+	}
+}
+
+func (svc *Service) sendAccountLockedEmail(ctx context.Context, user *fleet.User, failedLogins int) error {
+	if !svc.config.Auth.LockoutEmail {
+		return nil
+	}
+	config, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	if !config.SMTPSettings.SMTPConfigured {
+		return nil
+	}
+
+	return svc.mailService.SendEmail(fleet.Email{
+		Subject: "Your Fleet Account Is Locked",
+		To:      []string{user.Email},
+		Config:  config,
+		Mailer: &mail.AccountLockedMailer{
+			BaseURL:        template.URL(config.ServerSettings.ServerURL + svc.config.Server.URLPrefix),
+			AssetURL:       getAssetURL(),
+			FailedLogins:   failedLogins,
+			LockoutMinutes: int(math.Ceil(svc.config.Auth.LockoutDuration.Minutes())),
+		},
+	})
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Logout
 ////////////////////////////////////////////////////////////////////////////////
@@ -282,6 +484,20 @@ func (svc *Service) InitiateSSO(ctx context.Context, redirectURL string) (string
 		return "", ctxerr.Wrap(ctx, ssoError{err: err, code: ssoOrgDisabled}, "callback sso")
 	}
 
+	if appConfig.SSOSettings.IsOIDC() {
+		provider, err := svc.oidcProviders.Provider(ctx, appConfig.SSOSettings.OIDC.IssuerURL)
+		if err != nil {
+			return "", ctxerr.Wrap(ctx, err, "InitiateSSO discovering OIDC provider")
+		}
+		settings := svc.oidcSettings(appConfig)
+		settings.OriginalURL = redirectURL
+		idpURL, err := provider.CreateAuthorizationRequest(settings)
+		if err != nil {
+			return "", ctxerr.Wrap(ctx, err, "InitiateSSO creating OIDC authorization")
+		}
+		return idpURL, nil
+	}
+
 	metadata, err := svc.getMetadata(appConfig)
 	if err != nil {
 		return "", ctxerr.Wrap(ctx, err, "InitiateSSO getting metadata")
@@ -335,6 +551,25 @@ func (callbackSSORequest) DecodeRequest(ctx context.Context, r *http.Request) (i
 	return authResponse, nil
 }
 
+// callbackOIDCRequest is the redirection of the user agent by the OpenID
+// Connect provider to the callback URL at the end of the authorization code
+// flow.
+type callbackOIDCRequest struct{}
+
+func (callbackOIDCRequest) DecodeRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	qry := r.URL.Query()
+	authResponse := &sso.OIDCAuthResponse{
+		Code:             qry.Get("code"),
+		State:            qry.Get("state"),
+		Error:            qry.Get("error"),
+		ErrorDescription: qry.Get("error_description"),
+	}
+	if authResponse.State == "" {
+		return nil, ctxerr.Wrap(ctx, &badRequestError{message: "missing state"}, "decoding oidc callback")
+	}
+	return authResponse, nil
+}
+
 type callbackSSOResponse struct {
 	content string
 	Err     error `json:"error,omitempty"`
@@ -421,6 +656,14 @@ func (svc *Service) InitSSOCallback(ctx context.Context, auth fleet.Auth) (strin
 		return "", ctxerr.Wrap(ctx, ssoError{err: err, code: ssoOrgDisabled}, "callback sso")
 	}
 
+	if oidcResponse, ok := auth.(*sso.OIDCAuthResponse); ok {
+		return svc.initOIDCCallback(ctx, appConfig, oidcResponse)
+	}
+	if appConfig.SSOSettings.IsOIDC() {
+		err := ctxerr.New(ctx, "organization configured to use oidc")
+		return "", ctxerr.Wrap(ctx, ssoError{err: err, code: ssoOrgDisabled}, "callback sso")
+	}
+
 	// Load the request metadata if available
 
 	// localhost:9080/simplesaml/saml2/idp/SSOService.php?spentityid=https://localhost:8080
@@ -474,6 +717,53 @@ func (svc *Service) InitSSOCallback(ctx context.Context, auth fleet.Auth) (strin
 	return redirectURL, nil
 }
 
+// initOIDCCallback validates the response of the OpenID Connect provider and
+// returns the URL that the user initially requested. The user information of
+// the response is set once it is validated.
+func (svc *Service) initOIDCCallback(ctx context.Context, appConfig *fleet.AppConfig, auth *sso.OIDCAuthResponse) (string, error) {
+	if !appConfig.SSOSettings.IsOIDC() {
+		err := ctxerr.New(ctx, "organization not configured to use oidc")
+		return "", ctxerr.Wrap(ctx, ssoError{err: err, code: ssoOrgDisabled}, "callback sso")
+	}
+
+	session, err := svc.ssoSessionStore.Get(auth.RequestID())
+	if err != nil {
+		return "", ctxerr.Wrap(ctx, err, "sso request invalid")
+	}
+	// Remove session to so that is can't be reused before it expires.
+	err = svc.ssoSessionStore.Expire(auth.RequestID())
+	if err != nil {
+		return "", ctxerr.Wrap(ctx, err, "remove sso request")
+	}
+
+	provider, err := svc.oidcProviders.Provider(ctx, appConfig.SSOSettings.OIDC.IssuerURL)
+	if err != nil {
+		return "", ctxerr.Wrap(ctx, err, "discover oidc provider")
+	}
+	if err := provider.ValidateAuthResponse(ctx, svc.oidcSettings(appConfig), auth, session); err != nil {
+		return "", ctxerr.Wrap(ctx, err, "oidc response validation failed")
+	}
+	return session.OriginalURL, nil
+}
+
+// oidcSettings returns the settings used to authenticate with the OpenID
+// Connect provider configured in appConfig.
+func (svc *Service) oidcSettings(appConfig *fleet.AppConfig) *sso.OIDCSettings {
+	scopes := appConfig.SSOSettings.OIDC.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	return &sso.OIDCSettings{
+		ClientID:     appConfig.SSOSettings.OIDC.ClientID,
+		ClientSecret: appConfig.SSOSettings.OIDC.ClientSecret,
+		RedirectURL:  appConfig.ServerSettings.ServerURL + svc.config.Server.URLPrefix + "/api/v1/fleet/sso/callback",
+		Scopes:       scopes,
+		EmailClaim:   appConfig.SSOSettings.OIDC.EmailClaim,
+		NameClaim:    appConfig.SSOSettings.OIDC.NameClaim,
+		SessionStore: svc.ssoSessionStore,
+	}
+}
+
 func (svc *Service) GetSSOUser(ctx context.Context, auth fleet.Auth) (*fleet.User, error) {
 	user, err := svc.ds.UserByEmail(ctx, auth.UserID())
 	if err != nil {
@@ -492,10 +782,17 @@ func (svc *Service) LoginSSOUser(ctx context.Context, user *fleet.User, redirect
 		err := ctxerr.New(ctx, "user not configured to use sso")
 		return nil, ctxerr.Wrap(ctx, ssoError{err: err, code: ssoAccountDisabled})
 	}
+	if user.Disabled {
+		err := ctxerr.New(ctx, "user is disabled")
+		return nil, ctxerr.Wrap(ctx, ssoError{err: err, code: ssoAccountDisabled})
+	}
 	session, err := svc.makeSession(ctx, user.ID)
 	if err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "make session in sso callback")
 	}
+	if err := svc.newLoggedInActivity(ctx, user, "sso"); err != nil {
+		return nil, err
+	}
 	result := &fleet.SSOSession{
 		Token:       session.Key,
 		RedirectURL: redirectURL,
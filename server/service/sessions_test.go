@@ -12,6 +12,7 @@ import (
 	"github.com/fleetdm/fleet/v4/server/mock"
 	"github.com/fleetdm/fleet/v4/server/ptr"
 	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/fleetdm/fleet/v4/server/totp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -134,6 +135,262 @@ func TestAuthenticate(t *testing.T) {
 	}
 }
 
+func TestLoginLockout(t *testing.T) {
+	ds := new(mock.Store)
+	cfg := config.TestConfig()
+	cfg.Auth.LockoutThreshold = 2
+	cfg.Auth.LockoutDuration = time.Minute
+	cfg.Auth.LockoutEmail = true
+	svc := newTestServiceWithConfig(t, ds, cfg, nil, nil)
+
+	user := &fleet.User{ID: 42, Email: "alice@example.com", GlobalRole: ptr.String(fleet.RoleObserver)}
+	require.NoError(t, user.SetPassword(test.GoodPassword, 10, 10))
+
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		if email == user.Email {
+			return user, nil
+		}
+		return nil, &mock.Error{Message: "not found"}
+	}
+	ds.UserByIDFunc = func(ctx context.Context, id uint) (*fleet.User, error) {
+		return user, nil
+	}
+	ds.UserTOTPFunc = func(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+		return nil, &mock.Error{Message: "not found"}
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{SMTPSettings: fleet.SMTPSettings{SMTPConfigured: true}}, nil
+	}
+	ds.NewSessionFunc = func(ctx context.Context, userID uint, sessionKey string) (*fleet.Session, error) {
+		return &fleet.Session{UserID: userID, Key: sessionKey}, nil
+	}
+	var activities []string
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		activities = append(activities, activityType)
+		return nil
+	}
+
+	ctx := context.Background()
+	var authErr *fleet.AuthFailedError
+
+	// a successful login resets the failed logins
+	_, _, err := svc.Login(ctx, user.Email, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.NoError(t, err)
+	_, _, err = svc.Login(ctx, user.Email, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	require.Empty(t, activities)
+
+	// the account is locked once the threshold is reached, the valid password
+	// gets the same response as an invalid one
+	_, _, err = svc.Login(ctx, "ALICE@example.com", "wrong")
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, []string{fleet.ActivityTypeUserLockedOut}, activities)
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "account locked", authErr.Internal())
+	require.Equal(t, (&fleet.AuthFailedError{}).Error(), err.Error())
+
+	// the emails that don't match any user are locked the same way
+	for i := 0; i < cfg.Auth.LockoutThreshold; i++ {
+		_, _, err = svc.Login(ctx, "bob@example.com", "wrong")
+		require.ErrorAs(t, err, &authErr)
+		require.Equal(t, "user not found", authErr.Internal())
+	}
+	_, _, err = svc.Login(ctx, "bob@example.com", "wrong")
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "account locked", authErr.Internal())
+	require.Len(t, activities, 1)
+
+	// only admins can unlock the users
+	err = svc.UnlockUser(test.UserContext(test.UserObserver), user.ID)
+	checkAuthErr(t, true, err)
+	err = svc.UnlockUser(test.UserContext(test.UserAdmin), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{fleet.ActivityTypeUserLockedOut, fleet.ActivityTypeUnlockedUser}, activities)
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.NoError(t, err)
+}
+
+func TestLoginLockoutTOTP(t *testing.T) {
+	ds := new(mock.Store)
+	cfg := config.TestConfig()
+	cfg.Auth.LockoutThreshold = 2
+	cfg.Auth.LockoutDuration = time.Minute
+	svc := newTestServiceWithConfig(t, ds, cfg, nil, nil)
+
+	user := &fleet.User{ID: 42, Email: "alice@example.com", GlobalRole: ptr.String(fleet.RoleObserver)}
+	require.NoError(t, user.SetPassword(test.GoodPassword, 10, 10))
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	userTOTP := &fleet.UserTOTP{UserID: user.ID, Secret: secret, Enabled: true}
+
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		return user, nil
+	}
+	ds.UserByIDFunc = func(ctx context.Context, id uint) (*fleet.User, error) {
+		return user, nil
+	}
+	ds.UserTOTPFunc = func(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+		return userTOTP, nil
+	}
+	ds.SaveUserTOTPFunc = func(ctx context.Context, t *fleet.UserTOTP) error {
+		return nil
+	}
+	ds.DeleteUserTOTPRecoveryCodeFunc = func(ctx context.Context, userID uint, codeHash string) error {
+		return &mock.Error{Message: "not found"}
+	}
+	challenges := make(map[string]*fleet.TOTPLoginChallenge)
+	ds.NewTOTPLoginChallengeFunc = func(ctx context.Context, challenge *fleet.TOTPLoginChallenge) error {
+		challenge.CreatedAt = time.Now()
+		challenges[challenge.Token] = challenge
+		return nil
+	}
+	ds.TOTPLoginChallengeByTokenFunc = func(ctx context.Context, token string) (*fleet.TOTPLoginChallenge, error) {
+		return challenges[token], nil
+	}
+	ds.IncrementTOTPLoginChallengeAttemptsFunc = func(ctx context.Context, token string) error {
+		challenges[token].Attempts++
+		return nil
+	}
+	ds.DeleteTOTPLoginChallengeFunc = func(ctx context.Context, token string) error {
+		delete(challenges, token)
+		return nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewSessionFunc = func(ctx context.Context, userID uint, sessionKey string) (*fleet.Session, error) {
+		return &fleet.Session{UserID: userID, Key: sessionKey}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	ctx := context.Background()
+	var authErr *fleet.AuthFailedError
+	var totpErr *fleet.TOTPRequiredError
+
+	// the valid password doesn't reset the failed logins before the second
+	// factor, and the invalid codes count as failed logins
+	_, _, err = svc.Login(ctx, user.Email, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.ErrorAs(t, err, &totpErr)
+	_, _, _, err = svc.LoginTOTP(ctx, totpErr.Token, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "invalid two-factor code", authErr.Internal())
+
+	// the account is locked, for the password and the code
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "account locked", authErr.Internal())
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	_, _, _, err = svc.LoginTOTP(ctx, totpErr.Token, code)
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "account locked", authErr.Internal())
+
+	// a successful second factor resets the failed logins
+	require.NoError(t, svc.UnlockUser(test.UserContext(test.UserAdmin), user.ID))
+	_, _, err = svc.Login(ctx, user.Email, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.ErrorAs(t, err, &totpErr)
+	_, session, _, err := svc.LoginTOTP(ctx, totpErr.Token, code)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	_, _, err = svc.Login(ctx, user.Email, "wrong")
+	require.ErrorAs(t, err, &authErr)
+	require.Equal(t, "invalid password", authErr.Internal())
+	_, _, err = svc.Login(ctx, user.Email, test.GoodPassword)
+	require.ErrorAs(t, err, &totpErr)
+}
+
+func TestLoginTOTPDisabled(t *testing.T) {
+	ds := new(mock.Store)
+	cfg := config.TestConfig()
+	cfg.Auth.TOTPEnabled = false
+	svc := newTestServiceWithConfig(t, ds, cfg, nil, nil)
+
+	user := &fleet.User{ID: 42, Email: "alice@example.com", GlobalRole: ptr.String(fleet.RoleObserver)}
+	require.NoError(t, user.SetPassword(test.GoodPassword, 10, 10))
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		return user, nil
+	}
+	ds.UserTOTPFunc = func(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+		return &fleet.UserTOTP{UserID: userID, Enabled: true}, nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{TwoFactorAuthSettings: fleet.TwoFactorAuthSettings{Enforced: true}}, nil
+	}
+	ds.NewSessionFunc = func(ctx context.Context, userID uint, sessionKey string) (*fleet.Session, error) {
+		return &fleet.Session{UserID: userID, Key: sessionKey}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	// the password is enough while the two-factor authentication is disabled
+	_, session, err := svc.Login(context.Background(), user.Email, test.GoodPassword)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	assert.False(t, ds.NewTOTPLoginChallengeFuncInvoked)
+
+	// and the users can't enroll
+	_, err = svc.EnrollTOTP(viewer.NewContext(context.Background(), viewer.Viewer{User: user}))
+	var badReqErr *badRequestError
+	require.ErrorAs(t, err, &badReqErr)
+}
+
+func TestLoginExpiredPassword(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	user := &fleet.User{ID: 42, Email: "alice@example.com", GlobalRole: ptr.String(fleet.RoleObserver)}
+	require.NoError(t, user.SetPassword(test.GoodPassword, 10, 10))
+
+	ds.UserByEmailFunc = func(ctx context.Context, email string) (*fleet.User, error) {
+		return user, nil
+	}
+	ds.UserTOTPFunc = func(ctx context.Context, userID uint) (*fleet.UserTOTP, error) {
+		return nil, &mock.Error{Message: "not found"}
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{PasswordPolicy: fleet.PasswordPolicy{MaxAgeDays: 90}}, nil
+	}
+	var setAt time.Time
+	ds.ListPasswordHistoryFunc = func(ctx context.Context, userID uint, limit int) ([]*fleet.PasswordHistoryEntry, error) {
+		require.Equal(t, user.ID, userID)
+		return []*fleet.PasswordHistoryEntry{{UserID: userID, Password: user.Password, Salt: user.Salt, CreatedAt: setAt}}, nil
+	}
+	ds.SaveUserFunc = func(ctx context.Context, u *fleet.User) error {
+		return nil
+	}
+	ds.NewSessionFunc = func(ctx context.Context, userID uint, sessionKey string) (*fleet.Session, error) {
+		return &fleet.Session{UserID: userID, Key: sessionKey}, nil
+	}
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+
+	ctx := context.Background()
+	setAt = time.Now().AddDate(0, 0, -30)
+	loggedIn, _, err := svc.Login(ctx, user.Email, test.GoodPassword)
+	require.NoError(t, err)
+	require.False(t, loggedIn.AdminForcedPasswordReset)
+	require.False(t, ds.SaveUserFuncInvoked)
+
+	// the expired password must be reset, the user still gets a session to do so
+	setAt = time.Now().AddDate(0, 0, -91)
+	loggedIn, session, err := svc.Login(ctx, user.Email, test.GoodPassword)
+	require.NoError(t, err)
+	require.NotNil(t, session)
+	require.True(t, loggedIn.AdminForcedPasswordReset)
+	require.True(t, ds.SaveUserFuncInvoked)
+}
+
 func TestGetSessionByKey(t *testing.T) {
 	ds := new(mock.Store)
 	svc := newTestService(t, ds, nil, nil)
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/WatchBeam/clock"
 	eeservice "github.com/fleetdm/fleet/v4/ee/server/service"
@@ -49,9 +50,13 @@ func newTestServiceWithConfig(t *testing.T, ds fleet.Datastore, fleetConfig conf
 	var ssoStore sso.SessionStore
 
 	var (
-		failingPolicySet  fleet.FailingPolicySet  = NewMemFailingPolicySet()
-		enrollHostLimiter fleet.EnrollHostLimiter = nopEnrollHostLimiter{}
+		failingPolicySet  fleet.FailingPolicySet   = NewMemFailingPolicySet()
+		passingPolicySet  fleet.FailingPolicySet   = NewMemFailingPolicySet()
+		enrollHostLimiter fleet.EnrollHostLimiter  = nopEnrollHostLimiter{}
+		loginAttempts     fleet.LoginAttemptsStore = NewMemLoginAttemptsStore()
 		is                fleet.InstallerStore
+		activityArchive   fleet.ActivityArchiveStore
+		liveQueryExport   fleet.LiveQueryExportStore
 	)
 	var c clock.Clock = clock.C
 	if len(opts) > 0 {
@@ -82,15 +87,23 @@ func newTestServiceWithConfig(t *testing.T, ds fleet.Datastore, fleetConfig conf
 		if opts[0].FailingPolicySet != nil {
 			failingPolicySet = opts[0].FailingPolicySet
 		}
+		if opts[0].PassingPolicySet != nil {
+			passingPolicySet = opts[0].PassingPolicySet
+		}
 		if opts[0].EnrollHostLimiter != nil {
 			enrollHostLimiter = opts[0].EnrollHostLimiter
 		}
+		if opts[0].LoginAttempts != nil {
+			loginAttempts = opts[0].LoginAttempts
+		}
 
 		// allow to explicitly set installer store to nil
 		is = opts[0].Is
+		activityArchive = opts[0].ActivityArchive
+		liveQueryExport = opts[0].LiveQueryExport
 	}
 
-	svc, err := NewService(context.Background(), ds, task, rs, logger, osqlogger, fleetConfig, mailer, c, ssoStore, lq, ds, is, *license, failingPolicySet, &fleet.NoOpGeoIP{}, enrollHostLimiter)
+	svc, err := NewService(context.Background(), ds, task, rs, logger, osqlogger, fleetConfig, mailer, c, ssoStore, lq, ds, is, activityArchive, liveQueryExport, *license, failingPolicySet, passingPolicySet, &fleet.NoOpGeoIP{}, enrollHostLimiter, loginAttempts)
 	if err != nil {
 		panic(err)
 	}
@@ -175,10 +188,14 @@ type TestServerOpts struct {
 	Lq                  fleet.LiveQueryStore
 	Pool                fleet.RedisPool
 	FailingPolicySet    fleet.FailingPolicySet
+	PassingPolicySet    fleet.FailingPolicySet
 	Clock               clock.Clock
 	Task                *async.Task
 	EnrollHostLimiter   fleet.EnrollHostLimiter
+	LoginAttempts       fleet.LoginAttemptsStore
 	Is                  fleet.InstallerStore
+	ActivityArchive     fleet.ActivityArchiveStore
+	LiveQueryExport     fleet.LiveQueryExportStore
 	FleetConfig         *config.FleetConfig
 }
 
@@ -279,6 +296,41 @@ func testStdoutPluginConfig() config.FleetConfig {
 	return c
 }
 
+func testSplunkPluginConfig() config.FleetConfig {
+	c := config.TestConfig()
+	c.Osquery.ResultLogPlugin = "splunk"
+	c.Osquery.StatusLogPlugin = "splunk"
+	c.Splunk = config.SplunkConfig{
+		URL:              "https://splunk.example.com:8088",
+		Token:            "token",
+		Index:            "osquery",
+		StatusSourcetype: "osquery:status",
+		ResultSourcetype: "osquery:result",
+	}
+	return c
+}
+
+func testSyslogPluginConfig() config.FleetConfig {
+	c := config.TestConfig()
+	c.Osquery.ResultLogPlugin = "syslog"
+	c.Osquery.StatusLogPlugin = "syslog"
+	c.Syslog = config.SyslogConfig{
+		Address:  "syslog.example.com:6514",
+		Facility: "local0",
+		AppName:  "fleet",
+		TLS:      true,
+		TLSKey:   "/path/to/key.pem",
+	}
+	return c
+}
+
+func testMultiplePluginsConfig() config.FleetConfig {
+	c := testKinesisPluginConfig()
+	c.Osquery.StatusLogPlugin = "kinesis,stdout"
+	c.Osquery.ResultLogPlugin = "stdout"
+	return c
+}
+
 func testUnrecognizedPluginConfig() config.FleetConfig {
 	c := config.TestConfig()
 	c.Osquery = config.OsqueryConfig{ResultLogPlugin: "bar", StatusLogPlugin: "bar"}
@@ -381,6 +433,71 @@ func (m *memFailingPolicySet) ListSets() ([]uint, error) {
 	return policyIDs, nil
 }
 
+type memLoginAttemptsStore struct {
+	mu     sync.Mutex
+	counts map[string]memLoginAttempts
+	locks  map[string]time.Time
+}
+
+type memLoginAttempts struct {
+	count   int
+	expires time.Time
+}
+
+var _ fleet.LoginAttemptsStore = (*memLoginAttemptsStore)(nil)
+
+func NewMemLoginAttemptsStore() *memLoginAttemptsStore {
+	return &memLoginAttemptsStore{
+		counts: make(map[string]memLoginAttempts),
+		locks:  make(map[string]time.Time),
+	}
+}
+
+func (m *memLoginAttemptsStore) IncrFailedLogins(ctx context.Context, email string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts := m.counts[email]
+	if time.Now().After(attempts.expires) {
+		attempts = memLoginAttempts{expires: time.Now().Add(window)}
+	}
+	attempts.count++
+	m.counts[email] = attempts
+	return attempts.count, nil
+}
+
+func (m *memLoginAttemptsStore) ResetFailedLogins(ctx context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counts, email)
+	return nil
+}
+
+func (m *memLoginAttemptsStore) LockAccount(ctx context.Context, email string, duration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.locks[email] = time.Now().Add(duration)
+	return nil
+}
+
+func (m *memLoginAttemptsStore) IsAccountLocked(ctx context.Context, email string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return time.Now().Before(m.locks[email]), nil
+}
+
+func (m *memLoginAttemptsStore) UnlockAccount(ctx context.Context, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counts, email)
+	delete(m.locks, email)
+	return nil
+}
+
 type nopEnrollHostLimiter struct{}
 
 func (nopEnrollHostLimiter) CanEnrollNewHost(ctx context.Context) (bool, error) {
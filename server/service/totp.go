@@ -0,0 +1,519 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/contexts/logging"
+	"github.com/fleetdm/fleet/v4/server/contexts/viewer"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/totp"
+	"github.com/go-kit/kit/log/level"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// Login with a two-factor authentication code
+////////////////////////////////////////////////////////////////////////////////
+
+type loginTOTPRequest struct {
+	TOTPToken string `json:"totp_token"`
+	Code      string `json:"code"`
+}
+
+func loginTOTPEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*loginTOTPRequest)
+	user, session, recoveryCodes, err := svc.LoginTOTP(ctx, req.TOTPToken, req.Code)
+	if err != nil {
+		return loginResponse{Err: err}, nil
+	}
+	resp := makeLoginResponse(ctx, svc, user, session)
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+func (svc *Service) LoginTOTP(ctx context.Context, token, code string) (*fleet.User, *fleet.Session, []string, error) {
+	// skipauth: No user context available yet to authorize against, the user
+	// is authenticated by the login challenge and the code.
+	svc.authz.SkipAuthorization(ctx)
+
+	logging.WithLevel(logging.WithNoUser(ctx), level.Info)
+
+	// As for the password login, a failure for any reason takes ~1s.
+	var err error
+	defer func(start time.Time) {
+		if err != nil {
+			time.Sleep(time.Until(start.Add(1 * time.Second)))
+		}
+	}(time.Now())
+
+	user, err := svc.totpLoginChallengeUser(ctx, token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The invalid codes count as failed logins, the account can be locked
+	// between the password and the code.
+	var locked bool
+	locked, err = svc.isAccountLocked(ctx, user.Email)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if locked {
+		err = fleet.NewAuthFailedError("account locked")
+		return nil, nil, nil, err
+	}
+
+	userTOTP, err := svc.ds.UserTOTP(ctx, user.ID)
+	if err != nil {
+		if fleet.IsNotFound(err) {
+			return nil, nil, nil, fleet.NewAuthFailedError("two-factor enrollment not started")
+		}
+		return nil, nil, nil, ctxerr.Wrap(ctx, err, "get user totp")
+	}
+
+	// The recovery codes are only valid once the enrollment is completed.
+	var ok bool
+	ok, err = svc.validateTOTPCode(ctx, userTOTP, code, userTOTP.Enabled)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !ok {
+		err = svc.ds.IncrementTOTPLoginChallengeAttempts(ctx, token)
+		if err != nil {
+			return nil, nil, nil, ctxerr.Wrap(ctx, err, "increment totp login challenge attempts")
+		}
+		svc.newFailedLoginActivity(ctx, user, "invalid two-factor code")
+		svc.recordFailedLogin(ctx, user.Email, user)
+		err = fleet.NewAuthFailedError("invalid two-factor code")
+		return nil, nil, nil, err
+	}
+	if err = svc.resetFailedLogins(ctx, user.Email); err != nil {
+		return nil, nil, nil, err
+	}
+
+	var recoveryCodes []string
+	if !userTOTP.Enabled {
+		// the user completes the enrollment required by the enforced policy
+		recoveryCodes, err = svc.enableTOTP(ctx, userTOTP)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err = svc.ds.DeleteTOTPLoginChallenge(ctx, token); err != nil {
+		return nil, nil, nil, ctxerr.Wrap(ctx, err, "delete totp login challenge")
+	}
+
+	session, err := svc.makeSession(ctx, user.ID)
+	if err != nil {
+		return nil, nil, nil, fleet.NewAuthFailedError(err.Error())
+	}
+	if err = svc.newLoggedInActivity(ctx, user, "two_factor"); err != nil {
+		return nil, nil, nil, err
+	}
+	return user, session, recoveryCodes, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Enroll in two-factor authentication during the login
+////////////////////////////////////////////////////////////////////////////////
+
+type enrollTOTPForLoginRequest struct {
+	TOTPToken string `json:"totp_token"`
+}
+
+type enrollTOTPResponse struct {
+	*fleet.TOTPEnrollment
+	Err error `json:"error,omitempty"`
+}
+
+func (r enrollTOTPResponse) error() error { return r.Err }
+
+func enrollTOTPForLoginEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*enrollTOTPForLoginRequest)
+	enrollment, err := svc.EnrollTOTPForLogin(ctx, req.TOTPToken)
+	if err != nil {
+		return enrollTOTPResponse{Err: err}, nil
+	}
+	return enrollTOTPResponse{TOTPEnrollment: enrollment}, nil
+}
+
+func (svc *Service) EnrollTOTPForLogin(ctx context.Context, token string) (*fleet.TOTPEnrollment, error) {
+	// skipauth: No user context available yet to authorize against, the user
+	// is authenticated by the login challenge.
+	svc.authz.SkipAuthorization(ctx)
+
+	user, err := svc.totpLoginChallengeUser(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// A valid password must never be enough to replace the second factor of a
+	// user.
+	userTOTP, err := svc.ds.UserTOTP(ctx, user.ID)
+	if err != nil && !fleet.IsNotFound(err) {
+		return nil, ctxerr.Wrap(ctx, err, "get user totp")
+	}
+	if userTOTP != nil && userTOTP.Enabled {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("totp_token", "two-factor authentication is already enabled"))
+	}
+
+	return svc.newTOTPEnrollment(ctx, user)
+}
+
+// totpLoginChallengeUser returns the user of a valid two-factor login
+// challenge.
+func (svc *Service) totpLoginChallengeUser(ctx context.Context, token string) (*fleet.User, error) {
+	if token == "" {
+		return nil, fleet.NewAuthFailedError("missing two-factor token")
+	}
+	challenge, err := svc.ds.TOTPLoginChallengeByToken(ctx, token)
+	if err != nil {
+		if fleet.IsNotFound(err) {
+			return nil, fleet.NewAuthFailedError("invalid two-factor token")
+		}
+		return nil, ctxerr.Wrap(ctx, err, "get totp login challenge")
+	}
+	if challenge.IsExpired(svc.clock.Now()) {
+		return nil, fleet.NewAuthFailedError("two-factor token expired")
+	}
+
+	user, err := svc.ds.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, fleet.NewAuthFailedError(err.Error())
+	}
+	if user.SSOEnabled {
+		return nil, fleet.NewAuthFailedError("password login disabled for sso users")
+	}
+	if user.Disabled {
+		return nil, fleet.NewAuthFailedError("user is disabled")
+	}
+	return user, nil
+}
+
+// checkTOTPRequired is called by the password login once the password is
+// verified. It returns a TOTPRequiredError with a new login challenge if the
+// user must complete two-factor authentication before a session is issued.
+func (svc *Service) checkTOTPRequired(ctx context.Context, user *fleet.User) error {
+	if !svc.config.Auth.TOTPEnabled {
+		// the two-factor login is not supported by all the clients yet, the
+		// password is enough until it is enabled.
+		return nil
+	}
+
+	enabled := false
+	userTOTP, err := svc.ds.UserTOTP(ctx, user.ID)
+	switch {
+	case err == nil:
+		enabled = userTOTP.Enabled
+	case !fleet.IsNotFound(err):
+		return ctxerr.Wrap(ctx, err, "get user totp")
+	}
+
+	if !enabled {
+		appConfig, err := svc.ds.AppConfig(ctx)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "get app config")
+		}
+		if !appConfig.TwoFactorAuthSettings.Enforced {
+			return nil
+		}
+	}
+
+	key := make([]byte, svc.config.Session.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return ctxerr.Wrap(ctx, err, "generate totp login challenge token")
+	}
+	challenge := &fleet.TOTPLoginChallenge{
+		Token:  base32.StdEncoding.EncodeToString(key),
+		UserID: user.ID,
+	}
+	if err := svc.ds.NewTOTPLoginChallenge(ctx, challenge); err != nil {
+		return ctxerr.Wrap(ctx, err, "create totp login challenge")
+	}
+	return &fleet.TOTPRequiredError{Token: challenge.Token, EnrollmentRequired: !enabled}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Enroll in two-factor authentication
+////////////////////////////////////////////////////////////////////////////////
+
+func enrollTOTPEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	enrollment, err := svc.EnrollTOTP(ctx)
+	if err != nil {
+		return enrollTOTPResponse{Err: err}, nil
+	}
+	return enrollTOTPResponse{TOTPEnrollment: enrollment}, nil
+}
+
+func (svc *Service) EnrollTOTP(ctx context.Context) (*fleet.TOTPEnrollment, error) {
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, fleet.ErrNoContext
+	}
+	if err := svc.authz.Authorize(ctx, vc.User, fleet.ActionChangePassword); err != nil {
+		return nil, err
+	}
+	if err := svc.checkTOTPEnabled(ctx); err != nil {
+		return nil, err
+	}
+	if vc.User.SSOEnabled {
+		return nil, ctxerr.New(ctx, "two-factor authentication for single sign on user not allowed")
+	}
+
+	userTOTP, err := svc.ds.UserTOTP(ctx, vc.User.ID)
+	if err != nil && !fleet.IsNotFound(err) {
+		return nil, ctxerr.Wrap(ctx, err, "get user totp")
+	}
+	if userTOTP != nil && userTOTP.Enabled {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("totp", "two-factor authentication is already enabled"))
+	}
+
+	return svc.newTOTPEnrollment(ctx, vc.User)
+}
+
+// checkTOTPEnabled returns an error if the two-factor authentication is not
+// enabled in the server configuration, the users can't enroll until then.
+func (svc *Service) checkTOTPEnabled(ctx context.Context) error {
+	if !svc.config.Auth.TOTPEnabled {
+		return ctxerr.Wrap(ctx, &badRequestError{message: "two-factor authentication is not enabled on this server"})
+	}
+	return nil
+}
+
+// newTOTPEnrollment generates a new secret for the user. Two-factor
+// authentication is only enabled once a code generated with that secret is
+// verified.
+func (svc *Service) newTOTPEnrollment(ctx context.Context, user *fleet.User) (*fleet.TOTPEnrollment, error) {
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "get app config")
+	}
+	issuer := appConfig.OrgInfo.OrgName
+	if issuer == "" {
+		issuer = "Fleet"
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "generate totp secret")
+	}
+	if err := svc.ds.SaveUserTOTP(ctx, &fleet.UserTOTP{UserID: user.ID, Secret: secret}); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "save user totp")
+	}
+	return &fleet.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(issuer, user.Email, secret),
+	}, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Verify the two-factor authentication enrollment
+////////////////////////////////////////////////////////////////////////////////
+
+type verifyTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+type verifyTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Err           error    `json:"error,omitempty"`
+}
+
+func (r verifyTOTPResponse) error() error { return r.Err }
+
+func verifyTOTPEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*verifyTOTPRequest)
+	recoveryCodes, err := svc.VerifyTOTP(ctx, req.Code)
+	if err != nil {
+		return verifyTOTPResponse{Err: err}, nil
+	}
+	return verifyTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (svc *Service) VerifyTOTP(ctx context.Context, code string) ([]string, error) {
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return nil, fleet.ErrNoContext
+	}
+	if err := svc.authz.Authorize(ctx, vc.User, fleet.ActionChangePassword); err != nil {
+		return nil, err
+	}
+	if err := svc.checkTOTPEnabled(ctx); err != nil {
+		return nil, err
+	}
+
+	userTOTP, err := svc.ds.UserTOTP(ctx, vc.User.ID)
+	if err != nil {
+		if fleet.IsNotFound(err) {
+			return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("code", "two-factor enrollment not started"))
+		}
+		return nil, ctxerr.Wrap(ctx, err, "get user totp")
+	}
+	if userTOTP.Enabled {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("code", "two-factor authentication is already enabled"))
+	}
+
+	ok, err = svc.validateTOTPCode(ctx, userTOTP, code, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("code", "invalid two-factor code"))
+	}
+	return svc.enableTOTP(ctx, userTOTP)
+}
+
+// validateTOTPCode returns true if the code is valid for the user. If
+// allowRecovery is true, the code can also be one of the recovery codes of
+// the user, which is then consumed.
+func (svc *Service) validateTOTPCode(ctx context.Context, userTOTP *fleet.UserTOTP, code string, allowRecovery bool) (bool, error) {
+	step, ok, err := totp.Validate(userTOTP.Secret, code, svc.clock.Now(), userTOTP.LastStep)
+	if err != nil {
+		return false, ctxerr.Wrap(ctx, err, "validate totp code")
+	}
+	if ok {
+		userTOTP.LastStep = step
+		if err := svc.ds.SaveUserTOTP(ctx, userTOTP); err != nil {
+			return false, ctxerr.Wrap(ctx, err, "save user totp")
+		}
+		return true, nil
+	}
+
+	if !allowRecovery || code == "" {
+		return false, nil
+	}
+	err = svc.ds.DeleteUserTOTPRecoveryCode(ctx, userTOTP.UserID, fleet.HashTOTPRecoveryCode(code))
+	if err != nil {
+		if fleet.IsNotFound(err) {
+			return false, nil
+		}
+		return false, ctxerr.Wrap(ctx, err, "use totp recovery code")
+	}
+	return true, nil
+}
+
+// enableTOTP enables the two-factor authentication of the user once the
+// enrollment is verified and returns the new recovery codes of the user. The
+// recovery codes are only returned once, only their hash is stored.
+func (svc *Service) enableTOTP(ctx context.Context, userTOTP *fleet.UserTOTP) ([]string, error) {
+	codes := make([]string, 0, fleet.TOTPRecoveryCodesCount)
+	hashes := make([]string, 0, fleet.TOTPRecoveryCodesCount)
+	for i := 0; i < fleet.TOTPRecoveryCodesCount; i++ {
+		code, err := generateTOTPRecoveryCode()
+		if err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "generate totp recovery code")
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, fleet.HashTOTPRecoveryCode(code))
+	}
+	if err := svc.ds.ReplaceUserTOTPRecoveryCodes(ctx, userTOTP.UserID, hashes); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "save totp recovery codes")
+	}
+
+	userTOTP.Enabled = true
+	if err := svc.ds.SaveUserTOTP(ctx, userTOTP); err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "enable user totp")
+	}
+	return codes, nil
+}
+
+// generateTOTPRecoveryCode returns a random recovery code formatted as
+// "xxxxx-xxxxx".
+func generateTOTPRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Delete the two-factor authentication of a user
+////////////////////////////////////////////////////////////////////////////////
+
+// verifyUserTOTPOptOut returns an error unless the password or the two-factor
+// code (including a recovery code) of the user is valid.
+func (svc *Service) verifyUserTOTPOptOut(ctx context.Context, user *fleet.User, password, code string) error {
+	if password != "" && user.ValidatePassword(password) == nil {
+		return nil
+	}
+	if code != "" {
+		userTOTP, err := svc.ds.UserTOTP(ctx, user.ID)
+		if err != nil && !fleet.IsNotFound(err) {
+			return ctxerr.Wrap(ctx, err, "get user totp")
+		}
+		if userTOTP != nil && userTOTP.Enabled {
+			ok, err := svc.validateTOTPCode(ctx, userTOTP, code, true)
+			if err != nil {
+				return err
+			}
+			if ok {
+				return nil
+			}
+		}
+	}
+	return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("password", "a valid password or two-factor code is required"))
+}
+
+type deleteUserTOTPRequest struct {
+	ID       uint   `url:"id"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type deleteUserTOTPResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r deleteUserTOTPResponse) error() error { return r.Err }
+
+func deleteUserTOTPEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*deleteUserTOTPRequest)
+	if err := svc.DeleteUserTOTP(ctx, req.ID, req.Password, req.Code); err != nil {
+		return deleteUserTOTPResponse{Err: err}, nil
+	}
+	return deleteUserTOTPResponse{}, nil
+}
+
+func (svc *Service) DeleteUserTOTP(ctx context.Context, userID uint, password, code string) error {
+	if err := svc.authz.Authorize(ctx, &fleet.User{ID: userID}, fleet.ActionChangePassword); err != nil {
+		return err
+	}
+
+	vc, ok := viewer.FromContext(ctx)
+	if !ok {
+		return fleet.ErrNoContext
+	}
+	if vc.UserID() == userID {
+		// users can't opt out when two-factor authentication is enforced, the
+		// admins can still reset it (e.g. when a user lost their device) and
+		// the user then enrolls again on their next login.
+		appConfig, err := svc.ds.AppConfig(ctx)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "get app config")
+		}
+		if appConfig.TwoFactorAuthSettings.Enforced {
+			return fleet.NewPermissionError("two-factor authentication is enforced")
+		}
+
+		// a session is not enough to remove the second factor, the user must
+		// prove that they know the password or still have the device.
+		if err := svc.verifyUserTOTPOptOut(ctx, vc.User, password, code); err != nil {
+			return err
+		}
+	}
+
+	if _, err := svc.ds.UserByID(ctx, userID); err != nil {
+		return ctxerr.Wrap(ctx, err, "get user")
+	}
+	if err := svc.ds.DeleteUserTOTP(ctx, userID); err != nil {
+		return ctxerr.Wrap(ctx, err, "delete user totp")
+	}
+	return nil
+}
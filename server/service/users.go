@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/fleetdm/fleet/v4/server"
@@ -65,7 +67,14 @@ func (svc *Service) CreateUser(ctx context.Context, p fleet.UserPayload) (*fleet
 		p.AdminForcedPasswordReset = ptr.Bool(true)
 	}
 
-	return svc.NewUser(ctx, p)
+	user, err := svc.NewUser(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if err := svc.newCreatedUserActivity(ctx, authz.UserFromContext(ctx), user); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -108,6 +117,11 @@ func (svc *Service) CreateUserFromInvite(ctx context.Context, p fleet.UserPayloa
 	if err != nil {
 		return nil, err
 	}
+
+	// there is no user in the context, the new user is the actor
+	if err := svc.newCreatedUserActivity(ctx, user, user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
@@ -145,8 +159,10 @@ func (svc *Service) ListUsers(ctx context.Context, opt fleet.UserListOptions) ([
 	if opt.TeamID != 0 {
 		user.Teams = []fleet.UserTeam{{Team: fleet.Team{ID: opt.TeamID}}}
 	}
-	if err := svc.authz.Authorize(ctx, user, fleet.ActionRead); err != nil {
-		return nil, err
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		if err := svc.authz.Authorize(ctx, user, fleet.ActionRead); err != nil {
+			return nil, err
+		}
 	}
 
 	return svc.ds.ListUsers(ctx, opt)
@@ -238,8 +254,10 @@ func (svc *Service) User(ctx context.Context, id uint) (*fleet.User, error) {
 		return nil, ctxerr.Wrap(ctx, err)
 	}
 
-	if err := svc.authz.Authorize(ctx, user, fleet.ActionRead); err != nil {
-		return nil, err
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		if err := svc.authz.Authorize(ctx, user, fleet.ActionRead); err != nil {
+			return nil, err
+		}
 	}
 	return user, nil
 }
@@ -280,6 +298,7 @@ func (svc *Service) ModifyUser(ctx context.Context, userID uint, p fleet.UserPay
 	if err := svc.authz.Authorize(ctx, user, fleet.ActionWrite); err != nil {
 		return nil, err
 	}
+	before := newUserAuditFields(user)
 
 	vc, ok := viewer.FromContext(ctx)
 	if !ok {
@@ -300,8 +319,8 @@ func (svc *Service) ModifyUser(ctx context.Context, userID uint, p fleet.UserPay
 		if err := svc.authz.Authorize(ctx, user, fleet.ActionChangePassword); err != nil {
 			return nil, err
 		}
-		if err := fleet.ValidatePasswordRequirements(*p.NewPassword); err != nil {
-			return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("new_password", err.Error()))
+		if err := svc.checkPasswordPolicy(ctx, user, *p.NewPassword, "new_password"); err != nil {
+			return nil, err
 		}
 		if ownUser {
 			// when changing one's own password, user cannot reuse the same password
@@ -375,6 +394,9 @@ func (svc *Service) ModifyUser(ctx context.Context, userID uint, p fleet.UserPay
 		return nil, err
 	}
 
+	if err := svc.newEditedUserActivity(ctx, before, user, p.NewPassword != nil); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
@@ -407,10 +429,30 @@ func (svc *Service) DeleteUser(ctx context.Context, id uint) error {
 		setAuthCheckedOnPreAuthErr(ctx)
 		return ctxerr.Wrap(ctx, err)
 	}
-	if err := svc.authz.Authorize(ctx, user, fleet.ActionWrite); err != nil {
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		if err := svc.authz.Authorize(ctx, user, fleet.ActionWrite); err != nil {
+			return err
+		}
+	}
+	if err := svc.ds.DeleteUser(ctx, id); err != nil {
 		return err
 	}
-	return svc.ds.DeleteUser(ctx, id)
+
+	actor := authz.UserFromContext(ctx)
+	if actor != nil && actor.ID == user.ID {
+		// the deleted user cannot be referenced as the actor anymore, record
+		// its name only
+		actor = &fleet.User{Name: actor.Name}
+	}
+	if err := svc.ds.NewActivity(
+		ctx,
+		actor,
+		fleet.ActivityTypeDeletedUser,
+		&map[string]interface{}{"user_id": user.ID, "user_email": user.Email, "before": newUserAuditFields(user)},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create deleted user activity")
+	}
+	return nil
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -450,11 +492,15 @@ func (svc *Service) RequirePasswordReset(ctx context.Context, uid uint, require
 	if user.SSOEnabled {
 		return nil, ctxerr.New(ctx, "password reset for single sign on user not allowed")
 	}
+	before := newUserAuditFields(user)
 	// Require reset on next login
 	user.AdminForcedPasswordReset = require
 	if err := svc.saveUser(ctx, user); err != nil {
 		return nil, ctxerr.Wrap(ctx, err, "saving user")
 	}
+	if err := svc.newEditedUserActivity(ctx, before, user, false); err != nil {
+		return nil, err
+	}
 
 	if require {
 		// Clear all of the existing sessions
@@ -466,6 +512,52 @@ func (svc *Service) RequirePasswordReset(ctx context.Context, uid uint, require
 	return user, nil
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// Unlock User
+////////////////////////////////////////////////////////////////////////////////
+
+type unlockUserRequest struct {
+	ID uint `url:"id"`
+}
+
+type unlockUserResponse struct {
+	Err error `json:"error,omitempty"`
+}
+
+func (r unlockUserResponse) error() error { return r.Err }
+
+func unlockUserEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*unlockUserRequest)
+	if err := svc.UnlockUser(ctx, req.ID); err != nil {
+		return unlockUserResponse{Err: err}, nil
+	}
+	return unlockUserResponse{}, nil
+}
+
+func (svc *Service) UnlockUser(ctx context.Context, id uint) error {
+	if err := svc.authz.Authorize(ctx, &fleet.User{ID: id}, fleet.ActionWrite); err != nil {
+		return err
+	}
+
+	user, err := svc.ds.UserByID(ctx, id)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "loading user by ID")
+	}
+	if err := svc.unlockAccount(ctx, user.Email); err != nil {
+		return err
+	}
+
+	if err := svc.ds.NewActivity(
+		ctx,
+		authz.UserFromContext(ctx),
+		fleet.ActivityTypeUnlockedUser,
+		&map[string]interface{}{"user_id": user.ID, "user_email": user.Email},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create unlocked user activity")
+	}
+	return nil
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // Change Password
 ////////////////////////////////////////////////////////////////////////////////
@@ -503,8 +595,8 @@ func (svc *Service) ChangePassword(ctx context.Context, oldPass, newPass string)
 	if newPass == "" {
 		return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("new_password", "New password cannot be empty"))
 	}
-	if err := fleet.ValidatePasswordRequirements(newPass); err != nil {
-		return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("new_password", err.Error()))
+	if err := svc.checkPasswordPolicy(ctx, vc.User, newPass, "new_password"); err != nil {
+		return err
 	}
 	if vc.User.SSOEnabled {
 		return ctxerr.New(ctx, "change password for single sign on user not allowed")
@@ -519,6 +611,9 @@ func (svc *Service) ChangePassword(ctx context.Context, oldPass, newPass string)
 	if err := svc.setNewPassword(ctx, vc.User, newPass); err != nil {
 		return ctxerr.Wrap(ctx, err, "setting new password")
 	}
+	if err := svc.newEditedUserActivity(ctx, newUserAuditFields(vc.User), vc.User, true); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -599,8 +694,10 @@ func deleteSessionsForUserEndpoint(ctx context.Context, request interface{}, svc
 }
 
 func (svc *Service) DeleteSessionsForUser(ctx context.Context, id uint) error {
-	if err := svc.authz.Authorize(ctx, &fleet.Session{UserID: id}, fleet.ActionWrite); err != nil {
-		return err
+	if !svc.authz.IsAuthenticatedWith(ctx, authz_ctx.AuthnSCIMToken) {
+		if err := svc.authz.Authorize(ctx, &fleet.Session{UserID: id}, fleet.ActionWrite); err != nil {
+			return err
+		}
 	}
 
 	return svc.ds.DestroyAllSessionsForUser(ctx, id)
@@ -809,8 +906,8 @@ func (svc *Service) PerformRequiredPasswordReset(ctx context.Context, password s
 		return nil, fleet.NewInvalidArgumentError("new_password", "Cannot reuse old password")
 	}
 
-	if err := fleet.ValidatePasswordRequirements(password); err != nil {
-		return nil, fleet.NewInvalidArgumentError("new_password", "Password does not meet required criteria")
+	if err := svc.checkPasswordPolicy(ctx, user, password, "new_password"); err != nil {
+		return nil, err
 	}
 
 	user.AdminForcedPasswordReset = false
@@ -840,7 +937,88 @@ func (svc *Service) setNewPassword(ctx context.Context, user *fleet.User, passwo
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "saving changed password")
 	}
+	if err := svc.recordPasswordHistory(ctx, user); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// checkPasswordPolicy validates the new password of the user against the
+// password policy and the previous passwords of the user, if the user already
+// exists. The violations are returned as an invalid argument error for
+// argName.
+func (svc *Service) checkPasswordPolicy(ctx context.Context, user *fleet.User, password, argName string) error {
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	policy := appConfig.PasswordPolicy
+	if err := policy.ValidatePassword(password); err != nil {
+		return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError(argName, err.Error()))
+	}
+
+	if user == nil || user.ID == 0 || policy.HistoryCount <= 0 {
+		return nil
+	}
+	history, err := svc.ds.ListPasswordHistory(ctx, user.ID, policy.HistoryCount)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list password history")
+	}
+	for _, entry := range history {
+		if entry.MatchesPassword(password) {
+			return ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError(argName,
+				fmt.Sprintf("Cannot reuse any of the last %d passwords", policy.HistoryCount)))
+		}
+	}
+	return nil
+}
 
+// recordPasswordHistory records the password that was just set for the user,
+// keeping enough previous passwords to enforce the password policy.
+func (svc *Service) recordPasswordHistory(ctx context.Context, user *fleet.User) error {
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	// the most recent password is always kept, it is used to expire it.
+	keep := appConfig.PasswordPolicy.HistoryCount
+	if keep < 1 {
+		keep = 1
+	}
+	if err := svc.ds.NewPasswordHistory(ctx, user.ID, user.Password, user.Salt, keep); err != nil {
+		return ctxerr.Wrap(ctx, err, "record password history")
+	}
+	return nil
+}
+
+// expirePassword requires the user to reset their password if it is older
+// than the maximum age of the password policy. The users whose password was
+// set before the history was recorded never expire.
+func (svc *Service) expirePassword(ctx context.Context, user *fleet.User) error {
+	if user.SSOEnabled || user.IsAdminForcedPasswordReset() {
+		return nil
+	}
+	appConfig, err := svc.ds.AppConfig(ctx)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get app config")
+	}
+	if appConfig.PasswordPolicy.MaxAgeDays <= 0 {
+		return nil
+	}
+
+	history, err := svc.ds.ListPasswordHistory(ctx, user.ID, 1)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list password history")
+	}
+	if len(history) == 0 || !appConfig.PasswordPolicy.PasswordExpired(history[0].CreatedAt, svc.clock.Now()) {
+		return nil
+	}
+
+	user.AdminForcedPasswordReset = true
+	if err := svc.saveUser(ctx, user); err != nil {
+		return ctxerr.Wrap(ctx, err, "require reset of expired password")
+	}
 	return nil
 }
 
@@ -898,6 +1076,9 @@ func (svc *Service) ResetPassword(ctx context.Context, token, password string) e
 	if err := user.ValidatePassword(password); err == nil {
 		return fleet.NewInvalidArgumentError("new_password", "Cannot reuse old password")
 	}
+	if err := svc.checkPasswordPolicy(ctx, user, password, "new_password"); err != nil {
+		return err
+	}
 
 	// password requirements are validated as part of `setNewPassword``
 	err = svc.setNewPassword(ctx, user, password)
@@ -911,11 +1092,17 @@ func (svc *Service) ResetPassword(ctx context.Context, token, password string) e
 	}
 
 	// Clear sessions so that any other browsers will have to log in with
-	// the new password
+	// the new password. The two-factor authentication of the user is kept,
+	// the new password alone is not enough to log in.
 	if err := svc.ds.DestroyAllSessionsForUser(ctx, user.ID); err != nil {
 		return ctxerr.Wrap(ctx, err, "delete user sessions")
 	}
 
+	// The user proved they own the account, it can be unlocked.
+	if err := svc.unlockAccount(ctx, user.Email); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1004,3 +1191,82 @@ func (svc *Service) ListAvailableTeamsForUser(ctx context.Context, user *fleet.U
 
 	return nil, fleet.ErrMissingLicense
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// User activities
+////////////////////////////////////////////////////////////////////////////////
+
+// userAuditFields are the fields of a user recorded in the details of the user
+// activities. The teams are mapped from the team ID to the role in that team,
+// so that the before/after diff only lists the teams that changed.
+type userAuditFields struct {
+	Name                     string            `json:"name"`
+	Email                    string            `json:"email"`
+	Position                 string            `json:"position"`
+	SSOEnabled               bool              `json:"sso_enabled"`
+	APIOnly                  bool              `json:"api_only"`
+	Disabled                 bool              `json:"disabled"`
+	AdminForcedPasswordReset bool              `json:"force_password_reset"`
+	GlobalRole               *string           `json:"global_role"`
+	Teams                    map[string]string `json:"teams"`
+}
+
+func newUserAuditFields(user *fleet.User) userAuditFields {
+	fields := userAuditFields{
+		Name:                     user.Name,
+		Email:                    user.Email,
+		Position:                 user.Position,
+		SSOEnabled:               user.SSOEnabled,
+		APIOnly:                  user.APIOnly,
+		Disabled:                 user.Disabled,
+		AdminForcedPasswordReset: user.AdminForcedPasswordReset,
+		Teams:                    make(map[string]string, len(user.Teams)),
+	}
+	if user.GlobalRole != nil {
+		fields.GlobalRole = ptr.String(*user.GlobalRole)
+	}
+	for _, team := range user.Teams {
+		fields.Teams[strconv.FormatUint(uint64(team.ID), 10)] = team.Role
+	}
+	return fields
+}
+
+// newCreatedUserActivity records the creation of the user by the actor.
+func (svc *Service) newCreatedUserActivity(ctx context.Context, actor, user *fleet.User) error {
+	if err := svc.ds.NewActivity(
+		ctx,
+		actor,
+		fleet.ActivityTypeCreatedUser,
+		&map[string]interface{}{"user_id": user.ID, "user_email": user.Email, "after": newUserAuditFields(user)},
+	); err != nil {
+		return ctxerr.Wrap(ctx, err, "create created user activity")
+	}
+	return nil
+}
+
+// newEditedUserActivity records the changes made to the user by the user in
+// the context, if any. The password is never recorded, only the fact that it
+// was changed.
+func (svc *Service) newEditedUserActivity(ctx context.Context, before userAuditFields, user *fleet.User, passwordChanged bool) error {
+	changedBefore, changedAfter, err := activityDiff(before, newUserAuditFields(user))
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "diff user changes")
+	}
+	if len(changedAfter) == 0 && !passwordChanged {
+		return nil
+	}
+
+	details := map[string]interface{}{
+		"user_id":    user.ID,
+		"user_email": user.Email,
+		"before":     changedBefore,
+		"after":      changedAfter,
+	}
+	if passwordChanged {
+		details["password_changed"] = true
+	}
+	if err := svc.ds.NewActivity(ctx, authz.UserFromContext(ctx), fleet.ActivityTypeEditedUser, &details); err != nil {
+		return ctxerr.Wrap(ctx, err, "create edited user activity")
+	}
+	return nil
+}
@@ -21,6 +21,9 @@ import (
 
 func TestUserAuth(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	ds.InviteByTokenFunc = func(ctx context.Context, token string) (*fleet.Invite, error) {
@@ -42,6 +45,12 @@ func TestUserAuth(t *testing.T) {
 	ds.InviteByEmailFunc = func(ctx context.Context, email string) (*fleet.Invite, error) {
 		return nil, errors.New("AA")
 	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+	ds.NewPasswordHistoryFunc = func(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+		return nil
+	}
 
 	userTeamMaintainerID := uint(999)
 	userGlobalMaintainerID := uint(888)
@@ -427,6 +436,9 @@ func TestModifyUserEmail(t *testing.T) {
 	err := user.SetPassword(test.GoodPassword, 10, 10)
 	require.NoError(t, err)
 	ms := new(mock.Store)
+	ms.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	ms.PendingEmailChangeFunc = func(ctx context.Context, id uint, em, tk string) error {
 		return nil
 	}
@@ -480,6 +492,9 @@ func TestModifyUserEmailNoPassword(t *testing.T) {
 	err := user.SetPassword(test.GoodPassword, 10, 10)
 	require.NoError(t, err)
 	ms := new(mock.Store)
+	ms.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	ms.PendingEmailChangeFunc = func(ctx context.Context, id uint, em, tk string) error {
 		return nil
 	}
@@ -529,6 +544,9 @@ func TestModifyAdminUserEmailNoPassword(t *testing.T) {
 	err := user.SetPassword(test.GoodPassword, 10, 10)
 	require.NoError(t, err)
 	ms := new(mock.Store)
+	ms.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	ms.PendingEmailChangeFunc = func(ctx context.Context, id uint, em, tk string) error {
 		return nil
 	}
@@ -579,6 +597,9 @@ func TestModifyAdminUserEmailPassword(t *testing.T) {
 	err := user.SetPassword(test.GoodPassword, 10, 10)
 	require.NoError(t, err)
 	ms := new(mock.Store)
+	ms.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	ms.PendingEmailChangeFunc = func(ctx context.Context, id uint, em, tk string) error {
 		return nil
 	}
@@ -773,6 +794,52 @@ func testUsersRequirePasswordReset(t *testing.T, ds *mysql.Datastore) {
 	}
 }
 
+func TestChangePasswordPolicy(t *testing.T) {
+	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
+	svc := newTestService(t, ds, nil, nil)
+
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{PasswordPolicy: fleet.PasswordPolicy{RequireUppercase: true, HistoryCount: 2}}, nil
+	}
+	ds.SaveUserFunc = func(ctx context.Context, user *fleet.User) error {
+		return nil
+	}
+	var history []*fleet.PasswordHistoryEntry
+	ds.NewPasswordHistoryFunc = func(ctx context.Context, userID uint, password []byte, salt string, keep int) error {
+		require.Equal(t, 2, keep)
+		history = append([]*fleet.PasswordHistoryEntry{{UserID: userID, Password: password, Salt: salt}}, history...)
+		if len(history) > keep {
+			history = history[:keep]
+		}
+		return nil
+	}
+	ds.ListPasswordHistoryFunc = func(ctx context.Context, userID uint, limit int) ([]*fleet.PasswordHistoryEntry, error) {
+		if len(history) > limit {
+			return history[:limit], nil
+		}
+		return history, nil
+	}
+
+	user := &fleet.User{ID: 1, Email: "admin@example.com", GlobalRole: ptr.String(fleet.RoleAdmin)}
+	require.NoError(t, user.SetPassword("Password123#", 10, 10))
+	require.NoError(t, ds.NewPasswordHistory(context.Background(), user.ID, user.Password, user.Salt, 2))
+	ctx := viewer.NewContext(context.Background(), viewer.Viewer{User: user})
+
+	// the policy applies on top of the base requirements
+	err := svc.ChangePassword(ctx, "Password123#", "password456#")
+	require.ErrorContains(t, err, "Password must contain an uppercase letter")
+	require.NoError(t, svc.ChangePassword(ctx, "Password123#", "Password456#"))
+
+	// the passwords in the history cannot be reused
+	err = svc.ChangePassword(ctx, "Password456#", "Password123#")
+	require.ErrorContains(t, err, "Cannot reuse any of the last 2 passwords")
+	require.NoError(t, svc.ChangePassword(ctx, "Password456#", "Password789#"))
+	require.NoError(t, svc.ChangePassword(ctx, "Password789#", "Password123#"))
+}
+
 func TestPerformRequiredPasswordReset(t *testing.T) {
 	ds := mysql.CreateMySQLDS(t)
 
@@ -1268,6 +1335,9 @@ func TestIsAdminOfTheModifiedTeams(t *testing.T) {
 // that an admin cannot add itself to another team.
 func TestTeamAdminAddRoleOtherTeam(t *testing.T) {
 	ds := new(mock.Store)
+	ds.NewActivityFunc = func(ctx context.Context, user *fleet.User, activityType string, details *map[string]interface{}) error {
+		return nil
+	}
 	svc := newTestService(t, ds, nil, nil)
 
 	// adminTeam2 is a team admin of team with ID=2.
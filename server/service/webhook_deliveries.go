@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/worker"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// List webhook deliveries
+////////////////////////////////////////////////////////////////////////////////
+
+type listWebhookDeliveriesRequest struct {
+	fleet.WebhookDeliveryListOptions
+}
+
+type listWebhookDeliveriesResponse struct {
+	Deliveries []*fleet.WebhookDelivery `json:"deliveries"`
+	Err        error                    `json:"error,omitempty"`
+}
+
+func (r listWebhookDeliveriesResponse) error() error { return r.Err }
+
+func listWebhookDeliveriesEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*listWebhookDeliveriesRequest)
+	deliveries, err := svc.ListWebhookDeliveries(ctx, req.WebhookDeliveryListOptions)
+	if err != nil {
+		return listWebhookDeliveriesResponse{Err: err}, nil
+	}
+	return listWebhookDeliveriesResponse{Deliveries: deliveries}, nil
+}
+
+// ListWebhookDeliveries returns the deliveries of the webhooks configured in
+// the webhook settings.
+func (svc *Service) ListWebhookDeliveries(ctx context.Context, opts fleet.WebhookDeliveryListOptions) ([]*fleet.WebhookDelivery, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.WebhookDelivery{}, fleet.ActionRead); err != nil {
+		return nil, err
+	}
+
+	switch opts.Status {
+	case "", fleet.WebhookDeliveryPending, fleet.WebhookDeliverySuccess, fleet.WebhookDeliveryFailed, fleet.WebhookDeliveryDeadLetter:
+	default:
+		return nil, ctxerr.Wrap(ctx, fleet.NewInvalidArgumentError("status", "invalid webhook delivery status"))
+	}
+	return svc.ds.ListWebhookDeliveries(ctx, opts)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// Redeliver webhook delivery
+////////////////////////////////////////////////////////////////////////////////
+
+type redeliverWebhookDeliveryRequest struct {
+	ID uint `url:"id"`
+}
+
+type redeliverWebhookDeliveryResponse struct {
+	Delivery *fleet.WebhookDelivery `json:"delivery,omitempty"`
+	Err      error                  `json:"error,omitempty"`
+}
+
+func (r redeliverWebhookDeliveryResponse) error() error { return r.Err }
+
+func redeliverWebhookDeliveryEndpoint(ctx context.Context, request interface{}, svc fleet.Service) (interface{}, error) {
+	req := request.(*redeliverWebhookDeliveryRequest)
+	delivery, err := svc.RedeliverWebhookDelivery(ctx, req.ID)
+	if err != nil {
+		return redeliverWebhookDeliveryResponse{Err: err}, nil
+	}
+	return redeliverWebhookDeliveryResponse{Delivery: delivery}, nil
+}
+
+// RedeliverWebhookDelivery attempts to deliver the webhook delivery again. If
+// that attempt fails, it is retried via the worker as for a new delivery. The
+// deliveries that are still pending or being retried can't be redelivered.
+func (svc *Service) RedeliverWebhookDelivery(ctx context.Context, id uint) (*fleet.WebhookDelivery, error) {
+	if err := svc.authz.Authorize(ctx, &fleet.WebhookDelivery{}, fleet.ActionWrite); err != nil {
+		return nil, err
+	}
+
+	delivery, err := svc.ds.WebhookDelivery(ctx, id)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "get webhook delivery")
+	}
+	if err := worker.RedeliverWebhook(ctx, svc.ds, svc.logger, delivery); err != nil {
+		if errors.Is(err, worker.ErrWebhookDeliveryNotFinal) {
+			return nil, ctxerr.Wrap(ctx, &badRequestError{message: "the webhook delivery is still being retried"})
+		}
+		return nil, ctxerr.Wrap(ctx, err, "redeliver webhook")
+	}
+	return delivery, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/authz"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListWebhookDeliveries(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	ds.ListWebhookDeliveriesFunc = func(ctx context.Context, opts fleet.WebhookDeliveryListOptions) ([]*fleet.WebhookDelivery, error) {
+		return []*fleet.WebhookDelivery{{ID: 1}, {ID: 2}}, nil
+	}
+
+	// only global admins can list deliveries
+	deliveries, err := svc.ListWebhookDeliveries(test.UserContext(test.UserAdmin), fleet.WebhookDeliveryListOptions{})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+
+	for _, u := range []*fleet.User{test.UserMaintainer, test.UserObserver, test.UserTeamAdminTeam1, test.UserNoRoles} {
+		_, err := svc.ListWebhookDeliveries(test.UserContext(u), fleet.WebhookDeliveryListOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
+	}
+
+	// invalid status filter
+	_, err = svc.ListWebhookDeliveries(test.UserContext(test.UserAdmin), fleet.WebhookDeliveryListOptions{Status: "nope"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid webhook delivery status")
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	ds := new(mock.Store)
+	svc := newTestService(t, ds, nil, nil)
+
+	var received int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer srv.Close()
+
+	status := fleet.WebhookDeliveryDeadLetter
+	ds.WebhookDeliveryFunc = func(ctx context.Context, id uint) (*fleet.WebhookDelivery, error) {
+		return &fleet.WebhookDelivery{
+			ID:          id,
+			WebhookType: fleet.WebhookTypeFailingPolicies,
+			URL:         srv.URL,
+			Payload:     json.RawMessage(`{}`),
+			Status:      status,
+			Attempts:    7,
+		}, nil
+	}
+	ds.UpdateWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+		return nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{}, nil
+	}
+
+	_, err := svc.RedeliverWebhookDelivery(test.UserContext(test.UserMaintainer), 1)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), authz.ForbiddenErrorMessage)
+	require.Zero(t, received)
+
+	delivery, err := svc.RedeliverWebhookDelivery(test.UserContext(test.UserAdmin), 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, received)
+	require.Equal(t, fleet.WebhookDeliverySuccess, delivery.Status)
+	require.Equal(t, 8, delivery.Attempts)
+	require.True(t, ds.UpdateWebhookDeliveryFuncInvoked)
+	require.False(t, ds.NewJobFuncInvoked)
+
+	// a delivery that is still being retried can't be redelivered
+	status = fleet.WebhookDeliveryFailed
+	_, err = svc.RedeliverWebhookDelivery(test.UserContext(test.UserAdmin), 1)
+	var bre *badRequestError
+	require.ErrorAs(t, err, &bre)
+	require.Equal(t, 1, received)
+}
@@ -117,6 +117,23 @@ func (r resp) UserDisplayName() string {
 	return ""
 }
 
+func (r resp) UserGroups(name string) []string {
+	var groups []string
+	if r.response != nil {
+		for _, attr := range r.response.Assertion.AttributeStatement.Attributes {
+			if attr.Name != name {
+				continue
+			}
+			for _, v := range attr.AttributeValues {
+				if v.Value != "" {
+					groups = append(groups, v.Value)
+				}
+			}
+		}
+	}
+	return groups
+}
+
 func (r resp) status() (int, error) {
 	if r.response != nil {
 		statusURI := r.response.Status.StatusCode.Value
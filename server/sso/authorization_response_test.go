@@ -68,6 +68,8 @@ func TestDecodeAttributes(t *testing.T) {
 	assert.Equal(t, Success, status)
 	assert.Equal(t, "sso_user@example.com", auth.UserID())
 	assert.Equal(t, "SSO User 1", auth.UserDisplayName())
+	assert.Equal(t, []string{"group1"}, auth.UserGroups("eduPersonAffiliation"))
+	assert.Empty(t, auth.UserGroups("memberOf"))
 	assert.NotEmpty(t, auth.RequestID())
 }
 
@@ -104,3 +106,18 @@ func TestUserDisplayName(t *testing.T) {
 		})
 	}
 }
+
+func TestUserGroups(t *testing.T) {
+	var response Response
+	response.Assertion.AttributeStatement.Attributes = []Attribute{
+		{Name: "groups", AttributeValues: []AttributeValue{{Value: "fleet-admins"}, {Value: ""}, {Value: "eng"}}},
+		{Name: "displayname", AttributeValues: []AttributeValue{{Value: "Name Surname"}}},
+		{Name: "groups", AttributeValues: []AttributeValue{{Value: "sales"}}},
+	}
+	auth := resp{response: &response}
+	assert.Equal(t, []string{"fleet-admins", "eng", "sales"}, auth.UserGroups("groups"))
+	assert.Empty(t, auth.UserGroups("Groups"))
+
+	var empty resp
+	assert.Empty(t, empty.UserGroups("groups"))
+}
@@ -0,0 +1,504 @@
+package sso
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	// oidcDiscoveryTTL is the duration for which the discovery document of an
+	// OIDC provider is cached.
+	oidcDiscoveryTTL = time.Hour
+	// oidcJWKSRefreshInterval is the minimum interval between two fetches of
+	// the JWKS of a provider, when an unknown key ID is received.
+	oidcJWKSRefreshInterval = time.Minute
+	// oidcClockSkew is the clock skew tolerated when validating the ID token
+	// timestamps.
+	oidcClockSkew = time.Minute
+
+	oidcDefaultEmailClaim = "email"
+	oidcDefaultNameClaim  = "name"
+)
+
+// oidcSigningMethods are the ID token signing algorithms accepted by Fleet.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+// OIDCSettings are the settings used to create an OIDC authorization request
+// and to validate the response.
+type OIDCSettings struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback URL of Fleet where the identity provider
+	// redirects the user agent with the authorization code.
+	RedirectURL string
+	// Scopes are the scopes requested in addition to "openid".
+	Scopes []string
+	// EmailClaim and NameClaim are the ID token claims that contain the email
+	// and the name of the user, "email" and "name" if empty.
+	EmailClaim   string
+	NameClaim    string
+	SessionStore SessionStore
+	OriginalURL  string
+}
+
+// oidcSessionMetadata is the state of an OIDC authorization request, stored
+// in the metadata of the SSO session.
+type oidcSessionMetadata struct {
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// OIDCAuthResponse is the response of the identity provider to an OIDC
+// authorization request. It implements fleet.Auth, the user information is
+// available once it has been validated by OIDCProvider.ValidateAuthResponse.
+type OIDCAuthResponse struct {
+	Code             string
+	State            string
+	Error            string
+	ErrorDescription string
+
+	email  string
+	name   string
+	claims jwt.MapClaims
+}
+
+// UserID returns the email of the authenticated user.
+func (r *OIDCAuthResponse) UserID() string {
+	return r.email
+}
+
+// UserDisplayName returns the name of the authenticated user.
+func (r *OIDCAuthResponse) UserDisplayName() string {
+	return r.name
+}
+
+// UserGroups returns the values of the ID token claim identified by name,
+// which can be a string or an array of strings.
+func (r *OIDCAuthResponse) UserGroups(name string) []string {
+	switch v := r.claims[name].(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var groups []string
+		for _, g := range v {
+			if s, ok := g.(string); ok && s != "" {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// RequestID returns the state of the authorization request, which identifies
+// the SSO session.
+func (r *OIDCAuthResponse) RequestID() string {
+	return r.State
+}
+
+// OIDCProviderCache caches the discovered OIDC providers and their signing
+// keys, by issuer URL.
+type OIDCProviderCache struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*OIDCProvider
+}
+
+// NewOIDCProviderCache creates an OIDCProviderCache that uses the client for
+// the requests to the providers.
+func NewOIDCProviderCache(client *http.Client) *OIDCProviderCache {
+	return &OIDCProviderCache{
+		client:    client,
+		providers: make(map[string]*OIDCProvider),
+	}
+}
+
+// Provider returns the provider identified by the issuer URL, discovering its
+// configuration if it is not cached or if the cached configuration is stale.
+func (c *OIDCProviderCache) Provider(ctx context.Context, issuerURL string) (*OIDCProvider, error) {
+	c.mu.Lock()
+	p := c.providers[issuerURL]
+	c.mu.Unlock()
+	if p != nil && time.Since(p.discoveredAt) < oidcDiscoveryTTL {
+		return p, nil
+	}
+
+	p, err := discoverOIDCProvider(ctx, c.client, issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.providers[issuerURL] = p
+	c.mu.Unlock()
+	return p, nil
+}
+
+// OIDCProvider is an OpenID Connect identity provider.
+type OIDCProvider struct {
+	client       *http.Client
+	discoveredAt time.Time
+
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	keysMu        sync.Mutex
+	keys          map[string]interface{}
+	keysFetchedAt time.Time
+}
+
+// discoverOIDCProvider retrieves the configuration of the provider, see
+// https://openid.net/specs/openid-connect-discovery-1_0.html
+func discoverOIDCProvider(ctx context.Context, client *http.Client, issuerURL string) (*OIDCProvider, error) {
+	wellKnown := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+	body, err := oidcGet(ctx, client, wellKnown)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery: %w", err)
+	}
+
+	p := &OIDCProvider{client: client, discoveredAt: time.Now()}
+	if err := json.Unmarshal(body, p); err != nil {
+		return nil, fmt.Errorf("decode OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(p.Issuer, "/") != strings.TrimSuffix(issuerURL, "/") {
+		return nil, fmt.Errorf("OIDC discovery issuer %q does not match %q", p.Issuer, issuerURL)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing required endpoints")
+	}
+	return p, nil
+}
+
+// CreateAuthorizationRequest returns the URL of the identity provider to
+// redirect the user agent to, to initiate an authorization code flow with
+// PKCE. The state of the request is stored in the session store of the
+// settings.
+func (p *OIDCProvider) CreateAuthorizationRequest(settings *OIDCSettings) (string, error) {
+	state, err := randomURLSafeString(32)
+	if err != nil {
+		return "", fmt.Errorf("creating OIDC state: %w", err)
+	}
+	nonce, err := randomURLSafeString(32)
+	if err != nil {
+		return "", fmt.Errorf("creating OIDC nonce: %w", err)
+	}
+	verifier, err := randomURLSafeString(48)
+	if err != nil {
+		return "", fmt.Errorf("creating PKCE code verifier: %w", err)
+	}
+
+	metadata, err := json.Marshal(oidcSessionMetadata{Nonce: nonce, CodeVerifier: verifier})
+	if err != nil {
+		return "", fmt.Errorf("encoding OIDC session: %w", err)
+	}
+	if err := settings.SessionStore.create(state, settings.OriginalURL, string(metadata), cacheLifetime); err != nil {
+		return "", fmt.Errorf("caching OIDC session while creating auth request: %w", err)
+	}
+
+	u, err := url.Parse(p.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parsing authorization endpoint: %w", err)
+	}
+	scopes := []string{"openid"}
+	for _, s := range settings.Scopes {
+		if s != "openid" {
+			scopes = append(scopes, s)
+		}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	qry := u.Query()
+	qry.Set("response_type", "code")
+	qry.Set("client_id", settings.ClientID)
+	qry.Set("redirect_uri", settings.RedirectURL)
+	qry.Set("scope", strings.Join(scopes, " "))
+	qry.Set("state", state)
+	qry.Set("nonce", nonce)
+	qry.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	qry.Set("code_challenge_method", "S256")
+	u.RawQuery = qry.Encode()
+	return u.String(), nil
+}
+
+// ValidateAuthResponse exchanges the authorization code of the response for
+// an ID token, validates the token and sets the user information of the
+// response from its claims. The session is the SSO session identified by the
+// state of the response.
+func (p *OIDCProvider) ValidateAuthResponse(ctx context.Context, settings *OIDCSettings, resp *OIDCAuthResponse, session *Session) error {
+	if resp.Error != "" {
+		return fmt.Errorf("OIDC authorization failed: %s: %s", resp.Error, resp.ErrorDescription)
+	}
+	if resp.Code == "" {
+		return errors.New("OIDC authorization response is missing the code")
+	}
+
+	var meta oidcSessionMetadata
+	if err := json.Unmarshal([]byte(session.Metadata), &meta); err != nil {
+		return fmt.Errorf("decode OIDC session: %w", err)
+	}
+
+	idToken, err := p.exchangeCode(ctx, settings, resp.Code, meta.CodeVerifier)
+	if err != nil {
+		return err
+	}
+	claims, err := p.verifyIDToken(ctx, idToken, settings.ClientID, meta.Nonce)
+	if err != nil {
+		return err
+	}
+
+	emailClaim, nameClaim := settings.EmailClaim, settings.NameClaim
+	if emailClaim == "" {
+		emailClaim = oidcDefaultEmailClaim
+	}
+	if nameClaim == "" {
+		nameClaim = oidcDefaultNameClaim
+	}
+	email, _ := claims[emailClaim].(string)
+	if email == "" {
+		return fmt.Errorf("ID token is missing the %q claim", emailClaim)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified && emailClaim == oidcDefaultEmailClaim {
+		return errors.New("ID token email is not verified")
+	}
+	resp.email = email
+	resp.name, _ = claims[nameClaim].(string)
+	resp.claims = claims
+	return nil
+}
+
+func (p *OIDCProvider) exchangeCode(ctx context.Context, settings *OIDCSettings, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {settings.RedirectURL},
+		"client_id":     {settings.ClientID},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if settings.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(settings.ClientID), url.QueryEscape(settings.ClientSecret))
+	}
+
+	httpResp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OIDC token request: %w", err)
+	}
+	defer httpResp.Body.Close()
+	body, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read OIDC token response: %w", err)
+	}
+
+	var tokenResp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil && httpResp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("decode OIDC token response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OIDC token endpoint returned %s: %s %s", httpResp.Status, tokenResp.Error, tokenResp.ErrorDescription)
+	}
+	if tokenResp.IDToken == "" {
+		return "", errors.New("OIDC token response is missing the ID token")
+	}
+	return tokenResp.IDToken, nil
+}
+
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, idToken, clientID, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(oidcSigningMethods), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verify ID token: %w", err)
+	}
+
+	now := time.Now()
+	if !claims.VerifyIssuer(p.Issuer, true) {
+		return nil, errors.New("ID token issuer mismatch")
+	}
+	if !claims.VerifyAudience(clientID, true) {
+		return nil, errors.New("ID token audience mismatch")
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != clientID {
+		return nil, errors.New("ID token authorized party mismatch")
+	}
+	if !claims.VerifyExpiresAt(now.Add(-oidcClockSkew).Unix(), true) {
+		return nil, errors.New("ID token is expired")
+	}
+	if !claims.VerifyIssuedAt(now.Add(oidcClockSkew).Unix(), false) {
+		return nil, errors.New("ID token is issued in the future")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+	return claims, nil
+}
+
+// signingKey returns the public key identified by kid in the JWKS of the
+// provider. The JWKS is fetched again if the key is unknown, at most once per
+// oidcJWKSRefreshInterval, to support key rotation.
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (interface{}, error) {
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	if !p.keysFetchedAt.IsZero() && time.Since(p.keysFetchedAt) < oidcJWKSRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	body, err := oidcGet(ctx, p.client, p.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey returns the key identified by kid, or the only key of the JWKS if
+// kid is empty. It must be called with keysMu held.
+func (p *OIDCProvider) lookupKey(kid string) (interface{}, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and EC signing keys of the JSON Web Key Set, by
+// key ID. Keys of other types or uses are ignored.
+func parseJWKS(body []byte) (map[string]interface{}, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(body, &jwks); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]interface{}, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("decode RSA modulus of key %q: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("decode RSA exponent of key %q: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil {
+				return nil, fmt.Errorf("decode EC x of key %q: %w", k.Kid, err)
+			}
+			y, err := base64.RawURLEncoding.DecodeString(k.Y)
+			if err != nil {
+				return nil, fmt.Errorf("decode EC y of key %q: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+		}
+	}
+	return keys, nil
+}
+
+func oidcGet(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func randomURLSafeString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
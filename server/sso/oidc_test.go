@@ -0,0 +1,300 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOIDCProvider is a minimal OpenID Connect provider that issues ID tokens
+// with the claims returned by claimsFn.
+type mockOIDCProvider struct {
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	jwksCalls int32
+
+	challenge string
+	claimsFn  func(nonce string) jwt.MapClaims
+	nonce     string
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &mockOIDCProvider{key: key, kid: "key1"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.srv.URL,
+			"authorization_endpoint": p.srv.URL + "/authorize",
+			"token_endpoint":         p.srv.URL + "/token",
+			"jwks_uri":               p.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.jwksCalls, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": p.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "fleet" || secret != "secret" || r.FormValue("code") != "thecode" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"PKCE verification failed"}`))
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.claimsFn(p.nonce))
+		token.Header["kid"] = p.kid
+		signed, err := token.SignedString(p.key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     signed,
+		})
+	})
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+
+	p.claimsFn = p.validClaims
+	return p
+}
+
+func (p *mockOIDCProvider) validClaims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            p.srv.URL,
+		"sub":            "123",
+		"aud":            "fleet",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "user@example.com",
+		"email_verified": true,
+		"name":           "Example User",
+	}
+}
+
+// authorize simulates the login of the user at the provider: it records the
+// PKCE challenge and nonce of the authorization URL and returns the response
+// redirected to Fleet.
+func (p *mockOIDCProvider) authorize(t *testing.T, authURL string) *OIDCAuthResponse {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	p.challenge = q.Get("code_challenge")
+	p.nonce = q.Get("nonce")
+	return &OIDCAuthResponse{Code: "thecode", State: q.Get("state")}
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	mock := newMockOIDCProvider(t)
+
+	cache := NewOIDCProviderCache(http.DefaultClient)
+	provider, err := cache.Provider(ctx, mock.srv.URL)
+	require.NoError(t, err)
+
+	// the provider is cached
+	provider2, err := cache.Provider(ctx, mock.srv.URL)
+	require.NoError(t, err)
+	assert.Same(t, provider, provider2)
+
+	newSettings := func() *OIDCSettings {
+		return &OIDCSettings{
+			ClientID:     "fleet",
+			ClientSecret: "secret",
+			RedirectURL:  "https://fleet.example.com/api/v1/fleet/sso/callback",
+			Scopes:       []string{"email", "profile"},
+			SessionStore: &mockStore{},
+			OriginalURL:  "/redir",
+		}
+	}
+
+	login := func(t *testing.T, settings *OIDCSettings) (*OIDCAuthResponse, error) {
+		authURL, err := provider.CreateAuthorizationRequest(settings)
+		require.NoError(t, err)
+		resp := mock.authorize(t, authURL)
+		session, err := settings.SessionStore.Get(resp.RequestID())
+		require.NoError(t, err)
+		assert.Equal(t, "/redir", session.OriginalURL)
+		return resp, provider.ValidateAuthResponse(ctx, settings, resp, session)
+	}
+
+	t.Run("authorization request", func(t *testing.T) {
+		authURL, err := provider.CreateAuthorizationRequest(newSettings())
+		require.NoError(t, err)
+		u, err := url.Parse(authURL)
+		require.NoError(t, err)
+		assert.Equal(t, "/authorize", u.Path)
+		q := u.Query()
+		assert.Equal(t, "code", q.Get("response_type"))
+		assert.Equal(t, "fleet", q.Get("client_id"))
+		assert.Equal(t, "openid email profile", q.Get("scope"))
+		assert.Equal(t, "S256", q.Get("code_challenge_method"))
+		assert.NotEmpty(t, q.Get("code_challenge"))
+		assert.NotEmpty(t, q.Get("state"))
+		assert.NotEmpty(t, q.Get("nonce"))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mock.claimsFn = mock.validClaims
+		resp, err := login(t, newSettings())
+		require.NoError(t, err)
+		assert.Equal(t, "user@example.com", resp.UserID())
+		assert.Equal(t, "Example User", resp.UserDisplayName())
+		assert.Empty(t, resp.UserGroups("groups"))
+	})
+
+	t.Run("groups claim", func(t *testing.T) {
+		mock.claimsFn = func(nonce string) jwt.MapClaims {
+			claims := mock.validClaims(nonce)
+			claims["groups"] = []string{"fleet-admins", "eng"}
+			claims["role"] = "admin"
+			return claims
+		}
+		resp, err := login(t, newSettings())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"fleet-admins", "eng"}, resp.UserGroups("groups"))
+		assert.Equal(t, []string{"admin"}, resp.UserGroups("role"))
+	})
+
+	t.Run("custom claims", func(t *testing.T) {
+		mock.claimsFn = func(nonce string) jwt.MapClaims {
+			claims := mock.validClaims(nonce)
+			claims["upn"] = "upn@example.com"
+			claims["given_name"] = "Given"
+			return claims
+		}
+		settings := newSettings()
+		settings.EmailClaim = "upn"
+		settings.NameClaim = "given_name"
+		resp, err := login(t, settings)
+		require.NoError(t, err)
+		assert.Equal(t, "upn@example.com", resp.UserID())
+		assert.Equal(t, "Given", resp.UserDisplayName())
+	})
+
+	invalidCases := []struct {
+		name   string
+		modify func(claims jwt.MapClaims)
+		errMsg string
+	}{
+		{"bad nonce", func(c jwt.MapClaims) { c["nonce"] = "other" }, "nonce mismatch"},
+		{"bad audience", func(c jwt.MapClaims) { c["aud"] = "other" }, "audience mismatch"},
+		{"bad issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "issuer mismatch"},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "expired"},
+		{"unverified email", func(c jwt.MapClaims) { c["email_verified"] = false }, "not verified"},
+		{"missing email", func(c jwt.MapClaims) { delete(c, "email") }, "missing the \"email\" claim"},
+	}
+	for _, c := range invalidCases {
+		t.Run(c.name, func(t *testing.T) {
+			mock.claimsFn = func(nonce string) jwt.MapClaims {
+				claims := mock.validClaims(nonce)
+				c.modify(claims)
+				return claims
+			}
+			_, err := login(t, newSettings())
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.errMsg)
+		})
+	}
+
+	t.Run("bad signature", func(t *testing.T) {
+		mock.claimsFn = mock.validClaims
+		goodKey := mock.key
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		mock.key = otherKey
+		defer func() { mock.key = goodKey }()
+
+		_, err = login(t, newSettings())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "verify ID token")
+	})
+
+	t.Run("PKCE verifier mismatch", func(t *testing.T) {
+		mock.claimsFn = mock.validClaims
+		settings := newSettings()
+		authURL, err := provider.CreateAuthorizationRequest(settings)
+		require.NoError(t, err)
+		resp := mock.authorize(t, authURL)
+		mock.challenge = "tampered"
+		session, err := settings.SessionStore.Get(resp.RequestID())
+		require.NoError(t, err)
+		err = provider.ValidateAuthResponse(ctx, settings, resp, session)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PKCE verification failed")
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		resp := &OIDCAuthResponse{State: "abc", Error: "access_denied", ErrorDescription: "user denied"}
+		err := provider.ValidateAuthResponse(ctx, newSettings(), resp, &Session{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "access_denied")
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		mock.claimsFn = mock.validClaims
+		mock.kid = "key2"
+		defer func() { mock.kid = "key1" }()
+
+		// the JWKS is not fetched again before the refresh interval
+		_, err := login(t, newSettings())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown signing key")
+
+		calls := atomic.LoadInt32(&mock.jwksCalls)
+		provider.keysMu.Lock()
+		provider.keysFetchedAt = time.Now().Add(-2 * oidcJWKSRefreshInterval)
+		provider.keysMu.Unlock()
+		_, err = login(t, newSettings())
+		require.NoError(t, err)
+		assert.Equal(t, calls+1, atomic.LoadInt32(&mock.jwksCalls))
+	})
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 "https://other.example.com",
+			"authorization_endpoint": "https://other.example.com/authorize",
+			"token_endpoint":         "https://other.example.com/token",
+			"jwks_uri":               "https://other.example.com/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	_, err := NewOIDCProviderCache(http.DefaultClient).Provider(context.Background(), srv.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+}
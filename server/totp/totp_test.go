@@ -0,0 +1,96 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238 appendix B.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// the RFC test vectors have 8 digits, the 6 digits codes are the last 6
+	// digits of those.
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, c := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(c.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, c.want, code, c.unix)
+	}
+
+	_, err := Code("not base32!", 1)
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	now := time.Now()
+	step := Step(now)
+	code, err := Code(secret, step)
+	require.NoError(t, err)
+
+	got, ok, err := Validate(secret, code, now, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, step, got)
+
+	// spaces are ignored
+	_, ok, err = Validate(secret, code[:3]+" "+code[3:], now, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// the code can't be replayed
+	_, ok, err = Validate(secret, code, now, step)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// the codes of the adjacent periods are accepted, not the others
+	_, ok, err = Validate(secret, code, now.Add(Period), 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = Validate(secret, code, now.Add(-Period), 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	_, ok, err = Validate(secret, code, now.Add(3*Period), 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// invalid codes
+	for _, invalid := range []string{"", "12345", "1234567", "abcdef"} {
+		_, ok, err = Validate(secret, invalid, now, 0)
+		require.NoError(t, err)
+		require.False(t, ok, invalid)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Acme Fleet", "alice@example.com", "ABCDEF")
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/"))
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/Acme Fleet:alice@example.com", u.Path)
+	assert.Equal(t, "ABCDEF", u.Query().Get("secret"))
+	assert.Equal(t, "Acme Fleet", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+}
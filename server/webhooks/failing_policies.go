@@ -8,23 +8,26 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/fleetdm/fleet/v4/server"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/worker"
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 )
 
 // SendFailingPoliciesBatchedPOSTs sends a failing policy to the provided
-// webhook URL. It sends in batches if hostBatchSize > 0. After a successful
-// send, the corresponding hosts are removed from the failing policies set.
+// webhook URL, signed with webhookSecret if it is not empty. It sends in
+// batches if hostBatchSize > 0. After a batch is delivered or queued for
+// retry, the corresponding hosts are removed from the failing policies set.
 func SendFailingPoliciesBatchedPOSTs(
 	ctx context.Context,
+	ds fleet.Datastore,
 	policy *fleet.Policy,
 	failingPoliciesSet fleet.FailingPolicySet,
 	hostBatchSize int,
 	serverURL *url.URL,
 	webhookURL *url.URL,
+	webhookSecret string,
 	now time.Time,
 	logger kitlog.Logger,
 ) error {
@@ -61,8 +64,8 @@ func SendFailingPoliciesBatchedPOSTs(
 			FailingHosts: failingHosts,
 		}
 		level.Debug(logger).Log("payload", payload, "url", webhookURL.String(), "batch", len(batch))
-		if err := server.PostJSONWithTimeout(ctx, webhookURL.String(), &payload); err != nil {
-			return ctxerr.Wrapf(ctx, err, "posting to %q", webhookURL)
+		if _, err := worker.DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeFailingPolicies, policy.TeamID, webhookURL.String(), webhookSecret, &payload); err != nil {
+			return ctxerr.Wrapf(ctx, err, "delivering to %q", webhookURL)
 		}
 		if err := failingPoliciesSet.RemoveHosts(policy.ID, batch); err != nil {
 			return ctxerr.Wrapf(ctx, err, "removing hosts %+v from failing policies set %d", batch, policy.ID)
@@ -25,6 +25,7 @@ import (
 
 func TestTriggerFailingPoliciesWebhookBasic(t *testing.T) {
 	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
 
 	requestBody := ""
 
@@ -92,7 +93,7 @@ func TestTriggerFailingPoliciesWebhookBasic(t *testing.T) {
 			return err
 		}
 		return SendFailingPoliciesBatchedPOSTs(
-			context.Background(), pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, mockClock, kitlog.NewNopLogger())
+			context.Background(), ds, pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, cfg.WebhookSecret, mockClock, kitlog.NewNopLogger())
 	})
 	require.NoError(t, err)
 	timestamp, err := mockClock.MarshalJSON()
@@ -143,7 +144,7 @@ func TestTriggerFailingPoliciesWebhookBasic(t *testing.T) {
 			return err
 		}
 		return SendFailingPoliciesBatchedPOSTs(
-			context.Background(), pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, mockClock, kitlog.NewNopLogger())
+			context.Background(), ds, pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, cfg.WebhookSecret, mockClock, kitlog.NewNopLogger())
 	})
 	require.NoError(t, err)
 	assert.Empty(t, requestBody)
@@ -166,6 +167,7 @@ func TestTriggerFailingPoliciesWebhookTeam(t *testing.T) {
 	})
 
 	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
 
 	teamID := uint(1)
 
@@ -264,7 +266,7 @@ func TestTriggerFailingPoliciesWebhookTeam(t *testing.T) {
 			return err
 		}
 		return SendFailingPoliciesBatchedPOSTs(
-			context.Background(), pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, now, kitlog.NewNopLogger())
+			context.Background(), ds, pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, cfg.WebhookSecret, now, kitlog.NewNopLogger())
 	})
 	require.NoError(t, err)
 
@@ -313,13 +315,16 @@ func TestTriggerFailingPoliciesWebhookTeam(t *testing.T) {
 			return err
 		}
 		return SendFailingPoliciesBatchedPOSTs(
-			context.Background(), pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, now, kitlog.NewNopLogger())
+			context.Background(), ds, pol, failingPolicySet, cfg.HostBatchSize, serverURL, cfg.WebhookURL, cfg.WebhookSecret, now, kitlog.NewNopLogger())
 	})
 	require.NoError(t, err)
 	assert.Empty(t, webhookBody)
 }
 
 func TestSendBatchedPOSTs(t *testing.T) {
+	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+
 	allHosts := []uint{}
 	requestCount := 0
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -434,11 +439,13 @@ func TestSendBatchedPOSTs(t *testing.T) {
 
 			err = SendFailingPoliciesBatchedPOSTs(
 				context.Background(),
+				ds,
 				p,
 				failingPolicySet,
 				tc.batchSize,
 				serverURL,
 				webhookURL,
+				"",
 				now,
 				kitlog.NewNopLogger(),
 			)
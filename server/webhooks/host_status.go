@@ -4,51 +4,95 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/fleetdm/fleet/v4/server"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/worker"
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 )
 
+// TriggerHostStatusWebhook performs the host status webhook requests, for the
+// global webhook if it is enabled, and for each team that has its host status
+// webhook enabled, evaluated against the hosts of that team only.
 func TriggerHostStatusWebhook(
 	ctx context.Context,
 	ds fleet.Datastore,
 	logger kitlog.Logger,
 	appConfig *fleet.AppConfig,
 ) error {
-	if !appConfig.WebhookSettings.HostStatusWebhook.Enable {
-		return nil
+	if appConfig.WebhookSettings.HostStatusWebhook.Enable {
+		level.Debug(logger).Log("enabled", "true")
+		if err := triggerHostStatusWebhook(ctx, ds, logger, nil, appConfig.WebhookSettings.HostStatusWebhook); err != nil {
+			return err
+		}
+	}
+
+	teams, err := listTeamsWithWebhook(ctx, ds, fleet.TeamWebhookSettings.HostStatusWebhookEnabled)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list teams with host status webhook")
 	}
+	for _, team := range teams {
+		teamLogger := kitlog.With(logger, "team_id", team.ID)
+		level.Debug(teamLogger).Log("enabled", "true")
+		if err := triggerHostStatusWebhook(ctx, ds, teamLogger, team, *team.Config.WebhookSettings.HostStatusWebhook); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-	level.Debug(logger).Log("enabled", "true")
+// triggerHostStatusWebhook sends the host status webhook request with the
+// provided settings if the percentage of unseen hosts reaches the configured
+// threshold. If team is not nil, only the hosts of that team are considered.
+func triggerHostStatusWebhook(
+	ctx context.Context,
+	ds fleet.Datastore,
+	logger kitlog.Logger,
+	team *fleet.Team,
+	settings fleet.HostStatusWebhookSettings,
+) error {
+	var teamID *uint
+	if team != nil {
+		teamID = &team.ID
+	}
 
-	total, unseen, err := ds.TotalAndUnseenHostsSince(ctx, appConfig.WebhookSettings.HostStatusWebhook.DaysCount)
+	total, unseen, err := ds.TotalAndUnseenHostsSince(ctx, teamID, settings.DaysCount)
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "getting total and unseen hosts")
 	}
+	if total == 0 {
+		return nil
+	}
 
 	percentUnseen := float64(unseen) * 100.0 / float64(total)
-	if percentUnseen >= appConfig.WebhookSettings.HostStatusWebhook.HostPercentage {
-		url := appConfig.WebhookSettings.HostStatusWebhook.DestinationURL
+	if percentUnseen >= settings.HostPercentage {
+		url := settings.DestinationURL
 
+		hostsDesc := "your hosts"
+		if team != nil {
+			hostsDesc = fmt.Sprintf("your hosts in team %s", team.Name)
+		}
 		message := fmt.Sprintf(
-			"More than %.2f%% of your hosts have not checked into Fleet for more than %d days. "+
+			"More than %.2f%% of %s have not checked into Fleet for more than %d days. "+
 				"You've been sent this message because the Host status webhook is enabled in your Fleet instance.",
-			percentUnseen, appConfig.WebhookSettings.HostStatusWebhook.DaysCount,
+			percentUnseen, hostsDesc, settings.DaysCount,
 		)
+		data := map[string]interface{}{
+			"unseen_hosts": unseen,
+			"total_hosts":  total,
+			"days_unseen":  settings.DaysCount,
+		}
+		if teamID != nil {
+			data["team_id"] = *teamID
+		}
 		payload := map[string]interface{}{
 			"text": message,
-			"data": map[string]interface{}{
-				"unseen_hosts": unseen,
-				"total_hosts":  total,
-				"days_unseen":  appConfig.WebhookSettings.HostStatusWebhook.DaysCount,
-			},
+			"data": data,
 		}
 
-		err = server.PostJSONWithTimeout(ctx, url, &payload)
-		if err != nil {
-			return ctxerr.Wrapf(ctx, err, "posting to %s", url)
+		if _, err := worker.DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeHostStatus, teamID, url, settings.Secret, &payload); err != nil {
+			return ctxerr.Wrapf(ctx, err, "delivering to %s", url)
 		}
 	}
 
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/fleetdm/fleet/v4/pkg/webhooksig"
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/mock"
 	kitlog "github.com/go-kit/kit/log"
@@ -16,6 +17,10 @@ import (
 
 func TestTriggerHostStatusWebhook(t *testing.T) {
 	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
 
 	requestBody := ""
 
@@ -37,7 +42,8 @@ func TestTriggerHostStatusWebhook(t *testing.T) {
 		},
 	}
 
-	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, daysCount int) (int, int, error) {
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
+		assert.Nil(t, teamID)
 		assert.Equal(t, 2, daysCount)
 		return 10, 6, nil
 	}
@@ -50,7 +56,7 @@ func TestTriggerHostStatusWebhook(t *testing.T) {
 	)
 	requestBody = ""
 
-	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, daysCount int) (int, int, error) {
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
 		assert.Equal(t, 2, daysCount)
 		return 10, 1, nil
 	}
@@ -58,3 +64,167 @@ func TestTriggerHostStatusWebhook(t *testing.T) {
 	require.NoError(t, TriggerHostStatusWebhook(context.Background(), ds, kitlog.NewNopLogger(), ac))
 	assert.Equal(t, "", requestBody)
 }
+
+// mockWebhookDeliveries sets up the datastore mocks required to record the
+// webhook deliveries and queue their retries.
+func mockWebhookDeliveries(ds *mock.Store) {
+	var deliveryID uint
+	ds.NewWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+		deliveryID++
+		delivery.ID = deliveryID
+		return delivery, nil
+	}
+	ds.UpdateWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+		return nil
+	}
+	ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+		return job, nil
+	}
+}
+
+func TestTriggerHostStatusWebhookFailedDelivery(t *testing.T) {
+	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	ac := &fleet.AppConfig{
+		WebhookSettings: fleet.WebhookSettings{
+			HostStatusWebhook: fleet.HostStatusWebhookSettings{
+				Enable:         true,
+				DestinationURL: ts.URL,
+				HostPercentage: 43,
+				DaysCount:      2,
+			},
+		},
+	}
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
+		return 10, 6, nil
+	}
+
+	var lastDelivery *fleet.WebhookDelivery
+	ds.UpdateWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+		lastDelivery = delivery
+		return nil
+	}
+
+	// the failed delivery is recorded and queued for retry, not an error
+	require.NoError(t, TriggerHostStatusWebhook(context.Background(), ds, kitlog.NewNopLogger(), ac))
+	require.True(t, ds.NewWebhookDeliveryFuncInvoked)
+	require.True(t, ds.NewJobFuncInvoked)
+	require.NotNil(t, lastDelivery)
+	assert.Equal(t, fleet.WebhookTypeHostStatus, lastDelivery.WebhookType)
+	assert.Equal(t, fleet.WebhookDeliveryFailed, lastDelivery.Status)
+	assert.Equal(t, http.StatusBadGateway, *lastDelivery.LastStatusCode)
+	assert.Equal(t, 1, lastDelivery.Attempts)
+}
+
+func TestTriggerHostStatusWebhookSigned(t *testing.T) {
+	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
+
+	var verifyErr error
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, verifyErr = webhooksig.VerifyRequest(r, "s3cr3t", webhooksig.DefaultTolerance)
+	}))
+	defer ts.Close()
+
+	ac := &fleet.AppConfig{
+		WebhookSettings: fleet.WebhookSettings{
+			HostStatusWebhook: fleet.HostStatusWebhookSettings{
+				Enable:         true,
+				DestinationURL: ts.URL,
+				Secret:         "s3cr3t",
+				HostPercentage: 43,
+				DaysCount:      2,
+			},
+		},
+	}
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
+		return 10, 6, nil
+	}
+
+	require.NoError(t, TriggerHostStatusWebhook(context.Background(), ds, kitlog.NewNopLogger(), ac))
+	require.NoError(t, verifyErr)
+}
+
+func TestTriggerHostStatusWebhookTeams(t *testing.T) {
+	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+
+	requests := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBodyBytes, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		requests[r.URL.Path] = string(requestBodyBytes)
+	}))
+	defer ts.Close()
+
+	teams := map[uint]*fleet.Team{
+		1: {ID: 1, Name: "team1", Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+			HostStatusWebhook: &fleet.HostStatusWebhookSettings{
+				Enable:         true,
+				DestinationURL: ts.URL + "/team1",
+				HostPercentage: 50,
+				DaysCount:      3,
+			},
+		}}},
+		// host status webhook disabled
+		2: {ID: 2, Name: "team2", Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+			HostStatusWebhook: &fleet.HostStatusWebhookSettings{
+				DestinationURL: ts.URL + "/team2",
+			},
+		}}},
+		// host status webhook never set
+		3: {ID: 3, Name: "team3"},
+	}
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return []*fleet.Team{teams[1], teams[2], teams[3]}, nil
+	}
+	ds.TotalAndUnseenHostsSinceFunc = func(ctx context.Context, teamID *uint, daysCount int) (int, int, error) {
+		if teamID == nil {
+			return 10, 1, nil
+		}
+		require.Equal(t, uint(1), *teamID)
+		require.Equal(t, 3, daysCount)
+		return 4, 2, nil
+	}
+
+	// the global webhook is enabled but below its threshold
+	ac := &fleet.AppConfig{
+		WebhookSettings: fleet.WebhookSettings{
+			HostStatusWebhook: fleet.HostStatusWebhookSettings{
+				Enable:         true,
+				DestinationURL: ts.URL + "/global",
+				HostPercentage: 43,
+				DaysCount:      2,
+			},
+		},
+	}
+
+	var teamDelivery *fleet.WebhookDelivery
+	ds.NewWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+		teamDelivery = delivery
+		return delivery, nil
+	}
+
+	require.NoError(t, TriggerHostStatusWebhook(context.Background(), ds, kitlog.NewNopLogger(), ac))
+	require.Len(t, requests, 1)
+	assert.Equal(
+		t,
+		`{"data":{"days_unseen":3,"team_id":1,"total_hosts":4,"unseen_hosts":2},"text":"More than 50.00% of your hosts in team team1 have not checked into Fleet for more than 3 days. You've been sent this message because the Host status webhook is enabled in your Fleet instance."}`,
+		requests["/team1"],
+	)
+	require.NotNil(t, teamDelivery)
+	require.NotNil(t, teamDelivery.TeamID)
+	assert.Equal(t, uint(1), *teamDelivery.TeamID)
+}
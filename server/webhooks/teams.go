@@ -0,0 +1,35 @@
+package webhooks
+
+import (
+	"context"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+)
+
+// listTeamsWithWebhook returns the teams for which the enabled function
+// returns true for the team's webhook settings.
+func listTeamsWithWebhook(ctx context.Context, ds fleet.Datastore, enabled func(fleet.TeamWebhookSettings) bool) ([]*fleet.Team, error) {
+	all, err := ds.TeamsWithConfig(ctx)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "list teams")
+	}
+
+	var teams []*fleet.Team
+	for _, team := range all {
+		if enabled(team.Config.WebhookSettings) {
+			teams = append(teams, team)
+		}
+	}
+	return teams, nil
+}
+
+// TeamVulnerabilitiesWebhookEnabled returns true if at least one team has its
+// vulnerabilities webhook enabled.
+func TeamVulnerabilitiesWebhookEnabled(ctx context.Context, ds fleet.Datastore) (bool, error) {
+	teams, err := listTeamsWithWebhook(ctx, ds, fleet.TeamWebhookSettings.VulnerabilitiesWebhookEnabled)
+	if err != nil {
+		return false, err
+	}
+	return len(teams) > 0, nil
+}
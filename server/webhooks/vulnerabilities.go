@@ -8,14 +8,17 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/fleetdm/fleet/v4/server"
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/worker"
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 )
 
-// TriggerVulnerabilitiesWebhook performs the webhook requests for vulnerabilities.
+// TriggerVulnerabilitiesWebhook performs the webhook requests for
+// vulnerabilities. All affected hosts are sent to the global webhook if it is
+// enabled, and the affected hosts of each team are sent to the team's webhook
+// if it is enabled.
 func TriggerVulnerabilitiesWebhook(
 	ctx context.Context,
 	ds fleet.Datastore,
@@ -24,21 +27,22 @@ func TriggerVulnerabilitiesWebhook(
 	appConfig *fleet.AppConfig,
 	now time.Time,
 ) error {
-	vulnConfig := appConfig.WebhookSettings.VulnerabilitiesWebhook
-	if !vulnConfig.Enable {
+	globalConfig := appConfig.WebhookSettings.VulnerabilitiesWebhook
+	teams, err := listTeamsWithWebhook(ctx, ds, fleet.TeamWebhookSettings.VulnerabilitiesWebhookEnabled)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list teams with vulnerabilities webhook")
+	}
+	if !globalConfig.Enable && len(teams) == 0 {
 		return nil
 	}
 
-	level.Debug(logger).Log("enabled", "true", "recentVulns", len(recentVulns))
+	level.Debug(logger).Log("enabled", "true", "recentVulns", len(recentVulns), "teams", len(teams))
 
 	serverURL, err := url.Parse(appConfig.ServerSettings.ServerURL)
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "invalid server url")
 	}
 
-	targetURL := vulnConfig.DestinationURL
-	batchSize := vulnConfig.HostBatchSize
-
 	softwareIDsGroupedByCVE := make(map[string][]uint)
 	for _, v := range recentVulns {
 		softwareIDsGroupedByCVE[v.CVE] = append(softwareIDsGroupedByCVE[v.CVE], v.SoftwareID)
@@ -52,28 +56,66 @@ func TriggerVulnerabilitiesWebhook(
 			return ctxerr.Wrap(ctx, err, "get hosts by CPE")
 		}
 
-		for len(hosts) > 0 {
-			limit := len(hosts)
-			if batchSize > 0 && len(hosts) > batchSize {
-				limit = batchSize
+		if globalConfig.Enable {
+			if err := sendVulnerabilityHosts(ctx, ds, logger, nil, globalConfig, v.CVE, serverURL, hosts, now); err != nil {
+				return err
+			}
+		}
+
+		if len(teams) > 0 {
+			hostsByTeam := make(map[uint][]*fleet.HostShort)
+			for _, h := range hosts {
+				if h.TeamID != nil {
+					hostsByTeam[*h.TeamID] = append(hostsByTeam[*h.TeamID], h)
+				}
 			}
-			if err := sendVulnerabilityHostBatch(ctx, targetURL, v.CVE, serverURL, hosts[:limit], now); err != nil {
-				return ctxerr.Wrap(ctx, err, "send vulnerability host batch")
+			for _, team := range teams {
+				teamLogger := kitlog.With(logger, "team_id", team.ID)
+				teamConfig := *team.Config.WebhookSettings.VulnerabilitiesWebhook
+				if err := sendVulnerabilityHosts(ctx, ds, teamLogger, &team.ID, teamConfig, v.CVE, serverURL, hostsByTeam[team.ID], now); err != nil {
+					return err
+				}
 			}
-			hosts = hosts[limit:]
 		}
 	}
 
 	return nil
 }
 
+// sendVulnerabilityHosts sends the affected hosts to the webhook configured
+// in the settings, in batches of the configured size.
+func sendVulnerabilityHosts(
+	ctx context.Context,
+	ds fleet.Datastore,
+	logger kitlog.Logger,
+	teamID *uint,
+	vulnConfig fleet.VulnerabilitiesWebhookSettings,
+	cve string,
+	serverURL *url.URL,
+	hosts []*fleet.HostShort,
+	now time.Time,
+) error {
+	batchSize := vulnConfig.HostBatchSize
+	for len(hosts) > 0 {
+		limit := len(hosts)
+		if batchSize > 0 && len(hosts) > batchSize {
+			limit = batchSize
+		}
+		if err := sendVulnerabilityHostBatch(ctx, ds, logger, teamID, vulnConfig.DestinationURL, vulnConfig.Secret, cve, serverURL, hosts[:limit], now); err != nil {
+			return ctxerr.Wrap(ctx, err, "send vulnerability host batch")
+		}
+		hosts = hosts[limit:]
+	}
+	return nil
+}
+
 type vulnHostPayload struct {
 	ID       uint   `json:"id"`
 	Hostname string `json:"hostname"`
 	URL      string `json:"url"`
 }
 
-func sendVulnerabilityHostBatch(ctx context.Context, targetURL, cve string, hostBaseURL *url.URL, hosts []*fleet.HostShort, now time.Time) error {
+func sendVulnerabilityHostBatch(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, teamID *uint, targetURL, secret, cve string, hostBaseURL *url.URL, hosts []*fleet.HostShort, now time.Time) error {
 	shortHosts := make([]*vulnHostPayload, len(hosts))
 	for i, h := range hosts {
 		hostURL := *hostBaseURL
@@ -94,8 +136,8 @@ func sendVulnerabilityHostBatch(ctx context.Context, targetURL, cve string, host
 		},
 	}
 
-	if err := server.PostJSONWithTimeout(ctx, targetURL, &payload); err != nil {
-		return ctxerr.Wrapf(ctx, err, "posting to %s", targetURL)
+	if _, err := worker.DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeVulnerabilities, teamID, targetURL, secret, &payload); err != nil {
+		return ctxerr.Wrapf(ctx, err, "delivering to %s", targetURL)
 	}
 	return nil
 }
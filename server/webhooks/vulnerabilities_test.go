@@ -12,6 +12,7 @@ import (
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
 	kitlog "github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/require"
 	"github.com/tj/assert"
@@ -20,6 +21,10 @@ import (
 func TestTriggerVulnerabilitiesWebhook(t *testing.T) {
 	ctx := context.Background()
 	ds := new(mock.Store)
+	mockWebhookDeliveries(ds)
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return nil, nil
+	}
 	logger := kitlog.NewNopLogger()
 
 	appCfg := &fleet.AppConfig{
@@ -149,4 +154,62 @@ func TestTriggerVulnerabilitiesWebhook(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("team webhooks", func(t *testing.T) {
+		now := time.Now()
+
+		requests := make(map[string][]string)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			requests[r.URL.Path] = append(requests[r.URL.Path], string(b))
+			w.Write(nil)
+		}))
+		defer srv.Close()
+
+		teams := map[uint]*fleet.Team{
+			1: {ID: 1, Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+				VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{Enable: true, DestinationURL: srv.URL + "/team1"},
+			}}},
+			2: {ID: 2, Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+				VulnerabilitiesWebhook: &fleet.VulnerabilitiesWebhookSettings{Enable: true, DestinationURL: srv.URL + "/team2"},
+			}}},
+			3: {ID: 3},
+		}
+		ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+			return []*fleet.Team{teams[1], teams[2], teams[3]}, nil
+		}
+		ds.HostsBySoftwareIDsFunc = func(ctx context.Context, softwareIDs []uint) ([]*fleet.HostShort, error) {
+			return []*fleet.HostShort{
+				{ID: 1, Hostname: "h1"},
+				{ID: 2, Hostname: "h2", TeamID: ptr.Uint(1)},
+				{ID: 3, Hostname: "h3", TeamID: ptr.Uint(3)},
+			}, nil
+		}
+		jsonCVE := fmt.Sprintf(`{"timestamp":"%s","vulnerability":{"cve":"CVE-2012-1234","details_link":"https://nvd.nist.gov/vuln/detail/CVE-2012-1234","hosts_affected":`,
+			now.Format(time.RFC3339Nano))
+		jsonHost := func(id int) string {
+			return fmt.Sprintf(`{"id":%d,"hostname":"h%[1]d","url":"%s/hosts/%[1]d"}`, id, appCfg.ServerSettings.ServerURL)
+		}
+
+		// the global webhook is disabled, only the team webhooks are sent, with
+		// the hosts of the team.
+		appCfg := *appCfg
+		appCfg.WebhookSettings.VulnerabilitiesWebhook.Enable = false
+		err := TriggerVulnerabilitiesWebhook(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-2012-1234", SoftwareID: 1}}, &appCfg, now)
+		require.NoError(t, err)
+		require.Len(t, requests, 1)
+		assert.Equal(t, []string{fmt.Sprintf("%s[%s]}}", jsonCVE, jsonHost(2))}, requests["/team1"])
+
+		// the global webhook is enabled, it gets all the hosts
+		requests = make(map[string][]string)
+		appCfg.WebhookSettings.VulnerabilitiesWebhook.Enable = true
+		appCfg.WebhookSettings.VulnerabilitiesWebhook.HostBatchSize = 0
+		appCfg.WebhookSettings.VulnerabilitiesWebhook.DestinationURL = srv.URL + "/global"
+		err = TriggerVulnerabilitiesWebhook(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-2012-1234", SoftwareID: 1}}, &appCfg, now)
+		require.NoError(t, err)
+		require.Len(t, requests, 2)
+		assert.Equal(t, []string{fmt.Sprintf("%s[%s]}}", jsonCVE, jsonHost(2))}, requests["/team1"])
+		assert.Equal(t, []string{fmt.Sprintf("%s[%s,%s,%s]}}", jsonCVE, jsonHost(1), jsonHost(2), jsonHost(3))}, requests["/global"])
+	})
 }
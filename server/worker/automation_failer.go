@@ -60,6 +60,40 @@ func (f *TestAutomationFailer) CreateZendeskTicket(ctx context.Context, ticket *
 	return f.ZendeskClient.CreateZendeskTicket(ctx, ticket)
 }
 
+// AddJiraComment implements the JiraClient and introduces a forced failure if
+// required, otherwise it returns the result of calling
+// f.JiraClient.AddJiraComment with the provided arguments.
+func (f *TestAutomationFailer) AddJiraComment(ctx context.Context, issueKey, body string) error {
+	if err := f.forceErr(body); err != nil {
+		return err
+	}
+	return f.JiraClient.AddJiraComment(ctx, issueKey, body)
+}
+
+// ResolveJiraIssue implements the JiraClient and introduces a forced failure if
+// required, otherwise it returns the result of calling
+// f.JiraClient.ResolveJiraIssue with the provided arguments.
+func (f *TestAutomationFailer) ResolveJiraIssue(ctx context.Context, issueKey string) error {
+	if err := f.forceErr(issueKey); err != nil {
+		return err
+	}
+	return f.JiraClient.ResolveJiraIssue(ctx, issueKey)
+}
+
+// UpdateZendeskTicket implements the ZendeskClient and introduces a forced
+// failure if required, otherwise it returns the result of calling
+// f.ZendeskClient.UpdateZendeskTicket with the provided arguments.
+func (f *TestAutomationFailer) UpdateZendeskTicket(ctx context.Context, ticketID int64, ticket *zendesk.Ticket) (*zendesk.Ticket, error) {
+	var testValue string
+	if ticket.Comment != nil {
+		testValue = ticket.Comment.Body
+	}
+	if err := f.forceErr(testValue); err != nil {
+		return nil, err
+	}
+	return f.ZendeskClient.UpdateZendeskTicket(ctx, ticketID, ticket)
+}
+
 func (f *TestAutomationFailer) JiraConfigMatches(opts *externalsvc.JiraOptions) bool {
 	return f.JiraClient.JiraConfigMatches(opts)
 }
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// chatMaxHosts is the maximum number of hosts listed in a Slack or
+	// Microsoft Teams message, the others are only counted.
+	chatMaxHosts = 25
+	// chatMaxResolutionLen is the maximum length of the policy resolution text
+	// in a Slack or Microsoft Teams message, as they limit the size of a block.
+	chatMaxResolutionLen = 2000
+)
+
+// chatMessage is the content of a message posted to a chat integration
+// (Slack or Microsoft Teams), before it is formatted for that service.
+type chatMessage struct {
+	Title string
+	// CVE and NVDURL are set for vulnerabilities.
+	CVE    string
+	NVDURL string
+	// Resolution is set for failing policies that have one.
+	Resolution string
+	HostsLabel string
+	Hosts      []webhookHost
+	// MoreHosts is the number of hosts that are not listed.
+	MoreHosts int
+	// ViewURL is the link to the corresponding page in Fleet.
+	ViewURL string
+}
+
+// newVulnChatMessage returns the chat message for a vulnerability affecting
+// the hosts.
+func newVulnChatMessage(fleetURL, cve string, hosts []webhookHost) chatMessage {
+	msg := chatMessage{
+		Title:      fmt.Sprintf("Vulnerability %s detected on %d host(s)", cve, len(hosts)),
+		CVE:        cve,
+		NVDURL:     nvdCVEURL + cve,
+		HostsLabel: "Affected hosts",
+		ViewURL:    fleetPageURL(fleetURL, "software/manage", nil),
+	}
+	msg.setHosts(hosts)
+	return msg
+}
+
+// newFailingPolicyChatMessage returns the chat message for a policy failing
+// on the hosts.
+func newFailingPolicyChatMessage(fleetURL string, args *failingPolicyArgs, hosts []webhookHost) chatMessage {
+	resolution := args.Resolution
+	if len(resolution) > chatMaxResolutionLen {
+		resolution = resolution[:chatMaxResolutionLen] + "..."
+	}
+
+	query := url.Values{
+		"order_key":       {"hostname"},
+		"order_direction": {"asc"},
+		"policy_id":       {strconv.Itoa(int(args.PolicyID))},
+		"policy_response": {"failing"},
+	}
+	if args.TeamID != nil {
+		query.Set("team_id", strconv.Itoa(int(*args.TeamID)))
+	}
+
+	msg := chatMessage{
+		Title:      fmt.Sprintf("%s policy failed on %d host(s)", args.PolicyName, len(hosts)),
+		Resolution: resolution,
+		HostsLabel: "Failing hosts",
+		ViewURL:    fleetPageURL(fleetURL, "hosts/manage/", query),
+	}
+	msg.setHosts(hosts)
+	return msg
+}
+
+// chatFailingPolicyArgs returns the failing policy arguments of a Slack or
+// Microsoft Teams job, including the resolution of the policy. It returns
+// false if there is no host, in which case no job should be queued.
+func chatFailingPolicyArgs(logger kitlog.Logger, policy *fleet.Policy, hosts []fleet.PolicySetHost) (*failingPolicyArgs, bool) {
+	attrs := []interface{}{
+		"enabled", "true",
+		"failing_policy", policy.ID,
+		"hosts_count", len(hosts),
+	}
+	if policy.TeamID != nil {
+		attrs = append(attrs, "team_id", *policy.TeamID)
+	}
+	if len(hosts) == 0 {
+		attrs = append(attrs, "msg", "skipping, no host")
+		level.Debug(logger).Log(attrs...)
+		return nil, false
+	}
+
+	level.Info(logger).Log(attrs...)
+
+	args := &failingPolicyArgs{
+		PolicyID:   policy.ID,
+		PolicyName: policy.Name,
+		TeamID:     policy.TeamID,
+		Hosts:      hosts,
+	}
+	if policy.Resolution != nil {
+		args.Resolution = *policy.Resolution
+	}
+	return args, true
+}
+
+func (m *chatMessage) setHosts(hosts []webhookHost) {
+	if len(hosts) > chatMaxHosts {
+		m.MoreHosts = len(hosts) - chatMaxHosts
+		hosts = hosts[:chatMaxHosts]
+	}
+	m.Hosts = hosts
+}
+
+// fleetHostURL returns the link to the host's page in Fleet.
+func fleetHostURL(fleetURL string, hostID uint) string {
+	return fleetPageURL(fleetURL, path.Join("hosts", strconv.Itoa(int(hostID))), nil)
+}
+
+// fleetPageURL returns the link to the page of Fleet at the relative path,
+// with the optional query string.
+func fleetPageURL(fleetURL, relPath string, query url.Values) string {
+	u, err := url.Parse(fleetURL)
+	if err != nil {
+		return ""
+	}
+	u.Path = path.Join(u.Path, relPath)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
@@ -25,6 +25,7 @@ var jiraTemplates = struct {
 	VulnDescription          *template.Template
 	FailingPolicySummary     *template.Template
 	FailingPolicyDescription *template.Template
+	ResolvedPolicyComment    *template.Template
 }{
 	VulnSummary: template.Must(template.New("").Parse(
 		`Vulnerability {{ .CVE }} detected on {{ len .Hosts }} host(s)`,
@@ -68,6 +69,16 @@ View hosts that failed {{ .PolicyName }} on the [*Hosts*|{{ .FleetURL }}/hosts/m
 ----
 
 This issue was created automatically by your Fleet Jira integration.
+`)),
+
+	ResolvedPolicyComment: template.Must(template.New("").Parse(
+		`Host [{{ .Hostname }}|{{ .FleetURL }}/hosts/{{ .HostID }}] is now passing this policy.
+{{ if .Resolved }}
+All hosts are now passing this policy, resolving this issue.
+{{ end }}
+----
+
+This comment was added automatically by your Fleet Jira integration.
 `)),
 }
 
@@ -90,6 +101,8 @@ type jiraFailingPoliciesTplArgs struct {
 // to Jira.
 type JiraClient interface {
 	CreateJiraIssue(ctx context.Context, issue *jira.Issue) (*jira.Issue, error)
+	AddJiraComment(ctx context.Context, issueKey, body string) error
+	ResolveJiraIssue(ctx context.Context, issueKey string) error
 	JiraConfigMatches(opts *externalsvc.JiraOptions) bool
 }
 
@@ -118,10 +131,22 @@ func (j *Jira) getClient(ctx context.Context, args jiraArgs) (JiraClient, error)
 	var teamID uint
 	var useTeamCfg bool
 
+	var argsTeamID *uint
 	intgType := args.integrationType()
+	switch intgType {
+	case intgTypeVuln:
+		argsTeamID = args.TeamID
+	case intgTypeFailingPolicy:
+		argsTeamID = args.FailingPolicy.TeamID
+	case intgTypeResolvedPolicy:
+		// the ticket is resolved with the failing policies integration that
+		// created it.
+		intgType = intgTypeFailingPolicy
+		argsTeamID = args.ResolvedPolicy.TeamID
+	}
 	key := intgType + ":"
-	if intgType == intgTypeFailingPolicy && args.FailingPolicy.TeamID != nil {
-		teamID = *args.FailingPolicy.TeamID
+	if argsTeamID != nil {
+		teamID = *argsTeamID
 		useTeamCfg = true
 		key += fmt.Sprint(teamID)
 	}
@@ -146,7 +171,8 @@ func (j *Jira) getClient(ctx context.Context, args jiraArgs) (JiraClient, error)
 			return nil, err
 		}
 		for _, intg := range intgs.Jira {
-			if intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies {
+			if (intgType == intgTypeVuln && intg.EnableSoftwareVulnerabilities) ||
+				(intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies) {
 				opts = &externalsvc.JiraOptions{
 					BaseURL:           intg.URL,
 					BasicAuthUsername: intg.Username,
@@ -199,11 +225,19 @@ func (j *Jira) getClient(ctx context.Context, args jiraArgs) (JiraClient, error)
 
 // jiraArgs are the arguments for the Jira integration job.
 type jiraArgs struct {
-	CVE           string             `json:"cve,omitempty"`
-	FailingPolicy *failingPolicyArgs `json:"failing_policy,omitempty"`
+	CVE string `json:"cve,omitempty"`
+	// TeamID is the team of the Jira integration to use for a vulnerability,
+	// only the hosts of that team are listed in the issue. It is nil for the
+	// global integration.
+	TeamID         *uint               `json:"team_id,omitempty"`
+	FailingPolicy  *failingPolicyArgs  `json:"failing_policy,omitempty"`
+	ResolvedPolicy *resolvedPolicyArgs `json:"resolved_policy,omitempty"`
 }
 
 func (a *jiraArgs) integrationType() string {
+	if a.ResolvedPolicy != nil {
+		return intgTypeResolvedPolicy
+	}
 	if a.FailingPolicy == nil {
 		return intgTypeVuln
 	}
@@ -233,6 +267,8 @@ func (j *Jira) Run(ctx context.Context, argsJSON json.RawMessage) error {
 		return j.runVuln(ctx, cli, args)
 	case intgTypeFailingPolicy:
 		return j.runFailingPolicy(ctx, cli, args)
+	case intgTypeResolvedPolicy:
+		return j.runResolvedPolicy(ctx, cli, args)
 	default:
 		return ctxerr.Errorf(ctx, "unknown integration type: %v", intgType)
 	}
@@ -243,6 +279,13 @@ func (j *Jira) runVuln(ctx context.Context, cli JiraClient, args jiraArgs) error
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "find hosts by cve")
 	}
+	hosts = filterHostsByTeam(hosts, args.TeamID)
+	if len(hosts) == 0 && args.TeamID != nil {
+		// the hosts of the team are not affected anymore (e.g. they were moved
+		// to another team since the job was queued).
+		level.Debug(j.Log).Log("msg", "skipping cve, no affected host in team", "cve", args.CVE, "team_id", *args.TeamID)
+		return nil
+	}
 
 	tplArgs := &jiraVulnTplArgs{
 		NVDURL:   nvdCVEURL,
@@ -278,6 +321,14 @@ func (j *Jira) runFailingPolicy(ctx context.Context, cli JiraClient, args jiraAr
 		return err
 	}
 
+	// record the issue so that it gets resolved when the hosts pass the policy
+	// again. The issue is created at this point, so do not fail the job (it
+	// would create a duplicate issue when retried).
+	if err := j.Datastore.NewPolicyAutomationTickets(ctx, jiraName, createdIssue.Key, args.FailingPolicy.PolicyID,
+		args.FailingPolicy.TeamID, policyTicketHostIDs(args.FailingPolicy.Hosts)); err != nil {
+		level.Error(j.Log).Log("msg", "failed to record jira issue for failing policy", "issue_key", createdIssue.Key, "err", err)
+	}
+
 	attrs := []interface{}{
 		"msg", "created jira issue for failing policy",
 		"policy_id", args.FailingPolicy.PolicyID,
@@ -292,6 +343,57 @@ func (j *Jira) runFailingPolicy(ctx context.Context, cli JiraClient, args jiraAr
 	return nil
 }
 
+func (j *Jira) runResolvedPolicy(ctx context.Context, cli JiraClient, args jiraArgs) error {
+	rp := args.ResolvedPolicy
+	openHostIDs, err := j.Datastore.ListPolicyAutomationTicketOpenHosts(ctx, jiraName, rp.TicketID)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list policy automation ticket open hosts")
+	}
+	resolved, ok := resolvesPolicyTicket(openHostIDs, rp.HostID)
+	if !ok {
+		level.Debug(j.Log).Log("msg", "skipping, jira issue already resolved for host", "issue_key", rp.TicketID, "host_id", rp.HostID)
+		return nil
+	}
+
+	tplArgs := &resolvedPolicyTplArgs{
+		FleetURL: j.FleetURL,
+		HostID:   rp.HostID,
+		Hostname: rp.Hostname,
+		Resolved: resolved,
+	}
+	var buf bytes.Buffer
+	if err := jiraTemplates.ResolvedPolicyComment.Execute(&buf, tplArgs); err != nil {
+		return ctxerr.Wrap(ctx, err, "execute comment template")
+	}
+	if err := cli.AddJiraComment(ctx, rp.TicketID, buf.String()); err != nil {
+		return ctxerr.Wrap(ctx, err, "add comment")
+	}
+	if tplArgs.Resolved {
+		if err := cli.ResolveJiraIssue(ctx, rp.TicketID); err != nil {
+			return ctxerr.Wrap(ctx, err, "resolve issue")
+		}
+	}
+
+	// the issue is marked as resolved for the host only once it is updated,
+	// so that the job is retried otherwise.
+	if err := j.Datastore.ResolvePolicyAutomationTicket(ctx, jiraName, rp.TicketID, rp.HostID); err != nil {
+		return ctxerr.Wrap(ctx, err, "resolve policy automation ticket")
+	}
+
+	attrs := []interface{}{
+		"msg", "updated jira issue for passing policy",
+		"policy_id", rp.PolicyID,
+		"host_id", rp.HostID,
+		"issue_key", rp.TicketID,
+		"resolved", tplArgs.Resolved,
+	}
+	if rp.TeamID != nil {
+		attrs = append(attrs, "team_id", *rp.TeamID)
+	}
+	level.Debug(j.Log).Log(attrs...)
+	return nil
+}
+
 func (j *Jira) createTemplatedIssue(ctx context.Context, cli JiraClient, summaryTpl, descTpl *template.Template, args interface{}) (*jira.Issue, error) {
 	var buf bytes.Buffer
 	if err := summaryTpl.Execute(&buf, args); err != nil {
@@ -347,6 +449,16 @@ func QueueJiraVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Lo
 	return nil
 }
 
+// QueueJiraTeamVulnJobs queues a Jira vulnerability job for each of the
+// teams in teamIDs that has hosts affected by a recent vulnerability, to
+// process asynchronously via the worker. Each job uses the Jira integration
+// of its team and lists only the hosts of that team.
+func QueueJiraTeamVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, recentVulns []fleet.SoftwareVulnerability, teamIDs []uint) error {
+	return queueTeamVulnJobs(ctx, ds, logger, jiraName, recentVulns, teamIDs, func(cve string, teamID uint) interface{} {
+		return jiraArgs{CVE: cve, TeamID: &teamID}
+	})
+}
+
 // QueueJiraFailingPolicyJob queues a Jira job for a failing policy to process
 // asynchronously via the worker.
 func QueueJiraFailingPolicyJob(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
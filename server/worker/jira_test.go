@@ -52,6 +52,14 @@ func TestJiraRun(t *testing.T) {
 		}, nil
 	}
 
+	var gotTicketID string
+	var gotHostIDs []uint
+	ds.NewPolicyAutomationTicketsFunc = func(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+		require.Equal(t, jiraName, integration)
+		gotTicketID, gotHostIDs = ticketID, hostIDs
+		return nil
+	}
+
 	var expectedSummary, expectedDescription, expectedNotInDescription string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -126,6 +134,8 @@ func TestJiraRun(t *testing.T) {
 		expectedNotInDescription = ""
 		err = jira.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 2, "policy_name": "test-policy-2", "team_id": 123, "hosts": [{"id": 1, "hostname": "test-1"}, {"id": 2, "hostname": "test-2"}]}}`))
 		require.NoError(t, err)
+		require.Equal(t, "ED-24", gotTicketID)
+		require.Equal(t, []uint{1, 2}, gotHostIDs)
 	})
 }
 
@@ -154,6 +164,115 @@ func TestJiraQueueVulnJobs(t *testing.T) {
 	})
 }
 
+func TestJiraRunTeamVuln(t *testing.T) {
+	ds := new(mock.Store)
+	ds.HostsByCVEFunc = func(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{ID: 1, Hostname: "no-team"},
+			{ID: 2, Hostname: "team-1", TeamID: ptr.Uint(1)},
+			{ID: 3, Hostname: "team-2", TeamID: ptr.Uint(2)},
+			{ID: 4, Hostname: "team-2-bis", TeamID: ptr.Uint(2)},
+		}, nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Jira: []*fleet.JiraIntegration{
+				{ProjectKey: "global", EnableSoftwareVulnerabilities: true},
+				{ProjectKey: "team"},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		return &fleet.Team{
+			ID: tid,
+			Config: fleet.TeamConfig{
+				Integrations: fleet.TeamIntegrations{
+					Jira: []*fleet.TeamJiraIntegration{
+						{ProjectKey: "team", EnableSoftwareVulnerabilities: tid == 2},
+					},
+				},
+			},
+		}, nil
+	}
+
+	clients := make(map[string]*mockJiraClient)
+	jiraJob := &Jira{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.JiraOptions) (JiraClient, error) {
+			cli := &mockJiraClient{opts: *opts}
+			clients[opts.ProjectKey] = cli
+			return cli, nil
+		},
+	}
+	ctx := context.Background()
+
+	// the global job lists all hosts
+	err := jiraJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+	require.NoError(t, err)
+	require.Len(t, clients["global"].issues, 1)
+	require.Equal(t, "Vulnerability CVE-1234-5678 detected on 4 host(s)", clients["global"].issues[0].Fields.Summary)
+
+	// the team job uses the team integration and lists only the team's hosts
+	err = jiraJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678","team_id":2}`))
+	require.NoError(t, err)
+	require.Len(t, clients["team"].issues, 1)
+	require.Equal(t, "Vulnerability CVE-1234-5678 detected on 2 host(s)", clients["team"].issues[0].Fields.Summary)
+	require.Contains(t, clients["team"].issues[0].Fields.Description, "team-2-bis")
+	require.NotContains(t, clients["team"].issues[0].Fields.Description, "no-team")
+
+	// the integration is not enabled for software vulnerabilities on team 1,
+	// the job is a no-op
+	err = jiraJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678","team_id":1}`))
+	require.NoError(t, err)
+	require.Len(t, clients["team"].issues, 1)
+}
+
+func TestJiraQueueTeamVulnJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	ds.HostsBySoftwareIDsFunc = func(ctx context.Context, softwareIDs []uint) ([]*fleet.HostShort, error) {
+		switch softwareIDs[0] {
+		case 1:
+			return []*fleet.HostShort{
+				{ID: 1},
+				{ID: 2, TeamID: ptr.Uint(1)},
+				{ID: 3, TeamID: ptr.Uint(2)},
+				{ID: 4, TeamID: ptr.Uint(3)},
+			}, nil
+		default:
+			return []*fleet.HostShort{{ID: 1}}, nil
+		}
+	}
+	var jobs []jiraArgs
+	ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+		var args jiraArgs
+		require.NoError(t, json.Unmarshal(*job.Args, &args))
+		jobs = append(jobs, args)
+		return job, nil
+	}
+
+	// team 3 has no integration, and no team is affected by the second CVE
+	err := QueueJiraTeamVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{
+		{CVE: "CVE-1234-5678", SoftwareID: 1},
+		{CVE: "CVE-1234-9999", SoftwareID: 2},
+	}, []uint{1, 2})
+	require.NoError(t, err)
+	require.Equal(t, []jiraArgs{
+		{CVE: "CVE-1234-5678", TeamID: ptr.Uint(1)},
+		{CVE: "CVE-1234-5678", TeamID: ptr.Uint(2)},
+	}, jobs)
+
+	// no team with the integration enabled
+	jobs = nil
+	err = QueueJiraTeamVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-1234-5678", SoftwareID: 1}}, nil)
+	require.NoError(t, err)
+	require.Empty(t, jobs)
+}
+
 func TestJiraQueueFailingPolicyJob(t *testing.T) {
 	ds := new(mock.Store)
 	ctx := context.Background()
@@ -208,13 +327,35 @@ func TestJiraQueueFailingPolicyJob(t *testing.T) {
 }
 
 type mockJiraClient struct {
-	opts externalsvc.JiraOptions
+	opts     externalsvc.JiraOptions
+	issues   []*jira.Issue
+	comments map[string][]string
+	resolved []string
+	// updateErr is returned when adding a comment, if set.
+	updateErr error
 }
 
 func (c *mockJiraClient) CreateJiraIssue(ctx context.Context, issue *jira.Issue) (*jira.Issue, error) {
+	c.issues = append(c.issues, issue)
 	return &jira.Issue{}, nil
 }
 
+func (c *mockJiraClient) AddJiraComment(ctx context.Context, issueKey, body string) error {
+	if c.updateErr != nil {
+		return c.updateErr
+	}
+	if c.comments == nil {
+		c.comments = make(map[string][]string)
+	}
+	c.comments[issueKey] = append(c.comments[issueKey], body)
+	return nil
+}
+
+func (c *mockJiraClient) ResolveJiraIssue(ctx context.Context, issueKey string) error {
+	c.resolved = append(c.resolved, issueKey)
+	return nil
+}
+
 func (c *mockJiraClient) JiraConfigMatches(opts *externalsvc.JiraOptions) bool {
 	return c.opts == *opts
 }
@@ -273,6 +414,10 @@ func TestJiraRunClientUpdate(t *testing.T) {
 		return &curCfg, nil
 	}
 
+	ds.NewPolicyAutomationTicketsFunc = func(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+		return nil
+	}
+
 	var projectKeys []string
 	jiraJob := &Jira{
 		FleetURL:  "http://example.com",
@@ -310,3 +455,107 @@ func TestJiraRunClientUpdate(t *testing.T) {
 	require.Equal(t, 5, globalCount) // app config is requested every time
 	require.Equal(t, 3, teamCount)
 }
+
+func TestJiraRunResolvedPolicy(t *testing.T) {
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Jira: []*fleet.JiraIntegration{
+				{ProjectKey: "global", EnableFailingPolicies: true},
+				{ProjectKey: "team"},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		return &fleet.Team{
+			ID: tid,
+			Config: fleet.TeamConfig{
+				Integrations: fleet.TeamIntegrations{
+					Jira: []*fleet.TeamJiraIntegration{
+						{ProjectKey: "team", EnableFailingPolicies: tid == 2},
+					},
+				},
+			},
+		}, nil
+	}
+
+	// ED-1 has two hosts, ED-2 has a single one
+	openHosts := map[string]map[uint]bool{
+		"ED-1": {1: true, 2: true},
+		"ED-2": {3: true},
+	}
+	ds.ListPolicyAutomationTicketOpenHostsFunc = func(ctx context.Context, integration, ticketID string) ([]uint, error) {
+		require.Equal(t, jiraName, integration)
+		var hostIDs []uint
+		for id := range openHosts[ticketID] {
+			hostIDs = append(hostIDs, id)
+		}
+		return hostIDs, nil
+	}
+	ds.ResolvePolicyAutomationTicketFunc = func(ctx context.Context, integration, ticketID string, hostID uint) error {
+		require.Equal(t, jiraName, integration)
+		delete(openHosts[ticketID], hostID)
+		return nil
+	}
+
+	// the global client fails to update the issues at first
+	clients := map[string]*mockJiraClient{
+		"global": {updateErr: errors.New("unavailable")},
+	}
+	jiraJob := &Jira{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.JiraOptions) (JiraClient, error) {
+			cli := clients[opts.ProjectKey]
+			if cli == nil {
+				cli = &mockJiraClient{}
+				clients[opts.ProjectKey] = cli
+			}
+			cli.opts = *opts
+			return cli, nil
+		},
+	}
+	ctx := context.Background()
+
+	// the issue is not marked as resolved for the host if it can't be updated
+	err := jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 1, "hostname": "host-1", "ticket_id": "ED-1"}}`))
+	require.Error(t, err)
+	require.False(t, ds.ResolvePolicyAutomationTicketFuncInvoked)
+	require.True(t, openHosts["ED-1"][1])
+
+	// the first host passes, the issue is commented but not resolved
+	clients["global"].updateErr = nil
+	err = jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 1, "hostname": "host-1", "ticket_id": "ED-1"}}`))
+	require.NoError(t, err)
+	require.Len(t, clients["global"].comments["ED-1"], 1)
+	require.Contains(t, clients["global"].comments["ED-1"][0], "Host [host-1|http://example.com/hosts/1] is now passing this policy.")
+	require.NotContains(t, clients["global"].comments["ED-1"][0], "resolving this issue")
+	require.Empty(t, clients["global"].resolved)
+	require.False(t, openHosts["ED-1"][1])
+
+	// the issue is already resolved for the host, so a duplicate job is a
+	// no-op
+	err = jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 1, "hostname": "host-1", "ticket_id": "ED-1"}}`))
+	require.NoError(t, err)
+	require.Len(t, clients["global"].comments["ED-1"], 1)
+
+	// the last host passes, the issue is resolved
+	err = jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 2, "hostname": "host-2", "ticket_id": "ED-1"}}`))
+	require.NoError(t, err)
+	require.Len(t, clients["global"].comments["ED-1"], 2)
+	require.Contains(t, clients["global"].comments["ED-1"][1], "All hosts are now passing this policy, resolving this issue.")
+	require.Equal(t, []string{"ED-1"}, clients["global"].resolved)
+
+	// the team's integration is used for a team policy
+	err = jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 2, "team_id": 2, "host_id": 3, "hostname": "host-3", "ticket_id": "ED-2"}}`))
+	require.NoError(t, err)
+	require.Len(t, clients["team"].comments["ED-2"], 1)
+	require.Equal(t, []string{"ED-2"}, clients["team"].resolved)
+
+	// failing policies are disabled for team 1, so this is a no-op
+	ds.ResolvePolicyAutomationTicketFuncInvoked = false
+	err = jiraJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 3, "team_id": 1, "host_id": 4, "hostname": "host-4", "ticket_id": "ED-3"}}`))
+	require.NoError(t, err)
+	require.False(t, ds.ResolvePolicyAutomationTicketFuncInvoked)
+}
@@ -0,0 +1,273 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// microsoftTeamsName is the name of the job as registered in the worker.
+	microsoftTeamsName = "microsoft_teams"
+	// microsoftTeamsThemeColor is the accent color of the message cards.
+	microsoftTeamsThemeColor = "6A67FE"
+)
+
+// MicrosoftTeamsClient defines the method required for the client that posts
+// messages to Microsoft Teams.
+type MicrosoftTeamsClient interface {
+	PostMicrosoftTeamsMessage(ctx context.Context, msg *externalsvc.MicrosoftTeamsMessage) error
+	MicrosoftTeamsConfigMatches(opts *externalsvc.MicrosoftTeamsOptions) bool
+}
+
+// MicrosoftTeams is the job processor for Microsoft Teams integrations.
+type MicrosoftTeams struct {
+	FleetURL      string
+	Datastore     fleet.Datastore
+	Log           kitlog.Logger
+	NewClientFunc func(*externalsvc.MicrosoftTeamsOptions) (MicrosoftTeamsClient, error)
+
+	// mu protects concurrent access to clientsCache, so that the job processor
+	// can potentially be run concurrently.
+	mu sync.Mutex
+	// map of integration type + team ID to Microsoft Teams client (empty team
+	// ID for global), e.g. "vuln:123", "failingPolicy:", etc.
+	clientsCache map[string]MicrosoftTeamsClient
+}
+
+// Name returns the name of the job.
+func (t *MicrosoftTeams) Name() string {
+	return microsoftTeamsName
+}
+
+// returns nil, nil if there is no integration enabled for that message.
+func (t *MicrosoftTeams) getClient(ctx context.Context, args microsoftTeamsArgs) (MicrosoftTeamsClient, error) {
+	var teamID uint
+	var useTeamCfg bool
+
+	intgType := args.integrationType()
+	key := intgType + ":"
+	if intgType == intgTypeFailingPolicy && args.FailingPolicy.TeamID != nil {
+		teamID = *args.FailingPolicy.TeamID
+		useTeamCfg = true
+		key += fmt.Sprint(teamID)
+	}
+
+	ac, err := t.Datastore.AppConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// load the config that would be used to create the client first - it is
+	// needed to check if an existing client is configured the same or if its
+	// configuration has changed since it was created.
+	var opts *externalsvc.MicrosoftTeamsOptions
+	if useTeamCfg {
+		tm, err := t.Datastore.Team(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+
+		intgs, err := tm.Config.Integrations.MatchWithIntegrations(ac.Integrations)
+		if err != nil {
+			return nil, err
+		}
+		for _, intg := range intgs.MicrosoftTeams {
+			if intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies {
+				opts = &externalsvc.MicrosoftTeamsOptions{URL: intg.URL}
+				break
+			}
+		}
+	} else {
+		for _, intg := range ac.Integrations.MicrosoftTeams {
+			if (intgType == intgTypeVuln && intg.EnableSoftwareVulnerabilities) ||
+				(intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies) {
+				opts = &externalsvc.MicrosoftTeamsOptions{URL: intg.URL}
+				break
+			}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clientsCache == nil {
+		t.clientsCache = make(map[string]MicrosoftTeamsClient)
+	}
+	if opts == nil {
+		// no integration configured, clear any existing one
+		delete(t.clientsCache, key)
+		return nil, nil
+	}
+
+	// check if the existing one can be reused
+	if cli := t.clientsCache[key]; cli != nil && cli.MicrosoftTeamsConfigMatches(opts) {
+		return cli, nil
+	}
+
+	// otherwise create a new one
+	cli, err := t.NewClientFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+	t.clientsCache[key] = cli
+	return cli, nil
+}
+
+// microsoftTeamsArgs are the arguments for the Microsoft Teams integration
+// job.
+type microsoftTeamsArgs struct {
+	CVE           string             `json:"cve,omitempty"`
+	FailingPolicy *failingPolicyArgs `json:"failing_policy,omitempty"`
+}
+
+func (a *microsoftTeamsArgs) integrationType() string {
+	if a.FailingPolicy == nil {
+		return intgTypeVuln
+	}
+	return intgTypeFailingPolicy
+}
+
+// Run executes the Microsoft Teams job.
+func (t *MicrosoftTeams) Run(ctx context.Context, argsJSON json.RawMessage) error {
+	var args microsoftTeamsArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return ctxerr.Wrap(ctx, err, "unmarshal args")
+	}
+
+	cli, err := t.getClient(ctx, args)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get Microsoft Teams client")
+	}
+	if cli == nil {
+		// this message was queued when an integration was enabled, but since
+		// then it has been disabled, so return success to mark the message
+		// as processed.
+		return nil
+	}
+
+	var msg chatMessage
+	switch intgType := args.integrationType(); intgType {
+	case intgTypeVuln:
+		hosts, err := t.Datastore.HostsByCVE(ctx, args.CVE)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "find hosts by cve")
+		}
+		whHosts := make([]webhookHost, 0, len(hosts))
+		for _, h := range hosts {
+			whHosts = append(whHosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(t.FleetURL, h.ID)})
+		}
+		msg = newVulnChatMessage(t.FleetURL, args.CVE, whHosts)
+
+	case intgTypeFailingPolicy:
+		whHosts := make([]webhookHost, 0, len(args.FailingPolicy.Hosts))
+		for _, h := range args.FailingPolicy.Hosts {
+			whHosts = append(whHosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(t.FleetURL, h.ID)})
+		}
+		msg = newFailingPolicyChatMessage(t.FleetURL, args.FailingPolicy, whHosts)
+
+	default:
+		return ctxerr.Errorf(ctx, "unknown integration type: %v", intgType)
+	}
+
+	if err := cli.PostMicrosoftTeamsMessage(ctx, formatMicrosoftTeamsMessage(msg)); err != nil {
+		return ctxerr.Wrap(ctx, err, "post Microsoft Teams message")
+	}
+
+	attrs := []interface{}{"msg", "posted Microsoft Teams message", "type", args.integrationType()}
+	if args.CVE != "" {
+		attrs = append(attrs, "cve", args.CVE)
+	}
+	if args.FailingPolicy != nil {
+		attrs = append(attrs, "policy_id", args.FailingPolicy.PolicyID, "policy_name", args.FailingPolicy.PolicyName)
+		if args.FailingPolicy.TeamID != nil {
+			attrs = append(attrs, "team_id", *args.FailingPolicy.TeamID)
+		}
+	}
+	level.Debug(t.Log).Log(attrs...)
+	return nil
+}
+
+// formatMicrosoftTeamsMessage formats the chat message as a Microsoft Teams
+// message card.
+func formatMicrosoftTeamsMessage(msg chatMessage) *externalsvc.MicrosoftTeamsMessage {
+	card := &externalsvc.MicrosoftTeamsMessage{
+		Summary:    msg.Title,
+		ThemeColor: microsoftTeamsThemeColor,
+		Title:      msg.Title,
+	}
+	if msg.CVE != "" {
+		card.Text = fmt.Sprintf("See vulnerability (CVE) details in National Vulnerability Database (NVD) here: [%s](%s)", msg.CVE, msg.NVDURL)
+	}
+	if msg.Resolution != "" {
+		card.Sections = append(card.Sections, externalsvc.MicrosoftTeamsSection{Title: "Resolution", Text: msg.Resolution})
+	}
+
+	lines := make([]string, 0, len(msg.Hosts)+1)
+	for _, h := range msg.Hosts {
+		lines = append(lines, fmt.Sprintf("- [%s](%s)", h.Hostname, h.URL))
+	}
+	if msg.MoreHosts > 0 {
+		lines = append(lines, fmt.Sprintf("_and %d more_", msg.MoreHosts))
+	}
+	card.Sections = append(card.Sections,
+		externalsvc.MicrosoftTeamsSection{Title: msg.HostsLabel, Text: strings.Join(lines, "\n\n")},
+		externalsvc.MicrosoftTeamsSection{Text: "This message was posted automatically by your Fleet Microsoft Teams integration."},
+	)
+
+	card.PotentialAction = append(card.PotentialAction, externalsvc.MicrosoftTeamsAction{Name: "View in Fleet", URI: msg.ViewURL})
+	if msg.NVDURL != "" {
+		card.PotentialAction = append(card.PotentialAction, externalsvc.MicrosoftTeamsAction{Name: "View in NVD", URI: msg.NVDURL})
+	}
+	return card
+}
+
+// QueueMicrosoftTeamsVulnJobs queues the Microsoft Teams vulnerability jobs
+// to process asynchronously via the worker.
+func QueueMicrosoftTeamsVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, recentVulns []fleet.SoftwareVulnerability) error {
+	level.Info(logger).Log("enabled", "true", "recentVulns", len(recentVulns))
+
+	// for troubleshooting, log in debug level the CVEs that we will process
+	// (cannot be done in the loop below as we want to add the debug log
+	// _before_ we start processing them).
+	cves := make([]string, 0, len(recentVulns))
+	for _, vuln := range recentVulns {
+		cves = append(cves, vuln.CVE)
+	}
+	sort.Strings(cves)
+	level.Debug(logger).Log("recent_cves", fmt.Sprintf("%v", cves))
+
+	for _, vuln := range recentVulns {
+		job, err := QueueJob(ctx, ds, microsoftTeamsName, microsoftTeamsArgs{CVE: vuln.CVE})
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "queueing job")
+		}
+		level.Debug(logger).Log("job_id", job.ID)
+	}
+	return nil
+}
+
+// QueueMicrosoftTeamsFailingPolicyJob queues a Microsoft Teams job for a
+// failing policy to process asynchronously via the worker.
+func QueueMicrosoftTeamsFailingPolicyJob(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
+	policy *fleet.Policy, hosts []fleet.PolicySetHost) error {
+	args, ok := chatFailingPolicyArgs(logger, policy, hosts)
+	if !ok {
+		return nil
+	}
+	job, err := QueueJob(ctx, ds, microsoftTeamsName, microsoftTeamsArgs{FailingPolicy: args})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "queueing job")
+	}
+	level.Debug(logger).Log("job_id", job.ID)
+	return nil
+}
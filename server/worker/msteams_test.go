@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMicrosoftTeamsRun(t *testing.T) {
+	ds := new(mock.Store)
+	ds.HostsByCVEFunc = func(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{
+				ID:       1,
+				Hostname: "test",
+			},
+		}, nil
+	}
+
+	var srvURL string
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			MicrosoftTeams: []*fleet.MicrosoftTeamsIntegration{
+				{URL: srvURL, EnableSoftwareVulnerabilities: true, EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+
+	var gotBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(501)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotBody = nil
+		require.NoError(t, json.Unmarshal(body, &gotBody))
+		w.Write([]byte("1"))
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	msTeams := &MicrosoftTeams{
+		FleetURL:  "https://fleet.example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.MicrosoftTeamsOptions) (MicrosoftTeamsClient, error) {
+			return externalsvc.NewMicrosoftTeamsClient(opts)
+		},
+	}
+
+	t.Run("vuln", func(t *testing.T) {
+		err := msTeams.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+		require.NoError(t, err)
+		require.Equal(t, "MessageCard", gotBody["@type"])
+		require.Equal(t, "Vulnerability CVE-1234-5678 detected on 1 host(s)", gotBody["title"])
+		require.Equal(t, "See vulnerability (CVE) details in National Vulnerability Database (NVD) here: [CVE-1234-5678](https://nvd.nist.gov/vuln/detail/CVE-1234-5678)", gotBody["text"])
+
+		sections := gotBody["sections"].([]interface{})
+		require.Len(t, sections, 2)
+		require.Equal(t, map[string]interface{}{
+			"title": "Affected hosts",
+			"text":  "- [test](https://fleet.example.com/hosts/1)",
+		}, sections[0])
+
+		actions := gotBody["potentialAction"].([]interface{})
+		require.Len(t, actions, 2)
+		require.Equal(t, "View in Fleet", actions[0].(map[string]interface{})["name"])
+		require.Equal(t, "View in NVD", actions[1].(map[string]interface{})["name"])
+	})
+
+	t.Run("failing global policy", func(t *testing.T) {
+		err := msTeams.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 1, "policy_name": "test-policy", "resolution": "Turn on the firewall", "hosts": [{"id": 1, "hostname": "test-1"}, {"id": 2, "hostname": "test-2"}]}}`))
+		require.NoError(t, err)
+		require.Equal(t, "test-policy policy failed on 2 host(s)", gotBody["title"])
+		require.Nil(t, gotBody["text"])
+
+		sections := gotBody["sections"].([]interface{})
+		require.Len(t, sections, 3)
+		require.Equal(t, map[string]interface{}{
+			"title": "Resolution",
+			"text":  "Turn on the firewall",
+		}, sections[0])
+		require.Equal(t, map[string]interface{}{
+			"title": "Failing hosts",
+			"text":  "- [test-1](https://fleet.example.com/hosts/1)\n\n- [test-2](https://fleet.example.com/hosts/2)",
+		}, sections[1])
+
+		actions := gotBody["potentialAction"].([]interface{})
+		require.Len(t, actions, 1)
+		targets := actions[0].(map[string]interface{})["targets"].([]interface{})
+		require.Equal(t, "https://fleet.example.com/hosts/manage?order_direction=asc&order_key=hostname&policy_id=1&policy_response=failing",
+			targets[0].(map[string]interface{})["uri"])
+	})
+}
+
+func TestMicrosoftTeamsRunDisabled(t *testing.T) {
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			MicrosoftTeams: []*fleet.MicrosoftTeamsIntegration{
+				{URL: "http://example.com/hook", EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		// the team does not use the integration
+		return &fleet.Team{ID: tid}, nil
+	}
+
+	var newClientCalls int
+	msTeams := &MicrosoftTeams{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.MicrosoftTeamsOptions) (MicrosoftTeamsClient, error) {
+			newClientCalls++
+			return externalsvc.NewMicrosoftTeamsClient(opts)
+		},
+	}
+
+	// vulnerabilities are not enabled, so this is a no-op
+	err := msTeams.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+	require.NoError(t, err)
+	require.False(t, ds.HostsByCVEFuncInvoked)
+
+	// not enabled for the team, so this is a no-op
+	err = msTeams.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 2, "policy_name": "test-policy-2", "team_id": 123, "hosts": [{"id": 1, "hostname": "test-1"}]}}`))
+	require.NoError(t, err)
+	require.Equal(t, 0, newClientCalls)
+}
+
+func TestMicrosoftTeamsQueueJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	t.Run("vuln success", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			require.Equal(t, microsoftTeamsName, job.Name)
+			return job, nil
+		}
+		err := QueueMicrosoftTeamsVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-1234-5678"}})
+		require.NoError(t, err)
+		require.True(t, ds.NewJobFuncInvoked)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return nil, io.EOF
+		}
+		err := QueueMicrosoftTeamsFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{{ID: 1, Hostname: "h1"}})
+		require.Error(t, err)
+		require.ErrorIs(t, err, io.EOF)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("no host", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return job, nil
+		}
+		err := QueueMicrosoftTeamsFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{})
+		require.NoError(t, err)
+		require.False(t, ds.NewJobFuncInvoked)
+	})
+}
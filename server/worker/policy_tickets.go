@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// resolvedPolicyTplArgs are the arguments of the comment templates added to a
+// Jira issue or Zendesk ticket when a host passes the policy again.
+type resolvedPolicyTplArgs struct {
+	FleetURL string
+	HostID   uint
+	Hostname string
+	// Resolved is true if all hosts of the ticket now pass the policy, in which
+	// case the ticket is resolved.
+	Resolved bool
+}
+
+// policyTicketHostIDs returns the IDs of the hosts of a failing policy ticket.
+func policyTicketHostIDs(hosts []fleet.PolicySetHost) []uint {
+	hostIDs := make([]uint, 0, len(hosts))
+	for _, h := range hosts {
+		hostIDs = append(hostIDs, h.ID)
+	}
+	return hostIDs
+}
+
+// resolvesPolicyTicket returns true if the host is the last one for which the
+// ticket is still open, in which case passing the policy resolves the ticket.
+// It returns false for ok if the ticket is already resolved for the host.
+func resolvesPolicyTicket(openHostIDs []uint, hostID uint) (resolved, ok bool) {
+	for _, id := range openHostIDs {
+		if id == hostID {
+			return len(openHostIDs) == 1, true
+		}
+	}
+	return false, false
+}
+
+// QueueResolvedPolicyTicketJobs queues a Jira or Zendesk job for each open
+// ticket that was created for a host of the passing policies set while it was
+// failing the policy, now that the host passes that policy again, to process
+// asynchronously via the worker. The hosts are removed from the set once their
+// jobs are queued.
+func QueueResolvedPolicyTicketJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
+	passingPoliciesSet fleet.FailingPolicySet) error {
+	policyIDs, err := passingPoliciesSet.ListSets()
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list passing policies sets")
+	}
+
+	for _, policyID := range policyIDs {
+		hosts, err := passingPoliciesSet.ListHosts(policyID)
+		if err != nil {
+			return ctxerr.Wrapf(ctx, err, "list hosts of passing policies set %d", policyID)
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+
+		tickets, err := ds.ListOpenPolicyAutomationTickets(ctx, policyID, policyTicketHostIDs(hosts))
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "list open policy automation tickets")
+		}
+		hostnames := make(map[uint]string, len(hosts))
+		for _, h := range hosts {
+			hostnames[h.ID] = h.Hostname
+		}
+
+		for _, tkt := range tickets {
+			args := &resolvedPolicyArgs{
+				PolicyID: tkt.PolicyID,
+				TeamID:   tkt.TeamID,
+				HostID:   tkt.HostID,
+				Hostname: hostnames[tkt.HostID],
+				TicketID: tkt.TicketID,
+			}
+
+			var jobArgs interface{}
+			switch tkt.Integration {
+			case jiraName:
+				jobArgs = jiraArgs{ResolvedPolicy: args}
+			case zendeskName:
+				jobArgs = zendeskArgs{ResolvedPolicy: args}
+			default:
+				level.Debug(logger).Log("msg", "skipping, unknown ticket integration", "integration", tkt.Integration, "ticket_id", tkt.TicketID)
+				continue
+			}
+
+			job, err := QueueJob(ctx, ds, tkt.Integration, jobArgs)
+			if err != nil {
+				return ctxerr.Wrap(ctx, err, "queueing job")
+			}
+			level.Debug(logger).Log(
+				"job_id", job.ID,
+				"policy_id", tkt.PolicyID,
+				"host_id", tkt.HostID,
+				"integration", tkt.Integration,
+				"ticket_id", tkt.TicketID,
+			)
+		}
+
+		if err := passingPoliciesSet.RemoveHosts(policyID, hosts); err != nil {
+			return ctxerr.Wrapf(ctx, err, "removing %d hosts from passing policies set %d", len(hosts), policyID)
+		}
+	}
+	return nil
+}
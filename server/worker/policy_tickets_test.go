@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/datastore/redis/redistest"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/service/redis_policy_set"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueResolvedPolicyTicketJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+	pool := redistest.SetupRedis(t, t.Name(), false, false, false)
+	host1 := fleet.PolicySetHost{ID: 1, Hostname: "host-1"}
+	host2 := fleet.PolicySetHost{ID: 2, Hostname: "host-2"}
+
+	var queried []uint
+	ds.ListOpenPolicyAutomationTicketsFunc = func(ctx context.Context, policyID uint, hostIDs []uint) ([]*fleet.PolicyAutomationTicket, error) {
+		queried = append(queried, policyID)
+		switch policyID {
+		case 1:
+			require.ElementsMatch(t, []uint{1, 2}, hostIDs)
+			return []*fleet.PolicyAutomationTicket{
+				{PolicyID: 1, HostID: 1, Integration: "jira", TicketID: "ED-1"},
+				{PolicyID: 1, HostID: 2, Integration: "unknown", TicketID: "x"},
+			}, nil
+		case 2:
+			require.Equal(t, []uint{2}, hostIDs)
+			return []*fleet.PolicyAutomationTicket{
+				{PolicyID: 2, HostID: 2, TeamID: ptr.Uint(3), Integration: "zendesk", TicketID: "123"},
+			}, nil
+		}
+		return nil, nil
+	}
+
+	t.Run("success", func(t *testing.T) {
+		queried = nil
+		passingSet := redis_policy_set.NewPassingTest(t, pool)
+		require.NoError(t, passingSet.AddHost(1, host1))
+		require.NoError(t, passingSet.AddHost(1, host2))
+		require.NoError(t, passingSet.AddHost(2, host2))
+
+		var jobs []*fleet.Job
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			jobs = append(jobs, job)
+			return job, nil
+		}
+		err := QueueResolvedPolicyTicketJobs(ctx, ds, logger, passingSet)
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		// a single query per policy
+		require.ElementsMatch(t, []uint{1, 2}, queried)
+
+		sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+		require.Equal(t, jiraName, jobs[0].Name)
+		var jArgs jiraArgs
+		require.NoError(t, json.Unmarshal(*jobs[0].Args, &jArgs))
+		require.Equal(t, intgTypeResolvedPolicy, jArgs.integrationType())
+		require.Equal(t, &resolvedPolicyArgs{PolicyID: 1, HostID: 1, Hostname: "host-1", TicketID: "ED-1"}, jArgs.ResolvedPolicy)
+
+		require.Equal(t, zendeskName, jobs[1].Name)
+		var zArgs zendeskArgs
+		require.NoError(t, json.Unmarshal(*jobs[1].Args, &zArgs))
+		require.Equal(t, &resolvedPolicyArgs{PolicyID: 2, TeamID: ptr.Uint(3), HostID: 2, Hostname: "host-2", TicketID: "123"}, zArgs.ResolvedPolicy)
+
+		// the hosts are removed from the set
+		for _, policyID := range []uint{1, 2} {
+			hosts, err := passingSet.ListHosts(policyID)
+			require.NoError(t, err)
+			require.Empty(t, hosts)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		passingSet := redis_policy_set.NewPassingTest(t, pool)
+		require.NoError(t, passingSet.AddHost(1, host1))
+
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return nil, io.EOF
+		}
+		err := QueueResolvedPolicyTicketJobs(ctx, ds, logger, passingSet)
+		require.Error(t, err)
+		require.ErrorIs(t, err, io.EOF)
+
+		// the hosts are kept to be processed on the next run
+		hosts, err := passingSet.ListHosts(1)
+		require.NoError(t, err)
+		require.Equal(t, []fleet.PolicySetHost{host1}, hosts)
+	})
+}
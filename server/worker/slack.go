@@ -0,0 +1,283 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// slackName is the name of the job as registered in the worker.
+const slackName = "slack"
+
+// SlackClient defines the method required for the client that posts messages
+// to Slack.
+type SlackClient interface {
+	PostSlackMessage(ctx context.Context, msg *externalsvc.SlackMessage) error
+	SlackConfigMatches(opts *externalsvc.SlackOptions) bool
+}
+
+// Slack is the job processor for Slack integrations.
+type Slack struct {
+	FleetURL      string
+	Datastore     fleet.Datastore
+	Log           kitlog.Logger
+	NewClientFunc func(*externalsvc.SlackOptions) (SlackClient, error)
+
+	// mu protects concurrent access to clientsCache, so that the job processor
+	// can potentially be run concurrently.
+	mu sync.Mutex
+	// map of integration type + team ID to Slack client (empty team ID for
+	// global), e.g. "vuln:123", "failingPolicy:", etc.
+	clientsCache map[string]SlackClient
+}
+
+// Name returns the name of the job.
+func (s *Slack) Name() string {
+	return slackName
+}
+
+// returns nil, nil if there is no integration enabled for that message.
+func (s *Slack) getClient(ctx context.Context, args slackArgs) (SlackClient, error) {
+	var teamID uint
+	var useTeamCfg bool
+
+	intgType := args.integrationType()
+	key := intgType + ":"
+	if intgType == intgTypeFailingPolicy && args.FailingPolicy.TeamID != nil {
+		teamID = *args.FailingPolicy.TeamID
+		useTeamCfg = true
+		key += fmt.Sprint(teamID)
+	}
+
+	ac, err := s.Datastore.AppConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// load the config that would be used to create the client first - it is
+	// needed to check if an existing client is configured the same or if its
+	// configuration has changed since it was created.
+	var opts *externalsvc.SlackOptions
+	if useTeamCfg {
+		tm, err := s.Datastore.Team(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+
+		intgs, err := tm.Config.Integrations.MatchWithIntegrations(ac.Integrations)
+		if err != nil {
+			return nil, err
+		}
+		for _, intg := range intgs.Slack {
+			if intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies {
+				opts = slackOptions(intg)
+				break
+			}
+		}
+	} else {
+		for _, intg := range ac.Integrations.Slack {
+			if (intgType == intgTypeVuln && intg.EnableSoftwareVulnerabilities) ||
+				(intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies) {
+				opts = slackOptions(intg)
+				break
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.clientsCache == nil {
+		s.clientsCache = make(map[string]SlackClient)
+	}
+	if opts == nil {
+		// no integration configured, clear any existing one
+		delete(s.clientsCache, key)
+		return nil, nil
+	}
+
+	// check if the existing one can be reused
+	if cli := s.clientsCache[key]; cli != nil && cli.SlackConfigMatches(opts) {
+		return cli, nil
+	}
+
+	// otherwise create a new one
+	cli, err := s.NewClientFunc(opts)
+	if err != nil {
+		return nil, err
+	}
+	s.clientsCache[key] = cli
+	return cli, nil
+}
+
+func slackOptions(intg *fleet.SlackIntegration) *externalsvc.SlackOptions {
+	return &externalsvc.SlackOptions{
+		WebhookURL: intg.WebhookURL,
+		APIToken:   intg.APIToken,
+		Channel:    intg.Channel,
+	}
+}
+
+// slackArgs are the arguments for the Slack integration job.
+type slackArgs struct {
+	CVE           string             `json:"cve,omitempty"`
+	FailingPolicy *failingPolicyArgs `json:"failing_policy,omitempty"`
+}
+
+func (a *slackArgs) integrationType() string {
+	if a.FailingPolicy == nil {
+		return intgTypeVuln
+	}
+	return intgTypeFailingPolicy
+}
+
+// Run executes the Slack job.
+func (s *Slack) Run(ctx context.Context, argsJSON json.RawMessage) error {
+	var args slackArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return ctxerr.Wrap(ctx, err, "unmarshal args")
+	}
+
+	cli, err := s.getClient(ctx, args)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get Slack client")
+	}
+	if cli == nil {
+		// this message was queued when an integration was enabled, but since
+		// then it has been disabled, so return success to mark the message
+		// as processed.
+		return nil
+	}
+
+	var msg chatMessage
+	switch intgType := args.integrationType(); intgType {
+	case intgTypeVuln:
+		hosts, err := s.Datastore.HostsByCVE(ctx, args.CVE)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "find hosts by cve")
+		}
+		whHosts := make([]webhookHost, 0, len(hosts))
+		for _, h := range hosts {
+			whHosts = append(whHosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(s.FleetURL, h.ID)})
+		}
+		msg = newVulnChatMessage(s.FleetURL, args.CVE, whHosts)
+
+	case intgTypeFailingPolicy:
+		whHosts := make([]webhookHost, 0, len(args.FailingPolicy.Hosts))
+		for _, h := range args.FailingPolicy.Hosts {
+			whHosts = append(whHosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(s.FleetURL, h.ID)})
+		}
+		msg = newFailingPolicyChatMessage(s.FleetURL, args.FailingPolicy, whHosts)
+
+	default:
+		return ctxerr.Errorf(ctx, "unknown integration type: %v", intgType)
+	}
+
+	if err := cli.PostSlackMessage(ctx, formatSlackMessage(msg)); err != nil {
+		return ctxerr.Wrap(ctx, err, "post Slack message")
+	}
+
+	attrs := []interface{}{"msg", "posted Slack message", "type", args.integrationType()}
+	if args.CVE != "" {
+		attrs = append(attrs, "cve", args.CVE)
+	}
+	if args.FailingPolicy != nil {
+		attrs = append(attrs, "policy_id", args.FailingPolicy.PolicyID, "policy_name", args.FailingPolicy.PolicyName)
+		if args.FailingPolicy.TeamID != nil {
+			attrs = append(attrs, "team_id", *args.FailingPolicy.TeamID)
+		}
+	}
+	level.Debug(s.Log).Log(attrs...)
+	return nil
+}
+
+// slackEscaper escapes the control characters of Slack's mrkdwn format.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// formatSlackMessage formats the chat message using Slack blocks.
+func formatSlackMessage(msg chatMessage) *externalsvc.SlackMessage {
+	section := func(text string) externalsvc.SlackBlock {
+		return externalsvc.SlackBlock{Type: "section", Text: &externalsvc.SlackText{Type: "mrkdwn", Text: text}}
+	}
+
+	blocks := []externalsvc.SlackBlock{
+		section("*" + slackEscaper.Replace(msg.Title) + "*"),
+	}
+	if msg.CVE != "" {
+		blocks = append(blocks, section(fmt.Sprintf("See vulnerability (CVE) details in National Vulnerability Database (NVD) here: <%s|%s>", msg.NVDURL, msg.CVE)))
+	}
+	if msg.Resolution != "" {
+		blocks = append(blocks, section("*Resolution:*\n"+slackEscaper.Replace(msg.Resolution)))
+	}
+
+	var sb strings.Builder
+	sb.WriteString("*" + msg.HostsLabel + ":*")
+	for _, h := range msg.Hosts {
+		fmt.Fprintf(&sb, "\n• <%s|%s>", h.URL, slackEscaper.Replace(h.Hostname))
+	}
+	if msg.MoreHosts > 0 {
+		fmt.Fprintf(&sb, "\n_and %d more_", msg.MoreHosts)
+	}
+	blocks = append(blocks,
+		section(sb.String()),
+		section(fmt.Sprintf("<%s|View in Fleet>", msg.ViewURL)),
+		externalsvc.SlackBlock{Type: "context", Elements: []externalsvc.SlackText{
+			{Type: "mrkdwn", Text: "This message was posted automatically by your Fleet Slack integration."},
+		}},
+	)
+
+	return &externalsvc.SlackMessage{
+		Text:   msg.Title,
+		Blocks: blocks,
+	}
+}
+
+// QueueSlackVulnJobs queues the Slack vulnerability jobs to process
+// asynchronously via the worker.
+func QueueSlackVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, recentVulns []fleet.SoftwareVulnerability) error {
+	level.Info(logger).Log("enabled", "true", "recentVulns", len(recentVulns))
+
+	// for troubleshooting, log in debug level the CVEs that we will process
+	// (cannot be done in the loop below as we want to add the debug log
+	// _before_ we start processing them).
+	cves := make([]string, 0, len(recentVulns))
+	for _, vuln := range recentVulns {
+		cves = append(cves, vuln.CVE)
+	}
+	sort.Strings(cves)
+	level.Debug(logger).Log("recent_cves", fmt.Sprintf("%v", cves))
+
+	for _, vuln := range recentVulns {
+		job, err := QueueJob(ctx, ds, slackName, slackArgs{CVE: vuln.CVE})
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "queueing job")
+		}
+		level.Debug(logger).Log("job_id", job.ID)
+	}
+	return nil
+}
+
+// QueueSlackFailingPolicyJob queues a Slack job for a failing policy to
+// process asynchronously via the worker.
+func QueueSlackFailingPolicyJob(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
+	policy *fleet.Policy, hosts []fleet.PolicySetHost) error {
+	args, ok := chatFailingPolicyArgs(logger, policy, hosts)
+	if !ok {
+		return nil
+	}
+	job, err := QueueJob(ctx, ds, slackName, slackArgs{FailingPolicy: args})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "queueing job")
+	}
+	level.Debug(logger).Log("job_id", job.ID)
+	return nil
+}
@@ -0,0 +1,236 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackRun(t *testing.T) {
+	ds := new(mock.Store)
+	ds.HostsByCVEFunc = func(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{
+				ID:       1,
+				Hostname: "test<1>",
+			},
+		}, nil
+	}
+
+	var srvURL string
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Slack: []*fleet.SlackIntegration{
+				{WebhookURL: srvURL, EnableSoftwareVulnerabilities: true, EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		if tid != 123 {
+			return nil, errors.New("unexpected team id")
+		}
+		return &fleet.Team{
+			ID: 123,
+			Config: fleet.TeamConfig{
+				Integrations: fleet.TeamIntegrations{
+					Slack: []*fleet.TeamSlackIntegration{
+						{WebhookURL: srvURL, EnableFailingPolicies: true},
+					},
+				},
+			},
+		}, nil
+	}
+
+	var gotMsg externalsvc.SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(501)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotMsg = externalsvc.SlackMessage{}
+		require.NoError(t, json.Unmarshal(body, &gotMsg))
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	slack := &Slack{
+		FleetURL:  "https://fleet.example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.SlackOptions) (SlackClient, error) {
+			return externalsvc.NewSlackClient(opts)
+		},
+	}
+
+	blocksText := func(msg externalsvc.SlackMessage) []string {
+		var texts []string
+		for _, b := range msg.Blocks {
+			if b.Text != nil {
+				texts = append(texts, b.Text.Text)
+			}
+		}
+		return texts
+	}
+
+	t.Run("vuln", func(t *testing.T) {
+		err := slack.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+		require.NoError(t, err)
+		require.Equal(t, "Vulnerability CVE-1234-5678 detected on 1 host(s)", gotMsg.Text)
+		require.Equal(t, []string{
+			"*Vulnerability CVE-1234-5678 detected on 1 host(s)*",
+			"See vulnerability (CVE) details in National Vulnerability Database (NVD) here: <https://nvd.nist.gov/vuln/detail/CVE-1234-5678|CVE-1234-5678>",
+			"*Affected hosts:*\n• <https://fleet.example.com/hosts/1|test&lt;1&gt;>",
+			"<https://fleet.example.com/software/manage|View in Fleet>",
+		}, blocksText(gotMsg))
+	})
+
+	t.Run("failing team policy", func(t *testing.T) {
+		err := slack.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 2, "policy_name": "test-policy-2", "team_id": 123, "resolution": "Turn on the firewall", "hosts": [{"id": 1, "hostname": "test-1"}, {"id": 2, "hostname": "test-2"}]}}`))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"*test-policy-2 policy failed on 2 host(s)*",
+			"*Resolution:*\nTurn on the firewall",
+			"*Failing hosts:*\n• <https://fleet.example.com/hosts/1|test-1>\n• <https://fleet.example.com/hosts/2|test-2>",
+			"<https://fleet.example.com/hosts/manage?order_direction=asc&order_key=hostname&policy_id=2&policy_response=failing&team_id=123|View in Fleet>",
+		}, blocksText(gotMsg))
+	})
+
+	t.Run("many hosts", func(t *testing.T) {
+		hosts := make([]fleet.PolicySetHost, 30)
+		for i := range hosts {
+			hosts[i] = fleet.PolicySetHost{ID: uint(i + 1), Hostname: fmt.Sprintf("host-%d", i+1)}
+		}
+		args, err := json.Marshal(slackArgs{FailingPolicy: &failingPolicyArgs{PolicyID: 1, PolicyName: "p1", Hosts: hosts}})
+		require.NoError(t, err)
+		err = slack.Run(context.Background(), args)
+		require.NoError(t, err)
+		texts := blocksText(gotMsg)
+		require.Contains(t, texts[1], "host-25>")
+		require.NotContains(t, texts[1], "host-26>")
+		require.Contains(t, texts[1], "_and 5 more_")
+	})
+}
+
+func TestSlackRunClientUpdate(t *testing.T) {
+	ds := new(mock.Store)
+
+	// failing policies is enabled globally, vulnerabilities are disabled
+	token := "xoxb-1"
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Slack: []*fleet.SlackIntegration{
+				{APIToken: token, Channel: "#secops", EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+
+	var tokens []string
+	slack := &Slack{
+		FleetURL:  "https://fleet.example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.SlackOptions) (SlackClient, error) {
+			tokens = append(tokens, opts.APIToken)
+			return &mockSlackClient{opts: *opts}, nil
+		},
+	}
+
+	policyArgs := json.RawMessage(`{"failing_policy":{"policy_id": 1, "policy_name": "test-policy", "hosts": [{"id": 1, "hostname": "test-1"}]}}`)
+	err := slack.Run(context.Background(), policyArgs)
+	require.NoError(t, err)
+
+	// reuses the cached client
+	err = slack.Run(context.Background(), policyArgs)
+	require.NoError(t, err)
+
+	// the token changed, creates a new client
+	token = "xoxb-2"
+	err = slack.Run(context.Background(), policyArgs)
+	require.NoError(t, err)
+
+	// vulnerabilities are not enabled, so this is a no-op
+	err = slack.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+	require.NoError(t, err)
+	require.False(t, ds.HostsByCVEFuncInvoked)
+
+	require.Equal(t, []string{"xoxb-1", "xoxb-2"}, tokens)
+}
+
+type mockSlackClient struct {
+	opts externalsvc.SlackOptions
+}
+
+func (c *mockSlackClient) PostSlackMessage(ctx context.Context, msg *externalsvc.SlackMessage) error {
+	return nil
+}
+
+func (c *mockSlackClient) SlackConfigMatches(opts *externalsvc.SlackOptions) bool {
+	return c.opts == *opts
+}
+
+func TestSlackQueueJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	t.Run("vuln success", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			require.Equal(t, slackName, job.Name)
+			return job, nil
+		}
+		err := QueueSlackVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-1234-5678"}})
+		require.NoError(t, err)
+		require.True(t, ds.NewJobFuncInvoked)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("failing policy with resolution", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			require.Contains(t, string(*job.Args), `"team_id":2`)
+			require.Contains(t, string(*job.Args), `"resolution":"fix it"`)
+			return job, nil
+		}
+		err := QueueSlackFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1", TeamID: ptr.Uint(2), Resolution: ptr.String("fix it")}}, []fleet.PolicySetHost{{ID: 1, Hostname: "h1"}})
+		require.NoError(t, err)
+		require.True(t, ds.NewJobFuncInvoked)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return nil, io.EOF
+		}
+		err := QueueSlackFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{{ID: 1, Hostname: "h1"}})
+		require.Error(t, err)
+		require.ErrorIs(t, err, io.EOF)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("no host", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return job, nil
+		}
+		err := QueueSlackFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{})
+		require.NoError(t, err)
+		require.False(t, ds.NewJobFuncInvoked)
+	})
+}
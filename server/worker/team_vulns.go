@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"sort"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// TeamVulnIntegrations holds the IDs of the teams that have a Jira or Zendesk
+// integration enabled for software vulnerabilities.
+type TeamVulnIntegrations struct {
+	JiraTeamIDs    []uint
+	ZendeskTeamIDs []uint
+}
+
+// Enabled returns true if at least one team has a Jira or Zendesk integration
+// enabled for software vulnerabilities.
+func (t TeamVulnIntegrations) Enabled() bool {
+	return len(t.JiraTeamIDs) > 0 || len(t.ZendeskTeamIDs) > 0
+}
+
+// LoadTeamVulnIntegrations loads the teams that have a Jira or Zendesk
+// integration enabled for software vulnerabilities.
+func LoadTeamVulnIntegrations(ctx context.Context, ds fleet.Datastore) (TeamVulnIntegrations, error) {
+	var res TeamVulnIntegrations
+
+	teams, err := ds.TeamsWithConfig(ctx)
+	if err != nil {
+		return res, ctxerr.Wrap(ctx, err, "list teams")
+	}
+	for _, tm := range teams {
+		for _, j := range tm.Config.Integrations.Jira {
+			if j.EnableSoftwareVulnerabilities {
+				res.JiraTeamIDs = append(res.JiraTeamIDs, tm.ID)
+				break
+			}
+		}
+		for _, z := range tm.Config.Integrations.Zendesk {
+			if z.EnableSoftwareVulnerabilities {
+				res.ZendeskTeamIDs = append(res.ZendeskTeamIDs, tm.ID)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// teamsAffectedByVulns returns, for each CVE of the recent vulnerabilities,
+// the IDs of the teams in teamIDs that have at least one affected host, in
+// ascending order. CVEs that do not affect any of those teams are omitted.
+func teamsAffectedByVulns(ctx context.Context, ds fleet.Datastore, recentVulns []fleet.SoftwareVulnerability, teamIDs []uint) (map[string][]uint, error) {
+	candidates := make(map[uint]bool, len(teamIDs))
+	for _, id := range teamIDs {
+		candidates[id] = true
+	}
+
+	softwareIDsByCVE := make(map[string][]uint)
+	for _, v := range recentVulns {
+		softwareIDsByCVE[v.CVE] = append(softwareIDsByCVE[v.CVE], v.SoftwareID)
+	}
+
+	affected := make(map[string][]uint)
+	for cve, softwareIDs := range softwareIDsByCVE {
+		hosts, err := ds.HostsBySoftwareIDs(ctx, softwareIDs)
+		if err != nil {
+			return nil, ctxerr.Wrap(ctx, err, "get hosts by software ids")
+		}
+
+		seen := make(map[uint]bool)
+		for _, h := range hosts {
+			if h.TeamID == nil || !candidates[*h.TeamID] || seen[*h.TeamID] {
+				continue
+			}
+			seen[*h.TeamID] = true
+			affected[cve] = append(affected[cve], *h.TeamID)
+		}
+		sort.Slice(affected[cve], func(i, j int) bool { return affected[cve][i] < affected[cve][j] })
+	}
+	return affected, nil
+}
+
+// queueTeamVulnJobs queues a job for the job processor identified by name for
+// each team affected by each of the recent vulnerabilities, using newArgs to
+// create the arguments of the job for a CVE and team.
+func queueTeamVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, name string,
+	recentVulns []fleet.SoftwareVulnerability, teamIDs []uint, newArgs func(cve string, teamID uint) interface{}) error {
+	if len(teamIDs) == 0 {
+		return nil
+	}
+	level.Info(logger).Log("enabled", "true", "recentVulns", len(recentVulns), "teams", len(teamIDs))
+
+	affected, err := teamsAffectedByVulns(ctx, ds, recentVulns, teamIDs)
+	if err != nil {
+		return err
+	}
+
+	cves := make([]string, 0, len(affected))
+	for cve := range affected {
+		cves = append(cves, cve)
+	}
+	sort.Strings(cves)
+
+	for _, cve := range cves {
+		for _, teamID := range affected[cve] {
+			job, err := QueueJob(ctx, ds, name, newArgs(cve, teamID))
+			if err != nil {
+				return ctxerr.Wrap(ctx, err, "queueing job")
+			}
+			level.Debug(logger).Log("job_id", job.ID, "cve", cve, "team_id", teamID)
+		}
+	}
+	return nil
+}
+
+// filterHostsByTeam returns the hosts that belong to the team, or all hosts if
+// teamID is nil.
+func filterHostsByTeam(hosts []*fleet.HostShort, teamID *uint) []*fleet.HostShort {
+	if teamID == nil {
+		return hosts
+	}
+	filtered := make([]*fleet.HostShort, 0, len(hosts))
+	for _, h := range hosts {
+		if h.TeamID != nil && *h.TeamID == *teamID {
+			filtered = append(filtered, h)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTeamVulnIntegrations(t *testing.T) {
+	ds := new(mock.Store)
+	ds.TeamsWithConfigFunc = func(ctx context.Context) ([]*fleet.Team, error) {
+		return []*fleet.Team{
+			{ID: 1, Config: fleet.TeamConfig{Integrations: fleet.TeamIntegrations{
+				Jira: []*fleet.TeamJiraIntegration{
+					{ProjectKey: "a", EnableFailingPolicies: true},
+					{ProjectKey: "b", EnableSoftwareVulnerabilities: true},
+				},
+			}}},
+			{ID: 2, Config: fleet.TeamConfig{Integrations: fleet.TeamIntegrations{
+				Zendesk: []*fleet.TeamZendeskIntegration{{GroupID: 1, EnableSoftwareVulnerabilities: true}},
+			}}},
+			{ID: 3},
+		}, nil
+	}
+
+	res, err := LoadTeamVulnIntegrations(context.Background(), ds)
+	require.NoError(t, err)
+	require.True(t, res.Enabled())
+	require.Equal(t, []uint{1}, res.JiraTeamIDs)
+	require.Equal(t, []uint{2}, res.ZendeskTeamIDs)
+	// the teams are loaded in a single query
+	require.False(t, ds.TeamFuncInvoked)
+}
@@ -0,0 +1,345 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// webhookName is the name of the job as registered in the worker.
+const webhookName = "webhook"
+
+// webhookTplArgs are the arguments available to the body template of a
+// webhook integration. Fields that do not apply to the integration type of
+// the message are left empty.
+type webhookTplArgs struct {
+	// Type is the integration type of the message, "vuln" or "failingPolicy".
+	Type      string
+	Timestamp time.Time
+	FleetURL  string
+	NVDURL    string
+
+	// set for vulnerabilities
+	CVE string
+
+	// set for failing policies
+	PolicyID   uint
+	PolicyName string
+	TeamID     *uint
+
+	Hosts []webhookHost
+}
+
+type webhookHost struct {
+	ID       uint   `json:"id"`
+	Hostname string `json:"hostname"`
+	URL      string `json:"url"`
+}
+
+// WebhookClient defines the method required for the client that makes
+// requests to a generic webhook destination.
+type WebhookClient interface {
+	SendWebhook(ctx context.Context, body []byte) error
+	WebhookConfigMatches(opts *externalsvc.WebhookOptions) bool
+}
+
+// Webhook is the job processor for generic webhook integrations.
+type Webhook struct {
+	FleetURL      string
+	Datastore     fleet.Datastore
+	Log           kitlog.Logger
+	NewClientFunc func(*externalsvc.WebhookOptions) (WebhookClient, error)
+
+	// mu protects concurrent access to clientsCache, so that the job processor
+	// can potentially be run concurrently.
+	mu sync.Mutex
+	// map of integration type + team ID to webhook client (empty team ID for
+	// global), e.g. "vuln:123", "failingPolicy:", etc.
+	clientsCache map[string]WebhookClient
+}
+
+// Name returns the name of the job.
+func (w *Webhook) Name() string {
+	return webhookName
+}
+
+// returns nil, nil, nil if there is no integration enabled for that message.
+func (w *Webhook) getClient(ctx context.Context, args webhookArgs) (WebhookClient, *fleet.WebhookIntegration, error) {
+	var teamID uint
+	var useTeamCfg bool
+
+	intgType := args.integrationType()
+	key := intgType + ":"
+	if intgType == intgTypeFailingPolicy && args.FailingPolicy.TeamID != nil {
+		teamID = *args.FailingPolicy.TeamID
+		useTeamCfg = true
+		key += fmt.Sprint(teamID)
+	}
+
+	ac, err := w.Datastore.AppConfig(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// load the config that would be used to create the client first - it is
+	// needed to check if an existing client is configured the same or if its
+	// configuration has changed since it was created.
+	var intg *fleet.WebhookIntegration
+	if useTeamCfg {
+		tm, err := w.Datastore.Team(ctx, teamID)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		intgs, err := tm.Config.Integrations.MatchWithIntegrations(ac.Integrations)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, candidate := range intgs.Webhook {
+			if intgType == intgTypeFailingPolicy && candidate.EnableFailingPolicies {
+				intg = candidate
+				break
+			}
+		}
+	} else {
+		for _, candidate := range ac.Integrations.Webhook {
+			if (intgType == intgTypeVuln && candidate.EnableSoftwareVulnerabilities) ||
+				(intgType == intgTypeFailingPolicy && candidate.EnableFailingPolicies) {
+				intg = candidate
+				break
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.clientsCache == nil {
+		w.clientsCache = make(map[string]WebhookClient)
+	}
+	if intg == nil {
+		// no integration configured, clear any existing one
+		delete(w.clientsCache, key)
+		return nil, nil, nil
+	}
+
+	opts := &externalsvc.WebhookOptions{
+		URL:     intg.URL,
+		Headers: intg.Headers,
+		Secret:  intg.Secret,
+	}
+
+	// check if the existing one can be reused
+	if cli := w.clientsCache[key]; cli != nil && cli.WebhookConfigMatches(opts) {
+		return cli, intg, nil
+	}
+
+	// otherwise create a new one
+	cli, err := w.NewClientFunc(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.clientsCache[key] = cli
+	return cli, intg, nil
+}
+
+// webhookArgs are the arguments for the webhook integration job.
+type webhookArgs struct {
+	CVE           string             `json:"cve,omitempty"`
+	FailingPolicy *failingPolicyArgs `json:"failing_policy,omitempty"`
+}
+
+func (a *webhookArgs) integrationType() string {
+	if a.FailingPolicy == nil {
+		return intgTypeVuln
+	}
+	return intgTypeFailingPolicy
+}
+
+// Run executes the webhook job.
+func (w *Webhook) Run(ctx context.Context, argsJSON json.RawMessage) error {
+	var args webhookArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return ctxerr.Wrap(ctx, err, "unmarshal args")
+	}
+
+	cli, intg, err := w.getClient(ctx, args)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get webhook client")
+	}
+	if cli == nil {
+		// this message was queued when an integration was enabled, but since
+		// then it has been disabled, so return success to mark the message
+		// as processed.
+		return nil
+	}
+
+	tplArgs := &webhookTplArgs{
+		Type:      args.integrationType(),
+		Timestamp: time.Now(),
+		FleetURL:  w.FleetURL,
+		NVDURL:    nvdCVEURL,
+	}
+
+	switch intgType := args.integrationType(); intgType {
+	case intgTypeVuln:
+		hosts, err := w.Datastore.HostsByCVE(ctx, args.CVE)
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "find hosts by cve")
+		}
+		tplArgs.CVE = args.CVE
+		tplArgs.Hosts = make([]webhookHost, 0, len(hosts))
+		for _, h := range hosts {
+			tplArgs.Hosts = append(tplArgs.Hosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(w.FleetURL, h.ID)})
+		}
+
+	case intgTypeFailingPolicy:
+		tplArgs.PolicyID = args.FailingPolicy.PolicyID
+		tplArgs.PolicyName = args.FailingPolicy.PolicyName
+		tplArgs.TeamID = args.FailingPolicy.TeamID
+		tplArgs.Hosts = make([]webhookHost, 0, len(args.FailingPolicy.Hosts))
+		for _, h := range args.FailingPolicy.Hosts {
+			tplArgs.Hosts = append(tplArgs.Hosts, webhookHost{ID: h.ID, Hostname: h.Hostname, URL: fleetHostURL(w.FleetURL, h.ID)})
+		}
+
+	default:
+		return ctxerr.Errorf(ctx, "unknown integration type: %v", intgType)
+	}
+
+	body, err := renderWebhookBody(intg.BodyTemplate, tplArgs)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "render webhook body")
+	}
+	if err := cli.SendWebhook(ctx, body); err != nil {
+		return ctxerr.Wrap(ctx, err, "send webhook")
+	}
+
+	attrs := []interface{}{"msg", "sent webhook", "type", tplArgs.Type}
+	if tplArgs.CVE != "" {
+		attrs = append(attrs, "cve", tplArgs.CVE)
+	}
+	if args.FailingPolicy != nil {
+		attrs = append(attrs, "policy_id", args.FailingPolicy.PolicyID, "policy_name", args.FailingPolicy.PolicyName)
+		if args.FailingPolicy.TeamID != nil {
+			attrs = append(attrs, "team_id", *args.FailingPolicy.TeamID)
+		}
+	}
+	level.Debug(w.Log).Log(attrs...)
+	return nil
+}
+
+// renderWebhookBody renders the body of the webhook request using the
+// provided template, or the default JSON payload if the template is empty.
+func renderWebhookBody(bodyTpl string, args *webhookTplArgs) ([]byte, error) {
+	if bodyTpl == "" {
+		return defaultWebhookBody(args)
+	}
+
+	tpl, err := fleet.ParseWebhookIntegrationTemplate(bodyTpl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, args); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// defaultWebhookBody returns the default JSON payload, which uses the same
+// shape as the corresponding WebhookSettings payloads.
+func defaultWebhookBody(args *webhookTplArgs) ([]byte, error) {
+	var payload map[string]interface{}
+	switch args.Type {
+	case intgTypeVuln:
+		payload = map[string]interface{}{
+			"timestamp": args.Timestamp,
+			"vulnerability": map[string]interface{}{
+				"cve":            args.CVE,
+				"details_link":   args.NVDURL + args.CVE,
+				"hosts_affected": args.Hosts,
+			},
+		}
+	case intgTypeFailingPolicy:
+		payload = map[string]interface{}{
+			"timestamp": args.Timestamp,
+			"policy": map[string]interface{}{
+				"id":      args.PolicyID,
+				"name":    args.PolicyName,
+				"team_id": args.TeamID,
+			},
+			"hosts": args.Hosts,
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// QueueWebhookVulnJobs queues the webhook vulnerability jobs to process
+// asynchronously via the worker.
+func QueueWebhookVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, recentVulns []fleet.SoftwareVulnerability) error {
+	level.Info(logger).Log("enabled", "true", "recentVulns", len(recentVulns))
+
+	// for troubleshooting, log in debug level the CVEs that we will process
+	// (cannot be done in the loop below as we want to add the debug log
+	// _before_ we start processing them).
+	cves := make([]string, 0, len(recentVulns))
+	for _, vuln := range recentVulns {
+		cves = append(cves, vuln.CVE)
+	}
+	sort.Strings(cves)
+	level.Debug(logger).Log("recent_cves", fmt.Sprintf("%v", cves))
+
+	for _, vuln := range recentVulns {
+		job, err := QueueJob(ctx, ds, webhookName, webhookArgs{CVE: vuln.CVE})
+		if err != nil {
+			return ctxerr.Wrap(ctx, err, "queueing job")
+		}
+		level.Debug(logger).Log("job_id", job.ID)
+	}
+	return nil
+}
+
+// QueueWebhookFailingPolicyJob queues a webhook job for a failing policy to
+// process asynchronously via the worker.
+func QueueWebhookFailingPolicyJob(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
+	policy *fleet.Policy, hosts []fleet.PolicySetHost) error {
+	attrs := []interface{}{
+		"enabled", "true",
+		"failing_policy", policy.ID,
+		"hosts_count", len(hosts),
+	}
+	if policy.TeamID != nil {
+		attrs = append(attrs, "team_id", *policy.TeamID)
+	}
+	if len(hosts) == 0 {
+		attrs = append(attrs, "msg", "skipping, no host")
+		level.Debug(logger).Log(attrs...)
+		return nil
+	}
+
+	level.Info(logger).Log(attrs...)
+
+	args := &failingPolicyArgs{
+		PolicyID:   policy.ID,
+		PolicyName: policy.Name,
+		TeamID:     policy.TeamID,
+		Hosts:      hosts,
+	}
+	job, err := QueueJob(ctx, ds, webhookName, webhookArgs{FailingPolicy: args})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "queueing job")
+	}
+	level.Debug(logger).Log("job_id", job.ID)
+	return nil
+}
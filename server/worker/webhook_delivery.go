@@ -0,0 +1,260 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/fleethttp"
+	"github.com/fleetdm/fleet/v4/pkg/webhooksig"
+	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// webhookDeliveryName is the name of the job as registered in the worker.
+const webhookDeliveryName = "webhook_delivery"
+
+// webhookDeliveryTimeout is the timeout of a single delivery attempt.
+const webhookDeliveryTimeout = 30 * time.Second
+
+// WebhookDelivery is the job processor that retries failed deliveries of the
+// webhooks configured in the webhook settings (host status, failing policies
+// and vulnerabilities).
+type WebhookDelivery struct {
+	Datastore fleet.Datastore
+	Log       kitlog.Logger
+}
+
+// Name returns the name of the job.
+func (w *WebhookDelivery) Name() string {
+	return webhookDeliveryName
+}
+
+// webhookDeliveryArgs are the arguments for the webhook delivery job.
+type webhookDeliveryArgs struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// Run executes the webhook delivery job.
+func (w *WebhookDelivery) Run(ctx context.Context, argsJSON json.RawMessage) error {
+	var args webhookDeliveryArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return ctxerr.Wrap(ctx, err, "unmarshal args")
+	}
+
+	delivery, err := w.Datastore.WebhookDelivery(ctx, args.DeliveryID)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get webhook delivery")
+	}
+	if delivery.Status == fleet.WebhookDeliverySuccess || delivery.Status == fleet.WebhookDeliveryDeadLetter {
+		// already delivered, e.g. via a redeliver request, or abandoned
+		return nil
+	}
+
+	secret, err := webhookDeliverySecret(ctx, w.Datastore, delivery)
+	if err != nil {
+		if fleet.IsNotFound(err) {
+			// the team was deleted, the delivery can't succeed anymore
+			delivery.Status = fleet.WebhookDeliveryDeadLetter
+			delivery.Error = "team deleted"
+			if err := w.Datastore.UpdateWebhookDelivery(ctx, delivery); err != nil {
+				return ctxerr.Wrap(ctx, err, "update webhook delivery")
+			}
+			level.Info(w.Log).Log("msg", "webhook delivery of deleted team moved to dead letter", "delivery_id", delivery.ID)
+			return nil
+		}
+		return ctxerr.Wrap(ctx, err, "get webhook secret")
+	}
+	if err := attemptWebhookDelivery(ctx, w.Datastore, delivery, secret); err != nil {
+		return ctxerr.Wrap(ctx, err, "attempt webhook delivery")
+	}
+	level.Debug(w.Log).Log("msg", "delivered webhook", "delivery_id", delivery.ID, "attempts", delivery.Attempts)
+	return nil
+}
+
+// RetryDelay implements RetryDelayer, the failed deliveries are retried with
+// exponential backoff so that a receiver that is down is not flooded.
+func (w *WebhookDelivery) RetryDelay(n int) time.Duration {
+	return retryDelay(n)
+}
+
+// HandleFailure implements FailureHandler, it moves the delivery to the
+// dead-letter state once all retries are exhausted.
+func (w *WebhookDelivery) HandleFailure(ctx context.Context, argsJSON json.RawMessage, jobErr error) error {
+	var args webhookDeliveryArgs
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return ctxerr.Wrap(ctx, err, "unmarshal args")
+	}
+
+	delivery, err := w.Datastore.WebhookDelivery(ctx, args.DeliveryID)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get webhook delivery")
+	}
+	if delivery.Status == fleet.WebhookDeliverySuccess {
+		return nil
+	}
+	delivery.Status = fleet.WebhookDeliveryDeadLetter
+	if err := w.Datastore.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return ctxerr.Wrap(ctx, err, "update webhook delivery")
+	}
+
+	level.Info(w.Log).Log("msg", "webhook delivery moved to dead letter", "delivery_id", delivery.ID, "url", delivery.URL, "err", jobErr)
+	return nil
+}
+
+// DeliverWebhook records a new delivery of the provided payload to the url
+// and makes a first attempt to deliver it immediately, signed with the secret
+// if one is provided. If that attempt fails, a job is queued to retry the
+// delivery via the worker, with exponential backoff. An error is returned only
+// if the delivery could not be recorded or queued, a failed attempt that will
+// be retried is not an error.
+func DeliverWebhook(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, webhookType string, teamID *uint,
+	url, secret string, payload interface{}) (*fleet.WebhookDelivery, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "marshal payload")
+	}
+
+	delivery, err := ds.NewWebhookDelivery(ctx, &fleet.WebhookDelivery{
+		WebhookType: webhookType,
+		TeamID:      teamID,
+		URL:         url,
+		Payload:     payloadJSON,
+		Status:      fleet.WebhookDeliveryPending,
+	})
+	if err != nil {
+		return nil, ctxerr.Wrap(ctx, err, "create webhook delivery")
+	}
+	return delivery, deliverOrQueueRetry(ctx, ds, logger, delivery, secret)
+}
+
+// RedeliverWebhook attempts to deliver an existing delivery again, typically
+// one that was moved to the dead-letter state. As for DeliverWebhook, a retry
+// job is queued if that attempt fails. Only the deliveries in a final state
+// (success or dead letter) can be redelivered, ErrWebhookDeliveryNotFinal is
+// returned for the others as their retry job is still pending. A delivery of
+// a deleted team returns a NotFound error.
+func RedeliverWebhook(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, delivery *fleet.WebhookDelivery) error {
+	if delivery.Status != fleet.WebhookDeliverySuccess && delivery.Status != fleet.WebhookDeliveryDeadLetter {
+		return ErrWebhookDeliveryNotFinal
+	}
+	secret, err := webhookDeliverySecret(ctx, ds, delivery)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "get webhook secret")
+	}
+	delivery.Status = fleet.WebhookDeliveryPending
+	return deliverOrQueueRetry(ctx, ds, logger, delivery, secret)
+}
+
+// ErrWebhookDeliveryNotFinal is returned when redelivering a delivery that is
+// still pending or being retried.
+var ErrWebhookDeliveryNotFinal = errors.New("webhook delivery is still being retried")
+
+// webhookDeliverySecret returns the secret currently configured for the
+// webhook settings that generated the delivery, so that a retry is signed
+// with the up-to-date secret if it was rotated in the meantime.
+func webhookDeliverySecret(ctx context.Context, ds fleet.Datastore, delivery *fleet.WebhookDelivery) (string, error) {
+	if delivery.TeamID != nil {
+		tm, err := ds.Team(ctx, *delivery.TeamID)
+		if err != nil {
+			return "", err
+		}
+		return tm.Config.WebhookSettings.SecretFor(delivery.WebhookType), nil
+	}
+
+	ac, err := ds.AppConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ac.WebhookSettings.SecretFor(delivery.WebhookType), nil
+}
+
+func deliverOrQueueRetry(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, delivery *fleet.WebhookDelivery, secret string) error {
+	attemptErr := attemptWebhookDelivery(ctx, ds, delivery, secret)
+	if attemptErr == nil {
+		return nil
+	}
+
+	level.Info(logger).Log("msg", "webhook delivery failed, will retry", "delivery_id", delivery.ID, "url", delivery.URL, "err", attemptErr)
+
+	argsJSON, err := json.Marshal(webhookDeliveryArgs{DeliveryID: delivery.ID})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "marshal args")
+	}
+	job, err := ds.NewJob(ctx, &fleet.Job{
+		Name:      webhookDeliveryName,
+		Args:      (*json.RawMessage)(&argsJSON),
+		State:     fleet.JobStateQueued,
+		NotBefore: time.Now().Add(retryDelay(1)),
+	})
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "queueing job")
+	}
+	level.Debug(logger).Log("job_id", job.ID)
+	return nil
+}
+
+// attemptWebhookDelivery makes a single attempt to deliver the webhook and
+// saves the result of that attempt on the delivery. It returns the error of
+// the attempt if it failed, or the error to save it.
+func attemptWebhookDelivery(ctx context.Context, ds fleet.Datastore, delivery *fleet.WebhookDelivery, secret string) error {
+	statusCode, latency, attemptErr := postWebhook(ctx, delivery.URL, secret, delivery.Payload)
+
+	now := time.Now()
+	latencyMs := latency.Milliseconds()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.LastLatencyMs = &latencyMs
+	delivery.LastStatusCode = nil
+	if statusCode > 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+	delivery.Status = fleet.WebhookDeliverySuccess
+	delivery.Error = ""
+	if attemptErr != nil {
+		delivery.Status = fleet.WebhookDeliveryFailed
+		delivery.Error = attemptErr.Error()
+	}
+
+	if err := ds.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		return ctxerr.Wrap(ctx, err, "update webhook delivery")
+	}
+	return attemptErr
+}
+
+// postWebhook sends the JSON body to the url, signed with the secret if it is
+// not empty, and returns the status code of the response (0 if no response
+// was received) and the latency of the request.
+func postWebhook(ctx context.Context, url, secret string, body []byte) (int, time.Duration, error) {
+	client := fleethttp.NewClient(fleethttp.WithTimeout(webhookDeliveryTimeout))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		webhooksig.SetHeaders(req.Header, secret, time.Now(), body)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return 0, latency, fmt.Errorf("failed to POST to %s: %s, request-size=%d", url, err, len(body))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, latency, fmt.Errorf("error posting to %s: %d. %s", url, resp.StatusCode, string(respBody))
+	}
+	return resp.StatusCode, latency, nil
+}
@@ -0,0 +1,233 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fleetdm/fleet/v4/pkg/webhooksig"
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	var statusCode int
+	var gotBody string
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotBody = string(body)
+		gotHeader = r.Header
+		w.WriteHeader(statusCode)
+	}))
+	defer srv.Close()
+
+	// in-memory datastore of deliveries and jobs
+	ds := new(mock.Store)
+	deliveries := make(map[uint]*fleet.WebhookDelivery)
+	var jobs []*fleet.Job
+	ds.NewWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) (*fleet.WebhookDelivery, error) {
+		delivery.ID = uint(len(deliveries) + 1)
+		cp := *delivery
+		deliveries[delivery.ID] = &cp
+		return delivery, nil
+	}
+	ds.UpdateWebhookDeliveryFunc = func(ctx context.Context, delivery *fleet.WebhookDelivery) error {
+		cp := *delivery
+		deliveries[delivery.ID] = &cp
+		return nil
+	}
+	ds.WebhookDeliveryFunc = func(ctx context.Context, id uint) (*fleet.WebhookDelivery, error) {
+		cp := *deliveries[id]
+		return &cp, nil
+	}
+	ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+		job.ID = uint(len(jobs) + 1)
+		jobs = append(jobs, job)
+		return job, nil
+	}
+	ds.GetQueuedJobsFunc = func(ctx context.Context, maxNumJobs int) ([]*fleet.Job, error) {
+		// ignore not_before so that retries can be processed immediately
+		var queued []*fleet.Job
+		for _, j := range jobs {
+			if j.State == fleet.JobStateQueued {
+				queued = append(queued, j)
+			}
+		}
+		return queued, nil
+	}
+	ds.UpdateJobFunc = func(ctx context.Context, id uint, job *fleet.Job) (*fleet.Job, error) {
+		return job, nil
+	}
+	var globalSecret string
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{WebhookSettings: fleet.WebhookSettings{
+			FailingPoliciesWebhook: fleet.FailingPoliciesWebhookSettings{Secret: globalSecret},
+		}}, nil
+	}
+	deletedTeamID := uint(99)
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		if tid == deletedTeamID {
+			return nil, &mock.Error{Message: "not found"}
+		}
+		return &fleet.Team{ID: tid, Config: fleet.TeamConfig{WebhookSettings: fleet.TeamWebhookSettings{
+			FailingPoliciesWebhook: fleet.FailingPoliciesWebhookSettings{Secret: "team-secret"},
+		}}}, nil
+	}
+
+	w := NewWorker(ds, logger)
+	w.Register(&WebhookDelivery{Datastore: ds, Log: logger})
+
+	t.Run("success on first attempt", func(t *testing.T) {
+		statusCode = http.StatusOK
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeHostStatus, nil, srv.URL, "", map[string]string{"text": "a"})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"text": "a"}`, gotBody)
+
+		got := deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliverySuccess, got.Status)
+		require.Equal(t, 1, got.Attempts)
+		require.Equal(t, http.StatusOK, *got.LastStatusCode)
+		require.NotNil(t, got.LastLatencyMs)
+		require.NotNil(t, got.LastAttemptAt)
+		require.Empty(t, got.Error)
+		require.Empty(t, jobs)
+	})
+
+	t.Run("success on retry", func(t *testing.T) {
+		statusCode = http.StatusServiceUnavailable
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeFailingPolicies, nil, srv.URL, "", map[string]string{"text": "b"})
+		require.NoError(t, err)
+
+		got := deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliveryFailed, got.Status)
+		require.Equal(t, 1, got.Attempts)
+		require.Equal(t, http.StatusServiceUnavailable, *got.LastStatusCode)
+		require.Contains(t, got.Error, "503")
+
+		// a retry job was queued with a delay
+		require.Len(t, jobs, 1)
+		require.Equal(t, webhookDeliveryName, jobs[0].Name)
+		require.True(t, jobs[0].NotBefore.After(time.Now()))
+
+		statusCode = http.StatusOK
+		require.NoError(t, w.ProcessJobs(ctx))
+		require.Equal(t, fleet.JobStateSuccess, jobs[0].State)
+
+		got = deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliverySuccess, got.Status)
+		require.Equal(t, 2, got.Attempts)
+		require.Empty(t, got.Error)
+		jobs = nil
+	})
+
+	t.Run("dead letter and redeliver", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeVulnerabilities, nil, srv.URL, "", map[string]string{"text": "c"})
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		// the worker stops a ProcessJobs batch once it receives the same job
+		// again, so run it once per retry.
+		for i := 0; i <= maxRetries; i++ {
+			require.NoError(t, w.ProcessJobs(ctx))
+		}
+		require.Equal(t, fleet.JobStateFailure, jobs[0].State)
+
+		got := deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliveryDeadLetter, got.Status)
+		require.Equal(t, maxRetries+2, got.Attempts) // first attempt + all job runs
+
+		// redeliver it explicitly, now that the receiver is back
+		statusCode = http.StatusOK
+		require.NoError(t, RedeliverWebhook(ctx, ds, logger, got))
+		got = deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliverySuccess, got.Status)
+		require.Equal(t, maxRetries+3, got.Attempts)
+		require.Len(t, jobs, 1)
+		jobs = nil
+	})
+
+	t.Run("unreachable receiver", func(t *testing.T) {
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeHostStatus, nil, "http://127.0.0.1:1", "", json.RawMessage(`{}`))
+		require.NoError(t, err)
+
+		got := deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliveryFailed, got.Status)
+		require.Nil(t, got.LastStatusCode)
+		require.Contains(t, got.Error, "failed to POST")
+		require.Len(t, jobs, 1)
+		jobs = nil
+	})
+
+	t.Run("signed deliveries", func(t *testing.T) {
+		// first attempt is signed with the provided secret
+		statusCode = http.StatusInternalServerError
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeFailingPolicies, nil, srv.URL, "s3cr3t", map[string]string{"text": "d"})
+		require.NoError(t, err)
+		require.NoError(t, webhooksig.Verify("s3cr3t", gotHeader, []byte(gotBody), webhooksig.DefaultTolerance))
+		require.Len(t, jobs, 1)
+
+		// the secret was rotated since, the retry uses the new one
+		globalSecret = "rotated"
+		statusCode = http.StatusOK
+		require.NoError(t, w.ProcessJobs(ctx))
+		require.Equal(t, fleet.WebhookDeliverySuccess, deliveries[d.ID].Status)
+		require.NoError(t, webhooksig.Verify("rotated", gotHeader, []byte(gotBody), webhooksig.DefaultTolerance))
+		jobs = nil
+
+		// a team delivery uses the team's secret
+		statusCode = http.StatusOK
+		d, err = DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeFailingPolicies, ptr.Uint(1), srv.URL, "", map[string]string{"text": "e"})
+		require.NoError(t, err)
+		require.Empty(t, gotHeader.Get(webhooksig.SignatureHeader))
+		require.NoError(t, RedeliverWebhook(ctx, ds, logger, deliveries[d.ID]))
+		require.NoError(t, webhooksig.Verify("team-secret", gotHeader, []byte(gotBody), webhooksig.DefaultTolerance))
+	})
+
+	t.Run("pending deliveries are not redelivered", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeHostStatus, nil, srv.URL, "", map[string]string{"text": "f"})
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		err = RedeliverWebhook(ctx, ds, logger, deliveries[d.ID])
+		require.ErrorIs(t, err, ErrWebhookDeliveryNotFinal)
+		require.Equal(t, 1, deliveries[d.ID].Attempts)
+		require.Len(t, jobs, 1)
+		jobs = nil
+	})
+
+	t.Run("deleted team", func(t *testing.T) {
+		statusCode = http.StatusInternalServerError
+		d, err := DeliverWebhook(ctx, ds, logger, fleet.WebhookTypeFailingPolicies, &deletedTeamID, srv.URL, "", map[string]string{"text": "g"})
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		// the retry is abandoned without further attempts
+		statusCode = http.StatusOK
+		require.NoError(t, w.ProcessJobs(ctx))
+		require.Equal(t, fleet.JobStateSuccess, jobs[0].State)
+		got := deliveries[d.ID]
+		require.Equal(t, fleet.WebhookDeliveryDeadLetter, got.Status)
+		require.Equal(t, 1, got.Attempts)
+		require.Equal(t, "team deleted", got.Error)
+
+		// and it can't be redelivered
+		err = RedeliverWebhook(ctx, ds, logger, got)
+		require.True(t, fleet.IsNotFound(err))
+		require.Equal(t, 1, deliveries[d.ID].Attempts)
+		jobs = nil
+	})
+}
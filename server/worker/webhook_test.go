@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fleetdm/fleet/v4/server/fleet"
+	"github.com/fleetdm/fleet/v4/server/mock"
+	"github.com/fleetdm/fleet/v4/server/ptr"
+	"github.com/fleetdm/fleet/v4/server/service/externalsvc"
+	kitlog "github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookRun(t *testing.T) {
+	ds := new(mock.Store)
+	ds.HostsByCVEFunc = func(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{
+				ID:       1,
+				Hostname: "test",
+			},
+		}, nil
+	}
+
+	var bodyTpl string
+	var srvURL string
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Webhook: []*fleet.WebhookIntegration{
+				{URL: srvURL, BodyTemplate: bodyTpl, EnableSoftwareVulnerabilities: true, EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		if tid != 123 {
+			return nil, errors.New("unexpected team id")
+		}
+		return &fleet.Team{
+			ID: 123,
+			Config: fleet.TeamConfig{
+				Integrations: fleet.TeamIntegrations{
+					Webhook: []*fleet.TeamWebhookIntegration{
+						{URL: srvURL, EnableFailingPolicies: true},
+					},
+				},
+			},
+		}, nil
+	}
+
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(501)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	srvURL = srv.URL
+
+	webhook := &Webhook{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.WebhookOptions) (WebhookClient, error) {
+			return externalsvc.NewWebhookClient(opts)
+		},
+	}
+
+	t.Run("vuln default body", func(t *testing.T) {
+		bodyTpl = ""
+		err := webhook.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+		require.NoError(t, err)
+		require.Contains(t, gotBody, `"cve":"CVE-1234-5678"`)
+		require.Contains(t, gotBody, `"details_link":"https://nvd.nist.gov/vuln/detail/CVE-1234-5678"`)
+		require.Contains(t, gotBody, `"hosts_affected":[{"id":1,"hostname":"test","url":"http://example.com/hosts/1"}]`)
+	})
+
+	t.Run("failing global policy default body", func(t *testing.T) {
+		bodyTpl = ""
+		err := webhook.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 1, "policy_name": "test-policy", "hosts": []}}`))
+		require.NoError(t, err)
+		require.Contains(t, gotBody, `"policy":{"id":1,"name":"test-policy","team_id":null}`)
+		require.Contains(t, gotBody, `"hosts":[]`)
+	})
+
+	t.Run("failing team policy with template", func(t *testing.T) {
+		bodyTpl = `{"summary": {{ json (printf "%s failed on %d host(s)" .PolicyName (len .Hosts)) }}, "team": {{ .TeamID }}}`
+		err := webhook.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 2, "policy_name": "test \"quoted\"", "team_id": 123, "hosts": [{"id": 1, "hostname": "test-1"}, {"id": 2, "hostname": "test-2"}]}}`))
+		require.NoError(t, err)
+		require.Equal(t, `{"summary": "test \"quoted\" failed on 2 host(s)", "team": 123}`, gotBody)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		bodyTpl = `{{ .Invalid`
+		err := webhook.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "render webhook body")
+	})
+}
+
+func TestWebhookRunDisabled(t *testing.T) {
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Webhook: []*fleet.WebhookIntegration{
+				{URL: "http://example.com/hook", EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+
+	var newClientCalls int
+	webhook := &Webhook{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.WebhookOptions) (WebhookClient, error) {
+			newClientCalls++
+			return externalsvc.NewWebhookClient(opts)
+		},
+	}
+
+	// vulnerabilities are not enabled, so this is a no-op
+	err := webhook.Run(context.Background(), json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+	require.NoError(t, err)
+	require.Equal(t, 0, newClientCalls)
+	require.False(t, ds.HostsByCVEFuncInvoked)
+}
+
+func TestWebhookQueueJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	t.Run("vuln success", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			require.Equal(t, webhookName, job.Name)
+			return job, nil
+		}
+		err := QueueWebhookVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-1234-5678"}})
+		require.NoError(t, err)
+		require.True(t, ds.NewJobFuncInvoked)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("failing policy team success", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			require.Contains(t, string(*job.Args), `"team_id"`)
+			return job, nil
+		}
+		err := QueueWebhookFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1", TeamID: ptr.Uint(2)}}, []fleet.PolicySetHost{{ID: 1, Hostname: "h1"}})
+		require.NoError(t, err)
+		require.True(t, ds.NewJobFuncInvoked)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return nil, io.EOF
+		}
+		err := QueueWebhookFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{{ID: 1, Hostname: "h1"}})
+		require.Error(t, err)
+		require.ErrorIs(t, err, io.EOF)
+		ds.NewJobFuncInvoked = false
+	})
+
+	t.Run("no host", func(t *testing.T) {
+		ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+			return job, nil
+		}
+		err := QueueWebhookFailingPolicyJob(ctx, ds, logger,
+			&fleet.Policy{PolicyData: fleet.PolicyData{ID: 1, Name: "p1"}}, []fleet.PolicySetHost{})
+		require.NoError(t, err)
+		require.False(t, ds.NewJobFuncInvoked)
+	})
+}
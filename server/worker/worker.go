@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/contexts/ctxerr"
 	"github.com/fleetdm/fleet/v4/server/fleet"
@@ -13,6 +14,10 @@ import (
 
 const (
 	maxRetries = 5
+	// retryBaseDelay is the delay before the first retry of a failed job that
+	// implements RetryDelayer with exponential backoff, it doubles on each
+	// subsequent retry.
+	retryBaseDelay = time.Minute
 	// nvdCVEURL is the base link to a CVE on the NVD website, only the CVE code
 	// needs to be appended to make it a valid link.
 	nvdCVEURL = "https://nvd.nist.gov/vuln/detail/"
@@ -21,8 +26,9 @@ const (
 const (
 	// types of integrations - jobs like Jira and Zendesk support different
 	// integrations, this identifies the integration type of a message.
-	intgTypeVuln          = "vuln"
-	intgTypeFailingPolicy = "failingPolicy"
+	intgTypeVuln           = "vuln"
+	intgTypeFailingPolicy  = "failingPolicy"
+	intgTypeResolvedPolicy = "resolvedPolicy"
 )
 
 // Job defines an interface for jobs that can be run by the Worker
@@ -34,6 +40,33 @@ type Job interface {
 	Run(ctx context.Context, argsJSON json.RawMessage) error
 }
 
+// FailureHandler is an optional interface that a Job can implement to be
+// notified when a job has permanently failed, after all retries have been
+// exhausted.
+type FailureHandler interface {
+	// HandleFailure is called with the args of the failed job and the error of
+	// its last run.
+	HandleFailure(ctx context.Context, argsJSON json.RawMessage, err error) error
+}
+
+// RetryDelayer is an optional interface that a Job can implement to delay its
+// retries. The jobs that do not implement it are retried on the next run of
+// the worker.
+type RetryDelayer interface {
+	// RetryDelay returns the delay to wait before running the job again after
+	// its n-th failure (starting at 1).
+	RetryDelay(n int) time.Duration
+}
+
+// retryDelay returns the delay to wait before running a job again after its
+// n-th failure (starting at 1), using exponential backoff.
+func retryDelay(n int) time.Duration {
+	if n < 1 {
+		n = 1
+	}
+	return retryBaseDelay << (n - 1)
+}
+
 // failingPolicyArgs are the args common to all integrations that can process
 // failing policies.
 type failingPolicyArgs struct {
@@ -41,6 +74,20 @@ type failingPolicyArgs struct {
 	PolicyName string                `json:"policy_name"`
 	Hosts      []fleet.PolicySetHost `json:"hosts"`
 	TeamID     *uint                 `json:"team_id,omitempty"`
+	// Resolution is the resolution text of the policy, only set for the
+	// integrations that display it (Slack and Microsoft Teams).
+	Resolution string `json:"resolution,omitempty"`
+}
+
+// resolvedPolicyArgs are the args common to the integrations that resolve the
+// ticket created for a failing policy once a host passes that policy again.
+type resolvedPolicyArgs struct {
+	PolicyID uint   `json:"policy_id"`
+	TeamID   *uint  `json:"team_id,omitempty"`
+	HostID   uint   `json:"host_id"`
+	Hostname string `json:"hostname"`
+	// TicketID is the Jira issue key or the Zendesk ticket ID.
+	TicketID string `json:"ticket_id"`
 }
 
 // Worker runs jobs. NOT SAFE FOR CONCURRENT USE.
@@ -123,10 +170,16 @@ func (w *Worker) ProcessJobs(ctx context.Context) error {
 				level.Error(log).Log("msg", "process job", "err", err)
 				job.Error = err.Error()
 				if job.Retries < maxRetries {
-					level.Debug(log).Log("msg", "will retry job")
 					job.Retries += 1
+					if rd, ok := w.registry[job.Name].(RetryDelayer); ok {
+						job.NotBefore = time.Now().Add(rd.RetryDelay(job.Retries))
+					}
+					level.Debug(log).Log("msg", "will retry job", "not_before", job.NotBefore)
 				} else {
 					job.State = fleet.JobStateFailure
+					if err := w.handleFailure(ctx, job, err); err != nil {
+						level.Error(log).Log("msg", "handle job failure", "err", err)
+					}
 				}
 			} else {
 				job.State = fleet.JobStateSuccess
@@ -145,6 +198,19 @@ func (w *Worker) ProcessJobs(ctx context.Context) error {
 	return nil
 }
 
+func (w *Worker) handleFailure(ctx context.Context, job *fleet.Job, jobErr error) error {
+	fh, ok := w.registry[job.Name].(FailureHandler)
+	if !ok {
+		return nil
+	}
+
+	var args json.RawMessage
+	if job.Args != nil {
+		args = *job.Args
+	}
+	return fh.HandleFailure(ctx, args, jobErr)
+}
+
 func (w *Worker) processJob(ctx context.Context, job *fleet.Job) error {
 	j, ok := w.registry[job.Name]
 	if !ok {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/fleetdm/fleet/v4/server/fleet"
 	"github.com/fleetdm/fleet/v4/server/mock"
@@ -103,6 +104,10 @@ func TestWorkerRetries(t *testing.T) {
 		if job.State == fleet.JobStateFailure {
 			jobFailed = true
 			assert.Equal(t, maxRetries, job.Retries)
+		} else {
+			// the job does not implement RetryDelayer, so its retries are not
+			// delayed
+			assert.True(t, job.NotBefore.IsZero())
 		}
 
 		return job, nil
@@ -234,3 +239,100 @@ func TestWorkerMiddleJobFails(t *testing.T) {
 	require.Equal(t, 2, jobs[1].Retries)
 	require.Equal(t, 4, jobCallCount)
 }
+
+type testFailureHandlerJob struct {
+	testJob
+	handleFailure func(ctx context.Context, argsJSON json.RawMessage, err error) error
+}
+
+func (t testFailureHandlerJob) HandleFailure(ctx context.Context, argsJSON json.RawMessage, err error) error {
+	return t.handleFailure(ctx, argsJSON, err)
+}
+
+func TestWorkerFailureHandler(t *testing.T) {
+	ds := new(mock.Store)
+
+	argsJSON := json.RawMessage(`{"arg1":"foo"}`)
+	theJob := &fleet.Job{
+		ID:      1,
+		Name:    "test",
+		Args:    &argsJSON,
+		State:   fleet.JobStateQueued,
+		Retries: maxRetries,
+	}
+	ds.GetQueuedJobsFunc = func(ctx context.Context, maxNumJobs int) ([]*fleet.Job, error) {
+		if theJob.State == fleet.JobStateQueued {
+			return []*fleet.Job{theJob}, nil
+		}
+		return nil, nil
+	}
+	ds.UpdateJobFunc = func(ctx context.Context, id uint, job *fleet.Job) (*fleet.Job, error) {
+		return job, nil
+	}
+
+	w := NewWorker(ds, kitlog.NewNopLogger())
+
+	var handledErr error
+	j := testFailureHandlerJob{
+		testJob: testJob{
+			name: "test",
+			run: func(ctx context.Context, argsJSON json.RawMessage) error {
+				return errors.New("unknown error")
+			},
+		},
+		handleFailure: func(ctx context.Context, argsJSON json.RawMessage, err error) error {
+			assert.Equal(t, json.RawMessage(`{"arg1":"foo"}`), argsJSON)
+			handledErr = err
+			return nil
+		},
+	}
+	w.Register(j)
+
+	// the job has no retry left, so it fails permanently
+	err := w.ProcessJobs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, fleet.JobStateFailure, theJob.State)
+	require.Error(t, handledErr)
+	require.Equal(t, "unknown error", handledErr.Error())
+}
+
+type testRetryDelayerJob struct {
+	testJob
+}
+
+func (t testRetryDelayerJob) RetryDelay(n int) time.Duration {
+	return retryDelay(n)
+}
+
+func TestWorkerRetryDelayer(t *testing.T) {
+	ds := new(mock.Store)
+
+	argsJSON := json.RawMessage(`{"arg1":"foo"}`)
+	theJob := &fleet.Job{
+		ID:    1,
+		Name:  "test",
+		Args:  &argsJSON,
+		State: fleet.JobStateQueued,
+	}
+	ds.GetQueuedJobsFunc = func(ctx context.Context, maxNumJobs int) ([]*fleet.Job, error) {
+		return []*fleet.Job{theJob}, nil
+	}
+	ds.UpdateJobFunc = func(ctx context.Context, id uint, job *fleet.Job) (*fleet.Job, error) {
+		return job, nil
+	}
+
+	w := NewWorker(ds, kitlog.NewNopLogger())
+	w.Register(testRetryDelayerJob{testJob{
+		name: "test",
+		run: func(ctx context.Context, argsJSON json.RawMessage) error {
+			return errors.New("unknown error")
+		},
+	}})
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, w.ProcessJobs(context.Background()))
+		require.Equal(t, i, theJob.Retries)
+		// retries are delayed with exponential backoff
+		assert.WithinDuration(t, time.Now().Add(retryBaseDelay<<(i-1)), theJob.NotBefore, time.Minute)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"sync"
 	"text/template"
 
@@ -25,6 +26,7 @@ var zendeskTemplates = struct {
 	VulnDescription          *template.Template
 	FailingPolicySummary     *template.Template
 	FailingPolicyDescription *template.Template
+	ResolvedPolicyComment    *template.Template
 }{
 	VulnSummary: template.Must(template.New("").Parse(
 		`Vulnerability {{ .CVE }} detected on {{ len .Hosts }} host(s)`,
@@ -67,6 +69,16 @@ View hosts that failed {{ .PolicyName }} on the [**Hosts**]({{ .FleetURL }}/host
 ----
 
 This issue was created automatically by your Fleet Zendesk integration.
+`)),
+
+	ResolvedPolicyComment: template.Must(template.New("").Parse(
+		`Host [{{ .Hostname }}]({{ .FleetURL }}/hosts/{{ .HostID }}) is now passing this policy.
+{{ if .Resolved }}
+All hosts are now passing this policy, solving this ticket.
+{{ end }}
+----
+
+This comment was added automatically by your Fleet Zendesk integration.
 `)),
 }
 
@@ -89,6 +101,7 @@ type zendeskFailingPoliciesTplArgs struct {
 // to Zendesk.
 type ZendeskClient interface {
 	CreateZendeskTicket(ctx context.Context, ticket *zendesk.Ticket) (*zendesk.Ticket, error)
+	UpdateZendeskTicket(ctx context.Context, ticketID int64, ticket *zendesk.Ticket) (*zendesk.Ticket, error)
 	ZendeskConfigMatches(opts *externalsvc.ZendeskOptions) bool
 }
 
@@ -112,10 +125,22 @@ func (z *Zendesk) getClient(ctx context.Context, args zendeskArgs) (ZendeskClien
 	var teamID uint
 	var useTeamCfg bool
 
+	var argsTeamID *uint
 	intgType := args.integrationType()
+	switch intgType {
+	case intgTypeVuln:
+		argsTeamID = args.TeamID
+	case intgTypeFailingPolicy:
+		argsTeamID = args.FailingPolicy.TeamID
+	case intgTypeResolvedPolicy:
+		// the ticket is solved with the failing policies integration that
+		// created it.
+		intgType = intgTypeFailingPolicy
+		argsTeamID = args.ResolvedPolicy.TeamID
+	}
 	key := intgType + ":"
-	if intgType == intgTypeFailingPolicy && args.FailingPolicy.TeamID != nil {
-		teamID = *args.FailingPolicy.TeamID
+	if argsTeamID != nil {
+		teamID = *argsTeamID
 		useTeamCfg = true
 		key += fmt.Sprint(teamID)
 	}
@@ -141,7 +166,8 @@ func (z *Zendesk) getClient(ctx context.Context, args zendeskArgs) (ZendeskClien
 		}
 
 		for _, intg := range intgs.Zendesk {
-			if intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies {
+			if (intgType == intgTypeVuln && intg.EnableSoftwareVulnerabilities) ||
+				(intgType == intgTypeFailingPolicy && intg.EnableFailingPolicies) {
 				opts = &externalsvc.ZendeskOptions{
 					URL:      intg.URL,
 					Email:    intg.Email,
@@ -199,11 +225,19 @@ func (z *Zendesk) Name() string {
 
 // zendeskArgs are the arguments for the Zendesk integration job.
 type zendeskArgs struct {
-	CVE           string             `json:"cve,omitempty"`
-	FailingPolicy *failingPolicyArgs `json:"failing_policy,omitempty"`
+	CVE string `json:"cve,omitempty"`
+	// TeamID is the team of the Zendesk integration to use for a vulnerability,
+	// only the hosts of that team are listed in the ticket. It is nil for the
+	// global integration.
+	TeamID         *uint               `json:"team_id,omitempty"`
+	FailingPolicy  *failingPolicyArgs  `json:"failing_policy,omitempty"`
+	ResolvedPolicy *resolvedPolicyArgs `json:"resolved_policy,omitempty"`
 }
 
 func (a *zendeskArgs) integrationType() string {
+	if a.ResolvedPolicy != nil {
+		return intgTypeResolvedPolicy
+	}
 	if a.FailingPolicy == nil {
 		return intgTypeVuln
 	}
@@ -233,6 +267,8 @@ func (z *Zendesk) Run(ctx context.Context, argsJSON json.RawMessage) error {
 		return z.runVuln(ctx, cli, args)
 	case intgTypeFailingPolicy:
 		return z.runFailingPolicy(ctx, cli, args)
+	case intgTypeResolvedPolicy:
+		return z.runResolvedPolicy(ctx, cli, args)
 	default:
 		return ctxerr.Errorf(ctx, "unknown integration type: %v", intgType)
 	}
@@ -243,6 +279,13 @@ func (z *Zendesk) runVuln(ctx context.Context, cli ZendeskClient, args zendeskAr
 	if err != nil {
 		return ctxerr.Wrap(ctx, err, "find hosts by cve")
 	}
+	hosts = filterHostsByTeam(hosts, args.TeamID)
+	if len(hosts) == 0 && args.TeamID != nil {
+		// the hosts of the team are not affected anymore (e.g. they were moved
+		// to another team since the job was queued).
+		level.Debug(z.Log).Log("msg", "skipping cve, no affected host in team", "cve", args.CVE, "team_id", *args.TeamID)
+		return nil
+	}
 
 	tplArgs := &zendeskVulnTplArgs{
 		NVDURL:   nvdCVEURL,
@@ -277,6 +320,14 @@ func (z *Zendesk) runFailingPolicy(ctx context.Context, cli ZendeskClient, args
 		return err
 	}
 
+	// record the ticket so that it gets solved when the hosts pass the policy
+	// again. The ticket is created at this point, so do not fail the job (it
+	// would create a duplicate ticket when retried).
+	if err := z.Datastore.NewPolicyAutomationTickets(ctx, zendeskName, strconv.FormatInt(createdTicket.ID, 10), args.FailingPolicy.PolicyID,
+		args.FailingPolicy.TeamID, policyTicketHostIDs(args.FailingPolicy.Hosts)); err != nil {
+		level.Error(z.Log).Log("msg", "failed to record zendesk ticket for failing policy", "ticket_id", createdTicket.ID, "err", err)
+	}
+
 	attrs := []interface{}{
 		"msg", "created zendesk ticket for failing policy",
 		"policy_id", args.FailingPolicy.PolicyID,
@@ -290,6 +341,64 @@ func (z *Zendesk) runFailingPolicy(ctx context.Context, cli ZendeskClient, args
 	return nil
 }
 
+func (z *Zendesk) runResolvedPolicy(ctx context.Context, cli ZendeskClient, args zendeskArgs) error {
+	rp := args.ResolvedPolicy
+	ticketID, err := strconv.ParseInt(rp.TicketID, 10, 64)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "parse ticket id")
+	}
+
+	openHostIDs, err := z.Datastore.ListPolicyAutomationTicketOpenHosts(ctx, zendeskName, rp.TicketID)
+	if err != nil {
+		return ctxerr.Wrap(ctx, err, "list policy automation ticket open hosts")
+	}
+	resolved, ok := resolvesPolicyTicket(openHostIDs, rp.HostID)
+	if !ok {
+		level.Debug(z.Log).Log("msg", "skipping, zendesk ticket already resolved for host", "ticket_id", rp.TicketID, "host_id", rp.HostID)
+		return nil
+	}
+
+	tplArgs := &resolvedPolicyTplArgs{
+		FleetURL: z.FleetURL,
+		HostID:   rp.HostID,
+		Hostname: rp.Hostname,
+		Resolved: resolved,
+	}
+	var buf bytes.Buffer
+	if err := zendeskTemplates.ResolvedPolicyComment.Execute(&buf, tplArgs); err != nil {
+		return ctxerr.Wrap(ctx, err, "execute comment template")
+	}
+
+	ticket := &zendesk.Ticket{
+		Comment: &zendesk.TicketComment{Body: buf.String()},
+	}
+	if tplArgs.Resolved {
+		ticket.Status = "solved"
+	}
+	if _, err := cli.UpdateZendeskTicket(ctx, ticketID, ticket); err != nil {
+		return ctxerr.Wrap(ctx, err, "update ticket")
+	}
+
+	// the ticket is marked as resolved for the host only once it is updated,
+	// so that the job is retried otherwise.
+	if err := z.Datastore.ResolvePolicyAutomationTicket(ctx, zendeskName, rp.TicketID, rp.HostID); err != nil {
+		return ctxerr.Wrap(ctx, err, "resolve policy automation ticket")
+	}
+
+	attrs := []interface{}{
+		"msg", "updated zendesk ticket for passing policy",
+		"policy_id", rp.PolicyID,
+		"host_id", rp.HostID,
+		"ticket_id", rp.TicketID,
+		"resolved", tplArgs.Resolved,
+	}
+	if rp.TeamID != nil {
+		attrs = append(attrs, "team_id", *rp.TeamID)
+	}
+	level.Debug(z.Log).Log(attrs...)
+	return nil
+}
+
 func (z *Zendesk) createTemplatedTicket(ctx context.Context, cli ZendeskClient, summaryTpl, descTpl *template.Template, args interface{}) (*zendesk.Ticket, error) {
 	var buf bytes.Buffer
 	if err := summaryTpl.Execute(&buf, args); err != nil {
@@ -340,6 +449,16 @@ func QueueZendeskVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog
 	return nil
 }
 
+// QueueZendeskTeamVulnJobs queues a Zendesk vulnerability job for each of the
+// teams in teamIDs that has hosts affected by a recent vulnerability, to
+// process asynchronously via the worker. Each job uses the Zendesk integration
+// of its team and lists only the hosts of that team.
+func QueueZendeskTeamVulnJobs(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger, recentVulns []fleet.SoftwareVulnerability, teamIDs []uint) error {
+	return queueTeamVulnJobs(ctx, ds, logger, zendeskName, recentVulns, teamIDs, func(cve string, teamID uint) interface{} {
+		return zendeskArgs{CVE: cve, TeamID: &teamID}
+	})
+}
+
 // QueueZendeskFailingPolicyJob queues a Zendesk job for a failing policy to
 // process asynchronously via the worker.
 func QueueZendeskFailingPolicyJob(ctx context.Context, ds fleet.Datastore, logger kitlog.Logger,
@@ -52,6 +52,14 @@ func TestZendeskRun(t *testing.T) {
 		}, nil
 	}
 
+	var gotTicketID string
+	var gotHostIDs []uint
+	ds.NewPolicyAutomationTicketsFunc = func(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+		require.Equal(t, zendeskName, integration)
+		gotTicketID, gotHostIDs = ticketID, hostIDs
+		return nil
+	}
+
 	var expectedSubject, expectedDescription, expectedNotInDescription string
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
@@ -76,7 +84,7 @@ func TestZendeskRun(t *testing.T) {
 		}
 
 		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte(`{}`))
+		w.Write([]byte(`{"ticket": {"id": 987}}`))
 	}))
 	defer srv.Close()
 
@@ -114,6 +122,8 @@ func TestZendeskRun(t *testing.T) {
 		expectedNotInDescription = ""
 		err = zendesk.Run(context.Background(), json.RawMessage(`{"failing_policy":{"policy_id": 2, "policy_name": "test-policy-2", "team_id": 123, "hosts": [{"id": 1, "hostname": "host-1"}, {"id": 2, "hostname": "host-2"}]}}`))
 		require.NoError(t, err)
+		require.Equal(t, "987", gotTicketID)
+		require.Equal(t, []uint{1, 2}, gotHostIDs)
 	})
 }
 
@@ -142,6 +152,96 @@ func TestZendeskQueueVulnJobs(t *testing.T) {
 	})
 }
 
+func TestZendeskRunTeamVuln(t *testing.T) {
+	ds := new(mock.Store)
+	ds.HostsByCVEFunc = func(ctx context.Context, cve string) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{ID: 1, Hostname: "no-team"},
+			{ID: 2, Hostname: "team-1", TeamID: ptr.Uint(1)},
+			{ID: 3, Hostname: "team-2", TeamID: ptr.Uint(2)},
+			{ID: 4, Hostname: "team-2-bis", TeamID: ptr.Uint(2)},
+		}, nil
+	}
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Zendesk: []*fleet.ZendeskIntegration{
+				{GroupID: 1, EnableSoftwareVulnerabilities: true},
+				{GroupID: 2},
+			},
+		}}, nil
+	}
+	ds.TeamFunc = func(ctx context.Context, tid uint) (*fleet.Team, error) {
+		return &fleet.Team{
+			ID: tid,
+			Config: fleet.TeamConfig{
+				Integrations: fleet.TeamIntegrations{
+					Zendesk: []*fleet.TeamZendeskIntegration{
+						{GroupID: 2, EnableSoftwareVulnerabilities: tid == 2},
+					},
+				},
+			},
+		}, nil
+	}
+
+	clients := make(map[int64]*mockZendeskClient)
+	zendeskJob := &Zendesk{
+		FleetURL:  "http://example.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.ZendeskOptions) (ZendeskClient, error) {
+			cli := &mockZendeskClient{opts: *opts}
+			clients[opts.GroupID] = cli
+			return cli, nil
+		},
+	}
+	ctx := context.Background()
+
+	// the global job lists all hosts
+	err := zendeskJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678"}`))
+	require.NoError(t, err)
+	require.Len(t, clients[1].tickets, 1)
+	require.Equal(t, "Vulnerability CVE-1234-5678 detected on 4 host(s)", clients[1].tickets[0].Subject)
+
+	// the team job uses the team integration and lists only the team's hosts
+	err = zendeskJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678","team_id":2}`))
+	require.NoError(t, err)
+	require.Len(t, clients[2].tickets, 1)
+	require.Equal(t, "Vulnerability CVE-1234-5678 detected on 2 host(s)", clients[2].tickets[0].Subject)
+	require.Contains(t, clients[2].tickets[0].Comment.Body, "team-2-bis")
+	require.NotContains(t, clients[2].tickets[0].Comment.Body, "no-team")
+
+	// the integration is not enabled for software vulnerabilities on team 1,
+	// the job is a no-op
+	err = zendeskJob.Run(ctx, json.RawMessage(`{"cve":"CVE-1234-5678","team_id":1}`))
+	require.NoError(t, err)
+	require.Len(t, clients[2].tickets, 1)
+}
+
+func TestZendeskQueueTeamVulnJobs(t *testing.T) {
+	ds := new(mock.Store)
+	ctx := context.Background()
+	logger := kitlog.NewNopLogger()
+
+	ds.HostsBySoftwareIDsFunc = func(ctx context.Context, softwareIDs []uint) ([]*fleet.HostShort, error) {
+		return []*fleet.HostShort{
+			{ID: 1},
+			{ID: 2, TeamID: ptr.Uint(1)},
+			{ID: 3, TeamID: ptr.Uint(2)},
+		}, nil
+	}
+	var jobs []zendeskArgs
+	ds.NewJobFunc = func(ctx context.Context, job *fleet.Job) (*fleet.Job, error) {
+		var args zendeskArgs
+		require.NoError(t, json.Unmarshal(*job.Args, &args))
+		jobs = append(jobs, args)
+		return job, nil
+	}
+
+	err := QueueZendeskTeamVulnJobs(ctx, ds, logger, []fleet.SoftwareVulnerability{{CVE: "CVE-1234-5678", SoftwareID: 1}}, []uint{2})
+	require.NoError(t, err)
+	require.Equal(t, []zendeskArgs{{CVE: "CVE-1234-5678", TeamID: ptr.Uint(2)}}, jobs)
+}
+
 func TestZendeskQueueFailingPolicyJob(t *testing.T) {
 	ds := new(mock.Store)
 	ctx := context.Background()
@@ -196,13 +296,24 @@ func TestZendeskQueueFailingPolicyJob(t *testing.T) {
 }
 
 type mockZendeskClient struct {
-	opts externalsvc.ZendeskOptions
+	opts    externalsvc.ZendeskOptions
+	tickets []*zendesk.Ticket
+	updates map[int64][]*zendesk.Ticket
 }
 
 func (c *mockZendeskClient) CreateZendeskTicket(ctx context.Context, ticket *zendesk.Ticket) (*zendesk.Ticket, error) {
+	c.tickets = append(c.tickets, ticket)
 	return &zendesk.Ticket{}, nil
 }
 
+func (c *mockZendeskClient) UpdateZendeskTicket(ctx context.Context, ticketID int64, ticket *zendesk.Ticket) (*zendesk.Ticket, error) {
+	if c.updates == nil {
+		c.updates = make(map[int64][]*zendesk.Ticket)
+	}
+	c.updates[ticketID] = append(c.updates[ticketID], ticket)
+	return ticket, nil
+}
+
 func (c *mockZendeskClient) ZendeskConfigMatches(opts *externalsvc.ZendeskOptions) bool {
 	return c.opts == *opts
 }
@@ -261,6 +372,10 @@ func TestZendeskRunClientUpdate(t *testing.T) {
 		return &curCfg, nil
 	}
 
+	ds.NewPolicyAutomationTicketsFunc = func(ctx context.Context, integration, ticketID string, policyID uint, teamID *uint, hostIDs []uint) error {
+		return nil
+	}
+
 	var groupIDs []int64
 	zendeskJob := &Zendesk{
 		FleetURL:  "http://example.com",
@@ -298,3 +413,69 @@ func TestZendeskRunClientUpdate(t *testing.T) {
 	require.Equal(t, 5, globalCount) // app config is requested every time
 	require.Equal(t, 3, teamCount)
 }
+
+func TestZendeskRunResolvedPolicy(t *testing.T) {
+	ds := new(mock.Store)
+	ds.AppConfigFunc = func(ctx context.Context) (*fleet.AppConfig, error) {
+		return &fleet.AppConfig{Integrations: fleet.Integrations{
+			Zendesk: []*fleet.ZendeskIntegration{
+				{GroupID: 1, EnableFailingPolicies: true},
+			},
+		}}, nil
+	}
+
+	// ticket 123 has two hosts
+	openHosts := map[uint]bool{1: true, 2: true}
+	ds.ListPolicyAutomationTicketOpenHostsFunc = func(ctx context.Context, integration, ticketID string) ([]uint, error) {
+		require.Equal(t, zendeskName, integration)
+		require.Equal(t, "123", ticketID)
+		var hostIDs []uint
+		for id := range openHosts {
+			hostIDs = append(hostIDs, id)
+		}
+		return hostIDs, nil
+	}
+	ds.ResolvePolicyAutomationTicketFunc = func(ctx context.Context, integration, ticketID string, hostID uint) error {
+		require.Equal(t, zendeskName, integration)
+		require.Equal(t, "123", ticketID)
+		delete(openHosts, hostID)
+		return nil
+	}
+
+	var cli *mockZendeskClient
+	zendeskJob := &Zendesk{
+		FleetURL:  "https://fleetdm.com",
+		Datastore: ds,
+		Log:       kitlog.NewNopLogger(),
+		NewClientFunc: func(opts *externalsvc.ZendeskOptions) (ZendeskClient, error) {
+			cli = &mockZendeskClient{opts: *opts}
+			return cli, nil
+		},
+	}
+	ctx := context.Background()
+
+	// the first host passes, the ticket is commented but not solved
+	err := zendeskJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 1, "hostname": "host-1", "ticket_id": "123"}}`))
+	require.NoError(t, err)
+	require.Len(t, cli.updates[123], 1)
+	require.Contains(t, cli.updates[123][0].Comment.Body, "Host [host-1](https://fleetdm.com/hosts/1) is now passing this policy.")
+	require.Empty(t, cli.updates[123][0].Status)
+	require.False(t, openHosts[1])
+
+	// the ticket is already resolved for the host, so a duplicate job is a
+	// no-op
+	err = zendeskJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 1, "hostname": "host-1", "ticket_id": "123"}}`))
+	require.NoError(t, err)
+	require.Len(t, cli.updates[123], 1)
+
+	// the last host passes, the ticket is solved
+	err = zendeskJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 2, "hostname": "host-2", "ticket_id": "123"}}`))
+	require.NoError(t, err)
+	require.Len(t, cli.updates[123], 2)
+	require.Contains(t, cli.updates[123][1].Comment.Body, "All hosts are now passing this policy, solving this ticket.")
+	require.Equal(t, "solved", cli.updates[123][1].Status)
+
+	// invalid ticket id
+	err = zendeskJob.Run(ctx, json.RawMessage(`{"resolved_policy":{"policy_id": 1, "host_id": 2, "hostname": "host-2", "ticket_id": "abc"}}`))
+	require.Error(t, err)
+}